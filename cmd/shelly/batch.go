@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/germanamz/shelly/pkg/engine"
 )
@@ -17,6 +18,10 @@ func runBatch(args []string) error {
 	shellyDir := fs.String("shelly-dir", ".shelly", "path to .shelly directory")
 	tasksPath := fs.String("tasks", "", "path to input tasks JSONL file (required)")
 	outputPath := fs.String("output", "", "path to output results JSONL file (required)")
+	resume := fs.Bool("resume", false, "resume the previous run with the same --tasks and --output")
+	maxAttempts := fs.Int("max-attempts", engine.DefaultBatchMaxAttempts, "attempts per task before reporting an error")
+	retryDelay := fs.Duration("retry-delay", engine.DefaultBatchRetryDelay, "backoff before the first retry, doubled on each further attempt")
+	concurrency := fs.Int("concurrency", engine.DefaultBatchConcurrency, "number of tasks run in parallel")
 
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: shelly batch [flags]\n\nRun tasks in headless batch mode for cost-efficient processing.\n\nFlags:\n")
@@ -28,6 +33,8 @@ func runBatch(args []string) error {
 		fmt.Fprintf(os.Stderr, "  task     Required. The task prompt.\n")
 		fmt.Fprintf(os.Stderr, "  agent    Optional. Agent name (default: entry_agent from config).\n")
 		fmt.Fprintf(os.Stderr, "  context  Optional. Additional context prepended to the task.\n")
+		fmt.Fprintf(os.Stderr, "\nProgress is recorded in .shelly/local/batches/. After an interruption, rerun\n")
+		fmt.Fprintf(os.Stderr, "with --resume to skip completed tasks and re-attach to submitted provider batches.\n")
 	}
 
 	if err := fs.Parse(args); err != nil {
@@ -52,6 +59,7 @@ func runBatch(args []string) error {
 	}
 
 	cfg.ShellyDir = *shellyDir
+	cfg.NotifyTerminal = notifyTerminal()
 	cfg.StatusFunc = func(msg string) {
 		fmt.Fprintf(os.Stderr, "\r\033[K  %s", msg)
 	}
@@ -66,10 +74,47 @@ func runBatch(args []string) error {
 
 	fmt.Fprintf(os.Stderr, "Running batch: %s → %s\n", *tasksPath, *outputPath)
 
-	if err := engine.RunBatch(ctx, eng, *tasksPath, *outputPath); err != nil {
+	summary, err := engine.RunBatch(ctx, eng, *tasksPath, *outputPath, engine.BatchOptions{
+		Resume:      *resume,
+		MaxAttempts: *maxAttempts,
+		RetryDelay:  *retryDelay,
+		Concurrency: *concurrency,
+	})
+	if summary != nil {
+		printBatchSummary(summary)
+	}
+	if err != nil {
 		return err
 	}
 
+	if summary.Pending > 0 {
+		fmt.Fprintf(os.Stderr, "Batch interrupted. Rerun with --resume to continue.\n")
+		return nil
+	}
 	fmt.Fprintf(os.Stderr, "Batch complete. Results written to %s\n", *outputPath)
 	return nil
 }
+
+// printBatchSummary writes the end-of-run report to stderr.
+func printBatchSummary(s *engine.BatchSummary) {
+	fmt.Fprintf(os.Stderr, "\nRun %s (%s)\n", s.RunID, s.Elapsed.Round(time.Second))
+	fmt.Fprintf(os.Stderr, "  total      %d\n", s.Total)
+	if s.Skipped > 0 {
+		fmt.Fprintf(os.Stderr, "  skipped    %d (completed earlier)\n", s.Skipped)
+	}
+	fmt.Fprintf(os.Stderr, "  completed  %d\n", s.Completed)
+	fmt.Fprintf(os.Stderr, "  failed     %d\n", s.Failed)
+	if s.Retried > 0 {
+		fmt.Fprintf(os.Stderr, "  retried    %d\n", s.Retried)
+	}
+	if s.Pending > 0 {
+		fmt.Fprintf(os.Stderr, "  pending    %d\n", s.Pending)
+	}
+	if s.Reattached > 0 {
+		fmt.Fprintf(os.Stderr, "  reattached %d provider batches\n", s.Reattached)
+	}
+	for _, f := range s.Failures {
+		fmt.Fprintf(os.Stderr, "  ✗ %s: %s\n", f.ID, f.Error)
+	}
+	fmt.Fprintf(os.Stderr, "Manifest: %s\n", s.Manifest)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/germanamz/shelly/pkg/daemon"
+	"github.com/germanamz/shelly/pkg/engine"
+)
+
+func runDaemon(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to configuration file (default: .shelly/config.yaml or shelly.yaml)")
+	shellyDir := fs.String("shelly-dir", ".shelly", "path to .shelly directory")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: shelly daemon [flags]\n\nRun agents in the background from the triggers configured in the config file.\n\nFlags:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nTrigger kinds:\n")
+		fmt.Fprintf(os.Stderr, "  cron     Run on a cron schedule.\n")
+		fmt.Fprintf(os.Stderr, "  watch    Run when files matching a glob change.\n")
+		fmt.Fprintf(os.Stderr, "  webhook  Run on POST /hooks/<name>.\n")
+		fmt.Fprintf(os.Stderr, "  task     Run when a task enters pending with a given assignee.\n")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer cancel()
+
+	resolvedConfig := resolveConfigPath(*configPath, *shellyDir)
+
+	cfg, err := engine.LoadConfig(resolvedConfig)
+	if err != nil {
+		return err
+	}
+	if len(cfg.Triggers) == 0 {
+		return fmt.Errorf("daemon: no triggers configured in %s", resolvedConfig)
+	}
+
+	cfg.ShellyDir = *shellyDir
+	cfg.NotifyTerminal = notifyTerminal()
+	cfg.StatusFunc = func(msg string) {
+		fmt.Fprintf(os.Stderr, "\r\033[K  %s", msg)
+	}
+
+	eng, err := engine.New(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr)
+		return err
+	}
+	fmt.Fprintln(os.Stderr)
+	defer func() { _ = eng.Close() }()
+
+	d, err := daemon.New(eng, cfg.Daemon, cfg.Triggers, daemon.Options{})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Daemon running with %d trigger(s). Press Ctrl-C to stop.\n", len(cfg.Triggers))
+
+	if err := d.Run(ctx); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Daemon stopped.")
+	return nil
+}
@@ -2,9 +2,16 @@ package main
 
 import (
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 
+	tea "charm.land/bubbletea/v2"
+
+	"github.com/germanamz/shelly/cmd/shelly/internal/keymap"
+	"github.com/germanamz/shelly/cmd/shelly/internal/toolrender"
+	"github.com/germanamz/shelly/pkg/engine"
 	"github.com/joho/godotenv"
 )
 
@@ -33,3 +40,42 @@ func resolveConfigPath(explicit, shellyDirPath string) string {
 
 	return "shelly.yaml"
 }
+
+// notifyTerminal returns the writer for terminal notifications (bell, OSC 9,
+// OSC 777): stderr when it is a terminal, otherwise nil.
+func notifyTerminal() io.Writer {
+	if fi, err := os.Stderr.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		return os.Stderr
+	}
+	return nil
+}
+
+// programTerminal writes terminal notifications through a bubbletea program,
+// whose event loop writes them between frames instead of into one. Writes
+// before the program is set are dropped.
+type programTerminal struct {
+	p atomic.Pointer[tea.Program]
+}
+
+func (t *programTerminal) Write(b []byte) (int, error) {
+	if p := t.p.Load(); p != nil {
+		p.Send(tea.RawMsg{Msg: string(b)})
+	}
+	return len(b), nil
+}
+
+// configureToolRenderers installs the tool result renderers configured in
+// tool_renderers for the chat view.
+func configureToolRenderers(eng *engine.Engine) error {
+	cfgs := eng.ToolRenderers()
+	specs := make([]toolrender.Spec, len(cfgs))
+	for i, c := range cfgs {
+		specs[i] = toolrender.Spec{Tool: c.Tool, Server: c.MCPServer, View: c.View, Template: c.Template, MaxLines: c.MaxLines}
+	}
+	return toolrender.Configure(specs, eng.MCPTools())
+}
+
+// configureKeybindings applies the key overrides configured in keybindings.
+func configureKeybindings(eng *engine.Engine) error {
+	return keymap.Configure(eng.Keybindings().Bindings)
+}
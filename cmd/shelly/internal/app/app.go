@@ -12,19 +12,26 @@ import (
 	"github.com/germanamz/shelly/cmd/shelly/internal/bridge"
 	"github.com/germanamz/shelly/cmd/shelly/internal/chatview"
 	"github.com/germanamz/shelly/cmd/shelly/internal/configwizard"
+	"github.com/germanamz/shelly/cmd/shelly/internal/costpanel"
+	"github.com/germanamz/shelly/cmd/shelly/internal/diffview"
 	"github.com/germanamz/shelly/cmd/shelly/internal/format"
 	"github.com/germanamz/shelly/cmd/shelly/internal/input"
+	"github.com/germanamz/shelly/cmd/shelly/internal/keymap"
+	"github.com/germanamz/shelly/cmd/shelly/internal/memorypanel"
 	"github.com/germanamz/shelly/cmd/shelly/internal/menubar"
 	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
+	"github.com/germanamz/shelly/cmd/shelly/internal/queuepanel"
 	"github.com/germanamz/shelly/cmd/shelly/internal/styles"
 	"github.com/germanamz/shelly/cmd/shelly/internal/subagentpanel"
 	"github.com/germanamz/shelly/cmd/shelly/internal/taskpanel"
+	"github.com/germanamz/shelly/pkg/agent"
 	"github.com/germanamz/shelly/pkg/chats/content"
 	"github.com/germanamz/shelly/pkg/chats/message"
 	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/commands"
 	"github.com/germanamz/shelly/pkg/engine"
-	"github.com/germanamz/shelly/pkg/modeladapter"
 	"github.com/germanamz/shelly/pkg/modeladapter/usage"
+	"github.com/germanamz/shelly/pkg/shellydir"
 )
 
 // State represents the application state machine.
@@ -43,6 +50,9 @@ const (
 	PanelNone ActivePanel = iota
 	PanelSubAgents
 	PanelTasks
+	PanelCost
+	PanelQueue
+	PanelMemory
 )
 
 // askSet groups questions from a single agent for sequential presentation.
@@ -60,10 +70,17 @@ type AppModel struct {
 	chatView       chatview.ChatViewModel
 	inputBox       input.InputModel
 	taskPanel      taskpanel.TaskPanelModel
+	costPanel      costpanel.CostPanelModel
+	queuePanel     queuepanel.Model
+	queueEdit      string // ID of the queued prompt being edited in the input box
+	queueDraft     string // input text set aside while editing a queued prompt
+	memoryPanel    memorypanel.Model
 	askSets        []askSet
 	askActiveAgent string
 	askActive      *askprompt.AskBatchModel
 	askBatching    bool
+	reviews        []msgs.FileReviewMsg // file changes queued for review
+	reviewActive   *diffview.Model
 	state          State
 	cancelBridge   context.CancelFunc
 	cancelSend     context.CancelFunc // cancels the current Send when Escape is pressed
@@ -81,7 +98,10 @@ type AppModel struct {
 	shellyDir      string
 	configWizard   *configwizard.WizardModel
 	sessionPicker  input.SessionPickerModel
-	agentUsage     map[string]AgentUsageInfo // per-agent usage data
+	choicePicker   input.ChoicePickerModel
+	speakReplies   bool                        // read final replies aloud (speech.speak_replies, toggled by /speak)
+	commands       map[string]commands.Command // user-defined slash commands keyed by "/name"
+	agentUsage     map[string]AgentUsageInfo   // per-agent usage data
 	width          int
 	height         int
 
@@ -103,17 +123,33 @@ func NewAppModel(ctx context.Context, sess *engine.Session, eng *engine.Engine,
 	cv := chatview.New()
 	// Append logo to viewport as initial content.
 	cv, _ = cv.Update(msgs.ChatViewAppendMsg{Content: styles.DimStyle.Render(chatview.LogoArt)})
+
+	ib := input.New(historyPath, shellydir.New(shellyDir).DraftsPath())
+	ib.SetVim(eng.Keybindings().Vim)
+	ib.SetSession(sess.PersistID())
+	custom, defs, err := loadCommands(shellyDir)
+	if err != nil {
+		cv, _ = cv.Update(msgs.ChatViewAppendMsg{Content: "\n" + styles.DimStyle.Render("⚠ "+err.Error()) + "\n"})
+	}
+	ib.CmdPicker.SetCommands(defs)
+
 	return AppModel{
 		ctx:           ctx,
 		sess:          sess,
 		eng:           eng,
 		chatView:      cv,
-		inputBox:      input.New(historyPath),
+		inputBox:      ib,
+		commands:      custom,
 		taskPanel:     taskpanel.New(),
+		costPanel:     costpanel.New(),
+		queuePanel:    queuepanel.New(),
+		memoryPanel:   memorypanel.New(),
 		menuBar:       menubar.New(),
 		subAgentPanel: subagentpanel.New(),
 		sessionPicker: input.NewSessionPicker(),
+		choicePicker:  input.NewChoicePicker(),
 		state:         StateIdle,
+		speakReplies:  eng.Speech().SpeakReplies,
 		configPath:    configPath,
 		shellyDir:     shellyDir,
 	}
@@ -142,6 +178,10 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	// --- Global keys ---
 	case tea.KeyPressMsg:
+		if m.eng != nil {
+			// The user is at the terminal: drop idle notifications.
+			m.eng.Notifier().Activity()
+		}
 		return m.handleKey(msg)
 
 	// --- Window management ---
@@ -168,6 +208,16 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case msgs.InputSubmitMsg:
 		return m.handleSubmit(msg)
 
+	case msgs.InputEditorDoneMsg:
+		if msg.Err != nil {
+			errLine := styles.ErrorBlockStyle.Width(m.width).Render("Error: " + msg.Err.Error())
+			m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + errLine + "\n"})
+			return m, nil
+		}
+		m.inputBox, _ = m.inputBox.Update(msg)
+		m.recalcViewportHeight()
+		return m, nil
+
 	// --- Chat view (agent activity) ---
 	case msgs.ChatMessageMsg:
 		var cmd tea.Cmd
@@ -194,9 +244,21 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case msgs.SendCompleteMsg:
 		return m.handleSendComplete(msg)
 
+	case msgs.SpeakDoneMsg:
+		if msg.Err != nil && m.ctx.Err() == nil {
+			errLine := styles.ErrorBlockStyle.Width(m.width).Render(
+				lipgloss.NewStyle().Foreground(styles.ColorError).Render(msg.Err.Error()),
+			)
+			m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + errLine + "\n"})
+		}
+		return m, nil
+
 	case msgs.CompactCompleteMsg:
 		return m.handleCompactComplete(msg)
 
+	case msgs.CommandExpandedMsg:
+		return m.handleCommandExpanded(msg)
+
 	// --- Ask-user coordination ---
 	case msgs.AskUserMsg:
 		return m.handleAskUser(msg)
@@ -207,6 +269,13 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case msgs.AskBatchAnsweredMsg:
 		return m.handleBatchAnswered(msg)
 
+	// --- File change review ---
+	case msgs.FileReviewMsg:
+		return m.handleFileReview(msg)
+
+	case msgs.FileReviewDecidedMsg:
+		return m.handleReviewDecided(msg)
+
 	case msgs.RespondErrorMsg:
 		errLine := styles.ErrorBlockStyle.Width(m.width).Render(
 			lipgloss.NewStyle().Foreground(styles.ColorError).Render("error responding: " + msg.Err.Error()),
@@ -214,6 +283,24 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + errLine + "\n"})
 		return m, nil
 
+	case msgs.BudgetWarningMsg:
+		note := lipgloss.NewStyle().Foreground(styles.ColorWarning).Render(
+			fmt.Sprintf("Budget warning: %s (%.0f%%)", msg.Scope, msg.Scope.Ratio()*100),
+		)
+		m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + note + "\n"})
+		return m, nil
+
+	case msgs.QueueChangedMsg:
+		if msg.SessionID != m.sess.ID() {
+			return m, nil
+		}
+		// Interrupt prompts become part of the running turn.
+		for _, p := range msg.Injected {
+			m.chatView, _ = m.chatView.Update(msgs.ChatViewCommitUserMsg{Text: p.Text})
+		}
+		m.onQueueChanged()
+		return m, nil
+
 	case msgs.SubAgentSendErrorMsg:
 		errLine := styles.ErrorBlockStyle.Width(m.width).Render(
 			lipgloss.NewStyle().Foreground(styles.ColorError).Render(msg.Err.Error()),
@@ -241,6 +328,16 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmd := m.executeResumeSession(msg.ID)
 		return m, cmd
 
+	// --- Choice picker ---
+	case msgs.ChoicePickerActivateMsg:
+		m.choicePicker.Width = m.width
+		m.choicePicker, _ = m.choicePicker.Update(msg)
+		return m, nil
+
+	case msgs.ChoicePickerSelectionMsg:
+		m.executeSwitch(msg.Kind, msg.ID)
+		return m, nil
+
 	// --- Animation tick ---
 	case msgs.TickMsg:
 		if m.state == StateProcessing || m.chatView.HasActiveChains() || m.taskPanel.HasActiveTasks() {
@@ -250,6 +347,7 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.subAgentPanel.AdvanceSpinner()
 			}
 			m.updateTokenCounter()
+			m.refreshCostPanel()
 			return m, tickCmd()
 		}
 		return m, nil
@@ -261,7 +359,11 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	// --- Delegate to focused component ---
-	if m.askActive != nil {
+	if m.reviewActive != nil {
+		updated, cmd := m.reviewActive.Update(msg)
+		m.reviewActive = &updated
+		cmds = append(cmds, cmd)
+	} else if m.askActive != nil {
 		updated, cmd := m.askActive.Update(msg)
 		m.askActive = &updated
 		cmds = append(cmds, cmd)
@@ -307,6 +409,10 @@ func (m AppModel) View() tea.View {
 	switch {
 	case m.sessionPicker.Active:
 		parts = append(parts, m.sessionPicker.View())
+	case m.choicePicker.Active:
+		parts = append(parts, m.choicePicker.View())
+	case m.reviewActive != nil:
+		parts = append(parts, m.reviewActive.View())
 	case m.askActive != nil:
 		parts = append(parts, m.askActive.View())
 	default:
@@ -361,6 +467,15 @@ func (m *AppModel) handleResize(msg tea.WindowSizeMsg) (tea.Model, tea.Cmd) {
 		m.resizeSubAgentPanel()
 	case PanelTasks:
 		m.resizeTaskPanel()
+	case PanelCost:
+		m.resizeCostPanel()
+	case PanelQueue:
+		m.resizeQueuePanel()
+	case PanelMemory:
+		m.resizeMemoryPanel()
+	}
+	if m.reviewActive != nil {
+		m.reviewActive.SetSize(m.width, m.reviewHeight())
 	}
 	m.recalcViewportHeight()
 	return m, nil
@@ -371,10 +486,7 @@ func (m *AppModel) handleKey(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
 
 	// Ctrl+C always quits.
 	if k.Code == 'c' && k.Mod&tea.ModCtrl != 0 {
-		if m.cancelBridge != nil {
-			m.cancelBridge()
-		}
-		return m, func() tea.Msg { return tea.QuitMsg{} }
+		return m, m.executeQuit()
 	}
 
 	// Dismiss the transient menu bar hint on any keypress.
@@ -394,6 +506,21 @@ func (m *AppModel) handleKey(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
 		return m, cmd
 	}
 
+	// Forward to choice picker if active.
+	if m.choicePicker.Active {
+		var cmd tea.Cmd
+		m.choicePicker, cmd = m.choicePicker.Update(msg)
+		return m, cmd
+	}
+
+	// Forward to file review if active.
+	if m.reviewActive != nil {
+		updated, cmd := m.reviewActive.Update(msg)
+		m.reviewActive = &updated
+		m.recalcViewportHeight()
+		return m, cmd
+	}
+
 	// Forward to ask prompt if active.
 	if m.askActive != nil {
 		updated, cmd := m.askActive.Update(msg)
@@ -411,20 +538,37 @@ func (m *AppModel) handleKey(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
 		return m.handleMenuBarKey(msg)
 	}
 
+	// Tool result selection — Up/Down/Enter/Esc; other keys leave it.
+	if m.chatView.Selecting() && m.chatView.HandleSelectKey(msg) {
+		return m, nil
+	}
+
+	// Ctrl+O selects tool results to expand or collapse them.
+	if keymap.Matches(msg, keymap.SelectResults) {
+		m.chatView.ToggleSelect()
+		return m, nil
+	}
+
 	// Ctrl+B toggles menu bar focus (only when menu is visible).
-	if k.Code == 'b' && k.Mod&tea.ModCtrl != 0 && m.menuBar.Visible() {
+	if keymap.Matches(msg, keymap.Menu) && m.menuBar.Visible() {
 		m.menuFocused = true
 		m.menuBar.SetActive(true)
 		return m, nil
 	}
 
-	// Escape priority: picker → panel → menu → agent view back → agent interrupt → no-op.
+	// Escape priority: picker / vim / history search → agent view back → agent interrupt → no-op.
 	if k.Code == tea.KeyEsc {
-		if m.inputBox.PickerActive() {
+		if m.inputBox.PickerActive() || m.inputBox.WantsEsc() {
 			var cmd tea.Cmd
 			m.inputBox, cmd = m.inputBox.Update(msg)
 			return m, cmd
 		}
+		// Cancel editing a queued prompt.
+		if m.queueEdit != "" {
+			m.endQueueEdit()
+			m.recalcViewportHeight()
+			return m, nil
+		}
 		// Navigate back in agent view stack (only when input is empty).
 		if m.chatView.ViewedAgent() != "" && m.inputBox.IsEmpty() {
 			m.chatView, _ = m.chatView.Update(msgs.ChatViewNavigateBackMsg{})
@@ -441,6 +585,7 @@ func (m *AppModel) handleKey(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
 
 	var cmd tea.Cmd
 	m.inputBox, cmd = m.inputBox.Update(msg)
+	m.recalcViewportHeight()
 	return m, cmd
 }
 
@@ -460,7 +605,7 @@ func (m *AppModel) handleMenuBarKey(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
 	case k.Code == tea.KeyEsc:
 		m.menuFocused = false
 		m.menuBar.SetActive(false)
-	case k.Code == 'b' && k.Mod&tea.ModCtrl != 0:
+	case keymap.Matches(msg, keymap.Menu):
 		// Ctrl+B toggles back to input.
 		m.menuFocused = false
 		m.menuBar.SetActive(false)
@@ -503,6 +648,19 @@ func (m *AppModel) handlePanelKey(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
 		case tea.KeyEsc:
 			m.closePanel()
 		}
+	case PanelCost:
+		switch k.Code {
+		case tea.KeyUp:
+			m.costPanel.MoveUp()
+		case tea.KeyDown:
+			m.costPanel.MoveDown()
+		case tea.KeyEsc:
+			m.closePanel()
+		}
+	case PanelQueue:
+		return m.handleQueuePanelKey(msg)
+	case PanelMemory:
+		return m.handleMemoryPanelKey(msg)
 	}
 	return m, nil
 }
@@ -531,6 +689,8 @@ func (m *AppModel) handleMenuItemSelected(id string) (tea.Model, tea.Cmd) {
 		m.taskPanel.SetActive(true)
 		m.resizeTaskPanel()
 		m.recalcViewportHeight()
+	case queuepanel.PanelID:
+		m.executeQueue()
 	}
 	// Menu bar loses focus when a panel opens.
 	m.menuFocused = false
@@ -545,6 +705,12 @@ func (m *AppModel) closePanel() {
 		m.subAgentPanel.SetActive(false)
 	case PanelTasks:
 		m.taskPanel.SetActive(false)
+	case PanelCost:
+		m.costPanel.SetActive(false)
+	case PanelQueue:
+		m.queuePanel.SetActive(false)
+	case PanelMemory:
+		m.memoryPanel.SetActive(false)
 	}
 	m.activePanel = PanelNone
 	m.recalcViewportHeight()
@@ -553,6 +719,10 @@ func (m *AppModel) closePanel() {
 func (m *AppModel) handleSubmit(msg msgs.InputSubmitMsg) (tea.Model, tea.Cmd) {
 	text := msg.Text
 
+	if m.queueEdit != "" {
+		return m.saveQueueEdit(msg)
+	}
+
 	if result := m.dispatchCommand(text); result.handled {
 		return m, result.cmd
 	}
@@ -562,6 +732,11 @@ func (m *AppModel) handleSubmit(msg msgs.InputSubmitMsg) (tea.Model, tea.Cmd) {
 		return m.handleSubAgentSubmit(agentID, msg)
 	}
 
+	// Queue prompts submitted while the agent is running.
+	if m.state == StateProcessing {
+		return m.enqueue(msg)
+	}
+
 	// Commit user message to viewport.
 	m.chatView, _ = m.chatView.Update(msgs.ChatViewCommitUserMsg{Text: text, Parts: msg.Parts})
 	m.chatView, _ = m.chatView.Update(msgs.ChatViewMarkSentMsg{})
@@ -569,38 +744,41 @@ func (m *AppModel) handleSubmit(msg msgs.InputSubmitMsg) (tea.Model, tea.Cmd) {
 	// Build content parts: text first, then any attachments.
 	parts := buildSendParts(text, msg.Parts)
 
-	if m.state == StateProcessing {
-		if m.cancelSend != nil {
-			m.cancelSend()
-		}
-		m.sendGeneration++
-		gen := m.sendGeneration
-		sendCtx, cancelSend := context.WithCancel(m.ctx)
-		m.cancelSend = cancelSend
-		sess := m.sess
-		sendStart := time.Now()
-		return m, func() tea.Msg {
-			_, err := sess.SendParts(sendCtx, parts...)
-			return msgs.SendCompleteMsg{Err: err, Duration: time.Since(sendStart), Generation: gen}
-		}
-	}
+	return m, m.startSend(parts, nil)
+}
 
-	m.state = StateProcessing
-	m.chatView, _ = m.chatView.Update(msgs.ChatViewSetProcessingMsg{Processing: true})
+// startSend sends parts to the session, interrupting a send in progress.
+// A non-empty allowedTools restricts the tools the agent may call.
+func (m *AppModel) startSend(parts []content.Part, allowedTools []string) tea.Cmd {
+	wasProcessing := m.state == StateProcessing
+	if wasProcessing && m.cancelSend != nil {
+		m.cancelSend()
+	}
+	if !wasProcessing {
+		m.state = StateProcessing
+		m.chatView, _ = m.chatView.Update(msgs.ChatViewSetProcessingMsg{Processing: true})
+	}
 	sendStart := time.Now()
 
 	m.sendGeneration++
 	gen := m.sendGeneration
 	sendCtx, cancelSend := context.WithCancel(m.ctx)
 	m.cancelSend = cancelSend
+	if len(allowedTools) > 0 {
+		sendCtx = agent.WithAllowedTools(sendCtx, allowedTools)
+	}
 
 	sess := m.sess
 	sendCmd := func() tea.Msg {
-		_, err := sess.SendParts(sendCtx, parts...)
-		return msgs.SendCompleteMsg{Err: err, Duration: time.Since(sendStart), Generation: gen}
+		reply, err := sess.SendParts(sendCtx, parts...)
+		return msgs.SendCompleteMsg{Err: err, Duration: time.Since(sendStart), Generation: gen, Reply: reply.TextContent()}
 	}
 
-	return m, tea.Batch(sendCmd, tickCmd())
+	// A send in progress already has its spinner tick running.
+	if wasProcessing {
+		return sendCmd
+	}
+	return tea.Batch(sendCmd, tickCmd())
 }
 
 // handleSubAgentSubmit routes a user message to the currently viewed sub-agent.
@@ -615,16 +793,16 @@ func (m *AppModel) handleSubAgentSubmit(agentID string, msg msgs.InputSubmitMsg)
 	}
 
 	// Commit user message to viewport.
-	m.chatView, _ = m.chatView.Update(msgs.ChatViewCommitUserMsg(msg))
+	m.chatView, _ = m.chatView.Update(msgs.ChatViewCommitUserMsg{Text: msg.Text, Parts: msg.Parts})
 	m.chatView, _ = m.chatView.Update(msgs.ChatViewMarkSentMsg{})
 
 	// Build the message and send to the agent's inbox.
 	parts := buildSendParts(msg.Text, msg.Parts)
 	userMsg := message.New("user", role.User, parts...)
 
-	eng := m.eng
+	eng, sessionID := m.eng, m.sess.ID()
 	sendCmd := func() tea.Msg {
-		err := eng.SendToAgent(agentID, userMsg)
+		err := eng.SendToAgent(sessionID, agentID, userMsg)
 		if err != nil {
 			return msgs.SubAgentSendErrorMsg{AgentID: agentID, Err: err}
 		}
@@ -651,6 +829,7 @@ func (m *AppModel) handleSendComplete(msg msgs.SendCompleteMsg) (tea.Model, tea.
 	m.cancelSend = nil
 	m.chatView, _ = m.chatView.Update(msgs.ChatViewSetProcessingMsg{Processing: false})
 	m.updateTokenCounter()
+	m.refreshCostPanel()
 
 	m.chatView, _ = m.chatView.Update(msgs.ChatViewFlushAllMsg{})
 
@@ -659,9 +838,18 @@ func (m *AppModel) handleSendComplete(msg msgs.SendCompleteMsg) (tea.Model, tea.
 			lipgloss.NewStyle().Foreground(styles.ColorError).Render("error: " + msg.Err.Error()),
 		)
 		m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + errLine + "\n"})
+		m.noteQueue("kept")
+		return m, nil
 	}
 
-	return m, nil
+	var cmds []tea.Cmd
+	if m.speakReplies && msg.Reply != "" {
+		cmds = append(cmds, m.speakCmd(msg.Reply))
+	}
+	if msg.Err == nil {
+		cmds = append(cmds, m.sendNextQueued())
+	}
+	return m, tea.Batch(cmds...)
 }
 
 func (m *AppModel) handleCompactComplete(msg msgs.CompactCompleteMsg) (tea.Model, tea.Cmd) {
@@ -674,6 +862,7 @@ func (m *AppModel) handleCompactComplete(msg msgs.CompactCompleteMsg) (tea.Model
 			lipgloss.NewStyle().Foreground(styles.ColorError).Render("compact error: " + msg.Err.Error()),
 		)
 		m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + errLine + "\n"})
+		m.noteQueue("kept")
 		return m, nil
 	}
 
@@ -688,15 +877,11 @@ func (m *AppModel) handleCompactComplete(msg msgs.CompactCompleteMsg) (tea.Model
 		m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + rendered + "\n"})
 	}
 
-	return m, nil
+	return m, m.sendNextQueued()
 }
 
 func (m *AppModel) updateTokenCounter() {
-	ur, ok := m.sess.Completer().(modeladapter.UsageReporter)
-	if !ok {
-		return
-	}
-	total := ur.UsageTracker().Total()
+	total, cost := m.sess.UsageTotal()
 	totalTok := total.InputTokens + total.OutputTokens
 	if totalTok > 0 {
 		m.tokenCount = format.FmtTokens(totalTok)
@@ -704,12 +889,8 @@ func (m *AppModel) updateTokenCounter() {
 	if ratio := total.CacheSavings(); ratio > 0 {
 		m.cacheInfo = fmt.Sprintf("cache %.0f%%", ratio*100)
 	}
-	info := m.sess.ProviderInfo()
-	if pricing, ok := usage.LookupPricing(info.Kind, info.Model); ok {
-		cost := usage.CalculateCost(total, pricing)
-		if cost > 0 {
-			m.sessionCost = format.FmtCost(cost)
-		}
+	if cost > 0 {
+		m.sessionCost = format.FmtCost(cost)
 	}
 }
 
@@ -751,6 +932,9 @@ func (m AppModel) sessionStatusSegments() []string {
 	if m.cacheInfo != "" {
 		segments = append(segments, m.cacheInfo)
 	}
+	if n := m.queuePanel.Len(); n > 0 {
+		segments = append(segments, fmt.Sprintf("%d queued", n))
+	}
 	return segments
 }
 
@@ -792,12 +976,20 @@ func (m AppModel) agentStatusSegments(agentID string) []string {
 // keyboardHint returns context-sensitive keyboard hints for the status bar.
 func (m AppModel) keyboardHint() string {
 	switch {
-	case m.activePanel == PanelTasks:
+	case m.activePanel == PanelTasks, m.activePanel == PanelCost:
 		return styles.DimStyle.Render("↑↓ scroll  esc close")
+	case m.activePanel == PanelQueue:
+		return styles.DimStyle.Render("↑↓ navigate  shift+↑↓ move  tab mode  ⏎ edit  d delete  esc close")
+	case m.activePanel == PanelMemory:
+		return styles.DimStyle.Render("↑↓ navigate  ⏎ show  d forget  esc close")
 	case m.activePanel != PanelNone:
 		return styles.DimStyle.Render("↑↓ navigate  ⏎ select  esc close")
 	case m.menuFocused:
 		return styles.DimStyle.Render("←→ navigate  ⏎ select  esc back")
+	case m.queueEdit != "":
+		return styles.DimStyle.Render("⏎ save queued prompt  esc cancel")
+	case m.chatView.Selecting():
+		return styles.DimStyle.Render("↑↓ select result  ⏎ expand/collapse  esc done")
 	case m.chatView.ViewedAgent() != "":
 		return styles.DimStyle.Render("esc back to parent")
 	case m.menuHintActive:
@@ -812,8 +1004,12 @@ func (m *AppModel) recalcViewportHeight() {
 	// Status bar: 1 line for token counter (always reserve).
 	statusLines := 1
 	// Menu bar, sub-agent panel, task panel, and breadcrumb heights.
-	extraLines := m.menuBar.Height() + m.subAgentPanel.Height() + m.taskPanel.Height() + m.chatView.HeaderHeight()
-	vpHeight := max(m.height-m.inputBox.ViewHeight()-statusLines-extraLines, 3)
+	extraLines := m.menuBar.Height() + m.subAgentPanel.Height() + m.taskPanel.Height() + m.costPanel.Height() + m.queuePanel.Height() + m.memoryPanel.Height() + m.chatView.HeaderHeight()
+	inputHeight := m.inputBox.ViewHeight()
+	if m.reviewActive != nil {
+		inputHeight = m.reviewActive.Height()
+	}
+	vpHeight := max(m.height-inputHeight-statusLines-extraLines, 3)
 	m.chatView, _ = m.chatView.Update(msgs.ChatViewSetHeightMsg{Height: vpHeight})
 }
 
@@ -824,6 +1020,12 @@ func (m AppModel) activePanelView() string {
 		return m.subAgentPanel.View()
 	case PanelTasks:
 		return m.taskPanel.View()
+	case PanelCost:
+		return m.costPanel.View()
+	case PanelQueue:
+		return m.queuePanel.View()
+	case PanelMemory:
+		return m.memoryPanel.View()
 	default:
 		return ""
 	}
@@ -983,6 +1185,34 @@ func (m *AppModel) resizeTaskPanel() {
 	m.taskPanel.SetSize(m.width, h)
 }
 
+// refreshCostPanel reloads the session usage and provider rate limits into
+// the cost panel when it is open, resizing it to fit.
+func (m *AppModel) refreshCostPanel() {
+	if m.activePanel != PanelCost {
+		return
+	}
+	m.costPanel.SetUsage(m.sess.Usage(), m.eng.RateLimits())
+	if h := m.costPanel.Height(); h != m.costPanelHeight() {
+		m.resizeCostPanel()
+		m.recalcViewportHeight()
+	}
+}
+
+// costPanelHeight returns the height that fits the cost panel content:
+// min(lines + 2 borders, 16), or 3 for the empty state.
+func (m AppModel) costPanelHeight() int {
+	lines := m.costPanel.LineCount()
+	if lines == 0 {
+		return 3
+	}
+	return min(lines+2, 16)
+}
+
+// resizeCostPanel sets the cost panel size based on its content.
+func (m *AppModel) resizeCostPanel() {
+	m.costPanel.SetSize(m.width, m.costPanelHeight())
+}
+
 // onTasksChanged handles menu bar badge updates and panel refresh when tasks change.
 func (m *AppModel) onTasksChanged() {
 	badge := m.taskPanel.ActiveTaskCount()
@@ -1,21 +1,34 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
 
 	tea "charm.land/bubbletea/v2"
 	lipgloss "charm.land/lipgloss/v2"
 	"github.com/germanamz/shelly/cmd/shelly/internal/bridge"
 	"github.com/germanamz/shelly/cmd/shelly/internal/chatview"
 	"github.com/germanamz/shelly/cmd/shelly/internal/configwizard"
+	"github.com/germanamz/shelly/cmd/shelly/internal/costpanel"
 	"github.com/germanamz/shelly/cmd/shelly/internal/format"
+	"github.com/germanamz/shelly/cmd/shelly/internal/input"
+	"github.com/germanamz/shelly/cmd/shelly/internal/memorypanel"
 	"github.com/germanamz/shelly/cmd/shelly/internal/menubar"
 	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
+	"github.com/germanamz/shelly/cmd/shelly/internal/queuepanel"
+	"github.com/germanamz/shelly/cmd/shelly/internal/speech"
 	"github.com/germanamz/shelly/cmd/shelly/internal/styles"
 	"github.com/germanamz/shelly/cmd/shelly/internal/subagentpanel"
 	"github.com/germanamz/shelly/pkg/chats/content"
 	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/commands"
 	"github.com/germanamz/shelly/pkg/engine"
+	"github.com/germanamz/shelly/pkg/shellydir"
 )
 
 // commandResult holds the result of dispatching a slash command.
@@ -26,6 +39,15 @@ type commandResult struct {
 
 // dispatchCommand checks if text is a recognized slash command and handles it.
 func (m *AppModel) dispatchCommand(text string) commandResult {
+	if args, ok := strings.CutPrefix(text, "/run"); ok && (args == "" || args[0] == ' ') {
+		return commandResult{cmd: m.executeRun(strings.TrimSpace(args)), handled: true}
+	}
+	for _, kind := range []string{switchModel, switchAgent} {
+		if args, ok := strings.CutPrefix(text, "/"+kind); ok && (args == "" || args[0] == ' ') {
+			return commandResult{cmd: m.executeSwitchCommand(kind, strings.TrimSpace(args)), handled: true}
+		}
+	}
+
 	switch text {
 	case "/quit", "/exit":
 		return commandResult{cmd: m.executeQuit(), handled: true}
@@ -47,11 +69,32 @@ func (m *AppModel) dispatchCommand(text string) commandResult {
 	case "/tasks":
 		m.executeTasks()
 		return commandResult{handled: true}
+	case "/cost":
+		m.executeCost()
+		return commandResult{handled: true}
+	case "/queue":
+		m.executeQueue()
+		return commandResult{handled: true}
+	case "/memory":
+		m.executeMemory()
+		return commandResult{handled: true}
+	case "/speak":
+		m.executeSpeak()
+		return commandResult{handled: true}
+	case "/vim":
+		m.executeVim()
+		return commandResult{handled: true}
+	}
+
+	name, args, _ := strings.Cut(text, " ")
+	if c, ok := m.commands[name]; ok {
+		return commandResult{cmd: m.executeCustom(c, strings.TrimSpace(args)), handled: true}
 	}
 	return commandResult{}
 }
 
 func (m *AppModel) executeQuit() tea.Cmd {
+	m.inputBox.SaveDraft()
 	if m.cancelBridge != nil {
 		m.cancelBridge()
 	}
@@ -60,10 +103,21 @@ func (m *AppModel) executeQuit() tea.Cmd {
 
 func (m *AppModel) executeHelp() {
 	helpOutput := "\n" + styles.DimStyle.Render("⌘ /help") + "\n\n" + helpText() + "\n"
+	if custom := m.customHelp(); custom != "" {
+		helpOutput += "\n" + custom + "\n"
+	}
 	m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: helpOutput})
 }
 
 func (m *AppModel) executeClear() tea.Cmd {
+	m.resetSession("", "⌘ /clear")
+	return nil
+}
+
+// resetSession replaces the session with a new one for agentName ("" = the
+// entry agent) and clears the chat view, leaving label as its first line. It
+// reports whether the new session was created.
+func (m *AppModel) resetSession(agentName, label string) bool {
 	if m.cancelSend != nil {
 		m.cancelSend()
 		m.cancelSend = nil
@@ -74,31 +128,36 @@ func (m *AppModel) executeClear() tea.Cmd {
 		m.cancelBridge = nil
 	}
 	m.eng.RemoveSession(m.sess.ID())
-	newSess, err := m.eng.NewSession("")
+	newSess, err := m.eng.NewSession(agentName)
 	if err != nil {
 		errLine := styles.ErrorBlockStyle.Width(m.width).Render("Error: " + err.Error())
 		m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + errLine + "\n"})
-		return nil
+		return false
 	}
 	m.sess = newSess
 	m.chatView, _ = m.chatView.Update(msgs.ChatViewClearMsg{})
 	// Re-add the logo after clearing.
 	m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: styles.DimStyle.Render(chatview.LogoArt)})
-	m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + styles.DimStyle.Render("⌘ /clear") + "\n"})
-	m.inputBox, _ = m.inputBox.Update(msgs.InputResetMsg{})
+	m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + styles.DimStyle.Render(label) + "\n"})
+	m.inputBox.SetSession(newSess.PersistID())
 	m.tokenCount = ""
 	m.cacheInfo = ""
 	m.sessionCost = ""
 	// Reset menu bar and panel state.
 	m.menuBar = menubar.New()
 	m.subAgentPanel = subagentpanel.New()
+	m.costPanel = costpanel.New()
+	m.queuePanel = queuepanel.New()
+	m.memoryPanel = memorypanel.New()
+	m.queueEdit = ""
+	m.queueDraft = ""
 	m.activePanel = PanelNone
 	m.menuFocused = false
 	m.menuHintShown = false
 	m.menuHintActive = false
 	m.cancelBridge = bridge.Start(m.ctx, m.program, m.sess.Chat(), m.eng.Events(), m.eng.Tasks(), m.sess.AgentName())
 	m.state = StateIdle
-	return nil
+	return true
 }
 
 func (m *AppModel) executeCompact() tea.Cmd {
@@ -169,14 +228,14 @@ func (m *AppModel) executeResumeSession(id string) tea.Cmd {
 		switch msg.Role {
 		case role.User:
 			text := msg.TextContent()
-			if text != "" {
-				var attachments []content.Part
-				for _, p := range msg.Parts {
-					switch p.(type) {
-					case content.Image, content.Document:
-						attachments = append(attachments, p)
-					}
+			var attachments []content.Part
+			for _, p := range msg.Parts {
+				switch p.(type) {
+				case content.Image, content.Document, content.Audio:
+					attachments = append(attachments, p)
 				}
+			}
+			if text != "" || len(attachments) > 0 {
 				m.chatView, _ = m.chatView.Update(msgs.ChatViewCommitUserMsg{Text: text, Parts: attachments})
 			}
 		case role.Assistant:
@@ -195,12 +254,17 @@ func (m *AppModel) executeResumeSession(id string) tea.Cmd {
 		Content: "\n" + styles.DimStyle.Render("⌘ Resumed session") + "\n",
 	})
 
-	m.inputBox, _ = m.inputBox.Update(msgs.InputResetMsg{})
+	m.inputBox.SetSession(newSess.PersistID())
+	m.queueEdit = ""
+	m.queueDraft = ""
+	m.onQueueChanged()
+	m.noteQueue("restored")
 	m.tokenCount = ""
 	m.cacheInfo = ""
 	m.sessionCost = ""
 	m.cancelBridge = bridge.Start(m.ctx, m.program, m.sess.Chat(), m.eng.Events(), m.eng.Tasks(), m.sess.AgentName())
 	m.state = StateIdle
+	m.refreshCostPanel()
 	return nil
 }
 
@@ -237,6 +301,242 @@ func (m *AppModel) executeSubagents() {
 	m.recalcViewportHeight()
 }
 
+// executeRun runs a configured workflow on the current session. Without a
+// workflow name it lists the available workflows. The workflow report reaches
+// the chat view through the session chat like any agent reply.
+func (m *AppModel) executeRun(args string) tea.Cmd {
+	if args == "" {
+		m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + workflowList(m.eng.Workflows()) + "\n"})
+		return nil
+	}
+	if m.state == StateProcessing {
+		errLine := styles.ErrorBlockStyle.Width(m.width).Render("Cannot run a workflow while the agent is running.")
+		m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + errLine + "\n"})
+		return nil
+	}
+
+	name, input, _ := strings.Cut(args, " ")
+	input = strings.TrimSpace(input)
+
+	m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + styles.DimStyle.Render("⌘ /run "+args) + "\n"})
+	m.state = StateProcessing
+	m.chatView, _ = m.chatView.Update(msgs.ChatViewSetProcessingMsg{Processing: true})
+
+	m.sendGeneration++
+	gen := m.sendGeneration
+	runCtx, cancelRun := context.WithCancel(m.ctx)
+	m.cancelSend = cancelRun
+
+	sess := m.sess
+	start := time.Now()
+	return tea.Batch(func() tea.Msg {
+		_, err := sess.RunWorkflow(runCtx, name, input)
+		return msgs.SendCompleteMsg{Err: err, Duration: time.Since(start), Generation: gen}
+	}, tickCmd())
+}
+
+// Kinds of mid-session switch, named after their commands.
+const (
+	switchModel = "model"
+	switchAgent = "agent"
+)
+
+// executeSwitchCommand handles /model and /agent. With a name it switches
+// directly; without one it opens a picker of the configured providers or
+// agents.
+func (m *AppModel) executeSwitchCommand(kind, name string) tea.Cmd {
+	if m.state == StateProcessing {
+		errLine := styles.ErrorBlockStyle.Width(m.width).Render("Cannot switch the " + kind + " while the agent is running.")
+		m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + errLine + "\n"})
+		return nil
+	}
+	if name != "" {
+		m.executeSwitch(kind, name)
+		return nil
+	}
+
+	activate := msgs.ChoicePickerActivateMsg{Kind: kind}
+	if kind == switchModel {
+		activate.Title = "Switch model"
+		for _, pc := range m.eng.Providers() {
+			detail := pc.Kind
+			if pc.Model != "" {
+				detail += "/" + pc.Model
+			}
+			activate.Choices = append(activate.Choices, msgs.Choice{
+				ID: pc.Name, Label: pc.Name, Detail: detail, Current: pc.Name == m.sess.ProviderName(),
+			})
+		}
+	} else {
+		activate.Title = "Switch agent"
+		for _, ac := range m.eng.Agents() {
+			activate.Choices = append(activate.Choices, msgs.Choice{
+				ID: ac.Name, Label: ac.Name, Detail: ac.Description, Current: ac.Name == m.sess.AgentName(),
+			})
+		}
+	}
+	return func() tea.Msg { return activate }
+}
+
+// executeSwitch switches the session's provider or agent to id, keeping the
+// conversation. An agent switch restarts the bridge so events are attributed
+// to the new agent.
+func (m *AppModel) executeSwitch(kind, id string) {
+	if m.state == StateProcessing {
+		return
+	}
+
+	var err error
+	if kind == switchModel {
+		err = m.sess.SetCompleter(id)
+	} else {
+		err = m.sess.SetAgent(id)
+	}
+	if err != nil {
+		errLine := styles.ErrorBlockStyle.Width(m.width).Render("Error: " + err.Error())
+		m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + errLine + "\n"})
+		return
+	}
+
+	if kind == switchAgent {
+		if m.cancelBridge != nil {
+			m.cancelBridge()
+		}
+		m.cancelBridge = bridge.Start(m.ctx, m.program, m.sess.Chat(), m.eng.Events(), m.eng.Tasks(), m.sess.AgentName())
+	}
+
+	note := fmt.Sprintf("⌘ /%s %s — now using %s with %s", kind, id, m.sess.AgentName(), m.sess.ProviderInfo().Label())
+	m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + styles.DimStyle.Render(note) + "\n"})
+	m.tokenCount = ""
+	m.cacheInfo = ""
+	m.sessionCost = ""
+	m.updateTokenCounter()
+	m.refreshCostPanel()
+}
+
+// executeCustom runs a user-defined command: it expands the template in the
+// background and sends the result like a typed message. A command bound to
+// another agent first starts a fresh session with that agent.
+func (m *AppModel) executeCustom(c commands.Command, args string) tea.Cmd {
+	if m.state == StateProcessing {
+		errLine := styles.ErrorBlockStyle.Width(m.width).Render("Cannot run a command while the agent is running.")
+		m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + errLine + "\n"})
+		return nil
+	}
+
+	label := "/" + c.Name
+	if args != "" {
+		label += " " + args
+	}
+
+	if c.Agent != "" && c.Agent != m.sess.AgentName() {
+		if !m.resetSession(c.Agent, "⌘ "+label+" — new session with "+c.Agent) {
+			return nil
+		}
+	} else {
+		m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + styles.DimStyle.Render("⌘ "+label) + "\n"})
+	}
+	m.state = StateProcessing
+	m.chatView, _ = m.chatView.Update(msgs.ChatViewSetProcessingMsg{Processing: true})
+
+	m.sendGeneration++
+	gen := m.sendGeneration
+	expandCtx, cancelExpand := context.WithCancel(m.ctx)
+	m.cancelSend = cancelExpand
+
+	return tea.Batch(func() tea.Msg {
+		prompt, err := c.Expand(expandCtx, args, commands.ExpandOptions{})
+		return msgs.CommandExpandedMsg{Label: label, Prompt: prompt, AllowedTools: c.AllowedTools, Generation: gen, Err: err}
+	}, tickCmd())
+}
+
+// handleCommandExpanded sends an expanded command prompt, showing the command
+// line rather than the prompt as the user message.
+func (m *AppModel) handleCommandExpanded(msg msgs.CommandExpandedMsg) (tea.Model, tea.Cmd) {
+	if msg.Generation != m.sendGeneration {
+		return m, nil
+	}
+	if msg.Err != nil {
+		m.state = StateIdle
+		m.cancelSend = nil
+		m.chatView, _ = m.chatView.Update(msgs.ChatViewSetProcessingMsg{Processing: false})
+		if m.ctx.Err() == nil && !errors.Is(msg.Err, context.Canceled) {
+			errLine := styles.ErrorBlockStyle.Width(m.width).Render("Error: " + msg.Err.Error())
+			m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + errLine + "\n"})
+		}
+		return m, nil
+	}
+
+	m.chatView, _ = m.chatView.Update(msgs.ChatViewCommitUserMsg{Text: msg.Label})
+	m.chatView, _ = m.chatView.Update(msgs.ChatViewMarkSentMsg{})
+	return m, m.startSend([]content.Part{content.Text{Text: msg.Prompt}}, msg.AllowedTools)
+}
+
+// builtinCommands lists the built-in slash command names, including aliases
+// the picker does not show.
+func builtinCommands() map[string]bool {
+	names := map[string]bool{"/quit": true, "/subagents": true, "/tasks": true}
+	for _, c := range input.AvailableCommands {
+		names[c.Name] = true
+	}
+	return names
+}
+
+// loadCommands discovers the project and user commands. Commands that would
+// shadow a built-in are skipped and reported in the error along with any
+// files that failed to load.
+func loadCommands(shellyDir string) (map[string]commands.Command, []input.CommandDef, error) {
+	found, err := commands.Discover(shellydir.New(shellyDir).CommandsDir(), commands.UserDir())
+	errs := []error{err}
+
+	builtins := builtinCommands()
+	byName := make(map[string]commands.Command, len(found))
+	var defs []input.CommandDef
+	for _, c := range found {
+		name := "/" + c.Name
+		if builtins[name] {
+			errs = append(errs, fmt.Errorf("command %s (%s) shadows a built-in and is ignored", name, c.Path))
+			continue
+		}
+		byName[name] = c
+		defs = append(defs, input.CommandDef{Name: name, Desc: c.Description, Args: c.ArgumentHint})
+	}
+
+	return byName, defs, errors.Join(errs...)
+}
+
+// customHelp lists the user-defined commands for /help.
+func (m *AppModel) customHelp() string {
+	if len(m.commands) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Custom Commands:\n")
+	for _, name := range slices.Sorted(maps.Keys(m.commands)) {
+		c := m.commands[name]
+		usage := name
+		if c.ArgumentHint != "" {
+			usage += " " + c.ArgumentHint
+		}
+		fmt.Fprintf(&b, "  %-14s %s (%s)\n", usage, c.Description, c.Scope)
+	}
+	return lipgloss.NewStyle().Foreground(styles.ColorMuted).Render(strings.TrimRight(b.String(), "\n"))
+}
+
+// workflowList renders the configured workflows for a bare /run.
+func workflowList(wfs []engine.WorkflowConfig) string {
+	if len(wfs) == 0 {
+		return styles.DimStyle.Render("No workflows configured.")
+	}
+	var b strings.Builder
+	b.WriteString("Workflows:\n")
+	for _, wf := range wfs {
+		fmt.Fprintf(&b, "  %-14s %s\n", wf.Name, wf.Description)
+	}
+	b.WriteString("\nUsage: /run <workflow> [input]")
+	return lipgloss.NewStyle().Foreground(styles.ColorMuted).Render(b.String())
+}
+
 func (m *AppModel) executeTasks() {
 	if !m.menuBar.Visible() {
 		note := styles.DimStyle.Render("No tasks available.")
@@ -256,6 +556,69 @@ func (m *AppModel) executeTasks() {
 	m.recalcViewportHeight()
 }
 
+// executeCost toggles the cost panel.
+func (m *AppModel) executeCost() {
+	if m.activePanel == PanelCost {
+		m.closePanel()
+		return
+	}
+	m.closePanel() // close any other panel first
+	m.activePanel = PanelCost
+	m.costPanel.SetActive(true)
+	m.costPanel.SetUsage(m.sess.Usage(), m.eng.RateLimits())
+	m.resizeCostPanel()
+	m.menuFocused = false
+	m.menuBar.SetActive(false)
+	m.recalcViewportHeight()
+}
+
+// executeSpeak toggles reading final replies aloud.
+func (m *AppModel) executeSpeak() {
+	if m.eng.Synthesizer() == nil {
+		errLine := styles.ErrorBlockStyle.Width(m.width).Render("Speech is not configured (set speech.provider in the config).")
+		m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + errLine + "\n"})
+		return
+	}
+	m.speakReplies = !m.speakReplies
+	state := "off"
+	if m.speakReplies {
+		state = "on"
+	}
+	m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + styles.DimStyle.Render("⌘ /speak — replies are read aloud: "+state) + "\n"})
+}
+
+// executeVim toggles vim mode in the input.
+func (m *AppModel) executeVim() {
+	m.inputBox.SetVim(!m.inputBox.Vim())
+	state := "off"
+	if m.inputBox.Vim() {
+		state = "on (Esc for normal mode)"
+	}
+	m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + styles.DimStyle.Render("⌘ /vim — vim mode: "+state) + "\n"})
+	m.recalcViewportHeight()
+}
+
+// speakCmd synthesizes text and plays it with the configured player.
+func (m *AppModel) speakCmd(text string) tea.Cmd {
+	synth := m.eng.Synthesizer()
+	if synth == nil {
+		return nil
+	}
+	configured := m.eng.Speech().Player
+	ctx := m.ctx
+	return func() tea.Msg {
+		player, err := speech.Player(configured)
+		if err != nil {
+			return msgs.SpeakDoneMsg{Err: err}
+		}
+		audio, err := synth.Synthesize(ctx, speech.PlainText(text))
+		if err != nil {
+			return msgs.SpeakDoneMsg{Err: err}
+		}
+		return msgs.SpeakDoneMsg{Err: speech.Play(ctx, player, audio)}
+	}
+}
+
 func helpText() string {
 	return lipgloss.NewStyle().Foreground(styles.ColorMuted).Render(
 		fmt.Sprintf("Commands:\n" +
@@ -265,13 +628,26 @@ func helpText() string {
 			"  /sessions      Browse and resume previous sessions\n" +
 			"  /subagents     Browse running sub-agents\n" +
 			"  /tasks         View task board\n" +
+			"  /cost          Show token usage and cost per agent and provider\n" +
+			"  /queue         Review, edit and reorder queued prompts\n" +
+			"  /memory        Review and delete long-term memories\n" +
+			"  /model         Switch the model (/model [provider])\n" +
+			"  /agent         Switch the agent (/agent [name])\n" +
+			"  /run           Run a workflow (/run <name> [input])\n" +
+			"  /speak         Toggle reading replies aloud\n" +
+			"  /vim           Toggle vim mode in the input\n" +
 			"  /settings      Open the configuration wizard\n" +
 			"  /quit          Exit the chat\n\n" +
 			"Shortcuts:\n" +
-			"  Enter          Submit message\n" +
+			"  Enter          Submit message (queued while the agent is running)\n" +
+			"  Ctrl+S         Steer: send to the running agent at its next step\n" +
 			"  Shift+Enter    New line\n" +
 			"  Alt+Enter      New line\n" +
+			"  Ctrl+G         Edit the message in $VISUAL / $EDITOR\n" +
+			"  Ctrl+R         Search message history\n" +
+			"  Ctrl+U         Remove pending attachments\n" +
 			"  Ctrl+B         Browse sub-agents menu\n" +
+			"  Ctrl+O         Select tool results to expand or collapse\n" +
 			"  Escape         Navigate back / interrupt agent / dismiss picker\n" +
 			"  Ctrl+C         Exit\n" +
 			"  @              File picker\n" +
@@ -281,6 +657,7 @@ func helpText() string {
 			"  ←→             Navigate menu bar items\n" +
 			"  ↑↓             Navigate list items\n" +
 			"  Enter          Select item / view sub-agent\n" +
-			"  Escape         Close panel / navigate back to parent view"),
+			"  Escape         Close panel / navigate back to parent view\n\n" +
+			"Keys can be rebound in the keybindings: config section."),
 	)
 }
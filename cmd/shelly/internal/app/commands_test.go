@@ -0,0 +1,199 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/germanamz/shelly/cmd/shelly/internal/input"
+	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/commands"
+	"github.com/germanamz/shelly/pkg/engine"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCommands(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	shellyDir := t.TempDir()
+	dir := filepath.Join(shellyDir, "commands")
+	require.NoError(t, os.MkdirAll(dir, 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "review.md"), []byte("---\ndescription: Review\nargument-hint: \"[focus]\"\n---\nReview $ARGUMENTS"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "help.md"), []byte("Shadowed"), 0o600))
+
+	byName, defs, err := loadCommands(shellyDir)
+	require.ErrorContains(t, err, "command /help")
+	assert.Contains(t, err.Error(), "shadows a built-in")
+
+	require.Contains(t, byName, "/review")
+	assert.NotContains(t, byName, "/help")
+	require.Len(t, defs, 1)
+	assert.Equal(t, "/review", defs[0].Name)
+	assert.Equal(t, "[focus]", defs[0].Args)
+}
+
+func TestCustomCommand_Dispatch(t *testing.T) {
+	m := newIntegrationModel()
+	m.commands = map[string]commands.Command{"/review": {Name: "review", Body: "Review $ARGUMENTS"}}
+
+	res := m.dispatchCommand("/review auth flow")
+	require.True(t, res.handled)
+	assert.NotNil(t, res.cmd)
+	assert.Equal(t, StateProcessing, m.state, "expansion runs as part of the send")
+	assert.NotNil(t, m.cancelSend)
+
+	assert.False(t, m.dispatchCommand("/unknown").handled)
+}
+
+func TestCustomCommand_RejectedWhileProcessing(t *testing.T) {
+	m := newIntegrationModel()
+	m.commands = map[string]commands.Command{"/review": {Name: "review"}}
+	m.state = StateProcessing
+	gen := m.sendGeneration
+
+	res := m.dispatchCommand("/review")
+	assert.True(t, res.handled)
+	assert.Nil(t, res.cmd)
+	assert.Equal(t, gen, m.sendGeneration)
+}
+
+func TestCustomCommand_ExpandError(t *testing.T) {
+	m := newIntegrationModel()
+	m.commands = map[string]commands.Command{"/review": {Name: "review"}}
+	m.dispatchCommand("/review")
+
+	// A stale generation is ignored.
+	m.handleCommandExpanded(msgs.CommandExpandedMsg{Generation: m.sendGeneration - 1, Err: assert.AnError})
+	assert.Equal(t, StateProcessing, m.state)
+
+	// Cancellation (Escape) returns to idle.
+	m.handleCommandExpanded(msgs.CommandExpandedMsg{Generation: m.sendGeneration, Err: context.Canceled})
+	assert.Equal(t, StateIdle, m.state)
+	assert.Nil(t, m.cancelSend)
+}
+
+func TestCustomHelp(t *testing.T) {
+	m := newTestModel()
+	assert.Empty(t, m.customHelp())
+
+	m.commands = map[string]commands.Command{"/review": {Name: "review", Description: "Review the diff", ArgumentHint: "[focus]", Scope: commands.ScopeProject}}
+	help := m.customHelp()
+	assert.Contains(t, help, "Custom Commands")
+	assert.Contains(t, help, "/review [focus]")
+	assert.Contains(t, help, "Review the diff (project)")
+}
+
+type replyCompleter struct{}
+
+func (replyCompleter) Complete(context.Context, *chat.Chat, []toolbox.Tool) (message.Message, error) {
+	return message.NewText("bot", role.Assistant, "ok"), nil
+}
+
+func newSwitchModel(t *testing.T) AppModel {
+	t.Helper()
+	engine.RegisterProvider("app-switch-mock", func(engine.ProviderConfig) (modeladapter.Completer, error) {
+		return replyCompleter{}, nil
+	})
+	eng, err := engine.New(context.Background(), engine.Config{
+		ShellyDir: t.TempDir(),
+		Providers: []engine.ProviderConfig{
+			{Name: "cheap", Kind: "app-switch-mock", Model: "small"},
+			{Name: "smart", Kind: "app-switch-mock", Model: "large"},
+		},
+		Agents: []engine.AgentConfig{{Name: "bot", Provider: "cheap"}},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = eng.Close() })
+
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+
+	m := newIntegrationModel()
+	m.eng = eng
+	m.sess = sess
+	return m
+}
+
+func TestSwitchCommand_Picker(t *testing.T) {
+	m := newSwitchModel(t)
+
+	res := m.dispatchCommand("/model")
+	require.True(t, res.handled)
+	require.NotNil(t, res.cmd)
+	activate, ok := res.cmd().(msgs.ChoicePickerActivateMsg)
+	require.True(t, ok)
+	assert.Equal(t, switchModel, activate.Kind)
+	require.Len(t, activate.Choices, 2)
+	assert.Equal(t, msgs.Choice{ID: "cheap", Label: "cheap", Detail: "app-switch-mock/small", Current: true}, activate.Choices[0])
+
+	m.executeSwitch(activate.Kind, "smart")
+	assert.Equal(t, "smart", m.sess.ProviderName())
+	assert.Equal(t, "large", m.sess.ProviderInfo().Model)
+
+	assert.False(t, m.dispatchCommand("/models").handled)
+}
+
+func TestSwitchCommand_Errors(t *testing.T) {
+	m := newSwitchModel(t)
+
+	res := m.dispatchCommand("/model missing")
+	assert.True(t, res.handled)
+	assert.Equal(t, "cheap", m.sess.ProviderName(), "an unknown provider leaves the session as is")
+
+	m.state = StateProcessing
+	res = m.dispatchCommand("/model smart")
+	assert.True(t, res.handled)
+	assert.Nil(t, res.cmd)
+	assert.Equal(t, "cheap", m.sess.ProviderName(), "switching is rejected while the agent runs")
+}
+
+func TestCostCommand_TogglesPanel(t *testing.T) {
+	m := newSwitchModel(t)
+
+	require.True(t, m.dispatchCommand("/cost").handled)
+	assert.Equal(t, PanelCost, m.activePanel)
+	assert.True(t, m.costPanel.Active())
+	assert.Equal(t, 3, m.costPanel.Height(), "an empty panel shows a placeholder")
+	assert.Contains(t, m.activePanelView(), "No usage recorded yet.")
+
+	_, err := m.sess.Send(context.Background(), "hi")
+	require.NoError(t, err)
+	m.refreshCostPanel()
+	assert.Greater(t, m.costPanel.Height(), 3, "the panel grows with the usage rows")
+
+	m.dispatchCommand("/cost")
+	assert.Equal(t, PanelNone, m.activePanel)
+	assert.Equal(t, 0, m.costPanel.Height())
+}
+
+func TestDrafts_SwitchAndResume(t *testing.T) {
+	m := newSwitchModel(t)
+	draftsPath := filepath.Join(t.TempDir(), "drafts.json")
+	m.inputBox = input.New(filepath.Join(t.TempDir(), "history"), draftsPath)
+	m.inputBox.SetSession(m.sess.PersistID())
+
+	_, err := m.sess.Send(context.Background(), "hi")
+	require.NoError(t, err)
+	first := m.sess.PersistID()
+	m.inputBox.SetText("half-written")
+
+	require.True(t, m.resetSession("", "⌘ /clear"))
+	t.Cleanup(func() { m.cancelBridge() })
+	assert.Empty(t, m.inputBox.Text())
+	m.inputBox.SetText("other")
+
+	m.executeResumeSession(first)
+	assert.Equal(t, first, m.sess.PersistID())
+	assert.Equal(t, "half-written", m.inputBox.Text(), "the draft survives switching away")
+
+	// Drafts are keyed by persistent ID, so a new process finds them.
+	reloaded := input.New(filepath.Join(t.TempDir(), "history"), draftsPath)
+	reloaded.SetSession(first)
+	assert.Equal(t, "half-written", reloaded.Text())
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
+	"github.com/germanamz/shelly/cmd/shelly/internal/styles"
+)
+
+// executeMemory toggles the memory panel, which lists the long-term memories
+// saved by agents for review and deletion.
+func (m *AppModel) executeMemory() {
+	if m.activePanel == PanelMemory {
+		m.closePanel()
+		return
+	}
+	m.closePanel() // close any other panel first
+	m.activePanel = PanelMemory
+	m.memoryPanel.SetActive(true)
+	m.refreshMemoryPanel()
+	m.menuFocused = false
+	m.menuBar.SetActive(false)
+}
+
+// refreshMemoryPanel reloads the memories from the store and resizes the
+// panel to fit them.
+func (m *AppModel) refreshMemoryPanel() {
+	entries, err := m.eng.Memory().List()
+	if err != nil {
+		m.appendError(err)
+	}
+	m.memoryPanel.SetEntries(entries)
+	m.resizeMemoryPanel()
+	m.recalcViewportHeight()
+}
+
+// resizeMemoryPanel computes and sets the panel size based on the number of
+// memories.
+func (m *AppModel) resizeMemoryPanel() {
+	count := m.memoryPanel.Len()
+	// Panel height: min(items + 2 borders, 12), or 3 for empty state.
+	h := count + 2
+	if count == 0 {
+		h = 3
+	}
+	if h > 12 {
+		h = 12
+	}
+	m.memoryPanel.SetSize(m.width, h)
+}
+
+// handleMemoryPanelKey handles keys while the memory panel is open: Up/Down
+// select, Enter shows the whole memory in the chat and d or Delete forgets it.
+func (m *AppModel) handleMemoryPanelKey(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
+	k := msg.Key()
+	e, ok := m.memoryPanel.Selected()
+
+	switch {
+	case k.Code == tea.KeyEsc:
+		m.closePanel()
+	case k.Code == tea.KeyUp:
+		m.memoryPanel.MoveUp()
+	case k.Code == tea.KeyDown:
+		m.memoryPanel.MoveDown()
+	case k.Code == tea.KeyEnter && ok:
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s · %s · updated %s", e.ID, e.Kind, e.UpdatedAt.Local().Format("2006-01-02 15:04"))
+		if e.Agent != "" {
+			fmt.Fprintf(&b, " by %s", e.Agent)
+		}
+		if len(e.Tags) > 0 {
+			fmt.Fprintf(&b, " · tags: %s", strings.Join(e.Tags, ", "))
+		}
+		text := styles.DimStyle.Render(b.String()) + "\n" + e.Content
+		m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + text + "\n"})
+	case (k.Code == tea.KeyDelete || k.Code == tea.KeyBackspace || k.Text == "d") && ok:
+		if err := m.eng.Memory().Forget(e.ID); err != nil {
+			m.appendError(err)
+		}
+		m.refreshMemoryPanel()
+	}
+	return m, nil
+}
@@ -0,0 +1,41 @@
+package app
+
+import (
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/germanamz/shelly/cmd/shelly/internal/memorypanel"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory_Panel(t *testing.T) {
+	m := newSwitchModel(t)
+	m.memoryPanel = memorypanel.New()
+	for _, e := range []memory.Entry{
+		{Kind: memory.KindFact, Content: "The API listens on port 8080"},
+		{Kind: memory.KindPreference, Content: "Reply in Spanish", Agent: "coder"},
+	} {
+		_, _, err := m.eng.Memory().Remember(e)
+		require.NoError(t, err)
+	}
+
+	require.True(t, m.dispatchCommand("/memory").handled)
+	require.Equal(t, PanelMemory, m.activePanel)
+	assert.Equal(t, 4, m.memoryPanel.Height())
+	assert.Contains(t, m.activePanelView(), "Reply in Spanish")
+
+	// The most recently saved memory is listed first; d forgets the selection.
+	m.handleKey(keyMsg(tea.KeyDown, 0))
+	m.handleKey(tea.KeyPressMsg(tea.Key{Code: 'd', Text: "d"}))
+	entries, err := m.eng.Memory().List()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "Reply in Spanish", entries[0].Content)
+	assert.Equal(t, 3, m.memoryPanel.Height())
+
+	m.handleKey(escMsg())
+	assert.Equal(t, PanelNone, m.activePanel)
+	assert.Equal(t, 0, m.memoryPanel.Height())
+}
@@ -0,0 +1,199 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/germanamz/shelly/cmd/shelly/internal/menubar"
+	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
+	"github.com/germanamz/shelly/cmd/shelly/internal/queuepanel"
+	"github.com/germanamz/shelly/cmd/shelly/internal/styles"
+	"github.com/germanamz/shelly/pkg/engine"
+	"github.com/germanamz/shelly/pkg/sessions"
+)
+
+// enqueue queues a prompt submitted while the agent is running. Interrupt
+// prompts are injected into the running agent at its next step; the others
+// are sent one at a time as turns complete.
+func (m *AppModel) enqueue(msg msgs.InputSubmitMsg) (tea.Model, tea.Cmd) {
+	mode := sessions.QueueAfterTurn
+	if msg.Interrupt {
+		mode = sessions.QueueInterrupt
+	}
+	if _, err := m.sess.Enqueue(mode, msg.Text, msg.Parts...); err != nil {
+		m.appendError(err)
+		return m, nil
+	}
+	m.onQueueChanged()
+	return m, nil
+}
+
+// sendNextQueued sends the first queued prompt once a turn has completed.
+func (m *AppModel) sendNextQueued() tea.Cmd {
+	if m.ctx.Err() != nil {
+		return nil
+	}
+	p, attachments, ok := m.sess.PopQueued()
+	if !ok {
+		return nil
+	}
+	m.onQueueChanged()
+	m.chatView, _ = m.chatView.Update(msgs.ChatViewCommitUserMsg{Text: p.Text, Parts: attachments})
+	m.chatView, _ = m.chatView.Update(msgs.ChatViewMarkSentMsg{})
+	return m.startSend(buildSendParts(p.Text, attachments), nil)
+}
+
+// noteQueue tells the user that queued prompts wait for the next turn, e.g.
+// after a failed turn ("kept") or on resume ("restored").
+func (m *AppModel) noteQueue(verb string) {
+	n := m.queuePanel.Len()
+	if n == 0 {
+		return
+	}
+	noun := "prompts"
+	if n == 1 {
+		noun = "prompt"
+	}
+	note := fmt.Sprintf("%d queued %s %s — sent after the next turn (/queue to review)", n, noun, verb)
+	m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + styles.DimStyle.Render(note) + "\n"})
+}
+
+// onQueueChanged reloads the session's queue into the queue panel and its
+// menu bar item.
+func (m *AppModel) onQueueChanged() {
+	q := m.sess.Queue()
+	m.queuePanel.SetQueue(q)
+
+	// Lazy item creation: add "Queue" item when the first prompt is queued.
+	if len(q) > 0 && !m.menuBar.Visible() {
+		m.menuBar.SetVisible(true)
+		m.menuBar.SetWidth(m.width)
+		m.recalcViewportHeight()
+	}
+	if m.menuBar.Visible() {
+		m.menuBar.AddOrUpdateItem(menubar.Item{
+			ID:    queuepanel.PanelID,
+			Label: "Queue",
+			Badge: len(q),
+		})
+	}
+
+	if m.activePanel == PanelQueue {
+		m.resizeQueuePanel()
+		m.recalcViewportHeight()
+	}
+}
+
+// executeQueue toggles the queue panel.
+func (m *AppModel) executeQueue() {
+	if m.activePanel == PanelQueue {
+		m.closePanel()
+		return
+	}
+	m.closePanel() // close any other panel first
+	m.activePanel = PanelQueue
+	m.queuePanel.SetActive(true)
+	m.queuePanel.SetQueue(m.sess.Queue())
+	m.resizeQueuePanel()
+	m.menuFocused = false
+	m.menuBar.SetActive(false)
+	m.recalcViewportHeight()
+}
+
+// resizeQueuePanel computes and sets the panel size based on the queue length.
+func (m *AppModel) resizeQueuePanel() {
+	count := m.queuePanel.Len()
+	// Panel height: min(items + 2 borders, 12), or 3 for empty state.
+	h := count + 2
+	if count == 0 {
+		h = 3
+	}
+	if h > 12 {
+		h = 12
+	}
+	m.queuePanel.SetSize(m.width, h)
+}
+
+// handleQueuePanelKey handles keys while the queue panel is open: Up/Down
+// select, Shift+Up/Down reorder, Tab toggles the delivery mode, Enter edits
+// the prompt in the input box and d or Delete removes it.
+func (m *AppModel) handleQueuePanelKey(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
+	k := msg.Key()
+	p, ok := m.queuePanel.Selected()
+	shift := k.Mod&tea.ModShift != 0
+
+	var err error
+	switch {
+	case k.Code == tea.KeyEsc:
+		m.closePanel()
+		return m, nil
+	case k.Code == tea.KeyUp && shift && ok:
+		err = m.sess.MoveQueued(p.ID, -1)
+	case k.Code == tea.KeyDown && shift && ok:
+		err = m.sess.MoveQueued(p.ID, 1)
+	case k.Code == tea.KeyUp:
+		m.queuePanel.MoveUp()
+	case k.Code == tea.KeyDown:
+		m.queuePanel.MoveDown()
+	case k.Code == tea.KeyTab && ok:
+		mode := sessions.QueueInterrupt
+		if p.Mode == sessions.QueueInterrupt {
+			mode = sessions.QueueAfterTurn
+		}
+		err = m.sess.SetQueuedMode(p.ID, mode)
+	case (k.Code == tea.KeyDelete || k.Code == tea.KeyBackspace || k.Text == "d") && ok:
+		err = m.sess.RemoveQueued(p.ID)
+	case k.Code == tea.KeyEnter && ok:
+		m.beginQueueEdit(p)
+		return m, nil
+	}
+	if err != nil {
+		m.appendError(err)
+	}
+	m.onQueueChanged()
+	return m, nil
+}
+
+// beginQueueEdit closes the panel and loads a queued prompt into the input
+// box, setting aside the text being typed. Submitting saves the edit and Esc
+// cancels it.
+func (m *AppModel) beginQueueEdit(p sessions.QueuedPrompt) {
+	m.closePanel()
+	m.queueEdit = p.ID
+	m.queueDraft = m.inputBox.Text()
+	m.inputBox.SetText(p.Text)
+	m.recalcViewportHeight()
+}
+
+// endQueueEdit leaves queued prompt editing and restores the text that was
+// set aside.
+func (m *AppModel) endQueueEdit() {
+	m.queueEdit = ""
+	m.inputBox.SetText(m.queueDraft)
+	m.queueDraft = ""
+}
+
+// saveQueueEdit replaces the text of the queued prompt being edited. A prompt
+// sent in the meantime is submitted again with the edited text.
+func (m *AppModel) saveQueueEdit(msg msgs.InputSubmitMsg) (tea.Model, tea.Cmd) {
+	id := m.queueEdit
+	m.endQueueEdit()
+	m.recalcViewportHeight()
+
+	err := m.sess.EditQueued(id, msg.Text)
+	if errors.Is(err, engine.ErrQueuedPromptNotFound) {
+		return m.handleSubmit(msg)
+	}
+	if err != nil {
+		m.appendError(err)
+	}
+	m.onQueueChanged()
+	return m, nil
+}
+
+// appendError shows an error line in the chat view.
+func (m *AppModel) appendError(err error) {
+	errLine := styles.ErrorBlockStyle.Width(m.width).Render("Error: " + err.Error())
+	m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + errLine + "\n"})
+}
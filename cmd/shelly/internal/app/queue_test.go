@@ -0,0 +1,109 @@
+package app
+
+import (
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/germanamz/shelly/cmd/shelly/internal/input"
+	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
+	"github.com/germanamz/shelly/cmd/shelly/internal/queuepanel"
+	"github.com/germanamz/shelly/pkg/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newQueueModel(t *testing.T) AppModel {
+	t.Helper()
+	m := newSwitchModel(t)
+	m.inputBox = input.New("", "")
+	m.inputBox, _ = m.inputBox.Update(msgs.InputEnableMsg{})
+	m.queuePanel = queuepanel.New()
+	return m
+}
+
+func queueTexts(m AppModel) []string {
+	var out []string
+	for _, p := range m.sess.Queue() {
+		out = append(out, p.Text)
+	}
+	return out
+}
+
+func TestQueue_SubmitWhileProcessing(t *testing.T) {
+	m := newQueueModel(t)
+	m.state = StateProcessing
+	gen := m.sendGeneration
+
+	m.handleSubmit(msgs.InputSubmitMsg{Text: "then this"})
+	m.handleSubmit(msgs.InputSubmitMsg{Text: "stop", Interrupt: true})
+	assert.Equal(t, gen, m.sendGeneration, "the running send is not restarted")
+	assert.Equal(t, []string{"then this", "stop"}, queueTexts(m))
+	assert.Equal(t, sessions.QueueInterrupt, m.sess.Queue()[1].Mode)
+
+	assert.Contains(t, m.sessionStatusSegments(), "2 queued")
+	require.True(t, m.menuBar.Visible())
+	assert.Equal(t, queuepanel.PanelID, m.menuBar.Items()[0].ID)
+	assert.Equal(t, 2, m.menuBar.Items()[0].Badge)
+}
+
+func TestQueue_Panel(t *testing.T) {
+	m := newQueueModel(t)
+	m.state = StateProcessing
+	for _, text := range []string{"one", "two", "three"} {
+		m.handleSubmit(msgs.InputSubmitMsg{Text: text})
+	}
+
+	require.True(t, m.dispatchCommand("/queue").handled)
+	require.Equal(t, PanelQueue, m.activePanel)
+	assert.Equal(t, 5, m.queuePanel.Height())
+	assert.Contains(t, m.activePanelView(), "after turn")
+
+	// Shift+Down moves the selected prompt and the cursor follows it.
+	m.handleKey(keyMsg(tea.KeyDown, tea.ModShift))
+	m.handleKey(keyMsg(tea.KeyDown, tea.ModShift))
+	assert.Equal(t, []string{"two", "three", "one"}, queueTexts(m))
+	m.handleKey(keyMsg(tea.KeyUp, 0))
+	m.handleKey(keyMsg(tea.KeyTab, 0))
+	assert.Equal(t, sessions.QueueInterrupt, m.sess.Queue()[1].Mode)
+	assert.Contains(t, m.activePanelView(), "interrupt")
+
+	m.handleKey(tea.KeyPressMsg(tea.Key{Code: 'd', Text: "d"}))
+	assert.Equal(t, []string{"two", "one"}, queueTexts(m))
+
+	// Enter edits the prompt in the input box, setting the typed text aside.
+	m.inputBox.SetText("half-typed")
+	m.handleKey(enterMsg())
+	assert.Equal(t, PanelNone, m.activePanel)
+	assert.Equal(t, "one", m.inputBox.Text())
+	m.handleSubmit(msgs.InputSubmitMsg{Text: "one, edited"})
+	assert.Equal(t, []string{"two", "one, edited"}, queueTexts(m))
+	assert.Equal(t, "half-typed", m.inputBox.Text())
+
+	// Esc cancels an edit.
+	m.executeQueue()
+	m.handleKey(enterMsg())
+	m.handleKey(escMsg())
+	assert.Empty(t, m.queueEdit)
+	assert.Equal(t, "half-typed", m.inputBox.Text())
+	assert.Equal(t, []string{"two", "one, edited"}, queueTexts(m))
+}
+
+func TestQueue_SentAfterTurn(t *testing.T) {
+	m := newQueueModel(t)
+	m.state = StateProcessing
+	m.handleSubmit(msgs.InputSubmitMsg{Text: "first"})
+	m.handleSubmit(msgs.InputSubmitMsg{Text: "second"})
+
+	// A failed turn keeps the queue.
+	m.handleSendComplete(msgs.SendCompleteMsg{Generation: m.sendGeneration, Err: assert.AnError})
+	assert.Equal(t, StateIdle, m.state)
+	assert.Len(t, m.sess.Queue(), 2)
+
+	// A completed turn sends the next prompt.
+	m.state = StateProcessing
+	_, cmd := m.handleSendComplete(msgs.SendCompleteMsg{Generation: m.sendGeneration})
+	require.NotNil(t, cmd)
+	assert.Equal(t, StateProcessing, m.state)
+	assert.Equal(t, []string{"second"}, queueTexts(m))
+	assert.Contains(t, m.sessionStatusSegments(), "1 queued")
+}
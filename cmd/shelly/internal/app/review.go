@@ -0,0 +1,84 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/germanamz/shelly/cmd/shelly/internal/diffview"
+	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
+	"github.com/germanamz/shelly/cmd/shelly/internal/styles"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/filesystem"
+)
+
+// handleFileReview queues a file change for review and opens it when no other
+// review is open. Reviews are shown one at a time in arrival order.
+func (m *AppModel) handleFileReview(msg msgs.FileReviewMsg) (tea.Model, tea.Cmd) {
+	m.reviews = append(m.reviews, msg)
+	if m.reviewActive == nil {
+		m.openNextReview()
+	}
+	return m, nil
+}
+
+// handleReviewDecided delivers the decision to the engine, notes it in the
+// chat and opens the next queued review.
+func (m *AppModel) handleReviewDecided(msg msgs.FileReviewDecidedMsg) (tea.Model, tea.Cmd) {
+	if m.reviewActive != nil {
+		line := styles.DimStyle.Render(reviewSummary(m.reviewActive.Change(), msg.Decision))
+		m.chatView, _ = m.chatView.Update(msgs.ChatViewAppendMsg{Content: "\n" + line + "\n"})
+	}
+	m.openNextReview()
+
+	sess := m.sess
+	return m, func() tea.Msg {
+		if err := sess.RespondReview(msg.ID, msg.Decision); err != nil {
+			return msgs.RespondErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// openNextReview shows the next queued review, or closes the review prompt
+// when none is left.
+func (m *AppModel) openNextReview() {
+	m.reviewActive = nil
+	if len(m.reviews) > 0 {
+		next := m.reviews[0]
+		m.reviews = m.reviews[1:]
+		dv := diffview.New(next.Request, next.Agent, m.width, m.reviewHeight())
+		m.reviewActive = &dv
+	}
+	m.recalcViewportHeight()
+}
+
+// reviewHeight is the maximum height of the review prompt: two thirds of the
+// terminal, leaving room for the chat.
+func (m AppModel) reviewHeight() int {
+	return max(m.height*2/3, 12)
+}
+
+// reviewSummary describes a review decision, e.g. "Reviewed main.go: 2
+// accepted, 1 rejected".
+func reviewSummary(c filesystem.Change, d filesystem.Decision) string {
+	counts := map[string]int{}
+	for i := range c.Hunks {
+		verdict := filesystem.HunkAccept
+		if i < len(d.Hunks) && d.Hunks[i].Verdict != "" {
+			verdict = d.Hunks[i].Verdict
+		}
+		counts[verdict]++
+	}
+
+	var parts []string
+	for _, v := range []struct{ verdict, label string }{
+		{filesystem.HunkAccept, "accepted"},
+		{filesystem.HunkEdit, "edited"},
+		{filesystem.HunkReject, "rejected"},
+	} {
+		if n := counts[v.verdict]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, v.label))
+		}
+	}
+	return fmt.Sprintf("Reviewed %s: %s", c.Path, strings.Join(parts, ", "))
+}
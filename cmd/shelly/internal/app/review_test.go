@@ -0,0 +1,49 @@
+package app
+
+import (
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/filesystem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileReview_QueueAndDecide(t *testing.T) {
+	m := newSwitchModel(t)
+	first := filesystem.NewChange("fs_write", "/tmp/a.go", "", "package a\n")
+	second := filesystem.NewChange("fs_edit", "/tmp/b.go", "x\n", "y\n")
+
+	m.handleFileReview(msgs.FileReviewMsg{Request: filesystem.ReviewRequest{ID: "r-1", Change: first}, Agent: "bot"})
+	m.handleFileReview(msgs.FileReviewMsg{Request: filesystem.ReviewRequest{ID: "r-2", Change: second}, Agent: "bot"})
+	require.NotNil(t, m.reviewActive)
+	assert.Equal(t, "r-1", m.reviewActive.ID())
+	assert.Contains(t, m.View().Content, "fs_write /tmp/a.go (bot)")
+
+	// Keys go to the review: "r" rejects the only hunk, Enter submits.
+	_, _ = m.handleKey(tea.KeyPressMsg{Code: 'r', Text: "r"})
+	_, cmd := m.handleKey(tea.KeyPressMsg{Code: tea.KeyEnter})
+	require.NotNil(t, cmd)
+	decided, ok := cmd().(msgs.FileReviewDecidedMsg)
+	require.True(t, ok)
+
+	_, respond := m.handleReviewDecided(decided)
+	require.NotNil(t, m.reviewActive)
+	assert.Equal(t, "r-2", m.reviewActive.ID(), "the next queued review opens")
+	assert.Contains(t, m.chatView.View(), "Reviewed /tmp/a.go: 1 rejected")
+
+	// The engine has no such pending review, so responding fails visibly.
+	_, isErr := respond().(msgs.RespondErrorMsg)
+	assert.True(t, isErr)
+
+	m.handleReviewDecided(msgs.FileReviewDecidedMsg{ID: "r-2"})
+	assert.Nil(t, m.reviewActive)
+}
+
+func TestReviewSummary(t *testing.T) {
+	c := filesystem.Change{Path: "f.go", Hunks: make([]filesystem.Hunk, 3)}
+	d := filesystem.Decision{Hunks: []filesystem.HunkDecision{{Verdict: filesystem.HunkReject}, {Verdict: filesystem.HunkEdit}}}
+
+	assert.Equal(t, "Reviewed f.go: 1 accepted, 1 edited, 1 rejected", reviewSummary(c, d))
+}
@@ -67,6 +67,21 @@ func (m *Model) SetValue(s string) {
 	m.TA.SetValue(s)
 }
 
+// SetCursor moves the cursor to column col of hard line row. Both are
+// clamped to the text.
+func (m *Model) SetCursor(row, col int) {
+	m.TA.MoveToBegin()
+	// CursorDown moves by visual line, so a wrapped line takes several steps;
+	// the bound guards against a row past the end.
+	for range m.VisualLineCount() + m.TA.LineCount() {
+		if m.TA.Line() >= row {
+			break
+		}
+		m.TA.CursorDown()
+	}
+	m.TA.SetCursorColumn(col)
+}
+
 // Reset clears the textarea and resets height to MinHeight.
 func (m *Model) Reset() {
 	m.TA.Reset()
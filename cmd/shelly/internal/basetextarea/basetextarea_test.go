@@ -55,3 +55,21 @@ func TestWordWrapLineCount(t *testing.T) {
 		})
 	}
 }
+
+func TestSetCursor(t *testing.T) {
+	m := New("", 1, 5)
+	m.SetWidth(10)
+	m.SetValue("aaaa bbbbb cccc\nsecond\nthird")
+
+	m.SetCursor(1, 3)
+	assert.Equal(t, 1, m.TA.Line())
+	assert.Equal(t, 3, m.TA.Column())
+
+	m.SetCursor(2, 99)
+	assert.Equal(t, 2, m.TA.Line())
+	assert.Equal(t, 5, m.TA.Column())
+
+	m.SetCursor(0, 12)
+	assert.Equal(t, 0, m.TA.Line())
+	assert.Equal(t, 12, m.TA.Column())
+}
@@ -8,8 +8,10 @@ import (
 	tea "charm.land/bubbletea/v2"
 	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
 	"github.com/germanamz/shelly/pkg/agent"
+	"github.com/germanamz/shelly/pkg/budget"
 	"github.com/germanamz/shelly/pkg/chats/chat"
 	"github.com/germanamz/shelly/pkg/codingtoolbox/ask"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/filesystem"
 	"github.com/germanamz/shelly/pkg/engine"
 	"github.com/germanamz/shelly/pkg/modeladapter/usage"
 	"github.com/germanamz/shelly/pkg/tasks"
@@ -50,6 +52,21 @@ func Start(ctx context.Context, p *tea.Program, c *chat.Chat, events *engine.Eve
 					}
 					p.Send(msgs.AskUserMsg{Question: q, Agent: ev.Agent})
 
+				case engine.EventFileReview:
+					if r, ok := ev.Data.(filesystem.ReviewRequest); ok {
+						p.Send(msgs.FileReviewMsg{Request: r, Agent: ev.Agent})
+					}
+
+				case engine.EventBudgetWarning:
+					if s, ok := ev.Data.(budget.Scope); ok {
+						p.Send(msgs.BudgetWarningMsg{Agent: ev.Agent, Scope: s})
+					}
+
+				case engine.EventQueueChanged:
+					if d, ok := ev.Data.(engine.QueueEvent); ok {
+						p.Send(msgs.QueueChangedMsg{SessionID: ev.SessionID, Injected: d.Injected})
+					}
+
 				case engine.EventAgentStart:
 					var prefix, parent, providerLabel, task string
 					if d, ok := ev.Data.(agent.AgentEventData); ok {
@@ -2,6 +2,7 @@ package chatview
 
 import (
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -53,6 +54,8 @@ type ChatViewModel struct {
 	viewedAgent string           // "" = root view, or agent instance name
 	viewStack   []viewStackEntry // navigation stack for back functionality
 
+	selected *ToolCallItem // tool result selected for expanding; nil when not selecting
+
 	HasMessages    bool
 	Processing     bool
 	SpinnerIdx     int
@@ -394,6 +397,11 @@ func (m *ChatViewModel) rebuildContent() {
 	wasAtBottom := m.scrollToBottom || m.viewport.AtBottom() || m.viewport.TotalLineCount() <= m.viewport.Height()
 	m.scrollToBottom = false
 
+	// Selection ends when the selected call leaves the live view.
+	if m.selected != nil && !slices.Contains(m.selectableCalls(), m.selected) {
+		m.setSelected(nil)
+	}
+
 	var full strings.Builder
 
 	// When viewing a sub-agent, show its per-agent committed history;
@@ -421,6 +429,11 @@ func (m *ChatViewModel) rebuildContent() {
 
 	m.viewport.SetContent(full.String())
 
+	if m.selected != nil {
+		m.scrollToSelected(full.String())
+		return
+	}
+
 	if wasAtBottom {
 		m.viewport.GotoBottom()
 	}
@@ -494,6 +507,13 @@ func renderAttachmentLabel(p content.Part) string {
 			name = v.MediaType
 		}
 		return fmt.Sprintf("[Document: %s (%s)]", name, size)
+	case content.Audio:
+		size := format.FmtBytes(len(v.Data))
+		name := v.Path
+		if name == "" {
+			name = v.MediaType
+		}
+		return fmt.Sprintf("[Audio: %s (%s)]", name, size)
 	default:
 		return ""
 	}
@@ -545,6 +565,13 @@ func (m *ChatViewModel) processAssistantMessage(msg message.Message) {
 	if len(calls) > 0 {
 		ac := m.getOrCreateContainer(agentName, "")
 
+		// Reasoning summaries (e.g. from the openai_responses provider).
+		for _, p := range msg.Parts {
+			if r, ok := p.(content.Reasoning); ok && r.Text != "" {
+				ac.AddThinking(r.Text)
+			}
+		}
+
 		if text != "" {
 			if ac.Prefix == "📝" {
 				ac.AddPlan(text)
@@ -858,6 +885,7 @@ func (m *ChatViewModel) clear() {
 	m.committed = nil
 	m.viewedAgent = ""
 	m.viewStack = nil
+	m.selected = nil
 	m.Processing = false
 	m.SpinnerIdx = 0
 	m.ProcessingMsg = ""
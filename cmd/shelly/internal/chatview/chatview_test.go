@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	tea "charm.land/bubbletea/v2"
 	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
 	"github.com/germanamz/shelly/pkg/chats/content"
 	"github.com/germanamz/shelly/pkg/chats/message"
@@ -66,6 +67,19 @@ func TestChatViewUserMessageWithDocumentAttachment(t *testing.T) {
 	assert.Contains(t, cv.committed[0], "[Document: /tmp/report.pdf (150.0 KB)]")
 }
 
+func TestChatViewUserMessageWithAudioAttachment(t *testing.T) {
+	cv := newTestChatView()
+	cv, _ = cv.Update(msgs.ChatViewCommitUserMsg{
+		Text: "listen",
+		Parts: []content.Part{
+			content.Audio{Path: "memo.wav", Data: make([]byte, 2048), MediaType: "audio/wav"},
+		},
+	})
+
+	assert.Len(t, cv.committed, 1)
+	assert.Contains(t, cv.committed[0], "[Audio: memo.wav (2.0 KB)]")
+}
+
 func TestChatViewUserMessageWithMultipleAttachments(t *testing.T) {
 	cv := newTestChatView()
 	cv, _ = cv.Update(msgs.ChatViewCommitUserMsg{
@@ -1017,3 +1031,57 @@ func TestChatViewFlushAll(t *testing.T) {
 	// Summaries should be in committed buffer.
 	assert.NotEmpty(t, cv.committed)
 }
+
+func TestChatViewToolResultSelection(t *testing.T) {
+	cv := newTestChatView()
+
+	lines := make([]string, 20)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("output line %d", i+1)
+	}
+	for i, result := range []string{strings.Join(lines, "\n"), "ok"} {
+		id := fmt.Sprintf("tc-%d", i)
+		cv, _ = cv.Update(msgs.ChatMessageMsg{Msg: message.New("assistant", role.Assistant,
+			content.ToolCall{ID: id, Name: "exec_run", Arguments: `{"command":"make"}`},
+		)})
+		cv, _ = cv.Update(msgs.ChatMessageMsg{Msg: message.New("assistant", role.Tool,
+			content.ToolResult{ToolCallID: id, Content: result},
+		)})
+	}
+
+	// Long results are collapsed.
+	view := cv.View()
+	assert.Contains(t, view, "output line 8")
+	assert.NotContains(t, view, "output line 9")
+	assert.Contains(t, view, "12 more lines")
+
+	// Ctrl+O selects the latest result; Up moves to the long one and Enter expands it.
+	assert.True(t, cv.ToggleSelect())
+	assert.True(t, cv.Selecting())
+	calls := cv.selectableCalls()
+	assert.True(t, calls[1].Selected)
+	assert.True(t, cv.HandleSelectKey(tea.KeyPressMsg{Code: tea.KeyUp}))
+	assert.True(t, calls[0].Selected)
+	assert.False(t, calls[1].Selected)
+	assert.True(t, cv.HandleSelectKey(tea.KeyPressMsg{Code: tea.KeyEnter}))
+	assert.True(t, calls[0].Expanded)
+	assert.Contains(t, cv.viewport.GetContent(), "output line 20")
+	assert.Contains(t, cv.viewport.GetContent(), selectMarker)
+
+	// Esc leaves selection; the result stays expanded.
+	assert.True(t, cv.HandleSelectKey(tea.KeyPressMsg{Code: tea.KeyEsc}))
+	assert.False(t, cv.Selecting())
+	assert.True(t, calls[0].Expanded)
+	assert.NotContains(t, cv.viewport.GetContent(), selectMarker)
+
+	// Other keys leave selection unhandled.
+	cv.ToggleSelect()
+	assert.False(t, cv.HandleSelectKey(tea.KeyPressMsg{Code: 'x', Text: "x"}))
+	assert.False(t, cv.Selecting())
+
+	// Selection ends when the agent finishes.
+	cv.ToggleSelect()
+	cv, _ = cv.Update(msgs.AgentEndMsg{Agent: "assistant"})
+	assert.False(t, cv.Selecting())
+	assert.False(t, cv.ToggleSelect(), "nothing to select")
+}
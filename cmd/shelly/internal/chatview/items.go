@@ -9,6 +9,7 @@ import (
 
 	"github.com/germanamz/shelly/cmd/shelly/internal/format"
 	"github.com/germanamz/shelly/cmd/shelly/internal/styles"
+	"github.com/germanamz/shelly/cmd/shelly/internal/toolrender"
 )
 
 // DisplayItem is the interface for all renderable message types.
@@ -67,6 +68,8 @@ type ToolCallItem struct {
 	EndTime   time.Time // frozen when Completed is set
 	SpinMsg   string
 	FrameIdx  int
+	Expanded  bool // show the whole result instead of the first lines
+	Selected  bool // highlighted while selecting results to expand
 }
 
 func (m *ToolCallItem) View(width int) string {
@@ -88,6 +91,9 @@ func (m *ToolCallItem) View(width int) string {
 			elapsed = format.FmtDuration(end.Sub(m.StartTime))
 		}
 
+		if m.Selected {
+			sb.WriteString(styles.AskSelStyle.Render(selectMarker) + " ")
+		}
 		fmt.Fprintf(&sb, "🔧 %s", styles.ToolNameStyle.Render(title))
 		if detail != "" {
 			detailWidth := max(contentWidth-4, 20)
@@ -100,19 +106,18 @@ func (m *ToolCallItem) View(width int) string {
 				}
 			}
 		}
-		if m.Result != "" {
-			resultTxt := format.Truncate(m.Result, 200)
+		suffix := ""
+		if elapsed != "" {
+			suffix = fmt.Sprintf(" in %s", elapsed)
+		}
+		switch {
+		case m.Result == "":
+		case m.IsError:
 			resultWidth := max(contentWidth-len(styles.TreeCorner)-1, 20)
-			suffix := ""
-			if elapsed != "" {
-				suffix = fmt.Sprintf(" in %s", elapsed)
-			}
-			resultLine := format.Truncate(resultTxt+suffix, resultWidth)
-			if m.IsError {
-				fmt.Fprintf(&sb, "\n %s", styles.ToolErrorStyle.Render(styles.TreeCorner+resultLine))
-			} else {
-				fmt.Fprintf(&sb, "\n %s", styles.ToolResultStyle.Render(styles.TreeCorner+resultLine))
-			}
+			resultLine := format.Truncate(format.Truncate(m.Result, 200)+suffix, resultWidth)
+			fmt.Fprintf(&sb, "\n %s", styles.ToolErrorStyle.Render(styles.TreeCorner+resultLine))
+		default:
+			sb.WriteString(m.renderResult(contentWidth, suffix))
 		}
 	} else {
 		elapsed := ""
@@ -141,6 +146,27 @@ func (m *ToolCallItem) View(width int) string {
 	return sb.String()
 }
 
+// renderResult renders a successful result through its tool renderer, under
+// the tree corner and collapsed unless Expanded.
+func (m *ToolCallItem) renderResult(contentWidth int, suffix string) string {
+	rendered, hidden := toolrender.Render(m.ToolName, m.Args, m.Result, max(contentWidth-3, 20), m.Expanded)
+	lines := strings.Split(rendered, "\n")
+	if hidden > 0 {
+		lines = append(lines, styles.DimStyle.Render(fmt.Sprintf("… %d more lines (ctrl+o to expand)", hidden)))
+	}
+	lines[len(lines)-1] += styles.DimStyle.Render(suffix)
+
+	var sb strings.Builder
+	for i, line := range lines {
+		if i == 0 {
+			fmt.Fprintf(&sb, "\n %s%s", styles.ToolResultStyle.Render(styles.TreeCorner), line)
+		} else {
+			fmt.Fprintf(&sb, "\n   %s", line)
+		}
+	}
+	return sb.String()
+}
+
 func (m *ToolCallItem) IsLive() bool { return !m.Completed }
 func (m *ToolCallItem) Kind() string { return "tool_call" }
 
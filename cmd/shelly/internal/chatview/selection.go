@@ -0,0 +1,121 @@
+package chatview
+
+import (
+	"slices"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+	lipgloss "charm.land/lipgloss/v2"
+
+	"github.com/germanamz/shelly/cmd/shelly/internal/keymap"
+)
+
+// selectMarker prefixes the header of the selected tool call.
+const selectMarker = "▸"
+
+// Selecting reports whether the user is selecting tool results to expand.
+func (m ChatViewModel) Selecting() bool { return m.selected != nil }
+
+// ToggleSelect enters result selection on the most recent tool result, or
+// leaves it. It returns false when there is no result to select.
+func (m *ChatViewModel) ToggleSelect() bool {
+	if m.selected != nil {
+		m.setSelected(nil)
+		m.rebuildContent()
+		return true
+	}
+	calls := m.selectableCalls()
+	if len(calls) == 0 {
+		return false
+	}
+	m.setSelected(calls[len(calls)-1])
+	m.rebuildContent()
+	return true
+}
+
+// HandleSelectKey processes keys while selecting: Up/Down move between tool
+// results, Enter/Space expand or collapse the selected one and Esc or Ctrl+O
+// leave selection. Any other key leaves selection and is not handled.
+func (m *ChatViewModel) HandleSelectKey(msg tea.KeyPressMsg) bool {
+	calls := m.selectableCalls()
+	i := slices.Index(calls, m.selected)
+	k := msg.Key()
+
+	switch {
+	case k.Code == tea.KeyUp || (k.Code == 'k' && k.Mod == 0):
+		if i > 0 {
+			m.setSelected(calls[i-1])
+		}
+	case k.Code == tea.KeyDown || (k.Code == 'j' && k.Mod == 0):
+		if i >= 0 && i < len(calls)-1 {
+			m.setSelected(calls[i+1])
+		}
+	case k.Code == tea.KeyEnter || k.Code == tea.KeySpace:
+		m.selected.Expanded = !m.selected.Expanded
+	case k.Code == tea.KeyEsc || keymap.Matches(msg, keymap.SelectResults):
+		m.setSelected(nil)
+	default:
+		m.setSelected(nil)
+		m.rebuildContent()
+		return false
+	}
+	m.rebuildContent()
+	return true
+}
+
+func (m *ChatViewModel) setSelected(tc *ToolCallItem) {
+	if m.selected != nil {
+		m.selected.Selected = false
+	}
+	m.selected = tc
+	if tc != nil {
+		tc.Selected = true
+	}
+}
+
+// selectableCalls returns the completed tool calls with a result in the live
+// view, in display order.
+func (m *ChatViewModel) selectableCalls() []*ToolCallItem {
+	var containers []*AgentContainer
+	if m.viewedAgent != "" {
+		if ac := m.viewedAgentContainer(); ac != nil {
+			containers = append(containers, ac)
+		}
+	} else {
+		for _, name := range m.agentOrder {
+			if ac, ok := m.agents[name]; ok {
+				containers = append(containers, ac)
+			}
+		}
+	}
+
+	var calls []*ToolCallItem
+	for _, ac := range containers {
+		if ac.Done {
+			continue
+		}
+		for _, item := range ac.Items {
+			if tc, ok := item.(*ToolCallItem); ok && tc.Completed && !tc.IsError && tc.Result != "" {
+				calls = append(calls, tc)
+			}
+		}
+	}
+	return calls
+}
+
+// scrollToSelected scrolls the viewport so that the selected tool call's
+// header is visible. content is the viewport content.
+func (m *ChatViewModel) scrollToSelected(content string) {
+	width := max(m.viewport.Width(), 1)
+	row := 0
+	for line := range strings.SplitSeq(content, "\n") {
+		if strings.Contains(line, selectMarker) && strings.Contains(line, "🔧") {
+			if row < m.viewport.YOffset() || row >= m.viewport.YOffset()+m.viewport.Height() {
+				m.viewport.SetYOffset(row)
+			}
+			return
+		}
+		// Soft-wrapped lines take several rows.
+		row += max(1, (lipgloss.Width(line)+width-1)/width)
+	}
+}
@@ -56,6 +56,9 @@ func (f *TextField) SetValue(v string) { f.input.SetValue(v) }
 func (f *TextField) Focus() tea.Cmd    { return f.input.Focus() }
 func (f *TextField) Blur()             { f.input.Blur() }
 
+// SetPlaceholder replaces the hint shown while the field is empty.
+func (f *TextField) SetPlaceholder(v string) { f.input.Placeholder = v }
+
 func (f *TextField) Validate() error {
 	if f.required && strings.TrimSpace(f.input.Value()) == "" {
 		return fmt.Errorf("%s is required", f.label)
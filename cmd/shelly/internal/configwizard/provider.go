@@ -1,13 +1,16 @@
 package configwizard
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	tea "charm.land/bubbletea/v2"
 	"github.com/germanamz/shelly/cmd/shelly/internal/styles"
 	"github.com/germanamz/shelly/pkg/engine"
+	"github.com/germanamz/shelly/pkg/providers/ollama"
 )
 
 // -----------------------------------------------------------------------
@@ -129,6 +132,42 @@ type providerFormScreen struct {
 	form     *FormModel
 	isNew    bool
 	kinds    []string
+
+	// Local model discovery for the ollama kind.
+	discoveredURL string   // base URL of the last lookup, to avoid repeats
+	localModels   []string // models installed on that server
+	discoverErr   error
+}
+
+// localModelsMsg carries the models listed by a local Ollama server.
+type localModelsMsg struct {
+	baseURL string
+	models  []string
+	err     error
+}
+
+// localModelsTimeout bounds the /api/tags lookup so an absent server does
+// not stall the wizard.
+const localModelsTimeout = 2 * time.Second
+
+// discoverLocalModels lists the models installed on the Ollama server at
+// baseURL.
+func discoverLocalModels(baseURL string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), localModelsTimeout)
+		defer cancel()
+
+		url := baseURL
+		if url == "" {
+			url = ollama.DefaultBaseURL
+		}
+		models, err := ollama.New(url, "", "").ListModels(ctx)
+		names := make([]string, len(models))
+		for i, m := range models {
+			names[i] = m.Name
+		}
+		return localModelsMsg{baseURL: baseURL, models: names, err: err}
+	}
 }
 
 func newProviderFormScreen(p *engine.ProviderConfig, kinds []string, isNew bool) *providerFormScreen {
@@ -141,6 +180,10 @@ func newProviderFormScreen(p *engine.ProviderConfig, kinds []string, isNew bool)
 	maxTokensField := NewIntField("Max Tokens", "empty=provider default", false)
 	maxRetriesField := NewIntField("Rate Limit Retries", "e.g. 3", false)
 	baseDelayField := NewTextField("Rate Limit Delay", "e.g. 1s", false)
+	numCtxField := NewIntField("Ollama num_ctx", "ollama only; empty=discover", false)
+	keepAliveField := NewTextField("Ollama keep_alive", "ollama only; e.g. 10m, -1", false)
+	regionField := NewTextField("Cloud Region", "bedrock/vertex only; e.g. us-east-1", false)
+	projectField := NewTextField("Vertex Project", "vertex only; empty=from credentials", false)
 
 	// Pre-fill from existing provider.
 	if p.Kind != "" {
@@ -170,6 +213,21 @@ func newProviderFormScreen(p *engine.ProviderConfig, kinds []string, isNew bool)
 	if p.RateLimit.BaseDelay != "" {
 		baseDelayField.SetValue(p.RateLimit.BaseDelay)
 	}
+	if p.Ollama.NumCtx > 0 {
+		numCtxField.SetValue(strconv.Itoa(p.Ollama.NumCtx))
+	}
+	if p.Ollama.KeepAlive != "" {
+		keepAliveField.SetValue(p.Ollama.KeepAlive)
+	}
+	switch {
+	case p.Bedrock.Region != "":
+		regionField.SetValue(p.Bedrock.Region)
+	case p.Vertex.Region != "":
+		regionField.SetValue(p.Vertex.Region)
+	}
+	if p.Vertex.Project != "" {
+		projectField.SetValue(p.Vertex.Project)
+	}
 
 	title := "Edit Provider"
 	if isNew {
@@ -179,26 +237,59 @@ func newProviderFormScreen(p *engine.ProviderConfig, kinds []string, isNew bool)
 	form := NewFormModel(title, []FormField{
 		kindField, nameField, apiKeyField, modelField,
 		baseURLField, ctxWindowField, maxTokensField, maxRetriesField, baseDelayField,
+		numCtxField, keepAliveField, regionField, projectField,
 	})
 
 	return &providerFormScreen{provider: p, form: form, isNew: isNew, kinds: kinds}
 }
 
 func (s *providerFormScreen) init() tea.Cmd {
-	return s.form.Init()
+	return tea.Batch(s.form.Init(), s.maybeDiscover())
 }
 
 func (s *providerFormScreen) Update(msg tea.Msg) (screen, tea.Cmd) {
-	switch msg.(type) {
+	switch msg := msg.(type) {
 	case formSubmitMsg:
 		s.applyToProvider()
 		return nil, nil
 	case formCancelMsg:
 		return nil, nil
+	case localModelsMsg:
+		s.applyLocalModels(msg)
+		return s, nil
 	}
 
 	_, cmd := s.form.Update(msg)
-	return s, cmd
+	return s, tea.Batch(cmd, s.maybeDiscover())
+}
+
+// maybeDiscover starts a local model lookup when the kind is ollama and the
+// base URL changed since the last lookup.
+func (s *providerFormScreen) maybeDiscover() tea.Cmd {
+	if s.form.Fields[0].Value() != "ollama" {
+		return nil
+	}
+	baseURL := s.form.Fields[4].Value()
+	if s.localModels != nil && baseURL == s.discoveredURL {
+		return nil
+	}
+	s.discoveredURL = baseURL
+	s.localModels = []string{} // lookup in flight
+	return discoverLocalModels(baseURL)
+}
+
+// applyLocalModels shows discovered models as the model field hint.
+func (s *providerFormScreen) applyLocalModels(msg localModelsMsg) {
+	if msg.baseURL != s.discoveredURL {
+		return // stale lookup
+	}
+	s.localModels, s.discoverErr = msg.models, msg.err
+	if len(msg.models) == 0 {
+		return
+	}
+	if f, ok := s.form.Fields[3].(*TextField); ok {
+		f.SetPlaceholder("e.g. " + msg.models[0])
+	}
 }
 
 func (s *providerFormScreen) applyToProvider() {
@@ -235,8 +326,38 @@ func (s *providerFormScreen) applyToProvider() {
 		}
 	}
 	s.provider.RateLimit.BaseDelay = s.form.Fields[8].Value()
+
+	if f, ok := s.form.Fields[9].(*IntField); ok {
+		v, _ := f.IntValue()
+		s.provider.Ollama.NumCtx = v
+	}
+	s.provider.Ollama.KeepAlive = s.form.Fields[10].Value()
+
+	// The region belongs to whichever cloud kind is selected.
+	s.provider.Bedrock.Region, s.provider.Vertex.Region = "", ""
+	switch s.provider.Kind {
+	case "bedrock":
+		s.provider.Bedrock.Region = s.form.Fields[11].Value()
+	case "vertex":
+		s.provider.Vertex.Region = s.form.Fields[11].Value()
+	}
+	s.provider.Vertex.Project = s.form.Fields[12].Value()
 }
 
 func (s *providerFormScreen) View() string {
-	return s.form.View().Content
+	view := s.form.View().Content
+	if s.form.Fields[0].Value() != "ollama" || s.localModels == nil {
+		return view
+	}
+
+	var hint string
+	switch {
+	case s.discoverErr != nil:
+		hint = "Local models: server not reachable"
+	case len(s.localModels) == 0:
+		hint = "Local models: discovering..."
+	default:
+		hint = "Local models: " + strings.Join(s.localModels, ", ")
+	}
+	return view + "\n\n" + styles.DimStyle.Render(hint)
 }
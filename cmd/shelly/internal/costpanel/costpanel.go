@@ -0,0 +1,226 @@
+package costpanel
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/germanamz/shelly/cmd/shelly/internal/format"
+	"github.com/germanamz/shelly/cmd/shelly/internal/panel"
+	"github.com/germanamz/shelly/cmd/shelly/internal/styles"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/sessions"
+)
+
+// PanelID identifies the cost panel in message routing.
+const PanelID = "cost"
+
+// nameWidth is the width of the agent and provider name column.
+const nameWidth = 40
+
+// CostPanelModel displays the session's token usage and cost per agent
+// instance in the delegation tree, per provider and in total, with each
+// provider's rate limit headroom. Content scrolls when it exceeds the panel.
+type CostPanelModel struct {
+	panel  panel.Model
+	usage  []sessions.AgentUsage
+	limits map[string]modeladapter.RateLimitInfo
+	offset int
+}
+
+// New creates a new CostPanelModel.
+func New() CostPanelModel {
+	return CostPanelModel{panel: panel.New(PanelID, "Cost")}
+}
+
+// Active returns whether the panel is open.
+func (m CostPanelModel) Active() bool { return m.panel.Active() }
+
+// SetActive opens or closes the panel.
+func (m *CostPanelModel) SetActive(active bool) {
+	m.panel.SetActive(active)
+	m.offset = 0
+}
+
+// SetSize updates the panel dimensions.
+func (m *CostPanelModel) SetSize(width, height int) {
+	m.panel.SetSize(width, height)
+	m.clampOffset()
+}
+
+// Height returns the panel's total height (including borders).
+// Returns 0 when inactive.
+func (m CostPanelModel) Height() int {
+	if !m.panel.Active() {
+		return 0
+	}
+	return m.panel.Height()
+}
+
+// SetUsage updates the usage entries (see engine.Session.Usage) and the rate
+// limits per provider name (see engine.Engine.RateLimits).
+func (m *CostPanelModel) SetUsage(u []sessions.AgentUsage, limits map[string]modeladapter.RateLimitInfo) {
+	m.usage = u
+	m.limits = limits
+	m.clampOffset()
+}
+
+// LineCount returns the number of content lines.
+func (m CostPanelModel) LineCount() int { return len(m.lines()) }
+
+// MoveUp scrolls the content up.
+func (m *CostPanelModel) MoveUp() {
+	if m.offset > 0 {
+		m.offset--
+	}
+}
+
+// MoveDown scrolls the content down.
+func (m *CostPanelModel) MoveDown() {
+	m.offset++
+	m.clampOffset()
+}
+
+// View renders the panel.
+func (m CostPanelModel) View() string {
+	if len(m.usage) == 0 {
+		return m.panel.View(styles.DimStyle.Render("No usage recorded yet."))
+	}
+	return m.panel.View(strings.Join(m.lines()[m.offset:], "\n"))
+}
+
+func (m *CostPanelModel) clampOffset() {
+	maxOffset := max(m.LineCount()-m.panel.ContentHeight(), 0)
+	m.offset = min(m.offset, maxOffset)
+}
+
+// lines renders the session total, the agent tree and the providers.
+func (m CostPanelModel) lines() []string {
+	if len(m.usage) == 0 {
+		return nil
+	}
+
+	var total sessions.AgentUsage
+	for _, u := range m.usage {
+		total = add(total, u)
+	}
+
+	lines := []string{
+		row(styles.ToolNameStyle.Render(pad("Session total", nameWidth)), total),
+		"",
+		styles.ToolNameStyle.Render("Agents"),
+	}
+	lines = append(lines, m.agentLines()...)
+
+	lines = append(lines, "", styles.ToolNameStyle.Render("Providers"))
+	for _, p := range byProvider(m.usage) {
+		label := p.Provider.Name
+		if p.Provider.Kind != "" {
+			label += " (" + p.Provider.Kind + "/" + p.Provider.Model + ")"
+		}
+		lines = append(lines, row("  "+pad(label, nameWidth-2), p))
+		if info, ok := m.limits[p.Provider.Name]; ok {
+			lines = append(lines, styles.DimStyle.Render("    rate limit: "+headroom(info, time.Now())))
+		}
+	}
+	return lines
+}
+
+// agentLines renders the agent instances as a tree. Instances whose parent
+// has no usage are shown at the top level.
+func (m CostPanelModel) agentLines() []string {
+	known := make(map[string]bool, len(m.usage))
+	for _, u := range m.usage {
+		known[u.Agent] = true
+	}
+
+	var lines []string
+	var walk func(parent string, depth int)
+	walk = func(parent string, depth int) {
+		seen := map[string]bool{}
+		for _, u := range m.usage {
+			isRoot := u.Parent == "" || !known[u.Parent]
+			if (depth == 0 && !isRoot) || (depth > 0 && u.Parent != parent) {
+				continue
+			}
+			indent := strings.Repeat("  ", depth+1)
+			lines = append(lines, row(indent+pad(u.Agent, nameWidth-len(indent)), u))
+			if !seen[u.Agent] {
+				seen[u.Agent] = true
+				walk(u.Agent, depth+1)
+			}
+		}
+	}
+	walk("", 0)
+	return lines
+}
+
+// row renders one usage line after its label.
+func row(label string, u sessions.AgentUsage) string {
+	cols := []string{
+		fmt.Sprintf("%7s in", format.FmtTokens(u.InputTokens)),
+		fmt.Sprintf("%7s out", format.FmtTokens(u.OutputTokens)),
+	}
+	if cached := u.CacheReadTokens + u.CacheCreationTokens; cached > 0 {
+		cols = append(cols, fmt.Sprintf("cache %s read / %s write (%.0f%% hit)",
+			format.FmtTokens(u.CacheReadTokens), format.FmtTokens(u.CacheCreationTokens), hitRatio(u)*100))
+	}
+	cost := "n/a"
+	if u.CostUSD > 0 {
+		cost = format.FmtCost(u.CostUSD)
+	}
+	return label + "  " + fmt.Sprintf("%9s", cost) + "  " + styles.DimStyle.Render(strings.Join(cols, "  "))
+}
+
+// byProvider sums the usage per provider in first-use order.
+func byProvider(entries []sessions.AgentUsage) []sessions.AgentUsage {
+	var out []sessions.AgentUsage
+	for _, u := range entries {
+		i := slices.IndexFunc(out, func(p sessions.AgentUsage) bool { return p.Provider.Name == u.Provider.Name })
+		if i < 0 {
+			out = append(out, sessions.AgentUsage{Provider: u.Provider})
+			i = len(out) - 1
+		}
+		out[i] = add(out[i], u)
+	}
+	return out
+}
+
+// add returns a with the counters of b added.
+func add(a, b sessions.AgentUsage) sessions.AgentUsage {
+	a.Calls += b.Calls
+	a.InputTokens += b.InputTokens
+	a.OutputTokens += b.OutputTokens
+	a.CacheCreationTokens += b.CacheCreationTokens
+	a.CacheReadTokens += b.CacheReadTokens
+	a.CostUSD += b.CostUSD
+	return a
+}
+
+// hitRatio returns the share of input tokens read from the cache.
+func hitRatio(u sessions.AgentUsage) float64 {
+	total := u.InputTokens + u.CacheCreationTokens + u.CacheReadTokens
+	if total == 0 {
+		return 0
+	}
+	return float64(u.CacheReadTokens) / float64(total)
+}
+
+// headroom describes the remaining rate limit capacity.
+func headroom(info modeladapter.RateLimitInfo, now time.Time) string {
+	s := fmt.Sprintf("%d requests, %s tokens left", info.RemainingRequests, format.FmtTokens(info.RemainingTokens))
+	if reset := info.TokensReset; reset.After(now) {
+		s += ", tokens reset in " + format.FmtDuration(reset.Sub(now))
+	}
+	return s
+}
+
+// pad truncates or right-pads s to width runes.
+func pad(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width {
+		return string(r[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-len(r))
+}
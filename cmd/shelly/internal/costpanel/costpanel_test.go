@@ -0,0 +1,93 @@
+package costpanel
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	haiku  = sessions.ProviderMeta{Name: "cheap", Kind: "anthropic", Model: "claude-haiku"}
+	sonnet = sessions.ProviderMeta{Name: "smart", Kind: "anthropic", Model: "claude-sonnet"}
+)
+
+func sampleUsage() []sessions.AgentUsage {
+	return []sessions.AgentUsage{
+		{Agent: "coder", Provider: sonnet, Calls: 3, InputTokens: 1000, OutputTokens: 200, CacheReadTokens: 3000, CostUSD: 0.5},
+		{Agent: "coder-fix-1", Parent: "coder", Provider: haiku, Calls: 2, InputTokens: 500, OutputTokens: 100, CostUSD: 0.25},
+		{Agent: "reviewer-2", Parent: "coder-fix-1", Provider: haiku, Calls: 1, InputTokens: 100, OutputTokens: 10},
+	}
+}
+
+func plain(m CostPanelModel) []string {
+	lines := m.lines()
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(ansi.Strip(l), " ")
+	}
+	return lines
+}
+
+func TestCostPanelLines(t *testing.T) {
+	m := New()
+	m.SetUsage(sampleUsage(), map[string]modeladapter.RateLimitInfo{"cheap": {RemainingRequests: 42, RemainingTokens: 9000}})
+	lines := plain(m)
+
+	require.NotEmpty(t, lines)
+	assert.Contains(t, lines[0], "Session total")
+	assert.Contains(t, lines[0], "$0.750")
+	assert.Contains(t, lines[0], "1.6k in")
+	assert.Contains(t, lines[0], "(65% hit)")
+
+	all := strings.Join(lines, "\n")
+	assert.Contains(t, all, "\n  coder ")
+	assert.Contains(t, all, "\n    coder-fix-1 ", "children are indented under their parent")
+	assert.Contains(t, all, "\n      reviewer-2 ")
+	assert.Contains(t, all, "n/a", "usage without pricing has no cost")
+
+	assert.Contains(t, all, "smart (anthropic/claude-sonnet)")
+	assert.Contains(t, all, "cheap (anthropic/claude-haiku)")
+	assert.Contains(t, all, "rate limit: 42 requests, 9.0k tokens left")
+}
+
+func TestCostPanelOrphanIsRoot(t *testing.T) {
+	m := New()
+	m.SetUsage([]sessions.AgentUsage{{Agent: "helper-1", Parent: "gone", Provider: haiku, Calls: 1}}, nil)
+	assert.Contains(t, strings.Join(plain(m), "\n"), "\n  helper-1 ")
+}
+
+func TestCostPanelScroll(t *testing.T) {
+	m := New()
+	m.SetActive(true)
+	m.SetUsage(sampleUsage(), nil)
+	m.SetSize(120, 5) // 3 content rows
+
+	m.MoveUp()
+	assert.Equal(t, 0, m.offset)
+	for range 20 {
+		m.MoveDown()
+	}
+	assert.Equal(t, m.LineCount()-3, m.offset, "scrolling stops at the last line")
+
+	m.SetActive(false)
+	assert.Equal(t, 0, m.offset)
+	assert.Equal(t, 0, m.Height())
+}
+
+func TestCostPanelEmpty(t *testing.T) {
+	m := New()
+	m.SetActive(true)
+	m.SetSize(80, 3)
+	assert.Contains(t, ansi.Strip(m.View()), "No usage recorded yet.")
+}
+
+func TestHeadroom(t *testing.T) {
+	now := time.Now()
+	info := modeladapter.RateLimitInfo{RemainingRequests: 5, RemainingTokens: 1200, TokensReset: now.Add(30 * time.Second)}
+	assert.Equal(t, "5 requests, 1.2k tokens left, tokens reset in 30.0s", headroom(info, now))
+}
@@ -0,0 +1,334 @@
+// Package diffview implements the review prompt for file changes proposed by
+// agents: a unified diff with syntax colouring where each hunk can be
+// accepted, rejected or rewritten before the change lands.
+package diffview
+
+import (
+	"fmt"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+	lipgloss "charm.land/lipgloss/v2"
+	"github.com/germanamz/shelly/cmd/shelly/internal/basetextarea"
+	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
+	"github.com/germanamz/shelly/cmd/shelly/internal/styles"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/filesystem"
+	"github.com/mattn/go-runewidth"
+)
+
+// mode is what the keyboard currently drives.
+type mode int
+
+const (
+	modeBrowse  mode = iota
+	modeComment      // typing the comment for the model
+	modeEdit         // rewriting the current hunk
+)
+
+// chromeLines is the number of lines around the diff body: borders, title,
+// blank line, comment and hints.
+const chromeLines = 6
+
+// Model reviews one file change. Submitting it emits a
+// msgs.FileReviewDecidedMsg.
+type Model struct {
+	req       filesystem.ReviewRequest
+	agent     string
+	width     int
+	height    int
+	hunk      int
+	decisions []filesystem.HunkDecision
+	comment   string
+	mode      mode
+	ta        basetextarea.Model
+	offset    int
+	hl        highlighter
+}
+
+// New creates a review prompt for req proposed by agent. width and height are
+// the maximum size of the rendered prompt.
+func New(req filesystem.ReviewRequest, agent string, width, height int) Model {
+	decisions := make([]filesystem.HunkDecision, len(req.Change.Hunks))
+	for i := range decisions {
+		decisions[i].Verdict = filesystem.HunkAccept
+	}
+
+	return Model{
+		req:       req,
+		agent:     agent,
+		width:     width,
+		height:    height,
+		decisions: decisions,
+		hl:        newHighlighter(req.Change.Path),
+	}
+}
+
+// ID returns the ID of the review request.
+func (m Model) ID() string { return m.req.ID }
+
+// Change returns the change under review.
+func (m Model) Change() filesystem.Change { return m.req.Change }
+
+// SetSize updates the maximum size of the rendered prompt.
+func (m *Model) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+	m.clampOffset()
+}
+
+// Height returns the rendered height of the prompt.
+func (m Model) Height() int { return lipgloss.Height(m.View()) }
+
+// Decision returns the decision as currently set.
+func (m Model) Decision() filesystem.Decision {
+	return filesystem.Decision{Hunks: m.decisions, Comment: m.comment}
+}
+
+// Update handles key presses.
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyPressMsg)
+	if !ok {
+		if m.mode != modeBrowse {
+			var cmd tea.Cmd
+			m.ta, cmd = m.ta.Update(msg)
+			return m, cmd
+		}
+		return m, nil
+	}
+
+	switch m.mode {
+	case modeComment:
+		return m.updateComment(keyMsg)
+	case modeEdit:
+		return m.updateEdit(keyMsg)
+	}
+	return m.updateBrowse(keyMsg)
+}
+
+func (m Model) updateBrowse(msg tea.KeyPressMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		m.offset = max(m.offset-1, 0)
+	case "down", "j":
+		m.offset++
+		m.clampOffset()
+	case "tab", "n":
+		m.goToHunk(m.hunk + 1)
+	case "shift+tab", "p":
+		m.goToHunk(m.hunk - 1)
+	case "a":
+		m.setVerdict(filesystem.HunkAccept)
+	case "r":
+		m.setVerdict(filesystem.HunkReject)
+	case "A", "R":
+		verdict := filesystem.HunkAccept
+		if msg.String() == "R" {
+			verdict = filesystem.HunkReject
+		}
+		for i := range m.decisions {
+			m.decisions[i] = filesystem.HunkDecision{Verdict: verdict}
+		}
+	case "e":
+		if len(m.decisions) == 0 {
+			return m, nil
+		}
+		text := m.req.Change.Hunks[m.hunk].NewText()
+		if d := m.decisions[m.hunk]; d.Verdict == filesystem.HunkEdit {
+			text = d.Text
+		}
+		return m.openTextarea(modeEdit, "Hunk content...", strings.TrimSuffix(text, "\n"))
+	case "c":
+		return m.openTextarea(modeComment, "Comment for the agent...", m.comment)
+	case "enter":
+		return m, m.submit()
+	case "esc":
+		for i := range m.decisions {
+			m.decisions[i] = filesystem.HunkDecision{Verdict: filesystem.HunkReject}
+		}
+		return m, m.submit()
+	}
+	return m, nil
+}
+
+func (m Model) updateComment(msg tea.KeyPressMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.mode = modeBrowse
+		return m, nil
+	case "enter":
+		m.comment = strings.TrimSpace(m.ta.Value())
+		m.mode = modeBrowse
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.ta, cmd = m.ta.Update(msg)
+	return m, cmd
+}
+
+func (m Model) updateEdit(msg tea.KeyPressMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.mode = modeBrowse
+		return m, nil
+	case "ctrl+s":
+		text := m.ta.Value()
+		if text != "" {
+			text += "\n"
+		}
+		m.decisions[m.hunk] = filesystem.HunkDecision{Verdict: filesystem.HunkEdit, Text: text}
+		m.mode = modeBrowse
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.ta, cmd = m.ta.Update(msg)
+	return m, cmd
+}
+
+// openTextarea switches to a text entry mode with value prefilled.
+func (m Model) openTextarea(md mode, placeholder, value string) (Model, tea.Cmd) {
+	maxHeight := 3
+	if md == modeEdit {
+		maxHeight = max(m.bodyHeight(), 3)
+	}
+	m.ta = basetextarea.New(placeholder, 1, maxHeight)
+	m.ta.SetWidth(m.innerWidth())
+	m.ta.SetValue(value)
+	m.mode = md
+	return m, m.ta.Focus()
+}
+
+// setVerdict sets the current hunk's verdict and moves to the next hunk.
+func (m *Model) setVerdict(verdict string) {
+	if len(m.decisions) == 0 {
+		return
+	}
+	m.decisions[m.hunk] = filesystem.HunkDecision{Verdict: verdict}
+	m.goToHunk(m.hunk + 1)
+}
+
+// goToHunk selects hunk i, clamped, and scrolls to its header.
+func (m *Model) goToHunk(i int) {
+	m.hunk = min(max(i, 0), max(len(m.decisions)-1, 0))
+	_, starts := m.body()
+	if m.hunk < len(starts) {
+		m.offset = starts[m.hunk]
+		m.clampOffset()
+	}
+}
+
+func (m Model) submit() tea.Cmd {
+	msg := msgs.FileReviewDecidedMsg{ID: m.req.ID, Decision: m.Decision()}
+	return func() tea.Msg { return msg }
+}
+
+func (m Model) innerWidth() int { return max(m.width-4, 10) }
+
+// bodyHeight is the number of diff lines shown at once.
+func (m Model) bodyHeight() int { return max(m.height-chromeLines, 3) }
+
+func (m *Model) clampOffset() {
+	lines, _ := m.body()
+	m.offset = min(m.offset, max(len(lines)-m.bodyHeight(), 0))
+}
+
+// View renders the prompt.
+func (m Model) View() string {
+	c := m.req.Change
+	innerWidth := m.innerWidth()
+
+	var sb strings.Builder
+	title := "📝 " + c.Tool + " " + c.Path
+	if m.agent != "" {
+		title += " (" + m.agent + ")"
+	}
+	sb.WriteString(styles.AskTitleStyle.Render(runewidth.Truncate(title, innerWidth, "…")))
+	sb.WriteString("\n\n")
+
+	if m.mode == modeEdit {
+		sb.WriteString(styles.DimStyle.Render(fmt.Sprintf("Rewrite hunk %d of %d:", m.hunk+1, len(c.Hunks))))
+		sb.WriteString("\n")
+		sb.WriteString(m.ta.View())
+	} else {
+		lines, _ := m.body()
+		end := min(m.offset+m.bodyHeight(), len(lines))
+		sb.WriteString(strings.Join(lines[m.offset:end], "\n"))
+	}
+
+	sb.WriteString("\n")
+	switch {
+	case m.mode == modeComment:
+		sb.WriteString(m.ta.View())
+	case m.comment != "":
+		sb.WriteString(styles.DimStyle.Render(runewidth.Truncate("Comment: "+m.comment, innerWidth, "…")))
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString(styles.AskHintStyle.Render(m.hints()))
+
+	return styles.AskBorder.Width(innerWidth).Render(sb.String())
+}
+
+func (m Model) hints() string {
+	switch m.mode {
+	case modeComment:
+		return "↵ Save comment, Esc Cancel"
+	case modeEdit:
+		return "Ctrl+S Save hunk, Esc Cancel"
+	}
+	return "a/r Accept/reject hunk, A/R All, e Edit, c Comment, Tab Next hunk, ↵ Submit, Esc Reject all"
+}
+
+// body renders the hunks and returns the lines with the index of each hunk's
+// header line.
+func (m Model) body() ([]string, []int) {
+	width := m.innerWidth()
+	var lines []string
+	starts := make([]int, 0, len(m.req.Change.Hunks))
+
+	for i, h := range m.req.Change.Hunks {
+		starts = append(starts, len(lines))
+		d := m.decisions[i]
+
+		header := h.Header() + " " + verdictLabel(d.Verdict)
+		if i == m.hunk {
+			lines = append(lines, styles.AskSelStyle.Render("▸ "+header))
+		} else {
+			lines = append(lines, styles.DimStyle.Render("  "+header))
+		}
+
+		if d.Verdict == filesystem.HunkEdit {
+			for _, text := range strings.Split(strings.TrimSuffix(d.Text, "\n"), "\n") {
+				lines = append(lines, m.hl.line("~", text, width))
+			}
+			continue
+		}
+		for _, l := range h.Lines {
+			if d.Verdict == filesystem.HunkReject && l.Op != " " {
+				lines = append(lines, styles.DimStyle.Render(clip(l.Op+" "+l.Text, width)))
+				continue
+			}
+			lines = append(lines, m.hl.line(l.Op, l.Text, width))
+		}
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, styles.DimStyle.Render("No changes."))
+	}
+	return lines, starts
+}
+
+func verdictLabel(verdict string) string {
+	switch verdict {
+	case filesystem.HunkReject:
+		return lipgloss.NewStyle().Foreground(styles.ColorError).Render("[rejected]")
+	case filesystem.HunkEdit:
+		return lipgloss.NewStyle().Foreground(styles.ColorWarning).Render("[edited]")
+	default:
+		return lipgloss.NewStyle().Foreground(styles.ColorSuccess).Render("[accepted]")
+	}
+}
+
+// clip expands tabs and truncates s to width cells.
+func clip(s string, width int) string {
+	return runewidth.Truncate(strings.ReplaceAll(s, "\t", "    "), width, "…")
+}
@@ -0,0 +1,135 @@
+package diffview
+
+import (
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/filesystem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const oldGo = "package main\n\nfunc a() {}\n\nfunc b() {}\n\nfunc c() {}\n\nfunc d() {}\n\nfunc e() {}\n"
+
+func newTestModel(t *testing.T) Model {
+	t.Helper()
+	newGo := strings.Replace(strings.Replace(oldGo, "func a() {}", "func a() int { return 1 }", 1), "func e() {}", "func e() {}\n\nfunc f() {}", 1)
+	c := filesystem.NewChange("fs_edit", "/src/main.go", oldGo, newGo)
+	require.Len(t, c.Hunks, 2)
+	return New(filesystem.ReviewRequest{ID: "r-1", Change: c}, "coder", 100, 30)
+}
+
+func press(m Model, keys ...string) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	for _, k := range keys {
+		var msg tea.KeyPressMsg
+		switch k {
+		case "enter":
+			msg = tea.KeyPressMsg{Code: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyPressMsg{Code: tea.KeyEscape}
+		case "ctrl+s":
+			msg = tea.KeyPressMsg{Code: 's', Mod: tea.ModCtrl}
+		default:
+			r := []rune(k)[0]
+			msg = tea.KeyPressMsg{Code: r, Text: k}
+			if r >= 'A' && r <= 'Z' {
+				msg.ShiftedCode = r
+				msg.Code = r + ('a' - 'A')
+				msg.Mod = tea.ModShift
+			}
+		}
+		m, cmd = m.Update(msg)
+	}
+	return m, cmd
+}
+
+func decided(t *testing.T, cmd tea.Cmd) msgs.FileReviewDecidedMsg {
+	t.Helper()
+	require.NotNil(t, cmd)
+	msg, ok := cmd().(msgs.FileReviewDecidedMsg)
+	require.True(t, ok)
+	return msg
+}
+
+func TestModel_View(t *testing.T) {
+	m := newTestModel(t)
+	view := ansi.Strip(m.View())
+
+	assert.Contains(t, view, "fs_edit /src/main.go (coder)")
+	assert.Contains(t, view, "▸ @@ -1,6 +1,6 @@ [accepted]")
+	assert.Contains(t, view, "- func a() {}")
+	assert.Contains(t, view, "+ func a() int { return 1 }")
+	assert.Equal(t, m.Height(), strings.Count(m.View(), "\n")+1)
+}
+
+func TestModel_AcceptReject(t *testing.T) {
+	m := newTestModel(t)
+
+	m, _ = press(m, "r")
+	assert.Equal(t, 1, m.hunk, "a verdict moves to the next hunk")
+	m, cmd := press(m, "a", "enter")
+
+	msg := decided(t, cmd)
+	assert.Equal(t, "r-1", msg.ID)
+	assert.Equal(t, []filesystem.HunkDecision{{Verdict: filesystem.HunkReject}, {Verdict: filesystem.HunkAccept}}, msg.Decision.Hunks)
+	assert.Contains(t, ansi.Strip(m.View()), "[rejected]")
+}
+
+func TestModel_AllAndEscape(t *testing.T) {
+	m := newTestModel(t)
+
+	m, _ = press(m, "R")
+	assert.Equal(t, filesystem.HunkReject, m.Decision().Hunks[1].Verdict)
+	m, _ = press(m, "A")
+	assert.Equal(t, filesystem.HunkAccept, m.Decision().Hunks[0].Verdict)
+
+	_, cmd := press(m, "esc")
+	for _, d := range decided(t, cmd).Decision.Hunks {
+		assert.Equal(t, filesystem.HunkReject, d.Verdict)
+	}
+}
+
+func TestModel_CommentAndEdit(t *testing.T) {
+	m := newTestModel(t)
+
+	m, _ = press(m, "c", "n", "o", "enter")
+	assert.Equal(t, "no", m.Decision().Comment)
+
+	m, _ = press(m, "e")
+	assert.Equal(t, modeEdit, m.mode)
+	m.ta.SetValue("package main")
+	m, _ = press(m, "ctrl+s")
+	assert.Equal(t, modeBrowse, m.mode)
+	assert.Equal(t, filesystem.HunkDecision{Verdict: filesystem.HunkEdit, Text: "package main\n"}, m.Decision().Hunks[0])
+	assert.Contains(t, ansi.Strip(m.View()), "[edited]")
+
+	// Esc leaves the edit without changing the verdict.
+	m, _ = press(m, "tab", "e", "esc")
+	assert.Equal(t, filesystem.HunkAccept, m.Decision().Hunks[1].Verdict)
+}
+
+func TestModel_Scroll(t *testing.T) {
+	m := newTestModel(t)
+	m.SetSize(100, chromeLines+3)
+
+	m, _ = press(m, "tab")
+	_, starts := m.body()
+	assert.Equal(t, starts[1], m.offset)
+	assert.Contains(t, ansi.Strip(m.View()), "▸ @@")
+
+	m, _ = press(m, "j", "j", "j", "j", "j", "j", "j", "j", "j", "j")
+	lines, _ := m.body()
+	assert.Equal(t, len(lines)-3, m.offset, "scrolling stops at the last line")
+}
+
+func TestHighlighter_Line(t *testing.T) {
+	h := newHighlighter("main.go")
+	line := h.line("+", "func a() {}", 20)
+
+	assert.Equal(t, "+ func a() {}       ", ansi.Strip(line))
+	assert.Contains(t, line, "\x1b[", "the line is coloured")
+}
@@ -0,0 +1,88 @@
+package diffview
+
+import (
+	"image/color"
+	"path/filepath"
+	"strings"
+
+	lipgloss "charm.land/lipgloss/v2"
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	chromastyles "github.com/alecthomas/chroma/v2/styles"
+	"github.com/germanamz/shelly/cmd/shelly/internal/styles"
+	"github.com/mattn/go-runewidth"
+)
+
+// Line backgrounds by diff op: added, removed and rewritten by the reviewer.
+var (
+	addedBg     = lipgloss.Color("#dafbe1")
+	removedBg   = lipgloss.Color("#ffebe9")
+	rewrittenBg = lipgloss.Color("#fff8c5")
+)
+
+// highlighter colours source lines with the lexer matching a file name.
+type highlighter struct {
+	lexer chroma.Lexer
+	style *chroma.Style
+}
+
+func newHighlighter(path string) highlighter {
+	lexer := lexers.Match(filepath.Base(path))
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	return highlighter{lexer: chroma.Coalesce(lexer), style: chromastyles.Get("github")}
+}
+
+// line renders one diff line: the op marker followed by the syntax-coloured
+// text, clipped to width, on the op's background.
+func (h highlighter) line(op, text string, width int) string {
+	text = clip(text, width-2)
+
+	base := lipgloss.NewStyle()
+	marker := lipgloss.NewStyle().Foreground(styles.ColorMuted)
+	if bg, fg := opColours(op); bg != nil {
+		base = base.Background(bg)
+		marker = marker.Background(bg).Foreground(fg).Bold(true)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(marker.Render(op + " "))
+
+	it, err := h.lexer.Tokenise(nil, text)
+	if err != nil {
+		sb.WriteString(base.Render(text))
+	} else {
+		for _, tok := range it.Tokens() {
+			value := strings.TrimSuffix(tok.Value, "\n")
+			if value == "" {
+				continue
+			}
+			st := base
+			if e := h.style.Get(tok.Type); e.Colour.IsSet() {
+				st = st.Foreground(lipgloss.Color(e.Colour.String()))
+			}
+			sb.WriteString(st.Render(value))
+		}
+	}
+
+	// Extend the background to the full width.
+	if pad := width - 2 - runewidth.StringWidth(text); pad > 0 && op != " " {
+		sb.WriteString(base.Render(strings.Repeat(" ", pad)))
+	}
+	return sb.String()
+}
+
+// opColours returns the background and marker colour of a diff op, or nil for
+// context lines.
+func opColours(op string) (color.Color, color.Color) {
+	switch op {
+	case "+":
+		return addedBg, styles.ColorSuccess
+	case "-":
+		return removedBg, styles.ColorError
+	case "~":
+		return rewrittenBg, styles.ColorWarning
+	}
+	return nil, nil
+}
@@ -20,7 +20,7 @@ type Attachment struct {
 	Path      string
 	Data      []byte
 	MediaType string
-	Kind      string // "image", "document", "text"
+	Kind      string // "image", "document", "audio", "text"
 }
 
 // Label returns a short display label for the attachment.
@@ -43,6 +43,12 @@ func (a Attachment) ToPart() content.Part {
 			Data:      a.Data,
 			MediaType: a.MediaType,
 		}
+	case "audio":
+		return content.Audio{
+			Path:      a.Path,
+			Data:      a.Data,
+			MediaType: a.MediaType,
+		}
 	default:
 		// text — inline with filename header
 		return content.Text{
@@ -96,6 +102,18 @@ func detectMediaType(path string, data []byte) string {
 		return "image/webp"
 	case ".pdf":
 		return "application/pdf"
+	case ".wav":
+		return "audio/wav"
+	case ".mp3":
+		return "audio/mpeg"
+	case ".m4a":
+		return "audio/mp4"
+	case ".ogg", ".oga":
+		return "audio/ogg"
+	case ".flac":
+		return "audio/flac"
+	case ".aac":
+		return "audio/aac"
 	case ".go", ".rs", ".py", ".js", ".ts", ".tsx", ".jsx", ".c", ".cpp", ".h", ".rb", ".java", ".kt", ".swift", ".sh", ".bash", ".zsh":
 		return "text/plain"
 	case ".md", ".txt", ".csv", ".log", ".ini", ".cfg", ".toml":
@@ -116,11 +134,14 @@ func detectMediaType(path string, data []byte) string {
 	return http.DetectContentType(sniff)
 }
 
-// classifyKind maps a MIME type to "image", "document", or "text".
+// classifyKind maps a MIME type to "image", "document", "audio", or "text".
 func classifyKind(mediaType string) string {
 	if strings.HasPrefix(mediaType, "image/") {
 		return "image"
 	}
+	if strings.HasPrefix(mediaType, "audio/") {
+		return "audio"
+	}
 	if mediaType == "application/pdf" {
 		return "document"
 	}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/germanamz/shelly/pkg/chats/content"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -20,6 +21,9 @@ func TestDetectMediaType(t *testing.T) {
 		{"file.gif", "image/gif"},
 		{"file.webp", "image/webp"},
 		{"file.pdf", "application/pdf"},
+		{"file.wav", "audio/wav"},
+		{"file.mp3", "audio/mpeg"},
+		{"file.m4a", "audio/mp4"},
 		{"file.go", "text/plain"},
 		{"file.md", "text/plain"},
 		{"file.json", "application/json"},
@@ -39,6 +43,7 @@ func TestClassifyKind(t *testing.T) {
 	assert.Equal(t, "image", classifyKind("image/png"))
 	assert.Equal(t, "image", classifyKind("image/jpeg"))
 	assert.Equal(t, "document", classifyKind("application/pdf"))
+	assert.Equal(t, "audio", classifyKind("audio/wav"))
 	assert.Equal(t, "text", classifyKind("text/plain"))
 	assert.Equal(t, "text", classifyKind("application/json"))
 	assert.Equal(t, "document", classifyKind("application/octet-stream"))
@@ -122,6 +127,12 @@ func TestAttachmentToPart(t *testing.T) {
 		assert.Equal(t, "document", part.PartKind())
 	})
 
+	t.Run("audio part", func(t *testing.T) {
+		att := Attachment{Path: "memo.wav", Data: []byte("wav"), MediaType: "audio/wav", Kind: "audio"}
+		part := att.ToPart()
+		assert.Equal(t, content.Audio{Path: "memo.wav", Data: []byte("wav"), MediaType: "audio/wav"}, part)
+	})
+
 	t.Run("text part", func(t *testing.T) {
 		att := Attachment{Path: "code.go", Data: []byte("package main"), MediaType: "text/plain", Kind: "text"}
 		part := att.ToPart()
@@ -0,0 +1,166 @@
+package input
+
+import (
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
+	"github.com/germanamz/shelly/cmd/shelly/internal/styles"
+)
+
+const ChoicePickerMaxShow = 6
+
+// ChoicePickerModel displays a popup for choosing one of a list of options,
+// such as the session's model or agent. Typing filters the options.
+type ChoicePickerModel struct {
+	Active   bool
+	kind     string
+	title    string
+	choices  []msgs.Choice
+	filtered []msgs.Choice
+	query    string
+	cursor   int
+	maxShow  int
+	Width    int
+}
+
+// NewChoicePicker creates a new ChoicePickerModel.
+func NewChoicePicker() ChoicePickerModel {
+	return ChoicePickerModel{maxShow: ChoicePickerMaxShow}
+}
+
+// Update processes messages for the choice picker.
+func (cp ChoicePickerModel) Update(msg tea.Msg) (ChoicePickerModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case msgs.ChoicePickerActivateMsg:
+		cp.activate(msg)
+		return cp, nil
+	case tea.KeyPressMsg:
+		if !cp.Active {
+			return cp, nil
+		}
+		return cp.handleKey(msg)
+	}
+	return cp, nil
+}
+
+func (cp *ChoicePickerModel) activate(msg msgs.ChoicePickerActivateMsg) {
+	cp.Active = true
+	cp.kind = msg.Kind
+	cp.title = msg.Title
+	cp.choices = msg.Choices
+	cp.query = ""
+	cp.applyFilter()
+}
+
+func (cp *ChoicePickerModel) dismiss() {
+	cp.Active = false
+	cp.choices = nil
+	cp.filtered = nil
+	cp.query = ""
+	cp.cursor = 0
+}
+
+func (cp ChoicePickerModel) handleKey(msg tea.KeyPressMsg) (ChoicePickerModel, tea.Cmd) {
+	k := msg.Key()
+	switch k.Code {
+	case tea.KeyUp:
+		if cp.cursor > 0 {
+			cp.cursor--
+		}
+		return cp, nil
+	case tea.KeyDown:
+		if cp.cursor < len(cp.filtered)-1 {
+			cp.cursor++
+		}
+		return cp, nil
+	case tea.KeyEnter:
+		if len(cp.filtered) > 0 {
+			sel := msgs.ChoicePickerSelectionMsg{Kind: cp.kind, ID: cp.filtered[cp.cursor].ID}
+			cp.dismiss()
+			return cp, func() tea.Msg { return sel }
+		}
+		return cp, nil
+	case tea.KeyEsc:
+		cp.dismiss()
+		return cp, nil
+	case tea.KeyBackspace:
+		if len(cp.query) > 0 {
+			cp.query = cp.query[:len(cp.query)-1]
+			cp.applyFilter()
+		}
+		return cp, nil
+	default:
+		if k.Code >= 0x20 && k.Code < 0x7f && k.Mod == 0 {
+			cp.query += string(k.Code)
+			cp.applyFilter()
+		}
+		return cp, nil
+	}
+}
+
+// View renders the choice picker popup.
+func (cp ChoicePickerModel) View() string {
+	if !cp.Active {
+		return ""
+	}
+
+	innerWidth := max(cp.Width-4, 30)
+
+	var sb strings.Builder
+
+	sb.WriteString(styles.PickerCurStyle.Render(cp.title))
+	if cp.query != "" {
+		sb.WriteString("  " + styles.PickerDimStyle.Render("filter: "+cp.query))
+	}
+	sb.WriteString("\n")
+
+	if len(cp.filtered) == 0 {
+		sb.WriteString(styles.PickerDimStyle.Render("  no matches"))
+	} else {
+		show := min(len(cp.filtered), cp.maxShow)
+		start := 0
+		if cp.cursor >= show {
+			start = cp.cursor - show + 1
+		}
+		end := min(start+show, len(cp.filtered))
+
+		for i := start; i < end; i++ {
+			entry := cp.filtered[i]
+			label := entry.Label
+			if entry.Current {
+				label += " (current)"
+			}
+			if i == cp.cursor {
+				sb.WriteString(styles.PickerCurStyle.Render(label) + "  " + styles.PickerDimStyle.Render(entry.Detail))
+			} else {
+				sb.WriteString(styles.PickerDimStyle.Render(label + "  " + entry.Detail))
+			}
+			if i < end-1 {
+				sb.WriteString("\n")
+			}
+		}
+	}
+
+	sb.WriteString("\n" + styles.PickerDimStyle.Render("↑↓ navigate · enter select · esc cancel"))
+
+	border := styles.PickerBorder.Width(innerWidth)
+	return border.Render(sb.String())
+}
+
+// applyFilter narrows the choices to those matching the query and places the
+// cursor on the current choice, or the first one.
+func (cp *ChoicePickerModel) applyFilter() {
+	q := strings.ToLower(cp.query)
+	cp.filtered = nil
+	cp.cursor = 0
+	for _, c := range cp.choices {
+		if q != "" && !strings.Contains(strings.ToLower(c.Label), q) && !strings.Contains(strings.ToLower(c.Detail), q) {
+			continue
+		}
+		if c.Current {
+			cp.cursor = len(cp.filtered)
+		}
+		cp.filtered = append(cp.filtered, c)
+	}
+}
@@ -0,0 +1,59 @@
+package input
+
+import (
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func activeChoicePicker() ChoicePickerModel {
+	cp := NewChoicePicker()
+	cp, _ = cp.Update(msgs.ChoicePickerActivateMsg{Kind: "model", Title: "Model", Choices: []msgs.Choice{
+		{ID: "cheap", Label: "cheap", Detail: "anthropic/claude-haiku"},
+		{ID: "smart", Label: "smart", Detail: "anthropic/claude-opus", Current: true},
+		{ID: "local", Label: "local", Detail: "openai/llama"},
+	}})
+	return cp
+}
+
+func TestChoicePickerActivate(t *testing.T) {
+	cp := activeChoicePicker()
+	assert.True(t, cp.Active)
+	assert.Len(t, cp.filtered, 3)
+	assert.Equal(t, 1, cp.cursor, "the cursor starts on the current choice")
+	assert.NotEmpty(t, cp.View())
+}
+
+func TestChoicePickerFilter(t *testing.T) {
+	cp := activeChoicePicker()
+	cp, _ = cp.Update(tea.KeyPressMsg(tea.Key{Code: 'l', Text: "l"}))
+	cp, _ = cp.Update(tea.KeyPressMsg(tea.Key{Code: 'l', Text: "l"}))
+
+	require.Len(t, cp.filtered, 1)
+	assert.Equal(t, "local", cp.filtered[0].ID, "details are matched too")
+
+	cp, _ = cp.Update(tea.KeyPressMsg(tea.Key{Code: tea.KeyBackspace}))
+	assert.Len(t, cp.filtered, 3)
+	assert.Equal(t, 1, cp.cursor)
+}
+
+func TestChoicePickerSelection(t *testing.T) {
+	cp := activeChoicePicker()
+	cp, _ = cp.Update(tea.KeyPressMsg(tea.Key{Code: tea.KeyDown}))
+	cp, cmd := cp.Update(tea.KeyPressMsg(tea.Key{Code: tea.KeyEnter}))
+
+	assert.False(t, cp.Active)
+	require.NotNil(t, cmd)
+	assert.Equal(t, msgs.ChoicePickerSelectionMsg{Kind: "model", ID: "local"}, cmd())
+}
+
+func TestChoicePickerEscape(t *testing.T) {
+	cp := activeChoicePicker()
+	cp, cmd := cp.Update(tea.KeyPressMsg(tea.Key{Code: tea.KeyEsc}))
+	assert.False(t, cp.Active)
+	assert.Nil(t, cmd)
+	assert.Empty(t, cp.View())
+}
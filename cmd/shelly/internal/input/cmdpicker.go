@@ -1,6 +1,7 @@
 package input
 
 import (
+	"slices"
 	"strings"
 
 	tea "charm.land/bubbletea/v2"
@@ -10,10 +11,13 @@ import (
 
 const CmdPickerMaxShow = 4
 
-// CommandDef defines a slash command with its name and description.
+// CommandDef defines a slash command with its name and description. Args is
+// an optional argument hint; commands that take arguments are inserted into
+// the input for editing instead of being submitted on selection.
 type CommandDef struct {
 	Name string
 	Desc string
+	Args string
 }
 
 // AvailableCommands is the static list of supported slash commands.
@@ -22,6 +26,14 @@ var AvailableCommands = []CommandDef{
 	{Name: "/clear", Desc: "Clear the conversation history"},
 	{Name: "/compact", Desc: "Compact context to reduce token usage"},
 	{Name: "/sessions", Desc: "Browse and resume previous sessions"},
+	{Name: "/cost", Desc: "Show token usage and cost"},
+	{Name: "/queue", Desc: "Review queued prompts"},
+	{Name: "/memory", Desc: "Review long-term memories"},
+	{Name: "/model", Desc: "Switch the model mid-session"},
+	{Name: "/agent", Desc: "Switch the agent mid-session"},
+	{Name: "/run", Desc: "Run a configured workflow"},
+	{Name: "/speak", Desc: "Toggle reading replies aloud"},
+	{Name: "/vim", Desc: "Toggle vim mode in the input"},
 	{Name: "/settings", Desc: "Open the configuration wizard"},
 	{Name: "/exit", Desc: "Exit the application"},
 }
@@ -31,6 +43,7 @@ type CmdPickerModel struct {
 	Active   bool
 	query    string       // text typed after '/'
 	SlashPos int          // rune position of '/' in input value
+	custom   []CommandDef // user-defined commands listed after the built-ins
 	filtered []CommandDef // filtered commands
 	cursor   int          // highlighted entry index
 	maxShow  int
@@ -42,6 +55,12 @@ func NewCmdPicker() CmdPickerModel {
 	return CmdPickerModel{maxShow: CmdPickerMaxShow}
 }
 
+// SetCommands sets the user-defined commands offered after the built-ins.
+func (cp *CmdPickerModel) SetCommands(defs []CommandDef) {
+	cp.custom = defs
+	cp.applyFilter()
+}
+
 // Update processes messages for the command picker.
 func (cp CmdPickerModel) Update(msg tea.Msg) (CmdPickerModel, tea.Cmd) {
 	switch msg := msg.(type) {
@@ -84,12 +103,12 @@ func (cp *CmdPickerModel) setQuery(q string) {
 	cp.applyFilter()
 }
 
-// selected returns the currently highlighted command name, or "" if none.
-func (cp *CmdPickerModel) selected() string {
+// selected returns the currently highlighted command, if any.
+func (cp *CmdPickerModel) selected() (CommandDef, bool) {
 	if len(cp.filtered) == 0 {
-		return ""
+		return CommandDef{}, false
 	}
-	return cp.filtered[cp.cursor].Name
+	return cp.filtered[cp.cursor], true
 }
 
 // handleKey processes navigation keys while the picker is active.
@@ -107,10 +126,11 @@ func (cp CmdPickerModel) handleKey(msg tea.KeyPressMsg) (CmdPickerModel, tea.Cmd
 		}
 		return cp, nil
 	case tea.KeyEnter, tea.KeyTab:
-		sel := cp.selected()
-		if sel != "" {
+		if sel, ok := cp.selected(); ok {
 			cp.dismiss()
-			return cp, func() tea.Msg { return msgs.CmdPickerSelectionMsg{Command: sel} }
+			return cp, func() tea.Msg {
+				return msgs.CmdPickerSelectionMsg{Command: sel.Name, Insert: sel.Args != ""}
+			}
 		}
 		return cp, nil
 	case tea.KeyEsc:
@@ -142,10 +162,14 @@ func (cp CmdPickerModel) View() string {
 
 		for i := start; i < end; i++ {
 			entry := cp.filtered[i]
+			name := entry.Name
+			if entry.Args != "" {
+				name += " " + entry.Args
+			}
 			if i == cp.cursor {
-				sb.WriteString(styles.PickerCurStyle.Render(entry.Name) + "  " + styles.PickerDimStyle.Render(entry.Desc))
+				sb.WriteString(styles.PickerCurStyle.Render(name) + "  " + styles.PickerDimStyle.Render(entry.Desc))
 			} else {
-				sb.WriteString(styles.PickerDimStyle.Render(entry.Name + "  " + entry.Desc))
+				sb.WriteString(styles.PickerDimStyle.Render(name + "  " + entry.Desc))
 			}
 			if i < end-1 {
 				sb.WriteString("\n")
@@ -158,15 +182,16 @@ func (cp CmdPickerModel) View() string {
 }
 
 func (cp *CmdPickerModel) applyFilter() {
+	all := slices.Concat(AvailableCommands, cp.custom)
+
 	q := strings.ToLower(cp.query)
 	if q == "" {
-		cp.filtered = make([]CommandDef, len(AvailableCommands))
-		copy(cp.filtered, AvailableCommands)
+		cp.filtered = all
 		return
 	}
 
 	var filtered []CommandDef
-	for _, cmd := range AvailableCommands {
+	for _, cmd := range all {
 		// Match against the command without the leading '/'.
 		name := strings.TrimPrefix(cmd.Name, "/")
 		if strings.Contains(strings.ToLower(name), q) {
@@ -74,3 +74,34 @@ func TestCmdPickerViewInactive(t *testing.T) {
 	cp := NewCmdPicker()
 	assert.Empty(t, cp.View())
 }
+
+func TestCmdPickerCustomCommands(t *testing.T) {
+	cp := NewCmdPicker()
+	cp.SetCommands([]CommandDef{{Name: "/review", Desc: "Review the diff", Args: "[focus]"}})
+	cp, _ = cp.Update(msgs.CmdPickerActivateMsg{SlashPos: 0})
+
+	assert.Len(t, cp.filtered, len(AvailableCommands)+1)
+	assert.Equal(t, "/review", cp.filtered[len(cp.filtered)-1].Name, "custom commands follow the built-ins")
+
+	cp, _ = cp.Update(msgs.CmdPickerQueryMsg{Query: "rev"})
+	assert.Len(t, cp.filtered, 1)
+	assert.NotEmpty(t, cp.View())
+
+	_, cmd := cp.Update(tea.KeyPressMsg(tea.Key{Code: tea.KeyEnter}))
+	sel, ok := cmd().(msgs.CmdPickerSelectionMsg)
+	assert.True(t, ok)
+	assert.Equal(t, msgs.CmdPickerSelectionMsg{Command: "/review", Insert: true}, sel)
+}
+
+func TestInputCmdPickerInsert(t *testing.T) {
+	m := New("", "")
+	m.Enabled = true
+
+	m, cmd := m.Update(msgs.CmdPickerSelectionMsg{Command: "/review", Insert: true})
+	assert.Nil(t, cmd, "commands taking arguments are not submitted")
+	assert.Equal(t, "/review ", m.textarea.Value())
+
+	m, cmd = m.Update(msgs.CmdPickerSelectionMsg{Command: "/help"})
+	assert.Empty(t, m.textarea.Value())
+	assert.Equal(t, msgs.InputSubmitMsg{Text: "/help"}, cmd())
+}
@@ -0,0 +1,86 @@
+package input
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const draftsMaxSize = 100
+
+// draft is the unsent prompt of a session.
+type draft struct {
+	Text    string    `json:"text"`
+	Updated time.Time `json:"updated"`
+}
+
+// Drafts keeps the unsent prompt of each session so that it survives quitting
+// and switching sessions. Drafts are stored as JSON keyed by persistent
+// session ID (the ID a session is resumed by); only the most recently updated
+// ones are kept.
+type Drafts struct {
+	entries  map[string]draft
+	filePath string
+	maxSize  int
+}
+
+// NewDrafts loads drafts from disk (if the file exists) and returns a Drafts.
+func NewDrafts(path string) *Drafts {
+	d := &Drafts{
+		entries:  make(map[string]draft),
+		filePath: path,
+		maxSize:  draftsMaxSize,
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &d.entries)
+	}
+	return d
+}
+
+// Get returns the draft of a session, or "".
+func (d *Drafts) Get(sessionID string) string {
+	return d.entries[sessionID].Text
+}
+
+// Set stores the draft of a session and persists it. An empty text removes
+// the draft.
+func (d *Drafts) Set(sessionID, text string) {
+	if sessionID == "" || d.entries[sessionID].Text == text {
+		return
+	}
+	if text == "" {
+		delete(d.entries, sessionID)
+	} else {
+		d.entries[sessionID] = draft{Text: text, Updated: time.Now()}
+		d.prune()
+	}
+	d.save()
+}
+
+// prune drops the oldest drafts beyond maxSize.
+func (d *Drafts) prune() {
+	for len(d.entries) > d.maxSize {
+		var oldest string
+		for id, e := range d.entries {
+			if oldest == "" || e.Updated.Before(d.entries[oldest].Updated) {
+				oldest = id
+			}
+		}
+		delete(d.entries, oldest)
+	}
+}
+
+func (d *Drafts) save() {
+	if d.filePath == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(d.filePath), 0o750); err != nil {
+		return
+	}
+	data, err := json.Marshal(d.entries)
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(d.filePath, data, 0o600)
+}
@@ -0,0 +1,35 @@
+package input
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrafts_Persist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "local", "drafts.json")
+	d := NewDrafts(path)
+	d.Set("s1", "half-written\nprompt")
+	d.Set("s2", "other")
+
+	loaded := NewDrafts(path)
+	assert.Equal(t, "half-written\nprompt", loaded.Get("s1"))
+	assert.Equal(t, "other", loaded.Get("s2"))
+
+	loaded.Set("s1", "")
+	assert.Empty(t, NewDrafts(path).Get("s1"))
+	assert.Empty(t, NewDrafts(path).Get("missing"))
+}
+
+func TestDrafts_Prune(t *testing.T) {
+	d := NewDrafts(filepath.Join(t.TempDir(), "drafts.json"))
+	d.maxSize = 3
+	for i := range 5 {
+		d.Set(fmt.Sprintf("s%d", i), "text")
+	}
+	assert.Len(t, d.entries, 3)
+	assert.Empty(t, d.Get("s0"))
+	assert.Equal(t, "text", d.Get("s4"))
+}
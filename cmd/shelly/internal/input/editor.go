@@ -0,0 +1,56 @@
+package input
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
+)
+
+// openEditor suspends the TUI and edits text in the user's editor. The edited
+// text comes back as an InputEditorDoneMsg.
+func openEditor(text string) tea.Cmd {
+	f, err := os.CreateTemp("", "shelly-prompt-*.md")
+	if err != nil {
+		return editorFailed(err)
+	}
+	path := f.Name()
+	_, werr := f.WriteString(text)
+	if err := errors.Join(werr, f.Close()); err != nil {
+		_ = os.Remove(path)
+		return editorFailed(err)
+	}
+
+	args := editorCommand()
+	cmd := exec.Command(args[0], append(args[1:], path)...) //nolint:gosec // the editor is chosen by the user
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		defer func() { _ = os.Remove(path) }()
+		if err != nil {
+			return msgs.InputEditorDoneMsg{Err: fmt.Errorf("editor: %s: %w", args[0], err)}
+		}
+		data, err := os.ReadFile(path) //nolint:gosec // path is our temp file
+		if err != nil {
+			return msgs.InputEditorDoneMsg{Err: fmt.Errorf("editor: %w", err)}
+		}
+		return msgs.InputEditorDoneMsg{Text: strings.TrimRight(string(data), "\n")}
+	})
+}
+
+func editorFailed(err error) tea.Cmd {
+	return func() tea.Msg { return msgs.InputEditorDoneMsg{Err: fmt.Errorf("editor: %w", err)} }
+}
+
+// editorCommand returns the editor command line from $VISUAL or $EDITOR,
+// falling back to vi.
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if args := strings.Fields(os.Getenv(env)); len(args) > 0 {
+			return args
+		}
+	}
+	return []string{"vi"}
+}
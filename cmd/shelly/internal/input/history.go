@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 const historyMaxSize = 500
@@ -78,6 +79,23 @@ func (h *History) ResetNavigation() {
 	h.draft = ""
 }
 
+// Search returns the index of the newest entry older than before that
+// contains query. Pass before = Len() to search from the newest entry.
+func (h *History) Search(query string, before int) (int, bool) {
+	for i := min(before, len(h.entries)) - 1; i >= 0; i-- {
+		if strings.Contains(h.entries[i], query) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Entry returns the entry at index i (oldest first).
+func (h *History) Entry(i int) string { return h.entries[i] }
+
+// Len returns the number of entries.
+func (h *History) Len() int { return len(h.entries) }
+
 func (h *History) load() {
 	data, err := os.ReadFile(h.filePath)
 	if err != nil {
@@ -170,3 +170,23 @@ func TestLoadTruncates(t *testing.T) {
 	// Should keep the newest entries.
 	assert.Equal(t, entries[100], h.entries[0])
 }
+
+func TestSearch(t *testing.T) {
+	h := NewHistory(tempHistoryPath(t))
+	h.Add("git status")
+	h.Add("run tests")
+	h.Add("git push")
+
+	i, ok := h.Search("git", h.Len())
+	require.True(t, ok)
+	assert.Equal(t, "git push", h.Entry(i))
+
+	i, ok = h.Search("git", i)
+	require.True(t, ok)
+	assert.Equal(t, "git status", h.Entry(i))
+
+	_, ok = h.Search("git", i)
+	assert.False(t, ok)
+	_, ok = h.Search("deploy", h.Len())
+	assert.False(t, ok)
+}
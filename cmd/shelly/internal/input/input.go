@@ -6,10 +6,10 @@ import (
 	"path/filepath"
 	"strings"
 
-	"charm.land/bubbles/v2/key"
 	tea "charm.land/bubbletea/v2"
 	lipgloss "charm.land/lipgloss/v2"
 	"github.com/germanamz/shelly/cmd/shelly/internal/basetextarea"
+	"github.com/germanamz/shelly/cmd/shelly/internal/keymap"
 	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
 	"github.com/germanamz/shelly/cmd/shelly/internal/styles"
 	"github.com/germanamz/shelly/pkg/chats/content"
@@ -26,15 +26,20 @@ type InputModel struct {
 	FilePicker  FilePickerModel
 	CmdPicker   CmdPickerModel
 	history     *History
+	search      *historySearch // non-nil while searching history
+	drafts      *Drafts
+	session     string // persistent ID of the session whose draft is being edited
+	vim         vimState
 	attachments []Attachment // pending file attachments
 	Enabled     bool
 	width       int
 }
 
-// New creates a new InputModel with persistent history at the given path.
-func New(historyPath string) InputModel {
+// New creates a new InputModel with persistent history and drafts at the
+// given paths. Key bindings come from the keymap package.
+func New(historyPath, draftsPath string) InputModel {
 	ta := basetextarea.New("Type a message... (@ for files, / for commands)", InputMinHeight, InputMaxHeight)
-	ta.TA.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("alt+enter", "shift+enter"))
+	ta.TA.KeyMap.InsertNewline = keymap.Binding(keymap.Newline)
 	// Don't focus yet — we focus after the drain delay in appModel.Init().
 
 	return InputModel{
@@ -42,10 +47,51 @@ func New(historyPath string) InputModel {
 		FilePicker: NewFilePicker(),
 		CmdPicker:  NewCmdPicker(),
 		history:    NewHistory(historyPath),
+		drafts:     NewDrafts(draftsPath),
 		Enabled:    false,
 	}
 }
 
+// SetSession saves the current text as the draft of the previous session,
+// resets the input box as InputResetMsg does and loads the draft of
+// sessionID, the session's persistent ID.
+func (m *InputModel) SetSession(sessionID string) {
+	m.SaveDraft()
+	m.reset()
+	m.session = sessionID
+	if text := m.drafts.Get(sessionID); text != "" {
+		m.textarea.SetValue(text)
+		m.fitHeight()
+	}
+}
+
+// reset clears the text, re-enables the box and dismisses pickers.
+func (m *InputModel) reset() {
+	m.textarea.Reset()
+	m.Enabled = true
+	m.FilePicker.Active = false
+	m.CmdPicker.Active = false
+	m.search = nil
+	m.attachments = nil
+	if m.vim.enabled {
+		m.SetVim(true)
+	}
+}
+
+// SetText replaces the text in the input box.
+func (m *InputModel) SetText(text string) {
+	m.textarea.SetValue(text)
+	m.fitHeight()
+}
+
+// Text returns the text in the input box.
+func (m InputModel) Text() string { return m.textarea.Value() }
+
+// SaveDraft stores the current text as the draft of the session.
+func (m InputModel) SaveDraft() {
+	m.drafts.Set(m.session, m.textarea.Value())
+}
+
 func (m InputModel) Update(msg tea.Msg) (InputModel, tea.Cmd) {
 	// Handle lifecycle messages regardless of Enabled state.
 	switch msg := msg.(type) {
@@ -53,11 +99,7 @@ func (m InputModel) Update(msg tea.Msg) (InputModel, tea.Cmd) {
 		m.Enabled = true
 		return m, m.textarea.Focus()
 	case msgs.InputResetMsg:
-		m.textarea.Reset()
-		m.Enabled = true
-		m.FilePicker.Active = false
-		m.CmdPicker.Active = false
-		m.attachments = nil
+		m.reset()
 		return m, nil
 	case msgs.InputSetWidthMsg:
 		m.width = msg.Width
@@ -78,7 +120,17 @@ func (m InputModel) Update(msg tea.Msg) (InputModel, tea.Cmd) {
 	case msgs.CmdPickerSelectionMsg:
 		m.textarea.Reset()
 		m.CmdPicker.Active = false
+		if msg.Insert {
+			m.textarea.SetValue(msg.Command + " ")
+			return m, nil
+		}
 		return m, func() tea.Msg { return msgs.InputSubmitMsg{Text: msg.Command} }
+	case msgs.InputEditorDoneMsg:
+		if msg.Err == nil {
+			m.textarea.SetValue(msg.Text)
+			m.fitHeight()
+		}
+		return m, nil
 	case tea.KeyPressMsg:
 		return m.handleKeyPress(msg)
 	}
@@ -105,8 +157,13 @@ func isPickerNavKey(code rune) bool {
 	return code == tea.KeyUp || code == tea.KeyDown || code == tea.KeyEnter || code == tea.KeyTab || code == tea.KeyEsc
 }
 
-// handleKeyPress processes key presses, routing to pickers or textarea as appropriate.
+// handleKeyPress processes key presses, routing to history search, pickers,
+// vim mode or the textarea as appropriate.
 func (m InputModel) handleKeyPress(keyMsg tea.KeyPressMsg) (InputModel, tea.Cmd) {
+	if m.search != nil {
+		return m.handleSearchKey(keyMsg)
+	}
+
 	// Route keys to file picker when active.
 	if m.FilePicker.Active {
 		var cmd tea.Cmd
@@ -131,47 +188,40 @@ func (m InputModel) handleKeyPress(keyMsg tea.KeyPressMsg) (InputModel, tea.Cmd)
 		}
 	}
 
-	// Ctrl+U clears all pending attachments.
-	if keyMsg.Key().Code == 'u' && keyMsg.Key().Mod&tea.ModCtrl != 0 && len(m.attachments) > 0 {
+	switch {
+	case keymap.Matches(keyMsg, keymap.ToggleVim):
+		m.SetVim(!m.vim.enabled)
+		return m, nil
+	case keymap.Matches(keyMsg, keymap.OpenEditor):
+		return m, openEditor(m.textarea.Value())
+	case keymap.Matches(keyMsg, keymap.HistorySearch):
+		m.startSearch()
+		return m, nil
+	case keymap.Matches(keyMsg, keymap.ClearAttachments) && len(m.attachments) > 0:
 		m.attachments = nil
 		return m, nil
+	case keymap.Matches(keyMsg, keymap.Submit) && !m.FilePicker.Active && !m.CmdPicker.Active:
+		return m.submit(false)
+	case keymap.Matches(keyMsg, keymap.Steer) && !m.FilePicker.Active && !m.CmdPicker.Active:
+		return m.submit(true)
 	}
 
-	// History navigation: Up on first line, Down on last line.
-	if keyMsg.Key().Code == tea.KeyUp && !m.FilePicker.Active && !m.CmdPicker.Active && m.cursorOnFirstLine() {
-		if text, ok := m.history.Up(m.textarea.Value()); ok {
-			m.textarea.SetValue(text)
-			lines := m.textarea.VisualLineCount()
-			m.textarea.SetHeight(min(max(lines, InputMinHeight), InputMaxHeight))
+	if m.vim.enabled {
+		if m.vim.mode != vimInsert {
+			return m.handleVimKey(keyMsg)
 		}
-		return m, nil
-	}
-	if keyMsg.Key().Code == tea.KeyDown && !m.FilePicker.Active && !m.CmdPicker.Active && m.cursorOnLastLine() {
-		if text, ok := m.history.Down(); ok {
-			m.textarea.SetValue(text)
-			lines := m.textarea.VisualLineCount()
-			m.textarea.SetHeight(min(max(lines, InputMinHeight), InputMaxHeight))
+		if keyMsg.Key().Code == tea.KeyEsc {
+			m.enterVimNormal()
+			return m, nil
 		}
-		return m, nil
 	}
 
-	// Handle enter submission (Shift+Enter and Alt+Enter are consumed by the
-	// textarea's InsertNewline binding before reaching here).
-	if keyMsg.Key().Code == tea.KeyEnter && keyMsg.Key().Mod&tea.ModAlt == 0 && !m.FilePicker.Active && !m.CmdPicker.Active {
-		text := strings.TrimSpace(m.textarea.Value())
-		parts := m.attachmentParts()
-		if text != "" || len(parts) > 0 {
-			if text != "" {
-				m.history.Add(text)
-			}
-			m.textarea.Reset()
-			m.FilePicker.Active = false
-			m.CmdPicker.Active = false
-			submitMsg := msgs.InputSubmitMsg{Text: text, Parts: parts}
-			m.attachments = nil
-			return m, func() tea.Msg { return submitMsg }
-		}
-		return m, nil
+	// History navigation: previous on the first line, next on the last line.
+	if keymap.Matches(keyMsg, keymap.HistoryPrev) && !m.FilePicker.Active && !m.CmdPicker.Active && m.cursorOnFirstLine() {
+		return m.historyUp(), nil
+	}
+	if keymap.Matches(keyMsg, keymap.HistoryNext) && !m.FilePicker.Active && !m.CmdPicker.Active && m.cursorOnLastLine() {
+		return m.historyDown(), nil
 	}
 
 	// Capture text before update to detect '@' or '/' insertion.
@@ -188,6 +238,54 @@ func (m InputModel) handleKeyPress(keyMsg tea.KeyPressMsg) (InputModel, tea.Cmd)
 	return m, cmd
 }
 
+// submit sends the text and attachments, if any, and clears the input and the
+// session's draft. An interrupt submission steers the running agent.
+func (m InputModel) submit(interrupt bool) (InputModel, tea.Cmd) {
+	text := strings.TrimSpace(m.textarea.Value())
+	parts := m.attachmentParts()
+	if text == "" && len(parts) == 0 {
+		return m, nil
+	}
+	if text != "" {
+		m.history.Add(text)
+	}
+	m.textarea.Reset()
+	m.drafts.Set(m.session, "")
+	m.FilePicker.Active = false
+	m.CmdPicker.Active = false
+	m.attachments = nil
+	if m.vim.enabled {
+		m.SetVim(true)
+	}
+	submitMsg := msgs.InputSubmitMsg{Text: text, Parts: parts, Interrupt: interrupt}
+	return m, func() tea.Msg { return submitMsg }
+}
+
+// historyUp replaces the text with the previous history entry.
+func (m InputModel) historyUp() InputModel {
+	if text, ok := m.history.Up(m.textarea.Value()); ok {
+		m.textarea.SetValue(text)
+		m.fitHeight()
+	}
+	return m
+}
+
+// historyDown replaces the text with the next history entry, or the draft
+// after the newest one.
+func (m InputModel) historyDown() InputModel {
+	if text, ok := m.history.Down(); ok {
+		m.textarea.SetValue(text)
+		m.fitHeight()
+	}
+	return m
+}
+
+// fitHeight sizes the textarea to its content.
+func (m *InputModel) fitHeight() {
+	lines := m.textarea.VisualLineCount()
+	m.textarea.SetHeight(min(max(lines, InputMinHeight), InputMaxHeight))
+}
+
 // updatePickerState detects '@' or '/' insertion and updates the picker query.
 func (m *InputModel) updatePickerState(prevVal, newVal string, existingCmd tea.Cmd) tea.Cmd {
 	// File picker active — update query.
@@ -366,14 +464,28 @@ func (m InputModel) viewInput() string {
 	border = border.Width(m.width)
 
 	content := m.textarea.View()
-	if len(m.attachments) > 0 {
-		tags := m.attachmentTagLine()
-		content = content + "\n" + tags
+	if m.search != nil {
+		content = m.searchView(innerWidth)
+	}
+	if status := m.statusLine(); status != "" {
+		content = content + "\n" + status
 	}
 
 	return border.Render(content)
 }
 
+// statusLine renders the vim mode and the pending attachment names, or "".
+func (m InputModel) statusLine() string {
+	var parts []string
+	if mode := m.vimIndicator(); mode != "" {
+		parts = append(parts, mode)
+	}
+	if len(m.attachments) > 0 {
+		parts = append(parts, m.attachmentTagLine())
+	}
+	return strings.Join(parts, "  ")
+}
+
 // attachmentTagLine renders a line showing all pending attachment names.
 func (m InputModel) attachmentTagLine() string {
 	var tags []string
@@ -403,8 +515,14 @@ func (m InputModel) IsEmpty() bool {
 
 // ViewHeight returns the height of the input box area.
 func (m InputModel) ViewHeight() int {
-	// Border (2) + textarea lines.
-	lines := m.textarea.VisualLineCount()
-	h := min(max(lines, InputMinHeight), InputMaxHeight)
+	// Border (2) + textarea lines + status line.
+	h := 1
+	if m.search == nil {
+		lines := m.textarea.VisualLineCount()
+		h = min(max(lines, InputMinHeight), InputMaxHeight)
+	}
+	if m.statusLine() != "" {
+		h++
+	}
 	return h + 2
 }
@@ -1 +1,125 @@
 package input
+
+import (
+	"path/filepath"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/germanamz/shelly/cmd/shelly/internal/keymap"
+	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestInput returns an enabled input with history in a temp dir.
+func newTestInput(t *testing.T) InputModel {
+	t.Helper()
+	m := New(tempHistoryPath(t), filepath.Join(t.TempDir(), "drafts.json"))
+	m, _ = m.Update(msgs.InputEnableMsg{})
+	m, _ = m.Update(msgs.InputSetWidthMsg{Width: 84})
+	return m
+}
+
+// press sends a named key such as "esc", "enter" or "ctrl+r".
+func press(m InputModel, name string) (InputModel, tea.Cmd) {
+	keys := map[string]tea.Key{
+		"esc":       {Code: tea.KeyEsc},
+		"enter":     {Code: tea.KeyEnter},
+		"tab":       {Code: tea.KeyTab},
+		"backspace": {Code: tea.KeyBackspace},
+		"up":        {Code: tea.KeyUp},
+		"ctrl+r":    {Code: 'r', Mod: tea.ModCtrl},
+		"ctrl+f":    {Code: 'f', Mod: tea.ModCtrl},
+		"alt+enter": {Code: tea.KeyEnter, Mod: tea.ModAlt},
+		"f2":        {Code: tea.KeyF2},
+	}
+	return m.Update(tea.KeyPressMsg(keys[name]))
+}
+
+// typeKeys sends each rune of s as a key press.
+func typeKeys(m InputModel, s string) InputModel {
+	for _, r := range s {
+		k := tea.Key{Code: r, Text: string(r)}
+		if r >= 'A' && r <= 'Z' {
+			k.Code, k.Mod = r+'a'-'A', tea.ModShift
+		}
+		m, _ = m.Update(tea.KeyPressMsg(k))
+	}
+	return m
+}
+
+func TestDrafts_PerSession(t *testing.T) {
+	m := newTestInput(t)
+	m.SetSession("s1")
+	m.textarea.SetValue("half-written")
+
+	m.SetSession("s2")
+	assert.Empty(t, m.textarea.Value())
+	m.textarea.SetValue("other")
+
+	m.SetSession("s1")
+	assert.Equal(t, "half-written", m.textarea.Value())
+
+	// Submitting clears the draft.
+	m, _ = press(m, "enter")
+	m.SetSession("s2")
+	assert.Equal(t, "other", m.textarea.Value())
+	m.SetSession("s1")
+	assert.Empty(t, m.textarea.Value())
+}
+
+func TestEditorDone(t *testing.T) {
+	m := newTestInput(t)
+	m.textarea.SetValue("before")
+
+	m, _ = m.Update(msgs.InputEditorDoneMsg{Text: "line one\nline two"})
+	assert.Equal(t, "line one\nline two", m.textarea.Value())
+	assert.Equal(t, 4, m.ViewHeight())
+
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "code --wait")
+	assert.Equal(t, []string{"code", "--wait"}, editorCommand())
+	t.Setenv("EDITOR", "")
+	assert.Equal(t, []string{"vi"}, editorCommand())
+}
+
+func TestKeymapOverrides(t *testing.T) {
+	require.NoError(t, keymap.Configure(map[string][]string{
+		"submit":         {"alt+enter"},
+		"newline":        {"enter"},
+		"history_search": {"ctrl+f"},
+		"toggle_vim":     {"f2"},
+	}))
+	t.Cleanup(func() { _ = keymap.Configure(nil) })
+
+	// The newline binding is read when the input is created.
+	m := newTestInput(t)
+	m.history.Add("git status")
+	m.history.Add("git push")
+
+	m = typeKeys(m, "a")
+	m, _ = press(m, "enter")
+	m = typeKeys(m, "b")
+	assert.Equal(t, "a\nb", m.textarea.Value(), "enter inserts a newline")
+	m, cmd := press(m, "alt+enter")
+	require.NotNil(t, cmd)
+	assert.Equal(t, msgs.InputSubmitMsg{Text: "a\nb"}, cmd())
+
+	// The default search key is free; the new one also finds older matches.
+	m, _ = press(m, "ctrl+r")
+	assert.Nil(t, m.search)
+	m, _ = press(m, "ctrl+f")
+	require.NotNil(t, m.search)
+	m = typeKeys(m, "git")
+	m, _ = press(m, "ctrl+f")
+	m, _ = press(m, "enter")
+	assert.Equal(t, "git status", m.textarea.Value())
+
+	// toggle_vim works in insert and normal mode.
+	m, _ = press(m, "f2")
+	assert.True(t, m.vim.enabled)
+	m, _ = press(m, "esc")
+	require.Equal(t, vimNormal, m.vim.mode)
+	m, _ = press(m, "f2")
+	assert.False(t, m.vim.enabled)
+}
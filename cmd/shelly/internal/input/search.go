@@ -0,0 +1,93 @@
+package input
+
+import (
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/germanamz/shelly/cmd/shelly/internal/keymap"
+	"github.com/germanamz/shelly/cmd/shelly/internal/styles"
+	"github.com/mattn/go-runewidth"
+)
+
+// historySearch is a reverse incremental search over the input history, like
+// Ctrl-R in a shell.
+type historySearch struct {
+	query string
+	match int // Index of the matching history entry; -1 when nothing matches.
+}
+
+// startSearch begins a history search.
+func (m *InputModel) startSearch() {
+	m.search = &historySearch{match: -1}
+}
+
+// handleSearchKey processes keys while searching: typing refines the query,
+// the history search key finds the next older match, Enter or Tab puts the
+// match in the input for editing and Esc cancels.
+func (m InputModel) handleSearchKey(msg tea.KeyPressMsg) (InputModel, tea.Cmd) {
+	s := *m.search
+	k := msg.Key()
+
+	switch {
+	case keymap.Matches(msg, keymap.HistorySearch):
+		before := m.history.Len()
+		if s.match >= 0 {
+			before = s.match
+		}
+		if i, ok := m.history.Search(s.query, before); ok {
+			s.match = i
+		}
+	case k.Code == tea.KeyEsc:
+		m.search = nil
+		return m, nil
+	case k.Code == tea.KeyEnter || k.Code == tea.KeyTab:
+		m.search = nil
+		if s.match >= 0 {
+			m.textarea.SetValue(m.history.Entry(s.match))
+			m.fitHeight()
+			m.history.ResetNavigation()
+		}
+		return m, nil
+	case k.Code == tea.KeyBackspace:
+		if s.query == "" {
+			break
+		}
+		runes := []rune(s.query)
+		s.query = string(runes[:len(runes)-1])
+		s.match = m.searchFrom(s.query, m.history.Len())
+	case k.Text != "" && k.Mod&(tea.ModCtrl|tea.ModAlt) == 0:
+		s.query += k.Text
+		// Keep the current match while it still matches.
+		before := m.history.Len()
+		if s.match >= 0 {
+			before = s.match + 1
+		}
+		s.match = m.searchFrom(s.query, before)
+	}
+	m.search = &s
+	return m, nil
+}
+
+func (m InputModel) searchFrom(query string, before int) int {
+	if query == "" {
+		return -1
+	}
+	if i, ok := m.history.Search(query, before); ok {
+		return i
+	}
+	return -1
+}
+
+// searchView renders the search prompt and the matching entry on one line.
+func (m InputModel) searchView(width int) string {
+	prompt := "(reverse-i-search)`" + m.search.query + "': "
+	if m.search.match < 0 && m.search.query != "" {
+		prompt = "(failed " + prompt[1:]
+	}
+	var match string
+	if m.search.match >= 0 {
+		match = strings.ReplaceAll(m.history.Entry(m.search.match), "\n", " ⏎ ")
+	}
+	match = runewidth.Truncate(match, max(width-runewidth.StringWidth(prompt), 1), "…")
+	return styles.DimStyle.Render(prompt) + match
+}
@@ -0,0 +1,89 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistorySearch(t *testing.T) {
+	m := newTestInput(t)
+	for _, e := range []string{"git status", "run the tests", "git push origin"} {
+		m.history.Add(e)
+	}
+	m.textarea.SetValue("draft")
+
+	m, _ = press(m, "ctrl+r")
+	assert.True(t, m.WantsEsc())
+	m = typeKeys(m, "git")
+	assert.Contains(t, ansi.Strip(m.viewInput()), "(reverse-i-search)`git': git push origin")
+
+	m, _ = press(m, "ctrl+r")
+	assert.Contains(t, ansi.Strip(m.viewInput()), "git status")
+
+	m = typeKeys(m, "x")
+	assert.Contains(t, ansi.Strip(m.viewInput()), "(failed reverse-i-search)`gitx'")
+	m, _ = press(m, "backspace")
+	m, _ = press(m, "tab")
+	assert.Nil(t, m.search)
+	assert.Equal(t, "git push origin", m.textarea.Value(), "backspace searches again from the newest entry")
+
+	// Esc cancels and keeps the text.
+	m.textarea.SetValue("draft")
+	m, _ = press(m, "ctrl+r")
+	m = typeKeys(m, "run")
+	m, _ = press(m, "esc")
+	assert.Nil(t, m.search)
+	assert.Equal(t, "draft", m.textarea.Value())
+}
+
+func TestHistorySearch_Refine(t *testing.T) {
+	m := newTestInput(t)
+	for _, e := range []string{"git status", "git stash", "ls"} {
+		m.history.Add(e)
+	}
+
+	m, _ = press(m, "ctrl+r")
+	m = typeKeys(m, "git sta")
+	require.NotNil(t, m.search)
+	assert.Equal(t, "git stash", m.history.Entry(m.search.match))
+
+	// The current match is kept while it still matches, else an older one
+	// is found.
+	m = typeKeys(m, "s")
+	assert.Equal(t, "git stash", m.history.Entry(m.search.match))
+	m, _ = press(m, "backspace")
+	m = typeKeys(m, "t")
+	assert.Equal(t, "git status", m.history.Entry(m.search.match))
+
+	// The search key stays on the oldest match when there is no older one.
+	m, _ = press(m, "ctrl+r")
+	assert.Equal(t, "git status", m.history.Entry(m.search.match))
+
+	// Backspace on an empty query is a no-op; Enter without a match keeps the
+	// text.
+	m.textarea.SetValue("draft")
+	m.search = &historySearch{match: -1}
+	m, _ = press(m, "backspace")
+	assert.Empty(t, m.search.query)
+	m, _ = press(m, "enter")
+	assert.Nil(t, m.search)
+	assert.Equal(t, "draft", m.textarea.Value())
+}
+
+func TestHistorySearch_View(t *testing.T) {
+	m := newTestInput(t)
+	m.history.Add("first line\nsecond line with a long tail")
+
+	m.search = &historySearch{match: -1}
+	assert.Equal(t, "(reverse-i-search)`': ", ansi.Strip(m.searchView(80)))
+
+	m.search = &historySearch{query: "first", match: 0}
+	assert.Equal(t, "(reverse-i-search)`first': first line ⏎ second line with a long tail", ansi.Strip(m.searchView(80)))
+	assert.Equal(t, "(reverse-i-search)`first': first line ⏎…", ansi.Strip(m.searchView(40)), "the match is truncated to the width")
+
+	m.search = &historySearch{query: "zzz", match: -1}
+	assert.Equal(t, "(failed reverse-i-search)`zzz': ", ansi.Strip(m.searchView(80)))
+}
@@ -2,6 +2,7 @@ package input
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -133,12 +134,19 @@ func (sp SessionPickerModel) View() string {
 
 		for i := start; i < end; i++ {
 			entry := sp.filtered[i]
-			preview := format.Truncate(entry.Preview, 40)
+			label := entry.Title
+			if label == "" {
+				label = entry.Preview
+			}
+			preview := format.Truncate(label, 40)
 			if preview == "" {
 				preview = "(empty)"
 			}
 			ago := relativeTime(entry.UpdatedAt)
 			meta := fmt.Sprintf("%s | %s | %d msgs", entry.Agent, ago, entry.MsgCount)
+			if len(entry.Tags) > 0 {
+				meta += " | #" + strings.Join(entry.Tags, " #")
+			}
 
 			if i == sp.cursor {
 				sb.WriteString(styles.PickerCurStyle.Render(preview))
@@ -172,7 +180,9 @@ func (sp *SessionPickerModel) applyFilter() {
 	var filtered []sessions.SessionInfo
 	for _, s := range sp.sessions {
 		if strings.Contains(strings.ToLower(s.Preview), q) ||
-			strings.Contains(strings.ToLower(s.Agent), q) {
+			strings.Contains(strings.ToLower(s.Title), q) ||
+			strings.Contains(strings.ToLower(s.Agent), q) ||
+			slices.ContainsFunc(s.Tags, func(t string) bool { return strings.Contains(strings.ToLower(t), q) }) {
 			filtered = append(filtered, s)
 		}
 	}
@@ -0,0 +1,612 @@
+package input
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/germanamz/shelly/cmd/shelly/internal/styles"
+)
+
+// vimMode is the editing mode of the input while vim mode is enabled.
+type vimMode int
+
+const (
+	vimInsert vimMode = iota
+	vimNormal
+	vimVisual
+)
+
+const vimUndoMaxSize = 100
+
+// vimState holds the vim mode of the input: the current mode, a pending
+// operator and count, the unnamed register and the undo stack.
+type vimState struct {
+	enabled  bool
+	mode     vimMode
+	pending  string // Operator or prefix awaiting the rest of the command: "d", "c", "y", "g", "dg"...
+	count    int    // Count typed before the command or motion.
+	opCount  int    // Count typed before the pending operator.
+	anchor   int    // Visual mode: offset where the selection started.
+	register string // Last yanked or deleted text.
+	linewise bool   // The register holds whole lines.
+	undo     []vimSnapshot
+}
+
+type vimSnapshot struct {
+	text string
+	pos  int
+}
+
+// SetVim enables or disables vim mode. Vim mode starts in insert mode.
+func (m *InputModel) SetVim(on bool) {
+	m.vim = vimState{enabled: on}
+}
+
+// Vim reports whether vim mode is enabled.
+func (m InputModel) Vim() bool { return m.vim.enabled }
+
+// WantsEsc reports whether the input uses Esc itself: to leave vim insert or
+// visual mode, to cancel a pending vim command or to cancel history search.
+func (m InputModel) WantsEsc() bool {
+	if m.search != nil {
+		return true
+	}
+	return m.vim.enabled && (m.vim.mode != vimNormal || m.vim.pending != "" || m.vim.count > 0)
+}
+
+// vimIndicator renders the current vim mode for the status line.
+func (m InputModel) vimIndicator() string {
+	if !m.vim.enabled {
+		return ""
+	}
+	var s string
+	switch m.vim.mode {
+	case vimInsert:
+		s = "-- INSERT --"
+	case vimNormal:
+		s = "-- NORMAL --"
+	case vimVisual:
+		runes := []rune(m.textarea.Value())
+		start, end := m.visualRange(runes)
+		s = fmt.Sprintf("-- VISUAL -- %d selected", end-start)
+	}
+	if pending := m.vimPendingKeys(); pending != "" {
+		s += " " + pending
+	}
+	return styles.DimStyle.Render(s)
+}
+
+func (m InputModel) vimPendingKeys() string {
+	var sb strings.Builder
+	if m.vim.opCount > 0 {
+		fmt.Fprint(&sb, m.vim.opCount)
+	}
+	sb.WriteString(m.vim.pending)
+	if m.vim.count > 0 {
+		fmt.Fprint(&sb, m.vim.count)
+	}
+	return sb.String()
+}
+
+// enterVimNormal leaves insert or visual mode. Leaving insert mode moves the
+// cursor back onto the last inserted character, as vim does.
+func (m *InputModel) enterVimNormal() {
+	runes := []rune(m.textarea.Value())
+	pos := m.cursorOffset(runes)
+	if m.vim.mode == vimInsert && pos > lineStart(runes, pos) {
+		pos--
+	}
+	m.vim.mode = vimNormal
+	m.resetVimPending()
+	m.setCursorOffset(runes, clampNormal(runes, pos))
+}
+
+func (m *InputModel) resetVimPending() {
+	m.vim.pending = ""
+	m.vim.count = 0
+	m.vim.opCount = 0
+}
+
+// handleVimKey processes a key in normal or visual mode.
+func (m InputModel) handleVimKey(msg tea.KeyPressMsg) (InputModel, tea.Cmd) {
+	k := msg.String()
+	runes := []rune(m.textarea.Value())
+	pos := m.cursorOffset(runes)
+
+	if k == "esc" {
+		m.vim.mode = vimNormal
+		m.resetVimPending()
+		return m, nil
+	}
+
+	// Counts: "0" is a motion unless a count is being typed.
+	if len(k) == 1 && k[0] >= '0' && k[0] <= '9' && (k != "0" || m.vim.count > 0) {
+		m.vim.count = m.vim.count*10 + int(k[0]-'0')
+		return m, nil
+	}
+
+	if m.vim.mode == vimVisual {
+		m.handleVisualKey(k, runes, pos)
+		return m, nil
+	}
+
+	if op := m.vim.pending; op != "" && op != "g" {
+		m.handleOperatorKey(k, runes, pos)
+		return m, nil
+	}
+
+	n := max(m.vim.count, 1)
+	if m.vim.pending == "g" {
+		// gg goes to the first line, or to line n with a count.
+		counted := m.vim.count > 0
+		m.resetVimPending()
+		if k == "g" {
+			target := 0
+			if counted {
+				target = lineForward(runes, 0, n-1)
+			}
+			m.setCursorOffset(runes, clampNormal(runes, firstNonBlank(runes, target)))
+		}
+		return m, nil
+	}
+
+	switch k {
+	case "d", "c", "y":
+		m.vim.pending = k
+		m.vim.opCount = m.vim.count
+		m.vim.count = 0
+		return m, nil
+	case "g":
+		m.vim.pending = "g"
+		return m, nil
+	}
+	counted := m.vim.count > 0
+	m.vim.count = 0
+
+	switch k {
+	case "i":
+		m.vimInsertAt(runes, pos)
+	case "a":
+		m.vimInsertAt(runes, min(pos+1, lineEnd(runes, pos)))
+	case "I":
+		m.vimInsertAt(runes, firstNonBlank(runes, pos))
+	case "A":
+		m.vimInsertAt(runes, lineEnd(runes, pos))
+	case "o", "O":
+		m.pushUndo(runes, pos)
+		at := lineEnd(runes, pos)
+		cursor := at + 1
+		if k == "O" {
+			at = lineStart(runes, pos)
+			cursor = at
+		}
+		m.setText(insertRunes(runes, at, []rune("\n")), cursor)
+		m.vim.mode = vimInsert
+	case "x":
+		m.vimApply("d", runes, pos, min(pos+n, lineEnd(runes, pos)), false)
+	case "s":
+		m.vimApply("c", runes, pos, min(pos+n, lineEnd(runes, pos)), false)
+	case "D", "C":
+		m.vimApply(strings.ToLower(k), runes, pos, lineEnd(runes, pos), false)
+	case "S":
+		m.vimApply("c", runes, pos, lineForward(runes, pos, n-1), true)
+	case "Y":
+		m.vimApply("y", runes, pos, lineForward(runes, pos, n-1), true)
+	case "p", "P":
+		m.vimPaste(runes, pos, n, k == "P")
+	case "u":
+		m.popUndo()
+	case "v":
+		m.vim.mode = vimVisual
+		m.vim.anchor = pos
+	case "k", "up":
+		if lineStart(runes, pos) == 0 {
+			return m.historyUp(), nil
+		}
+		m.vimMove(runes, pos, k, n, counted)
+	case "j", "down":
+		if lineEnd(runes, pos) == len(runes) {
+			return m.historyDown(), nil
+		}
+		m.vimMove(runes, pos, k, n, counted)
+	default:
+		m.vimMove(runes, pos, k, n, counted)
+	}
+	return m, nil
+}
+
+// vimMove moves the cursor by a motion in normal mode.
+func (m *InputModel) vimMove(runes []rune, pos int, k string, n int, counted bool) {
+	if k == "G" && !counted {
+		n = 0
+	}
+	if target, _, _, ok := vimMotion(runes, pos, k, n); ok {
+		m.setCursorOffset(runes, clampNormal(runes, target))
+	}
+}
+
+// handleOperatorKey completes a pending d, c or y with a motion or, when the
+// operator is repeated, with whole lines.
+func (m *InputModel) handleOperatorKey(k string, runes []rune, pos int) {
+	pending := m.vim.pending
+	op := pending[:1]
+	counted := m.vim.count > 0 || m.vim.opCount > 0
+	n := max(m.vim.opCount, 1) * max(m.vim.count, 1)
+
+	if pending == op && k == "g" {
+		m.vim.pending = op + "g"
+		return
+	}
+	m.resetVimPending()
+
+	var (
+		target              int
+		inclusive, linewise bool
+		ok                  bool
+	)
+	switch {
+	case pending == op && k == op:
+		target, linewise, ok = lineForward(runes, pos, n-1), true, true
+	case pending != op:
+		// dgg, cgg, ygg: to the first line.
+		target, linewise, ok = 0, true, k == "g"
+	case op == "c" && k == "w":
+		// cw changes to the end of the word, like ce.
+		if unicode.IsSpace(runeAt(runes, pos)) {
+			target, ok = min(pos+1, lineEnd(runes, pos)), true
+		} else {
+			target, inclusive, linewise, ok = vimMotion(runes, pos, "e", n)
+		}
+	default:
+		if k == "G" && !counted {
+			n = 0
+		}
+		target, inclusive, linewise, ok = vimMotion(runes, pos, k, n)
+		switch {
+		case !ok:
+		case op != "c" && k == "w":
+			// dw and yw stop at the end of the line.
+			target = min(target, max(lineEnd(runes, pos), pos+1))
+		case linewise && k != "G" && lineStart(runes, target) == lineStart(runes, pos):
+			// j or k past the first or last line.
+			ok = false
+		}
+	}
+	if !ok {
+		return
+	}
+
+	start, end := min(pos, target), max(pos, target)
+	if inclusive && !linewise {
+		end = min(end+1, lineEnd(runes, end))
+	}
+	m.vimApply(op, runes, start, end, linewise)
+}
+
+// handleVisualKey moves the selection or applies an operator to it.
+func (m *InputModel) handleVisualKey(k string, runes []rune, pos int) {
+	n := max(m.vim.count, 1)
+	m.vim.count = 0
+
+	switch k {
+	case "v":
+		m.vim.mode = vimNormal
+	case "o":
+		m.vim.anchor, pos = pos, m.vim.anchor
+		m.setCursorOffset(runes, pos)
+	case "d", "x", "c", "s", "y":
+		start, end := m.visualRange(runes)
+		op := map[string]string{"d": "d", "x": "d", "c": "c", "s": "c", "y": "y"}[k]
+		m.vim.mode = vimNormal
+		m.vimApply(op, runes, start, end, false)
+	default:
+		if target, _, _, ok := vimMotion(runes, pos, k, n); ok {
+			m.setCursorOffset(runes, clampNormal(runes, target))
+		}
+	}
+}
+
+// visualRange returns the selected range, which includes the characters
+// under the anchor and the cursor.
+func (m InputModel) visualRange(runes []rune) (int, int) {
+	pos := m.cursorOffset(runes)
+	start, end := min(pos, m.vim.anchor), max(pos, m.vim.anchor)
+	return start, min(end+1, len(runes))
+}
+
+// vimApply deletes, changes or yanks runes[start:end]. A linewise range is
+// widened to whole lines.
+func (m *InputModel) vimApply(op string, runes []rune, start, end int, linewise bool) {
+	if linewise {
+		start, end = lineStart(runes, start), lineEnd(runes, end)
+	}
+	if start >= end && !linewise {
+		if op == "c" {
+			m.vimInsertAt(runes, start)
+		}
+		return
+	}
+	m.vim.register = string(runes[start:end])
+	m.vim.linewise = linewise
+
+	switch op {
+	case "y":
+		m.setCursorOffset(runes, clampNormal(runes, start))
+		return
+	case "c":
+		m.pushUndo(runes, m.cursorOffset(runes))
+		m.setText(deleteRunes(runes, start, end), start)
+		m.vim.mode = vimInsert
+		return
+	}
+
+	m.pushUndo(runes, m.cursorOffset(runes))
+	if linewise {
+		// Take a line break with the lines.
+		switch {
+		case end < len(runes):
+			end++
+		case start > 0:
+			start--
+		}
+	}
+	out := deleteRunes(runes, start, end)
+	cursor := start
+	if linewise {
+		cursor = firstNonBlank(out, min(start, len(out)))
+	}
+	m.setText(out, clampNormal(out, cursor))
+}
+
+// vimPaste puts the register n times after (or, with before, at) the cursor.
+// Linewise text goes below or above the cursor line.
+func (m *InputModel) vimPaste(runes []rune, pos, n int, before bool) {
+	if m.vim.register == "" && !m.vim.linewise {
+		return
+	}
+	m.pushUndo(runes, pos)
+	text := []rune(strings.Repeat(m.vim.register+"\n", n))
+
+	if m.vim.linewise {
+		if before {
+			at := lineStart(runes, pos)
+			m.setText(insertRunes(runes, at, text), at)
+			return
+		}
+		at := lineEnd(runes, pos)
+		// Move the line break from the end of the pasted lines to their start.
+		text = append([]rune("\n"), text[:len(text)-1]...)
+		m.setText(insertRunes(runes, at, text), at+1)
+		return
+	}
+
+	text = []rune(strings.Repeat(m.vim.register, n))
+	at := pos
+	if !before && pos < lineEnd(runes, pos) {
+		at++
+	}
+	m.setText(insertRunes(runes, at, text), at+len(text)-1)
+}
+
+// vimInsertAt enters insert mode with the cursor at pos.
+func (m *InputModel) vimInsertAt(runes []rune, pos int) {
+	m.pushUndo(runes, m.cursorOffset(runes))
+	m.vim.mode = vimInsert
+	m.setCursorOffset(runes, pos)
+}
+
+func (m *InputModel) pushUndo(runes []rune, pos int) {
+	m.vim.undo = append(m.vim.undo, vimSnapshot{text: string(runes), pos: pos})
+	if len(m.vim.undo) > vimUndoMaxSize {
+		m.vim.undo = m.vim.undo[1:]
+	}
+}
+
+func (m *InputModel) popUndo() {
+	if len(m.vim.undo) == 0 {
+		return
+	}
+	s := m.vim.undo[len(m.vim.undo)-1]
+	m.vim.undo = m.vim.undo[:len(m.vim.undo)-1]
+	runes := []rune(s.text)
+	m.setText(runes, clampNormal(runes, s.pos))
+}
+
+// setText replaces the text, places the cursor at pos and fits the height.
+func (m *InputModel) setText(runes []rune, pos int) {
+	m.textarea.SetValue(string(runes))
+	m.fitHeight()
+	m.setCursorOffset(runes, pos)
+}
+
+// cursorOffset returns the cursor position as an offset into runes.
+func (m InputModel) cursorOffset(runes []rune) int {
+	row, col := m.textarea.TA.Line(), m.textarea.TA.Column()
+	pos := 0
+	for i := 0; i < row && pos <= len(runes); i++ {
+		pos = lineEnd(runes, pos) + 1
+	}
+	return min(pos+col, len(runes))
+}
+
+// setCursorOffset moves the cursor to offset pos in runes.
+func (m *InputModel) setCursorOffset(runes []rune, pos int) {
+	pos = max(0, min(pos, len(runes)))
+	row := strings.Count(string(runes[:pos]), "\n")
+	m.textarea.SetCursor(row, pos-lineStart(runes, pos))
+}
+
+// vimMotion returns where a motion repeated n times moves the cursor from pos,
+// whether an operator includes the target character and whether it acts on
+// whole lines. ok is false for keys that are not motions.
+func vimMotion(runes []rune, pos int, k string, n int) (target int, inclusive, linewise, ok bool) {
+	switch k {
+	case "h", "left", "backspace":
+		return max(pos-n, lineStart(runes, pos)), false, false, true
+	case "l", "right", "space":
+		return min(pos+n, lineEnd(runes, pos)), false, false, true
+	case "0", "home":
+		return lineStart(runes, pos), false, false, true
+	case "^":
+		return firstNonBlank(runes, pos), false, false, true
+	case "$", "end":
+		return max(lineEnd(runes, pos)-1, lineStart(runes, pos)), true, false, true
+	case "w":
+		for range n {
+			pos = nextWordStart(runes, pos)
+		}
+		return pos, false, false, true
+	case "b":
+		for range n {
+			pos = prevWordStart(runes, pos)
+		}
+		return pos, false, false, true
+	case "e":
+		for range n {
+			pos = wordEnd(runes, pos)
+		}
+		return pos, true, false, true
+	case "j", "down":
+		return lineForward(runes, pos, n), false, true, true
+	case "k", "up":
+		return lineForward(runes, pos, -n), false, true, true
+	case "G":
+		// n is a line number; 0 means the last line.
+		last := strings.Count(string(runes), "\n")
+		row := last
+		if n > 0 {
+			row = min(n-1, last)
+		}
+		return firstNonBlank(runes, lineForward(runes, 0, row)), false, true, true
+	}
+	return pos, false, false, false
+}
+
+// lineForward moves pos by delta lines, keeping the column where the target
+// line is long enough.
+func lineForward(runes []rune, pos, delta int) int {
+	col := pos - lineStart(runes, pos)
+	start := lineStart(runes, pos)
+	for ; delta > 0; delta-- {
+		end := lineEnd(runes, start)
+		if end == len(runes) {
+			break
+		}
+		start = end + 1
+	}
+	for ; delta < 0; delta++ {
+		if start == 0 {
+			break
+		}
+		start = lineStart(runes, start-1)
+	}
+	return min(start+col, lineEnd(runes, start))
+}
+
+// clampNormal keeps pos on a character of its line, as the cursor in normal
+// mode cannot sit past the last one.
+func clampNormal(runes []rune, pos int) int {
+	pos = max(0, min(pos, len(runes)))
+	start, end := lineStart(runes, pos), lineEnd(runes, pos)
+	return max(start, min(pos, end-1))
+}
+
+func lineStart(runes []rune, pos int) int {
+	for pos > 0 && runes[pos-1] != '\n' {
+		pos--
+	}
+	return pos
+}
+
+func lineEnd(runes []rune, pos int) int {
+	for pos < len(runes) && runes[pos] != '\n' {
+		pos++
+	}
+	return pos
+}
+
+func firstNonBlank(runes []rune, pos int) int {
+	pos, end := lineStart(runes, pos), lineEnd(runes, pos)
+	for pos < end && unicode.IsSpace(runes[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// runeClass groups runes into words for w, b and e: blanks, word characters
+// and other symbols.
+func runeClass(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	}
+	return 2
+}
+
+func runeAt(runes []rune, pos int) rune {
+	if pos < len(runes) {
+		return runes[pos]
+	}
+	return ' '
+}
+
+func nextWordStart(runes []rune, pos int) int {
+	if pos >= len(runes) {
+		return len(runes)
+	}
+	if c := runeClass(runes[pos]); c != 0 {
+		for pos < len(runes) && runeClass(runes[pos]) == c {
+			pos++
+		}
+	}
+	for pos < len(runes) && runeClass(runes[pos]) == 0 {
+		pos++
+	}
+	return pos
+}
+
+func prevWordStart(runes []rune, pos int) int {
+	for pos > 0 && runeClass(runes[pos-1]) == 0 {
+		pos--
+	}
+	if pos == 0 {
+		return 0
+	}
+	c := runeClass(runes[pos-1])
+	for pos > 0 && runeClass(runes[pos-1]) == c {
+		pos--
+	}
+	return pos
+}
+
+func wordEnd(runes []rune, pos int) int {
+	pos++
+	for pos < len(runes) && runeClass(runes[pos]) == 0 {
+		pos++
+	}
+	if pos >= len(runes) {
+		return max(len(runes)-1, 0)
+	}
+	c := runeClass(runes[pos])
+	for pos+1 < len(runes) && runeClass(runes[pos+1]) == c {
+		pos++
+	}
+	return pos
+}
+
+func insertRunes(runes []rune, at int, ins []rune) []rune {
+	out := make([]rune, 0, len(runes)+len(ins))
+	out = append(out, runes[:at]...)
+	out = append(out, ins...)
+	return append(out, runes[at:]...)
+}
+
+func deleteRunes(runes []rune, start, end int) []rune {
+	out := make([]rune, 0, len(runes)-(end-start))
+	out = append(out, runes[:start]...)
+	return append(out, runes[end:]...)
+}
@@ -0,0 +1,124 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// normal returns a vim input holding text in normal mode with the cursor at
+// the start.
+func normal(t *testing.T, text string) InputModel {
+	t.Helper()
+	m := newTestInput(t)
+	m.SetVim(true)
+	m.textarea.SetValue(text)
+	m, _ = press(m, "esc")
+	m = typeKeys(m, "gg0")
+	require.Equal(t, vimNormal, m.vim.mode)
+	return m
+}
+
+func cursor(m InputModel) int {
+	return m.cursorOffset([]rune(m.textarea.Value()))
+}
+
+func TestVim_Motions(t *testing.T) {
+	m := normal(t, "foo bar.baz qux\n  second line")
+
+	tests := []struct {
+		keys string
+		want int
+	}{
+		{"w", 4}, {"w", 7}, {"w", 8}, {"e", 10}, {"b", 8}, {"$", 14},
+		{"0", 0}, {"2w", 7}, {"j", 23}, {"^", 18}, {"k", 2}, {"G", 18}, {"gg", 0}, {"3l", 3}, {"h", 2},
+	}
+	for _, tt := range tests {
+		m = typeKeys(m, tt.keys)
+		assert.Equal(t, tt.want, cursor(m), "after %q", tt.keys)
+	}
+}
+
+func TestVim_Edits(t *testing.T) {
+	tests := []struct {
+		text, keys, want string
+	}{
+		{"one two three", "dw", "two three"},
+		{"one two three", "wdw", "one three"},
+		{"one two three", "d2w", "three"},
+		{"one two three", "de", " two three"},
+		{"one two three", "wD", "one "},
+		{"one two three", "x", "ne two three"},
+		{"one two three", "3x", " two three"},
+		{"one two three", "cwzero\x1b", "zero two three"},
+		{"one two three", "wcwtwo!\x1b", "one two! three"},
+		{"a\nb\nc", "jdd", "a\nc"},
+		{"a\nb\nc", "Gdd", "a\nb"},
+		{"a\nb\nc", "2dd", "c"},
+		{"a\nb\nc", "dj", "c"},
+		{"a\nb\nc", "Gdk", "a"},
+		{"a\nb\nc", "yyp", "a\na\nb\nc"},
+		{"a\nb\nc", "jyyP", "a\nb\nb\nc"},
+		{"a\nb\nc", "jddp", "a\nc\nb"},
+		{"one two", "ywP", "one one two"},
+		{"one two", "xp", "noe two"},
+		{"one two", "ofoo\x1b", "one two\nfoo"},
+		{"one two", "Ofoo\x1b", "foo\none two"},
+		{"one two", "A!\x1b", "one two!"},
+		{"  one", "I-\x1b", "  -one"},
+		{"one two", "ccnew\x1b", "new"},
+		{"one two", "wvlld", "one "},
+		{"one two", "vey$p", "one twoone"},
+		{"one two", "dwu", "one two"},
+		{"one two", "dwxuu", "one two"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.keys, func(t *testing.T) {
+			m := normal(t, tt.text)
+			for _, r := range tt.keys {
+				if r == '\x1b' {
+					m, _ = press(m, "esc")
+					continue
+				}
+				m = typeKeys(m, string(r))
+			}
+			assert.Equal(t, tt.want, m.textarea.Value())
+		})
+	}
+}
+
+func TestVim_Modes(t *testing.T) {
+	m := newTestInput(t)
+	m.SetVim(true)
+	assert.True(t, m.WantsEsc(), "insert mode uses Esc")
+	assert.Contains(t, m.statusLine(), "-- INSERT --")
+
+	m = typeKeys(m, "hello")
+	m, _ = press(m, "esc")
+	assert.Equal(t, 4, cursor(m), "leaving insert mode steps back onto the last character")
+	assert.False(t, m.WantsEsc(), "normal mode leaves Esc to the app")
+	assert.Contains(t, m.statusLine(), "-- NORMAL --")
+
+	m = typeKeys(m, "2d")
+	assert.True(t, m.WantsEsc())
+	assert.Contains(t, m.statusLine(), "2d")
+	m, _ = press(m, "esc")
+	assert.Equal(t, "hello", m.textarea.Value())
+
+	m = typeKeys(m, "0vl")
+	assert.Contains(t, m.statusLine(), "-- VISUAL -- 2 selected")
+
+	// Enter submits from normal mode and vim mode restarts in insert mode.
+	m, _ = press(m, "esc")
+	m, cmd := press(m, "enter")
+	require.NotNil(t, cmd)
+	assert.Equal(t, msgs.InputSubmitMsg{Text: "hello"}, cmd())
+	assert.Equal(t, vimInsert, m.vim.mode)
+
+	// k on the first line recalls history.
+	m, _ = press(m, "esc")
+	m = typeKeys(m, "k")
+	assert.Equal(t, "hello", m.textarea.Value())
+}
@@ -0,0 +1,100 @@
+// Package keymap holds the configurable key bindings of the TUI. Bindings
+// default to the keys below and can be overridden per action with the
+// keybindings: config section.
+package keymap
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"charm.land/bubbles/v2/key"
+	tea "charm.land/bubbletea/v2"
+)
+
+// Action names a bindable TUI action.
+type Action string
+
+const (
+	Submit           Action = "submit"            // Send the prompt.
+	Steer            Action = "steer"             // Send the prompt to the running agent at its next step.
+	Newline          Action = "newline"           // Insert a line break.
+	HistoryPrev      Action = "history_prev"      // Previous history entry (on the first line).
+	HistoryNext      Action = "history_next"      // Next history entry (on the last line).
+	HistorySearch    Action = "history_search"    // Reverse incremental history search.
+	OpenEditor       Action = "open_editor"       // Edit the prompt in $VISUAL / $EDITOR.
+	ClearAttachments Action = "clear_attachments" // Drop pending file attachments.
+	ToggleVim        Action = "toggle_vim"        // Switch vim mode on or off.
+	SelectResults    Action = "select_results"    // Select tool results to expand.
+	Menu             Action = "menu"              // Focus the menu bar.
+)
+
+// Defaults maps every action to its default keys. An empty list leaves the
+// action unbound.
+var Defaults = map[Action][]string{
+	Submit:           {"enter"},
+	Steer:            {"ctrl+s"},
+	Newline:          {"alt+enter", "shift+enter"},
+	HistoryPrev:      {"up"},
+	HistoryNext:      {"down"},
+	HistorySearch:    {"ctrl+r"},
+	OpenEditor:       {"ctrl+g"},
+	ClearAttachments: {"ctrl+u"},
+	ToggleVim:        {},
+	SelectResults:    {"ctrl+o"},
+	Menu:             {"ctrl+b"},
+}
+
+// reserved keys cannot be bound: Ctrl+C always quits.
+var reserved = []string{"ctrl+c"}
+
+var bindings = build(nil)
+
+// Configure overrides the keys of the actions in overrides, keyed by action
+// name. It fails on unknown actions and reserved keys and keeps the previous
+// bindings in that case.
+func Configure(overrides map[string][]string) error {
+	for name, keys := range overrides {
+		if _, ok := Defaults[Action(name)]; !ok {
+			return fmt.Errorf("keymap: unknown action %q", name)
+		}
+		for _, k := range keys {
+			if slices.Contains(reserved, strings.ToLower(k)) {
+				return fmt.Errorf("keymap: %s: %q is reserved", name, k)
+			}
+		}
+	}
+	bindings = build(overrides)
+	return nil
+}
+
+func build(overrides map[string][]string) map[Action]key.Binding {
+	b := make(map[Action]key.Binding, len(Defaults))
+	for a, keys := range Defaults {
+		if o, ok := overrides[string(a)]; ok {
+			keys = o
+		}
+		binding := key.NewBinding(key.WithKeys(keys...))
+		if len(keys) == 0 {
+			binding.Unbind()
+		}
+		b[a] = binding
+	}
+	return b
+}
+
+// Binding returns the binding of an action.
+func Binding(a Action) key.Binding { return bindings[a] }
+
+// Matches reports whether msg is bound to a.
+func Matches(msg tea.KeyPressMsg, a Action) bool { return key.Matches(msg, bindings[a]) }
+
+// Keys describes the keys bound to a for help text, e.g. "ctrl+g", or
+// "unbound".
+func Keys(a Action) string {
+	keys := bindings[a].Keys()
+	if len(keys) == 0 {
+		return "unbound"
+	}
+	return strings.Join(keys, ", ")
+}
@@ -0,0 +1,40 @@
+package keymap
+
+import (
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatches_Defaults(t *testing.T) {
+	assert.True(t, Matches(tea.KeyPressMsg{Code: tea.KeyEnter}, Submit))
+	assert.False(t, Matches(tea.KeyPressMsg{Code: tea.KeyEnter, Mod: tea.ModShift}, Submit))
+	assert.True(t, Matches(tea.KeyPressMsg{Code: tea.KeyEnter, Mod: tea.ModShift}, Newline))
+	assert.True(t, Matches(tea.KeyPressMsg{Code: 'r', Mod: tea.ModCtrl}, HistorySearch))
+	assert.False(t, Matches(tea.KeyPressMsg{Code: 'v', Mod: tea.ModCtrl}, ToggleVim), "toggle_vim is unbound by default")
+	assert.Equal(t, "unbound", Keys(ToggleVim))
+}
+
+func TestConfigure(t *testing.T) {
+	t.Cleanup(func() { bindings = build(nil) })
+
+	require.NoError(t, Configure(map[string][]string{
+		"open_editor": {"ctrl+e"},
+		"toggle_vim":  {"ctrl+v", "f2"},
+		"menu":        {},
+	}))
+	assert.True(t, Matches(tea.KeyPressMsg{Code: 'e', Mod: tea.ModCtrl}, OpenEditor))
+	assert.False(t, Matches(tea.KeyPressMsg{Code: 'g', Mod: tea.ModCtrl}, OpenEditor), "overrides replace the defaults")
+	assert.True(t, Matches(tea.KeyPressMsg{Code: tea.KeyF2}, ToggleVim))
+	assert.False(t, Matches(tea.KeyPressMsg{Code: 'b', Mod: tea.ModCtrl}, Menu))
+	assert.True(t, Matches(tea.KeyPressMsg{Code: 'r', Mod: tea.ModCtrl}, HistorySearch), "other actions keep their defaults")
+	assert.Equal(t, "ctrl+v, f2", Keys(ToggleVim))
+
+	err := Configure(map[string][]string{"launch": {"ctrl+l"}})
+	require.ErrorContains(t, err, `unknown action "launch"`)
+	err = Configure(map[string][]string{"submit": {"ctrl+c"}})
+	require.ErrorContains(t, err, `"ctrl+c" is reserved`)
+	assert.True(t, Matches(tea.KeyPressMsg{Code: 'e', Mod: tea.ModCtrl}, OpenEditor), "a failed Configure keeps the previous bindings")
+}
@@ -0,0 +1,104 @@
+package memorypanel
+
+import (
+	"strings"
+
+	"github.com/germanamz/shelly/cmd/shelly/internal/list"
+	"github.com/germanamz/shelly/cmd/shelly/internal/panel"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/memory"
+	"github.com/mattn/go-runewidth"
+)
+
+// PanelID identifies the memory panel in message routing.
+const PanelID = "memory"
+
+// Model displays the long-term memories (see memory.Store), most recently
+// updated first, as a selectable list. The app shows and forgets the selected
+// memory through the store.
+type Model struct {
+	panel   panel.Model
+	list    list.Model
+	entries []memory.Entry
+	width   int
+}
+
+// New creates a new memory panel Model.
+func New() Model {
+	return Model{
+		panel: panel.New(PanelID, "Memory"),
+		list:  list.New(PanelID, true),
+	}
+}
+
+// Active returns whether the panel is open.
+func (m Model) Active() bool { return m.panel.Active() }
+
+// SetActive opens or closes the panel.
+func (m *Model) SetActive(active bool) { m.panel.SetActive(active) }
+
+// SetSize updates the panel and list dimensions.
+func (m *Model) SetSize(width, height int) {
+	m.width = width
+	m.panel.SetSize(width, height)
+	m.list.SetSize(width-2, m.panel.ContentHeight()) // -2 for borders
+	m.rebuildList()
+}
+
+// Height returns the panel's total height (including borders).
+// Returns 0 when inactive.
+func (m Model) Height() int {
+	if !m.panel.Active() {
+		return 0
+	}
+	return m.panel.Height()
+}
+
+// SetEntries replaces the listed memories. The cursor stays on the selected
+// memory when it is still listed.
+func (m *Model) SetEntries(entries []memory.Entry) {
+	m.entries = entries
+	m.rebuildList()
+}
+
+// Len returns the number of listed memories.
+func (m Model) Len() int { return len(m.entries) }
+
+// MoveUp moves the cursor up in the list.
+func (m *Model) MoveUp() { m.list.MoveUp() }
+
+// MoveDown moves the cursor down in the list.
+func (m *Model) MoveDown() { m.list.MoveDown() }
+
+// Selected returns the memory under the cursor.
+func (m Model) Selected() (memory.Entry, bool) {
+	sel := m.list.Select()
+	if sel == nil {
+		return memory.Entry{}, false
+	}
+	for _, e := range m.entries {
+		if e.ID == sel.ItemID {
+			return e, true
+		}
+	}
+	return memory.Entry{}, false
+}
+
+// View renders the panel with the list content.
+func (m Model) View() string {
+	return m.panel.View(m.list.View())
+}
+
+func (m *Model) rebuildList() {
+	items := make([]list.Item, len(m.entries))
+	for i, e := range m.entries {
+		detail := string(e.Kind)
+		label := strings.ReplaceAll(e.Content, "\n", " ⏎ ")
+		// Borders, cursor, icon and detail share the line with the label;
+		// leave a column for icons drawn double-width.
+		if w := m.width - 2 - 5 - runewidth.StringWidth(detail) - 1; w > 0 {
+			label = runewidth.Truncate(label, w, "…")
+		}
+		items[i] = list.Item{ID: e.ID, Label: label, Detail: detail, Status: list.StatusNone}
+	}
+	m.list.SetItems(items)
+}
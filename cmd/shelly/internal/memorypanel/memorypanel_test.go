@@ -0,0 +1,40 @@
+package memorypanel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/codingtoolbox/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModel_Entries(t *testing.T) {
+	m := New()
+	m.SetActive(true)
+	m.SetSize(60, 6)
+
+	_, ok := m.Selected()
+	assert.False(t, ok)
+
+	m.SetEntries([]memory.Entry{
+		{ID: "m2", Kind: memory.KindPreference, Content: "Reply in Spanish"},
+		{ID: "m1", Kind: memory.KindProcedure, Content: strings.Repeat("step ", 20)},
+	})
+	assert.Equal(t, 2, m.Len())
+
+	view := m.View()
+	assert.Contains(t, view, "Reply in Spanish")
+	assert.Contains(t, view, "preference")
+	assert.Contains(t, view, "…", "long memories are truncated to the panel width")
+
+	m.MoveDown()
+	e, ok := m.Selected()
+	require.True(t, ok)
+	assert.Equal(t, "m1", e.ID)
+
+	// The cursor follows the selected memory when the list is reloaded.
+	m.SetEntries([]memory.Entry{{ID: "m1", Kind: memory.KindProcedure}})
+	e, _ = m.Selected()
+	assert.Equal(t, "m1", e.ID)
+}
@@ -4,9 +4,11 @@ import (
 	"time"
 
 	tea "charm.land/bubbletea/v2"
+	"github.com/germanamz/shelly/pkg/budget"
 	"github.com/germanamz/shelly/pkg/chats/content"
 	"github.com/germanamz/shelly/pkg/chats/message"
 	"github.com/germanamz/shelly/pkg/codingtoolbox/ask"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/filesystem"
 	"github.com/germanamz/shelly/pkg/modeladapter/usage"
 	"github.com/germanamz/shelly/pkg/sessions"
 	"github.com/germanamz/shelly/pkg/tasks"
@@ -49,12 +51,19 @@ type AskUserMsg struct {
 	Agent    string
 }
 
+// FileReviewMsg delivers a file change awaiting review.
+type FileReviewMsg struct {
+	Request filesystem.ReviewRequest
+	Agent   string
+}
+
 // --- Internal messages ---
 
 // InputSubmitMsg carries the text the user submitted from the input box.
 type InputSubmitMsg struct {
-	Text  string
-	Parts []content.Part // non-text parts (images, documents)
+	Text      string
+	Parts     []content.Part // non-text parts (images, documents)
+	Interrupt bool           // steer the running agent instead of waiting for its turn to end
 }
 
 // SendCompleteMsg is returned by the tea.Cmd that calls sess.Send.
@@ -62,6 +71,22 @@ type SendCompleteMsg struct {
 	Err        error
 	Duration   time.Duration
 	Generation uint64
+	Reply      string // Text of the final assistant reply.
+}
+
+// SpeakDoneMsg is returned by the tea.Cmd that reads a reply aloud.
+type SpeakDoneMsg struct {
+	Err error
+}
+
+// CommandExpandedMsg is returned by the tea.Cmd that expands a user-defined
+// slash command into its prompt.
+type CommandExpandedMsg struct {
+	Label        string   // The command line as typed, e.g. "/review auth".
+	Prompt       string   // Expanded prompt to send.
+	AllowedTools []string // Tools the agent may use for this send; empty = all.
+	Generation   uint64
+	Err          error
 }
 
 // CompactCompleteMsg is returned by the tea.Cmd that calls sess.Compact.
@@ -97,11 +122,30 @@ type AskAnswer struct {
 	Response   string
 }
 
+// FileReviewDecidedMsg is sent when the user finishes reviewing a file change.
+type FileReviewDecidedMsg struct {
+	ID       string
+	Decision filesystem.Decision
+}
+
 // RespondErrorMsg is sent when a sess.Respond call fails asynchronously.
 type RespondErrorMsg struct {
 	Err error
 }
 
+// BudgetWarningMsg is sent when spend crosses a budget's warning threshold.
+type BudgetWarningMsg struct {
+	Agent string
+	Scope budget.Scope
+}
+
+// QueueChangedMsg is sent when a session's prompt queue changes. Injected
+// holds the interrupt prompts just delivered to the running agent.
+type QueueChangedMsg struct {
+	SessionID string
+	Injected  []sessions.QueuedPrompt
+}
+
 // SubAgentSendErrorMsg is sent when routing a message to a sub-agent fails.
 type SubAgentSendErrorMsg struct {
 	AgentID string
@@ -152,8 +196,10 @@ type CmdPickerQueryMsg struct {
 }
 
 // CmdPickerSelectionMsg carries the selected command from the command picker.
+// Insert places the command in the input for arguments instead of submitting.
 type CmdPickerSelectionMsg struct {
 	Command string
+	Insert  bool
 }
 
 // --- Session picker messages ---
@@ -171,6 +217,30 @@ type SessionPickerSelectionMsg struct {
 	ID string
 }
 
+// --- Choice picker messages ---
+
+// Choice is one option of the choice picker.
+type Choice struct {
+	ID      string
+	Label   string
+	Detail  string
+	Current bool // Marked as the current selection.
+}
+
+// ChoicePickerActivateMsg opens the choice picker. Kind identifies what is
+// being chosen and is echoed in the selection.
+type ChoicePickerActivateMsg struct {
+	Kind    string
+	Title   string
+	Choices []Choice
+}
+
+// ChoicePickerSelectionMsg carries the chosen option.
+type ChoicePickerSelectionMsg struct {
+	Kind string
+	ID   string
+}
+
 // --- ChatView messages ---
 
 // ChatViewSetWidthMsg sets the render width.
@@ -239,6 +309,12 @@ type InputSetWidthMsg struct {
 	Width int
 }
 
+// InputEditorDoneMsg carries the prompt edited in the external editor.
+type InputEditorDoneMsg struct {
+	Text string
+	Err  error
+}
+
 // InputSetTokenCountMsg updates the token counter display.
 type InputSetTokenCountMsg struct {
 	TokenCount string
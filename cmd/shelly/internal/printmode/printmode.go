@@ -0,0 +1,385 @@
+// Package printmode runs a single prompt without the TUI. Engine events are
+// streamed as progress lines or NDJSON, ask_user questions are answered by a
+// fixed policy, and the outcome maps to a process exit code.
+package printmode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/germanamz/shelly/cmd/shelly/internal/format"
+	"github.com/germanamz/shelly/pkg/agent"
+	"github.com/germanamz/shelly/pkg/budget"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/ask"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/filesystem"
+	"github.com/germanamz/shelly/pkg/engine"
+)
+
+// Output formats.
+const (
+	OutputText = "text" // Reply on stdout, progress on stderr.
+	OutputJSON = "json" // One JSON object per event on stdout, then a result object.
+)
+
+// Policies for ask_user questions. They also decide file change reviews:
+// AskDeny rejects the change, AskFirst accepts it and AskFail stops the run.
+const (
+	AskDeny  = "deny"  // Tell the agent nobody can answer.
+	AskFirst = "first" // Pick the first option; free-form questions are denied.
+	AskFail  = "fail"  // Stop the run with ExitAskUser.
+)
+
+// Exit codes.
+const (
+	ExitOK          = 0
+	ExitError       = 1   // Config, engine or agent error.
+	ExitUsage       = 2   // Invalid flags or an empty prompt.
+	ExitAskUser     = 3   // An agent asked a question under AskFail.
+	ExitBudget      = 4   // A spend budget stopped the run.
+	ExitInterrupted = 130 // SIGINT or SIGTERM.
+)
+
+// ErrAskUser stops a run when an agent asks a question under AskFail.
+var ErrAskUser = errors.New("printmode: an agent asked a question and --on-ask is fail")
+
+// denyAnswer is given to questions that are not answered by an option.
+const denyAnswer = "No user is available to answer: this is a non-interactive run. Proceed with your best judgement, or stop and explain what you need."
+
+// eventBuffer is large enough that a busy delegation tree does not drop events.
+const eventBuffer = 1024
+
+// Options configures Run.
+type Options struct {
+	Output string    // OutputText (default) or OutputJSON.
+	OnAsk  string    // AskDeny (default), AskFirst or AskFail.
+	Stdout io.Writer // Reply or NDJSON events.
+	Stderr io.Writer // Progress lines in text mode.
+}
+
+// Validate checks the output format and ask policy.
+func (o Options) Validate() error {
+	switch o.Output {
+	case "", OutputText, OutputJSON:
+	default:
+		return fmt.Errorf("printmode: unknown output %q (want %s or %s)", o.Output, OutputText, OutputJSON)
+	}
+	switch o.OnAsk {
+	case "", AskDeny, AskFirst, AskFail:
+	default:
+		return fmt.Errorf("printmode: unknown --on-ask policy %q (want %s, %s or %s)", o.OnAsk, AskDeny, AskFirst, AskFail)
+	}
+	return nil
+}
+
+// Run sends prompt to sess and streams the session's events until the reply
+// arrives. In text mode the reply is written to Stdout; in JSON mode a final
+// "result" object carries it. Failed runs are saved so they can be resumed.
+func Run(ctx context.Context, eng *engine.Engine, sess *engine.Session, prompt string, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+
+	r := &runner{sess: sess, opts: opts, stop: stop, enc: json.NewEncoder(opts.Stdout)}
+	sub := eng.Events().Subscribe(eventBuffer)
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for ev := range sub.C {
+			if ev.SessionID == sess.ID() {
+				r.handle(ev)
+			}
+		}
+	})
+
+	reply, err := sess.Send(ctx, prompt)
+
+	// Unsubscribe closes the channel; buffered events are still drained.
+	eng.Events().Unsubscribe(sub)
+	wg.Wait()
+
+	if err != nil {
+		_ = eng.SaveSession(sess)
+	}
+	return r.finish(reply, err)
+}
+
+// ExitCode maps the error returned by Run to a process exit code.
+func ExitCode(err error) int {
+	var exceeded *budget.ExceededError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, ErrAskUser):
+		return ExitAskUser
+	case errors.As(err, &exceeded), errors.Is(err, engine.ErrBudgetStopped):
+		return ExitBudget
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	default:
+		return ExitError
+	}
+}
+
+// runner renders the events of one run.
+type runner struct {
+	sess *engine.Session
+	opts Options
+	stop context.CancelCauseFunc
+	enc  *json.Encoder
+}
+
+func (r *runner) json() bool { return r.opts.Output == OutputJSON }
+
+// handle answers questions and renders one event.
+func (r *runner) handle(ev engine.Event) {
+	var answer string
+	if q, ok := ev.Data.(ask.Question); ok && ev.Kind == engine.EventAskUser {
+		answer = r.answer(q)
+	}
+	if req, ok := ev.Data.(filesystem.ReviewRequest); ok && ev.Kind == engine.EventFileReview {
+		answer = r.review(req)
+	}
+
+	if r.json() {
+		_ = r.enc.Encode(jsonEvent{
+			Type:      string(ev.Kind),
+			SessionID: r.sess.PersistID(),
+			Agent:     ev.Agent,
+			Time:      ev.Timestamp,
+			Data:      eventData(ev, answer),
+		})
+		return
+	}
+
+	if line := r.textLine(ev, answer); line != "" {
+		fmt.Fprintln(r.opts.Stderr, line)
+	}
+}
+
+// answer applies the ask policy to q and returns the answer given, or "" when
+// the run was stopped instead.
+func (r *runner) answer(q ask.Question) string {
+	answer := denyAnswer
+	switch r.opts.OnAsk {
+	case AskFail:
+		r.stop(ErrAskUser)
+		return ""
+	case AskFirst:
+		if len(q.Options) > 0 {
+			answer = q.Options[0]
+		}
+	}
+	_ = r.sess.Respond(q.ID, answer)
+	return answer
+}
+
+// review applies the ask policy to a file change review and returns the
+// verdict given ("accepted" or "rejected"), or "" when the run was stopped.
+func (r *runner) review(req filesystem.ReviewRequest) string {
+	switch r.opts.OnAsk {
+	case AskFail:
+		r.stop(ErrAskUser)
+		return ""
+	case AskFirst:
+		_ = r.sess.RespondReview(req.ID, filesystem.AcceptAll(req.Change))
+		return "accepted"
+	}
+	_ = r.sess.RespondReview(req.ID, filesystem.RejectAll(req.Change, denyAnswer))
+	return "rejected"
+}
+
+// textLine renders an event as a progress line, or "" to skip it. The
+// session agent's final reply is not repeated here; it goes to stdout.
+func (r *runner) textLine(ev engine.Event, answer string) string {
+	prefix := "[" + ev.Agent + "] "
+
+	switch ev.Kind {
+	case engine.EventMessageAdded:
+		d, ok := ev.Data.(agent.MessageAddedEventData)
+		if !ok {
+			return ""
+		}
+		var lines []string
+		calls := d.Message.ToolCalls()
+		if text := strings.TrimSpace(d.Message.TextContent()); text != "" && d.Message.Role == role.Assistant &&
+			(len(calls) > 0 || ev.Agent != r.sess.AgentName()) {
+			lines = append(lines, prefix+format.Truncate(text, 200))
+		}
+		for _, tc := range calls {
+			lines = append(lines, prefix+"→ "+format.FormatToolCall(tc.Name, tc.Arguments))
+		}
+		for _, p := range d.Message.Parts {
+			if tr, ok := p.(content.ToolResult); ok && tr.IsError {
+				lines = append(lines, prefix+"✗ "+format.Truncate(tr.Content, 200))
+			}
+		}
+		return strings.Join(lines, "\n")
+
+	case engine.EventAgentStart:
+		if d, ok := ev.Data.(agent.AgentEventData); ok && d.Parent != "" {
+			return prefix + "started by " + d.Parent + ": " + format.Truncate(d.Task, 120)
+		}
+	case engine.EventAgentEnd:
+		if d, ok := ev.Data.(agent.AgentEventData); ok && d.Parent != "" {
+			return prefix + "finished"
+		}
+	case engine.EventAskUser:
+		q, _ := ev.Data.(ask.Question)
+		if answer == "" {
+			return prefix + "asked: " + q.Text + " (stopping: --on-ask fail)"
+		}
+		return prefix + "asked: " + q.Text + " → " + format.Truncate(answer, 60)
+	case engine.EventFileReview:
+		req, _ := ev.Data.(filesystem.ReviewRequest)
+		if answer == "" {
+			return prefix + "review: " + req.Change.Path + " (stopping: --on-ask fail)"
+		}
+		return prefix + "review: " + req.Change.Path + " → " + answer
+	case engine.EventError:
+		return prefix + "error: " + fmt.Sprint(ev.Data)
+	case engine.EventBudgetWarning:
+		return "budget warning: " + fmt.Sprint(ev.Data)
+	case engine.EventCompaction:
+		return prefix + "context compacted"
+	}
+	return ""
+}
+
+// finish writes the reply (text) or the result object (JSON).
+func (r *runner) finish(reply message.Message, err error) error {
+	if r.json() {
+		res := jsonResult{
+			Type:      "result",
+			SessionID: r.sess.PersistID(),
+			Agent:     r.sess.AgentName(),
+			Reply:     reply.TextContent(),
+			ExitCode:  ExitCode(err),
+		}
+		if err != nil {
+			res.Error = err.Error()
+		}
+		total, cost := r.sess.UsageTotal()
+		res.Usage = &jsonUsage{InputTokens: total.InputTokens, OutputTokens: total.OutputTokens}
+		res.CostUSD = cost
+		if encErr := r.enc.Encode(res); encErr != nil && err == nil {
+			return fmt.Errorf("printmode: %w", encErr)
+		}
+		return err
+	}
+
+	if err != nil {
+		fmt.Fprintf(r.opts.Stderr, "Session %s saved; continue it with --resume %s\n", r.sess.PersistID(), r.sess.PersistID())
+		return err
+	}
+	if text := reply.TextContent(); text != "" {
+		fmt.Fprintln(r.opts.Stdout, strings.TrimRight(text, "\n"))
+	}
+	return nil
+}
+
+// jsonEvent is one NDJSON line.
+type jsonEvent struct {
+	Type      string    `json:"type"`
+	SessionID string    `json:"session_id"`
+	Agent     string    `json:"agent,omitempty"`
+	Time      time.Time `json:"time"`
+	Data      any       `json:"data,omitempty"`
+}
+
+// jsonResult is the last NDJSON line of a run.
+type jsonResult struct {
+	Type      string     `json:"type"`
+	SessionID string     `json:"session_id"`
+	Agent     string     `json:"agent"`
+	Reply     string     `json:"reply"`
+	ExitCode  int        `json:"exit_code"`
+	Error     string     `json:"error,omitempty"`
+	Usage     *jsonUsage `json:"usage,omitempty"`
+	CostUSD   float64    `json:"cost_usd,omitempty"`
+}
+
+type jsonUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type jsonMessage struct {
+	Role        string           `json:"role"`
+	Text        string           `json:"text,omitempty"`
+	ToolCalls   []jsonToolCall   `json:"tool_calls,omitempty"`
+	ToolResults []jsonToolResult `json:"tool_results,omitempty"`
+}
+
+type jsonToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type jsonToolResult struct {
+	CallID  string `json:"call_id"`
+	Content string `json:"content"`
+	IsError bool   `json:"is_error,omitempty"`
+}
+
+type jsonAgent struct {
+	Parent   string `json:"parent,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	Task     string `json:"task,omitempty"`
+	Summary  string `json:"summary,omitempty"`
+}
+
+type jsonAsk struct {
+	Question ask.Question `json:"question"`
+	Answer   string       `json:"answer,omitempty"` // Empty when the run was stopped.
+}
+
+type jsonReview struct {
+	Tool    string `json:"tool"`
+	Path    string `json:"path"`
+	Diff    string `json:"diff"`
+	Verdict string `json:"verdict,omitempty"` // Empty when the run was stopped.
+}
+
+// eventData converts event payloads to JSON-friendly values.
+func eventData(ev engine.Event, answer string) any {
+	switch d := ev.Data.(type) {
+	case agent.MessageAddedEventData:
+		m := jsonMessage{Role: d.Role, Text: d.Message.TextContent()}
+		for _, p := range d.Message.Parts {
+			switch v := p.(type) {
+			case content.ToolCall:
+				m.ToolCalls = append(m.ToolCalls, jsonToolCall{ID: v.ID, Name: v.Name, Arguments: v.Arguments})
+			case content.ToolResult:
+				m.ToolResults = append(m.ToolResults, jsonToolResult{CallID: v.ToolCallID, Content: v.Content, IsError: v.IsError})
+			}
+		}
+		return m
+	case agent.AgentEventData:
+		return jsonAgent{Parent: d.Parent, Provider: d.ProviderLabel, Task: d.Task, Summary: d.Summary}
+	case ask.Question:
+		return jsonAsk{Question: d, Answer: answer}
+	case filesystem.ReviewRequest:
+		return jsonReview{Tool: d.Change.Tool, Path: d.Change.Path, Diff: d.Change.Diff(), Verdict: answer}
+	case error:
+		return map[string]string{"error": d.Error()}
+	case nil:
+		return nil
+	}
+	if _, err := json.Marshal(ev.Data); err != nil {
+		return fmt.Sprint(ev.Data)
+	}
+	return ev.Data
+}
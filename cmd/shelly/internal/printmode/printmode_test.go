@@ -0,0 +1,184 @@
+package printmode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/germanamz/shelly/pkg/budget"
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/filesystem"
+	"github.com/germanamz/shelly/pkg/engine"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+)
+
+// scriptedCompleter asks one question with ask_user, then replies with the
+// answer it received.
+type scriptedCompleter struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (c *scriptedCompleter) Complete(_ context.Context, ch *chat.Chat, _ []toolbox.Tool) (message.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+
+	if c.calls == 1 {
+		return message.New("bot", role.Assistant,
+			content.Text{Text: "Let me check."},
+			content.ToolCall{ID: "c1", Name: "ask_user", Arguments: `{"question":"Which branch?","options":["main","dev"]}`},
+		), nil
+	}
+
+	last := ch.Messages()[ch.Len()-1]
+	for _, p := range last.Parts {
+		if tr, ok := p.(content.ToolResult); ok {
+			return message.NewText("bot", role.Assistant, "answer: "+tr.Content), nil
+		}
+	}
+	return message.NewText("bot", role.Assistant, "no answer"), nil
+}
+
+func newSession(t *testing.T) (*engine.Engine, *engine.Session) {
+	t.Helper()
+	engine.RegisterProvider("printmode-mock", func(_ engine.ProviderConfig) (modeladapter.Completer, error) {
+		return &scriptedCompleter{}, nil
+	})
+
+	eng, err := engine.New(context.Background(), engine.Config{
+		ShellyDir: t.TempDir(),
+		Providers: []engine.ProviderConfig{{Name: "p1", Kind: "printmode-mock"}},
+		Agents:    []engine.AgentConfig{{Name: "bot", Provider: "p1"}},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = eng.Close() })
+
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+	return eng, sess
+}
+
+func TestRun_Text(t *testing.T) {
+	eng, sess := newSession(t)
+	var stdout, stderr bytes.Buffer
+
+	err := Run(context.Background(), eng, sess, "deploy", Options{OnAsk: AskFirst, Stdout: &stdout, Stderr: &stderr})
+	require.NoError(t, err)
+
+	assert.Equal(t, "answer: main\n", stdout.String(), "only the reply goes to stdout")
+	assert.Contains(t, stderr.String(), "[bot] Let me check.")
+	assert.Contains(t, stderr.String(), "[bot] asked: Which branch? → main")
+}
+
+func TestRun_Deny(t *testing.T) {
+	eng, sess := newSession(t)
+	var stdout bytes.Buffer
+
+	require.NoError(t, Run(context.Background(), eng, sess, "deploy", Options{Stdout: &stdout, Stderr: &bytes.Buffer{}}))
+	assert.Equal(t, "answer: "+denyAnswer+"\n", stdout.String())
+}
+
+func TestRun_JSON(t *testing.T) {
+	eng, sess := newSession(t)
+	var stdout bytes.Buffer
+
+	err := Run(context.Background(), eng, sess, "deploy", Options{Output: OutputJSON, OnAsk: AskFirst, Stdout: &stdout})
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	var types []string
+	for _, l := range lines {
+		var ev map[string]any
+		require.NoError(t, json.Unmarshal([]byte(l), &ev), l)
+		assert.Equal(t, sess.PersistID(), ev["session_id"])
+		types = append(types, ev["type"].(string))
+	}
+	assert.Contains(t, types, string(engine.EventAskUser))
+	assert.Contains(t, types, string(engine.EventToolCallStart))
+
+	var res jsonResult
+	require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &res))
+	assert.Equal(t, "result", res.Type)
+	assert.Equal(t, "answer: main", res.Reply)
+	assert.Equal(t, ExitOK, res.ExitCode)
+}
+
+func TestRun_AskFail(t *testing.T) {
+	eng, sess := newSession(t)
+	var stdout, stderr bytes.Buffer
+
+	err := Run(context.Background(), eng, sess, "deploy", Options{OnAsk: AskFail, Stdout: &stdout, Stderr: &stderr})
+	require.ErrorIs(t, err, ErrAskUser)
+	assert.Equal(t, ExitAskUser, ExitCode(err))
+	assert.Empty(t, stdout.String())
+	assert.Contains(t, stderr.String(), "--resume "+sess.PersistID())
+
+	_, _, err = eng.SessionStore().Load(sess.PersistID())
+	assert.NoError(t, err, "failed runs are saved for --resume")
+}
+
+// writeCompleter writes the prompt's path with fs_write, then replies with the
+// tool result.
+type writeCompleter struct{}
+
+func (writeCompleter) Complete(_ context.Context, ch *chat.Chat, _ []toolbox.Tool) (message.Message, error) {
+	last := ch.Messages()[ch.Len()-1]
+	if last.Role == role.Tool {
+		return message.NewText("bot", role.Assistant, "wrote: "+last.Parts[0].(content.ToolResult).Content), nil
+	}
+	args, _ := json.Marshal(map[string]string{"path": last.TextContent(), "content": "hi\n"})
+	return message.New("bot", role.Assistant, content.ToolCall{ID: "c1", Name: "fs_write", Arguments: string(args)}), nil
+}
+
+func TestRun_ReviewAccepted(t *testing.T) {
+	engine.RegisterProvider("printmode-write", func(_ engine.ProviderConfig) (modeladapter.Completer, error) {
+		return writeCompleter{}, nil
+	})
+	eng, err := engine.New(context.Background(), engine.Config{
+		ShellyDir:  filepath.Join(t.TempDir(), ".shelly"),
+		Providers:  []engine.ProviderConfig{{Name: "p1", Kind: "printmode-write"}},
+		Agents:     []engine.AgentConfig{{Name: "bot", Provider: "p1", Toolboxes: []engine.ToolboxRef{{Name: "filesystem"}}}},
+		Filesystem: engine.FilesystemConfig{Review: filesystem.ReviewEach},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = eng.Close() })
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "out.txt")
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, Run(context.Background(), eng, sess, path, Options{OnAsk: AskFirst, Stdout: &stdout, Stderr: &stderr}))
+
+	assert.Equal(t, "wrote: ok\n", stdout.String())
+	assert.Contains(t, stderr.String(), "[bot] review: "+path+" → accepted")
+	data, err := os.ReadFile(path) //nolint:gosec // test file
+	require.NoError(t, err)
+	assert.Equal(t, "hi\n", string(data))
+}
+
+func TestOptions_Validate(t *testing.T) {
+	assert.NoError(t, Options{}.Validate())
+	assert.ErrorContains(t, Options{Output: "yaml"}.Validate(), `unknown output "yaml"`)
+	assert.ErrorContains(t, Options{OnAsk: "always"}.Validate(), `unknown --on-ask policy "always"`)
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, ExitOK, ExitCode(nil))
+	assert.Equal(t, ExitBudget, ExitCode(&budget.ExceededError{}))
+	assert.Equal(t, ExitBudget, ExitCode(engine.ErrBudgetStopped))
+	assert.Equal(t, ExitInterrupted, ExitCode(context.Canceled))
+	assert.Equal(t, ExitError, ExitCode(assert.AnError))
+}
@@ -0,0 +1,112 @@
+package queuepanel
+
+import (
+	"strings"
+
+	"github.com/germanamz/shelly/cmd/shelly/internal/list"
+	"github.com/germanamz/shelly/cmd/shelly/internal/panel"
+	"github.com/germanamz/shelly/pkg/sessions"
+	"github.com/mattn/go-runewidth"
+)
+
+// PanelID identifies the queue panel in menu bar and message routing.
+const PanelID = "queue"
+
+// Model displays the session's queued prompts (see engine.Session.Queue) in
+// delivery order as a selectable list. The app edits, reorders and removes
+// the selected prompt through the session.
+type Model struct {
+	panel panel.Model
+	list  list.Model
+	queue []sessions.QueuedPrompt
+	width int
+}
+
+// New creates a new queue panel Model.
+func New() Model {
+	return Model{
+		panel: panel.New(PanelID, "Queue"),
+		list:  list.New(PanelID, true),
+	}
+}
+
+// Active returns whether the panel is open.
+func (m Model) Active() bool { return m.panel.Active() }
+
+// SetActive opens or closes the panel.
+func (m *Model) SetActive(active bool) { m.panel.SetActive(active) }
+
+// SetSize updates the panel and list dimensions.
+func (m *Model) SetSize(width, height int) {
+	m.width = width
+	m.panel.SetSize(width, height)
+	m.list.SetSize(width-2, m.panel.ContentHeight()) // -2 for borders
+	m.rebuildList()
+}
+
+// Height returns the panel's total height (including borders).
+// Returns 0 when inactive.
+func (m Model) Height() int {
+	if !m.panel.Active() {
+		return 0
+	}
+	return m.panel.Height()
+}
+
+// SetQueue replaces the queued prompts. The cursor stays on the selected
+// prompt when it moves.
+func (m *Model) SetQueue(q []sessions.QueuedPrompt) {
+	m.queue = q
+	m.rebuildList()
+}
+
+// Len returns the number of queued prompts.
+func (m Model) Len() int { return len(m.queue) }
+
+// MoveUp moves the cursor up in the list.
+func (m *Model) MoveUp() { m.list.MoveUp() }
+
+// MoveDown moves the cursor down in the list.
+func (m *Model) MoveDown() { m.list.MoveDown() }
+
+// Selected returns the prompt under the cursor.
+func (m Model) Selected() (sessions.QueuedPrompt, bool) {
+	sel := m.list.Select()
+	if sel == nil {
+		return sessions.QueuedPrompt{}, false
+	}
+	for _, p := range m.queue {
+		if p.ID == sel.ItemID {
+			return p, true
+		}
+	}
+	return sessions.QueuedPrompt{}, false
+}
+
+// View renders the panel with the list content.
+func (m Model) View() string {
+	return m.panel.View(m.list.View())
+}
+
+func (m *Model) rebuildList() {
+	items := make([]list.Item, len(m.queue))
+	for i, p := range m.queue {
+		detail := ModeLabel(p.Mode)
+		label := strings.ReplaceAll(p.Text, "\n", " ⏎ ")
+		// Borders, cursor, icon and detail share the line with the label;
+		// leave a column for icons drawn double-width.
+		if w := m.width - 2 - 5 - runewidth.StringWidth(detail) - 1; w > 0 {
+			label = runewidth.Truncate(label, w, "…")
+		}
+		items[i] = list.Item{ID: p.ID, Label: label, Detail: detail, Status: list.StatusPending}
+	}
+	m.list.SetItems(items)
+}
+
+// ModeLabel describes when a prompt of the given mode is delivered.
+func ModeLabel(mode sessions.QueueMode) string {
+	if mode == sessions.QueueInterrupt {
+		return "interrupt"
+	}
+	return "after turn"
+}
@@ -0,0 +1,43 @@
+package queuepanel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModel_Queue(t *testing.T) {
+	m := New()
+	m.SetActive(true)
+	m.SetSize(60, 6)
+
+	_, ok := m.Selected()
+	assert.False(t, ok)
+
+	m.SetQueue([]sessions.QueuedPrompt{
+		{ID: "q1", Text: "first line\nsecond line", Mode: sessions.QueueAfterTurn},
+		{ID: "q2", Text: strings.Repeat("long ", 20), Mode: sessions.QueueInterrupt},
+	})
+	assert.Equal(t, 2, m.Len())
+
+	view := m.View()
+	assert.Contains(t, view, "first line ⏎ second line")
+	assert.Contains(t, view, "interrupt")
+	assert.Contains(t, view, "…", "long prompts are truncated to the panel width")
+
+	m.MoveDown()
+	p, ok := m.Selected()
+	require.True(t, ok)
+	assert.Equal(t, "q2", p.ID)
+
+	// The cursor follows the selected prompt when the queue is reordered.
+	m.SetQueue([]sessions.QueuedPrompt{
+		{ID: "q2", Mode: sessions.QueueInterrupt},
+		{ID: "q1", Mode: sessions.QueueAfterTurn},
+	})
+	p, _ = m.Selected()
+	assert.Equal(t, "q2", p.ID)
+}
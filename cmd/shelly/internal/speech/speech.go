@@ -0,0 +1,98 @@
+// Package speech plays synthesized assistant replies through an external
+// audio player.
+package speech
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/germanamz/shelly/pkg/chats/content"
+)
+
+// defaultPlayers are tried in order when no player is configured. Each plays
+// the file given as its last argument and exits.
+var defaultPlayers = [][]string{
+	{"afplay"},
+	{"ffplay", "-nodisp", "-autoexit", "-loglevel", "quiet"},
+	{"mpv", "--no-video", "--really-quiet"},
+	{"mpg123", "-q"},
+}
+
+// lookPath finds executables; tests replace it.
+var lookPath = defaultLookPath
+
+func defaultLookPath(file string) (string, error) { return exec.LookPath(file) }
+
+// Player resolves the player command line. A configured player is split on
+// whitespace; an empty one picks the first default player found on PATH.
+func Player(configured string) ([]string, error) {
+	if fields := strings.Fields(configured); len(fields) > 0 {
+		return fields, nil
+	}
+	for _, p := range defaultPlayers {
+		if _, err := lookPath(p[0]); err == nil {
+			return p, nil
+		}
+	}
+	return nil, errors.New("speech: no audio player found (install ffplay, mpv or mpg123, or set speech.player)")
+}
+
+// Play writes audio to a temporary file and plays it with player, blocking
+// until playback ends or ctx is cancelled.
+func Play(ctx context.Context, player []string, audio content.Audio) error {
+	f, err := os.CreateTemp("", "shelly-speech-*"+extension(audio.MediaType))
+	if err != nil {
+		return fmt.Errorf("speech: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err := f.Write(audio.Data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("speech: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("speech: %w", err)
+	}
+
+	args := append(append([]string{}, player[1:]...), f.Name())
+	cmd := exec.CommandContext(ctx, player[0], args...) //nolint:gosec // player comes from the user's config or a fixed list.
+	if err := cmd.Run(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("speech: %s: %w", player[0], err)
+	}
+	return nil
+}
+
+// extension returns a file extension players can detect the format from.
+func extension(mediaType string) string {
+	switch mediaType {
+	case "audio/wav", "audio/x-wav":
+		return ".wav"
+	case "audio/ogg":
+		return ".ogg"
+	default:
+		return ".mp3"
+	}
+}
+
+var (
+	codeBlockRe = regexp.MustCompile("(?s)```.*?(```|$)")
+	linkRe      = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	headingRe   = regexp.MustCompile(`(?m)^[ \t]*(#{1,6}|[-*+]|>)[ \t]+`)
+	emphasisRe  = regexp.MustCompile("[*_`~]+")
+)
+
+// PlainText turns a Markdown reply into text worth reading aloud: code blocks
+// are replaced by a short note, links keep their text, and heading, list and
+// emphasis markers are dropped.
+func PlainText(md string) string {
+	s := codeBlockRe.ReplaceAllString(md, "(code block omitted)")
+	s = linkRe.ReplaceAllString(s, "$1")
+	s = headingRe.ReplaceAllString(s, "")
+	s = emphasisRe.ReplaceAllString(s, "")
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,59 @@
+package speech
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlayer(t *testing.T) {
+	p, err := Player("mpv --no-video")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mpv", "--no-video"}, p)
+
+	lookPath = func(name string) (string, error) {
+		if name == "mpv" {
+			return "/usr/bin/mpv", nil
+		}
+		return "", errors.New("not found")
+	}
+	t.Cleanup(func() { lookPath = defaultLookPath })
+
+	p, err = Player("")
+	require.NoError(t, err)
+	assert.Equal(t, "mpv", p[0])
+
+	lookPath = func(string) (string, error) { return "", errors.New("not found") }
+	_, err = Player("")
+	require.ErrorContains(t, err, "speech: no audio player found")
+}
+
+func TestPlay(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script player")
+	}
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "played")
+	script := filepath.Join(dir, "player.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\ncp \"$2\" "+out+"\n"), 0o700)) //nolint:gosec // test script must be executable.
+
+	err := Play(context.Background(), []string{script, "--quiet"}, content.Audio{Data: []byte("ID3"), MediaType: "audio/mpeg"})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(out) //nolint:gosec // test file in a temp dir.
+	require.NoError(t, err)
+	assert.Equal(t, "ID3", string(data))
+}
+
+func TestPlainText(t *testing.T) {
+	md := "# Result\n\nUse **bold** and `code`, see [docs](https://x.y).\n\n- item one\n\n```go\nfmt.Println()\n```\nDone."
+	assert.Equal(t, "Result\n\nUse bold and code, see docs.\n\nitem one\n\n(code block omitted)\nDone.", PlainText(md))
+}
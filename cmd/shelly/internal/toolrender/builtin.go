@@ -0,0 +1,319 @@
+package toolrender
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	lipgloss "charm.land/lipgloss/v2"
+	"github.com/mattn/go-runewidth"
+
+	"github.com/germanamz/shelly/cmd/shelly/internal/styles"
+)
+
+// maxCellWidth caps the width of a table column.
+const maxCellWidth = 40
+
+var (
+	addedStyle   = lipgloss.NewStyle().Foreground(styles.ColorSuccess)
+	removedStyle = lipgloss.NewStyle().Foreground(styles.ColorError)
+	hunkStyle    = lipgloss.NewStyle().Foreground(styles.ColorAccent)
+	fileStyle    = lipgloss.NewStyle().Bold(true).Foreground(styles.ColorAccent)
+	headerStyle  = lipgloss.NewStyle().Bold(true)
+)
+
+// Text renders the result as plain lines.
+func Text(r Result, width int) (string, bool) {
+	return textLines(r.Text, width), true
+}
+
+func textLines(s string, width int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = styles.ToolResultStyle.Render(clip(strings.ReplaceAll(line, "\t", "    "), width))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Auto picks a view from the shape of the result: a table for JSON arrays,
+// indented JSON for other JSON values, a diff for unified diffs and plain
+// text otherwise.
+func Auto(r Result, width int) (string, bool) {
+	text := strings.TrimSpace(r.Text)
+	switch {
+	case strings.HasPrefix(text, "[") && json.Valid([]byte(text)):
+		if out, ok := Table(r, width); ok {
+			return out, true
+		}
+		return JSON(r, width)
+	case strings.HasPrefix(text, "{") && json.Valid([]byte(text)):
+		return JSON(r, width)
+	case isDiff(text):
+		return Diff(r, width)
+	}
+	return Text(r, width)
+}
+
+// isDiff reports whether s looks like a unified diff.
+func isDiff(s string) bool {
+	if strings.HasPrefix(s, "diff --git ") {
+		return true
+	}
+	return strings.HasPrefix(s, "--- ") && strings.Contains(s, "\n+++ ") && strings.Contains(s, "\n@@")
+}
+
+// Diff colours a unified diff: added lines green, removed lines red and hunk
+// headers blue.
+func Diff(r Result, width int) (string, bool) {
+	text := strings.TrimRight(r.Text, "\n")
+	if !isDiff(strings.TrimSpace(text)) {
+		return "", false
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		clipped := clip(strings.ReplaceAll(line, "\t", "    "), width)
+		switch {
+		case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "),
+			strings.HasPrefix(line, "diff "), strings.HasPrefix(line, "index "):
+			lines[i] = headerStyle.Render(clipped)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = hunkStyle.Render(clipped)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = addedStyle.Render(clipped)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = removedStyle.Render(clipped)
+		default:
+			lines[i] = clipped
+		}
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+// searchHit is one match in a search_content result.
+type searchHit struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line"`
+	Content string `json:"content"`
+}
+
+// Search groups search_content matches by file. File names link to the file
+// in terminals that support OSC 8 hyperlinks.
+func Search(r Result, width int) (string, bool) {
+	var hits []searchHit
+	if err := json.Unmarshal([]byte(r.Text), &hits); err != nil {
+		return "", false
+	}
+	if len(hits) == 0 {
+		return styles.DimStyle.Render("No matches"), true
+	}
+
+	var args struct {
+		Directory string `json:"directory"`
+	}
+	_ = json.Unmarshal([]byte(r.Args), &args)
+
+	var order []string
+	byPath := make(map[string][]searchHit)
+	for _, h := range hits {
+		if _, ok := byPath[h.Path]; !ok {
+			order = append(order, h.Path)
+		}
+		byPath[h.Path] = append(byPath[h.Path], h)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(styles.DimStyle.Render(fmt.Sprintf("%s in %s", plural(len(hits), "match", "matches"), plural(len(order), "file", "files"))))
+	for _, path := range order {
+		style := fileStyle
+		if abs, err := filepath.Abs(filepath.Join(args.Directory, path)); err == nil {
+			style = style.Hyperlink("file://" + filepath.ToSlash(abs))
+		}
+		sb.WriteString("\n")
+		sb.WriteString(style.Render(clip(path, width)))
+
+		numWidth := len(fmt.Sprint(byPath[path][len(byPath[path])-1].Line))
+		for _, h := range byPath[path] {
+			num := fmt.Sprintf("  %*d ", numWidth, h.Line)
+			content := strings.TrimSpace(strings.ReplaceAll(h.Content, "\t", "    "))
+			fmt.Fprintf(&sb, "\n%s%s", styles.DimStyle.Render(num), clip(content, width-len(num)))
+		}
+	}
+	return sb.String(), true
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
+
+// Table renders a JSON array as a table: objects become rows with a column
+// per key, in order of first appearance, and scalars become a list.
+func Table(r Result, width int) (string, bool) {
+	var items []json.RawMessage
+	if err := json.Unmarshal([]byte(r.Text), &items); err != nil {
+		return "", false
+	}
+	return renderTable(items, width)
+}
+
+func renderTable(items []json.RawMessage, width int) (string, bool) {
+	if len(items) == 0 {
+		return styles.DimStyle.Render("(empty)"), true
+	}
+
+	if first := bytes.TrimSpace(items[0]); len(first) == 0 || first[0] != '{' {
+		// A list of scalars, one per line.
+		lines := make([]string, len(items))
+		for i, raw := range items {
+			raw = bytes.TrimSpace(raw)
+			if len(raw) > 0 && (raw[0] == '{' || raw[0] == '[') {
+				return "", false
+			}
+			lines[i] = styles.ToolResultStyle.Render(clip(cell(raw), width))
+		}
+		return strings.Join(lines, "\n"), true
+	}
+
+	var columns []string
+	seen := make(map[string]bool)
+	rows := make([]map[string]json.RawMessage, len(items))
+	for i, raw := range items {
+		keys, ok := objectKeys(raw)
+		if !ok {
+			return "", false
+		}
+		for _, k := range keys {
+			if !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+		if err := json.Unmarshal(raw, &rows[i]); err != nil {
+			return "", false
+		}
+	}
+
+	cells := make([][]string, len(rows))
+	widths := make([]int, len(columns))
+	for j, c := range columns {
+		widths[j] = runewidth.StringWidth(c)
+	}
+	for i, row := range rows {
+		cells[i] = make([]string, len(columns))
+		for j, c := range columns {
+			v := clip(cell(row[c]), maxCellWidth)
+			cells[i][j] = v
+			widths[j] = max(widths[j], runewidth.StringWidth(v))
+		}
+	}
+
+	line := func(values []string) string {
+		var sb strings.Builder
+		for j, v := range values {
+			if j > 0 {
+				sb.WriteString("  ")
+			}
+			sb.WriteString(runewidth.FillRight(v, widths[j]))
+		}
+		return clip(strings.TrimRight(sb.String(), " "), width)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(headerStyle.Render(line(columns)))
+	for _, values := range cells {
+		sb.WriteString("\n")
+		sb.WriteString(line(values))
+	}
+	return sb.String(), true
+}
+
+// objectKeys returns the keys of a JSON object in document order.
+func objectKeys(raw json.RawMessage) ([]string, bool) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, false
+	}
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		key, _ := tok.(string)
+		keys = append(keys, key)
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, false
+		}
+	}
+	return keys, true
+}
+
+// cell formats a JSON value for a table cell.
+func cell(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return strings.ReplaceAll(s, "\n", " ")
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err == nil {
+		return compact.String()
+	}
+	return string(raw)
+}
+
+// JSON renders a JSON value indented.
+func JSON(r Result, width int) (string, bool) {
+	var out bytes.Buffer
+	if err := json.Indent(&out, bytes.TrimSpace([]byte(r.Text)), "", "  "); err != nil {
+		return "", false
+	}
+	return textLines(out.String(), width), true
+}
+
+// HTTP renders an http_fetch response: the status line, the content type and
+// the body, indented when it is JSON.
+func HTTP(r Result, width int) (string, bool) {
+	var resp struct {
+		Status  int               `json:"status"`
+		Headers map[string]string `json:"headers"`
+		Body    string            `json:"body"`
+	}
+	if err := json.Unmarshal([]byte(r.Text), &resp); err != nil || resp.Status == 0 {
+		return "", false
+	}
+
+	statusStyle := addedStyle
+	switch {
+	case resp.Status >= 400:
+		statusStyle = removedStyle
+	case resp.Status >= 300:
+		statusStyle = lipgloss.NewStyle().Foreground(styles.ColorWarning)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(statusStyle.Bold(true).Render(fmt.Sprintf("HTTP %d", resp.Status)))
+	if ct := resp.Headers["Content-Type"]; ct != "" {
+		sb.WriteString(styles.DimStyle.Render("  " + ct))
+	}
+
+	body := strings.TrimSpace(resp.Body)
+	if body == "" {
+		return sb.String(), true
+	}
+	sb.WriteString("\n")
+	if out, ok := JSON(Result{Text: body}, width); ok {
+		sb.WriteString(out)
+	} else {
+		sb.WriteString(textLines(body, width))
+	}
+	return sb.String(), true
+}
@@ -0,0 +1,143 @@
+package toolrender
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+
+	lipgloss "charm.land/lipgloss/v2"
+
+	"github.com/germanamz/shelly/cmd/shelly/internal/format"
+	"github.com/germanamz/shelly/cmd/shelly/internal/styles"
+)
+
+// Spec declares a renderer for a tool, for every tool of an MCP server, or
+// for one tool of a server. It either reuses a view by name or renders a
+// template.
+type Spec struct {
+	Tool     string
+	Server   string
+	View     string // Name in Views.
+	Template string // text/template executed with TemplateData.
+	MaxLines int    // Lines shown while collapsed: 0 = DefaultMaxLines, <0 = never collapse.
+}
+
+// TemplateData is the data a renderer template is executed with.
+type TemplateData struct {
+	Tool   string
+	Server string
+	Args   map[string]any // Parsed call arguments.
+	Result any            // Parsed JSON result, or the text when it is not JSON.
+	Text   string         // Raw result.
+	Width  int
+}
+
+// templateFuncs are available to renderer templates in addition to the
+// text/template builtins.
+var templateFuncs = template.FuncMap{
+	"truncate": func(n int, s string) string { return format.Truncate(s, n) },
+	"join": func(sep string, v any) string {
+		items, _ := v.([]any)
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, sep)
+	},
+	"json": func(v any) (string, error) {
+		data, err := json.MarshalIndent(v, "", "  ")
+		return string(data), err
+	},
+	"style": func(name, s string) string { return templateStyles[name].Render(s) },
+	"table": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return "", fmt.Errorf("table: not an array")
+		}
+		out, ok := renderTable(items, 0)
+		if !ok {
+			return "", fmt.Errorf("table: array of mixed values")
+		}
+		return out, nil
+	},
+	"diff": func(s string) string {
+		if out, ok := Diff(Result{Text: s}, 0); ok {
+			return out
+		}
+		return s
+	},
+}
+
+// templateStyles are the style names accepted by the style template function.
+var templateStyles = map[string]lipgloss.Style{
+	"bold":    lipgloss.NewStyle().Bold(true),
+	"dim":     styles.DimStyle,
+	"accent":  lipgloss.NewStyle().Foreground(styles.ColorAccent),
+	"success": lipgloss.NewStyle().Foreground(styles.ColorSuccess),
+	"warning": lipgloss.NewStyle().Foreground(styles.ColorWarning),
+	"error":   lipgloss.NewStyle().Foreground(styles.ColorError),
+}
+
+// Configure replaces the configured renderers. mcpTools maps MCP tool names
+// to the server that provides them, so that renderers can be bound to a
+// server. It fails on unknown views and templates that do not parse.
+func Configure(specs []Spec, mcpTools map[string]string) error {
+	entries := make([]entry, 0, len(specs))
+	for i, s := range specs {
+		e := entry{tool: s.Tool, server: s.Server, maxLines: s.MaxLines}
+		if e.maxLines == 0 {
+			e.maxLines = DefaultMaxLines
+		}
+
+		name := s.Tool
+		if name == "" {
+			name = s.Server
+		}
+		switch {
+		case s.Template != "":
+			tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(s.Template)
+			if err != nil {
+				return fmt.Errorf("toolrender: renderer %d (%s): %w", i, name, err)
+			}
+			e.render = templateRenderer(tmpl)
+		case s.View != "":
+			r, ok := Views[s.View]
+			if !ok {
+				return fmt.Errorf("toolrender: renderer %d (%s): unknown view %q", i, name, s.View)
+			}
+			e.render = r
+		default:
+			return fmt.Errorf("toolrender: renderer %d (%s): view or template is required", i, name)
+		}
+		entries = append(entries, e)
+	}
+
+	configured = entries
+	servers = mcpTools
+	return nil
+}
+
+// templateRenderer renders results with tmpl. A template that fails to
+// execute falls back to plain text.
+func templateRenderer(tmpl *template.Template) Renderer {
+	return func(r Result, width int) (string, bool) {
+		data := TemplateData{Tool: r.Tool, Server: r.Server, Text: r.Text, Width: width}
+		_ = json.Unmarshal([]byte(r.Args), &data.Args)
+		if err := json.Unmarshal([]byte(r.Text), &data.Result); err != nil {
+			data.Result = r.Text
+		}
+
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, data); err != nil {
+			slog.Debug("toolrender: template failed", "tool", r.Tool, "error", err)
+			return "", false
+		}
+		return sb.String(), true
+	}
+}
@@ -0,0 +1,128 @@
+// Package toolrender turns tool results into rich views for the chat view:
+// coloured diffs, search hits grouped by file, tables for JSON arrays and
+// plain text collapsed to a few lines. Renderers are looked up by tool name
+// and, for MCP tools, by the server that provides them.
+package toolrender
+
+import (
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// DefaultMaxLines is the number of lines a result shows while collapsed.
+const DefaultMaxLines = 8
+
+// Result is a completed tool call as seen by a renderer.
+type Result struct {
+	Tool   string
+	Server string // MCP server that provides Tool; "" for built-in tools.
+	Args   string // JSON arguments of the call.
+	Text   string // Tool output.
+}
+
+// Renderer renders a result into lines at most width columns wide. It
+// returns false when the result is not in the shape it expects, in which
+// case the result is shown as plain text.
+type Renderer func(r Result, width int) (string, bool)
+
+// Views maps view names to renderers. Configured renderers reuse them by name.
+var Views = map[string]Renderer{
+	"text":   Text,
+	"diff":   Diff,
+	"search": Search,
+	"table":  Table,
+	"json":   JSON,
+	"http":   HTTP,
+}
+
+// ToolRenderers maps built-in tool names to their renderers. Tools without an
+// entry are rendered by Auto.
+var ToolRenderers = map[string]Renderer{
+	"git_diff":       Diff,
+	"fs_diff":        Diff,
+	"search_content": Search,
+	"search_files":   Table,
+	"fs_list":        Table,
+	"http_fetch":     HTTP,
+}
+
+// entry is a renderer bound to a tool, an MCP server or both.
+type entry struct {
+	tool     string
+	server   string
+	render   Renderer
+	maxLines int
+}
+
+// configured holds renderers set with Configure; servers maps MCP tool names
+// to the server that provides them.
+var (
+	configured []entry
+	servers    map[string]string
+)
+
+// Lookup returns the renderer for a tool and the number of lines it shows
+// while collapsed. Configured renderers for the tool on its server take
+// precedence over ones for the tool alone, then for the whole server, then
+// ToolRenderers and finally Auto.
+func Lookup(tool string) (Renderer, int) {
+	server := servers[tool]
+
+	var toolOnly, serverOnly *entry
+	for i := range configured {
+		e := &configured[i]
+		switch {
+		case e.tool == tool && e.server != "" && e.server == server:
+			return e.render, e.maxLines
+		case e.tool == tool && e.server == "" && toolOnly == nil:
+			toolOnly = e
+		case e.tool == "" && e.server != "" && e.server == server && serverOnly == nil:
+			serverOnly = e
+		}
+	}
+	switch {
+	case toolOnly != nil:
+		return toolOnly.render, toolOnly.maxLines
+	case serverOnly != nil:
+		return serverOnly.render, serverOnly.maxLines
+	}
+	if r, ok := ToolRenderers[tool]; ok {
+		return r, DefaultMaxLines
+	}
+	return Auto, DefaultMaxLines
+}
+
+// Render renders the result of a tool call. Unless expanded, the output is
+// collapsed to the renderer's line limit and hidden reports how many lines
+// were left out.
+func Render(tool, args, text string, width int, expanded bool) (out string, hidden int) {
+	render, maxLines := Lookup(tool)
+	r := Result{Tool: tool, Server: servers[tool], Args: args, Text: text}
+
+	out, ok := render(r, width)
+	if !ok {
+		out, _ = Text(r, width)
+	}
+	out = strings.TrimRight(out, "\n")
+
+	if expanded || maxLines <= 0 {
+		return out, 0
+	}
+	lines := strings.Split(out, "\n")
+	if len(lines) <= maxLines {
+		return out, 0
+	}
+	return strings.Join(lines[:maxLines], "\n"), len(lines) - maxLines
+}
+
+// Server returns the MCP server that provides tool, or "".
+func Server(tool string) string { return servers[tool] }
+
+// clip shortens plain text to width display columns.
+func clip(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	return runewidth.Truncate(s, width, "…")
+}
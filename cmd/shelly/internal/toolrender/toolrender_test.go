@@ -0,0 +1,160 @@
+package toolrender
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// configure installs specs for the duration of the test.
+func configure(t *testing.T, specs []Spec, mcpTools map[string]string) {
+	t.Helper()
+	require.NoError(t, Configure(specs, mcpTools))
+	t.Cleanup(func() { configured, servers = nil, nil })
+}
+
+func TestRender_Collapses(t *testing.T) {
+	lines := make([]string, 20)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i+1)
+	}
+	text := strings.Join(lines, "\n")
+
+	out, hidden := Render("exec_run", "{}", text, 80, false)
+	assert.Equal(t, 12, hidden)
+	assert.Equal(t, strings.Join(lines[:DefaultMaxLines], "\n"), ansi.Strip(out))
+
+	out, hidden = Render("exec_run", "{}", text, 80, true)
+	assert.Zero(t, hidden)
+	assert.Equal(t, text, ansi.Strip(out))
+
+	_, hidden = Render("exec_run", "{}", "short", 80, false)
+	assert.Zero(t, hidden)
+}
+
+func TestDiff(t *testing.T) {
+	diff := "diff --git a/x.go b/x.go\n--- a/x.go\n+++ b/x.go\n@@ -1 +1 @@\n-old\n+new\n ctx\n"
+
+	out, ok := Diff(Result{Text: diff}, 80)
+	require.True(t, ok)
+	assert.Equal(t, strings.TrimSuffix(diff, "\n"), ansi.Strip(out))
+	lines := strings.Split(out, "\n")
+	assert.Equal(t, addedStyle.Render("+new"), lines[5])
+	assert.Equal(t, removedStyle.Render("-old"), lines[4])
+
+	_, ok = Diff(Result{Text: "no changes"}, 80)
+	assert.False(t, ok)
+}
+
+func TestSearch(t *testing.T) {
+	r := Result{
+		Args: `{"pattern":"TODO","directory":"/src"}`,
+		Text: `[{"path":"a.go","line":3,"content":"\t// TODO one"},{"path":"b.go","line":12,"content":"TODO two"},{"path":"a.go","line":40,"content":"TODO three"}]`,
+	}
+
+	out, ok := Search(r, 80)
+	require.True(t, ok)
+	assert.Equal(t, "3 matches in 2 files\na.go\n   3 // TODO one\n  40 TODO three\nb.go\n  12 TODO two", ansi.Strip(out))
+	assert.Contains(t, out, "\x1b]8;;file:///src/a.go", "file names are hyperlinks")
+
+	out, ok = Search(Result{Text: "[]"}, 80)
+	require.True(t, ok)
+	assert.Equal(t, "No matches", ansi.Strip(out))
+
+	_, ok = Search(Result{Text: "not json"}, 80)
+	assert.False(t, ok)
+}
+
+func TestTable(t *testing.T) {
+	out, ok := Table(Result{Text: `[{"name":"main.go","type":"file","size":120},{"name":"pkg","type":"dir","size":0,"extra":null}]`}, 80)
+	require.True(t, ok)
+	assert.Equal(t, "name     type  size  extra\nmain.go  file  120\npkg      dir   0", ansi.Strip(out))
+
+	out, ok = Table(Result{Text: `["a.go","b.go"]`}, 80)
+	require.True(t, ok)
+	assert.Equal(t, "a.go\nb.go", ansi.Strip(out))
+
+	_, ok = Table(Result{Text: `[{"a":1},2]`}, 80)
+	assert.False(t, ok)
+}
+
+func TestHTTP(t *testing.T) {
+	out, ok := HTTP(Result{Text: `{"status":404,"headers":{"Content-Type":"application/json"},"body":"{\"error\":\"missing\"}"}`}, 80)
+	require.True(t, ok)
+	assert.Equal(t, "HTTP 404  application/json\n{\n  \"error\": \"missing\"\n}", ansi.Strip(out))
+
+	_, ok = HTTP(Result{Text: `{"other":true}`}, 80)
+	assert.False(t, ok)
+}
+
+func TestAuto(t *testing.T) {
+	out, _ := Auto(Result{Text: `{"ok":true}`}, 80)
+	assert.Equal(t, "{\n  \"ok\": true\n}", ansi.Strip(out))
+
+	out, _ = Auto(Result{Text: `[{"id":1}]`}, 80)
+	assert.Equal(t, "id\n1", ansi.Strip(out))
+
+	out, _ = Auto(Result{Text: "plain\ttext"}, 80)
+	assert.Equal(t, "plain    text", ansi.Strip(out))
+}
+
+func TestLookup_Precedence(t *testing.T) {
+	configure(t, []Spec{
+		{Server: "tracker", View: "json"},
+		{Tool: "get_issue", Template: "server-tool", Server: "tracker"},
+		{Tool: "get_issue", Template: "tool"},
+		{Tool: "git_diff", View: "text", MaxLines: -1},
+	}, map[string]string{"get_issue": "tracker", "list_issues": "tracker"})
+
+	render := func(tool string) string {
+		r, _ := Lookup(tool)
+		out, _ := r(Result{Tool: tool, Text: `{"a":1}`}, 80)
+		return ansi.Strip(out)
+	}
+	assert.Equal(t, "server-tool", render("get_issue"))
+	assert.Equal(t, "{\n  \"a\": 1\n}", render("list_issues"))
+	assert.Equal(t, `{"a":1}`, render("git_diff"))
+	assert.Equal(t, "tracker", Server("get_issue"))
+
+	_, maxLines := Lookup("git_diff")
+	assert.Equal(t, -1, maxLines)
+	_, maxLines = Lookup("list_issues")
+	assert.Equal(t, DefaultMaxLines, maxLines)
+}
+
+func TestConfigure_Template(t *testing.T) {
+	configure(t, []Spec{{
+		Tool:     "get_issue",
+		Template: `{{.Result.key | style "bold"}} {{.Result.title | truncate 5}} [{{join ", " .Result.labels}}] via {{.Server}} for {{.Args.id}}`,
+	}}, map[string]string{"get_issue": "tracker"})
+
+	out, hidden := Render("get_issue", `{"id":7}`, `{"key":"BUG-7","title":"Crash on start","labels":["p1","ui"]}`, 80, false)
+	assert.Zero(t, hidden)
+	assert.Equal(t, "BUG-7 Crash... [p1, ui] via tracker for 7", ansi.Strip(out))
+
+	// A template that fails to execute falls back to plain text.
+	configure(t, []Spec{{Tool: "get_issue", Template: `{{table .Result}}`}}, nil)
+	out, _ = Render("get_issue", "{}", `{"key":"BUG-7"}`, 80, false)
+	assert.Equal(t, `{"key":"BUG-7"}`, ansi.Strip(out))
+}
+
+func TestConfigure_Errors(t *testing.T) {
+	tests := []struct {
+		spec Spec
+		want string
+	}{
+		{Spec{Tool: "x", View: "fancy"}, `unknown view "fancy"`},
+		{Spec{Tool: "x", Template: "{{.Text"}, "renderer 0 (x)"},
+		{Spec{Server: "tracker"}, "view or template is required"},
+	}
+	for _, tt := range tests {
+		err := Configure([]Spec{tt.spec}, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), tt.want)
+	}
+	assert.Nil(t, configured, "a failed Configure keeps the previous renderers")
+}
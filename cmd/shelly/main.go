@@ -13,6 +13,7 @@ import (
 	"github.com/germanamz/shelly/cmd/shelly/internal/app"
 	"github.com/germanamz/shelly/cmd/shelly/internal/format"
 	"github.com/germanamz/shelly/cmd/shelly/internal/msgs"
+	"github.com/germanamz/shelly/cmd/shelly/internal/printmode"
 	"github.com/germanamz/shelly/cmd/shelly/internal/tty"
 	"github.com/germanamz/shelly/pkg/engine"
 	"github.com/germanamz/shelly/pkg/shellydir"
@@ -52,19 +53,46 @@ func main() {
 				os.Exit(1)
 			}
 			return
+		case "daemon":
+			if err := runDaemon(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "sessions":
+			if err := runSessions(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
 		}
 	}
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: shelly [command] [flags]\n\nCommands:\n  init      Initialize a new project from a template\n  config    Interactive configuration wizard\n  index     Build or update the project knowledge graph\n  batch     Run tasks in headless batch mode\n\nFlags:\n")
+		fmt.Fprintf(os.Stderr, "Usage: shelly [command] [flags]\n       shelly -p <prompt|-> [flags]\n\nCommands:\n  init      Initialize a new project from a template\n  config    Interactive configuration wizard\n  index     Build or update the project knowledge graph\n  batch     Run tasks in headless batch mode\n  daemon    Run agents from scheduled and event triggers\n  sessions  List, search, export and prune saved sessions\n\nFlags:\n")
 		flag.PrintDefaults()
 	}
 
 	configPath := flag.String("config", "", "path to configuration file (default: .shelly/config.yaml or shelly.yaml)")
 	shellyDir := flag.String("shelly-dir", ".shelly", "path to .shelly directory")
 	agentName := flag.String("agent", "", "agent to start with (overrides entry_agent in config)")
+	var pf printFlags
+	flag.StringVar(&pf.prompt, "p", "", "run this prompt without the TUI and print the reply (\"-\" reads it from stdin)")
+	flag.StringVar(&pf.output, "output", printmode.OutputText, "print mode output: text (reply on stdout, progress on stderr) or json (NDJSON events)")
+	flag.StringVar(&pf.onAsk, "on-ask", printmode.AskDeny, "print mode answer to ask_user questions: deny, first (first option) or fail (exit 3)")
+	flag.StringVar(&pf.resume, "resume", "", "print mode: continue the saved session with this ID")
 	flag.Parse()
 
+	printMode := false
+	flag.Visit(func(f *flag.Flag) { printMode = printMode || f.Name == "p" })
+	if printMode {
+		os.Exit(runPrint(*configPath, *shellyDir, *agentName, pf))
+	}
+	if pf.resume != "" {
+		fmt.Fprintln(os.Stderr, "error: --resume requires -p; resume sessions in the TUI with /sessions")
+		os.Exit(printmode.ExitUsage)
+	}
+
 	if err := run(*configPath, *shellyDir, *agentName); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
@@ -84,6 +112,12 @@ func run(configPath, shellyDirPath, agentName string) error {
 	}
 
 	cfg.ShellyDir = shellyDirPath
+	// The TUI owns the terminal, so notifications go through its program.
+	var term *programTerminal
+	if notifyTerminal() != nil {
+		term = &programTerminal{}
+		cfg.NotifyTerminal = term
+	}
 	cfg.StatusFunc = func(msg string) {
 		fmt.Fprintf(os.Stderr, "\r\033[K  %s", msg)
 	}
@@ -96,6 +130,13 @@ func run(configPath, shellyDirPath, agentName string) error {
 	fmt.Fprintln(os.Stderr)
 	defer func() { _ = eng.Close() }()
 
+	if err := configureToolRenderers(eng); err != nil {
+		return err
+	}
+	if err := configureKeybindings(eng); err != nil {
+		return err
+	}
+
 	sess, err := eng.NewSession(agentName)
 	if err != nil {
 		return err
@@ -114,6 +155,9 @@ func run(configPath, shellyDirPath, agentName string) error {
 
 	staleFilter := tty.NewStaleEscapeFilter()
 	p := tea.NewProgram(model, tea.WithFilter(staleFilter))
+	if term != nil {
+		term.p.Store(p)
+	}
 
 	// Send the program reference so the model can start bridge goroutines.
 	go func() {
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/germanamz/shelly/cmd/shelly/internal/printmode"
+	"github.com/germanamz/shelly/pkg/engine"
+)
+
+// printFlags are the main-command flags used by print mode (-p).
+type printFlags struct {
+	prompt string
+	output string
+	onAsk  string
+	resume string
+}
+
+// runPrint runs a single prompt without the TUI and returns the process exit
+// code. A prompt of "-" is read from stdin.
+func runPrint(configPath, shellyDirPath, agentName string, pf printFlags) int {
+	opts := printmode.Options{Output: pf.output, OnAsk: pf.onAsk, Stdout: os.Stdout, Stderr: os.Stderr}
+	if err := opts.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return printmode.ExitUsage
+	}
+
+	prompt := pf.prompt
+	if prompt == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: read prompt: %v\n", err)
+			return printmode.ExitError
+		}
+		prompt = string(data)
+	}
+	if strings.TrimSpace(prompt) == "" {
+		fmt.Fprintln(os.Stderr, "error: -p: empty prompt")
+		return printmode.ExitUsage
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer cancel()
+
+	cfg, err := engine.LoadConfig(resolveConfigPath(configPath, shellyDirPath))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return printmode.ExitError
+	}
+	cfg.ShellyDir = shellyDirPath
+	cfg.NotifyTerminal = notifyTerminal()
+
+	eng, err := engine.New(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return printmode.ExitError
+	}
+	defer func() { _ = eng.Close() }()
+
+	var sess *engine.Session
+	if pf.resume != "" {
+		sess, err = eng.ResumeSession(pf.resume)
+		if err == nil && agentName != "" && agentName != sess.AgentName() {
+			err = fmt.Errorf("session %s belongs to agent %q, not %q", pf.resume, sess.AgentName(), agentName)
+		}
+	} else {
+		sess, err = eng.NewSession(agentName)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return printmode.ExitError
+	}
+
+	err = printmode.Run(ctx, eng, sess, prompt, opts)
+	if err != nil && pf.output != printmode.OutputJSON {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	}
+	return printmode.ExitCode(err)
+}
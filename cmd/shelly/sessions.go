@@ -0,0 +1,572 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/germanamz/shelly/cmd/shelly/internal/format"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/engine"
+	"github.com/germanamz/shelly/pkg/sessions"
+	"github.com/germanamz/shelly/pkg/shellydir"
+)
+
+const sessionsUsage = `Usage: shelly sessions <command> [flags]
+
+Manage saved sessions.
+
+Commands:
+  list     List saved sessions, newest first
+  search   Find sessions whose messages contain a query
+  show     Print a session as Markdown
+  edit     Set a session's title and tags
+  delete   Delete sessions
+  prune    Delete old sessions and attachments by a retention policy
+  export   Write a session as Markdown, HTML, JSON or a provider-native transcript
+  import   Create a session from an exported or provider-native transcript
+
+Run 'shelly sessions <command> -h' for command flags.
+`
+
+func runSessions(args []string) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprint(os.Stderr, sessionsUsage)
+		return nil
+	}
+
+	switch args[0] {
+	case "list":
+		return runSessionsList(args[1:])
+	case "search":
+		return runSessionsSearch(args[1:])
+	case "show":
+		return runSessionsShow(args[1:])
+	case "edit":
+		return runSessionsEdit(args[1:])
+	case "delete":
+		return runSessionsDelete(args[1:])
+	case "prune":
+		return runSessionsPrune(args[1:])
+	case "export":
+		return runSessionsExport(args[1:])
+	case "import":
+		return runSessionsImport(args[1:])
+	default:
+		fmt.Fprint(os.Stderr, sessionsUsage)
+		return fmt.Errorf("sessions: unknown command %q", args[0])
+	}
+}
+
+func runSessionsExport(args []string) error {
+	fs := flag.NewFlagSet("sessions export", flag.ExitOnError)
+	shellyDir := fs.String("shelly-dir", ".shelly", "path to .shelly directory")
+	format := fs.String("format", string(sessions.FormatMarkdown), "output format: "+formatList(sessions.ExportFormats()))
+	output := fs.String("output", "", "write to this file instead of stdout")
+	link := fs.Bool("link-attachments", false, "link images, documents and audio from the attachment store instead of inlining them (md, html)")
+	noRedact := fs.Bool("no-redact", false, "keep API keys, tokens and passwords in the export")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: shelly sessions export [flags] <id>\n\nExport a saved session. Secrets are redacted unless --no-redact is set.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	pos, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		fs.Usage()
+		return errors.New("sessions export: expected one session ID")
+	}
+
+	store := sessions.New(shellydir.New(*shellyDir).SessionsDir())
+	opts := sessions.ExportOptions{Format: sessions.Format(*format), LinkAttachments: *link, NoRedact: *noRedact}
+
+	if *output == "" {
+		return store.Export(os.Stdout, pos[0], opts)
+	}
+
+	f, err := os.Create(*output) //nolint:gosec // output path chosen by the user.
+	if err != nil {
+		return fmt.Errorf("sessions export: %w", err)
+	}
+	err = store.Export(f, pos[0], opts)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("sessions export: %w", closeErr)
+	}
+	if err != nil {
+		_ = os.Remove(*output)
+	}
+	return err
+}
+
+func runSessionsImport(args []string) error {
+	fs := flag.NewFlagSet("sessions import", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to configuration file (default: .shelly/config.yaml or shelly.yaml)")
+	shellyDir := fs.String("shelly-dir", ".shelly", "path to .shelly directory")
+	format := fs.String("format", "", "input format (required): "+formatList(sessions.ImportFormats()))
+	agentName := fs.String("agent", "", "agent that resumes the session (default: the exported agent, else entry_agent from config)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: shelly sessions import [flags] <file|->\n\nCreate a session from a transcript. OpenAI and Anthropic transcripts may be\na bare message array or a request body with a \"messages\" array.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	pos, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		fs.Usage()
+		return errors.New("sessions import: expected one transcript file")
+	}
+	if *format == "" {
+		return fmt.Errorf("sessions import: --format is required (%s)", formatList(sessions.ImportFormats()))
+	}
+
+	var data []byte
+	if pos[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(pos[0])
+	}
+	if err != nil {
+		return fmt.Errorf("sessions import: %w", err)
+	}
+
+	info, msgs, err := sessions.Import(data, sessions.Format(*format))
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 {
+		return errors.New("sessions import: transcript has no messages")
+	}
+
+	if *agentName != "" {
+		info.Agent = *agentName
+	}
+	if info.Agent == "" {
+		cfg, err := engine.LoadConfig(resolveConfigPath(*configPath, *shellyDir))
+		if err != nil {
+			return fmt.Errorf("sessions import: no --agent given and config unavailable: %w", err)
+		}
+		info.Agent = cfg.EntryAgent
+		if info.Agent == "" && len(cfg.Agents) > 0 {
+			info.Agent = cfg.Agents[0].Name
+		}
+	}
+
+	now := time.Now()
+	info.ID = sessions.NewID()
+	info.UpdatedAt = now
+	if info.CreatedAt.IsZero() {
+		info.CreatedAt = now
+	}
+	info.MsgCount = len(msgs)
+	info.Preview = lastUserText(msgs, 100)
+
+	store := sessions.New(shellydir.New(*shellyDir).SessionsDir())
+	if err := store.Save(info, msgs); err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d messages as session %s (agent %s). Resume it from /sessions.\n", len(msgs), info.ID, info.Agent)
+	return nil
+}
+
+// listFlags are the session filters shared by list and search.
+type listFlags struct {
+	agent, provider, tag string
+	since, until         string
+	limit, offset        int
+	json                 bool
+}
+
+func (lf *listFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&lf.agent, "agent", "", "only sessions of this agent")
+	fs.StringVar(&lf.provider, "provider", "", "only sessions of this provider kind, model or kind/model")
+	fs.StringVar(&lf.tag, "tag", "", "only sessions with this tag")
+	fs.StringVar(&lf.since, "since", "", "only sessions updated since an age (7d, 36h) or date (2006-01-02)")
+	fs.StringVar(&lf.until, "until", "", "only sessions updated before an age or date")
+	fs.IntVar(&lf.limit, "limit", 0, "maximum number of sessions (0 = all)")
+	fs.IntVar(&lf.offset, "offset", 0, "number of sessions to skip")
+	fs.BoolVar(&lf.json, "json", false, "print JSON instead of a table")
+}
+
+func (lf *listFlags) opts(now time.Time) (sessions.ListOpts, error) {
+	opts := sessions.ListOpts{Agent: lf.agent, Provider: lf.provider, Tag: lf.tag, Limit: lf.limit, Offset: lf.offset}
+	var err error
+	if opts.Since, err = parseSince(lf.since, now); err != nil {
+		return opts, fmt.Errorf("--since: %w", err)
+	}
+	if opts.Until, err = parseSince(lf.until, now); err != nil {
+		return opts, fmt.Errorf("--until: %w", err)
+	}
+	return opts, nil
+}
+
+// parseSince turns an age relative to now or a date into a time. Empty
+// input returns the zero time.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	age, err := sessions.ParseAge(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("want an age (7d, 36h) or a date (2006-01-02), got %q", s)
+	}
+	return now.Add(-age), nil
+}
+
+func runSessionsList(args []string) error {
+	fs := flag.NewFlagSet("sessions list", flag.ExitOnError)
+	shellyDir := fs.String("shelly-dir", ".shelly", "path to .shelly directory")
+	var lf listFlags
+	lf.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: shelly sessions list [flags]\n\nList saved sessions, newest first.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts, err := lf.opts(time.Now())
+	if err != nil {
+		return fmt.Errorf("sessions list: %w", err)
+	}
+	infos, err := sessions.New(shellydir.New(*shellyDir).SessionsDir()).List(opts)
+	if err != nil {
+		return err
+	}
+
+	if lf.json {
+		return writeJSON(os.Stdout, infos)
+	}
+	if len(infos) == 0 {
+		fmt.Println("No sessions.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUPDATED\tAGENT\tPROVIDER\tMSGS\tTITLE")
+	for _, info := range infos {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n", info.ID, info.UpdatedAt.Local().Format("2006-01-02 15:04"),
+			info.Agent, providerLabel(info.Provider), info.MsgCount, sessionLabel(info))
+	}
+	return tw.Flush()
+}
+
+func runSessionsSearch(args []string) error {
+	fs := flag.NewFlagSet("sessions search", flag.ExitOnError)
+	shellyDir := fs.String("shelly-dir", ".shelly", "path to .shelly directory")
+	var lf listFlags
+	lf.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: shelly sessions search [flags] <query>\n\nFind sessions whose messages, tool calls or tool results contain query\n(case-insensitive).\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	pos, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) == 0 {
+		fs.Usage()
+		return errors.New("sessions search: expected a query")
+	}
+
+	opts, err := lf.opts(time.Now())
+	if err != nil {
+		return fmt.Errorf("sessions search: %w", err)
+	}
+	hits, err := sessions.New(shellydir.New(*shellyDir).SessionsDir()).Search(strings.Join(pos, " "), opts)
+	if err != nil {
+		return err
+	}
+
+	if lf.json {
+		return writeJSON(os.Stdout, hits)
+	}
+	if len(hits) == 0 {
+		fmt.Println("No matching sessions.")
+		return nil
+	}
+
+	for i, hit := range hits {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s  %s  %s  %s\n", hit.Info.ID, hit.Info.UpdatedAt.Local().Format("2006-01-02 15:04"), hit.Info.Agent, sessionLabel(hit.Info))
+		for _, m := range hit.Matches {
+			fmt.Printf("  #%d %s: %s\n", m.Message, m.Role, m.Snippet)
+		}
+	}
+	return nil
+}
+
+func runSessionsShow(args []string) error {
+	fs := flag.NewFlagSet("sessions show", flag.ExitOnError)
+	shellyDir := fs.String("shelly-dir", ".shelly", "path to .shelly directory")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: shelly sessions show [flags] <id>\n\nPrint a saved session as Markdown. Secrets are redacted; use\n'shelly sessions export --no-redact' for the raw transcript.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	pos, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		fs.Usage()
+		return errors.New("sessions show: expected one session ID")
+	}
+
+	info, msgs, err := sessions.New(shellydir.New(*shellyDir).SessionsDir()).Load(pos[0])
+	if err != nil {
+		return err
+	}
+	return sessions.Export(os.Stdout, info, msgs, sessions.ExportOptions{Format: sessions.FormatMarkdown})
+}
+
+func runSessionsEdit(args []string) error {
+	fs := flag.NewFlagSet("sessions edit", flag.ExitOnError)
+	shellyDir := fs.String("shelly-dir", ".shelly", "path to .shelly directory")
+	title := fs.String("title", "", "set the title (\"-\" clears it)")
+	var add, remove stringsFlag
+	fs.Var(&add, "tag", "add a tag (repeatable)")
+	fs.Var(&remove, "untag", "remove a tag (repeatable)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: shelly sessions edit [flags] <id>\n\nSet a session's title and tags.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	pos, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		fs.Usage()
+		return errors.New("sessions edit: expected one session ID")
+	}
+	if *title == "" && len(add) == 0 && len(remove) == 0 {
+		return errors.New("sessions edit: nothing to change (use --title, --tag or --untag)")
+	}
+
+	store := sessions.New(shellydir.New(*shellyDir).SessionsDir())
+	info, err := store.Update(pos[0], func(info *sessions.SessionInfo) {
+		switch *title {
+		case "":
+		case "-":
+			info.Title = ""
+		default:
+			info.Title = *title
+		}
+		for _, t := range add {
+			if !info.HasTag(t) {
+				info.Tags = append(info.Tags, t)
+			}
+		}
+		info.Tags = slices.DeleteFunc(info.Tags, func(t string) bool { return slices.Contains(remove, t) })
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s  %s", info.ID, sessionLabel(info))
+	if len(info.Tags) > 0 {
+		fmt.Printf("  #%s", strings.Join(info.Tags, " #"))
+	}
+	fmt.Println()
+	return nil
+}
+
+func runSessionsDelete(args []string) error {
+	fs := flag.NewFlagSet("sessions delete", flag.ExitOnError)
+	shellyDir := fs.String("shelly-dir", ".shelly", "path to .shelly directory")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: shelly sessions delete [flags] <id>...\n\nDelete saved sessions and their attachments.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	pos, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) == 0 {
+		fs.Usage()
+		return errors.New("sessions delete: expected at least one session ID")
+	}
+
+	store := sessions.New(shellydir.New(*shellyDir).SessionsDir())
+	for _, id := range pos {
+		if _, _, err := store.Load(id); err != nil {
+			return err
+		}
+		if err := store.Delete(id); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted session %s\n", id)
+	}
+	return nil
+}
+
+func runSessionsPrune(args []string) error {
+	fs := flag.NewFlagSet("sessions prune", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to configuration file (default: .shelly/config.yaml or shelly.yaml)")
+	shellyDir := fs.String("shelly-dir", ".shelly", "path to .shelly directory")
+	olderThan := fs.String("older-than", "", "delete sessions not updated within this age (30d, 2w, 36h)")
+	keep := fs.Int("keep", 0, "keep only the most recently updated N sessions")
+	attachmentsOlderThan := fs.String("attachments-older-than", "", "remove attachments of sessions not updated within this age")
+	dryRun := fs.Bool("dry-run", false, "report what would be removed without removing it")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: shelly sessions prune [flags]\n\nDelete old sessions and attachments. Without policy flags the\nsessions.retention policy from the config is applied.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var policy sessions.RetentionPolicy
+	var err error
+	if *olderThan == "" && *keep == 0 && *attachmentsOlderThan == "" {
+		cfg, err := engine.LoadConfig(resolveConfigPath(*configPath, *shellyDir))
+		if err != nil {
+			return fmt.Errorf("sessions prune: no policy flags given and config unavailable: %w", err)
+		}
+		if policy, err = cfg.Sessions.Retention.Policy(); err != nil {
+			return err
+		}
+		if policy.IsZero() {
+			return errors.New("sessions prune: no policy flags given and no sessions.retention in config")
+		}
+	} else {
+		if *keep < 0 {
+			return fmt.Errorf("sessions prune: --keep must be >= 0, got %d", *keep)
+		}
+		policy.MaxSessions = *keep
+		if *olderThan != "" {
+			if policy.MaxAge, err = sessions.ParseAge(*olderThan); err != nil {
+				return fmt.Errorf("sessions prune: --older-than: %w", err)
+			}
+		}
+		if *attachmentsOlderThan != "" {
+			if policy.AttachmentMaxAge, err = sessions.ParseAge(*attachmentsOlderThan); err != nil {
+				return fmt.Errorf("sessions prune: --attachments-older-than: %w", err)
+			}
+		}
+	}
+
+	report, err := sessions.New(shellydir.New(*shellyDir).SessionsDir()).Prune(policy, time.Now(), *dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "Deleted"
+	if *dryRun {
+		verb = "Would delete"
+	}
+	for _, info := range report.Deleted {
+		fmt.Printf("%s session %s (%s)\n", verb, info.ID, sessionLabel(info))
+	}
+	for _, info := range report.AttachmentsFrom {
+		fmt.Printf("%s attachments of session %s (%s)\n", verb, info.ID, sessionLabel(info))
+	}
+	fmt.Printf("%s %d sessions and the attachments of %d more, %s freed.\n",
+		verb, len(report.Deleted), len(report.AttachmentsFrom), format.FmtBytes(int(report.FreedBytes)))
+	return nil
+}
+
+// stringsFlag is a repeatable string flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func providerLabel(p sessions.ProviderMeta) string {
+	if p.Model == "" {
+		return p.Kind
+	}
+	return p.Kind + "/" + p.Model
+}
+
+// sessionLabel returns the title of a session, else its preview on one line.
+func sessionLabel(info sessions.SessionInfo) string {
+	if info.Title != "" {
+		return info.Title
+	}
+	label := format.Truncate(strings.Join(strings.Fields(info.Preview), " "), 60)
+	if label == "" {
+		label = "(empty)"
+	}
+	return label
+}
+
+// parseInterspersed parses flags that may appear before or after positional
+// arguments, returning the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return pos, nil
+		}
+		pos = append(pos, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func formatList(formats []sessions.Format) string {
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ", ")
+}
+
+// lastUserText returns the text of the last user message, truncated to n
+// bytes, for the session preview.
+func lastUserText(msgs []message.Message, n int) string {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == role.User {
+			text := msgs[i].TextContent()
+			if len(text) > n {
+				text = text[:n]
+			}
+			return text
+		}
+	}
+	return ""
+}
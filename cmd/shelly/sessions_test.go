@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/sessions"
+	"github.com/germanamz/shelly/pkg/shellydir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSessionsDir returns a .shelly directory holding three sessions: login
+// (1h old), parser (3d old) and migration (40d old).
+func newSessionsDir(t *testing.T) string {
+	t.Helper()
+	now := time.Now()
+	dir := t.TempDir()
+	store := sessions.New(shellydir.New(dir).SessionsDir())
+	for _, info := range []sessions.SessionInfo{
+		{ID: "login", Agent: "coder", Provider: sessions.ProviderMeta{Kind: "anthropic", Model: "claude"}, UpdatedAt: now.Add(-time.Hour), Preview: "fix the login bug", Title: "Login", Tags: []string{"bug"}},
+		{ID: "parser", Agent: "reviewer", Provider: sessions.ProviderMeta{Kind: "openai", Model: "gpt"}, UpdatedAt: now.Add(-72 * time.Hour), Preview: "review the parser"},
+		{ID: "migration", Agent: "coder", Provider: sessions.ProviderMeta{Kind: "openai", Model: "gpt"}, UpdatedAt: now.Add(-40 * 24 * time.Hour), Preview: "old migration work", Tags: []string{"old"}},
+	} {
+		info.CreatedAt = info.UpdatedAt
+		msgs := []message.Message{message.NewText("user", role.User, info.Preview)}
+		info.MsgCount = len(msgs)
+		require.NoError(t, store.Save(info, msgs))
+	}
+	return dir
+}
+
+// captureStdout returns what fn prints to stdout, and fn's error.
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+
+	stdout := os.Stdout
+	os.Stdout = w
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+
+	runErr := fn()
+	os.Stdout = stdout
+	require.NoError(t, w.Close())
+	return <-out, runErr
+}
+
+func TestParseInterspersed(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		pos     []string
+		json    bool
+		wantErr bool
+	}{
+		{"flags first", []string{"--json", "a", "b"}, []string{"a", "b"}, true, false},
+		{"flags between", []string{"a", "--json", "b"}, []string{"a", "b"}, true, false},
+		{"flags last", []string{"a", "b", "--json"}, []string{"a", "b"}, true, false},
+		{"no flags", []string{"a"}, []string{"a"}, false, false},
+		{"double dash", []string{"a", "--", "--json"}, []string{"a", "--json"}, false, false},
+		{"nothing", nil, nil, false, false},
+		{"bad value", []string{"a", "--limit", "x"}, nil, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			var lf listFlags
+			lf.register(fs)
+
+			pos, err := parseInterspersed(fs, tt.args)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.pos, pos)
+			assert.Equal(t, tt.json, lf.json)
+		})
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"", time.Time{}, false},
+		{"7d", now.Add(-7 * 24 * time.Hour), false},
+		{"2w", now.Add(-14 * 24 * time.Hour), false},
+		{"36h", now.Add(-36 * time.Hour), false},
+		{"2024-03-01", time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), false},
+		{"2024-03-01T10:00:00Z", time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), false},
+		{"soon", time.Time{}, true},
+		{"-3d", time.Time{}, true},
+		{"2024-13-01", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseSince(tt.in, now)
+			if tt.wantErr {
+				require.ErrorContains(t, err, "want an age (7d, 36h) or a date (2006-01-02)")
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "got %s, want %s", got, tt.want)
+		})
+	}
+}
+
+func TestListFlags_Opts(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	parse := func(args ...string) (sessions.ListOpts, error) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		var lf listFlags
+		lf.register(fs)
+		require.NoError(t, fs.Parse(args))
+		return lf.opts(now)
+	}
+
+	opts, err := parse("--agent", "coder", "--provider", "openai/gpt", "--tag", "bug",
+		"--since", "7d", "--until", "2025-06-14", "--limit", "5", "--offset", "2")
+	require.NoError(t, err)
+	assert.Equal(t, sessions.ListOpts{
+		Agent: "coder", Provider: "openai/gpt", Tag: "bug", Limit: 5, Offset: 2,
+		Since: now.Add(-7 * 24 * time.Hour),
+		Until: time.Date(2025, 6, 14, 0, 0, 0, 0, time.Local),
+	}, opts)
+
+	_, err = parse("--since", "yesterday")
+	require.ErrorContains(t, err, "--since:")
+	_, err = parse("--until", "tomorrow")
+	require.ErrorContains(t, err, "--until:")
+}
+
+func TestSessionsList_Filters(t *testing.T) {
+	dir := newSessionsDir(t)
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"all, newest first", nil, []string{"login", "parser", "migration"}},
+		{"agent", []string{"--agent", "coder"}, []string{"login", "migration"}},
+		{"provider kind", []string{"--provider", "openai"}, []string{"parser", "migration"}},
+		{"provider kind/model", []string{"--provider", "anthropic/claude"}, []string{"login"}},
+		{"tag", []string{"--tag", "old"}, []string{"migration"}},
+		{"since age", []string{"--since", "7d"}, []string{"login", "parser"}},
+		{"until age", []string{"--until", "2d"}, []string{"parser", "migration"}},
+		{"date range", []string{"--since", "7d", "--until", "2d"}, []string{"parser"}},
+		{"until date", []string{"--until", time.Now().AddDate(0, 0, -30).Format("2006-01-02")}, []string{"migration"}},
+		{"page", []string{"--limit", "1", "--offset", "1"}, []string{"parser"}},
+		{"no match", []string{"--agent", "nobody"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"--shelly-dir", dir, "--json"}, tt.args...)
+			out, err := captureStdout(t, func() error { return runSessionsList(args) })
+			require.NoError(t, err)
+
+			var infos []sessions.SessionInfo
+			require.NoError(t, json.Unmarshal([]byte(out), &infos))
+			var ids []string
+			for _, info := range infos {
+				ids = append(ids, info.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+}
+
+func TestSessionsList_Table(t *testing.T) {
+	out, err := captureStdout(t, func() error { return runSessionsList([]string{"--shelly-dir", newSessionsDir(t)}) })
+	require.NoError(t, err)
+	assert.Regexp(t, `^ID +UPDATED +AGENT +PROVIDER +MSGS +TITLE\n`, out)
+	assert.Regexp(t, `login +\S+ \S+ +coder +anthropic/claude +1 +Login\n`, out)
+	assert.Contains(t, out, "review the parser")
+
+	out, err = captureStdout(t, func() error { return runSessionsList([]string{"--shelly-dir", t.TempDir()}) })
+	require.NoError(t, err)
+	assert.Equal(t, "No sessions.\n", out)
+}
+
+func TestSessionsSearch(t *testing.T) {
+	dir := newSessionsDir(t)
+
+	out, err := captureStdout(t, func() error { return runSessionsSearch([]string{"--shelly-dir", dir, "PARSER"}) })
+	require.NoError(t, err)
+	assert.Contains(t, out, "parser  ")
+	assert.Contains(t, out, "  #0 user: review the parser")
+	assert.NotContains(t, out, "login")
+
+	// Filter flags may follow the query.
+	out, err = captureStdout(t, func() error {
+		return runSessionsSearch([]string{"--shelly-dir", dir, "the", "--agent", "coder", "--since", "7d"})
+	})
+	require.NoError(t, err)
+	assert.Contains(t, out, "fix the login bug")
+	assert.NotContains(t, out, "migration")
+
+	out, err = captureStdout(t, func() error { return runSessionsSearch([]string{"--shelly-dir", dir, "nothing here"}) })
+	require.NoError(t, err)
+	assert.Equal(t, "No matching sessions.\n", out)
+}
+
+func TestSessionsShow(t *testing.T) {
+	dir := newSessionsDir(t)
+
+	out, err := captureStdout(t, func() error { return runSessionsShow([]string{"parser", "--shelly-dir", dir}) })
+	require.NoError(t, err)
+	assert.Contains(t, out, "review the parser")
+
+	_, err = captureStdout(t, func() error { return runSessionsShow([]string{"--shelly-dir", dir, "nope"}) })
+	require.Error(t, err)
+}
+
+func TestSessionsDelete(t *testing.T) {
+	dir := newSessionsDir(t)
+	store := sessions.New(shellydir.New(dir).SessionsDir())
+
+	out, err := captureStdout(t, func() error { return runSessionsDelete([]string{"--shelly-dir", dir, "login", "parser"}) })
+	require.NoError(t, err)
+	assert.Equal(t, "Deleted session login\nDeleted session parser\n", out)
+
+	infos, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, "migration", infos[0].ID)
+
+	_, err = captureStdout(t, func() error { return runSessionsDelete([]string{"--shelly-dir", dir, "login"}) })
+	require.Error(t, err, "deleting an unknown session fails")
+}
+
+func TestSessionsPrune_DryRun(t *testing.T) {
+	dir := newSessionsDir(t)
+	store := sessions.New(shellydir.New(dir).SessionsDir())
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"older than", []string{"--older-than", "30d"}, []string{
+			"Would delete session migration (old migration work)",
+			"Would delete 1 sessions and the attachments of 0 more, ",
+		}},
+		{"keep", []string{"--keep", "1"}, []string{
+			"Would delete session parser (review the parser)",
+			"Would delete session migration (old migration work)",
+			"Would delete 2 sessions and the attachments of 0 more, ",
+		}},
+		{"nothing to prune", []string{"--older-than", "90d"}, []string{
+			"Would delete 0 sessions and the attachments of 0 more, 0 B freed.",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"--shelly-dir", dir, "--dry-run"}, tt.args...)
+			out, err := captureStdout(t, func() error { return runSessionsPrune(args) })
+			require.NoError(t, err)
+			for _, line := range tt.want {
+				assert.Contains(t, out, line)
+			}
+
+			infos, err := store.List()
+			require.NoError(t, err)
+			assert.Len(t, infos, 3, "a dry run removes nothing")
+		})
+	}
+
+	out, err := captureStdout(t, func() error { return runSessionsPrune([]string{"--shelly-dir", dir, "--older-than", "30d"}) })
+	require.NoError(t, err)
+	assert.Contains(t, out, "Deleted session migration (old migration work)")
+	infos, err := store.List()
+	require.NoError(t, err)
+	assert.Len(t, infos, 2)
+}
+
+func TestSessionsPrune_Errors(t *testing.T) {
+	dir := newSessionsDir(t)
+	missing := filepath.Join(dir, "missing.yaml")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"negative keep", []string{"--keep", "-1"}, "--keep must be >= 0"},
+		{"bad age", []string{"--older-than", "soon"}, "--older-than"},
+		{"bad attachment age", []string{"--attachments-older-than", "soon"}, "--attachments-older-than"},
+		{"no policy", []string{"--config", missing}, "no policy flags given and config unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"--shelly-dir", dir}, tt.args...)
+			_, err := captureStdout(t, func() error { return runSessionsPrune(args) })
+			require.ErrorContains(t, err, tt.want)
+		})
+	}
+}
@@ -43,7 +43,8 @@ var ErrStallDetected = errors.New("agent: stall detected")
 type EventNotifier func(ctx context.Context, kind string, agentName string, data any)
 
 // EventFunc is called by the agent to publish fine-grained loop events
-// (tool_call_start, tool_call_end, message_added).
+// (tool_call_start, tool_call_end, tool_args_repaired, tool_args_invalid,
+// message_added).
 type EventFunc func(ctx context.Context, kind string, data any)
 
 // CancelRegistrar registers a context.CancelFunc for a named child agent so
@@ -54,11 +55,16 @@ type CancelRegistrar func(name string, cancel context.CancelFunc)
 type CancelUnregistrar func(name string)
 
 // InboxRegistrar registers an inbox channel for a named child agent so that
-// the engine can deliver user messages to it via SendToAgent.
-type InboxRegistrar func(name string, inbox chan message.Message)
+// the engine can deliver user messages to it via SendToAgent. ctx is the
+// delegating call's context, which identifies the session.
+type InboxRegistrar func(ctx context.Context, name string, inbox chan message.Message)
 
 // InboxUnregistrar removes a previously registered inbox channel.
-type InboxUnregistrar func(name string)
+type InboxUnregistrar func(ctx context.Context, name string)
+
+// MemoryRecall returns the long-term memories most relevant to query,
+// formatted one per line for the system prompt, or "" when there are none.
+type MemoryRecall func(query string) string
 
 // ToolCallEventData carries metadata for tool_call_start / tool_call_end events.
 type ToolCallEventData struct {
@@ -89,28 +95,33 @@ type TaskCancelWatcher interface {
 
 // Options configures an Agent.
 type Options struct {
-	MaxIterations          int               // ReAct loop limit (0 = unlimited).
-	WarnIterations         int               // Inject wrap-up message at this iteration (0 = no warning).
-	MaxDelegationDepth     int               // Max tree depth for delegation (0 = cannot delegate).
-	MaxHandoffs            int               // Max peer-to-peer handoff chain length (0 = disabled).
-	Skills                 []skill.Skill     // Procedures the agent knows.
-	Middleware             []Middleware      // Applied around Run().
-	Effects                []Effect          // Per-iteration hooks run inside the ReAct loop.
-	Context                string            // Project context injected into the system prompt.
-	EventNotifier          EventNotifier     // Publishes sub-agent lifecycle events.
-	Prefix                 string            // Display prefix (emoji + label) for the TUI.
-	TaskBoard              TaskBoard         // Optional task board for automatic task lifecycle during delegation.
-	ReflectionDir          string            // Directory for failure reflection notes (empty = disabled).
-	DisableBehavioralHints bool              // When true, omits the <behavioral_constraints> section from the system prompt.
-	EventFunc              EventFunc         // Optional callback for fine-grained loop events (tool calls, message added).
-	CancelRegistrar        CancelRegistrar   // Registers child-agent cancel funcs for external cancellation.
-	CancelUnregistrar      CancelUnregistrar // Unregisters child-agent cancel funcs.
-	InboxRegistrar         InboxRegistrar    // Registers child-agent inbox channels for user message routing.
-	InboxUnregistrar       InboxUnregistrar  // Unregisters child-agent inbox channels.
-	ProviderLabel          string            // Display label for the provider (e.g. "anthropic/claude-sonnet-4").
-	InteractionMode        string            // "" | "auto" | "interactive" | "blocking". Controls child question handling.
-	QuestionTimeout        time.Duration     // Timeout for child questions in interactive mode (0 = no timeout).
-	UsageDiffLock          *sync.Mutex       // Shared lock for per-agent usage tracking via AgentUsageCompleter. All agents sharing the same provider completer must share the same lock.
+	MaxIterations          int                         // ReAct loop limit (0 = unlimited).
+	WarnIterations         int                         // Inject wrap-up message at this iteration (0 = no warning).
+	MaxDelegationDepth     int                         // Max tree depth for delegation (0 = cannot delegate).
+	MaxHandoffs            int                         // Max peer-to-peer handoff chain length (0 = disabled).
+	Skills                 []skill.Skill               // Procedures the agent knows.
+	Middleware             []Middleware                // Applied around Run().
+	ToolMiddleware         []ToolMiddleware            // Applied around each tool call.
+	Effects                []Effect                    // Per-iteration hooks run inside the ReAct loop.
+	Context                string                      // Project context injected into the system prompt.
+	EventNotifier          EventNotifier               // Publishes sub-agent lifecycle events.
+	Prefix                 string                      // Display prefix (emoji + label) for the TUI.
+	TaskBoard              TaskBoard                   // Optional task board for automatic task lifecycle during delegation.
+	ReflectionDir          string                      // Directory for failure reflection notes (empty = disabled).
+	DisableBehavioralHints bool                        // When true, omits the <behavioral_constraints> section from the system prompt.
+	EventFunc              EventFunc                   // Optional callback for fine-grained loop events (tool calls, message added).
+	CancelRegistrar        CancelRegistrar             // Registers child-agent cancel funcs for external cancellation.
+	CancelUnregistrar      CancelUnregistrar           // Unregisters child-agent cancel funcs.
+	InboxRegistrar         InboxRegistrar              // Registers child-agent inbox channels for user message routing.
+	InboxUnregistrar       InboxUnregistrar            // Unregisters child-agent inbox channels.
+	ProviderLabel          string                      // Display label for the provider (e.g. "anthropic/claude-sonnet-4").
+	InteractionMode        string                      // "" | "auto" | "interactive" | "blocking". Controls child question handling.
+	QuestionTimeout        time.Duration               // Timeout for child questions in interactive mode (0 = no timeout).
+	UsageDiffLock          *sync.Mutex                 // Shared lock for per-agent usage tracking via AgentUsageCompleter. All agents sharing the same provider completer must share the same lock.
+	TokenEstimator         modeladapter.TokenEstimator // Pre-call token estimates (zero value: heuristic tokenizer).
+	TokenCalibration       *modeladapter.Calibration   // Corrects estimates from reported usage. Shared by all agents of a provider; nil disables it.
+	TokenCounter           modeladapter.TokenCounter   // Exact pre-call counts from the provider; estimates are the fallback. Nil disables it.
+	MemoryRecall           MemoryRecall                // Recalls memories into the system prompt on the first run and before delegation. Nil disables it.
 }
 
 // delegationConfig groups fields used by the delegation handler.
@@ -128,6 +139,9 @@ type promptConfig struct {
 	context                string
 	skills                 []skill.Skill
 	disableBehavioralHints bool
+	memoryRecall           MemoryRecall
+	memories               string // recalled memories; set once per agent instance
+	recalled               bool
 }
 
 // eventConfig groups fields used for event emission.
@@ -156,16 +170,19 @@ type Agent struct {
 	maxIterations          int
 	warnIterations         int
 	middleware             []Middleware
+	toolMiddleware         []ToolMiddleware
 	effects                []Effect
 	delegation             delegationConfig
 	prompt                 promptConfig
 	events                 eventConfig
+	tokens                 tokenConfig
 	depth                  int
 	completion             completionHandler
 	handoff                handoffHandler
 	interaction            *InteractionChannel
 	interactiveDelegations *DelegationRegistry  // nil when interaction_mode != "interactive"
 	usageDiffLock          *sync.Mutex          // shared lock for AgentUsageCompleter wrapping
+	toolArgs               toolArgTracker       // per-run counts of repaired and rejected tool arguments
 	inbox                  chan message.Message // buffered(1) inbox for user messages injected while running
 }
 
@@ -183,6 +200,7 @@ func New(name, description, instructions string, completer modeladapter.Complete
 		maxIterations:  opts.MaxIterations,
 		warnIterations: opts.WarnIterations,
 		middleware:     opts.Middleware,
+		toolMiddleware: opts.ToolMiddleware,
 		effects:        opts.Effects,
 		delegation: delegationConfig{
 			maxDepth:        opts.MaxDelegationDepth,
@@ -196,6 +214,7 @@ func New(name, description, instructions string, completer modeladapter.Complete
 			context:                opts.Context,
 			skills:                 opts.Skills,
 			disableBehavioralHints: opts.DisableBehavioralHints,
+			memoryRecall:           opts.MemoryRecall,
 		},
 		events: eventConfig{
 			notifier:          opts.EventNotifier,
@@ -205,6 +224,11 @@ func New(name, description, instructions string, completer modeladapter.Complete
 			inboxRegistrar:    opts.InboxRegistrar,
 			inboxUnregistrar:  opts.InboxUnregistrar,
 		},
+		tokens: tokenConfig{
+			estimator:   opts.TokenEstimator,
+			calibration: opts.TokenCalibration,
+			counter:     opts.TokenCounter,
+		},
 		usageDiffLock: opts.UsageDiffLock,
 	}
 
@@ -261,9 +285,28 @@ func (a *Agent) ProviderLabel() string { return a.providerLabel }
 // Chat returns the agent's chat.
 func (a *Agent) Chat() *chat.Chat { return a.chat }
 
+// SetChat makes the agent continue an existing conversation, e.g. when a
+// session switches agents. Call Init afterwards to install this agent's system
+// prompt in place of the previous one.
+func (a *Agent) SetChat(c *chat.Chat) { a.chat = c }
+
 // Completer returns the agent's completer.
 func (a *Agent) Completer() modeladapter.Completer { return a.completer }
 
+// TrackUsage wraps the agent's completer in an AgentUsageCompleter so that
+// its usage tracker counts only this agent's calls rather than those of every
+// agent sharing the provider. No-op without Options.UsageDiffLock or when the
+// completer is already wrapped.
+func (a *Agent) TrackUsage() {
+	if a.usageDiffLock == nil {
+		return
+	}
+	if _, ok := a.completer.(*modeladapter.AgentUsageCompleter); ok {
+		return
+	}
+	a.completer = modeladapter.NewAgentUsageCompleter(a.completer, a.usageDiffLock)
+}
+
 // CompletionResult returns the structured completion data set by the
 // task_complete tool, or nil if the agent stopped without calling it.
 func (a *Agent) CompletionResult() *CompletionResult { return a.completion.Result() }
@@ -284,11 +327,29 @@ func (a *Agent) InitInbox() {
 	}
 }
 
+// RecallMemories loads the memories relevant to query into the system prompt,
+// replacing any recalled before. The next Init (e.g. at the start of Run)
+// applies them. Run recalls once by itself, using the latest user message,
+// unless RecallMemories was called first. No-op without Options.MemoryRecall.
+func (a *Agent) RecallMemories(query string) {
+	if a.prompt.memoryRecall == nil {
+		return
+	}
+	a.prompt.memories = a.prompt.memoryRecall(query)
+	a.prompt.recalled = true
+}
+
 // SetRegistry enables dynamic delegation by setting the agent's registry.
 func (a *Agent) SetRegistry(r *Registry) {
 	a.registry = r
 }
 
+// AddEffects appends effects to the agent. They run after the effects given
+// in Options.
+func (a *Agent) AddEffects(effs ...Effect) {
+	a.effects = append(a.effects, effs...)
+}
+
 // AddToolBoxes adds user-provided toolboxes to the agent, skipping any that
 // are already present (pointer equality) to avoid duplicate tools.
 func (a *Agent) AddToolBoxes(tbs ...*toolbox.ToolBox) {
@@ -308,6 +369,9 @@ func (a *Agent) AddToolBoxes(tbs ...*toolbox.ToolBox) {
 
 // Run executes the agent's ReAct loop with middleware applied.
 func (a *Agent) Run(ctx context.Context) (message.Message, error) {
+	// Expose the agent's name to middleware as well as to the loop.
+	ctx = agentctx.WithAgentName(ctx, a.name)
+
 	var runner Runner = RunnerFunc(a.run)
 
 	// Apply middleware in reverse order so the first middleware is outermost.
@@ -344,14 +408,28 @@ func (a *Agent) run(ctx context.Context) (message.Message, error) {
 		defer a.interactiveDelegations.Close()
 	}
 
+	// Recall memories once per agent instance, so the system prompt stays
+	// stable (and cacheable) across turns.
+	if !a.prompt.recalled {
+		a.RecallMemories(a.lastUserText())
+	}
+
 	// Ensure system prompt exists (fallback for direct usage without Init).
 	a.Init()
 
 	// Collect all toolboxes (user + orchestration).
 	toolboxes := a.allToolBoxes()
 
-	// Collect tool declarations and a handler map from all toolboxes.
-	tools, handlers := deduplicateTools(toolboxes)
+	// Collect tool declarations and a dispatch map from all toolboxes.
+	tools, byName := deduplicateTools(toolboxes)
+
+	// An allowlist applies to this agent only; delegated agents must not
+	// inherit it through ctx.
+	if allowed := allowedToolsFromContext(ctx); len(allowed) > 0 {
+		tools, byName = restrictTools(tools, byName, allowed)
+		ctx = WithAllowedTools(ctx, nil)
+	}
+	call := a.toolCaller(byName)
 
 	// Reset effects that track per-run state so they behave correctly across
 	// multiple Run() calls on a long-lived session agent.
@@ -360,11 +438,7 @@ func (a *Agent) run(ctx context.Context) (message.Message, error) {
 			r.Reset()
 		}
 	}
-
-	// Cache the static tool token cost so effects can inspect it without
-	// recomputing on every iteration.
-	var estimator modeladapter.TokenEstimator
-	toolTokens := estimator.EstimateTools(tools)
+	a.toolArgs.reset()
 
 	warnInjected := false
 
@@ -377,27 +451,34 @@ func (a *Agent) run(ctx context.Context) (message.Message, error) {
 			a.chat.Append(warnMsg)
 		}
 
-		estimatedTokens := estimator.EstimateTotal(a.chat, tools)
-
 		ic := IterationContext{
-			Phase:           PhaseBeforeComplete,
-			Iteration:       i,
-			Chat:            a.chat,
-			Completer:       a.completer,
-			AgentName:       a.name,
-			EstimatedTokens: estimatedTokens,
-			ToolTokens:      toolTokens,
+			Phase:     PhaseBeforeComplete,
+			Iteration: i,
+			Chat:      a.chat,
+			Completer: a.completer,
+			AgentName: a.name,
+			ToolArgs:  a.toolArgs.snapshot(),
 		}
 
+		// Filter first so token estimates reflect only the tool definitions
+		// actually sent (e.g. after dynamic tool discovery narrows the set).
+		iterTools := a.filterTools(ctx, ic, tools)
+		ic.ToolTokens = a.tokens.estimateTools(iterTools)
+		ic.EstimatedTokens = a.tokens.estimateTotal(ctx, a.chat, iterTools)
+
 		if err := a.evalEffects(ctx, ic); err != nil {
 			return message.Message{}, err
 		}
 
-		iterTools := a.filterTools(ctx, ic, tools)
+		// Estimate again after effects (e.g. compaction) so calibration
+		// compares against the request actually sent.
+		sent := a.tokens.rawEstimate(a.chat, iterTools)
+
 		reply, err := a.completer.Complete(ctx, a.chat, iterTools)
 		if err != nil {
 			return message.Message{}, err
 		}
+		a.tokens.observe(a.completer, sent)
 
 		reply.Sender = a.name
 		a.chat.Append(reply)
@@ -421,7 +502,7 @@ func (a *Agent) run(ctx context.Context) (message.Message, error) {
 		for idx, tc := range calls {
 			wg.Go(func() {
 				a.emitEvent(ctx, "tool_call_start", ToolCallEventData{ToolName: tc.Name, CallID: tc.ID})
-				results[idx] = callTool(ctx, handlers, tc)
+				results[idx] = call(ctx, tc)
 				a.emitEvent(ctx, "tool_call_end", ToolCallEventData{ToolName: tc.Name, CallID: tc.ID})
 			})
 		}
@@ -532,6 +613,7 @@ func (a *Agent) buildSystemPrompt() string {
 		ConfigName:               a.configName,
 		Depth:                    a.depth,
 		Skills:                   a.prompt.skills,
+		Memories:                 a.prompt.memories,
 		DisableBehavioralHints:   a.prompt.disableBehavioralHints,
 		HasNotesTools:            a.hasNotesTools(),
 		CanDelegate:              a.canDelegate(),
@@ -554,6 +636,17 @@ func (a *Agent) emitEvent(ctx context.Context, kind string, data any) {
 	}
 }
 
+// lastUserText returns the text of the latest user message in the chat.
+func (a *Agent) lastUserText() string {
+	msgs := a.chat.Messages()
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == role.User {
+			return msgs[i].TextContent()
+		}
+	}
+	return ""
+}
+
 // hasNotesTools returns true if any toolbox contains the "list_notes" tool.
 func (a *Agent) hasNotesTools() bool {
 	for _, tb := range a.toolboxes {
@@ -564,9 +657,42 @@ func (a *Agent) hasNotesTools() bool {
 	return false
 }
 
-// callTool looks up the named tool in the pre-built handler map and executes it.
-func callTool(ctx context.Context, handlers map[string]toolbox.Handler, tc content.ToolCall) content.ToolResult {
-	handler, ok := handlers[tc.Name]
+// toolCaller returns the ToolCaller used by a run: callTool over the dispatch
+// map, wrapped by the configured tool middleware (first is outermost). The
+// arguments are checked against the tool's input schema (see
+// checkToolArguments) before the middleware runs, so it sees the repaired
+// call and invalid calls never reach it.
+func (a *Agent) toolCaller(byName map[string]toolbox.Tool) ToolCaller {
+	var caller ToolCaller = func(ctx context.Context, tc content.ToolCall) content.ToolResult {
+		return a.callTool(ctx, byName, tc)
+	}
+
+	for i := len(a.toolMiddleware) - 1; i >= 0; i-- {
+		caller = a.toolMiddleware[i](caller)
+	}
+
+	return func(ctx context.Context, tc content.ToolCall) content.ToolResult {
+		if t, ok := byName[tc.Name]; ok {
+			args, invalid := a.checkToolArguments(ctx, t, tc)
+			if invalid != nil {
+				return *invalid
+			}
+			tc.Arguments = string(args)
+			ctx = context.WithValue(ctx, checkedArgsKey{}, tc.Arguments)
+		}
+		return caller(ctx, tc)
+	}
+}
+
+// checkedArgsKey carries the arguments checked by toolCaller to callTool, so
+// that only arguments rewritten by tool middleware are checked again.
+type checkedArgsKey struct{}
+
+// callTool looks up the named tool in the pre-built dispatch map and
+// executes it. Arguments rewritten by tool middleware after toolCaller
+// checked them are checked again.
+func (a *Agent) callTool(ctx context.Context, byName map[string]toolbox.Tool, tc content.ToolCall) content.ToolResult {
+	t, ok := byName[tc.Name]
 	if !ok {
 		return content.ToolResult{
 			ToolCallID: tc.ID,
@@ -575,7 +701,15 @@ func callTool(ctx context.Context, handlers map[string]toolbox.Handler, tc conte
 		}
 	}
 
-	result, err := handler(ctx, json.RawMessage(tc.Arguments))
+	args := json.RawMessage(tc.Arguments)
+	if checked, _ := ctx.Value(checkedArgsKey{}).(string); checked != tc.Arguments {
+		var invalid *content.ToolResult
+		if args, invalid = a.checkToolArguments(ctx, t, tc); invalid != nil {
+			return *invalid
+		}
+	}
+
+	result, err := t.Handler(ctx, args)
 	if err != nil {
 		return content.ToolResult{
 			ToolCallID: tc.ID,
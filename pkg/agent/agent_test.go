@@ -7,6 +7,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/germanamz/shelly/pkg/agentctx"
 	"github.com/germanamz/shelly/pkg/chats/chat"
 	"github.com/germanamz/shelly/pkg/chats/content"
 	"github.com/germanamz/shelly/pkg/chats/message"
@@ -298,6 +299,50 @@ func TestRunToolNotFound(t *testing.T) {
 	assert.True(t, foundError)
 }
 
+// toolsCompleter records the tool names offered on each call and replays
+// the sequenceCompleter replies.
+type toolsCompleter struct {
+	sequenceCompleter
+	offered [][]string
+}
+
+func (c *toolsCompleter) Complete(ctx context.Context, ch *chat.Chat, tools []toolbox.Tool) (message.Message, error) {
+	names := make([]string, len(tools))
+	for i, t := range tools {
+		names[i] = t.Name
+	}
+	c.offered = append(c.offered, names)
+	return c.sequenceCompleter.Complete(ctx, ch, tools)
+}
+
+func TestRunAllowedTools(t *testing.T) {
+	p := &toolsCompleter{sequenceCompleter: sequenceCompleter{
+		replies: []message.Message{
+			message.New("", role.Assistant, content.ToolCall{ID: "c1", Name: "echo", Arguments: `{}`}),
+			message.NewText("", role.Assistant, "Done."),
+		},
+	}}
+	a := New("bot", "", "", p, Options{})
+	git := toolbox.New()
+	git.Register(toolbox.Tool{Name: "git_status", Handler: func(context.Context, json.RawMessage) (string, error) { return "clean", nil }})
+	a.AddToolBoxes(newEchoToolBox(), git)
+
+	_, err := a.Run(WithAllowedTools(context.Background(), []string{"git_*"}))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"git_status"}, p.offered[0])
+	msgs := a.Chat().Messages()
+	tr := msgs[len(msgs)-2].Parts[0].(content.ToolResult)
+	assert.True(t, tr.IsError, "tools outside the allowlist cannot be called")
+	assert.Equal(t, "tool not found: echo", tr.Content)
+
+	// The limit applies to one run only.
+	p.index = 0
+	_, err = a.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"echo", "git_status"}, p.offered[2])
+}
+
 // --- System prompt tests ---
 
 func TestSystemPromptIdentity(t *testing.T) {
@@ -430,6 +475,36 @@ func TestSystemPromptContext(t *testing.T) {
 	assert.Contains(t, prompt, "<instructions>")
 }
 
+func TestSystemPromptMemories(t *testing.T) {
+	p := &sequenceCompleter{
+		replies: []message.Message{
+			message.NewText("", role.Assistant, "Hi"),
+			message.NewText("", role.Assistant, "Hi again"),
+		},
+	}
+	var queries []string
+	a := New("bot", "", "", p, Options{
+		MemoryRecall: func(query string) string {
+			queries = append(queries, query)
+			return "- [m1 preference] Answer tersely\n"
+		},
+	})
+
+	a.Chat().Append(message.NewText("user", role.User, "fix the build"))
+	_, err := a.Run(context.Background())
+	require.NoError(t, err)
+
+	prompt := a.Chat().SystemPrompt()
+	assert.Contains(t, prompt, "<memories>")
+	assert.Contains(t, prompt, "- [m1 preference] Answer tersely\n</memories>")
+
+	// Memories are recalled once per agent, so the prompt stays stable.
+	a.Chat().Append(message.NewText("user", role.User, "now the tests"))
+	_, err = a.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fix the build"}, queries)
+}
+
 func TestSystemPromptContextEmpty(t *testing.T) {
 	p := &sequenceCompleter{
 		replies: []message.Message{
@@ -548,6 +623,87 @@ func TestRunWithMiddleware(t *testing.T) {
 	}, order)
 }
 
+func TestRunWithToolMiddleware(t *testing.T) {
+	var order []string
+
+	rewrite := func(next ToolCaller) ToolCaller {
+		return func(ctx context.Context, tc content.ToolCall) content.ToolResult {
+			order = append(order, "rewrite:"+agentctx.AgentNameFromContext(ctx))
+			tc.Arguments = `{"msg":"rewritten"}`
+			return next(ctx, tc)
+		}
+	}
+	annotate := func(next ToolCaller) ToolCaller {
+		return func(ctx context.Context, tc content.ToolCall) content.ToolResult {
+			order = append(order, "annotate")
+			result := next(ctx, tc)
+			result.Content += " (annotated)"
+			return result
+		}
+	}
+
+	p := &sequenceCompleter{
+		replies: []message.Message{
+			message.New("", role.Assistant,
+				content.ToolCall{ID: "c1", Name: "echo", Arguments: `{"msg":"hi"}`},
+			),
+			message.NewText("", role.Assistant, "Done."),
+		},
+	}
+	a := New("bot", "", "", p, Options{
+		ToolMiddleware: []ToolMiddleware{rewrite, annotate},
+	})
+	a.AddToolBoxes(newEchoToolBox())
+
+	_, err := a.Run(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"rewrite:bot", "annotate"}, order)
+
+	msgs := a.Chat().Messages()
+	result, ok := msgs[len(msgs)-2].Parts[0].(content.ToolResult)
+	require.True(t, ok)
+	assert.Equal(t, `{"msg":"rewritten"} (annotated)`, result.Content)
+}
+
+func TestRunToolMiddlewareShortCircuits(t *testing.T) {
+	called := false
+	tb := toolbox.New()
+	tb.Register(toolbox.Tool{
+		Name:        "danger",
+		InputSchema: json.RawMessage(`{"type":"object"}`),
+		Handler: func(_ context.Context, _ json.RawMessage) (string, error) {
+			called = true
+			return "ran", nil
+		},
+	})
+
+	deny := func(_ ToolCaller) ToolCaller {
+		return func(_ context.Context, tc content.ToolCall) content.ToolResult {
+			return content.ToolResult{ToolCallID: tc.ID, Content: "blocked", IsError: true}
+		}
+	}
+
+	p := &sequenceCompleter{
+		replies: []message.Message{
+			message.New("", role.Assistant, content.ToolCall{ID: "c1", Name: "danger", Arguments: `{}`}),
+			message.NewText("", role.Assistant, "Done."),
+		},
+	}
+	a := New("bot", "", "", p, Options{ToolMiddleware: []ToolMiddleware{deny}})
+	a.AddToolBoxes(tb)
+
+	_, err := a.Run(context.Background())
+	require.NoError(t, err)
+	assert.False(t, called)
+
+	msgs := a.Chat().Messages()
+	result, ok := msgs[len(msgs)-2].Parts[0].(content.ToolResult)
+	require.True(t, ok)
+	assert.True(t, result.IsError)
+	assert.Equal(t, "blocked", result.Content)
+}
+
 // --- Delegation tests ---
 
 func TestDelegateToAgent(t *testing.T) {
@@ -232,11 +232,9 @@ func runInteractiveDelegate(ctx context.Context, a *Agent, tasks []delegateTask)
 }
 
 // propagateParentConfig applies the common parent-to-child configuration that
-// all delegation paths share: unique instance name, registry, event wiring,
-// reflection directory, and task board.
-func propagateParentConfig(parent, child *Agent, configName string, t delegateTask) {
-	child.name = fmt.Sprintf("%s-%s-%d", configName, taskSlug(t.Task), parent.registry.NextID(configName))
-	child.registry = parent.registry
+// all delegation paths share: event wiring, reflection directory, and task
+// board. The child must already have its instance name.
+func propagateParentConfig(parent, child *Agent) {
 	child.events.notifier = parent.events.notifier
 	child.events.eventFunc = delegationProgressFunc(parent.events.eventFunc, parent.events.notifier, child.name, parent.name)
 	child.events.cancelRegistrar = parent.events.cancelRegistrar
@@ -317,10 +315,10 @@ func runChildWithHandoff(ctx context.Context, a *Agent, child *Agent, t delegate
 	// Initialize and register the child's inbox for user message routing.
 	child.InitInbox()
 	if a.events.inboxRegistrar != nil {
-		a.events.inboxRegistrar(child.name, child.Inbox())
+		a.events.inboxRegistrar(ctx, child.name, child.Inbox())
 		defer func() {
 			if a.events.inboxUnregistrar != nil {
-				a.events.inboxUnregistrar(child.name)
+				a.events.inboxUnregistrar(ctx, child.name)
 			}
 		}()
 	}
@@ -579,22 +577,39 @@ type childConfig struct {
 	interaction *InteractionChannel // Interaction channel to wire; nil = NewInteractionChannel().
 }
 
-// spawnChild creates a configured child agent from the registry. It handles
-// unique naming, parent config propagation, interaction wiring, context/
-// reflection prepending, task message appending, and per-agent usage wrapping.
+// spawnChild creates a configured child agent from the registry: a task
+// agent (see spawnTaskAgent) one level below parent that inherits the
+// parent's configuration.
 func spawnChild(parent *Agent, cfg childConfig) (*Agent, error) {
-	child, ok := parent.registry.Spawn(cfg.agentName, parent.depth+1)
+	child, ok := spawnTaskAgent(parent.registry, parent.depth+1, cfg, func(child *Agent) {
+		propagateParentConfig(parent, child)
+	})
 	if !ok {
 		return nil, fmt.Errorf("agent %q not found", cfg.agentName)
 	}
+	return child, nil
+}
 
-	propagateParentConfig(parent, child, cfg.agentName, cfg.task)
+// spawnTaskAgent spawns a fresh instance of cfg.agentName from r at depth to
+// work on cfg.task. It handles unique naming, inherit (applied once the
+// instance is named; nil = nothing to inherit), per-agent usage wrapping,
+// interaction wiring, memory recall for the task, context/reflection
+// prepending, and task message appending. Returns nil and false if the name
+// is not registered.
+func spawnTaskAgent(r *Registry, depth int, cfg childConfig, inherit func(child *Agent)) (*Agent, bool) {
+	child, ok := r.Spawn(cfg.agentName, depth)
+	if !ok {
+		return nil, false
+	}
 
-	// Wrap the child's completer for independent per-agent usage tracking.
-	if child.usageDiffLock != nil {
-		child.completer = modeladapter.NewAgentUsageCompleter(child.completer, child.usageDiffLock)
+	child.name = fmt.Sprintf("%s-%s-%d", cfg.agentName, taskSlug(cfg.task.Task), r.NextID(cfg.agentName))
+	child.registry = r
+	if inherit != nil {
+		inherit(child)
 	}
 
+	child.TrackUsage()
+
 	if cfg.interaction != nil {
 		child.interaction = cfg.interaction
 	} else {
@@ -605,11 +620,13 @@ func spawnChild(parent *Agent, cfg childConfig) (*Agent, error) {
 		child.chat.Append(message.NewText("user", role.User, cfg.contextMsg))
 	}
 
-	if reflections := searchReflections(parent.delegation.reflectionDir, cfg.task.Task); reflections != "" {
+	child.RecallMemories(cfg.task.Task + "\n" + cfg.task.Context)
+
+	if reflections := searchReflections(child.delegation.reflectionDir, cfg.task.Task); reflections != "" {
 		child.chat.Append(message.NewText("user", role.User, reflections))
 	}
 
 	child.chat.Append(message.NewText("user", role.User, cfg.task.Task))
 
-	return child, nil
+	return child, true
 }
@@ -206,6 +206,34 @@ func TestDelegateToolSuccess(t *testing.T) {
 	assert.Equal(t, "done by worker", results[0].Result)
 }
 
+func TestDelegateToolChildRecallsMemories(t *testing.T) {
+	var query string
+	var captured []message.Message
+	reg := NewRegistry()
+	reg.Register("worker", "Does work", func() *Agent {
+		return New("worker", "", "", &capturingCompleter{
+			capture: &captured,
+			reply:   message.NewText("", role.Assistant, "done"),
+		}, Options{MemoryRecall: func(q string) string {
+			query = q
+			return "- [m2 fact] Migrations live in db/\n"
+		}})
+	})
+
+	a := &Agent{name: "orch", configName: "orch", registry: reg, chat: chat.New(), delegation: delegationConfig{maxDepth: 1}}
+	tool := delegateTool(a)
+
+	_, err := tool.Handler(context.Background(), json.RawMessage(
+		`{"tasks":[{"agent":"worker","task":"add a migration","context":"schema change"}]}`,
+	))
+	require.NoError(t, err)
+
+	// The child recalls with its task and context, not just its last message.
+	assert.Equal(t, "add a migration\nschema change", query)
+	require.NotEmpty(t, captured)
+	assert.Contains(t, captured[0].TextContent(), "Migrations live in db/")
+}
+
 func TestDelegateToolEmptyTasks(t *testing.T) {
 	a := &Agent{name: "orch", configName: "orch", registry: NewRegistry()}
 	tool := delegateTool(a)
@@ -26,8 +26,9 @@ type IterationContext struct {
 	Chat            *chat.Chat
 	Completer       modeladapter.Completer
 	AgentName       string
-	EstimatedTokens int // Pre-call token estimate (chat + tools). 0 = not computed.
-	ToolTokens      int // Cached token cost of tool definitions. 0 = not computed.
+	EstimatedTokens int          // Pre-call token estimate (chat + tools). 0 = not computed.
+	ToolTokens      int          // Token cost of the (filtered) tool definitions sent this iteration. 0 = not computed.
+	ToolArgs        ToolArgStats // Tool calls of this run whose arguments were repaired or rejected so far.
 }
 
 // Effect is a dynamic, per-iteration hook that runs inside the ReAct loop.
@@ -46,7 +47,9 @@ type Resetter interface {
 
 // ToolFilter is an optional interface that effects can implement to filter
 // which tools are sent to the LLM on each iteration. Multiple filters are
-// applied sequentially (intersection semantics).
+// applied sequentially (intersection semantics). Filters run before the
+// PhaseBeforeComplete effects, so the token estimates in the IterationContext
+// are not yet populated; those estimates cover only the filtered tools.
 type ToolFilter interface {
 	FilterTools(ctx context.Context, ic IterationContext, tools []toolbox.Tool) []toolbox.Tool
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/germanamz/shelly/pkg/chats/content"
 	"github.com/germanamz/shelly/pkg/chats/message"
 	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -150,3 +151,32 @@ func TestRunWithAfterCompleteEffectError(t *testing.T) {
 	_, err := a.Run(context.Background())
 	assert.EqualError(t, err, "after-complete abort")
 }
+
+// dropAllTools is a ToolFilter effect that hides every tool and records the
+// ToolTokens value seen by Eval.
+type dropAllTools struct {
+	toolTokens []int
+}
+
+func (d *dropAllTools) Eval(_ context.Context, ic IterationContext) error {
+	if ic.Phase == PhaseBeforeComplete {
+		d.toolTokens = append(d.toolTokens, ic.ToolTokens)
+	}
+	return nil
+}
+
+func (d *dropAllTools) FilterTools(_ context.Context, _ IterationContext, _ []toolbox.Tool) []toolbox.Tool {
+	return nil
+}
+
+func TestRun_ToolTokensReflectFilteredTools(t *testing.T) {
+	filter := &dropAllTools{}
+	a := New("bot", "", "", &sequenceCompleter{
+		replies: []message.Message{message.NewText("", role.Assistant, "done")},
+	}, Options{Effects: []Effect{filter}})
+	a.AddToolBoxes(newEchoToolBox())
+
+	_, err := a.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []int{0}, filter.toolTokens)
+}
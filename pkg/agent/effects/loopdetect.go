@@ -22,11 +22,14 @@ type LoopDetectConfig struct {
 }
 
 // LoopDetectEffect detects when an agent is stuck calling the same tool with
-// the same arguments repeatedly and injects an intervention message. It runs
-// only at PhaseBeforeComplete when Iteration > 0.
+// the same arguments repeatedly, or keeps sending tool calls whose arguments
+// are rejected as invalid (IterationContext.ToolArgs), and injects an
+// intervention message. It runs only at PhaseBeforeComplete when
+// Iteration > 0.
 type LoopDetectEffect struct {
 	cfg               LoopDetectConfig
 	lastInjectedCount int
+	lastInvalidCount  int
 }
 
 // NewLoopDetectEffect creates a LoopDetectEffect with the given configuration,
@@ -48,6 +51,17 @@ func (e *LoopDetectEffect) Eval(_ context.Context, ic agent.IterationContext) er
 		return nil
 	}
 
+	if invalid := ic.ToolArgs.ConsecutiveInvalid; invalid < e.cfg.Threshold {
+		e.lastInvalidCount = 0
+	} else if invalid > e.lastInvalidCount {
+		ic.Chat.Append(message.NewText("", role.User,
+			fmt.Sprintf("Your last %d tool calls were rejected for invalid arguments. Each error names the offending values by JSON pointer: fix exactly those values to match the tool's input schema, or use a different tool.", invalid),
+		))
+		e.lastInvalidCount = invalid
+
+		return nil
+	}
+
 	toolName, count := e.detectLoop(ic)
 	if count < e.cfg.Threshold {
 		e.lastInjectedCount = 0
@@ -67,7 +81,10 @@ func (e *LoopDetectEffect) Eval(_ context.Context, ic agent.IterationContext) er
 
 // Reset clears per-run state so the effect behaves correctly across multiple
 // Run() calls on a long-lived agent. Implements agent.Resetter.
-func (e *LoopDetectEffect) Reset() { e.lastInjectedCount = 0 }
+func (e *LoopDetectEffect) Reset() {
+	e.lastInjectedCount = 0
+	e.lastInvalidCount = 0
+}
 
 // detectLoop scans the last WindowSize assistant-role messages from the end of
 // the chat for ToolCall parts. It returns the tool name and the count of
@@ -291,3 +291,33 @@ func TestLoopDetectEffect_ResetClearsState(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 5, c2.Len(), "should inject again after reset")
 }
+
+func TestLoopDetectEffect_InvalidArguments(t *testing.T) {
+	e := NewLoopDetectEffect(LoopDetectConfig{})
+	c := chat.New(message.NewText("", role.System, "sys"))
+
+	eval := func(invalid int) {
+		t.Helper()
+		ic := agent.IterationContext{
+			Phase:     agent.PhaseBeforeComplete,
+			Iteration: 4,
+			Chat:      c,
+			ToolArgs:  agent.ToolArgStats{Invalid: invalid, ConsecutiveInvalid: invalid},
+		}
+		require.NoError(t, e.Eval(context.Background(), ic))
+	}
+
+	eval(2)
+	assert.Equal(t, 1, c.Len(), "below the threshold")
+
+	eval(3)
+	require.Equal(t, 2, c.Len())
+	assert.Contains(t, c.At(1).TextContent(), "last 3 tool calls were rejected for invalid arguments")
+	assert.Contains(t, c.At(1).TextContent(), "JSON pointer")
+
+	eval(3)
+	assert.Equal(t, 2, c.Len(), "no repeat without a new rejection")
+
+	eval(4)
+	assert.Equal(t, 3, c.Len())
+}
@@ -0,0 +1,166 @@
+package effects
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/germanamz/shelly/pkg/agent"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/projectctx"
+)
+
+const (
+	defaultNestedContextMaxRunes = 16000
+	nestedContextMetaKey         = "project_instructions"
+)
+
+// defaultNestedPathKeys are the tool argument keys treated as file paths.
+// They cover the filesystem toolbox (path, source, destination, file_a,
+// file_b) and the common "file" spelling used by MCP servers.
+var defaultNestedPathKeys = []string{"path", "source", "destination", "file_a", "file_b", "file"}
+
+// NestedContextConfig holds parameters for the NestedContextEffect.
+type NestedContextConfig struct {
+	Loader   *projectctx.NestedLoader // Discovers instruction files for a path.
+	PathKeys []string                 // Tool argument keys holding file paths (default: path, source, destination, file_a, file_b, file).
+	MaxRunes int                      // Cap per injected instruction file (default: 16000).
+}
+
+// NestedContextEffect lazily injects directory-scoped project instructions
+// (e.g. services/api/AGENTS.md) the first time the agent reads or edits a
+// file under that directory.
+//
+// It runs at PhaseBeforeComplete. It scans the tool calls in the chat for
+// path arguments, resolves the instruction files that apply to each path
+// (outermost directory first, so more specific instructions come last), and
+// appends one note per instruction file that has not yet been injected.
+// Injected notes are tagged with message metadata, so tracking is per chat
+// and survives session persistence; a note removed by compaction is
+// re-injected when its directory is touched again. Lookups are cached for
+// the duration of a run, so instruction files added or edited between runs
+// are picked up.
+type NestedContextEffect struct {
+	cfg      NestedContextConfig
+	resolved map[string][]projectctx.Instruction // path → applicable instructions
+}
+
+// NewNestedContextEffect creates a NestedContextEffect with the given
+// configuration, applying defaults for zero values.
+func NewNestedContextEffect(cfg NestedContextConfig) *NestedContextEffect {
+	if len(cfg.PathKeys) == 0 {
+		cfg.PathKeys = defaultNestedPathKeys
+	}
+	if cfg.MaxRunes <= 0 {
+		cfg.MaxRunes = defaultNestedContextMaxRunes
+	}
+
+	return &NestedContextEffect{cfg: cfg, resolved: make(map[string][]projectctx.Instruction)}
+}
+
+// Eval implements agent.Effect.
+func (e *NestedContextEffect) Eval(_ context.Context, ic agent.IterationContext) error {
+	if ic.Phase != agent.PhaseBeforeComplete || ic.Iteration == 0 || e.cfg.Loader == nil {
+		return nil
+	}
+
+	msgs := ic.Chat.Messages()
+
+	injected := make(map[string]struct{})
+	for _, m := range msgs {
+		if src, ok := m.GetMeta(nestedContextMetaKey); ok {
+			if s, ok := src.(string); ok {
+				injected[s] = struct{}{}
+			}
+		}
+	}
+
+	var notes []message.Message
+
+	for _, path := range e.touchedPaths(msgs) {
+		for _, inst := range e.resolve(path) {
+			if _, done := injected[inst.Source]; done {
+				continue
+			}
+			injected[inst.Source] = struct{}{}
+			notes = append(notes, e.note(inst))
+		}
+	}
+
+	if len(notes) > 0 {
+		ic.Chat.Append(notes...)
+	}
+
+	return nil
+}
+
+// touchedPaths returns the distinct path arguments of all tool calls in
+// msgs, in first-seen order.
+func (e *NestedContextEffect) touchedPaths(msgs []message.Message) []string {
+	seen := make(map[string]struct{})
+	var paths []string
+
+	for _, m := range msgs {
+		if m.Role != role.Assistant {
+			continue
+		}
+		for _, p := range m.Parts {
+			tc, ok := p.(content.ToolCall)
+			if !ok {
+				continue
+			}
+
+			var args map[string]any
+			if err := json.Unmarshal([]byte(tc.Arguments), &args); err != nil {
+				continue
+			}
+
+			for _, k := range e.cfg.PathKeys {
+				s, ok := args[k].(string)
+				if !ok || s == "" {
+					continue
+				}
+				if _, dup := seen[s]; dup {
+					continue
+				}
+				seen[s] = struct{}{}
+				paths = append(paths, s)
+			}
+		}
+	}
+
+	return paths
+}
+
+// Reset implements agent.Resetter. It drops the cached lookups so that the
+// next run sees the instruction files as they are on disk.
+func (e *NestedContextEffect) Reset() {
+	clear(e.resolved)
+}
+
+// resolve returns the instructions applying to path, caching the lookup so
+// the filesystem is only walked once per distinct path within a run.
+func (e *NestedContextEffect) resolve(path string) []projectctx.Instruction {
+	if insts, ok := e.resolved[path]; ok {
+		return insts
+	}
+	insts := e.cfg.Loader.ForPath(path)
+	e.resolved[path] = insts
+	return insts
+}
+
+// note builds the chat message carrying a single instruction file.
+func (e *NestedContextEffect) note(inst projectctx.Instruction) message.Message {
+	body := fmt.Sprintf(
+		"<project_instructions dir=%q source=%q>\n"+
+			"These instructions apply to files under %s/. Where they conflict, they take precedence over "+
+			"the project context and over instructions from parent directories.\n\n%s\n</project_instructions>",
+		inst.Dir, inst.Source, inst.Dir, truncate(inst.Content, e.cfg.MaxRunes),
+	)
+
+	msg := message.NewText("", role.User, body)
+	message.SetMeta(&msg, nestedContextMetaKey, inst.Source)
+	return msg
+}
@@ -0,0 +1,121 @@
+package effects
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/agent"
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/projectctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newNestedProject(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "svc", "api"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "svc", "AGENTS.md"), []byte("svc rules"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "svc", "api", "AGENTS.md"), []byte("api rules"), 0o600))
+	return root
+}
+
+func toolCallMsg(name, args string) message.Message {
+	return message.New("bot", role.Assistant, content.ToolCall{ID: "c-" + name, Name: name, Arguments: args})
+}
+
+func nestedNotes(ch *chat.Chat) []string {
+	var sources []string
+	for _, m := range ch.Messages() {
+		if v, ok := m.GetMeta(nestedContextMetaKey); ok {
+			sources = append(sources, v.(string))
+		}
+	}
+	return sources
+}
+
+func TestNestedContextEffect_InjectsOncePerSource(t *testing.T) {
+	root := newNestedProject(t)
+	e := NewNestedContextEffect(NestedContextConfig{Loader: projectctx.NewNestedLoader(root, nil, 0)})
+
+	ch := chat.New(
+		message.NewText("", role.System, "sys"),
+		toolCallMsg("fs_read", `{"path":"`+filepath.ToSlash(filepath.Join(root, "svc", "api", "h.go"))+`"}`),
+	)
+	ic := agent.IterationContext{Phase: agent.PhaseBeforeComplete, Iteration: 1, Chat: ch}
+
+	require.NoError(t, e.Eval(context.Background(), ic))
+	assert.Equal(t, []string{"svc/AGENTS.md", "svc/api/AGENTS.md"}, nestedNotes(ch))
+
+	last, _ := ch.Last()
+	assert.Equal(t, role.User, last.Role)
+	assert.Contains(t, last.TextContent(), "api rules")
+	assert.Contains(t, last.TextContent(), `dir="svc/api"`)
+
+	// A second file in the same tree does not re-inject.
+	ch.Append(toolCallMsg("fs_write", `{"path":"`+filepath.ToSlash(filepath.Join(root, "svc", "other.go"))+`"}`))
+	require.NoError(t, e.Eval(context.Background(), ic))
+	assert.Len(t, nestedNotes(ch), 2)
+}
+
+func TestNestedContextEffect_ResetRereadsFiles(t *testing.T) {
+	root := newNestedProject(t)
+	e := NewNestedContextEffect(NestedContextConfig{Loader: projectctx.NewNestedLoader(root, nil, 0)})
+	read := toolCallMsg("fs_read", `{"path":"svc/a.go"}`)
+
+	ch := chat.New(read)
+	ic := agent.IterationContext{Phase: agent.PhaseBeforeComplete, Iteration: 1, Chat: ch}
+	require.NoError(t, e.Eval(context.Background(), ic))
+	assert.Equal(t, []string{"svc/AGENTS.md"}, nestedNotes(ch))
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "svc", "AGENTS.md"), []byte("new svc rules"), 0o600))
+	e.Reset()
+
+	ch = chat.New(read)
+	ic.Chat = ch
+	require.NoError(t, e.Eval(context.Background(), ic))
+	last, _ := ch.Last()
+	assert.Contains(t, last.TextContent(), "new svc rules")
+}
+
+func TestNestedContextEffect_AlternatePathKeys(t *testing.T) {
+	root := newNestedProject(t)
+	e := NewNestedContextEffect(NestedContextConfig{Loader: projectctx.NewNestedLoader(root, nil, 0)})
+
+	ch := chat.New(toolCallMsg("fs_move", `{"source":"/elsewhere/x","destination":"`+filepath.ToSlash(filepath.Join(root, "svc", "y.go"))+`"}`))
+
+	require.NoError(t, e.Eval(context.Background(), agent.IterationContext{Phase: agent.PhaseBeforeComplete, Iteration: 1, Chat: ch}))
+	assert.Equal(t, []string{"svc/AGENTS.md"}, nestedNotes(ch))
+}
+
+func TestNestedContextEffect_SkipsIterationZeroAndAfterPhase(t *testing.T) {
+	root := newNestedProject(t)
+	e := NewNestedContextEffect(NestedContextConfig{Loader: projectctx.NewNestedLoader(root, nil, 0)})
+
+	ch := chat.New(toolCallMsg("fs_read", `{"path":"`+filepath.ToSlash(filepath.Join(root, "svc", "a.go"))+`"}`))
+
+	require.NoError(t, e.Eval(context.Background(), agent.IterationContext{Phase: agent.PhaseBeforeComplete, Iteration: 0, Chat: ch}))
+	require.NoError(t, e.Eval(context.Background(), agent.IterationContext{Phase: agent.PhaseAfterComplete, Iteration: 1, Chat: ch}))
+	assert.Empty(t, nestedNotes(ch))
+}
+
+func TestNestedContextEffect_TruncatesLongInstructions(t *testing.T) {
+	root := newNestedProject(t)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "svc", "AGENTS.md"), []byte("abcdefghij"), 0o600))
+	e := NewNestedContextEffect(NestedContextConfig{
+		Loader:   projectctx.NewNestedLoader(root, nil, 0),
+		MaxRunes: 4,
+	})
+
+	ch := chat.New(toolCallMsg("fs_read", `{"path":"`+filepath.ToSlash(filepath.Join(root, "svc", "a.go"))+`"}`))
+	require.NoError(t, e.Eval(context.Background(), agent.IterationContext{Phase: agent.PhaseBeforeComplete, Iteration: 1, Chat: ch}))
+
+	last, _ := ch.Last()
+	assert.Contains(t, last.TextContent(), "abcd…")
+	assert.NotContains(t, last.TextContent(), "abcde")
+}
@@ -27,7 +27,9 @@ type StallDetectConfig struct {
 // StallDetectEffect detects when an agent is active but not progressing —
 // calling different tools but getting the same errors, reading the same files,
 // or producing no meaningful output. It complements LoopDetectEffect which
-// catches exact consecutive repetition.
+// catches exact consecutive repetition. A run of Window consecutive tool calls
+// rejected for invalid arguments (IterationContext.ToolArgs) also counts as a
+// stall.
 //
 // It runs at PhaseBeforeComplete when Iteration > 0. On first trigger it
 // injects a nudge message. If stall continues for another window, it returns
@@ -62,24 +64,7 @@ func (e *StallDetectEffect) Eval(_ context.Context, ic agent.IterationContext) e
 		return nil
 	}
 
-	fps := e.collectFingerprints(ic)
-	if len(fps) < e.cfg.Window {
-		return nil
-	}
-
-	// Count how many fingerprints in the window are duplicates of others.
-	seen := make(map[string]struct{})
-	duplicates := 0
-	for _, fp := range fps {
-		if _, ok := seen[fp]; ok {
-			duplicates++
-		} else {
-			seen[fp] = struct{}{}
-		}
-	}
-
-	ratio := float64(duplicates) / float64(len(fps))
-	if ratio < e.cfg.SimilarityThreshold {
+	if !e.stalled(ic) {
 		return nil
 	}
 
@@ -95,7 +80,7 @@ func (e *StallDetectEffect) Eval(_ context.Context, ic agent.IterationContext) e
 			fmt.Sprintf(
 				"You appear stalled. The last %d iterations produced similar results. "+
 					"Step back and reconsider your approach.",
-				len(fps),
+				e.cfg.Window,
 			),
 		))
 	}
@@ -103,6 +88,34 @@ func (e *StallDetectEffect) Eval(_ context.Context, ic agent.IterationContext) e
 	return nil
 }
 
+// stalled reports whether the recent tool results look stuck: the last
+// Window calls were all rejected for invalid arguments, or enough of the last
+// Window results duplicate each other.
+func (e *StallDetectEffect) stalled(ic agent.IterationContext) bool {
+	if ic.ToolArgs.ConsecutiveInvalid >= e.cfg.Window {
+		return true
+	}
+
+	fps := e.collectFingerprints(ic)
+	if len(fps) < e.cfg.Window {
+		return false
+	}
+
+	// Count how many fingerprints in the window are duplicates of others.
+	seen := make(map[string]struct{})
+	duplicates := 0
+	for _, fp := range fps {
+		if _, ok := seen[fp]; ok {
+			duplicates++
+		} else {
+			seen[fp] = struct{}{}
+		}
+	}
+
+	ratio := float64(duplicates) / float64(len(fps))
+	return ratio >= e.cfg.SimilarityThreshold
+}
+
 // collectFingerprints scans the chat and builds fingerprints for the most
 // recent tool-call/tool-result pairs within the configured window. A
 // fingerprint is: toolName + isError + hash(first N chars of result).
@@ -216,3 +216,23 @@ func TestStallDetectEffect_SameToolSameErrorTriggers(t *testing.T) {
 	assert.Equal(t, role.User, last.Role)
 	assert.Contains(t, last.TextContent(), "stalled")
 }
+
+func TestStallDetectEffect_InvalidArgumentsTrigger(t *testing.T) {
+	e := NewStallDetectEffect(StallDetectConfig{Window: 4})
+
+	// The rejections differ, so their fingerprints do not repeat.
+	c := chat.New(message.NewText("", role.System, "sys"))
+	for i := range 4 {
+		addToolRound(c, fmt.Sprintf("c%d", i), "fs_read", `{}`, fmt.Sprintf("invalid arguments for fs_read: %d", i), true)
+	}
+
+	ic := newStallIC(5, c)
+	ic.ToolArgs = agent.ToolArgStats{Invalid: 3, ConsecutiveInvalid: 3}
+	require.NoError(t, e.Eval(context.Background(), ic))
+	assert.False(t, e.nudged)
+
+	ic.ToolArgs = agent.ToolArgStats{Invalid: 4, ConsecutiveInvalid: 4}
+	require.NoError(t, e.Eval(context.Background(), ic))
+	assert.True(t, e.nudged)
+	assert.Contains(t, c.At(c.Len()-1).TextContent(), "stalled")
+}
@@ -0,0 +1,464 @@
+package effects
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/germanamz/shelly/pkg/agent"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+)
+
+const (
+	defaultToolSearchMaxResults = 5
+	findToolsName               = "find_tools"
+
+	// BM25 tuning constants.
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// builtinCoreTools are agent-provided tools that must stay visible for the
+// loop to terminate and delegate correctly, regardless of configuration.
+var builtinCoreTools = []string{
+	findToolsName,
+	"task_complete",
+	"handoff",
+	"request_input",
+	"delegate",
+	"list_agents",
+	"answer_delegation_questions",
+}
+
+// ToolSearchConfig holds parameters for the ToolSearchEffect.
+type ToolSearchConfig struct {
+	Core       []string // Tool names always sent to the LLM.
+	MaxResults int      // Max tools activated per find_tools call (default: 5).
+	Threshold  int      // Only hide tools when the agent has more than this many (0 = always).
+}
+
+// ToolSearchEffect keeps large toolsets out of the LLM request. Only the core
+// tools and a find_tools meta-tool are sent; find_tools runs a BM25 search over
+// the names, descriptions and input schemas of every tool available to the
+// agent and activates the best matches for all following iterations.
+//
+// It implements agent.Effect (no-op Eval), agent.ToolFilter and
+// agent.ToolProvider. Activated tools persist across Run() calls so that tools
+// referenced earlier in a long-lived session stay callable.
+type ToolSearchEffect struct {
+	cfg  ToolSearchConfig
+	core map[string]struct{}
+
+	mu        sync.Mutex
+	catalog   []toolbox.Tool
+	index     *bm25Index
+	activated map[string]struct{}
+}
+
+// NewToolSearchEffect creates a ToolSearchEffect with the given configuration,
+// applying defaults for zero values.
+func NewToolSearchEffect(cfg ToolSearchConfig) *ToolSearchEffect {
+	if cfg.MaxResults <= 0 {
+		cfg.MaxResults = defaultToolSearchMaxResults
+	}
+
+	core := make(map[string]struct{}, len(cfg.Core)+len(builtinCoreTools))
+	for _, name := range builtinCoreTools {
+		core[name] = struct{}{}
+	}
+	for _, name := range cfg.Core {
+		core[name] = struct{}{}
+	}
+
+	return &ToolSearchEffect{
+		cfg:       cfg,
+		core:      core,
+		activated: make(map[string]struct{}),
+	}
+}
+
+// Eval implements agent.Effect. ToolSearchEffect is a no-op during evaluation;
+// all work happens in FilterTools and the find_tools handler.
+func (e *ToolSearchEffect) Eval(_ context.Context, _ agent.IterationContext) error {
+	return nil
+}
+
+// FilterTools implements agent.ToolFilter. It records the full tool list as
+// the search catalog and returns only core and activated tools.
+func (e *ToolSearchEffect) FilterTools(_ context.Context, _ agent.IterationContext, tools []toolbox.Tool) []toolbox.Tool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.updateCatalog(tools)
+
+	// Toolsets at or below the threshold are sent as-is, minus the meta-tool.
+	scoped := len(tools) > e.cfg.Threshold
+
+	filtered := make([]toolbox.Tool, 0, len(tools))
+	for _, t := range tools {
+		switch {
+		case t.Name == findToolsName:
+			if scoped {
+				filtered = append(filtered, t)
+			}
+		case !scoped || e.isVisible(t.Name):
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered
+}
+
+// Activated returns the sorted names of tools activated via find_tools.
+func (e *ToolSearchEffect) Activated() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	names := make([]string, 0, len(e.activated))
+	for name := range e.activated {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Search returns up to limit catalog tools ranked by relevance to query.
+// Core and already-activated tools are included in the ranking.
+func (e *ToolSearchEffect) Search(query string, limit int) []toolbox.Tool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.search(query, limit)
+}
+
+// ProvidedTools implements agent.ToolProvider, returning a toolbox containing
+// the find_tools meta-tool.
+func (e *ToolSearchEffect) ProvidedTools() *toolbox.ToolBox {
+	tb := toolbox.New()
+	tb.Register(toolbox.Tool{
+		Name: findToolsName,
+		Description: "Search the full set of available tools by keyword and activate the best matches. " +
+			"Only a core set of tools is visible by default; call this when you need a capability you do not see. " +
+			"Activated tools become callable from your next step onwards.",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{"query":{"type":"string","description":"Keywords describing the capability you need (e.g. \"create github issue\")"},"names":{"type":"array","items":{"type":"string"},"description":"Exact tool names to activate, when already known"},"limit":{"type":"integer","description":"Maximum number of tools to activate (default 5)"}}}`),
+		Handler:     e.handleFindTools,
+	})
+	return tb
+}
+
+// handleFindTools implements the find_tools tool.
+func (e *ToolSearchEffect) handleFindTools(_ context.Context, input json.RawMessage) (string, error) {
+	var args struct {
+		Query string   `json:"query"`
+		Names []string `json:"names"`
+		Limit int      `json:"limit"`
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		return "", fmt.Errorf("find_tools: invalid input: %w", err)
+	}
+	if strings.TrimSpace(args.Query) == "" && len(args.Names) == 0 {
+		return "", errors.New("find_tools: query or names is required")
+	}
+
+	limit := args.Limit
+	if limit <= 0 || limit > e.cfg.MaxResults {
+		limit = e.cfg.MaxResults
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var (
+		matches []toolbox.Tool
+		unknown []string
+		seen    = make(map[string]struct{})
+	)
+
+	for _, name := range args.Names {
+		t, ok := e.lookup(name)
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		seen[t.Name] = struct{}{}
+		matches = append(matches, t)
+	}
+
+	if strings.TrimSpace(args.Query) != "" {
+		for _, t := range e.search(args.Query, limit) {
+			if _, dup := seen[t.Name]; dup {
+				continue
+			}
+			seen[t.Name] = struct{}{}
+			matches = append(matches, t)
+		}
+	}
+
+	var b strings.Builder
+	if len(matches) == 0 {
+		b.WriteString("No matching tools found. Try different keywords.")
+	} else {
+		b.WriteString("Activated tools (callable from your next step):\n")
+		for _, t := range matches {
+			if !e.isCore(t.Name) {
+				e.activated[t.Name] = struct{}{}
+			}
+			fmt.Fprintf(&b, "- %s: %s\n", t.Name, truncate(firstLine(t.Description), 200))
+		}
+	}
+	if len(unknown) > 0 {
+		fmt.Fprintf(&b, "\nUnknown tool names: %s", strings.Join(unknown, ", "))
+	}
+
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// updateCatalog rebuilds the search index when the set of tool names changes.
+// Must be called with e.mu held.
+func (e *ToolSearchEffect) updateCatalog(tools []toolbox.Tool) {
+	if e.index != nil && sameToolNames(e.catalog, tools) {
+		return
+	}
+
+	e.catalog = make([]toolbox.Tool, 0, len(tools))
+	docs := make([][]string, 0, len(tools))
+	for _, t := range tools {
+		if t.Name == findToolsName {
+			continue
+		}
+		e.catalog = append(e.catalog, t)
+		docs = append(docs, toolTerms(t))
+	}
+	e.index = newBM25Index(docs)
+}
+
+// search ranks catalog tools against query. Must be called with e.mu held.
+func (e *ToolSearchEffect) search(query string, limit int) []toolbox.Tool {
+	if e.index == nil || limit <= 0 {
+		return nil
+	}
+
+	ranked := e.index.rank(tokenize(query))
+	out := make([]toolbox.Tool, 0, min(limit, len(ranked)))
+	for _, idx := range ranked {
+		if len(out) == limit {
+			break
+		}
+		out = append(out, e.catalog[idx])
+	}
+	return out
+}
+
+// lookup finds a catalog tool by exact name. Must be called with e.mu held.
+func (e *ToolSearchEffect) lookup(name string) (toolbox.Tool, bool) {
+	for _, t := range e.catalog {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return toolbox.Tool{}, false
+}
+
+func (e *ToolSearchEffect) isCore(name string) bool {
+	_, ok := e.core[name]
+	return ok
+}
+
+func (e *ToolSearchEffect) isVisible(name string) bool {
+	if e.isCore(name) {
+		return true
+	}
+	_, ok := e.activated[name]
+	return ok
+}
+
+// sameToolNames reports whether the catalog (which excludes find_tools)
+// holds exactly the non-meta tools in tools, in order.
+func sameToolNames(catalog, tools []toolbox.Tool) bool {
+	i := 0
+	for _, t := range tools {
+		if t.Name == findToolsName {
+			continue
+		}
+		if i >= len(catalog) || catalog[i].Name != t.Name {
+			return false
+		}
+		i++
+	}
+	return i == len(catalog)
+}
+
+// firstLine returns s up to its first newline.
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// toolTerms extracts searchable terms from a tool's name, description and
+// input schema (property names, descriptions and enum values). The name is
+// repeated so that name matches outweigh incidental description matches.
+func toolTerms(t toolbox.Tool) []string {
+	nameTerms := tokenize(t.Name)
+	terms := make([]string, 0, len(nameTerms)*2+16)
+	terms = append(terms, nameTerms...)
+	terms = append(terms, nameTerms...)
+	terms = append(terms, tokenize(t.Description)...)
+
+	if len(t.InputSchema) > 0 {
+		var schema any
+		if err := json.Unmarshal(t.InputSchema, &schema); err == nil {
+			terms = appendSchemaTerms(terms, schema)
+		}
+	}
+	return terms
+}
+
+// appendSchemaTerms walks a decoded JSON schema collecting property names,
+// descriptions and string enum values.
+func appendSchemaTerms(terms []string, node any) []string {
+	switch v := node.(type) {
+	case map[string]any:
+		for key, child := range v {
+			switch key {
+			case "properties":
+				if props, ok := child.(map[string]any); ok {
+					for name, prop := range props {
+						terms = append(terms, tokenize(name)...)
+						terms = appendSchemaTerms(terms, prop)
+					}
+				}
+			case "description", "title":
+				if s, ok := child.(string); ok {
+					terms = append(terms, tokenize(s)...)
+				}
+			case "enum":
+				if vals, ok := child.([]any); ok {
+					for _, val := range vals {
+						if s, ok := val.(string); ok {
+							terms = append(terms, tokenize(s)...)
+						}
+					}
+				}
+			default:
+				terms = appendSchemaTerms(terms, child)
+			}
+		}
+	case []any:
+		for _, child := range v {
+			terms = appendSchemaTerms(terms, child)
+		}
+	}
+	return terms
+}
+
+// tokenize lowercases s and splits it into alphanumeric terms, breaking
+// snake_case, kebab-case, dotted and camelCase identifiers apart.
+func tokenize(s string) []string {
+	var (
+		terms []string
+		cur   []rune
+		prev  rune
+	)
+
+	flush := func() {
+		if len(cur) > 0 {
+			terms = append(terms, string(cur))
+			cur = cur[:0]
+		}
+	}
+
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if unicode.IsUpper(r) && unicode.IsLower(prev) {
+				flush()
+			}
+			cur = append(cur, unicode.ToLower(r))
+		default:
+			flush()
+		}
+		prev = r
+	}
+	flush()
+
+	return terms
+}
+
+// bm25Index is a minimal in-memory Okapi BM25 index over tokenized documents.
+type bm25Index struct {
+	docs   []map[string]int // term frequencies per document
+	lens   []int
+	avgLen float64
+	df     map[string]int
+}
+
+// newBM25Index builds an index from pre-tokenized documents.
+func newBM25Index(docs [][]string) *bm25Index {
+	idx := &bm25Index{
+		docs: make([]map[string]int, len(docs)),
+		lens: make([]int, len(docs)),
+		df:   make(map[string]int),
+	}
+
+	total := 0
+	for i, terms := range docs {
+		tf := make(map[string]int, len(terms))
+		for _, term := range terms {
+			tf[term]++
+		}
+		for term := range tf {
+			idx.df[term]++
+		}
+		idx.docs[i] = tf
+		idx.lens[i] = len(terms)
+		total += len(terms)
+	}
+	if len(docs) > 0 {
+		idx.avgLen = float64(total) / float64(len(docs))
+	}
+	return idx
+}
+
+// rank returns the indices of documents with a positive score for the query
+// terms, ordered by descending score (ties keep catalog order).
+func (idx *bm25Index) rank(query []string) []int {
+	n := float64(len(idx.docs))
+	if n == 0 || len(query) == 0 {
+		return nil
+	}
+
+	scores := make([]float64, len(idx.docs))
+	for _, term := range query {
+		df := idx.df[term]
+		if df == 0 {
+			continue
+		}
+		idf := math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+		for i, tf := range idx.docs {
+			f := float64(tf[term])
+			if f == 0 {
+				continue
+			}
+			norm := 1 - bm25B + bm25B*float64(idx.lens[i])/idx.avgLen
+			scores[i] += idf * f * (bm25K1 + 1) / (f + bm25K1*norm)
+		}
+	}
+
+	var ranked []int
+	for i, s := range scores {
+		if s > 0 {
+			ranked = append(ranked, i)
+		}
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		return scores[ranked[a]] > scores[ranked[b]]
+	})
+	return ranked
+}
@@ -0,0 +1,126 @@
+package effects
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/agent"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func searchTestTools() []toolbox.Tool {
+	return []toolbox.Tool{
+		{Name: "fs_read", Description: "Read a file from disk", InputSchema: json.RawMessage(`{"type":"object","properties":{"path":{"type":"string"}}}`)},
+		{Name: "github_create_issue", Description: "Open a new issue in a repository", InputSchema: json.RawMessage(`{"type":"object","properties":{"title":{"type":"string"},"body":{"type":"string"}}}`)},
+		{Name: "github_list_pulls", Description: "List pull requests", InputSchema: json.RawMessage(`{"type":"object","properties":{"state":{"type":"string","enum":["open","closed"]}}}`)},
+		{Name: "slack_post", Description: "Post a chat message", InputSchema: json.RawMessage(`{"type":"object","properties":{"channel":{"type":"string","description":"Target channel name"}}}`)},
+		{Name: "task_complete", Description: "Finish", InputSchema: json.RawMessage(`{}`)},
+		{Name: findToolsName, Description: "Find tools", InputSchema: json.RawMessage(`{}`)},
+	}
+}
+
+func toolNames(tools []toolbox.Tool) []string {
+	names := make([]string, len(tools))
+	for i, t := range tools {
+		names[i] = t.Name
+	}
+	return names
+}
+
+func callFindTools(t *testing.T, e *ToolSearchEffect, input string) string {
+	t.Helper()
+	tb := e.ProvidedTools()
+	require.NotNil(t, tb)
+	tool, ok := tb.Get(findToolsName)
+	require.True(t, ok)
+	out, err := tool.Handler(context.Background(), json.RawMessage(input))
+	require.NoError(t, err)
+	return out
+}
+
+func TestToolSearchEffect_FiltersToCoreTools(t *testing.T) {
+	e := NewToolSearchEffect(ToolSearchConfig{Core: []string{"fs_read"}})
+
+	filtered := e.FilterTools(context.Background(), agent.IterationContext{}, searchTestTools())
+
+	assert.Equal(t, []string{"fs_read", "task_complete", findToolsName}, toolNames(filtered))
+}
+
+func TestToolSearchEffect_FindToolsActivatesMatches(t *testing.T) {
+	e := NewToolSearchEffect(ToolSearchConfig{MaxResults: 1})
+	tools := searchTestTools()
+	e.FilterTools(context.Background(), agent.IterationContext{}, tools)
+
+	out := callFindTools(t, e, `{"query":"create an issue"}`)
+	assert.Contains(t, out, "github_create_issue")
+	assert.Equal(t, []string{"github_create_issue"}, e.Activated())
+
+	filtered := e.FilterTools(context.Background(), agent.IterationContext{}, tools)
+	assert.Equal(t, []string{"github_create_issue", "task_complete", findToolsName}, toolNames(filtered))
+}
+
+func TestToolSearchEffect_SearchesSchemaTerms(t *testing.T) {
+	e := NewToolSearchEffect(ToolSearchConfig{})
+	e.FilterTools(context.Background(), agent.IterationContext{}, searchTestTools())
+
+	results := e.Search("channel", 5)
+	require.Len(t, results, 1)
+	assert.Equal(t, "slack_post", results[0].Name)
+
+	results = e.Search("closed", 5)
+	require.Len(t, results, 1)
+	assert.Equal(t, "github_list_pulls", results[0].Name)
+}
+
+func TestToolSearchEffect_RanksNameMatchesFirst(t *testing.T) {
+	e := NewToolSearchEffect(ToolSearchConfig{})
+	e.FilterTools(context.Background(), agent.IterationContext{}, searchTestTools())
+
+	results := e.Search("github pull requests", 5)
+	require.NotEmpty(t, results)
+	assert.Equal(t, "github_list_pulls", results[0].Name)
+}
+
+func TestToolSearchEffect_ActivateByName(t *testing.T) {
+	e := NewToolSearchEffect(ToolSearchConfig{})
+	e.FilterTools(context.Background(), agent.IterationContext{}, searchTestTools())
+
+	out := callFindTools(t, e, `{"names":["slack_post","nope"]}`)
+	assert.Contains(t, out, "slack_post")
+	assert.Contains(t, out, "Unknown tool names: nope")
+	assert.Equal(t, []string{"slack_post"}, e.Activated())
+}
+
+func TestToolSearchEffect_NoMatches(t *testing.T) {
+	e := NewToolSearchEffect(ToolSearchConfig{})
+	e.FilterTools(context.Background(), agent.IterationContext{}, searchTestTools())
+
+	out := callFindTools(t, e, `{"query":"kubernetes"}`)
+	assert.Contains(t, out, "No matching tools found")
+	assert.Empty(t, e.Activated())
+}
+
+func TestToolSearchEffect_RequiresQueryOrNames(t *testing.T) {
+	e := NewToolSearchEffect(ToolSearchConfig{})
+	tool, ok := e.ProvidedTools().Get(findToolsName)
+	require.True(t, ok)
+
+	_, err := tool.Handler(context.Background(), json.RawMessage(`{}`))
+	assert.ErrorContains(t, err, "query or names is required")
+}
+
+func TestToolSearchEffect_BelowThresholdPassesThrough(t *testing.T) {
+	e := NewToolSearchEffect(ToolSearchConfig{Threshold: 10})
+
+	filtered := e.FilterTools(context.Background(), agent.IterationContext{}, searchTestTools())
+
+	assert.Equal(t, []string{"fs_read", "github_create_issue", "github_list_pulls", "slack_post", "task_complete"}, toolNames(filtered))
+}
+
+func TestTokenize_SplitsIdentifiers(t *testing.T) {
+	assert.Equal(t, []string{"git", "hub", "create", "issue"}, tokenize("gitHub_create-issue"))
+	assert.Equal(t, []string{"read", "file", "v2"}, tokenize("Read file.v2"))
+}
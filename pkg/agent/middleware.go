@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/germanamz/shelly/pkg/chats/content"
 	"github.com/germanamz/shelly/pkg/chats/message"
 )
 
@@ -25,6 +26,16 @@ func (f RunnerFunc) Run(ctx context.Context) (message.Message, error) {
 // Middleware wraps a Runner, returning a new Runner with added behaviour.
 type Middleware func(next Runner) Runner
 
+// ToolCaller executes a single tool call and returns its result.
+type ToolCaller func(ctx context.Context, tc content.ToolCall) content.ToolResult
+
+// ToolMiddleware wraps a ToolCaller, returning a new ToolCaller with added
+// behaviour. It is applied around every tool call in the ReAct loop and may
+// rewrite the call, short-circuit it with its own result, or post-process the
+// result. Tool calls from one reply run concurrently, so implementations must
+// be safe for concurrent use.
+type ToolMiddleware func(next ToolCaller) ToolCaller
+
 // --- Timeout middleware ---
 
 // Timeout returns a Middleware that wraps the runner's context with a deadline.
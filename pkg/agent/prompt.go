@@ -13,6 +13,7 @@ type promptBuilder struct {
 	Name, Description, Instructions, Context, ConfigName string
 	Depth                                                int
 	Skills                                               []skill.Skill
+	Memories                                             string
 	DisableBehavioralHints                               bool
 	HasNotesTools                                        bool
 	CanDelegate                                          bool
@@ -136,6 +137,16 @@ func (pb *promptBuilder) build() string {
 		b.WriteString("\n</project_context>\n")
 	}
 
+	// Memories recalled from earlier sessions.
+	if pb.Memories != "" {
+		b.WriteString("\n<memories>\n")
+		b.WriteString("Long-term memories saved in earlier sessions that are relevant to this task ")
+		b.WriteString("(user preferences, project facts and procedures). Follow them unless told otherwise. ")
+		b.WriteString("When one turns out to be wrong or outdated, correct it with update_memory or forget_memory.\n\n")
+		b.WriteString(pb.Memories)
+		b.WriteString("</memories>\n")
+	}
+
 	// Skills — split into inline (no description) and on-demand (has description).
 	var inline, onDemand []skill.Skill
 	for _, s := range pb.Skills {
@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/germanamz/shelly/pkg/skill"
@@ -54,6 +55,18 @@ func TestPromptBuilderNoNotesProtocol(t *testing.T) {
 	assert.NotContains(t, prompt, "<notes_protocol>")
 }
 
+func TestPromptBuilderMemories(t *testing.T) {
+	pb := promptBuilder{Name: "bot", Context: "ctx", Memories: "- [m1 fact] Uses Go\n"}
+	prompt := pb.build()
+
+	assert.Contains(t, prompt, "<memories>")
+	assert.Contains(t, prompt, "- [m1 fact] Uses Go\n</memories>")
+	assert.Greater(t, strings.Index(prompt, "<memories>"), strings.Index(prompt, "<project_context>"))
+
+	pb.Memories = ""
+	assert.NotContains(t, pb.build(), "<memories>")
+}
+
 func TestPromptBuilderInstructions(t *testing.T) {
 	pb := promptBuilder{Name: "bot", Instructions: "Be helpful."}
 	prompt := pb.build()
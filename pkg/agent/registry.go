@@ -111,3 +111,19 @@ func (r *Registry) Spawn(name string, depth int) (*Agent, bool) {
 
 	return agent, true
 }
+
+// SpawnTask creates a sub-agent for a task scheduled outside a delegate call,
+// such as an engine workflow step. It is set up like a delegated child (see
+// spawnTaskAgent) at depth 1, so it reports through task_complete, and is
+// initialized with task as its last user message. Returns nil and false if
+// the name is not registered.
+func (r *Registry) SpawnTask(name, task string) (*Agent, bool) {
+	a, ok := spawnTaskAgent(r, 1, childConfig{agentName: name, task: delegateTask{Agent: name, Task: task}}, nil)
+	if !ok {
+		return nil, false
+	}
+
+	a.Init()
+
+	return a, true
+}
@@ -3,6 +3,8 @@ package agent
 import (
 	"testing"
 
+	"github.com/germanamz/shelly/pkg/chats/role"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -64,6 +66,58 @@ func TestRegistrySpawn(t *testing.T) {
 	assert.Equal(t, 3, agent.depth)
 }
 
+func TestRegistrySpawnTask(t *testing.T) {
+	r := NewRegistry()
+	r.Register("worker", "Does work", func() *Agent {
+		return New("worker", "Does work", "Work hard", nil, Options{})
+	})
+
+	a1, ok := r.SpawnTask("worker", "Review the parser")
+	require.True(t, ok)
+	a2, _ := r.SpawnTask("worker", "Review the parser")
+
+	assert.Equal(t, 1, a1.depth)
+	assert.Equal(t, "worker", a1.ConfigName())
+	assert.Same(t, r, a1.registry)
+	assert.NotEqual(t, a1.Name(), a2.Name())
+
+	msgs := a1.Chat().Messages()
+	require.Len(t, msgs, 2)
+	assert.Equal(t, role.System, msgs[0].Role)
+	assert.Equal(t, "Review the parser", msgs[1].TextContent())
+
+	_, ok = r.SpawnTask("missing", "anything")
+	assert.False(t, ok)
+}
+
+func TestRegistrySpawnTask_SetupLikeDelegation(t *testing.T) {
+	dir := t.TempDir()
+	writeReflection(dir, "worker", "Review the parser", &CompletionResult{Status: "failed", Summary: "parser tests were flaky"})
+
+	var recalled string
+	r := NewRegistry()
+	r.Register("worker", "Does work", func() *Agent {
+		return New("worker", "Does work", "Work hard", nil, Options{
+			ReflectionDir: dir,
+			MemoryRecall: func(query string) string {
+				recalled = query
+				return "- the parser lives in pkg/parse"
+			},
+		})
+	})
+
+	a, ok := r.SpawnTask("worker", "Review the parser")
+	require.True(t, ok)
+	assert.NotNil(t, a.interaction)
+	assert.Equal(t, "Review the parser\n", recalled)
+	assert.Contains(t, a.Chat().Messages()[0].TextContent(), "the parser lives in pkg/parse")
+
+	msgs := a.Chat().Messages()
+	require.Len(t, msgs, 3)
+	assert.Contains(t, msgs[1].TextContent(), "parser tests were flaky", "past reflections precede the task")
+	assert.Equal(t, "Review the parser", msgs[2].TextContent())
+}
+
 func TestRegistrySpawnMissing(t *testing.T) {
 	r := NewRegistry()
 
@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+)
+
+// tokenConfig groups the fields used for pre-call token estimates.
+type tokenConfig struct {
+	estimator   modeladapter.TokenEstimator
+	calibration *modeladapter.Calibration
+	counter     modeladapter.TokenCounter
+}
+
+// estimateTools returns the calibrated token cost of the tool definitions.
+func (t tokenConfig) estimateTools(tools []toolbox.Tool) int {
+	return t.calibration.Apply(t.estimator.EstimateTools(tools))
+}
+
+// estimateTotal returns the input tokens of the next request: the provider's
+// exact count when a counter is configured and succeeds, otherwise the
+// calibrated estimate.
+func (t tokenConfig) estimateTotal(ctx context.Context, c *chat.Chat, tools []toolbox.Tool) int {
+	if t.counter != nil {
+		n, err := t.counter.CountTokens(ctx, c, tools)
+		if err == nil {
+			return n
+		}
+		slog.Debug("agent: token count failed, using estimate", "err", err)
+	}
+	return t.calibration.Apply(t.estimator.EstimateTotal(c, tools))
+}
+
+// rawEstimate returns the uncalibrated estimate calibration learns from, or 0
+// when calibration is off.
+func (t tokenConfig) rawEstimate(c *chat.Chat, tools []toolbox.Tool) int {
+	if t.calibration == nil {
+		return 0
+	}
+	return t.estimator.EstimateTotal(c, tools)
+}
+
+// observe feeds the usage reported for the last call into calibration.
+func (t tokenConfig) observe(completer modeladapter.Completer, estimated int) {
+	if t.calibration == nil {
+		return
+	}
+	reporter, ok := completer.(modeladapter.UsageReporter)
+	if !ok {
+		return
+	}
+	if last, ok := reporter.UsageTracker().Last(); ok {
+		t.calibration.Observe(estimated, last)
+	}
+}
@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/usage"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// usageCompleter replies with a tool call until the last reply and reports
+// input usage of twice the heuristic estimate of each request.
+type usageCompleter struct {
+	sequenceCompleter
+	usage usage.Tracker
+}
+
+func (u *usageCompleter) Complete(ctx context.Context, c *chat.Chat, tools []toolbox.Tool) (message.Message, error) {
+	var est modeladapter.TokenEstimator
+	u.usage.Add(usage.TokenCount{InputTokens: 2 * est.EstimateTotal(c, tools)})
+	return u.sequenceCompleter.Complete(ctx, c, tools)
+}
+
+func (u *usageCompleter) UsageTracker() *usage.Tracker { return &u.usage }
+func (u *usageCompleter) ModelMaxTokens() int          { return 0 }
+
+// recordEstimates records EstimatedTokens before each completion.
+type recordEstimates struct{ seen []int }
+
+func (r *recordEstimates) Eval(_ context.Context, ic IterationContext) error {
+	if ic.Phase == PhaseBeforeComplete {
+		r.seen = append(r.seen, ic.EstimatedTokens)
+	}
+	return nil
+}
+
+func echoCall(id string) message.Message {
+	return message.New("", role.Assistant, content.ToolCall{ID: id, Name: "echo", Arguments: `{}`})
+}
+
+func TestRun_CalibratesEstimatesFromUsage(t *testing.T) {
+	rec := &recordEstimates{}
+	cal := &modeladapter.Calibration{}
+	a := New("bot", "", strings.Repeat("Follow the instructions carefully. ", 100), &usageCompleter{
+		sequenceCompleter: sequenceCompleter{replies: []message.Message{
+			echoCall("c1"),
+			message.NewText("", role.Assistant, "done"),
+		}},
+	}, Options{Effects: []Effect{rec}, TokenCalibration: cal})
+	a.AddToolBoxes(newEchoToolBox())
+
+	_, err := a.Run(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, rec.seen, 2)
+	assert.Equal(t, 2, cal.Samples())
+	assert.InDelta(t, 2.0, cal.Factor(), 1e-9)
+
+	// The first estimate is raw; the second, for a longer chat, is doubled by
+	// the factor learned from the first call.
+	assert.GreaterOrEqual(t, rec.seen[1], 2*rec.seen[0])
+}
+
+type fixedCounter struct {
+	n   int
+	err error
+}
+
+func (f fixedCounter) CountTokens(context.Context, *chat.Chat, []toolbox.Tool) (int, error) {
+	return f.n, f.err
+}
+
+func TestRun_TokenCounter(t *testing.T) {
+	rec := &recordEstimates{}
+	a := New("bot", "", "", &sequenceCompleter{
+		replies: []message.Message{message.NewText("", role.Assistant, "done")},
+	}, Options{Effects: []Effect{rec}, TokenCounter: fixedCounter{n: 12345}})
+
+	_, err := a.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []int{12345}, rec.seen)
+}
+
+func TestRun_TokenCounterFailureFallsBackToEstimate(t *testing.T) {
+	rec := &recordEstimates{}
+	a := New("bot", "", "", &sequenceCompleter{
+		replies: []message.Message{message.NewText("", role.Assistant, "done")},
+	}, Options{Effects: []Effect{rec}, TokenCounter: fixedCounter{err: errors.New("offline")}})
+
+	_, err := a.Run(context.Background())
+	require.NoError(t, err)
+	require.Len(t, rec.seen, 1)
+	assert.Positive(t, rec.seen[0])
+}
@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/tools/schema"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+)
+
+// ToolArgStats counts the tool calls of a run whose arguments did not match
+// the tool's input schema. Effects read it from IterationContext.ToolArgs to
+// spot a model that keeps sending malformed calls.
+type ToolArgStats struct {
+	Repaired           int `json:"repaired"`            // Calls dispatched after their arguments were repaired.
+	Invalid            int `json:"invalid"`             // Calls rejected because their arguments were invalid.
+	ConsecutiveInvalid int `json:"consecutive_invalid"` // Rejected calls since the last call with usable arguments.
+}
+
+// ToolArgsEventData carries metadata for tool_args_repaired and
+// tool_args_invalid events.
+type ToolArgsEventData struct {
+	ToolName string       `json:"tool_name"`
+	CallID   string       `json:"call_id"`
+	Issues   []string     `json:"issues"` // Repairs applied, or the violations that rejected the call.
+	Stats    ToolArgStats `json:"stats"`  // Run totals including this call.
+}
+
+// toolArgTracker accumulates ToolArgStats across the concurrent tool calls
+// of a run.
+type toolArgTracker struct {
+	mu    sync.Mutex
+	stats ToolArgStats
+}
+
+func (t *toolArgTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats = ToolArgStats{}
+}
+
+func (t *toolArgTracker) snapshot() ToolArgStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
+// record counts one call and returns the updated totals.
+func (t *toolArgTracker) record(repaired, invalid bool) ToolArgStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch {
+	case invalid:
+		t.stats.Invalid++
+		t.stats.ConsecutiveInvalid++
+	case repaired:
+		t.stats.Repaired++
+		t.stats.ConsecutiveInvalid = 0
+	default:
+		t.stats.ConsecutiveInvalid = 0
+	}
+	return t.stats
+}
+
+// checkToolArguments validates the arguments of tc against the input schema
+// of t before dispatch. Unless the tool is strict, common mistakes (trailing
+// commas, unescaped quotes, stringified numbers, ...) are repaired first, and
+// the repaired arguments are returned. Arguments that remain invalid yield an
+// error result naming each offending value by its JSON pointer, so that the
+// model can fix the call instead of retrying it blindly. Repairs and
+// rejections are published as tool_args_repaired and tool_args_invalid events.
+func (a *Agent) checkToolArguments(ctx context.Context, t toolbox.Tool, tc content.ToolCall) (json.RawMessage, *content.ToolResult) {
+	res := schema.CheckArguments(t.InputSchema, json.RawMessage(tc.Arguments), !t.Strict)
+
+	if len(res.Violations) > 0 {
+		issues := make([]string, len(res.Violations))
+		for i, v := range res.Violations {
+			issues[i] = v.String()
+		}
+		stats := a.toolArgs.record(false, true)
+		a.emitEvent(ctx, "tool_args_invalid", ToolArgsEventData{ToolName: tc.Name, CallID: tc.ID, Issues: issues, Stats: stats})
+
+		return nil, &content.ToolResult{
+			ToolCallID: tc.ID,
+			Content: "invalid arguments for " + tc.Name + ":\n- " + strings.Join(issues, "\n- ") +
+				"\nFix these values to match the tool's input schema and call it again.",
+			IsError: true,
+		}
+	}
+
+	stats := a.toolArgs.record(len(res.Repairs) > 0, false)
+	if len(res.Repairs) > 0 {
+		a.emitEvent(ctx, "tool_args_repaired", ToolArgsEventData{ToolName: tc.Name, CallID: tc.ID, Issues: res.Repairs, Stats: stats})
+	}
+	return res.JSON, nil
+}
@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/tools/schema"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type readInput struct {
+	Path  string `json:"path"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+func TestRunValidatesToolArguments(t *testing.T) {
+	var received []string
+	read := func(_ context.Context, input json.RawMessage) (string, error) {
+		var in readInput
+		if err := json.Unmarshal(input, &in); err != nil {
+			return "", err
+		}
+		received = append(received, string(input))
+		return "ok", nil
+	}
+	tb := toolbox.New()
+	tb.Register(
+		toolbox.Tool{Name: "read", InputSchema: schema.Generate[readInput](), Handler: read},
+		toolbox.Tool{Name: "read_strict", InputSchema: schema.Generate[readInput](), Handler: read, Strict: true},
+	)
+
+	p := &sequenceCompleter{
+		replies: []message.Message{
+			message.New("", role.Assistant, content.ToolCall{ID: "c1", Name: "read", Arguments: `{"path":"a.go","limit":"5",}`}),
+			message.New("", role.Assistant, content.ToolCall{ID: "c2", Name: "read", Arguments: `{"limit":"many"}`}),
+			message.New("", role.Assistant, content.ToolCall{ID: "c3", Name: "read_strict", Arguments: `{"path":"a.go","limit":"5"}`}),
+			message.NewText("", role.Assistant, "Done."),
+		},
+	}
+
+	var (
+		mu     sync.Mutex
+		events []string
+		data   []ToolArgsEventData
+		stats  []ToolArgStats
+	)
+	a := New("bot", "", "", p, Options{
+		EventFunc: func(_ context.Context, kind string, d any) {
+			if ev, ok := d.(ToolArgsEventData); ok {
+				mu.Lock()
+				events = append(events, kind)
+				data = append(data, ev)
+				mu.Unlock()
+			}
+		},
+		Effects: []Effect{EffectFunc(func(_ context.Context, ic IterationContext) error {
+			if ic.Phase == PhaseBeforeComplete {
+				stats = append(stats, ic.ToolArgs)
+			}
+			return nil
+		})},
+	})
+	a.AddToolBoxes(tb)
+
+	_, err := a.Run(context.Background())
+	require.NoError(t, err)
+
+	// The repaired call reached the handler with usable arguments.
+	assert.Equal(t, []string{`{"limit":5,"path":"a.go"}`}, received)
+
+	var results []content.ToolResult
+	for _, m := range a.Chat().Messages() {
+		if m.Role == role.Tool {
+			results = append(results, m.Parts[0].(content.ToolResult))
+		}
+	}
+	require.Len(t, results, 3)
+	assert.False(t, results[0].IsError)
+	assert.True(t, results[1].IsError)
+	assert.Equal(t, "invalid arguments for read:\n"+
+		"- /path: required property is missing\n"+
+		"- /limit: expected integer, got string \"many\"\n"+
+		"Fix these values to match the tool's input schema and call it again.", results[1].Content)
+	assert.True(t, results[2].IsError)
+	assert.Contains(t, results[2].Content, `/limit: expected integer, got string "5"`, "strict tools are not repaired")
+
+	assert.Equal(t, []string{"tool_args_repaired", "tool_args_invalid", "tool_args_invalid"}, events)
+	assert.Equal(t, []string{"removed trailing commas", "/limit: converted string to integer"}, data[0].Issues)
+	assert.Equal(t, ToolArgStats{Repaired: 1, Invalid: 2, ConsecutiveInvalid: 2}, data[2].Stats)
+
+	assert.Equal(t, []ToolArgStats{
+		{},
+		{Repaired: 1},
+		{Repaired: 1, Invalid: 1, ConsecutiveInvalid: 1},
+		{Repaired: 1, Invalid: 2, ConsecutiveInvalid: 2},
+	}, stats)
+
+	// A new run starts counting afresh.
+	a.toolArgs.record(false, true)
+	p.replies = append(p.replies, message.NewText("", role.Assistant, "Again."))
+	_, err = a.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, ToolArgStats{}, stats[len(stats)-1])
+}
+
+func TestRunChecksToolArgumentsBeforeMiddleware(t *testing.T) {
+	var received []string
+	read := func(_ context.Context, input json.RawMessage) (string, error) {
+		received = append(received, string(input))
+		return "ok", nil
+	}
+	tb := toolbox.New()
+	tb.Register(toolbox.Tool{Name: "read", InputSchema: schema.Generate[readInput](), Handler: read})
+
+	p := &sequenceCompleter{
+		replies: []message.Message{
+			message.New("", role.Assistant, content.ToolCall{ID: "c1", Name: "read", Arguments: `{"path":"a.go",}`}),
+			message.New("", role.Assistant, content.ToolCall{ID: "c2", Name: "read", Arguments: `{"limit":"many"}`}),
+			message.New("", role.Assistant, content.ToolCall{ID: "c3", Name: "read", Arguments: `{"path":"rewrite"}`}),
+			message.NewText("", role.Assistant, "Done."),
+		},
+	}
+
+	var seen []string
+	rewrite := func(next ToolCaller) ToolCaller {
+		return func(ctx context.Context, tc content.ToolCall) content.ToolResult {
+			seen = append(seen, tc.Arguments)
+			if tc.ID == "c3" {
+				tc.Arguments = `{"path":"b.go","limit":"2"}`
+			}
+			return next(ctx, tc)
+		}
+	}
+
+	a := New("bot", "", "", p, Options{ToolMiddleware: []ToolMiddleware{rewrite}})
+	a.AddToolBoxes(tb)
+
+	_, err := a.Run(context.Background())
+	require.NoError(t, err)
+
+	// The middleware sees repaired arguments and never sees invalid ones;
+	// arguments it rewrites are checked (and repaired) again.
+	assert.Equal(t, []string{`{"path":"a.go"}`, `{"path":"rewrite"}`}, seen)
+	assert.Equal(t, []string{`{"path":"a.go"}`, `{"limit":2,"path":"b.go"}`}, received)
+}
@@ -1,26 +1,68 @@
 package agent
 
-import "github.com/germanamz/shelly/pkg/tools/toolbox"
+import (
+	"context"
+	"path"
+
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+)
 
 // deduplicateTools collects tool declarations from all toolboxes,
 // deduplicating by name so providers that reject duplicate definitions
 // (e.g. Grok) don't fail when parent toolboxes are injected into children.
-// It also returns a handler map for O(1) tool dispatch in callTool.
-// First-toolbox-wins semantics are preserved: the first handler registered
+// It also returns a name → tool map for O(1) tool dispatch in callTool.
+// First-toolbox-wins semantics are preserved: the first tool registered
 // for a given name is the one used.
-func deduplicateTools(toolboxes []*toolbox.ToolBox) ([]toolbox.Tool, map[string]toolbox.Handler) {
-	handlers := make(map[string]toolbox.Handler)
+func deduplicateTools(toolboxes []*toolbox.ToolBox) ([]toolbox.Tool, map[string]toolbox.Tool) {
+	byName := make(map[string]toolbox.Tool)
 	var tools []toolbox.Tool
 
 	for _, tb := range toolboxes {
 		for _, t := range tb.Tools() {
-			if _, dup := handlers[t.Name]; dup {
+			if _, dup := byName[t.Name]; dup {
 				continue
 			}
-			handlers[t.Name] = t.Handler
+			byName[t.Name] = t
 			tools = append(tools, t)
 		}
 	}
 
-	return tools, handlers
+	return tools, byName
+}
+
+type allowedToolsCtxKey struct{}
+
+// WithAllowedTools limits the next Run with ctx to the tools matching
+// patterns (exact names or path.Match globs such as "git_*"). The limit
+// applies to that agent only; agents it delegates to keep their own tools.
+// Empty patterns do not limit.
+func WithAllowedTools(ctx context.Context, patterns []string) context.Context {
+	return context.WithValue(ctx, allowedToolsCtxKey{}, patterns)
+}
+
+// allowedToolsFromContext returns the patterns set by WithAllowedTools.
+func allowedToolsFromContext(ctx context.Context) []string {
+	patterns, _ := ctx.Value(allowedToolsCtxKey{}).([]string)
+	return patterns
+}
+
+// restrictTools keeps only the tools matching patterns, removing the others
+// from the dispatch map too so that calls to them fail as unknown tools.
+func restrictTools(tools []toolbox.Tool, byName map[string]toolbox.Tool, patterns []string) ([]toolbox.Tool, map[string]toolbox.Tool) {
+	if len(patterns) == 0 {
+		return tools, byName
+	}
+
+	kept := make([]toolbox.Tool, 0, len(tools))
+	keptByName := make(map[string]toolbox.Tool, len(patterns))
+	for _, t := range tools {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, t.Name); ok {
+				kept = append(kept, t)
+				keptByName[t.Name] = byName[t.Name]
+				break
+			}
+		}
+	}
+	return kept, keptByName
 }
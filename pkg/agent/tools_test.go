@@ -70,8 +70,8 @@ func TestDeduplicateToolsRemovesDuplicates(t *testing.T) {
 	// First version wins.
 	assert.Equal(t, "First version", tools[0].Description)
 
-	// Handler map also uses first-wins semantics.
-	result, err := handlers["shared"](context.Background(), nil)
+	// Dispatch map also uses first-wins semantics.
+	result, err := handlers["shared"].Handler(context.Background(), nil)
 	require.NoError(t, err)
 	assert.Equal(t, "first", result)
 }
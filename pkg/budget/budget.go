@@ -0,0 +1,272 @@
+// Package budget enforces dollar-denominated spend limits on LLM calls.
+//
+// A Tracker checks a Charge (the session, agent config and provider about to
+// make a call) against per-session, per-agent, per-provider and rolling daily
+// limits, and records the cost of each call once it is known. Rolling totals
+// come from a Ledger shared by every process using the same directory, so
+// concurrent shelly processes draw from one daily budget.
+package budget
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Window is the rolling period for daily and per-provider limits.
+const Window = 24 * time.Hour
+
+// DefaultWarnThreshold is the fraction of a limit at which a warning is raised
+// when Limits.WarnThreshold is unset.
+const DefaultWarnThreshold = 0.8
+
+// ErrExceeded matches every *ExceededError via errors.Is.
+var ErrExceeded = errors.New("budget: limit exceeded")
+
+// Limits are spend limits in USD. Zero disables a limit.
+type Limits struct {
+	Session       float64            // Per session, including all sub-agents.
+	Daily         float64            // Across all sessions and processes in the last Window.
+	Agents        map[string]float64 // Per agent config name, per session.
+	Providers     map[string]float64 // Per provider name in the last Window.
+	WarnThreshold float64            // Fraction in (0, 1) at which to warn (default DefaultWarnThreshold).
+}
+
+// Enabled reports whether any limit is set.
+func (l Limits) Enabled() bool {
+	if l.Session > 0 || l.Daily > 0 {
+		return true
+	}
+	for _, v := range l.Agents {
+		if v > 0 {
+			return true
+		}
+	}
+	for _, v := range l.Providers {
+		if v > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeKind identifies which limit a Scope describes.
+type ScopeKind string
+
+// Scope kinds.
+const (
+	ScopeSession  ScopeKind = "session"
+	ScopeAgent    ScopeKind = "agent"
+	ScopeProvider ScopeKind = "provider"
+	ScopeDaily    ScopeKind = "daily"
+)
+
+// Scope is the spend against one limit.
+type Scope struct {
+	Kind  ScopeKind `json:"kind"`
+	Name  string    `json:"name,omitempty"` // Agent or provider name; empty for session and daily.
+	Spent float64   `json:"spent"`
+	Limit float64   `json:"limit"`
+}
+
+// Ratio returns Spent as a fraction of Limit.
+func (s Scope) Ratio() float64 { return s.Spent / s.Limit }
+
+// String returns a description such as `agent "coder" budget: $1.62 of $2.00`.
+func (s Scope) String() string {
+	label := string(s.Kind)
+	if s.Name != "" {
+		label = fmt.Sprintf("%s %q", s.Kind, s.Name)
+	}
+	return fmt.Sprintf("%s budget: $%.2f of $%.2f", label, s.Spent, s.Limit)
+}
+
+// ExceededError reports the limit that stopped a call.
+type ExceededError struct {
+	Scope Scope
+}
+
+func (e *ExceededError) Error() string {
+	return "budget: " + e.Scope.String() + " exhausted"
+}
+
+// Is makes errors.Is(err, ErrExceeded) match.
+func (e *ExceededError) Is(target error) bool { return target == ErrExceeded }
+
+// Charge identifies who is spending: the session, the agent config and the
+// provider handling the call.
+type Charge struct {
+	Session  string
+	Agent    string
+	Provider string
+}
+
+type agentKey struct{ session, agent string }
+
+// Tracker enforces Limits. It is safe for concurrent use.
+type Tracker struct {
+	limits Limits
+	ledger *Ledger // nil: rolling totals are kept in memory only
+
+	mu       sync.Mutex
+	sessions map[string]float64
+	agents   map[agentKey]float64
+	local    []Entry             // rolling entries when ledger is nil
+	warned   map[string]struct{} // scopes already warned about
+}
+
+// NewTracker creates a Tracker. When ledger is nil, daily and provider totals
+// only cover this process.
+func NewTracker(limits Limits, ledger *Ledger) *Tracker {
+	if limits.WarnThreshold <= 0 || limits.WarnThreshold >= 1 {
+		limits.WarnThreshold = DefaultWarnThreshold
+	}
+	return &Tracker{
+		limits:   limits,
+		ledger:   ledger,
+		sessions: make(map[string]float64),
+		agents:   make(map[agentKey]float64),
+		warned:   make(map[string]struct{}),
+	}
+}
+
+// Limits returns the tracker's limits.
+func (t *Tracker) Limits() Limits { return t.limits }
+
+// Scopes returns the current spend against every limit that applies to c.
+func (t *Tracker) Scopes(c Charge) []Scope {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.scopesLocked(c)
+}
+
+func (t *Tracker) scopesLocked(c Charge) []Scope {
+	var scopes []Scope
+
+	if t.limits.Session > 0 {
+		scopes = append(scopes, Scope{Kind: ScopeSession, Spent: t.sessions[c.Session], Limit: t.limits.Session})
+	}
+	if limit := t.limits.Agents[c.Agent]; limit > 0 {
+		scopes = append(scopes, Scope{Kind: ScopeAgent, Name: c.Agent, Spent: t.agents[agentKey{c.Session, c.Agent}], Limit: limit})
+	}
+
+	providerLimit := t.limits.Providers[c.Provider]
+	if providerLimit > 0 || t.limits.Daily > 0 {
+		var daily, provider float64
+		for _, e := range t.recentLocked() {
+			daily += e.Cost
+			if e.Provider == c.Provider {
+				provider += e.Cost
+			}
+		}
+		if providerLimit > 0 {
+			scopes = append(scopes, Scope{Kind: ScopeProvider, Name: c.Provider, Spent: provider, Limit: providerLimit})
+		}
+		if t.limits.Daily > 0 {
+			scopes = append(scopes, Scope{Kind: ScopeDaily, Spent: daily, Limit: t.limits.Daily})
+		}
+	}
+
+	return scopes
+}
+
+// recentLocked returns the entries within Window.
+func (t *Tracker) recentLocked() []Entry {
+	since := time.Now().Add(-Window)
+	if t.ledger != nil {
+		return t.ledger.Since(since)
+	}
+
+	i := 0
+	for i < len(t.local) && t.local[i].Time.Before(since) {
+		i++
+	}
+	t.local = t.local[i:]
+	return t.local
+}
+
+// Check is called before a call is made. It returns an *ExceededError when
+// any applicable limit is exhausted. Otherwise it returns the scopes that
+// crossed the warning threshold since they were last reported; each crossing
+// is reported once until spend falls back below the threshold.
+func (t *Tracker) Check(c Charge) ([]Scope, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	scopes := t.scopesLocked(c)
+
+	for _, s := range scopes {
+		if s.Spent >= s.Limit {
+			return nil, &ExceededError{Scope: s}
+		}
+	}
+
+	var warnings []Scope
+	for _, s := range scopes {
+		key := t.warnKey(c, s)
+		if s.Ratio() < t.limits.WarnThreshold {
+			delete(t.warned, key)
+			continue
+		}
+		if _, done := t.warned[key]; done {
+			continue
+		}
+		t.warned[key] = struct{}{}
+		warnings = append(warnings, s)
+	}
+
+	return warnings, nil
+}
+
+// warnKey identifies a scope for warning de-duplication.
+func (t *Tracker) warnKey(c Charge, s Scope) string {
+	switch s.Kind {
+	case ScopeSession:
+		return "session/" + c.Session
+	case ScopeAgent:
+		return "agent/" + c.Session + "/" + c.Agent
+	case ScopeProvider:
+		return "provider/" + c.Provider
+	default:
+		return string(s.Kind)
+	}
+}
+
+// Record adds the cost of a completed call. The ledger write error, if any,
+// is returned after the in-memory totals are updated.
+func (t *Tracker) Record(c Charge, cost float64) error {
+	if cost <= 0 {
+		return nil
+	}
+
+	e := Entry{Time: time.Now(), Session: c.Session, Agent: c.Agent, Provider: c.Provider, Cost: cost}
+
+	t.mu.Lock()
+	t.sessions[c.Session] += cost
+	t.agents[agentKey{c.Session, c.Agent}] += cost
+	if t.ledger == nil {
+		t.local = append(t.local, e)
+	}
+	t.mu.Unlock()
+
+	if t.ledger != nil {
+		return t.ledger.Append(e)
+	}
+	return nil
+}
+
+// Forget drops the per-session totals of a finished session.
+func (t *Tracker) Forget(session string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.sessions, session)
+	delete(t.warned, "session/"+session)
+	for k := range t.agents {
+		if k.session == session {
+			delete(t.agents, k)
+			delete(t.warned, "agent/"+session+"/"+k.agent)
+		}
+	}
+}
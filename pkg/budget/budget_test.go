@@ -0,0 +1,114 @@
+package budget
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimits_Enabled(t *testing.T) {
+	assert.False(t, Limits{}.Enabled())
+	assert.False(t, Limits{Agents: map[string]float64{"a": 0}}.Enabled())
+	assert.True(t, Limits{Session: 1}.Enabled())
+	assert.True(t, Limits{Providers: map[string]float64{"p": 2}}.Enabled())
+}
+
+func TestTracker_SessionLimit(t *testing.T) {
+	tr := NewTracker(Limits{Session: 1}, nil)
+	c := Charge{Session: "s1", Agent: "coder", Provider: "p"}
+
+	warnings, err := tr.Check(c)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	require.NoError(t, tr.Record(c, 0.85))
+
+	warnings, err = tr.Check(c)
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, ScopeSession, warnings[0].Kind)
+	assert.InDelta(t, 0.85, warnings[0].Spent, 1e-9)
+
+	// A crossing is reported once.
+	warnings, err = tr.Check(c)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	require.NoError(t, tr.Record(c, 0.2))
+	_, err = tr.Check(c)
+	require.ErrorIs(t, err, ErrExceeded)
+
+	var exceeded *ExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, ScopeSession, exceeded.Scope.Kind)
+	assert.Contains(t, err.Error(), "session budget: $1.05 of $1.00")
+
+	// Other sessions have their own budget.
+	_, err = tr.Check(Charge{Session: "s2", Agent: "coder", Provider: "p"})
+	require.NoError(t, err)
+
+	tr.Forget("s1")
+	_, err = tr.Check(c)
+	require.NoError(t, err)
+}
+
+func TestTracker_AgentLimit(t *testing.T) {
+	tr := NewTracker(Limits{Agents: map[string]float64{"coder": 0.5}}, nil)
+
+	coder := Charge{Session: "s1", Agent: "coder", Provider: "p"}
+	planner := Charge{Session: "s1", Agent: "planner", Provider: "p"}
+
+	require.NoError(t, tr.Record(coder, 0.5))
+	require.NoError(t, tr.Record(planner, 5))
+
+	_, err := tr.Check(coder)
+	var exceeded *ExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, Scope{Kind: ScopeAgent, Name: "coder", Spent: 0.5, Limit: 0.5}, exceeded.Scope)
+
+	_, err = tr.Check(planner)
+	require.NoError(t, err)
+}
+
+func TestTracker_DailyAndProvider(t *testing.T) {
+	tr := NewTracker(Limits{Daily: 3, Providers: map[string]float64{"cheap": 1}, WarnThreshold: 0.5}, nil)
+
+	require.NoError(t, tr.Record(Charge{Session: "s1", Provider: "cheap"}, 0.6))
+
+	warnings, err := tr.Check(Charge{Session: "s2", Provider: "cheap"})
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, ScopeProvider, warnings[0].Kind)
+
+	require.NoError(t, tr.Record(Charge{Session: "s2", Provider: "big"}, 2.5))
+
+	_, err = tr.Check(Charge{Session: "s3", Provider: "big"})
+	var exceeded *ExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, ScopeDaily, exceeded.Scope.Kind)
+	assert.InDelta(t, 3.1, exceeded.Scope.Spent, 1e-9)
+}
+
+func TestTracker_SharedLedger(t *testing.T) {
+	dir := t.TempDir()
+
+	l1, err := OpenLedger(dir)
+	require.NoError(t, err)
+	l2, err := OpenLedger(dir)
+	require.NoError(t, err)
+
+	// Two trackers stand in for two processes sharing one directory.
+	a := NewTracker(Limits{Daily: 1}, l1)
+	b := NewTracker(Limits{Daily: 1}, l2)
+
+	require.NoError(t, a.Record(Charge{Session: "a", Provider: "p"}, 0.7))
+	require.NoError(t, b.Record(Charge{Session: "b", Provider: "p"}, 0.4))
+
+	_, err = a.Check(Charge{Session: "a", Provider: "p"})
+	require.ErrorIs(t, err, ErrExceeded)
+
+	scopes := b.Scopes(Charge{Session: "b", Provider: "p"})
+	require.Len(t, scopes, 1)
+	assert.InDelta(t, 1.1, scopes[0].Spent, 1e-9)
+}
@@ -0,0 +1,177 @@
+package budget
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ledgerRetention is how long daily ledger files are kept before being
+// deleted. Only the last Window of entries is ever read.
+const ledgerRetention = 7 * 24 * time.Hour
+
+// ledgerDayFormat names one ledger file per UTC day.
+const ledgerDayFormat = "2006-01-02"
+
+// Entry is one recorded charge.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Session  string    `json:"session,omitempty"`
+	Agent    string    `json:"agent,omitempty"`
+	Provider string    `json:"provider,omitempty"`
+	Cost     float64   `json:"cost"`
+}
+
+// Ledger is an append-only spend log shared by every process that opens the
+// same directory. Entries are written as JSON lines to one file per UTC day
+// with O_APPEND, so concurrent writers never interleave partial lines, and
+// each process reads only the bytes appended since its previous refresh.
+type Ledger struct {
+	dir string
+
+	mu      sync.Mutex
+	offsets map[string]int64 // file name → bytes consumed
+	entries []Entry          // entries within the retention window, oldest first
+}
+
+// OpenLedger opens (creating if needed) the ledger in dir and deletes daily
+// files older than the retention period.
+func OpenLedger(dir string) (*Ledger, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("budget: ledger: %w", err)
+	}
+
+	l := &Ledger{dir: dir, offsets: make(map[string]int64)}
+	l.prune(time.Now())
+
+	return l, nil
+}
+
+// Append records e, stamping the current time when e.Time is zero.
+func (l *Ledger) Append(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("budget: ledger: %w", err)
+	}
+	line = append(line, '\n')
+
+	path := filepath.Join(l.dir, dayFile(e.Time))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600) //nolint:gosec // path built from ledger dir
+	if err != nil {
+		return fmt.Errorf("budget: ledger: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("budget: ledger: %w", err)
+	}
+	return f.Close()
+}
+
+// Since returns all entries recorded at or after t, including those appended
+// by other processes. t must be within the retention period.
+func (l *Ledger) Since(t time.Time) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for day := t.UTC().Truncate(24 * time.Hour); !day.After(now); day = day.Add(24 * time.Hour) {
+		l.readNew(dayFile(day))
+	}
+
+	// Drop entries older than the retention period from memory.
+	cutoff := now.Add(-ledgerRetention)
+	i := 0
+	for i < len(l.entries) && l.entries[i].Time.Before(cutoff) {
+		i++
+	}
+	l.entries = l.entries[i:]
+
+	var out []Entry
+	for _, e := range l.entries {
+		if !e.Time.Before(t) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// readNew consumes complete lines appended to name since the last read.
+// A trailing partial line (a write in progress) is left for the next read.
+func (l *Ledger) readNew(name string) {
+	f, err := os.Open(filepath.Join(l.dir, name)) //nolint:gosec // path built from ledger dir
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	off := l.offsets[name]
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		return
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return
+	}
+
+	end := bytes.LastIndexByte(data, '\n')
+	if end < 0 {
+		return
+	}
+	l.offsets[name] = off + int64(end) + 1
+
+	for line := range bytes.SplitSeq(data[:end], []byte{'\n'}) {
+		var e Entry
+		if json.Unmarshal(line, &e) != nil {
+			continue
+		}
+		l.insert(e)
+	}
+}
+
+// insert adds e keeping entries ordered by time. Entries almost always arrive
+// in order, so the scan from the end is short.
+func (l *Ledger) insert(e Entry) {
+	i := len(l.entries)
+	for i > 0 && l.entries[i-1].Time.After(e.Time) {
+		i--
+	}
+	l.entries = append(l.entries, Entry{})
+	copy(l.entries[i+1:], l.entries[i:])
+	l.entries[i] = e
+}
+
+// prune deletes daily files older than the retention period.
+func (l *Ledger) prune(now time.Time) {
+	dirEntries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return
+	}
+
+	cutoff := now.Add(-ledgerRetention).UTC().Truncate(24 * time.Hour)
+	for _, de := range dirEntries {
+		day, ok := strings.CutSuffix(de.Name(), ".jsonl")
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(ledgerDayFormat, day)
+		if err != nil || !t.Before(cutoff) {
+			continue
+		}
+		_ = os.Remove(filepath.Join(l.dir, de.Name()))
+	}
+}
+
+// dayFile returns the ledger file name for the UTC day containing t.
+func dayFile(t time.Time) string {
+	return t.UTC().Format(ledgerDayFormat) + ".jsonl"
+}
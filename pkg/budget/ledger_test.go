@@ -0,0 +1,69 @@
+package budget
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLedger_AppendSince(t *testing.T) {
+	dir := t.TempDir()
+	l, err := OpenLedger(dir)
+	require.NoError(t, err)
+
+	now := time.Now()
+	require.NoError(t, l.Append(Entry{Time: now.Add(-30 * time.Hour), Cost: 1}))
+	require.NoError(t, l.Append(Entry{Time: now.Add(-time.Hour), Session: "s", Agent: "a", Provider: "p", Cost: 2}))
+	require.NoError(t, l.Append(Entry{Cost: 3}))
+
+	got := l.Since(now.Add(-Window))
+	require.Len(t, got, 2)
+	assert.InDelta(t, 2.0, got[0].Cost, 1e-9)
+	assert.Equal(t, "p", got[0].Provider)
+	assert.InDelta(t, 3.0, got[1].Cost, 1e-9)
+
+	// Reads are incremental: a second call sees only the same entries.
+	assert.Len(t, l.Since(now.Add(-Window)), 2)
+	assert.Len(t, l.Since(now.Add(-48*time.Hour)), 3)
+}
+
+func TestLedger_PartialLine(t *testing.T) {
+	dir := t.TempDir()
+	l, err := OpenLedger(dir)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, dayFile(time.Now()))
+	require.NoError(t, os.WriteFile(path, []byte(`{"time":"`+time.Now().UTC().Format(time.RFC3339Nano)+`","cost":1}`+"\n"+`{"time":`), 0o600))
+
+	assert.Len(t, l.Since(time.Now().Add(-time.Hour)), 1)
+
+	// Completing the line (as a concurrent writer would) makes it visible.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`"` + time.Now().UTC().Format(time.RFC3339Nano) + `","cost":2}` + "\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assert.Len(t, l.Since(time.Now().Add(-time.Hour)), 2)
+}
+
+func TestOpenLedger_Prunes(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, dayFile(time.Now().Add(-10*24*time.Hour)))
+	recent := filepath.Join(dir, dayFile(time.Now().Add(-24*time.Hour)))
+	other := filepath.Join(dir, "notes.txt")
+	for _, p := range []string{old, recent, other} {
+		require.NoError(t, os.WriteFile(p, nil, 0o600))
+	}
+
+	_, err := OpenLedger(dir)
+	require.NoError(t, err)
+
+	assert.NoFileExists(t, old)
+	assert.FileExists(t, recent)
+	assert.FileExists(t, other)
+}
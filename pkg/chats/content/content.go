@@ -32,6 +32,15 @@ type Document struct {
 
 func (d Document) PartKind() string { return "document" }
 
+// Audio is an audio content part (WAV, MP3, etc.) embedded as raw bytes.
+type Audio struct {
+	Path      string // Original file path (for display)
+	Data      []byte // Raw audio bytes
+	MediaType string // MIME type (audio/wav, audio/mpeg, etc.)
+}
+
+func (a Audio) PartKind() string { return "audio" }
+
 // ToolCall represents an assistant's request to invoke a tool.
 // Arguments holds the raw JSON string to avoid unnecessary deserialization.
 // Metadata carries provider-specific opaque data (e.g. Gemini thought signatures)
@@ -53,3 +62,14 @@ type ToolResult struct {
 }
 
 func (tr ToolResult) PartKind() string { return "tool_result" }
+
+// Reasoning is the model's reasoning output, kept so that providers which
+// support it can carry reasoning over to later turns. Text holds the readable
+// summary, if any. Metadata carries provider-specific opaque data (e.g. an
+// OpenAI reasoning item ID or encrypted content) and is not shown to users.
+type Reasoning struct {
+	Text     string
+	Metadata map[string]string
+}
+
+func (r Reasoning) PartKind() string { return "reasoning" }
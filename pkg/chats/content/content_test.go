@@ -16,6 +16,11 @@ func TestImage_PartKind(t *testing.T) {
 	assert.Equal(t, "image", p.PartKind())
 }
 
+func TestAudio_PartKind(t *testing.T) {
+	p := Audio{Data: []byte("RIFF"), MediaType: "audio/wav"}
+	assert.Equal(t, "audio", p.PartKind())
+}
+
 func TestToolCall_PartKind(t *testing.T) {
 	p := ToolCall{ID: "1", Name: "search", Arguments: `{"q":"go"}`}
 	assert.Equal(t, "tool_call", p.PartKind())
@@ -32,9 +37,10 @@ func TestPart_Interface(t *testing.T) {
 		Image{URL: "u"},
 		ToolCall{ID: "1"},
 		ToolResult{ToolCallID: "1"},
+		Reasoning{Text: "r"},
 	}
 
-	expected := []string{"text", "image", "tool_call", "tool_result"}
+	expected := []string{"text", "image", "tool_call", "tool_result", "reasoning"}
 	for i, p := range parts {
 		assert.Equal(t, expected[i], p.PartKind())
 	}
@@ -2,7 +2,7 @@
 //
 // It is organized into sub-packages:
 //   - [github.com/germanamz/shelly/pkg/chats/role] — conversation roles (system, user, assistant, tool)
-//   - [github.com/germanamz/shelly/pkg/chats/content] — multi-modal content parts (text, image, tool call/result)
+//   - [github.com/germanamz/shelly/pkg/chats/content] — multi-modal content parts (text, image, document, audio, tool call/result)
 //   - [github.com/germanamz/shelly/pkg/chats/message] — messages composed of a role, sender, and content parts
 //   - [github.com/germanamz/shelly/pkg/chats/chat] — mutable conversation container
 //
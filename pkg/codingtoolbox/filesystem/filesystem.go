@@ -42,6 +42,9 @@ type FS struct {
 	notify   NotifyFunc
 	locker   *FileLocker
 	approver *codingtoolbox.Approver
+
+	reviewMode string
+	review     ReviewFunc
 }
 
 // New creates an FS backed by the given shared permissions store.
@@ -215,7 +218,7 @@ func (f *FS) handleRead(ctx context.Context, input json.RawMessage) (string, err
 		return "", fmt.Errorf("fs_read: %w", err)
 	}
 
-	file, err := os.Open(abs) //nolint:gosec // path is approved by user
+	file, err := f.openFile(ctx, abs)
 	if err != nil {
 		return "", fmt.Errorf("fs_read: %w", err)
 	}
@@ -261,7 +264,7 @@ func (f *FS) handleReadLines(ctx context.Context, input json.RawMessage) (string
 		return "", fmt.Errorf("fs_read_lines: %w", err)
 	}
 
-	file, err := os.Open(abs) //nolint:gosec // path is approved by user
+	file, err := f.openFile(ctx, abs)
 	if err != nil {
 		return "", fmt.Errorf("fs_read_lines: %w", err)
 	}
@@ -360,26 +363,16 @@ func (f *FS) handleWrite(ctx context.Context, input json.RawMessage) (string, er
 
 	// Read existing content for diff (empty if file doesn't exist yet).
 	oldContent := ""
-	if data, readErr := os.ReadFile(abs); readErr == nil { //nolint:gosec // path is approved by user
+	if data, readErr := f.readFile(ctx, abs); readErr == nil {
 		oldContent = string(data)
 	}
 
-	diff := computeDiff(abs, oldContent, in.Content)
-	if diff != "" {
-		if err := f.confirmChange(ctx, abs, diff); err != nil {
-			return "", fmt.Errorf("fs_write: %w", err)
-		}
-	}
-
-	if err := os.MkdirAll(filepath.Dir(abs), 0o750); err != nil {
-		return "", fmt.Errorf("fs_write: create dirs: %w", err)
-	}
-
-	if err := os.WriteFile(abs, []byte(in.Content), fileMode(abs)); err != nil {
+	result, err := f.writeEdit(ctx, "fs_write", abs, oldContent, in.Content)
+	if err != nil {
 		return "", fmt.Errorf("fs_write: %w", err)
 	}
 
-	return "ok", nil
+	return result, nil
 }
 
 func (f *FS) editTool() toolbox.Tool {
@@ -417,7 +410,7 @@ func (f *FS) handleEdit(ctx context.Context, input json.RawMessage) (string, err
 	f.locker.Lock(abs)
 	defer f.locker.Unlock(abs)
 
-	data, err := os.ReadFile(abs) //nolint:gosec // path is approved by user
+	data, err := f.readFile(ctx, abs)
 	if err != nil {
 		return "", fmt.Errorf("fs_edit: %w", err)
 	}
@@ -435,18 +428,12 @@ func (f *FS) handleEdit(ctx context.Context, input json.RawMessage) (string, err
 
 	newContent := strings.Replace(content, in.OldText, in.NewText, 1)
 
-	diff := computeDiff(abs, content, newContent)
-	if diff != "" {
-		if err := f.confirmChange(ctx, abs, diff); err != nil {
-			return "", fmt.Errorf("fs_edit: %w", err)
-		}
-	}
-
-	if err := os.WriteFile(abs, []byte(newContent), fileMode(abs)); err != nil {
+	result, err := f.writeEdit(ctx, "fs_edit", abs, content, newContent)
+	if err != nil {
 		return "", fmt.Errorf("fs_edit: %w", err)
 	}
 
-	return "ok", nil
+	return result, nil
 }
 
 // listEntry is returned by fs_list for each directory entry.
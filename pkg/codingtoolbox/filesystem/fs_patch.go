@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
 
@@ -57,7 +56,7 @@ func (f *FS) handlePatch(ctx context.Context, input json.RawMessage) (string, er
 	f.locker.Lock(abs)
 	defer f.locker.Unlock(abs)
 
-	data, err := os.ReadFile(abs) //nolint:gosec // path is approved by user
+	data, err := f.readFile(ctx, abs)
 	if err != nil {
 		return "", fmt.Errorf("fs_patch: %w", err)
 	}
@@ -82,16 +81,10 @@ func (f *FS) handlePatch(ctx context.Context, input json.RawMessage) (string, er
 		content = strings.Replace(content, h.OldText, h.NewText, 1)
 	}
 
-	diff := computeDiff(abs, original, content)
-	if diff != "" {
-		if err := f.confirmChange(ctx, abs, diff); err != nil {
-			return "", fmt.Errorf("fs_patch: %w", err)
-		}
-	}
-
-	if err := os.WriteFile(abs, []byte(content), fileMode(abs)); err != nil {
+	result, err := f.writeEdit(ctx, "fs_patch", abs, original, content)
+	if err != nil {
 		return "", fmt.Errorf("fs_patch: %w", err)
 	}
 
-	return "ok", nil
+	return result, nil
 }
@@ -0,0 +1,338 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Review modes for fs_write, fs_edit and fs_patch (see FS.SetReview).
+const (
+	ReviewOff       = ""            // Edits are confirmed with a yes/no prompt.
+	ReviewEach      = "each"        // Each edit is reviewed hunk by hunk before it is written.
+	ReviewEndOfTurn = "end_of_turn" // Edits are staged in memory and reviewed when the turn ends.
+)
+
+// Hunk verdicts.
+const (
+	HunkAccept = "accept"
+	HunkReject = "reject"
+	HunkEdit   = "edit"
+)
+
+// reviewContext is the number of context lines around each hunk.
+const reviewContext = 3
+
+// DiffLine is one line of a hunk.
+type DiffLine struct {
+	Op   string `json:"op"`   // " " (context), "-" (removed) or "+" (added).
+	Text string `json:"text"` // Line content without the line terminator.
+}
+
+// Hunk is one group of changed lines with its surrounding context. Line
+// numbers follow the unified diff convention: 1-based, and for an empty range
+// the line before it.
+type Hunk struct {
+	OldStart int        `json:"old_start"`
+	OldLines int        `json:"old_lines"`
+	NewStart int        `json:"new_start"`
+	NewLines int        `json:"new_lines"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+// Header returns the hunk's unified diff header, e.g. "@@ -3,7 +3,8 @@".
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+}
+
+// NewText returns the hunk's new side: its context and added lines.
+func (h Hunk) NewText() string {
+	var sb strings.Builder
+	for _, l := range h.Lines {
+		if l.Op != "-" {
+			sb.WriteString(l.Text)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// oldBounds returns the hunk's old side as a half-open range of line indices.
+func (h Hunk) oldBounds() (int, int) { return rangeBounds(h.OldStart, h.OldLines) }
+
+// newBounds returns the hunk's new side as a half-open range of line indices.
+func (h Hunk) newBounds() (int, int) { return rangeBounds(h.NewStart, h.NewLines) }
+
+func rangeBounds(start, n int) (int, int) {
+	if n == 0 {
+		return start, start
+	}
+	return start - 1, start - 1 + n
+}
+
+func hunkRange(start, n int) string {
+	if n == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, n)
+}
+
+// Change is a proposed edit of one file awaiting review.
+type Change struct {
+	Tool  string `json:"tool"` // Tool that made the edit; several tools are comma-separated.
+	Path  string `json:"path"` // Absolute path of the file.
+	Old   string `json:"old"`  // Current content ("" for a new file).
+	New   string `json:"new"`  // Proposed content.
+	Hunks []Hunk `json:"hunks"`
+}
+
+// NewChange creates a Change from path's old and new content, split into
+// hunks with three lines of context.
+func NewChange(tool, path, oldContent, newContent string) Change {
+	a, b := splitLines(oldContent), splitLines(newContent)
+	c := Change{Tool: tool, Path: path, Old: oldContent, New: newContent}
+
+	for _, group := range difflib.NewMatcher(a, b).GetGroupedOpCodes(reviewContext) {
+		first, last := group[0], group[len(group)-1]
+		h := Hunk{
+			OldStart: first.I1 + 1, OldLines: last.I2 - first.I1,
+			NewStart: first.J1 + 1, NewLines: last.J2 - first.J1,
+		}
+		if h.OldLines == 0 {
+			h.OldStart--
+		}
+		if h.NewLines == 0 {
+			h.NewStart--
+		}
+		for _, op := range group {
+			if op.Tag == 'e' {
+				h.Lines = appendDiffLines(h.Lines, " ", a[op.I1:op.I2])
+				continue
+			}
+			h.Lines = appendDiffLines(h.Lines, "-", a[op.I1:op.I2])
+			h.Lines = appendDiffLines(h.Lines, "+", b[op.J1:op.J2])
+		}
+		c.Hunks = append(c.Hunks, h)
+	}
+
+	return c
+}
+
+// Diff returns the change as a unified diff.
+func (c Change) Diff() string { return computeDiff(c.Path, c.Old, c.New) }
+
+// Apply returns the old content with the decision applied: accepted hunks take
+// their new side, rejected hunks keep their old side and edited hunks are
+// replaced by the reviewer's text.
+func (c Change) Apply(d Decision) string {
+	a, b := splitLines(c.Old), splitLines(c.New)
+
+	var sb strings.Builder
+	last := 0
+	for i, h := range c.Hunks {
+		from, to := h.oldBounds()
+		sb.WriteString(strings.Join(a[last:from], ""))
+		switch hd := d.hunk(i); hd.Verdict {
+		case HunkReject:
+			sb.WriteString(strings.Join(a[from:to], ""))
+		case HunkEdit:
+			sb.WriteString(hd.Text)
+		default:
+			nf, nt := h.newBounds()
+			sb.WriteString(strings.Join(b[nf:nt], ""))
+		}
+		last = to
+	}
+	sb.WriteString(strings.Join(a[last:], ""))
+
+	return sb.String()
+}
+
+// HunkDecision is the reviewer's verdict on one hunk.
+type HunkDecision struct {
+	Verdict string `json:"verdict"`        // HunkAccept, HunkReject or HunkEdit; "" accepts.
+	Text    string `json:"text,omitempty"` // Replacement for the hunk's new side (HunkEdit), with line terminators.
+}
+
+// Decision is the reviewer's answer for a Change.
+type Decision struct {
+	Hunks   []HunkDecision `json:"hunks"`             // Verdicts by hunk index; missing entries accept.
+	Comment string         `json:"comment,omitempty"` // Passed on to the model.
+}
+
+// AcceptAll returns a decision accepting every hunk of c.
+func AcceptAll(c Change) Decision { return allHunks(c, HunkAccept, "") }
+
+// RejectAll returns a decision rejecting every hunk of c with comment.
+func RejectAll(c Change, comment string) Decision { return allHunks(c, HunkReject, comment) }
+
+func allHunks(c Change, verdict, comment string) Decision {
+	d := Decision{Hunks: make([]HunkDecision, len(c.Hunks)), Comment: comment}
+	for i := range d.Hunks {
+		d.Hunks[i].Verdict = verdict
+	}
+	return d
+}
+
+func (d Decision) hunk(i int) HunkDecision {
+	if i < len(d.Hunks) {
+		return d.Hunks[i]
+	}
+	return HunkDecision{Verdict: HunkAccept}
+}
+
+// ReviewFunc presents a change to the user and blocks until they decide.
+type ReviewFunc func(ctx context.Context, c Change) (Decision, error)
+
+// ReviewRejection is the tool error returned when the reviewer rejects hunks
+// of an edit. Its message carries the details as JSON so the model can act on
+// them.
+type ReviewRejection struct {
+	Path     string   `json:"path"`
+	Rejected []string `json:"rejected_hunks"`         // Headers of the rejected hunks.
+	Edited   []string `json:"edited_hunks,omitempty"` // Headers of the hunks the reviewer rewrote.
+	Applied  bool     `json:"applied"`                // Whether the accepted and edited hunks were written.
+	Comment  string   `json:"comment,omitempty"`
+}
+
+func (r *ReviewRejection) Error() string {
+	data, _ := json.Marshal(r)
+	return "change rejected by reviewer: " + string(data)
+}
+
+// SetReview enables review of fs_write, fs_edit and fs_patch edits. In
+// ReviewEach mode every edit is passed to fn before it is written; in
+// ReviewEndOfTurn mode edits made under a context carrying a Staging are held
+// there until ReviewStaged. Review replaces the yes/no confirmation for these
+// tools, also in trusted sessions; other write tools are confirmed as before.
+func (f *FS) SetReview(mode string, fn ReviewFunc) {
+	f.reviewMode = mode
+	f.review = fn
+}
+
+// writeEdit confirms, reviews or stages an edit of path made by tool and
+// writes the result. It returns the tool result.
+func (f *FS) writeEdit(ctx context.Context, tool, path, oldContent, newContent string) (string, error) {
+	reviewing := f.reviewMode != ReviewOff && f.review != nil
+
+	if st := stagingFromContext(ctx); reviewing && f.reviewMode == ReviewEndOfTurn && st != nil {
+		st.stage(tool, path, oldContent, newContent)
+		return "ok (staged for review at the end of the turn)", nil
+	}
+
+	diff := computeDiff(path, oldContent, newContent)
+	switch {
+	case diff == "":
+	case reviewing:
+		return f.reviewEdit(ctx, NewChange(tool, path, oldContent, newContent))
+	default:
+		if err := f.confirmChange(ctx, path, diff); err != nil {
+			return "", err
+		}
+	}
+
+	if err := writeFile(path, newContent); err != nil {
+		return "", err
+	}
+	return "ok", nil
+}
+
+// reviewEdit passes c to the reviewer and writes the reviewed content.
+func (f *FS) reviewEdit(ctx context.Context, c Change) (string, error) {
+	d, err := f.review(ctx, c)
+	if err != nil {
+		return "", fmt.Errorf("review change: %w", err)
+	}
+
+	content := c.Apply(d)
+	if content != c.Old {
+		if err := writeFile(c.Path, content); err != nil {
+			return "", err
+		}
+	}
+
+	return reviewResult(c, d, content != c.Old)
+}
+
+// reviewResult describes the outcome of a review for the model: a
+// *ReviewRejection when hunks were rejected, otherwise "ok" with any edits
+// and comment from the reviewer.
+func reviewResult(c Change, d Decision, applied bool) (string, error) {
+	var rejected, edited []string
+	for i, h := range c.Hunks {
+		switch d.hunk(i).Verdict {
+		case HunkReject:
+			rejected = append(rejected, h.Header())
+		case HunkEdit:
+			edited = append(edited, h.Header())
+		}
+	}
+
+	if len(rejected) > 0 {
+		return "", &ReviewRejection{Path: c.Path, Rejected: rejected, Edited: edited, Applied: applied, Comment: d.Comment}
+	}
+
+	result := "ok"
+	if len(edited) > 0 {
+		result += fmt.Sprintf("; the reviewer rewrote hunks %s, re-read the file before editing it again", strings.Join(edited, ", "))
+	}
+	if d.Comment != "" {
+		result += "; reviewer comment: " + d.Comment
+	}
+	return result, nil
+}
+
+// readFile returns the content of path as the current turn sees it: the
+// staged content when the edit is awaiting end-of-turn review.
+func (f *FS) readFile(ctx context.Context, path string) ([]byte, error) {
+	if st := stagingFromContext(ctx); st != nil {
+		if content, ok := st.content(path); ok {
+			return []byte(content), nil
+		}
+	}
+	return os.ReadFile(path) //nolint:gosec // path is approved by user
+}
+
+// openFile opens path for reading like readFile.
+func (f *FS) openFile(ctx context.Context, path string) (io.ReadCloser, error) {
+	if st := stagingFromContext(ctx); st != nil {
+		if content, ok := st.content(path); ok {
+			return io.NopCloser(strings.NewReader(content)), nil
+		}
+	}
+	return os.Open(path) //nolint:gosec // path is approved by user
+}
+
+// writeFile writes content to path, creating parent directories as needed.
+func writeFile(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("create dirs: %w", err)
+	}
+	return os.WriteFile(path, []byte(content), fileMode(path))
+}
+
+// splitLines splits s into lines that keep their terminators. A final line
+// without one is kept as is.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func appendDiffLines(dst []DiffLine, op string, lines []string) []DiffLine {
+	for _, l := range lines {
+		dst = append(dst, DiffLine{Op: op, Text: strings.TrimSuffix(l, "\n")})
+	}
+	return dst
+}
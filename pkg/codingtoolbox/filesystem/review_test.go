@@ -0,0 +1,181 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// twoHunks is a file whose edit at lines 2 and 11 yields two hunks.
+const twoHunks = "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+
+func TestNewChange_Hunks(t *testing.T) {
+	newContent := strings.Replace(strings.Replace(twoHunks, "b\n", "B\n", 1), "k\n", "K\nK2\n", 1)
+	c := NewChange("fs_edit", "f.txt", twoHunks, newContent)
+
+	require.Len(t, c.Hunks, 2)
+	assert.Equal(t, "@@ -1,5 +1,5 @@", c.Hunks[0].Header())
+	assert.Equal(t, "@@ -8,5 +8,6 @@", c.Hunks[1].Header())
+	assert.Equal(t, []DiffLine{
+		{Op: " ", Text: "a"}, {Op: "-", Text: "b"}, {Op: "+", Text: "B"},
+		{Op: " ", Text: "c"}, {Op: " ", Text: "d"}, {Op: " ", Text: "e"},
+	}, c.Hunks[0].Lines)
+	assert.Equal(t, "h\ni\nj\nK\nK2\nl\n", c.Hunks[1].NewText())
+}
+
+func TestNewChange_NewFile(t *testing.T) {
+	c := NewChange("fs_write", "f.txt", "", "x\ny\n")
+
+	require.Len(t, c.Hunks, 1)
+	assert.Equal(t, "@@ -0,0 +1,2 @@", c.Hunks[0].Header())
+	assert.Equal(t, c.New, c.Apply(AcceptAll(c)))
+	assert.Empty(t, c.Apply(RejectAll(c, "")))
+}
+
+func TestChange_Apply(t *testing.T) {
+	newContent := strings.Replace(strings.Replace(twoHunks, "b\n", "B\n", 1), "k\n", "K\n", 1)
+	c := NewChange("fs_edit", "f.txt", twoHunks, newContent)
+
+	tests := []struct {
+		name     string
+		decision Decision
+		want     string
+	}{
+		{"accept all", AcceptAll(c), newContent},
+		{"missing verdicts accept", Decision{}, newContent},
+		{"reject all", RejectAll(c, ""), twoHunks},
+		{
+			"reject second",
+			Decision{Hunks: []HunkDecision{{Verdict: HunkAccept}, {Verdict: HunkReject}}},
+			strings.Replace(twoHunks, "b\n", "B\n", 1),
+		},
+		{
+			"edit first",
+			Decision{Hunks: []HunkDecision{{Verdict: HunkEdit, Text: "a\nbee\nc\nd\ne\n"}, {Verdict: HunkReject}}},
+			strings.Replace(twoHunks, "b\n", "bee\n", 1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, c.Apply(tt.decision))
+		})
+	}
+}
+
+func TestChange_Apply_NoTrailingNewline(t *testing.T) {
+	c := NewChange("fs_write", "f.txt", "one\ntwo", "one\n2")
+
+	assert.Equal(t, "one\n2", c.Apply(AcceptAll(c)))
+	assert.Equal(t, "one\ntwo", c.Apply(RejectAll(c, "")))
+}
+
+// reviewWith returns a ReviewFunc answering with decide and recording the
+// changes it was given.
+func reviewWith(seen *[]Change, decide func(Change) Decision) ReviewFunc {
+	return func(_ context.Context, c Change) (Decision, error) {
+		*seen = append(*seen, c)
+		return decide(c), nil
+	}
+}
+
+func TestReview_Each(t *testing.T) {
+	fs, dir := newTestFS(t, autoApprove)
+	path := filepath.Join(dir, "f.txt")
+	require.NoError(t, os.WriteFile(path, []byte(twoHunks), 0o600))
+
+	var seen []Change
+	fs.SetReview(ReviewEach, reviewWith(&seen, func(Change) Decision {
+		return Decision{Hunks: []HunkDecision{{Verdict: HunkAccept}, {Verdict: HunkReject}}, Comment: "keep k"}
+	}))
+	tb := fs.Tools()
+
+	tr := callTool(tb, context.Background(), content.ToolCall{
+		ID:   "tc1",
+		Name: "fs_patch",
+		Arguments: mustJSON(t, patchInput{Path: path, Hunks: []hunk{
+			{OldText: "b\n", NewText: "B\n"},
+			{OldText: "k\n", NewText: "K\n"},
+		}}),
+	})
+
+	assert.True(t, tr.IsError)
+	assert.Contains(t, tr.Content, `"rejected_hunks":["@@ -8,5 +8,5 @@"]`)
+	assert.Contains(t, tr.Content, `"applied":true`)
+	assert.Contains(t, tr.Content, `"comment":"keep k"`)
+	require.Len(t, seen, 1)
+	assert.Equal(t, "fs_patch", seen[0].Tool)
+
+	data, err := os.ReadFile(path) //nolint:gosec // test file
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(twoHunks, "b\n", "B\n", 1), string(data))
+}
+
+func TestReview_EachEdited(t *testing.T) {
+	fs, dir := newTestFS(t, autoApprove)
+	path := filepath.Join(dir, "f.txt")
+
+	var seen []Change
+	fs.SetReview(ReviewEach, reviewWith(&seen, func(Change) Decision {
+		return Decision{Hunks: []HunkDecision{{Verdict: HunkEdit, Text: "hello there\n"}}}
+	}))
+
+	tr := callTool(fs.Tools(), context.Background(), content.ToolCall{
+		ID:        "tc1",
+		Name:      "fs_write",
+		Arguments: mustJSON(t, writeInput{Path: path, Content: "hello\n"}),
+	})
+
+	assert.False(t, tr.IsError, tr.Content)
+	assert.Contains(t, tr.Content, "rewrote hunks @@ -0,0 +1 @@")
+
+	data, err := os.ReadFile(path) //nolint:gosec // test file
+	require.NoError(t, err)
+	assert.Equal(t, "hello there\n", string(data))
+}
+
+func TestReview_EachError(t *testing.T) {
+	fs, dir := newTestFS(t, autoApprove)
+	path := filepath.Join(dir, "f.txt")
+	fs.SetReview(ReviewEach, func(context.Context, Change) (Decision, error) {
+		return Decision{}, errors.New("no reviewer")
+	})
+
+	tr := callTool(fs.Tools(), context.Background(), content.ToolCall{
+		ID:        "tc1",
+		Name:      "fs_write",
+		Arguments: mustJSON(t, writeInput{Path: path, Content: "hello\n"}),
+	})
+
+	assert.True(t, tr.IsError)
+	assert.Contains(t, tr.Content, "no reviewer")
+	assert.NoFileExists(t, path)
+}
+
+func TestReview_IgnoresSessionTrust(t *testing.T) {
+	fs, dir := newTestFS(t, autoApprove)
+	path := filepath.Join(dir, "f.txt")
+
+	var seen []Change
+	fs.SetReview(ReviewEach, reviewWith(&seen, func(c Change) Decision { return RejectAll(c, "") }))
+
+	st := &SessionTrust{}
+	st.Trust()
+	tr := callTool(fs.Tools(), WithSessionTrust(context.Background(), st), content.ToolCall{
+		ID:        "tc1",
+		Name:      "fs_write",
+		Arguments: mustJSON(t, writeInput{Path: path, Content: "hello\n"}),
+	})
+
+	assert.True(t, tr.IsError)
+	assert.Contains(t, tr.Content, `"applied":false`)
+	assert.Len(t, seen, 1)
+	assert.NoFileExists(t, path)
+}
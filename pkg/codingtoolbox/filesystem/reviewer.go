@@ -0,0 +1,83 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// ReviewRequest is a change presented to the frontend for review.
+type ReviewRequest struct {
+	ID     string `json:"id"`
+	Change Change `json:"change"`
+}
+
+// OnReviewFunc is called when a change awaits review. Implementations should
+// notify the frontend so it can present the diff and collect a decision.
+type OnReviewFunc func(ctx context.Context, r ReviewRequest)
+
+// Reviewer manages pending change reviews and their decisions, like
+// ask.Responder does for questions. Its Review method is a ReviewFunc.
+type Reviewer struct {
+	mu       sync.Mutex
+	pending  map[string]chan Decision
+	onReview OnReviewFunc
+	nextID   atomic.Int64
+}
+
+// NewReviewer creates a Reviewer. The onReview callback is invoked for every
+// change awaiting review. If onReview is nil, reviews are still registered but
+// no notification is sent.
+func NewReviewer(onReview OnReviewFunc) *Reviewer {
+	return &Reviewer{
+		pending:  make(map[string]chan Decision),
+		onReview: onReview,
+	}
+}
+
+// Review registers c, notifies the frontend and blocks until a decision is
+// delivered with Respond or ctx is cancelled.
+func (r *Reviewer) Review(ctx context.Context, c Change) (Decision, error) {
+	id := fmt.Sprintf("r-%d", r.nextID.Add(1))
+	ch := make(chan Decision, 1)
+
+	r.mu.Lock()
+	r.pending[id] = ch
+	r.mu.Unlock()
+
+	if r.onReview != nil {
+		r.onReview(ctx, ReviewRequest{ID: id, Change: c})
+	}
+
+	select {
+	case d := <-ch:
+		return d, nil
+	case <-ctx.Done():
+		// Prefer a decision that arrived just before cancellation.
+		select {
+		case d := <-ch:
+			return d, nil
+		default:
+		}
+		r.mu.Lock()
+		delete(r.pending, id)
+		r.mu.Unlock()
+		return Decision{}, ctx.Err()
+	}
+}
+
+// Respond delivers the decision for a pending review. It returns an error if
+// the review ID is not found or no longer awaits a decision.
+func (r *Reviewer) Respond(id string, d Decision) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ch, ok := r.pending[id]
+	if !ok {
+		return fmt.Errorf("filesystem: review %q not found", id)
+	}
+	delete(r.pending, id)
+	ch <- d // Buffered and sent at most once.
+	return nil
+}
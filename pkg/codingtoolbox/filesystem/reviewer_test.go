@@ -0,0 +1,42 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReviewer_Respond(t *testing.T) {
+	requests := make(chan ReviewRequest, 1)
+	r := NewReviewer(func(_ context.Context, req ReviewRequest) { requests <- req })
+
+	c := NewChange("fs_write", "f.txt", "", "x\n")
+	done := make(chan Decision, 1)
+	go func() {
+		d, err := r.Review(context.Background(), c)
+		assert.NoError(t, err)
+		done <- d
+	}()
+
+	req := <-requests
+	assert.Equal(t, c, req.Change)
+	require.NoError(t, r.Respond(req.ID, RejectAll(c, "no")))
+
+	d := <-done
+	assert.Equal(t, "no", d.Comment)
+	assert.Error(t, r.Respond(req.ID, AcceptAll(c)), "a review is answered once")
+}
+
+func TestReviewer_Cancelled(t *testing.T) {
+	var id string
+	r := NewReviewer(func(_ context.Context, req ReviewRequest) { id = req.ID })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := r.Review(ctx, Change{})
+
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Error(t, r.Respond(id, Decision{}))
+}
@@ -0,0 +1,149 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Staging holds the fs_write, fs_edit and fs_patch edits of one turn in
+// ReviewEndOfTurn mode. Staged content is what fs_read, fs_read_lines and
+// further edits see during the turn; other tools see the files on disk. It is
+// safe for concurrent use.
+type Staging struct {
+	mu    sync.Mutex
+	order []string
+	files map[string]*stagedFile
+}
+
+// stagedFile is the pending edit of one file.
+type stagedFile struct {
+	tools    []string
+	original string // Content on disk when the file was first staged.
+	content  string
+}
+
+// Len returns the number of files with staged edits.
+func (st *Staging) Len() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return len(st.order)
+}
+
+// stage records newContent for path. oldContent is the file's content on disk
+// when it is staged for the first time.
+func (st *Staging) stage(tool, path, oldContent, newContent string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.files == nil {
+		st.files = make(map[string]*stagedFile)
+	}
+	sf, ok := st.files[path]
+	if !ok {
+		sf = &stagedFile{original: oldContent}
+		st.files[path] = sf
+		st.order = append(st.order, path)
+	}
+	if !slices.Contains(sf.tools, tool) {
+		sf.tools = append(sf.tools, tool)
+	}
+	sf.content = newContent
+}
+
+// content returns the staged content of path.
+func (st *Staging) content(path string) (string, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	sf, ok := st.files[path]
+	if !ok {
+		return "", false
+	}
+	return sf.content, true
+}
+
+// take returns the staged edits as changes in staging order and clears st.
+// Files whose staged content equals the original are dropped.
+func (st *Staging) take() []Change {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	var changes []Change
+	for _, path := range st.order {
+		sf := st.files[path]
+		if sf.content != sf.original {
+			changes = append(changes, NewChange(strings.Join(sf.tools, ", "), path, sf.original, sf.content))
+		}
+	}
+	st.order, st.files = nil, nil
+	return changes
+}
+
+type stagingKey struct{}
+
+// WithStaging returns a new context carrying the given Staging.
+func WithStaging(ctx context.Context, st *Staging) context.Context {
+	return context.WithValue(ctx, stagingKey{}, st)
+}
+
+func stagingFromContext(ctx context.Context) *Staging {
+	st, _ := ctx.Value(stagingKey{}).(*Staging)
+	return st
+}
+
+// ReviewStaged passes the edits staged in st to the reviewer one file at a
+// time, writes the reviewed content and clears st. It returns a note for the
+// model listing rejected hunks, hunks the reviewer rewrote, comments and edits
+// that could not be applied, or "" when every edit landed as proposed. When the
+// review fails (e.g. ctx is cancelled) the remaining edits are discarded.
+func (f *FS) ReviewStaged(ctx context.Context, st *Staging) string {
+	changes := st.take()
+	if len(changes) == 0 || f.review == nil {
+		return ""
+	}
+
+	var notes []string
+	for i, c := range changes {
+		note, err := f.reviewStagedChange(ctx, c)
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			for _, rest := range changes[i:] {
+				notes = append(notes, fmt.Sprintf("%s: edit discarded, the review was interrupted", rest.Path))
+			}
+			break
+		}
+		switch {
+		case err != nil:
+			notes = append(notes, fmt.Sprintf("%s: %v", c.Path, err))
+		case note != "ok":
+			notes = append(notes, fmt.Sprintf("%s: %s", c.Path, note))
+		}
+	}
+
+	if len(notes) == 0 {
+		return ""
+	}
+	return "Review of the file edits staged during the previous turn:\n- " + strings.Join(notes, "\n- ")
+}
+
+// reviewStagedChange reviews and writes one staged change. The change is not
+// applied when the file was modified on disk after it was staged.
+func (f *FS) reviewStagedChange(ctx context.Context, c Change) (string, error) {
+	f.locker.Lock(c.Path)
+	defer f.locker.Unlock(c.Path)
+
+	current := ""
+	if data, err := os.ReadFile(c.Path); err == nil { //nolint:gosec // path was approved when the edit was staged
+		current = string(data)
+	}
+	if current != c.Old {
+		return "", fmt.Errorf("edit not applied, the file changed on disk after the edit was staged")
+	}
+
+	return f.reviewEdit(ctx, c)
+}
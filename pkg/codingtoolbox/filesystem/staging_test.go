@@ -0,0 +1,115 @@
+package filesystem
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaging_EndOfTurn(t *testing.T) {
+	fs, dir := newTestFS(t, autoApprove)
+	edited := filepath.Join(dir, "edited.txt")
+	created := filepath.Join(dir, "created.txt")
+	require.NoError(t, os.WriteFile(edited, []byte("one\ntwo\n"), 0o600))
+
+	var seen []Change
+	fs.SetReview(ReviewEndOfTurn, reviewWith(&seen, func(c Change) Decision {
+		if c.Path == created {
+			return RejectAll(c, "not needed")
+		}
+		return AcceptAll(c)
+	}))
+	tb := fs.Tools()
+
+	st := &Staging{}
+	ctx := WithStaging(context.Background(), st)
+
+	calls := []content.ToolCall{
+		{ID: "tc1", Name: "fs_edit", Arguments: mustJSON(t, editInput{Path: edited, OldText: "two", NewText: "2"})},
+		{ID: "tc2", Name: "fs_edit", Arguments: mustJSON(t, editInput{Path: edited, OldText: "one", NewText: "1"})},
+		{ID: "tc3", Name: "fs_write", Arguments: mustJSON(t, writeInput{Path: created, Content: "new\n"})},
+	}
+	for _, tc := range calls {
+		tr := callTool(tb, ctx, tc)
+		require.False(t, tr.IsError, tr.Content)
+		assert.Contains(t, tr.Content, "staged")
+	}
+
+	// Reads see the staged content; the disk does not change until review.
+	tr := callTool(tb, ctx, content.ToolCall{ID: "tc4", Name: "fs_read", Arguments: mustJSON(t, pathInput{Path: edited})})
+	assert.Equal(t, "1\n2\n", tr.Content)
+	tr = callTool(tb, ctx, content.ToolCall{ID: "tc5", Name: "fs_read_lines", Arguments: mustJSON(t, readLinesInput{Path: created})})
+	assert.Contains(t, tr.Content, "new")
+	assert.NoFileExists(t, created)
+	assert.Equal(t, 2, st.Len())
+	assert.Empty(t, seen)
+
+	note := fs.ReviewStaged(context.Background(), st)
+
+	require.Len(t, seen, 2)
+	assert.Equal(t, "fs_edit", seen[0].Tool)
+	assert.Equal(t, "one\ntwo\n", seen[0].Old)
+	assert.Contains(t, note, created)
+	assert.Contains(t, note, `"comment":"not needed"`)
+	assert.NotContains(t, note, edited+":")
+	assert.Zero(t, st.Len())
+
+	data, err := os.ReadFile(edited) //nolint:gosec // test file
+	require.NoError(t, err)
+	assert.Equal(t, "1\n2\n", string(data))
+	assert.NoFileExists(t, created)
+}
+
+func TestStaging_Conflict(t *testing.T) {
+	fs, dir := newTestFS(t, autoApprove)
+	path := filepath.Join(dir, "f.txt")
+	require.NoError(t, os.WriteFile(path, []byte("one\n"), 0o600))
+
+	var seen []Change
+	fs.SetReview(ReviewEndOfTurn, reviewWith(&seen, AcceptAll))
+
+	st := &Staging{}
+	tr := callTool(fs.Tools(), WithStaging(context.Background(), st), content.ToolCall{
+		ID: "tc1", Name: "fs_write", Arguments: mustJSON(t, writeInput{Path: path, Content: "two\n"}),
+	})
+	require.False(t, tr.IsError, tr.Content)
+
+	require.NoError(t, os.WriteFile(path, []byte("changed\n"), 0o600))
+	note := fs.ReviewStaged(context.Background(), st)
+
+	assert.Contains(t, note, "changed on disk")
+	assert.Empty(t, seen)
+	data, err := os.ReadFile(path) //nolint:gosec // test file
+	require.NoError(t, err)
+	assert.Equal(t, "changed\n", string(data))
+}
+
+func TestStaging_Interrupted(t *testing.T) {
+	fs, dir := newTestFS(t, autoApprove)
+	fs.SetReview(ReviewEndOfTurn, NewReviewer(nil).Review)
+
+	st := &Staging{}
+	st.stage("fs_write", filepath.Join(dir, "a.txt"), "", "a\n")
+	st.stage("fs_write", filepath.Join(dir, "b.txt"), "", "b\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	note := fs.ReviewStaged(ctx, st)
+
+	assert.Contains(t, note, "a.txt: edit discarded")
+	assert.Contains(t, note, "b.txt: edit discarded")
+	assert.NoFileExists(t, filepath.Join(dir, "a.txt"))
+}
+
+func TestStaging_RevertedEditDropped(t *testing.T) {
+	st := &Staging{}
+	st.stage("fs_write", "/tmp/f.txt", "same\n", "other\n")
+	st.stage("fs_write", "/tmp/f.txt", "other\n", "same\n")
+
+	assert.Empty(t, st.take())
+}
@@ -0,0 +1,33 @@
+package memory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockFile takes the lock that serializes read-modify-write cycles on the
+// memory file across processes: an exclusive advisory lock on a sibling
+// ".lock" file, which the OS releases if the process dies. The lock file is
+// never removed, so every process locks the same inode. The returned
+// function releases the lock.
+func (s *Store) lockFile() (func(), error) {
+	path := s.path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("memory: create dir: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("memory: lock: %w", err)
+	}
+	if err := lock(f); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("memory: lock: %w", err)
+	}
+
+	return func() {
+		_ = unlock(f)
+		_ = f.Close()
+	}, nil
+}
@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package memory
+
+import "os"
+
+// lock is a no-op on platforms without flock or LockFileEx; the Store's mutex
+// still serializes writers within the process.
+func lock(*os.File) error { return nil }
+
+func unlock(*os.File) error { return nil }
@@ -0,0 +1,18 @@
+//go:build windows
+
+package memory
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lock blocks until it holds an exclusive lock on the first byte of f.
+func lock(f *os.File) error {
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, &windows.Overlapped{})
+}
+
+func unlock(f *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &windows.Overlapped{})
+}
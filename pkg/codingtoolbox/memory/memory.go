@@ -0,0 +1,484 @@
+// Package memory provides a long-term memory store for agents. Memories are
+// short typed entries (user preferences, project facts, procedures) kept in a
+// JSON file that outlives sessions. Near-duplicate entries are merged, and
+// Recall ranks entries by relevance to a query so that the most useful ones
+// can be injected into system prompts.
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Kind classifies a memory.
+type Kind string
+
+// Memory kinds.
+const (
+	KindPreference Kind = "preference" // How the user likes things done.
+	KindFact       Kind = "fact"       // Something true about the project.
+	KindProcedure  Kind = "procedure"  // Steps that worked for a recurring task.
+)
+
+// Kinds lists the memory kinds in display order.
+var Kinds = []Kind{KindPreference, KindFact, KindProcedure}
+
+// ErrNotFound is returned when no memory has the given ID.
+var ErrNotFound = errors.New("memory: not found")
+
+// Entry is one memory.
+type Entry struct {
+	ID        string    `json:"id"`
+	Kind      Kind      `json:"kind"`
+	Content   string    `json:"content"`
+	Tags      []string  `json:"tags,omitempty"`
+	Agent     string    `json:"agent,omitempty"` // Agent that saved it; empty when added by the user.
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Update holds the fields to change on an entry. Zero fields are left as
+// they are.
+type Update struct {
+	Kind    Kind
+	Content string
+	Tags    []string
+}
+
+// duplicateSimilarity is the word overlap (Jaccard index) above which a new
+// memory is merged into an existing one of the same kind.
+const duplicateSimilarity = 0.8
+
+// preferenceBoost is added to the relevance of preferences, which apply to
+// most tasks even when they share no words with the query.
+const preferenceBoost = 0.5
+
+// file is the on-disk format.
+type file struct {
+	NextID  int     `json:"next_id"`
+	Entries []Entry `json:"entries"`
+}
+
+// Store manages memories persisted in a JSON file. The file is read on every
+// call and changes hold a lock file while they read, modify and write it, so
+// several processes (e.g. the TUI and the daemon) can share it.
+type Store struct {
+	path string
+	mu   sync.Mutex
+	now  func() time.Time
+}
+
+// New creates a Store backed by the file at path. The file and its directory
+// are created on the first write.
+func New(path string) *Store {
+	return &Store{path: path, now: time.Now}
+}
+
+// List returns all memories, most recently updated first.
+func (s *Store) List() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	sortByUpdated(f.Entries)
+	return f.Entries, nil
+}
+
+// Get returns the memory with the given ID.
+func (s *Store) Get(id string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.load()
+	if err != nil {
+		return Entry{}, err
+	}
+	i, err := f.index(id)
+	if err != nil {
+		return Entry{}, err
+	}
+	return f.Entries[i], nil
+}
+
+// Remember saves a memory. When an entry of the same kind says nearly the
+// same thing, it is updated with the new wording and tags instead, and merged
+// is true. The saved entry is returned.
+func (s *Store) Remember(e Entry) (saved Entry, merged bool, err error) {
+	e.Content = strings.TrimSpace(e.Content)
+	if e.Content == "" {
+		return Entry{}, false, fmt.Errorf("memory: empty content")
+	}
+	if err := validKind(e.Kind); err != nil {
+		return Entry{}, false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lockFile()
+	if err != nil {
+		return Entry{}, false, err
+	}
+	defer unlock()
+
+	f, err := s.load()
+	if err != nil {
+		return Entry{}, false, err
+	}
+
+	now := s.now().UTC()
+	if i := f.duplicate(e); i >= 0 {
+		d := &f.Entries[i]
+		d.Content = e.Content
+		d.Tags = mergeTags(d.Tags, e.Tags)
+		d.UpdatedAt = now
+		if e.Agent != "" {
+			d.Agent = e.Agent
+		}
+		saved, merged = *d, true
+	} else {
+		f.NextID++
+		e.ID = fmt.Sprintf("m%d", f.NextID)
+		e.Tags = mergeTags(nil, e.Tags)
+		e.CreatedAt, e.UpdatedAt = now, now
+		f.Entries = append(f.Entries, e)
+		saved = e
+	}
+
+	if err := s.save(f); err != nil {
+		return Entry{}, false, err
+	}
+	return saved, merged, nil
+}
+
+// Update changes the memory with the given ID and returns it.
+func (s *Store) Update(id string, u Update) (Entry, error) {
+	if u.Kind != "" {
+		if err := validKind(u.Kind); err != nil {
+			return Entry{}, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lockFile()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer unlock()
+
+	f, err := s.load()
+	if err != nil {
+		return Entry{}, err
+	}
+	i, err := f.index(id)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	e := &f.Entries[i]
+	if u.Kind != "" {
+		e.Kind = u.Kind
+	}
+	if c := strings.TrimSpace(u.Content); c != "" {
+		e.Content = c
+	}
+	if u.Tags != nil {
+		e.Tags = mergeTags(nil, u.Tags)
+	}
+	e.UpdatedAt = s.now().UTC()
+
+	updated := *e
+	if err := s.save(f); err != nil {
+		return Entry{}, err
+	}
+	return updated, nil
+}
+
+// Forget deletes the memory with the given ID.
+func (s *Store) Forget(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := s.load()
+	if err != nil {
+		return err
+	}
+	i, err := f.index(id)
+	if err != nil {
+		return err
+	}
+	f.Entries = slices.Delete(f.Entries, i, i+1)
+	return s.save(f)
+}
+
+// Recall returns up to k memories (all when k <= 0) ranked by relevance to
+// query: the words they share with it, weighted by how rare each word is
+// among the memories. Preferences always qualify, so an empty query returns
+// the preferences, most recently updated first. An optional kind restricts
+// the results.
+func (s *Store) Recall(query string, k int, kind Kind) ([]Entry, error) {
+	s.mu.Lock()
+	f, err := s.load()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, e := range f.Entries {
+		if kind == "" || e.Kind == kind {
+			entries = append(entries, e)
+		}
+	}
+
+	docs := make([][]string, len(entries))
+	df := make(map[string]int)
+	for i, e := range entries {
+		docs[i] = words(e.Content + " " + strings.Join(e.Tags, " "))
+		for _, w := range docs[i] {
+			df[w]++
+		}
+	}
+
+	queryWords := words(query)
+	type scored struct {
+		entry Entry
+		score float64
+	}
+	var ranked []scored
+	for i, e := range entries {
+		score := 0.0
+		for _, q := range queryWords {
+			if n := matches(q, docs[i], df); n > 0 {
+				score += math.Log(1 + float64(len(entries))/float64(n))
+			}
+		}
+		if e.Kind == KindPreference {
+			score += preferenceBoost
+		}
+		if score > 0 {
+			ranked = append(ranked, scored{e, score})
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].entry.UpdatedAt.After(ranked[j].entry.UpdatedAt)
+	})
+	if k > 0 && len(ranked) > k {
+		ranked = ranked[:k]
+	}
+
+	out := make([]Entry, len(ranked))
+	for i, r := range ranked {
+		out[i] = r.entry
+	}
+	return out, nil
+}
+
+// Format renders memories one per line for tool results and system prompts.
+func Format(entries []Entry) string {
+	var b strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&b, "- [%s %s] %s", e.ID, e.Kind, e.Content)
+		if len(e.Tags) > 0 {
+			fmt.Fprintf(&b, " (tags: %s)", strings.Join(e.Tags, ", "))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// --- persistence ---
+
+func (s *Store) load() (file, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return file{}, nil
+		}
+		return file{}, fmt.Errorf("memory: read: %w", err)
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return file{}, fmt.Errorf("memory: parse %s: %w", s.path, err)
+	}
+	return f, nil
+}
+
+func (s *Store) save(f file) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("memory: marshal: %w", err)
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("memory: create dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".memory-*.tmp")
+	if err != nil {
+		return fmt.Errorf("memory: create temp file: %w", err)
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName) //nolint:gosec // tmpName comes from os.CreateTemp in a known directory
+		return fmt.Errorf("memory: write temp file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName) //nolint:gosec // tmpName comes from os.CreateTemp in a known directory
+		return fmt.Errorf("memory: close temp file: %w", err)
+	}
+
+	if err := os.Rename(tmpName, s.path); err != nil { //nolint:gosec // tmpName comes from os.CreateTemp in a known directory
+		_ = os.Remove(tmpName) //nolint:gosec // tmpName comes from os.CreateTemp in a known directory
+		return fmt.Errorf("memory: rename temp file: %w", err)
+	}
+
+	return nil
+}
+
+func (f file) index(id string) (int, error) {
+	for i, e := range f.Entries {
+		if e.ID == id {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// duplicate returns the index of an entry of the same kind that says nearly
+// the same thing as e, or -1.
+func (f file) duplicate(e Entry) int {
+	ew := set(words(e.Content))
+	for i, d := range f.Entries {
+		if d.Kind != e.Kind {
+			continue
+		}
+		if normalize(d.Content) == normalize(e.Content) || jaccard(ew, set(words(d.Content))) >= duplicateSimilarity {
+			return i
+		}
+	}
+	return -1
+}
+
+// --- helpers ---
+
+func validKind(k Kind) error {
+	if slices.Contains(Kinds, k) {
+		return nil
+	}
+	return fmt.Errorf("memory: unknown kind %q (want preference, fact or procedure)", k)
+}
+
+// sortByUpdated sorts entries most recently updated first; ties keep the
+// latest added first.
+func sortByUpdated(entries []Entry) {
+	slices.Reverse(entries)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].UpdatedAt.After(entries[j].UpdatedAt)
+	})
+}
+
+// mergeTags returns the lowercased union of two tag lists, without blanks.
+func mergeTags(a, b []string) []string {
+	var out []string
+	for _, t := range slices.Concat(a, b) {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// normalize lowercases s, collapses whitespace and drops trailing
+// punctuation.
+func normalize(s string) string {
+	s = strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	return strings.TrimRightFunc(s, unicode.IsPunct)
+}
+
+// stopWords are ignored when comparing and ranking memories.
+var stopWords = map[string]struct{}{
+	"the": {}, "and": {}, "for": {}, "with": {}, "from": {}, "that": {},
+	"this": {}, "are": {}, "was": {}, "not": {}, "but": {}, "use": {},
+	"when": {}, "into": {}, "should": {}, "always": {}, "never": {},
+}
+
+// words splits s into lowercase words of three or more letters or digits,
+// without stop words.
+func words(s string) []string {
+	var out []string
+	for w := range strings.FieldsFuncSeq(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(w)) < 3 {
+			continue
+		}
+		if _, stop := stopWords[w]; stop {
+			continue
+		}
+		out = append(out, w)
+	}
+	return out
+}
+
+// matches reports how many memories contain a word matching q (0 when doc
+// does not). Words match when equal or when one is a prefix of the other and
+// at least four letters long, so "test" matches "tests" and "testing".
+func matches(q string, doc []string, df map[string]int) int {
+	best := 0
+	for _, w := range doc {
+		if w == q || (len(q) >= 4 && len(w) >= 4 && (strings.HasPrefix(w, q) || strings.HasPrefix(q, w))) {
+			best = max(best, df[w])
+		}
+	}
+	return best
+}
+
+func set(ws []string) map[string]struct{} {
+	m := make(map[string]struct{}, len(ws))
+	for _, w := range ws {
+		m[w] = struct{}{}
+	}
+	return m
+}
+
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	inter := 0
+	for w := range a {
+		if _, ok := b[w]; ok {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
@@ -0,0 +1,217 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/agentctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStore returns a Store in a temp dir whose clock advances a minute per
+// write, so that entries have distinct update times.
+func newStore(t *testing.T) *Store {
+	t.Helper()
+	s := New(filepath.Join(t.TempDir(), "local", "memory.json"))
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time {
+		clock = clock.Add(time.Minute)
+		return clock
+	}
+	return s
+}
+
+func ids(entries []Entry) []string {
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func TestStore_RememberUpdateForget(t *testing.T) {
+	s := newStore(t)
+
+	entries, err := s.List()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	a, merged, err := s.Remember(Entry{Kind: KindFact, Content: "Tests run with go test ./...", Tags: []string{"Go", "tests"}})
+	require.NoError(t, err)
+	assert.False(t, merged)
+	assert.Equal(t, "m1", a.ID)
+	assert.Equal(t, []string{"go", "tests"}, a.Tags)
+
+	b, _, err := s.Remember(Entry{Kind: KindPreference, Content: "Keep commit messages short"})
+	require.NoError(t, err)
+	assert.Equal(t, "m2", b.ID)
+
+	// A near-duplicate of the same kind updates the existing entry.
+	d, merged, err := s.Remember(Entry{Kind: KindFact, Content: "tests run with go test ./... .", Tags: []string{"ci"}})
+	require.NoError(t, err)
+	assert.True(t, merged)
+	assert.Equal(t, "m1", d.ID)
+	assert.Equal(t, []string{"go", "tests", "ci"}, d.Tags)
+	assert.True(t, d.UpdatedAt.After(a.UpdatedAt))
+
+	// The same words as a different kind are a separate memory.
+	c, merged, err := s.Remember(Entry{Kind: KindProcedure, Content: "Tests run with go test ./..."})
+	require.NoError(t, err)
+	assert.False(t, merged)
+	assert.Equal(t, "m3", c.ID)
+
+	entries, err = s.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"m3", "m1", "m2"}, ids(entries), "most recently updated first")
+
+	u, err := s.Update("m2", Update{Content: "Keep commit subjects under 72 characters", Tags: []string{"git"}})
+	require.NoError(t, err)
+	assert.Equal(t, KindPreference, u.Kind)
+	assert.Equal(t, []string{"git"}, u.Tags)
+
+	require.NoError(t, s.Forget("m3"))
+	require.ErrorIs(t, s.Forget("m3"), ErrNotFound)
+	_, err = s.Update("m9", Update{Content: "x"})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// IDs are not reused after a delete.
+	e, _, err := s.Remember(Entry{Kind: KindFact, Content: "The API listens on port 8080"})
+	require.NoError(t, err)
+	assert.Equal(t, "m4", e.ID)
+
+	// Entries survive reopening the store.
+	got, err := New(s.path).Get("m2")
+	require.NoError(t, err)
+	assert.Equal(t, "Keep commit subjects under 72 characters", got.Content)
+}
+
+func TestStore_Remember_Invalid(t *testing.T) {
+	s := newStore(t)
+
+	_, _, err := s.Remember(Entry{Kind: KindFact, Content: "  "})
+	require.Error(t, err)
+	_, _, err = s.Remember(Entry{Kind: "rumour", Content: "x"})
+	require.ErrorContains(t, err, `unknown kind "rumour"`)
+	_, err = s.Update("m1", Update{Kind: "rumour"})
+	require.Error(t, err)
+}
+
+func TestStore_SharedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "local", "memory.json")
+	a, b := New(path), New(path)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		s := a
+		if i%2 == 1 {
+			s = b
+		}
+		wg.Go(func() {
+			_, _, err := s.Remember(Entry{Kind: KindFact, Content: fmt.Sprintf("Service number %d listens on port %d", i, 8000+i)})
+			assert.NoError(t, err)
+		})
+	}
+	wg.Wait()
+
+	entries, err := a.List()
+	require.NoError(t, err)
+	assert.Len(t, entries, 20)
+}
+
+func TestStore_LeftoverLockFile(t *testing.T) {
+	s := newStore(t)
+	require.NoError(t, os.MkdirAll(filepath.Dir(s.path), 0o750))
+	require.NoError(t, os.WriteFile(s.path+".lock", nil, 0o600))
+
+	// A lock file left behind by a crashed process holds no lock.
+	_, _, err := s.Remember(Entry{Kind: KindFact, Content: "The lock was left by a crashed process"})
+	require.NoError(t, err)
+}
+
+func TestStore_Recall(t *testing.T) {
+	s := newStore(t)
+	for _, e := range []Entry{
+		{Kind: KindFact, Content: "The database migrations live in db/migrations"},
+		{Kind: KindProcedure, Content: "To release, tag the commit and push the tag", Tags: []string{"release"}},
+		{Kind: KindFact, Content: "Integration tests need the database container running", Tags: []string{"testing"}},
+		{Kind: KindPreference, Content: "Answer in British English"},
+		{Kind: KindFact, Content: "The frontend is built with Vite"},
+	} {
+		_, _, err := s.Remember(e)
+		require.NoError(t, err)
+	}
+
+	got, err := s.Recall("run the database tests", 3, "")
+	require.NoError(t, err)
+	// m3 matches "database" and "tests" (by prefix); m1 only "database";
+	// the preference always qualifies.
+	assert.Equal(t, []string{"m3", "m1", "m4"}, ids(got))
+
+	got, err = s.Recall("release", 0, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"m2", "m4"}, ids(got))
+
+	got, err = s.Recall("", 0, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"m4"}, ids(got), "without a query only preferences are recalled")
+
+	got, err = s.Recall("database", 0, KindProcedure)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	assert.Equal(t, "- [m2 procedure] To release, tag the commit and push the tag (tags: release)\n",
+		Format([]Entry{{ID: "m2", Kind: KindProcedure, Content: "To release, tag the commit and push the tag", Tags: []string{"release"}}}))
+}
+
+func TestTools(t *testing.T) {
+	s := newStore(t)
+	tb := s.Tools()
+	ctx := agentctx.WithAgentName(context.Background(), "coder")
+
+	call := func(name, args string) (string, error) {
+		tool, ok := tb.Get(name)
+		require.True(t, ok, name)
+		return tool.Handler(ctx, json.RawMessage(args))
+	}
+
+	out, err := call("remember", `{"kind":"preference","content":"Use tabs in Makefiles"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "Remembered as m1.", out)
+
+	out, err = call("remember", `{"kind":"preference","content":"Use tabs in Makefiles."}`)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Updated existing memory m1")
+
+	e, err := s.Get("m1")
+	require.NoError(t, err)
+	assert.Equal(t, "coder", e.Agent)
+
+	out, err = call("recall_memories", `{"query":"makefiles"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "- [m1 preference] Use tabs in Makefiles.\n", out)
+
+	out, err = call("recall_memories", `{"query":"nothing","kind":"fact"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "No matching memories.", out)
+
+	out, err = call("update_memory", `{"id":"m1","kind":"fact","content":"Makefiles require tabs"}`)
+	require.NoError(t, err)
+	assert.Contains(t, out, "[m1 fact] Makefiles require tabs")
+
+	_, err = call("remember", `{"kind":"opinion","content":"x"}`)
+	require.ErrorContains(t, err, "remember: memory: unknown kind")
+
+	out, err = call("forget_memory", `{"id":"m1"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "Forgot m1.", out)
+
+	_, err = call("forget_memory", `{"id":"m1"}`)
+	require.ErrorIs(t, err, ErrNotFound)
+}
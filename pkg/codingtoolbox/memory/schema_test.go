@@ -0,0 +1,14 @@
+package memory_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/codingtoolbox/internal/schematest"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/memory"
+)
+
+func TestToolSchemas(t *testing.T) {
+	s := memory.New(filepath.Join(t.TempDir(), "memory.json"))
+	schematest.ValidateTools(t, s.Tools())
+}
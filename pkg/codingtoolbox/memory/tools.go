@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/germanamz/shelly/pkg/agentctx"
+	"github.com/germanamz/shelly/pkg/tools/schema"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+)
+
+// defaultRecallLimit is the number of memories recall_memories returns when
+// no limit is given.
+const defaultRecallLimit = 10
+
+// Tools returns a ToolBox with remember, recall_memories, update_memory and
+// forget_memory tools.
+func (s *Store) Tools() *toolbox.ToolBox {
+	tb := toolbox.New()
+
+	tb.Register(
+		toolbox.Tool{
+			Name:        "remember",
+			Description: "Save a long-term memory that later sessions and agents will recall. Use it for durable knowledge only: a user preference (how the user wants things done), a project fact (something true about the codebase or environment) or a procedure (steps that worked for a recurring task). Keep each memory to one self-contained statement. A near-duplicate of an existing memory updates it instead.",
+			InputSchema: schema.Generate[rememberInput](),
+			Handler:     s.handleRemember,
+		},
+		toolbox.Tool{
+			Name:        "recall_memories",
+			Description: "Search long-term memories by relevance to a query. Returns each memory with its ID, kind and tags.",
+			InputSchema: schema.Generate[recallInput](),
+			Handler:     s.handleRecall,
+		},
+		toolbox.Tool{
+			Name:        "update_memory",
+			Description: "Correct an outdated or inaccurate memory by ID. Only the given fields change; tags replace the existing ones.",
+			InputSchema: schema.Generate[updateInput](),
+			Handler:     s.handleUpdate,
+		},
+		toolbox.Tool{
+			Name:        "forget_memory",
+			Description: "Delete a memory by ID when it is wrong or no longer applies.",
+			InputSchema: schema.Generate[forgetInput](),
+			Handler:     s.handleForget,
+		},
+	)
+
+	return tb
+}
+
+// --- input types ---
+
+type rememberInput struct {
+	Kind    string   `json:"kind" desc:"One of: preference, fact, procedure"`
+	Content string   `json:"content" desc:"The memory, as one self-contained statement"`
+	Tags    []string `json:"tags,omitempty" desc:"Keywords that help recall it (e.g. package or tool names)"`
+}
+
+type recallInput struct {
+	Query string `json:"query" desc:"What to look for"`
+	Kind  string `json:"kind,omitempty" desc:"Only return this kind: preference, fact or procedure"`
+	Limit int    `json:"limit,omitempty" desc:"Maximum number of memories (default 10)"`
+}
+
+type updateInput struct {
+	ID      string   `json:"id" desc:"ID of the memory (e.g. m3)"`
+	Kind    string   `json:"kind,omitempty" desc:"New kind: preference, fact or procedure"`
+	Content string   `json:"content,omitempty" desc:"New content"`
+	Tags    []string `json:"tags,omitempty" desc:"New tags"`
+}
+
+type forgetInput struct {
+	ID string `json:"id" desc:"ID of the memory to delete"`
+}
+
+// --- handlers ---
+
+func (s *Store) handleRemember(ctx context.Context, input json.RawMessage) (string, error) {
+	var in rememberInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", fmt.Errorf("remember: invalid input: %w", err)
+	}
+
+	e, merged, err := s.Remember(Entry{
+		Kind:    Kind(in.Kind),
+		Content: in.Content,
+		Tags:    in.Tags,
+		Agent:   agentctx.AgentNameFromContext(ctx),
+	})
+	if err != nil {
+		return "", fmt.Errorf("remember: %w", err)
+	}
+
+	if merged {
+		return fmt.Sprintf("Updated existing memory %s, which said nearly the same.", e.ID), nil
+	}
+	return fmt.Sprintf("Remembered as %s.", e.ID), nil
+}
+
+func (s *Store) handleRecall(_ context.Context, input json.RawMessage) (string, error) {
+	var in recallInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", fmt.Errorf("recall_memories: invalid input: %w", err)
+	}
+
+	kind := Kind(in.Kind)
+	if kind != "" {
+		if err := validKind(kind); err != nil {
+			return "", fmt.Errorf("recall_memories: %w", err)
+		}
+	}
+
+	limit := in.Limit
+	if limit <= 0 {
+		limit = defaultRecallLimit
+	}
+
+	entries, err := s.Recall(in.Query, limit, kind)
+	if err != nil {
+		return "", fmt.Errorf("recall_memories: %w", err)
+	}
+	if len(entries) == 0 {
+		return "No matching memories.", nil
+	}
+	return Format(entries), nil
+}
+
+func (s *Store) handleUpdate(_ context.Context, input json.RawMessage) (string, error) {
+	var in updateInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", fmt.Errorf("update_memory: invalid input: %w", err)
+	}
+
+	e, err := s.Update(in.ID, Update{Kind: Kind(in.Kind), Content: in.Content, Tags: in.Tags})
+	if err != nil {
+		return "", fmt.Errorf("update_memory: %w", err)
+	}
+	return "Updated:\n" + Format([]Entry{e}), nil
+}
+
+func (s *Store) handleForget(_ context.Context, input json.RawMessage) (string, error) {
+	var in forgetInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", fmt.Errorf("forget_memory: invalid input: %w", err)
+	}
+
+	if err := s.Forget(in.ID); err != nil {
+		return "", fmt.Errorf("forget_memory: %w", err)
+	}
+	return fmt.Sprintf("Forgot %s.", in.ID), nil
+}
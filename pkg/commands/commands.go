@@ -0,0 +1,195 @@
+// Package commands loads user-defined slash commands. Each command is a
+// Markdown prompt template in .shelly/commands/ or a user-global directory,
+// with optional YAML frontmatter for its description, argument hint, target
+// agent and allowed tools.
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scopes of a command.
+const (
+	ScopeProject = "project" // From .shelly/commands/.
+	ScopeUser    = "user"    // From the user-global directory.
+)
+
+// Command is a prompt template invoked as /Name.
+type Command struct {
+	Name         string   // Path relative to the commands directory without ".md"; subdirectories join with ":" (git/review.md is "git:review").
+	Description  string   // From frontmatter.
+	ArgumentHint string   // From frontmatter, e.g. "<tag> [focus]".
+	Agent        string   // Agent that runs the command; empty = the current agent.
+	AllowedTools []string // Tool names or globs the agent may use; empty = all its tools.
+	Body         string   // Template after the frontmatter.
+	Path         string   // Absolute path of the file.
+	Scope        string   // ScopeProject or ScopeUser.
+}
+
+// frontmatter holds the optional YAML metadata of a command file.
+type frontmatter struct {
+	Description  string   `yaml:"description"`
+	ArgumentHint string   `yaml:"argument-hint"`
+	Agent        string   `yaml:"agent"`
+	AllowedTools toolList `yaml:"allowed-tools"`
+}
+
+// toolList accepts a YAML list or a comma- or space-separated string.
+type toolList []string
+
+func (l *toolList) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*l = strings.FieldsFunc(n.Value, func(r rune) bool { return r == ',' || r == ' ' })
+		return nil
+	}
+	var list []string
+	if err := n.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// Load reads the command file at path, naming it name.
+func Load(path, name, scope string) (Command, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path comes from a commands directory walk
+	if err != nil {
+		return Command{}, fmt.Errorf("commands: load %q: %w", path, err)
+	}
+
+	fm, body, err := parseFrontmatter(string(data))
+	if err != nil {
+		return Command{}, fmt.Errorf("commands: load %q: %w", path, err)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Command{}, fmt.Errorf("commands: load %q: %w", path, err)
+	}
+
+	return Command{
+		Name:         name,
+		Description:  fm.Description,
+		ArgumentHint: fm.ArgumentHint,
+		Agent:        fm.Agent,
+		AllowedTools: fm.AllowedTools,
+		Body:         strings.TrimSpace(body),
+		Path:         abs,
+		Scope:        scope,
+	}, nil
+}
+
+// LoadDir loads every *.md file under dir. A missing directory yields no
+// commands. Files that fail to load are reported in the joined error; the
+// others are still returned.
+func LoadDir(dir, scope string) ([]Command, error) {
+	var cmds []Command
+	var errs []error
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == dir {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))
+		name = strings.ReplaceAll(name, "/", ":")
+
+		c, err := Load(path, name, scope)
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		cmds = append(cmds, c)
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("commands: load dir %q: %w", dir, err))
+	}
+
+	return cmds, errors.Join(errs...)
+}
+
+// Discover loads the project and user commands, sorted by name. A project
+// command hides a user command of the same name.
+func Discover(projectDir, userDir string) ([]Command, error) {
+	var cmds []Command
+	var errs []error
+
+	seen := map[string]bool{}
+	for _, src := range []struct{ dir, scope string }{{projectDir, ScopeProject}, {userDir, ScopeUser}} {
+		if src.dir == "" {
+			continue
+		}
+		loaded, err := LoadDir(src.dir, src.scope)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, c := range loaded {
+			if !seen[c.Name] {
+				seen[c.Name] = true
+				cmds = append(cmds, c)
+			}
+		}
+	}
+
+	slices.SortFunc(cmds, func(a, b Command) int { return strings.Compare(a.Name, b.Name) })
+	return cmds, errors.Join(errs...)
+}
+
+// UserDir returns the user-global commands directory:
+// $XDG_CONFIG_HOME/shelly/commands, else ~/.config/shelly/commands. It
+// returns "" when neither can be determined.
+func UserDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "shelly", "commands")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "shelly", "commands")
+}
+
+// parseFrontmatter splits YAML frontmatter delimited by "---" lines from the
+// body. Content without frontmatter is returned whole.
+func parseFrontmatter(raw string) (frontmatter, string, error) {
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+
+	rest, ok := strings.CutPrefix(raw, "---\n")
+	if !ok {
+		return frontmatter{}, raw, nil
+	}
+
+	var block, body string
+	if before, after, found := strings.Cut(rest, "\n---\n"); found {
+		block, body = before, after
+	} else if before, found := strings.CutSuffix(rest, "\n---"); found {
+		block = before
+	} else {
+		return frontmatter{}, raw, nil
+	}
+
+	var fm frontmatter
+	if err := yaml.Unmarshal([]byte(block), &fm); err != nil {
+		return frontmatter{}, "", fmt.Errorf("invalid frontmatter: %w", err)
+	}
+	return fm, body, nil
+}
@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "review.md"), `---
+description: Review the current diff
+argument-hint: "[focus]"
+agent: reviewer
+allowed-tools: [git_diff, "fs_*"]
+---
+
+Review the diff focusing on $ARGUMENTS.
+`)
+	writeFile(t, filepath.Join(dir, "git", "log.md"), "---\nallowed-tools: git_log, git_status\n---\nSummarize.")
+	writeFile(t, filepath.Join(dir, "plain.md"), "No frontmatter here.")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "ignored")
+
+	cmds, err := LoadDir(dir, ScopeProject)
+	require.NoError(t, err)
+	require.Len(t, cmds, 3)
+
+	byName := map[string]Command{}
+	for _, c := range cmds {
+		byName[c.Name] = c
+	}
+
+	review := byName["review"]
+	assert.Equal(t, "Review the current diff", review.Description)
+	assert.Equal(t, "[focus]", review.ArgumentHint)
+	assert.Equal(t, "reviewer", review.Agent)
+	assert.Equal(t, []string{"git_diff", "fs_*"}, review.AllowedTools)
+	assert.Equal(t, "Review the diff focusing on $ARGUMENTS.", review.Body)
+	assert.Equal(t, ScopeProject, review.Scope)
+	assert.True(t, filepath.IsAbs(review.Path))
+
+	assert.Equal(t, []string{"git_log", "git_status"}, byName["git:log"].AllowedTools)
+	assert.Equal(t, "No frontmatter here.", byName["plain"].Body)
+}
+
+func TestLoadDir_Missing(t *testing.T) {
+	cmds, err := LoadDir(filepath.Join(t.TempDir(), "nope"), ScopeUser)
+	require.NoError(t, err)
+	assert.Empty(t, cmds)
+}
+
+func TestLoadDir_InvalidFrontmatter(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "bad.md"), "---\ndescription: [unclosed\n---\nbody")
+	writeFile(t, filepath.Join(dir, "good.md"), "body")
+
+	cmds, err := LoadDir(dir, ScopeProject)
+	require.ErrorContains(t, err, "invalid frontmatter")
+	require.Len(t, cmds, 1, "valid commands are still returned")
+	assert.Equal(t, "good", cmds[0].Name)
+}
+
+func TestDiscover_ProjectOverridesUser(t *testing.T) {
+	project, user := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(project, "deploy.md"), "project deploy")
+	writeFile(t, filepath.Join(user, "deploy.md"), "user deploy")
+	writeFile(t, filepath.Join(user, "standup.md"), "user standup")
+
+	cmds, err := Discover(project, user)
+	require.NoError(t, err)
+	require.Len(t, cmds, 2)
+
+	assert.Equal(t, "deploy", cmds[0].Name)
+	assert.Equal(t, "project deploy", cmds[0].Body)
+	assert.Equal(t, ScopeProject, cmds[0].Scope)
+	assert.Equal(t, "standup", cmds[1].Name)
+	assert.Equal(t, ScopeUser, cmds[1].Scope)
+}
+
+func TestUserDir(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	assert.Equal(t, filepath.Join("/xdg", "shelly", "commands"), UserDir())
+}
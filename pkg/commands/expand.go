@@ -0,0 +1,230 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds each inline !`command` when ExpandOptions.Timeout is
+// unset.
+const DefaultTimeout = 30 * time.Second
+
+// Size caps for inlined content; longer output is truncated with a note.
+const (
+	maxOutputBytes = 32 * 1024
+	maxFileBytes   = 100 * 1024
+)
+
+var (
+	// argRe matches $ARGUMENTS and the positional placeholders $1 to $9.
+	argRe = regexp.MustCompile(`\$(ARGUMENTS|[1-9])`)
+	// inlineRe matches !`command` and @path references. A reference must
+	// start a line or follow whitespace so e-mail addresses are left alone.
+	inlineRe = regexp.MustCompile("!`([^`\n]+)`|(^|\\s)@([^\\s`]+)")
+)
+
+// ExpandOptions configures Command.Expand.
+type ExpandOptions struct {
+	WorkDir string        // Directory for commands and relative @paths; empty = the process directory.
+	Timeout time.Duration // Per-command timeout; zero = DefaultTimeout.
+}
+
+// Expand renders the command's prompt for the raw argument string args:
+//
+//   - $ARGUMENTS becomes args and $1…$9 its shell-style words. A template
+//     without placeholders gets non-empty args appended after a blank line.
+//   - !`command` becomes the combined output of running command with the
+//     system shell. A failing command inlines its output and exit status.
+//   - @path becomes the contents of that file in a fenced block. References
+//     to missing files are left as written.
+//
+// Arguments are substituted first, so they may be used in commands and
+// paths. The returned error is non-nil only when ctx is done.
+func (c Command) Expand(ctx context.Context, args string, opts ExpandOptions) (string, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	prompt := substituteArgs(c.Body, strings.TrimSpace(args))
+
+	var b strings.Builder
+	last := 0
+	for _, m := range inlineRe.FindAllStringSubmatchIndex(prompt, -1) {
+		b.WriteString(prompt[last:m[0]])
+		last = m[1]
+
+		if m[2] >= 0 {
+			out, err := runInline(ctx, prompt[m[2]:m[3]], opts)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(out)
+			continue
+		}
+
+		b.WriteString(prompt[m[4]:m[5]])
+		b.WriteString(includeFile(prompt[m[6]:m[7]], opts.WorkDir))
+	}
+	b.WriteString(prompt[last:])
+
+	return b.String(), nil
+}
+
+// SplitArgs splits s into words on whitespace. Single or double quotes group
+// words and are removed; a backslash escapes the next character.
+func SplitArgs(s string) []string {
+	var words []string
+	var cur strings.Builder
+	var quote rune
+	inWord, escaped := false, false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+
+	return words
+}
+
+// substituteArgs replaces the argument placeholders in body.
+func substituteArgs(body, args string) string {
+	if !argRe.MatchString(body) {
+		if args == "" {
+			return body
+		}
+		return body + "\n\n" + args
+	}
+
+	words := SplitArgs(args)
+	return argRe.ReplaceAllStringFunc(body, func(m string) string {
+		if m == "$ARGUMENTS" {
+			return args
+		}
+		n, _ := strconv.Atoi(m[1:])
+		if n > len(words) {
+			return ""
+		}
+		return words[n-1]
+	})
+}
+
+// runInline runs command with the system shell and returns its trimmed
+// combined output.
+func runInline(ctx context.Context, command string, opts ExpandOptions) (string, error) {
+	runCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(runCtx, "cmd", "/C", command) //nolint:gosec // command comes from the user's own command file
+	} else {
+		cmd = exec.CommandContext(runCtx, "sh", "-c", command) //nolint:gosec // command comes from the user's own command file
+	}
+	cmd.Dir = opts.WorkDir
+	cmd.WaitDelay = time.Second
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	runErr := cmd.Run()
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("commands: run %q: %w", command, err)
+	}
+
+	text := truncate(strings.TrimRight(out.String(), "\n"), maxOutputBytes)
+
+	var note string
+	var exitErr *exec.ExitError
+	switch {
+	case runErr == nil:
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		note = fmt.Sprintf("(timed out after %s)", opts.Timeout)
+	case errors.As(runErr, &exitErr):
+		note = fmt.Sprintf("(exit status %d)", exitErr.ExitCode())
+	default:
+		note = fmt.Sprintf("(failed: %v)", runErr)
+	}
+
+	switch {
+	case note == "":
+		return text, nil
+	case text == "":
+		return note, nil
+	default:
+		return text + "\n" + note, nil
+	}
+}
+
+// includeFile returns the contents of the file at ref as a fenced block, or
+// the reference unchanged when it is not a readable file. Trailing sentence
+// punctuation is not part of the path.
+func includeFile(ref, workDir string) string {
+	path := strings.TrimRight(ref, ".,;:!?)]}'\"")
+	trail := ref[len(path):]
+
+	full := path
+	if !filepath.IsAbs(full) {
+		full = filepath.Join(workDir, full)
+	}
+
+	info, err := os.Stat(full)
+	if path == "" || err != nil || !info.Mode().IsRegular() {
+		return "@" + ref
+	}
+
+	data, err := os.ReadFile(full) //nolint:gosec // path is referenced by the user's own command
+	if err != nil {
+		return "@" + ref
+	}
+
+	text := truncate(strings.TrimRight(string(data), "\n"), maxFileBytes)
+	return fmt.Sprintf("%s:\n```\n%s\n```\n%s", path, text, trail)
+}
+
+// truncate caps s at limit bytes on a line boundary where possible.
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	cut := s[:limit]
+	if i := strings.LastIndexByte(cut, '\n'); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + fmt.Sprintf("\n… (truncated, %d bytes total)", len(s))
+}
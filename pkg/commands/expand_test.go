@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitArgs(t *testing.T) {
+	assert.Equal(t, []string{"a", "b c", "d'e", `f"g`, "h i"}, SplitArgs(`a "b c" "d'e" 'f"g' h\ i`))
+	assert.Equal(t, []string{"", "x"}, SplitArgs(`"" x`))
+	assert.Empty(t, SplitArgs("   "))
+}
+
+func TestExpand_Arguments(t *testing.T) {
+	ctx := context.Background()
+
+	c := Command{Body: "Tag $1 on $2 ($ARGUMENTS). Missing: [$3]"}
+	out, err := c.Expand(ctx, ` v1.2 "release branch" `, ExpandOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, `Tag v1.2 on release branch (v1.2 "release branch"). Missing: []`, out)
+
+	c = Command{Body: "Explain this."}
+	out, err = c.Expand(ctx, "the parser", ExpandOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Explain this.\n\nthe parser", out, "args are appended without placeholders")
+
+	out, err = c.Expand(ctx, "", ExpandOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Explain this.", out)
+}
+
+func TestExpand_Files(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "notes.md"), "line one\nline two\n")
+
+	c := Command{Body: "See @notes.md, @$1 and mail me@example.com or @missing.txt."}
+	out, err := c.Expand(context.Background(), "notes.md", ExpandOptions{WorkDir: dir})
+	require.NoError(t, err)
+
+	block := "notes.md:\n```\nline one\nline two\n```\n"
+	assert.Equal(t, "See "+block+", "+block+" and mail me@example.com or @missing.txt.", out)
+}
+
+func TestExpand_Shell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh syntax")
+	}
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "")
+
+	c := Command{Body: "Files:\n!`ls`\nFail:\n!`echo oops; exit 3`\nArg: !`echo $1`"}
+	out, err := c.Expand(context.Background(), "hello", ExpandOptions{WorkDir: dir})
+	require.NoError(t, err)
+	assert.Equal(t, "Files:\na.txt\nFail:\noops\n(exit status 3)\nArg: hello", out)
+}
+
+func TestExpand_ShellTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh syntax")
+	}
+	c := Command{Body: "!`sleep 5`"}
+	out, err := c.Expand(context.Background(), "", ExpandOptions{Timeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+	assert.Equal(t, "(timed out after 50ms)", out)
+}
+
+func TestExpand_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Command{Body: "!`echo hi`"}.Expand(ctx, "", ExpandOptions{})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestTruncate(t *testing.T) {
+	s := strings.Repeat("abcd\n", 10)
+	out := truncate(s, 12)
+	assert.True(t, strings.HasPrefix(out, "abcd\nabcd\n… (truncated, 50 bytes total)"), out)
+	assert.Equal(t, "short", truncate("short", 12))
+}
@@ -0,0 +1,197 @@
+package daemon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes the activation times of a cron trigger.
+type Schedule interface {
+	// Next returns the first activation time strictly after t.
+	Next(t time.Time) time.Time
+}
+
+// cronDescriptors maps the supported @-shorthands to their 5-field form.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSearchLimit bounds the search for the next activation so impossible
+// schedules (e.g. February 30th) terminate.
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// ParseSchedule parses a standard 5-field cron expression (minute hour
+// day-of-month month day-of-week) or one of @yearly, @annually, @monthly,
+// @weekly, @daily, @midnight, @hourly and "@every <duration>". Fields accept
+// "*", single values, ranges ("1-5"), lists ("1,15") and steps ("*/10",
+// "0-30/5"). Day-of-week 0 and 7 both mean Sunday. As in classic cron, when
+// both day-of-month and day-of-week are restricted a day matching either
+// activates.
+func ParseSchedule(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+
+	if rest, ok := strings.CutPrefix(expr, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("daemon: schedule %q: %w", expr, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("daemon: schedule %q: interval must be at least 1s", expr)
+		}
+		return everySchedule(d), nil
+	}
+
+	if full, ok := cronDescriptors[expr]; ok {
+		expr = full
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("daemon: schedule %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	bounds := []struct{ min, max int }{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+	var sets [5]uint64
+	for i, f := range fields {
+		set, err := parseCronField(f, bounds[i].min, bounds[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("daemon: schedule %q: field %d: %w", expr, i+1, err)
+		}
+		sets[i] = set
+	}
+
+	// Fold Sunday-as-7 into 0.
+	if sets[4]&(1<<7) != 0 {
+		sets[4] = sets[4]&^(1<<7) | 1
+	}
+
+	return &cronSchedule{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parseCronField parses one comma-separated cron field into a bit set.
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var set uint64
+
+	for part := range strings.SplitSeq(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = n
+		}
+
+		start, end := lo, hi
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = cronValue(a, lo, hi); err != nil {
+				return 0, err
+			}
+			if end, err = cronValue(b, lo, hi); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			v, err := cronValue(rangePart, lo, hi)
+			if err != nil {
+				return 0, err
+			}
+			start = v
+			if !hasStep {
+				end = v
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			set |= 1 << v
+		}
+	}
+
+	return set, nil
+}
+
+// cronValue parses a single numeric cron value within [lo, hi].
+func cronValue(s string, lo, hi int) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < lo || v > hi {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", v, lo, hi)
+	}
+	return v, nil
+}
+
+// cronSchedule is a parsed 5-field cron expression. Each field is a bit set
+// of allowed values.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+// Next implements Schedule.
+func (c *cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// dayMatches applies the classic cron day-of-month / day-of-week rule.
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	domOK := c.dom&(1<<uint(t.Day())) != 0
+	dowOK := c.dow&(1<<uint(t.Weekday())) != 0
+
+	if c.domStar || c.dowStar {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+// everySchedule fires at a fixed interval.
+type everySchedule time.Duration
+
+// Next implements Schedule.
+func (e everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
@@ -0,0 +1,73 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustNext(t *testing.T, expr string, from time.Time) time.Time {
+	t.Helper()
+	s, err := ParseSchedule(expr)
+	require.NoError(t, err)
+	return s.Next(from)
+}
+
+func TestParseSchedule_Next(t *testing.T) {
+	// Wednesday, 2026-03-04 10:17:30 UTC.
+	from := time.Date(2026, 3, 4, 10, 17, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 3, 4, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC)},
+		{"30 8-18/2 * * *", time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 1-5", time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)},
+		{"0 12 * * 0", time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 3, 4, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", from.Add(90 * time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			assert.Equal(t, tt.want, mustNext(t, tt.expr, from))
+		})
+	}
+}
+
+func TestParseSchedule_DayOfMonthOrWeek(t *testing.T) {
+	// Both restricted: the 10th OR any Friday, whichever comes first.
+	from := time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC), mustNext(t, "0 0 10 * 5", from))
+}
+
+func TestParseSchedule_Impossible(t *testing.T) {
+	assert.True(t, mustNext(t, "0 0 30 2 *", time.Now()).IsZero())
+}
+
+func TestParseSchedule_Errors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@every nope",
+		"@every 10ms",
+		"@sometimes",
+	} {
+		_, err := ParseSchedule(expr)
+		assert.Error(t, err, expr)
+	}
+}
@@ -0,0 +1,564 @@
+// Package daemon runs agents in the background in response to triggers:
+// cron schedules, file changes, webhook calls and task-board events. Each
+// trigger fire starts a fresh engine.Session for the trigger's agent with a
+// prompt rendered from the event, bounded by a per-trigger concurrency
+// limit. Sessions are persisted through the engine's session store, tagged
+// with the trigger name, so run history survives restarts.
+package daemon
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/codingtoolbox/ask"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/filesystem"
+	"github.com/germanamz/shelly/pkg/engine"
+	"github.com/germanamz/shelly/pkg/tasks"
+)
+
+// DefaultListen is the webhook listen address when DaemonConfig.Listen is empty.
+const DefaultListen = "127.0.0.1:7717"
+
+// DefaultPollInterval is how long file changes must settle before a watch
+// trigger fires, and how often busy watch and task triggers are retried, when
+// DaemonConfig.PollInterval is empty.
+const DefaultPollInterval = 2 * time.Second
+
+// SecretHeader carries a webhook trigger's shared secret.
+const SecretHeader = "X-Shelly-Secret"
+
+// maxWebhookBody caps webhook request bodies.
+const maxWebhookBody = 1 << 20
+
+// DefaultRunTimeout bounds a run when its trigger sets no timeout.
+const DefaultRunTimeout = 30 * time.Minute
+
+// denyAnswer is given to questions and file reviews in triggered runs, which
+// have nobody to answer them.
+const denyAnswer = "No user is available to answer: this is a background run started by a daemon trigger. Proceed with your best judgement, or stop and explain what you need."
+
+// eventBuffer is large enough that a busy delegation tree does not drop the
+// questions a run has to answer.
+const eventBuffer = 1024
+
+// Event is the data a trigger's prompt template is rendered with.
+type Event struct {
+	Trigger string      // Trigger name.
+	Kind    string      // Trigger kind (cron, watch, webhook, task).
+	Time    time.Time   // When the event fired.
+	Files   []string    // watch: changed paths relative to the project root.
+	Body    string      // webhook: raw request body.
+	Payload any         // webhook: request body decoded as JSON (nil if not JSON).
+	Task    *tasks.Task // task: the task that entered pending.
+}
+
+// RunResult describes one completed (or failed) triggered run.
+type RunResult struct {
+	Trigger   string
+	Agent     string
+	SessionID string // Persisted session ID (empty if the session could not be created).
+	Start     time.Time
+	Elapsed   time.Duration
+	Reply     string
+	Err       error
+}
+
+// Options configures optional Daemon behaviour.
+type Options struct {
+	Logger   *slog.Logger    // Defaults to slog.Default().
+	OnRun    func(RunResult) // Called after every run. Must be safe for concurrent use.
+	Listener net.Listener    // Serves webhooks instead of listening on DaemonConfig.Listen.
+}
+
+// trigger is a configured trigger with its parsed state.
+type trigger struct {
+	cfg      engine.TriggerConfig
+	prompt   *template.Template
+	timeout  time.Duration
+	sem      chan struct{}
+	schedule Schedule // cron only
+}
+
+// Daemon dispatches trigger events to agent sessions.
+type Daemon struct {
+	eng          *engine.Engine
+	listen       string
+	pollInterval time.Duration
+	triggers     []*trigger
+	opts         Options
+	log          *slog.Logger
+
+	runs sync.WaitGroup
+}
+
+// New creates a Daemon for the given triggers. It parses schedules and
+// prompt templates and checks that task triggers have a task board to watch.
+func New(eng *engine.Engine, cfg engine.DaemonConfig, triggers []engine.TriggerConfig, opts Options) (*Daemon, error) {
+	d := &Daemon{
+		eng:          eng,
+		listen:       cfg.Listen,
+		pollInterval: DefaultPollInterval,
+		opts:         opts,
+		log:          opts.Logger,
+	}
+	if d.listen == "" {
+		d.listen = DefaultListen
+	}
+	if cfg.PollInterval != "" {
+		pi, err := time.ParseDuration(cfg.PollInterval)
+		if err != nil || pi <= 0 {
+			return nil, fmt.Errorf("daemon: invalid poll_interval %q", cfg.PollInterval)
+		}
+		d.pollInterval = pi
+	}
+	if d.log == nil {
+		d.log = slog.Default()
+	}
+
+	for _, tc := range triggers {
+		t := &trigger{cfg: tc}
+
+		tmpl, err := template.New(tc.Name).Option("missingkey=zero").Parse(tc.Prompt)
+		if err != nil {
+			return nil, fmt.Errorf("daemon: trigger %q: prompt: %w", tc.Name, err)
+		}
+		t.prompt = tmpl
+
+		t.timeout = DefaultRunTimeout
+		if tc.Timeout != "" {
+			if t.timeout, err = time.ParseDuration(tc.Timeout); err != nil {
+				return nil, fmt.Errorf("daemon: trigger %q: timeout: %w", tc.Name, err)
+			}
+		}
+
+		limit := tc.MaxConcurrency
+		if limit <= 0 {
+			limit = 1
+		}
+		t.sem = make(chan struct{}, limit)
+
+		switch tc.Kind {
+		case engine.TriggerCron:
+			if t.schedule, err = ParseSchedule(tc.Schedule); err != nil {
+				return nil, fmt.Errorf("daemon: trigger %q: %w", tc.Name, err)
+			}
+		case engine.TriggerTask:
+			if eng.Tasks() == nil {
+				return nil, fmt.Errorf("daemon: trigger %q: task triggers require an agent with the tasks toolbox", tc.Name)
+			}
+		case engine.TriggerWebhook:
+			if tc.Secret == "" {
+				return nil, fmt.Errorf("daemon: trigger %q: webhook triggers require a secret", tc.Name)
+			}
+		case engine.TriggerWatch:
+		default:
+			return nil, fmt.Errorf("daemon: trigger %q: unknown kind %q", tc.Name, tc.Kind)
+		}
+
+		d.triggers = append(d.triggers, t)
+	}
+
+	return d, nil
+}
+
+// Run starts all trigger sources and blocks until ctx is canceled, then waits
+// for in-flight runs to finish. It returns an error only if a file watcher or
+// the webhook listener cannot be started, in which case no trigger source is
+// started.
+func (d *Daemon) Run(ctx context.Context) error {
+	webhooks := make(map[string]*trigger)
+	watchers := make(map[*trigger]*watcher)
+	closeWatchers := func() {
+		for _, w := range watchers {
+			_ = w.Close()
+		}
+	}
+	for _, t := range d.triggers {
+		switch t.cfg.Kind {
+		case engine.TriggerWebhook:
+			webhooks[t.cfg.Name] = t
+		case engine.TriggerWatch:
+			w, err := newWatcher(d.projectRoot(), t.cfg.Paths, []string{d.eng.Dir().Root()})
+			if err != nil {
+				closeWatchers()
+				return fmt.Errorf("daemon: trigger %q: watch: %w", t.cfg.Name, err)
+			}
+			watchers[t] = w
+		}
+	}
+	var srv *http.Server
+	if len(webhooks) > 0 {
+		var err error
+		if srv, err = d.serveWebhooks(ctx, webhooks); err != nil {
+			closeWatchers()
+			return err
+		}
+	}
+
+	var (
+		loops   sync.WaitGroup
+		taskTrs []*trigger
+	)
+
+	for _, t := range d.triggers {
+		switch t.cfg.Kind {
+		case engine.TriggerCron:
+			loops.Go(func() { d.runCron(ctx, t) })
+		case engine.TriggerWatch:
+			loops.Go(func() { d.runWatch(ctx, t, watchers[t]) })
+		case engine.TriggerTask:
+			taskTrs = append(taskTrs, t)
+		}
+	}
+
+	if len(taskTrs) > 0 {
+		loops.Go(func() { d.runTasks(ctx, taskTrs) })
+	}
+
+	<-ctx.Done()
+
+	// Stop every event source before waiting on runs so no new run starts
+	// while draining.
+	if srv != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			_ = srv.Close()
+		}
+		cancel()
+	}
+	loops.Wait()
+	d.runs.Wait()
+
+	return nil
+}
+
+// projectRoot returns the directory containing the engine's .shelly dir.
+func (d *Daemon) projectRoot() string {
+	return filepath.Dir(d.eng.Dir().Root())
+}
+
+// fire starts a run for t unless the trigger is at its concurrency limit, in
+// which case the event is dropped. Reports whether a run was started.
+func (d *Daemon) fire(ctx context.Context, t *trigger, ev Event) bool {
+	if d.launch(ctx, t, ev) {
+		return true
+	}
+	if ctx.Err() == nil {
+		d.log.Warn("daemon: trigger at concurrency limit, event dropped", "trigger", t.cfg.Name)
+	}
+	return false
+}
+
+// launch starts a run for t if the trigger is below its concurrency limit and
+// reports whether it did. Callers that keep the event to retry it later use
+// launch directly; fire drops it.
+func (d *Daemon) launch(ctx context.Context, t *trigger, ev Event) bool {
+	ev.Trigger = t.cfg.Name
+	ev.Kind = t.cfg.Kind
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	if ctx.Err() != nil {
+		return false
+	}
+
+	select {
+	case t.sem <- struct{}{}:
+	default:
+		return false
+	}
+
+	d.runs.Go(func() {
+		defer func() { <-t.sem }()
+		d.run(ctx, t, ev)
+	})
+
+	return true
+}
+
+// run renders the prompt, runs it in a new session and reports the result.
+func (d *Daemon) run(ctx context.Context, t *trigger, ev Event) {
+	res := RunResult{Trigger: t.cfg.Name, Agent: t.cfg.Agent, Start: time.Now()}
+	defer func() {
+		res.Elapsed = time.Since(res.Start)
+		d.report(res)
+	}()
+
+	var prompt strings.Builder
+	if err := t.prompt.Execute(&prompt, ev); err != nil {
+		res.Err = fmt.Errorf("daemon: render prompt: %w", err)
+		return
+	}
+
+	sess, err := d.eng.NewSession(t.cfg.Agent)
+	if err != nil {
+		res.Err = err
+		return
+	}
+	defer d.eng.RemoveSession(sess.ID())
+
+	sess.SetTrigger(t.cfg.Name)
+	res.SessionID = sess.PersistID()
+
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	sub := d.eng.Events().Subscribe(eventBuffer)
+	var answering sync.WaitGroup
+	answering.Go(func() {
+		for ev := range sub.C {
+			if ev.SessionID == sess.ID() {
+				deny(sess, ev)
+			}
+		}
+	})
+
+	reply, err := sess.Send(ctx, prompt.String())
+
+	// Unsubscribe closes the channel; buffered events are still drained.
+	d.eng.Events().Unsubscribe(sub)
+	answering.Wait()
+	if err != nil {
+		res.Err = err
+		// Successful sends are auto-saved; persist failures explicitly so
+		// they appear in the run history too.
+		if saveErr := d.eng.SaveSession(sess); saveErr != nil {
+			d.log.Warn("daemon: save failed run", "trigger", t.cfg.Name, "err", saveErr)
+		}
+		return
+	}
+
+	res.Reply = reply.TextContent()
+}
+
+// deny answers the questions and file reviews of a triggered run, which has
+// nobody to answer them: questions get denyAnswer and changes are rejected.
+// Without it, a run that asks would block until its timeout.
+func deny(sess *engine.Session, ev engine.Event) {
+	switch ev.Kind {
+	case engine.EventAskUser:
+		if q, ok := ev.Data.(ask.Question); ok {
+			_ = sess.Respond(q.ID, denyAnswer)
+		}
+	case engine.EventFileReview:
+		if req, ok := ev.Data.(filesystem.ReviewRequest); ok {
+			_ = sess.RespondReview(req.ID, filesystem.RejectAll(req.Change, denyAnswer))
+		}
+	}
+}
+
+// report logs a run result and forwards it to Options.OnRun.
+func (d *Daemon) report(res RunResult) {
+	if res.Err != nil {
+		d.log.Error("daemon: run failed", "trigger", res.Trigger, "agent", res.Agent, "session", res.SessionID, "elapsed", res.Elapsed, "err", res.Err)
+	} else {
+		d.log.Info("daemon: run completed", "trigger", res.Trigger, "agent", res.Agent, "session", res.SessionID, "elapsed", res.Elapsed)
+	}
+
+	if d.opts.OnRun != nil {
+		d.opts.OnRun(res)
+	}
+}
+
+// runCron fires t at each activation of its schedule.
+func (d *Daemon) runCron(ctx context.Context, t *trigger) {
+	for {
+		next := t.schedule.Next(time.Now())
+		if next.IsZero() {
+			d.log.Warn("daemon: schedule never fires", "trigger", t.cfg.Name, "schedule", t.cfg.Schedule)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			d.fire(ctx, t, Event{Time: next})
+		}
+	}
+}
+
+// runWatch fires t once changes reported by w settle: changed paths
+// accumulate until no further change arrives for a poll interval, so a burst
+// of writes yields one run. While the trigger is busy the changes are kept
+// and retried every poll interval.
+func (d *Daemon) runWatch(ctx context.Context, t *trigger, w *watcher) {
+	defer func() { _ = w.Close() }()
+
+	settle := time.NewTimer(d.pollInterval)
+	settle.Stop()
+	defer settle.Stop()
+
+	pending := make(map[string]struct{})
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			d.log.Warn("daemon: file watch", "trigger", t.cfg.Name, "err", err)
+			continue
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			changed := w.changed(ev)
+			for _, p := range changed {
+				pending[p] = struct{}{}
+			}
+			if len(changed) > 0 {
+				settle.Reset(d.pollInterval)
+			}
+			continue
+		case <-settle.C:
+		}
+
+		files := make([]string, 0, len(pending))
+		for p := range pending {
+			files = append(files, p)
+		}
+		slices.Sort(files)
+
+		if d.launch(ctx, t, Event{Files: files}) {
+			clear(pending)
+		} else {
+			settle.Reset(d.pollInterval)
+		}
+	}
+}
+
+// runTasks fires task triggers when a task enters pending with the
+// trigger's assignee. A task fires again only after it has left that state.
+// A task that arrives while its trigger is busy is retried every poll
+// interval until a run starts.
+func (d *Daemon) runTasks(ctx context.Context, ts []*trigger) {
+	store := d.eng.Tasks()
+	matched := make(map[string]map[string]struct{}, len(ts)) // trigger → task IDs currently matching
+	for _, t := range ts {
+		matched[t.cfg.Name] = make(map[string]struct{})
+	}
+
+	pending := tasks.StatusPending
+
+	for {
+		// Grab the change signal before reading so no mutation is missed.
+		changes := store.Changes()
+		busy := false
+
+		for _, t := range ts {
+			assignee := t.cfg.Assignee
+			seen := matched[t.cfg.Name]
+
+			current := make(map[string]struct{})
+			for _, task := range store.List(tasks.Filter{Status: &pending, Assignee: &assignee}) {
+				if _, ok := seen[task.ID]; !ok && !d.launch(ctx, t, Event{Task: &task}) {
+					busy = true
+					continue
+				}
+				current[task.ID] = struct{}{}
+			}
+			matched[t.cfg.Name] = current
+		}
+
+		var retry <-chan time.Time
+		if busy {
+			retry = time.After(d.pollInterval)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-changes:
+		case <-retry:
+		}
+	}
+}
+
+// serveWebhooks starts an HTTP server routing POST /hooks/<name> to the
+// matching webhook trigger. The caller shuts the server down.
+func (d *Daemon) serveWebhooks(ctx context.Context, webhooks map[string]*trigger) (*http.Server, error) {
+	ln := d.opts.Listener
+	if ln == nil {
+		var err error
+		if ln, err = net.Listen("tcp", d.listen); err != nil {
+			return nil, fmt.Errorf("daemon: webhook listener: %w", err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /hooks/{name}", func(w http.ResponseWriter, r *http.Request) {
+		d.handleWebhook(ctx, webhooks, w, r)
+	})
+
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			d.log.Error("daemon: webhook server", "err", err)
+		}
+	}()
+
+	d.log.Info("daemon: webhooks listening", "addr", ln.Addr().String())
+
+	return srv, nil
+}
+
+// handleWebhook authenticates and dispatches a single webhook request. It
+// replies 202 when a run starts and 429 when the trigger is busy.
+func (d *Daemon) handleWebhook(ctx context.Context, webhooks map[string]*trigger, w http.ResponseWriter, r *http.Request) {
+	t, ok := webhooks[r.PathValue("name")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(SecretHeader)), []byte(t.cfg.Secret)) != 1 {
+		http.Error(w, "invalid secret", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody+1))
+	if err != nil {
+		http.Error(w, "read body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxWebhookBody {
+		http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	ev := Event{Body: string(body)}
+	var payload any
+	if json.Unmarshal(body, &payload) == nil {
+		ev.Payload = payload
+	}
+
+	if !d.fire(ctx, t, ev) {
+		http.Error(w, "trigger busy", http.StatusTooManyRequests)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
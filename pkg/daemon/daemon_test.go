@@ -0,0 +1,461 @@
+package daemon
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/engine"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/tasks"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoCompleter replies with the last prompt it received. When release is
+// non-nil every call blocks until it is closed. When started is non-nil every
+// call sends its prompt there first.
+type echoCompleter struct {
+	release chan struct{}
+	started chan string
+}
+
+func (m *echoCompleter) Complete(ctx context.Context, c *chat.Chat, _ []toolbox.Tool) (message.Message, error) {
+	if m.started != nil {
+		last, _ := c.Last()
+		m.started <- last.TextContent()
+	}
+	if m.release != nil {
+		select {
+		case <-m.release:
+		case <-ctx.Done():
+			return message.Message{}, ctx.Err()
+		}
+	}
+
+	last, _ := c.Last()
+	return message.NewText("bot", role.Assistant, "echo: "+last.TextContent()), nil
+}
+
+// recorder collects run results.
+type recorder struct {
+	mu      sync.Mutex
+	results []RunResult
+}
+
+func (r *recorder) onRun(res RunResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, res)
+}
+
+func (r *recorder) snapshot() []RunResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RunResult(nil), r.results...)
+}
+
+func (r *recorder) waitFor(t *testing.T, n int) []RunResult {
+	t.Helper()
+	require.Eventually(t, func() bool { return len(r.snapshot()) >= n }, 5*time.Second, 10*time.Millisecond)
+	return r.snapshot()
+}
+
+// newTestEngine creates an engine with a single "bot" agent backed by
+// completer and a temporary .shelly directory.
+func newTestEngine(t *testing.T, completer modeladapter.Completer, tasksEnabled bool) *engine.Engine {
+	t.Helper()
+
+	engine.RegisterProvider("daemon-mock", func(_ engine.ProviderConfig) (modeladapter.Completer, error) {
+		return completer, nil
+	})
+
+	shellyDir := filepath.Join(t.TempDir(), ".shelly")
+	require.NoError(t, os.MkdirAll(shellyDir, 0o750))
+
+	ac := engine.AgentConfig{Name: "bot", Provider: "p1"}
+	if tasksEnabled {
+		ac.Toolboxes = []engine.ToolboxRef{{Name: "tasks"}}
+	}
+
+	eng, err := engine.New(context.Background(), engine.Config{
+		ShellyDir: shellyDir,
+		Providers: []engine.ProviderConfig{{Name: "p1", Kind: "daemon-mock", Model: "test"}},
+		Agents:    []engine.AgentConfig{ac},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = eng.Close() })
+
+	return eng
+}
+
+// start runs d in the background and returns a stop function that cancels it
+// and waits for Run to return.
+func start(t *testing.T, d *Daemon) func() {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx) }()
+
+	stop := func() {
+		cancel()
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("daemon did not stop")
+		}
+	}
+	t.Cleanup(func() { cancel() })
+
+	return stop
+}
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return ln
+}
+
+func post(t *testing.T, url, secret, body string) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	require.NoError(t, err)
+	if secret != "" {
+		req.Header.Set(SecretHeader, secret)
+	}
+	// Without keep-alives no idle or speculative connection delays the
+	// server's graceful shutdown.
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestNew_Errors(t *testing.T) {
+	eng := newTestEngine(t, &echoCompleter{}, false)
+
+	_, err := New(eng, engine.DaemonConfig{}, []engine.TriggerConfig{
+		{Name: "c", Kind: engine.TriggerCron, Agent: "bot", Prompt: "x", Schedule: "not a schedule"},
+	}, Options{})
+	require.Error(t, err)
+
+	_, err = New(eng, engine.DaemonConfig{}, []engine.TriggerConfig{
+		{Name: "t", Kind: engine.TriggerTask, Agent: "bot", Prompt: "x", Assignee: "bot"},
+	}, Options{})
+	require.ErrorContains(t, err, "tasks toolbox")
+
+	_, err = New(eng, engine.DaemonConfig{}, []engine.TriggerConfig{
+		{Name: "w", Kind: engine.TriggerWebhook, Agent: "bot", Prompt: "x"},
+	}, Options{})
+	require.ErrorContains(t, err, "require a secret")
+
+	_, err = New(eng, engine.DaemonConfig{PollInterval: "soon"}, nil, Options{})
+	require.Error(t, err)
+}
+
+func TestDaemon_Webhook(t *testing.T) {
+	eng := newTestEngine(t, &echoCompleter{}, false)
+	rec := &recorder{}
+	ln := listen(t)
+
+	d, err := New(eng, engine.DaemonConfig{}, []engine.TriggerConfig{{
+		Name:   "deploy",
+		Kind:   engine.TriggerWebhook,
+		Agent:  "bot",
+		Prompt: "deploy {{.Payload.ref}} via {{.Trigger}}",
+		Secret: "s3cret",
+	}}, Options{Listener: ln, OnRun: rec.onRun})
+	require.NoError(t, err)
+	stop := start(t, d)
+
+	base := "http://" + ln.Addr().String() + "/hooks/"
+	assert.Equal(t, http.StatusNotFound, post(t, base+"nope", "s3cret", "{}"))
+	assert.Equal(t, http.StatusUnauthorized, post(t, base+"deploy", "wrong", "{}"))
+	assert.Equal(t, http.StatusAccepted, post(t, base+"deploy", "s3cret", `{"ref":"v1.2"}`))
+
+	res := rec.waitFor(t, 1)
+	stop()
+
+	require.NoError(t, res[0].Err)
+	assert.Equal(t, "deploy", res[0].Trigger)
+	assert.Equal(t, "bot", res[0].Agent)
+	assert.Equal(t, "echo: deploy v1.2 via deploy", res[0].Reply)
+
+	// Run history is persisted and tagged with the trigger.
+	listed, err := eng.SessionStore().List()
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, res[0].SessionID, listed[0].ID)
+	assert.Equal(t, "deploy", listed[0].Trigger)
+}
+
+func TestDaemon_WebhookBusy(t *testing.T) {
+	release := make(chan struct{})
+	eng := newTestEngine(t, &echoCompleter{release: release}, false)
+	rec := &recorder{}
+	ln := listen(t)
+
+	d, err := New(eng, engine.DaemonConfig{}, []engine.TriggerConfig{{
+		Name:   "hook",
+		Kind:   engine.TriggerWebhook,
+		Agent:  "bot",
+		Prompt: "{{.Body}}",
+		Secret: "s3cret",
+	}}, Options{Listener: ln, OnRun: rec.onRun})
+	require.NoError(t, err)
+	stop := start(t, d)
+
+	url := "http://" + ln.Addr().String() + "/hooks/hook"
+	assert.Equal(t, http.StatusAccepted, post(t, url, "s3cret", "first"))
+	assert.Equal(t, http.StatusTooManyRequests, post(t, url, "s3cret", "second"))
+
+	close(release)
+	res := rec.waitFor(t, 1)
+	stop()
+
+	require.Len(t, res, 1)
+	assert.Equal(t, "echo: first", res[0].Reply)
+}
+
+func TestDaemon_Timeout(t *testing.T) {
+	eng := newTestEngine(t, &echoCompleter{release: make(chan struct{})}, false)
+	rec := &recorder{}
+	ln := listen(t)
+
+	d, err := New(eng, engine.DaemonConfig{}, []engine.TriggerConfig{{
+		Name:    "slow",
+		Kind:    engine.TriggerWebhook,
+		Agent:   "bot",
+		Prompt:  "go",
+		Timeout: "50ms",
+		Secret:  "s3cret",
+	}}, Options{Listener: ln, OnRun: rec.onRun})
+	require.NoError(t, err)
+	stop := start(t, d)
+
+	assert.Equal(t, http.StatusAccepted, post(t, "http://"+ln.Addr().String()+"/hooks/slow", "s3cret", ""))
+	res := rec.waitFor(t, 1)
+	stop()
+
+	require.ErrorIs(t, res[0].Err, context.DeadlineExceeded)
+
+	// Failed runs are persisted too.
+	listed, err := eng.SessionStore().List()
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, "slow", listed[0].Trigger)
+}
+
+// askingCompleter asks the user a question, then replies with the answer.
+type askingCompleter struct{}
+
+func (askingCompleter) Complete(_ context.Context, c *chat.Chat, _ []toolbox.Tool) (message.Message, error) {
+	if last, _ := c.Last(); last.Role == role.Tool {
+		return message.NewText("bot", role.Assistant, "answer: "+last.Parts[0].(content.ToolResult).Content), nil
+	}
+	return message.New("bot", role.Assistant,
+		content.ToolCall{ID: "c1", Name: "ask_user", Arguments: `{"question":"Which branch?","options":["main","dev"]}`},
+	), nil
+}
+
+func TestDaemon_DeniesQuestions(t *testing.T) {
+	eng := newTestEngine(t, askingCompleter{}, false)
+	rec := &recorder{}
+	ln := listen(t)
+
+	d, err := New(eng, engine.DaemonConfig{}, []engine.TriggerConfig{{
+		Name:   "asker",
+		Kind:   engine.TriggerWebhook,
+		Agent:  "bot",
+		Prompt: "go",
+		Secret: "s3cret",
+	}}, Options{Listener: ln, OnRun: rec.onRun})
+	require.NoError(t, err)
+	stop := start(t, d)
+
+	assert.Equal(t, http.StatusAccepted, post(t, "http://"+ln.Addr().String()+"/hooks/asker", "s3cret", ""))
+	res := rec.waitFor(t, 1)
+	stop()
+
+	require.NoError(t, res[0].Err)
+	assert.Contains(t, res[0].Reply, "No user is available to answer")
+}
+
+func TestDaemon_Task(t *testing.T) {
+	eng := newTestEngine(t, &echoCompleter{}, true)
+	rec := &recorder{}
+
+	d, err := New(eng, engine.DaemonConfig{}, []engine.TriggerConfig{{
+		Name:     "triage",
+		Kind:     engine.TriggerTask,
+		Agent:    "bot",
+		Prompt:   "work on {{.Task.Title}}",
+		Assignee: "bot",
+	}}, Options{OnRun: rec.onRun})
+	require.NoError(t, err)
+	stop := start(t, d)
+
+	store := eng.Tasks()
+	id, err := store.Create(tasks.Task{Title: "fix bug"})
+	require.NoError(t, err)
+
+	// Unassigned pending tasks do not fire.
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, rec.snapshot())
+
+	require.NoError(t, store.Reassign(id, "bot"))
+	pending := tasks.StatusPending
+	require.NoError(t, store.Update(id, tasks.Update{Status: &pending}))
+
+	res := rec.waitFor(t, 1)
+
+	// Further updates while the task stays pending do not fire again.
+	desc := "more detail"
+	require.NoError(t, store.Update(id, tasks.Update{Description: &desc}))
+	time.Sleep(50 * time.Millisecond)
+	stop()
+
+	require.Len(t, rec.snapshot(), 1)
+	require.NoError(t, res[0].Err)
+	assert.Equal(t, "echo: work on fix bug", res[0].Reply)
+}
+
+func TestDaemon_TaskBusyRetried(t *testing.T) {
+	completer := &echoCompleter{release: make(chan struct{}), started: make(chan string, 4)}
+	eng := newTestEngine(t, completer, true)
+	rec := &recorder{}
+
+	d, err := New(eng, engine.DaemonConfig{PollInterval: "20ms"}, []engine.TriggerConfig{{
+		Name:     "triage",
+		Kind:     engine.TriggerTask,
+		Agent:    "bot",
+		Prompt:   "work on {{.Task.Title}}",
+		Assignee: "bot",
+	}}, Options{OnRun: rec.onRun})
+	require.NoError(t, err)
+	stop := start(t, d)
+
+	store := eng.Tasks()
+	assign := func(title string) {
+		id, err := store.Create(tasks.Task{Title: title})
+		require.NoError(t, err)
+		require.NoError(t, store.Reassign(id, "bot"))
+		pending := tasks.StatusPending
+		require.NoError(t, store.Update(id, tasks.Update{Status: &pending}))
+	}
+
+	assign("first")
+	assert.Equal(t, "work on first", <-completer.started)
+
+	// The trigger is busy: the second task waits instead of being dropped,
+	// and no further board change is needed for it to fire.
+	assign("second")
+	time.Sleep(50 * time.Millisecond)
+	close(completer.release)
+
+	res := rec.waitFor(t, 2)
+	stop()
+
+	assert.Equal(t, "echo: work on first", res[0].Reply)
+	assert.Equal(t, "echo: work on second", res[1].Reply)
+}
+
+func TestDaemon_Watch(t *testing.T) {
+	eng := newTestEngine(t, &echoCompleter{}, false)
+	rec := &recorder{}
+	root := filepath.Dir(eng.Dir().Root())
+
+	d, err := New(eng, engine.DaemonConfig{PollInterval: "20ms"}, []engine.TriggerConfig{{
+		Name:   "docs",
+		Kind:   engine.TriggerWatch,
+		Agent:  "bot",
+		Prompt: "changed: {{range .Files}}{{.}} {{end}}",
+		Paths:  []string{"docs/**/*.md"},
+	}}, Options{OnRun: rec.onRun})
+	require.NoError(t, err)
+	stop := start(t, d)
+
+	// Let the watcher record its baseline before writing.
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "docs", "api"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "docs", "api", "a.md"), []byte("a"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "docs", "b.md"), []byte("b"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "docs", "c.txt"), []byte("c"), 0o600))
+
+	res := rec.waitFor(t, 1)
+	stop()
+
+	require.NoError(t, res[0].Err)
+	assert.Contains(t, res[0].Reply, "docs/api/a.md")
+	assert.Contains(t, res[0].Reply, "docs/b.md")
+	assert.NotContains(t, res[0].Reply, "c.txt")
+}
+
+func TestDaemon_WatchBusyRetried(t *testing.T) {
+	completer := &echoCompleter{release: make(chan struct{}), started: make(chan string, 4)}
+	eng := newTestEngine(t, completer, false)
+	rec := &recorder{}
+	root := filepath.Dir(eng.Dir().Root())
+
+	d, err := New(eng, engine.DaemonConfig{PollInterval: "20ms"}, []engine.TriggerConfig{{
+		Name:   "docs",
+		Kind:   engine.TriggerWatch,
+		Agent:  "bot",
+		Prompt: "changed: {{range .Files}}{{.}} {{end}}",
+		Paths:  []string{"*.md"},
+	}}, Options{OnRun: rec.onRun})
+	require.NoError(t, err)
+	stop := start(t, d)
+
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.md"), []byte("a"), 0o600))
+	assert.Equal(t, "changed: a.md ", <-completer.started)
+
+	// Changes seen while the trigger is busy fire once it is free again.
+	require.NoError(t, os.WriteFile(filepath.Join(root, "b.md"), []byte("b"), 0o600))
+	time.Sleep(100 * time.Millisecond)
+	close(completer.release)
+
+	res := rec.waitFor(t, 2)
+	stop()
+
+	assert.Equal(t, "echo: changed: a.md ", res[0].Reply)
+	assert.Equal(t, "echo: changed: b.md ", res[1].Reply)
+}
+
+func TestDaemon_Cron(t *testing.T) {
+	eng := newTestEngine(t, &echoCompleter{}, false)
+	rec := &recorder{}
+
+	d, err := New(eng, engine.DaemonConfig{}, []engine.TriggerConfig{{
+		Name:     "tick",
+		Kind:     engine.TriggerCron,
+		Agent:    "bot",
+		Prompt:   "tick at {{.Time.Year}}",
+		Schedule: "@every 1s",
+	}}, Options{OnRun: rec.onRun})
+	require.NoError(t, err)
+	stop := start(t, d)
+
+	res := rec.waitFor(t, 1)
+	stop()
+
+	require.NoError(t, res[0].Err)
+	assert.Equal(t, "tick", res[0].Trigger)
+	assert.True(t, strings.HasPrefix(res[0].Reply, "echo: tick at 20"))
+}
@@ -0,0 +1,194 @@
+package daemon
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watcher detects changes to files matching a set of globs through OS file
+// notifications. Globs are slash-separated, relative to root, and support
+// "**" for any number of path segments.
+//
+// Notifications are not recursive, so every directory that can hold a match
+// is watched: those below the static base of a glob, and the ones leading
+// down to it so that a base created later is picked up. Directories created
+// while watching are added as they appear.
+type watcher struct {
+	root  string
+	globs []string
+	bases []string // absolute static base of each glob
+	skip  []string // absolute directories never descended into
+	fsw   *fsnotify.Watcher
+}
+
+// newWatcher creates a watcher and starts watching the directories that can
+// hold matches. Files that exist already are not reported.
+func newWatcher(root string, globs, skip []string) (*watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &watcher{root: root, globs: globs, skip: skip, fsw: fsw}
+	for _, g := range globs {
+		w.bases = append(w.bases, filepath.Join(root, filepath.FromSlash(globBase(g))))
+	}
+	if err := w.fsw.Add(root); err != nil {
+		_ = fsw.Close()
+		return nil, err
+	}
+	w.add(root)
+
+	return w, nil
+}
+
+// Close stops watching.
+func (w *watcher) Close() error { return w.fsw.Close() }
+
+// changed returns the sorted relative paths of matching files affected by
+// ev. A new directory is watched, and the matching files already in it are
+// reported since they may have been written before the watch was in place.
+func (w *watcher) changed(ev fsnotify.Event) []string {
+	if ev.Op == fsnotify.Chmod {
+		return nil
+	}
+
+	if ev.Has(fsnotify.Create) {
+		if info, err := os.Lstat(ev.Name); err == nil && info.IsDir() {
+			if !w.wanted(ev.Name) {
+				return nil
+			}
+			_ = w.fsw.Add(ev.Name)
+			return w.add(ev.Name)
+		}
+	}
+
+	if rel, ok := w.match(ev.Name); ok {
+		return []string{rel}
+	}
+	return nil
+}
+
+// add watches the wanted directories below dir (dir itself is watched by the
+// caller) and returns the sorted relative paths of the matching files found.
+func (w *watcher) add(dir string) []string {
+	var found []string
+
+	_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p == dir {
+				return nil
+			}
+			if !w.wanted(p) {
+				return filepath.SkipDir
+			}
+			_ = w.fsw.Add(p)
+			return nil
+		}
+		if rel, ok := w.match(p); ok {
+			found = append(found, rel)
+		}
+		return nil
+	})
+
+	slices.Sort(found)
+	return found
+}
+
+// wanted reports whether the directory p can hold a match or lead to one:
+// it is not skipped and is below or above the base of some glob.
+func (w *watcher) wanted(p string) bool {
+	if filepath.Base(p) == ".git" || slices.Contains(w.skip, p) {
+		return false
+	}
+	for _, b := range w.bases {
+		if within(p, b) || within(b, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the path of p relative to the root when it matches a glob.
+func (w *watcher) match(p string) (string, bool) {
+	rel, err := filepath.Rel(w.root, p)
+	if err != nil {
+		return "", false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, s := range w.skip {
+		if within(p, s) {
+			return "", false
+		}
+	}
+	for _, g := range w.globs {
+		if matchGlob(g, rel) {
+			return rel, true
+		}
+	}
+	return "", false
+}
+
+// within reports whether p is dir or lies below it.
+func within(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, dir+string(filepath.Separator))
+}
+
+// globBase returns the leading directory segments of glob that contain no
+// wildcard characters ("." when the first segment is a pattern).
+func globBase(glob string) string {
+	segs := strings.Split(glob, "/")
+
+	var base []string
+	for _, s := range segs[:len(segs)-1] {
+		if strings.ContainsAny(s, "*?[") {
+			break
+		}
+		base = append(base, s)
+	}
+
+	if len(base) == 0 {
+		return "."
+	}
+	return strings.Join(base, "/")
+}
+
+// matchGlob reports whether the slash-separated relative path name matches
+// glob. "**" matches zero or more whole path segments; other segments follow
+// path.Match.
+func matchGlob(glob, name string) bool {
+	return matchSegments(strings.Split(glob, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
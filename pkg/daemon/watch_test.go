@@ -0,0 +1,121 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		glob, name string
+		want       bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "pkg/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "pkg/a/main.go", true},
+		{"pkg/**", "pkg/a/b.txt", true},
+		{"pkg/**/*_test.go", "pkg/x_test.go", true},
+		{"pkg/**/*_test.go", "pkg/a/b/x_test.go", true},
+		{"pkg/**/*_test.go", "cmd/x_test.go", false},
+		{"docs/*.md", "docs/a/b.md", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchGlob(tt.glob, tt.name), "%s vs %s", tt.glob, tt.name)
+	}
+}
+
+func TestGlobBase(t *testing.T) {
+	assert.Equal(t, ".", globBase("*.go"))
+	assert.Equal(t, ".", globBase("**/*.go"))
+	assert.Equal(t, "pkg/api", globBase("pkg/api/**/*.go"))
+	assert.Equal(t, "docs", globBase("docs/*.md"))
+}
+
+// drain collects the changes w reports until none arrive for a while.
+func drain(t *testing.T, w *watcher) []string {
+	t.Helper()
+
+	set := make(map[string]struct{})
+	for {
+		select {
+		case ev := <-w.fsw.Events:
+			for _, p := range w.changed(ev) {
+				set[p] = struct{}{}
+			}
+		case err := <-w.fsw.Errors:
+			require.NoError(t, err)
+		case <-time.After(200 * time.Millisecond):
+			out := make([]string, 0, len(set))
+			for p := range set {
+				out = append(out, p)
+			}
+			slices.Sort(out)
+			return out
+		}
+	}
+}
+
+func TestWatcher_Changes(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, body string) {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+		require.NoError(t, os.WriteFile(path, []byte(body), 0o600))
+	}
+
+	write("pkg/a.go", "package pkg")
+	write("pkg/notes.txt", "ignored")
+	write(".shelly/x.go", "skipped dir")
+
+	w, err := newWatcher(root, []string{"**/*.go"}, []string{filepath.Join(root, ".shelly")})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = w.Close() })
+	assert.Empty(t, drain(t, w), "existing files are the baseline")
+
+	write("pkg/b.go", "package pkg")
+	write("pkg/notes.txt", "still ignored")
+	write(".shelly/y.go", "still skipped")
+	assert.Equal(t, []string{"pkg/b.go"}, drain(t, w))
+
+	// Modification and removal.
+	write("pkg/a.go", "package pkg // edited")
+	require.NoError(t, os.Remove(filepath.Join(root, "pkg", "b.go")))
+	assert.Equal(t, []string{"pkg/a.go", "pkg/b.go"}, drain(t, w))
+
+	// New directories are watched, including files written into them
+	// before the watch was added.
+	write("cmd/tool/main.go", "package main")
+	write("cmd/tool/sub/x.go", "package sub")
+	assert.Equal(t, []string{"cmd/tool/main.go", "cmd/tool/sub/x.go"}, drain(t, w))
+	write("cmd/tool/sub/x.go", "package sub // edited")
+	assert.Equal(t, []string{"cmd/tool/sub/x.go"}, drain(t, w))
+
+	// Metadata-only changes are ignored.
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(root, "pkg", "a.go"), future, future))
+	assert.Empty(t, drain(t, w))
+}
+
+func TestWatcher_BaseCreatedLater(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "vendor", "big"), 0o750))
+
+	w, err := newWatcher(root, []string{"docs/api/*.md"}, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = w.Close() })
+
+	assert.NotContains(t, w.fsw.WatchList(), filepath.Join(root, "vendor"), "directories off the glob base are not watched")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "docs", "api"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "docs", "api", "a.md"), []byte("a"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "docs", "b.md"), []byte("b"), 0o600))
+	assert.Equal(t, []string{"docs/api/a.md"}, drain(t, w))
+}
@@ -0,0 +1,237 @@
+package engine
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/modeladapter/batch"
+)
+
+// Batch task states recorded in the run manifest.
+const (
+	batchStatusRunning   = "running"
+	batchStatusCompleted = "completed"
+	batchStatusError     = "error"
+)
+
+// Manifest record kinds.
+const (
+	manifestTask           = "task"
+	manifestBatchSubmitted = "batch_submitted"
+	manifestBatchFinished  = "batch_finished"
+)
+
+// manifestRecord is one JSONL line of a batch run manifest.
+type manifestRecord struct {
+	Time     time.Time       `json:"time"`
+	Kind     string          `json:"kind"`
+	Task     string          `json:"task,omitempty"`
+	Status   string          `json:"status,omitempty"`
+	Attempt  int             `json:"attempt,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Provider string          `json:"provider,omitempty"`
+	Batch    *batch.InFlight `json:"batch,omitempty"`
+	BatchID  string          `json:"batch_id,omitempty"`
+}
+
+// batchTaskState is the replayed state of a single task.
+type batchTaskState struct {
+	Status   string
+	Attempts int
+}
+
+// batchManifest is an append-only JSONL log of a batch run. It records task
+// state transitions and the provider batches that are still in flight, so
+// that an interrupted run can be resumed. Replaying the log rebuilds the
+// latest state; a torn trailing line from a crash is ignored.
+type batchManifest struct {
+	path string
+
+	mu      sync.Mutex
+	f       *os.File
+	tasks   map[string]batchTaskState
+	batches map[string]map[string]batch.InFlight // provider → batch ID → in-flight batch
+}
+
+// batchRunID derives a stable run identifier from the tasks and output paths,
+// so that resuming the same invocation finds the same manifest.
+func batchRunID(tasksPath, outputPath string) string {
+	if abs, err := filepath.Abs(tasksPath); err == nil {
+		tasksPath = abs
+	}
+	if abs, err := filepath.Abs(outputPath); err == nil {
+		outputPath = abs
+	}
+	sum := sha256.Sum256([]byte(tasksPath + "\x00" + outputPath))
+	return hex.EncodeToString(sum[:6])
+}
+
+// openBatchManifest opens the manifest at path. When resume is true the
+// existing log is replayed and must exist; otherwise any previous log is
+// discarded.
+func openBatchManifest(path string, resume bool) (*batchManifest, error) {
+	m := &batchManifest{
+		path:    path,
+		tasks:   make(map[string]batchTaskState),
+		batches: make(map[string]map[string]batch.InFlight),
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if resume {
+		valid, err := m.replay()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("no run manifest to resume at %s", path)
+			}
+			return nil, err
+		}
+		// Drop a torn trailing line so new records start on a fresh line.
+		if err := os.Truncate(path, valid); err != nil {
+			return nil, err
+		}
+	} else {
+		flags |= os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(path, flags, 0o600) //nolint:gosec // path built from shelly dir
+	if err != nil {
+		return nil, err
+	}
+	m.f = f
+	return m, nil
+}
+
+// replay rebuilds the state from the log on disk and returns the length of
+// its complete lines.
+func (m *batchManifest) replay() (int64, error) {
+	return readJSONLines(m.path, func(line []byte) {
+		var rec manifestRecord
+		if json.Unmarshal(line, &rec) == nil {
+			m.apply(rec)
+		}
+	})
+}
+
+// apply folds a record into the in-memory state. Must be called with mu held
+// (or before the manifest is shared).
+func (m *batchManifest) apply(rec manifestRecord) {
+	switch rec.Kind {
+	case manifestTask:
+		m.tasks[rec.Task] = batchTaskState{Status: rec.Status, Attempts: rec.Attempt}
+	case manifestBatchSubmitted:
+		if rec.Batch == nil {
+			return
+		}
+		if m.batches[rec.Provider] == nil {
+			m.batches[rec.Provider] = make(map[string]batch.InFlight)
+		}
+		m.batches[rec.Provider][rec.Batch.BatchID] = *rec.Batch
+	case manifestBatchFinished:
+		delete(m.batches[rec.Provider], rec.BatchID)
+	}
+}
+
+// append applies rec and writes it to the log.
+func (m *batchManifest) append(rec manifestRecord) error {
+	rec.Time = time.Now().UTC()
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.apply(rec)
+	_, err = m.f.Write(append(line, '\n'))
+	return err
+}
+
+// setTask records a task state transition.
+func (m *batchManifest) setTask(id, status string, attempt int, errText string) error {
+	return m.append(manifestRecord{Kind: manifestTask, Task: id, Status: status, Attempt: attempt, Error: errText})
+}
+
+// task returns the latest recorded state of a task.
+func (m *batchManifest) task(id string) batchTaskState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tasks[id]
+}
+
+// inFlight returns the provider batches that have not finished yet.
+func (m *batchManifest) inFlight(provider string) []batch.InFlight {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]batch.InFlight, 0, len(m.batches[provider]))
+	for _, b := range m.batches[provider] {
+		out = append(out, b)
+	}
+	return out
+}
+
+// journal returns a batch.Journal that records the given provider's batches.
+func (m *batchManifest) journal(provider string) batch.Journal {
+	return manifestJournal{m: m, provider: provider}
+}
+
+// Close closes the manifest file.
+func (m *batchManifest) Close() error {
+	return m.f.Close()
+}
+
+// readJSONLines calls fn for every newline-terminated line of the file at
+// path and returns the byte length of those lines. A trailing line without a
+// newline is a torn write from an interrupted run and is not reported.
+func readJSONLines(path string, fn func(line []byte)) (int64, error) {
+	f, err := os.Open(path) //nolint:gosec // path from CLI flag or shelly dir
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var valid int64
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return valid, nil
+		}
+		if err != nil {
+			return valid, err
+		}
+		valid += int64(len(line))
+		fn(line)
+	}
+}
+
+// manifestJournal adapts a batchManifest to batch.Journal for one provider.
+// Write errors are dropped: losing a journal entry only costs a re-submission
+// on resume.
+type manifestJournal struct {
+	m        *batchManifest
+	provider string
+}
+
+func (j manifestJournal) BatchSubmitted(b batch.InFlight) {
+	_ = j.m.append(manifestRecord{Kind: manifestBatchSubmitted, Provider: j.provider, Batch: &b})
+}
+
+func (j manifestJournal) BatchFinished(batchID string) {
+	_ = j.m.append(manifestRecord{Kind: manifestBatchFinished, Provider: j.provider, BatchID: batchID})
+}
@@ -4,36 +4,101 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/germanamz/shelly/pkg/budget"
 	"github.com/germanamz/shelly/pkg/chats/content"
 	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/batch"
 )
 
 // DefaultBatchConcurrency is the default number of tasks to run in parallel.
 const DefaultBatchConcurrency = 8
 
-// BatchTask describes a single task in a batch run's input JSONL.
+// Default retry policy for batch tasks.
+const (
+	DefaultBatchMaxAttempts = 3
+	DefaultBatchRetryDelay  = 5 * time.Second
+	maxBatchRetryDelay      = 5 * time.Minute
+)
+
+// BatchTask describes a single task in a batch run's input JSONL. When
+// Workflow is set the named workflow runs instead of a single agent, with
+// Task (and Context) as its input.
 type BatchTask struct {
-	ID      string `json:"id"`
-	Agent   string `json:"agent"`
-	Task    string `json:"task"`
-	Context string `json:"context,omitempty"`
+	ID       string `json:"id"`
+	Agent    string `json:"agent"`
+	Workflow string `json:"workflow,omitempty"`
+	Task     string `json:"task"`
+	Context  string `json:"context,omitempty"`
 }
 
 // BatchResult is written as one JSONL line per completed task.
 type BatchResult struct {
-	ID      string `json:"id"`
-	Agent   string `json:"agent"`
-	Status  string `json:"status"` // "completed" or "error"
-	Reply   string `json:"reply,omitempty"`
-	Error   string `json:"error,omitempty"`
-	Elapsed string `json:"elapsed"`
+	ID       string               `json:"id"`
+	Agent    string               `json:"agent,omitempty"`
+	Workflow string               `json:"workflow,omitempty"`
+	Status   string               `json:"status"` // "completed" or "error"
+	Reply    string               `json:"reply,omitempty"`
+	Error    string               `json:"error,omitempty"`
+	Steps    []WorkflowStepResult `json:"steps,omitempty"` // Workflow tasks only.
+	Attempts int                  `json:"attempts,omitempty"`
+	Elapsed  string               `json:"elapsed"`
+}
+
+// BatchOptions configures RunBatch. The zero value runs a fresh batch with
+// the default concurrency and retry policy.
+type BatchOptions struct {
+	// Resume continues the run previously started with the same tasks and
+	// output paths: completed tasks are skipped, results are appended to the
+	// output, and in-flight provider batches are re-attached.
+	Resume bool
+	// MaxAttempts is the number of times a failing task is run before its
+	// error is reported (default DefaultBatchMaxAttempts).
+	MaxAttempts int
+	// RetryDelay is the backoff before the first retry; it doubles on every
+	// further attempt (default DefaultBatchRetryDelay).
+	RetryDelay time.Duration
+	// Concurrency is the number of tasks run in parallel
+	// (default DefaultBatchConcurrency).
+	Concurrency int
+}
+
+func (o BatchOptions) withDefaults() BatchOptions {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = DefaultBatchMaxAttempts
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = DefaultBatchRetryDelay
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = DefaultBatchConcurrency
+	}
+	return o
+}
+
+// BatchSummary reports the outcome of a RunBatch call.
+type BatchSummary struct {
+	RunID      string        // Identifier of the run manifest.
+	Manifest   string        // Path of the run manifest.
+	Total      int           // Tasks in the input file.
+	Skipped    int           // Tasks already completed by a previous run.
+	Completed  int           // Tasks completed by this run.
+	Failed     int           // Tasks that exhausted their attempts.
+	Retried    int           // Tasks that needed more than one attempt.
+	Pending    int           // Tasks left unfinished because the run was interrupted.
+	Reattached int           // Provider batches handed back to their collectors.
+	Elapsed    time.Duration // Wall time of this run.
+	Failures   []BatchResult // Results of the failed tasks.
 }
 
 // RunBatch reads tasks from tasksPath, runs them through the engine with bounded
@@ -43,23 +108,57 @@ type BatchResult struct {
 // Running tasks concurrently allows the batch Collector to accumulate multiple
 // Complete() calls from independent agent trees into a single batch submission,
 // maximizing cost savings from provider batch APIs.
-func RunBatch(ctx context.Context, eng *Engine, tasksPath, outputPath string) error {
+//
+// Progress is recorded in a run manifest under .shelly/local/batches/: task
+// states and the provider batches still in flight. With opts.Resume a later
+// call picks the run up where it stopped. Failing tasks are retried with
+// exponential backoff; tasks interrupted by ctx stay pending for a resume.
+// The summary is also published as EventBatchCompleted.
+func RunBatch(ctx context.Context, eng *Engine, tasksPath, outputPath string, opts BatchOptions) (*BatchSummary, error) {
+	start := time.Now()
+	opts = opts.withDefaults()
+
 	tasks, err := readBatchTasks(tasksPath)
 	if err != nil {
-		return fmt.Errorf("batch: read tasks: %w", err)
+		return nil, fmt.Errorf("batch: read tasks: %w", err)
 	}
 
 	if len(tasks) == 0 {
-		return fmt.Errorf("batch: no tasks found in %s", tasksPath)
+		return nil, fmt.Errorf("batch: no tasks found in %s", tasksPath)
+	}
+
+	runID := batchRunID(tasksPath, outputPath)
+	summary := &BatchSummary{
+		RunID:    runID,
+		Manifest: filepath.Join(eng.dir.BatchesDir(), runID+".jsonl"),
+		Total:    len(tasks),
+	}
+
+	manifest, err := openBatchManifest(summary.Manifest, opts.Resume)
+	if err != nil {
+		return nil, fmt.Errorf("batch: manifest: %w", err)
 	}
+	defer func() { _ = manifest.Close() }()
 
-	out, err := os.Create(outputPath) //nolint:gosec // output path from CLI flag
+	out, done, err := openBatchOutput(outputPath, opts.Resume)
 	if err != nil {
-		return fmt.Errorf("batch: create output: %w", err)
+		return nil, fmt.Errorf("batch: open output: %w", err)
 	}
 	defer func() { _ = out.Close() }()
 
-	concurrency := min(DefaultBatchConcurrency, len(tasks))
+	summary.Reattached = eng.attachBatchJournals(manifest)
+	defer eng.attachBatchJournals(nil)
+
+	var todo []BatchTask
+	for _, t := range tasks {
+		if done[t.ID] || manifest.task(t.ID).Status == batchStatusCompleted {
+			summary.Skipped++
+			continue
+		}
+		todo = append(todo, t)
+	}
+
+	concurrency := min(opts.Concurrency, max(len(todo), 1))
 
 	resultCh := make(chan BatchResult, concurrency)
 
@@ -70,14 +169,17 @@ func RunBatch(ctx context.Context, eng *Engine, tasksPath, outputPath string) er
 	for range concurrency {
 		wg.Go(func() {
 			for t := range taskCh {
-				resultCh <- runSingleTask(ctx, eng, t)
+				res, ok := runBatchTask(ctx, eng, manifest, t, opts)
+				if ok {
+					resultCh <- res
+				}
 			}
 		})
 	}
 
 	// Send tasks to the worker pool.
 	go func() {
-		for _, t := range tasks {
+		for _, t := range todo {
 			if ctx.Err() != nil {
 				break
 			}
@@ -89,17 +191,69 @@ func RunBatch(ctx context.Context, eng *Engine, tasksPath, outputPath string) er
 	}()
 
 	enc := json.NewEncoder(out)
+	var writeErr error
 	for res := range resultCh {
+		if writeErr != nil {
+			continue // drain so workers can exit
+		}
+		// Write the result before marking the task final so that a crash in
+		// between re-runs the task rather than losing its result.
 		if err := enc.Encode(res); err != nil {
-			return fmt.Errorf("batch: write result: %w", err)
+			writeErr = fmt.Errorf("batch: write result: %w", err)
+			continue
+		}
+		if err := manifest.setTask(res.ID, res.Status, res.Attempts, res.Error); err != nil {
+			writeErr = fmt.Errorf("batch: manifest: %w", err)
+			continue
+		}
+
+		if res.Attempts > 1 {
+			summary.Retried++
+		}
+		if res.Status == batchStatusCompleted {
+			summary.Completed++
+		} else {
+			summary.Failed++
+			summary.Failures = append(summary.Failures, res)
 		}
 	}
 
-	return nil
+	summary.Pending = summary.Total - summary.Skipped - summary.Completed - summary.Failed
+	summary.Elapsed = time.Since(start)
+	eng.events.publish(EventBatchCompleted, "", "", summary)
+	return summary, writeErr
+}
+
+// runBatchTask runs a task until it completes, fails permanently, or runs
+// out of attempts. ok is false when ctx was cancelled before a final result,
+// leaving the task pending in the manifest.
+func runBatchTask(ctx context.Context, eng *Engine, m *batchManifest, task BatchTask, opts BatchOptions) (BatchResult, bool) {
+	delay := opts.RetryDelay
+
+	for attempt := 1; ; attempt++ {
+		_ = m.setTask(task.ID, batchStatusRunning, attempt, "")
+
+		res, retryable := runSingleTask(ctx, eng, task)
+		res.Attempts = attempt
+
+		if ctx.Err() != nil {
+			return res, false
+		}
+		if res.Status == batchStatusCompleted || !retryable || attempt >= opts.MaxAttempts {
+			return res, true
+		}
+
+		_ = m.setTask(task.ID, batchStatusError, attempt, res.Error)
+		if modeladapter.ContextSleep(ctx, delay) != nil {
+			return res, false
+		}
+		delay = min(delay*2, maxBatchRetryDelay)
+	}
 }
 
 // runSingleTask creates a session, sends the task, and returns the result.
-func runSingleTask(ctx context.Context, eng *Engine, task BatchTask) BatchResult {
+// retryable reports whether a failed task may succeed on another attempt.
+func runSingleTask(ctx context.Context, eng *Engine, task BatchTask) (res BatchResult, retryable bool) {
 	start := time.Now()
 
 	agentName := task.Agent
@@ -112,10 +266,10 @@ func runSingleTask(ctx context.Context, eng *Engine, task BatchTask) BatchResult
 		return BatchResult{
 			ID:      task.ID,
 			Agent:   agentName,
-			Status:  "error",
+			Status:  batchStatusError,
 			Error:   err.Error(),
 			Elapsed: time.Since(start).String(),
-		}
+		}, false
 	}
 	defer eng.RemoveSession(sess.ID())
 
@@ -125,24 +279,110 @@ func runSingleTask(ctx context.Context, eng *Engine, task BatchTask) BatchResult
 		userText = task.Context + "\n\n" + task.Task
 	}
 
+	if task.Workflow != "" {
+		return runWorkflowTask(ctx, sess, task, userText, start)
+	}
+
 	reply, err := sess.SendParts(ctx, content.Text{Text: userText})
 	if err != nil {
 		return BatchResult{
 			ID:      task.ID,
 			Agent:   agentName,
-			Status:  "error",
+			Status:  batchStatusError,
 			Error:   err.Error(),
 			Elapsed: time.Since(start).String(),
-		}
+		}, !errors.Is(err, budget.ErrExceeded) && !errors.Is(err, ErrBudgetStopped)
 	}
 
 	return BatchResult{
 		ID:      task.ID,
 		Agent:   agentName,
-		Status:  "completed",
+		Status:  batchStatusCompleted,
 		Reply:   replyText(reply),
 		Elapsed: time.Since(start).String(),
+	}, false
+}
+
+// runWorkflowTask runs a workflow batch task. A workflow whose steps failed
+// is reported as an error but not retried: its failures come from the agents,
+// not from transient provider errors.
+func runWorkflowTask(ctx context.Context, sess *Session, task BatchTask, input string, start time.Time) (BatchResult, bool) {
+	res := BatchResult{ID: task.ID, Workflow: task.Workflow, Status: batchStatusCompleted}
+
+	wr, err := sess.RunWorkflow(ctx, task.Workflow, input)
+	if wr != nil {
+		res.Reply = wr.Report()
+		res.Steps = wr.Steps
+		if wr.Status == WorkflowFailed {
+			res.Status = batchStatusError
+			res.Error = fmt.Sprintf("workflow %s failed", task.Workflow)
+		}
+	}
+	if err != nil {
+		res.Status = batchStatusError
+		res.Error = err.Error()
 	}
+	res.Elapsed = time.Since(start).String()
+	return res, false
+}
+
+// openBatchOutput opens the results file. A fresh run truncates it; a resumed
+// run appends to it and returns the IDs it already reports as completed.
+func openBatchOutput(path string, resume bool) (*os.File, map[string]bool, error) {
+	if !resume {
+		f, err := os.Create(path) //nolint:gosec // output path from CLI flag
+		return f, nil, err
+	}
+
+	done := make(map[string]bool)
+	valid, err := readJSONLines(path, func(line []byte) {
+		var res BatchResult
+		if json.Unmarshal(line, &res) == nil && res.Status == batchStatusCompleted {
+			done[res.ID] = true
+		}
+	})
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, nil, err
+	default:
+		// Drop a torn trailing line so appended results start on a fresh line.
+		if err := os.Truncate(path, valid); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) //nolint:gosec // output path from CLI flag
+	return f, done, err
+}
+
+// attachBatchJournals points every provider's batch Collector at the run
+// manifest and hands it the manifest's in-flight batches. It returns the
+// number of batches handed over. Passing nil detaches the journals.
+func (e *Engine) attachBatchJournals(m *batchManifest) int {
+	n := 0
+	for name, c := range e.completers {
+		col := batchCollector(c)
+		if col == nil {
+			continue
+		}
+		if m == nil {
+			col.SetJournal(nil)
+			continue
+		}
+		inFlight := m.inFlight(name)
+		col.Resume(inFlight)
+		col.SetJournal(m.journal(name))
+		n += len(inFlight)
+	}
+	return n
+}
+
+// batchCollector returns the batch Collector inside c, looking through
+// decorators such as the rate limiter.
+func batchCollector(c modeladapter.Completer) *batch.Collector {
+	col, _ := unwrapCompleter[*batch.Collector](c)
+	return col
 }
 
 // replyText extracts the text content from a message.
@@ -189,8 +429,8 @@ func parseBatchTasks(r io.Reader) ([]BatchTask, error) {
 		if task.ID == "" {
 			return nil, fmt.Errorf("line %d: task id is required", lineNum)
 		}
-		if task.Task == "" {
-			return nil, fmt.Errorf("line %d: task field is required", lineNum)
+		if task.Task == "" && task.Workflow == "" {
+			return nil, fmt.Errorf("line %d: task or workflow field is required", lineNum)
 		}
 
 		tasks = append(tasks, task)
@@ -1,12 +1,24 @@
 package engine
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/germanamz/shelly/pkg/chats/chat"
 	"github.com/germanamz/shelly/pkg/chats/content"
 	"github.com/germanamz/shelly/pkg/chats/message"
 	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/batch"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -51,7 +63,7 @@ func TestParseBatchTasks_MissingTask(t *testing.T) {
 	input := `{"id":"task-1"}`
 	_, err := parseBatchTasks(strings.NewReader(input))
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "task field is required")
+	assert.Contains(t, err.Error(), "task or workflow field is required")
 }
 
 func TestParseBatchTasks_InvalidJSON(t *testing.T) {
@@ -80,3 +92,182 @@ func TestReplyText_Empty(t *testing.T) {
 	msg := message.Message{}
 	assert.Empty(t, replyText(msg))
 }
+
+// flakyCompleter fails a request while its prompt has failures left.
+type flakyCompleter struct {
+	mu       sync.Mutex
+	failures map[string]int // prompt → remaining failures (-1 = always)
+	calls    int
+}
+
+func (f *flakyCompleter) Complete(_ context.Context, ch *chat.Chat, _ []toolbox.Tool) (message.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+
+	last, _ := ch.Last()
+	prompt := last.TextContent()
+	if n := f.failures[prompt]; n != 0 {
+		if n > 0 {
+			f.failures[prompt] = n - 1
+		}
+		return message.Message{}, errors.New("provider unavailable")
+	}
+	return message.NewText("bot", role.Assistant, "done: "+prompt), nil
+}
+
+func (f *flakyCompleter) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// newBatchEngine creates an engine backed by completer and a tasks file with
+// the given prompts as task IDs.
+func newBatchEngine(t *testing.T, completer modeladapter.Completer, prompts ...string) (eng *Engine, tasksPath, outputPath string) {
+	t.Helper()
+
+	root := t.TempDir()
+	eng = newTestEngine(t, "flaky", fixedProvider(completer), Config{
+		ShellyDir: filepath.Join(root, ".shelly"),
+		Providers: []ProviderConfig{{Name: "p1", Kind: "flaky", Model: "test-model"}},
+		Agents:    []AgentConfig{{Name: "bot", Provider: "p1"}},
+	})
+
+	var b strings.Builder
+	for _, p := range prompts {
+		fmt.Fprintf(&b, "{\"id\":%q,\"task\":%q}\n", p, p)
+	}
+	tasksPath = filepath.Join(root, "tasks.jsonl")
+	require.NoError(t, os.WriteFile(tasksPath, []byte(b.String()), 0o600))
+
+	return eng, tasksPath, filepath.Join(root, "out.jsonl")
+}
+
+func readBatchResults(t *testing.T, path string) []BatchResult {
+	t.Helper()
+
+	var results []BatchResult
+	_, err := readJSONLines(path, func(line []byte) {
+		var r BatchResult
+		require.NoError(t, json.Unmarshal(line, &r))
+		results = append(results, r)
+	})
+	require.NoError(t, err)
+	return results
+}
+
+func TestRunBatch_RetriesWithBackoff(t *testing.T) {
+	completer := &flakyCompleter{failures: map[string]int{"a": 1, "b": -1}}
+	eng, tasksPath, outputPath := newBatchEngine(t, completer, "a", "b", "c")
+
+	summary, err := RunBatch(context.Background(), eng, tasksPath, outputPath, BatchOptions{
+		MaxAttempts: 2,
+		RetryDelay:  time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, summary.Total)
+	assert.Equal(t, 2, summary.Completed)
+	assert.Equal(t, 1, summary.Failed)
+	assert.Equal(t, 2, summary.Retried)
+	assert.Zero(t, summary.Pending)
+	require.Len(t, summary.Failures, 1)
+	assert.Equal(t, "b", summary.Failures[0].ID)
+	assert.Equal(t, 2, summary.Failures[0].Attempts)
+	assert.FileExists(t, summary.Manifest)
+
+	byID := make(map[string]BatchResult)
+	for _, r := range readBatchResults(t, outputPath) {
+		byID[r.ID] = r
+	}
+	assert.Equal(t, "completed", byID["a"].Status)
+	assert.Equal(t, 2, byID["a"].Attempts)
+	assert.Equal(t, "done: c", byID["c"].Reply)
+	assert.Equal(t, "error", byID["b"].Status)
+}
+
+func TestRunBatch_ResumeSkipsCompleted(t *testing.T) {
+	completer := &flakyCompleter{failures: map[string]int{"b": 1}}
+	eng, tasksPath, outputPath := newBatchEngine(t, completer, "a", "b")
+
+	first, err := RunBatch(context.Background(), eng, tasksPath, outputPath, BatchOptions{MaxAttempts: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, first.Completed)
+	assert.Equal(t, 1, first.Failed)
+
+	// Simulate a crash mid-write of the output.
+	f, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"id":"torn`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	calls := completer.callCount()
+	second, err := RunBatch(context.Background(), eng, tasksPath, outputPath, BatchOptions{Resume: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, first.RunID, second.RunID)
+	assert.Equal(t, 1, second.Skipped)
+	assert.Equal(t, 1, second.Completed)
+	assert.Zero(t, second.Failed)
+	assert.Equal(t, calls+1, completer.callCount(), "only the failed task runs again")
+
+	results := readBatchResults(t, outputPath)
+	require.Len(t, results, 3)
+	assert.Equal(t, "b", results[2].ID)
+	assert.Equal(t, "completed", results[2].Status)
+}
+
+func TestRunBatch_ResumeWithoutManifest(t *testing.T) {
+	eng, tasksPath, outputPath := newBatchEngine(t, &flakyCompleter{}, "a")
+
+	_, err := RunBatch(context.Background(), eng, tasksPath, outputPath, BatchOptions{Resume: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no run manifest")
+}
+
+func TestBatchManifest_ReplaysInFlightBatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+
+	m, err := openBatchManifest(path, false)
+	require.NoError(t, err)
+	j := m.journal("p1")
+	j.BatchSubmitted(batch.InFlight{BatchID: "b1", Requests: map[string]string{"fp1": "r1"}})
+	j.BatchSubmitted(batch.InFlight{BatchID: "b2", Requests: map[string]string{"fp2": "r2"}})
+	j.BatchFinished("b1")
+	require.NoError(t, m.setTask("t1", batchStatusCompleted, 1, ""))
+	require.NoError(t, m.Close())
+
+	// A torn trailing record must be dropped.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"kind":"task","task":"t2"`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	m, err = openBatchManifest(path, true)
+	require.NoError(t, err)
+
+	assert.Equal(t, batchStatusCompleted, m.task("t1").Status)
+	assert.Empty(t, m.task("t2").Status)
+	assert.Equal(t, []batch.InFlight{{BatchID: "b2", Requests: map[string]string{"fp2": "r2"}}}, m.inFlight("p1"))
+	assert.Empty(t, m.inFlight("p2"))
+
+	// New records start on a fresh line after the dropped tail.
+	require.NoError(t, m.setTask("t2", batchStatusRunning, 1, ""))
+	require.NoError(t, m.Close())
+
+	m, err = openBatchManifest(path, true)
+	require.NoError(t, err)
+	defer func() { _ = m.Close() }()
+	assert.Equal(t, batchStatusRunning, m.task("t2").Status)
+}
+
+func TestBatchCollector_UnwrapsRateLimiter(t *testing.T) {
+	col := batch.NewCollector(&flakyCompleter{}, nil, batch.CollectorOpts{})
+	rl := modeladapter.NewRateLimitedCompleter(col, modeladapter.RateLimitOpts{RPM: 10})
+
+	assert.Same(t, col, batchCollector(rl))
+	assert.Nil(t, batchCollector(&flakyCompleter{}))
+}
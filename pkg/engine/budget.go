@@ -0,0 +1,176 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/germanamz/shelly/pkg/agent"
+	"github.com/germanamz/shelly/pkg/budget"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/usage"
+	"github.com/germanamz/shelly/pkg/shellydir"
+)
+
+// ErrBudgetStopped is returned when the user chooses to stop at a budget
+// warning (budget.confirm_on_warn). Exhausted limits return a
+// *budget.ExceededError instead; both stop the whole delegation tree.
+var ErrBudgetStopped = errors.New("engine: stopped at budget warning")
+
+// Budget answer options offered by the confirm_on_warn question.
+const (
+	budgetContinue = "Continue"
+	budgetStop     = "Stop"
+)
+
+// buildBudgetTracker creates the spend tracker for cfg, or nil when no limit
+// is set. Rolling totals use the shared ledger when the .shelly directory
+// exists and are process-local otherwise.
+func buildBudgetTracker(cfg BudgetConfig, dir shellydir.Dir) (*budget.Tracker, error) {
+	limits := budget.Limits{
+		Session:       cfg.Session,
+		Daily:         cfg.Daily,
+		Agents:        cfg.Agents,
+		Providers:     cfg.Providers,
+		WarnThreshold: cfg.WarnThreshold,
+	}
+	if !limits.Enabled() {
+		return nil, nil
+	}
+
+	var ledger *budget.Ledger
+	if dir.Exists() {
+		var err error
+		if ledger, err = budget.OpenLedger(dir.SpendDir()); err != nil {
+			return nil, err
+		}
+	}
+
+	return budget.NewTracker(limits, ledger), nil
+}
+
+// budgetEffect enforces spend limits around every completion of one agent
+// instance. Before the call it checks the limits, surfacing warnings and
+// stopping the session on exhaustion; after the call it prices the usage
+// recorded by the agent's completer since the previous charge and charges it
+// to the tracker. That includes calls made by other effects (e.g. compaction)
+// and outside the loop (e.g. /compact). The completer is the agent's own
+// usage-tracking wrapper (see agent.Agent.TrackUsage), so calls of other
+// agents sharing the provider are not included.
+type budgetEffect struct {
+	tracker  *budget.Tracker
+	agent    string // agent config name
+	provider string // provider config name
+	pricing  usage.ModelPricing
+	confirm  bool
+
+	events *EventBus
+	ask    func(ctx context.Context, text string, options []string) (string, error)
+	stop   func(sessionID string, cause error)
+
+	charged usage.TokenCount // completer usage total already charged
+}
+
+// Eval implements agent.Effect.
+func (e *budgetEffect) Eval(ctx context.Context, ic agent.IterationContext) error {
+	sid, _ := sessionIDFromContext(ctx)
+	charge := budget.Charge{Session: sid, Agent: e.agent, Provider: e.provider}
+
+	if ic.Phase == agent.PhaseAfterComplete {
+		total := usageTotal(ic.Completer)
+		cost := usage.CalculateCost(tokenDelta(e.charged, total), e.pricing)
+		e.charged = total
+		if err := e.tracker.Record(charge, cost); err != nil {
+			slog.Warn("engine: budget: record spend", "agent", e.agent, "err", err)
+		}
+		return nil
+	}
+
+	warnings, err := e.tracker.Check(charge)
+	if err != nil {
+		var exceeded *budget.ExceededError
+		if errors.As(err, &exceeded) {
+			publishFromContext(e.events, ctx, EventBudgetExceeded, exceeded.Scope)
+		}
+		return e.halt(sid, err)
+	}
+
+	for _, w := range warnings {
+		publishFromContext(e.events, ctx, EventBudgetWarning, w)
+
+		if !e.confirm || e.ask == nil {
+			continue
+		}
+		answer, err := e.ask(ctx, fmt.Sprintf("Spend has reached %.0f%% of the %s. Continue?", w.Ratio()*100, w), []string{budgetContinue, budgetStop})
+		if err != nil {
+			return err
+		}
+		if answer == budgetStop {
+			return e.halt(sid, fmt.Errorf("%w: %s", ErrBudgetStopped, w))
+		}
+	}
+
+	return nil
+}
+
+// halt cancels the session's whole agent tree and returns cause so the
+// calling agent stops immediately too.
+func (e *budgetEffect) halt(sessionID string, cause error) error {
+	if e.stop != nil && sessionID != "" {
+		e.stop(sessionID, cause)
+	}
+	return cause
+}
+
+// usageTotal returns the completer's cumulative usage, or zero when it does
+// not report usage.
+func usageTotal(c modeladapter.Completer) usage.TokenCount {
+	if ur, ok := c.(modeladapter.UsageReporter); ok {
+		return ur.UsageTracker().Total()
+	}
+	return usage.TokenCount{}
+}
+
+// tokenDelta returns after minus before.
+func tokenDelta(before, after usage.TokenCount) usage.TokenCount {
+	return usage.TokenCount{
+		InputTokens:              after.InputTokens - before.InputTokens,
+		OutputTokens:             after.OutputTokens - before.OutputTokens,
+		CacheCreationInputTokens: after.CacheCreationInputTokens - before.CacheCreationInputTokens,
+		CacheReadInputTokens:     after.CacheReadInputTokens - before.CacheReadInputTokens,
+	}
+}
+
+// newBudgetEffect creates the budget effect template for an agent config, or
+// nil when budgets are disabled. The agent factory copies it per instance.
+func (e *Engine) newBudgetEffect(agentName, providerName string) *budgetEffect {
+	if e.budget == nil {
+		return nil
+	}
+
+	info := e.providerInfo(providerName)
+	pricing, ok := usage.LookupPricing(info.Kind, info.Model)
+	if !ok {
+		slog.Warn("engine: budget: no pricing for model, its spend is not counted", "agent", agentName, "provider", info.Label())
+	}
+
+	return &budgetEffect{
+		tracker:  e.budget,
+		agent:    agentName,
+		provider: providerName,
+		pricing:  pricing,
+		confirm:  e.cfg.Budget.ConfirmOnWarn,
+		events:   e.events,
+		ask:      e.responder.Ask,
+		stop:     e.stopSession,
+	}
+}
+
+// stopSession cancels the running Send of the session with the given ID,
+// which cancels every agent in its delegation tree.
+func (e *Engine) stopSession(id string, cause error) {
+	if s, ok := e.Session(id); ok {
+		s.stop(cause)
+	}
+}
@@ -0,0 +1,182 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/budget"
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/ask"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/usage"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pricedCompleter reports one million input tokens per call, which costs $1
+// with the pricing written by newBudgetEngine.
+type pricedCompleter struct {
+	usage usage.Tracker
+	block chan struct{} // when non-nil, Complete waits for ctx cancellation
+}
+
+func (p *pricedCompleter) Complete(ctx context.Context, _ *chat.Chat, _ []toolbox.Tool) (message.Message, error) {
+	if p.block != nil {
+		close(p.block)
+		<-ctx.Done()
+		return message.Message{}, ctx.Err()
+	}
+	p.usage.Add(usage.TokenCount{InputTokens: 1_000_000})
+	return message.NewText("bot", role.Assistant, "ok"), nil
+}
+
+func (p *pricedCompleter) UsageTracker() *usage.Tracker { return &p.usage }
+func (p *pricedCompleter) ModelMaxTokens() int          { return 0 }
+
+// newBudgetEngine creates an engine whose "priced" provider costs $1 per call.
+func newBudgetEngine(t *testing.T, completer modeladapter.Completer, b BudgetConfig) (*Engine, string) {
+	t.Helper()
+
+	usage.ResetForTest()
+	t.Cleanup(usage.ResetForTest)
+
+	shellyDir := filepath.Join(t.TempDir(), ".shelly")
+	require.NoError(t, os.MkdirAll(filepath.Join(shellyDir, "local"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(shellyDir, "local", "pricing.yaml"),
+		[]byte("- provider: priced\n  prefix: test\n  input: 1.0\n  output: 1.0\n"), 0o600))
+
+	eng := newTestEngine(t, "priced", fixedProvider(completer), Config{
+		ShellyDir: shellyDir,
+		Providers: []ProviderConfig{{Name: "p1", Kind: "priced", Model: "test-model"}},
+		Agents:    []AgentConfig{{Name: "bot", Provider: "p1"}},
+		Budget:    b,
+	})
+
+	return eng, shellyDir
+}
+
+func TestBudget_SessionLimit(t *testing.T) {
+	eng, shellyDir := newBudgetEngine(t, &pricedCompleter{}, BudgetConfig{Session: 1.5, WarnThreshold: 0.5})
+
+	sub := eng.Events().Subscribe(64)
+	defer eng.Events().Unsubscribe(sub)
+
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+
+	_, err = sess.Send(context.Background(), "one")
+	require.NoError(t, err)
+
+	// $1 of $1.50 spent: the second call warns but proceeds.
+	_, err = sess.Send(context.Background(), "two")
+	require.NoError(t, err)
+
+	_, err = sess.Send(context.Background(), "three")
+	require.ErrorIs(t, err, budget.ErrExceeded)
+
+	var exceeded *budget.ExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, budget.ScopeSession, exceeded.Scope.Kind)
+	assert.InDelta(t, 2.0, exceeded.Scope.Spent, 1e-9)
+
+	var kinds []EventKind
+	for len(sub.C) > 0 {
+		ev := <-sub.C
+		if ev.Kind == EventBudgetWarning || ev.Kind == EventBudgetExceeded {
+			kinds = append(kinds, ev.Kind)
+			assert.Equal(t, sess.ID(), ev.SessionID)
+		}
+	}
+	assert.Equal(t, []EventKind{EventBudgetWarning, EventBudgetExceeded}, kinds)
+
+	// Spend is persisted to the shared ledger.
+	files, err := os.ReadDir(filepath.Join(shellyDir, "local", "spend"))
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	// A new session has a fresh session budget.
+	other, err := eng.NewSession("")
+	require.NoError(t, err)
+	_, err = other.Send(context.Background(), "hi")
+	require.NoError(t, err)
+}
+
+func TestBudget_DailyLimitSharedAcrossEngines(t *testing.T) {
+	eng, shellyDir := newBudgetEngine(t, &pricedCompleter{}, BudgetConfig{Daily: 1})
+
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+	_, err = sess.Send(context.Background(), "one")
+	require.NoError(t, err)
+
+	// A second engine on the same .shelly directory sees the spend.
+	eng2, err := New(context.Background(), Config{
+		ShellyDir: shellyDir,
+		Providers: []ProviderConfig{{Name: "p1", Kind: "priced", Model: "test-model"}},
+		Agents:    []AgentConfig{{Name: "bot", Provider: "p1"}},
+		Budget:    BudgetConfig{Daily: 1},
+	})
+	require.NoError(t, err)
+	defer func() { _ = eng2.Close() }()
+
+	sess2, err := eng2.NewSession("")
+	require.NoError(t, err)
+	_, err = sess2.Send(context.Background(), "two")
+
+	var exceeded *budget.ExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, budget.ScopeDaily, exceeded.Scope.Kind)
+}
+
+func TestBudget_ConfirmOnWarnStop(t *testing.T) {
+	eng, _ := newBudgetEngine(t, &pricedCompleter{}, BudgetConfig{Session: 10, WarnThreshold: 0.1, ConfirmOnWarn: true})
+
+	sub := eng.Events().Subscribe(64)
+	defer eng.Events().Unsubscribe(sub)
+
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+
+	_, err = sess.Send(context.Background(), "one")
+	require.NoError(t, err)
+
+	go func() {
+		for ev := range sub.C {
+			if q, ok := ev.Data.(ask.Question); ok && ev.Kind == EventAskUser {
+				assert.Equal(t, []string{budgetContinue, budgetStop}, q.Options)
+				_ = sess.Respond(q.ID, budgetStop)
+				return
+			}
+		}
+	}()
+
+	_, err = sess.Send(context.Background(), "two")
+	require.ErrorIs(t, err, ErrBudgetStopped)
+}
+
+func TestSession_StopReportsCause(t *testing.T) {
+	block := make(chan struct{})
+	eng, _ := newBudgetEngine(t, &pricedCompleter{block: block}, BudgetConfig{})
+
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+
+	cause := errors.New("stopped for a reason")
+	go func() {
+		select {
+		case <-block:
+			eng.stopSession(sess.ID(), cause)
+		case <-time.After(5 * time.Second):
+		}
+	}()
+
+	_, err = sess.Send(context.Background(), "hi")
+	require.ErrorIs(t, err, cause)
+}
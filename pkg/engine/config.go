@@ -2,35 +2,215 @@ package engine
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"regexp"
+	"slices"
 	"sort"
+	"strings"
+	"text/template"
 	"time"
 
+	"github.com/germanamz/shelly/pkg/codingtoolbox/filesystem"
+	"github.com/germanamz/shelly/pkg/hooks"
+	"github.com/germanamz/shelly/pkg/modeladapter/bpe"
+	"github.com/germanamz/shelly/pkg/notify"
+	"github.com/germanamz/shelly/pkg/providers/ollama"
+	"github.com/germanamz/shelly/pkg/providers/openai"
+	"github.com/germanamz/shelly/pkg/sessions"
+
 	"gopkg.in/yaml.v3"
 )
 
 // Config is the top-level engine configuration.
 type Config struct {
-	ShellyDir             string           `yaml:"-"` // Set by CLI, not from YAML.
-	Providers             []ProviderConfig `yaml:"providers"`
-	MCPServers            []MCPConfig      `yaml:"mcp_servers"`
-	Agents                []AgentConfig    `yaml:"agents"`
-	EntryAgent            string           `yaml:"entry_agent"`
-	Filesystem            FilesystemConfig `yaml:"filesystem"`
-	Context               ContextConfig    `yaml:"context"`
-	Git                   GitConfig        `yaml:"git"`
-	DefaultContextWindows map[string]int   `yaml:"default_context_windows"` // Per-kind context window overrides (e.g. anthropic: 200000).
-	StatusFunc            func(string)     `yaml:"-"`                       // Called with progress messages during initialization. Nil means silent.
+	ShellyDir             string               `yaml:"-"` // Set by CLI, not from YAML.
+	Providers             []ProviderConfig     `yaml:"providers"`
+	MCPServers            []MCPConfig          `yaml:"mcp_servers"`
+	Agents                []AgentConfig        `yaml:"agents"`
+	EntryAgent            string               `yaml:"entry_agent"`
+	Filesystem            FilesystemConfig     `yaml:"filesystem"`
+	Context               ContextConfig        `yaml:"context"`
+	Git                   GitConfig            `yaml:"git"`
+	Hooks                 []HookConfig         `yaml:"hooks"`
+	Budget                BudgetConfig         `yaml:"budget"`
+	Daemon                DaemonConfig         `yaml:"daemon"`
+	Triggers              []TriggerConfig      `yaml:"triggers"`
+	Workflows             []WorkflowConfig     `yaml:"workflows"`
+	Speech                SpeechConfig         `yaml:"speech"`
+	Sessions              SessionsConfig       `yaml:"sessions"`
+	Memory                MemoryConfig         `yaml:"memory"`
+	Notifications         NotifyConfig         `yaml:"notifications"`
+	ToolRenderers         []ToolRendererConfig `yaml:"tool_renderers"`
+	Keybindings           KeybindingsConfig    `yaml:"keybindings"`
+	DefaultContextWindows map[string]int       `yaml:"default_context_windows"` // Per-kind context window overrides (e.g. anthropic: 200000).
+	StatusFunc            func(string)         `yaml:"-"`                       // Called with progress messages during initialization. Nil means silent.
+	NotifyTerminal        io.Writer            `yaml:"-"`                       // Terminal that bell/OSC notifications are written to. Nil disables them.
 }
 
 // FilesystemConfig holds filesystem tool settings.
 type FilesystemConfig struct {
 	PermissionsFile string `yaml:"permissions_file"`
+	Review          string `yaml:"review"` // Diff review of fs_write/fs_edit/fs_patch edits: "" (off), "each" or "end_of_turn".
 }
 
 // ContextConfig holds project context loading settings.
 type ContextConfig struct {
-	MaxExternalFileSize int `yaml:"max_external_file_size"` // Max bytes to read per external context file (0 = default 512 KB).
+	MaxExternalFileSize int      `yaml:"max_external_file_size"` // Max bytes to read per external context file (0 = default 512 KB).
+	NestedFiles         []string `yaml:"nested_files"`           // Instruction file names discovered in subdirectories (default: AGENTS.md, CLAUDE.md, .shelly/context.md).
+	DisableNested       bool     `yaml:"disable_nested"`         // Disables lazy injection of nested instruction files.
+}
+
+// HookConfig binds an external command to a lifecycle event. The command
+// receives a JSON document on stdin and may print a JSON decision on stdout
+// (see pkg/hooks).
+type HookConfig struct {
+	Event   string   `yaml:"event"`   // pre_tool_call, post_tool_call, agent_start, agent_end, user_prompt_submit or session_end.
+	Command string   `yaml:"command"` // Executable to run (resolved via PATH).
+	Args    []string `yaml:"args"`
+	Matcher string   `yaml:"matcher"`  // Regexp on the tool name (tool events) or agent name (agent events). Empty matches all.
+	Timeout string   `yaml:"timeout"`  // Duration string (default "30s").
+	OnError string   `yaml:"on_error"` // deny or allow: whether a failing hook denies the action (default deny for pre_tool_call, allow otherwise).
+}
+
+// NotifyConfig alerts the user when a session needs attention or a long run
+// ends (see pkg/notify). Nothing notifies without rules.
+type NotifyConfig struct {
+	Terminal string               `yaml:"terminal"` // bell, osc9, osc777 or "" (bell when no other backend is set).
+	Desktop  bool                 `yaml:"desktop"`  // Freedesktop notification via notify-send or gdbus, when available.
+	Command  *NotifyCommandConfig `yaml:"command"`
+	Rules    []NotifyRuleConfig   `yaml:"rules"`
+}
+
+// NotifyCommandConfig runs an external command for each notification. It
+// receives the notification as JSON on stdin.
+type NotifyCommandConfig struct {
+	Command string   `yaml:"command"` // Executable to run (resolved via PATH).
+	Args    []string `yaml:"args"`
+	Timeout string   `yaml:"timeout"` // Duration string (default "10s").
+}
+
+// NotifyRuleConfig enables notifications for one event.
+type NotifyRuleConfig struct {
+	Event       string `yaml:"event"`        // ask_user, file_review, agent_end, error or batch_completed.
+	Idle        string `yaml:"idle"`         // Notify only after the user has been idle this long (default: at once).
+	MinDuration string `yaml:"min_duration"` // agent_end, batch_completed: skip runs shorter than this.
+}
+
+// SpeechConfig enables speech-to-text and text-to-speech through the audio
+// endpoints of a configured OpenAI provider. Audio sent to models that cannot
+// take it is transcribed with TranscriptionModel.
+type SpeechConfig struct {
+	Provider           string `yaml:"provider"`            // Name of an openai or openai_responses provider; its base URL and API key are used.
+	TranscriptionModel string `yaml:"transcription_model"` // Default "gpt-4o-mini-transcribe".
+	SpeechModel        string `yaml:"speech_model"`        // Default "gpt-4o-mini-tts".
+	Voice              string `yaml:"voice"`               // Default "alloy".
+	SpeakReplies       bool   `yaml:"speak_replies"`       // Read assistant replies aloud in the TUI (toggle with /speak).
+	Player             string `yaml:"player"`              // Command that plays an audio file, e.g. "mpv --no-video". Default: afplay, ffplay, mpv or mpg123, whichever is found.
+}
+
+// MemoryConfig configures recall from the long-term memory store (see
+// pkg/codingtoolbox/memory). Agents with the memory toolbox get the memories
+// most relevant to their task in the system prompt.
+type MemoryConfig struct {
+	Recall int `yaml:"recall"` // Memories injected per agent (0 = default 5, negative disables).
+}
+
+// SessionsConfig configures saved sessions.
+type SessionsConfig struct {
+	Retention RetentionConfig `yaml:"retention"`
+}
+
+// RetentionConfig prunes saved sessions at startup and for `shelly sessions
+// prune`. Ages are Go durations or whole days/weeks ("30d", "2w").
+type RetentionConfig struct {
+	MaxAge           string `yaml:"max_age"`            // Delete sessions not updated within this age.
+	MaxSessions      int    `yaml:"max_sessions"`       // Keep only the newest N sessions (0 = unlimited).
+	AttachmentMaxAge string `yaml:"attachment_max_age"` // Remove attachments of sessions not updated within this age; messages are kept.
+}
+
+// Policy converts the config to a sessions.RetentionPolicy.
+func (r RetentionConfig) Policy() (sessions.RetentionPolicy, error) {
+	var (
+		p   sessions.RetentionPolicy
+		err error
+	)
+	if r.MaxAge != "" {
+		if p.MaxAge, err = sessions.ParseAge(r.MaxAge); err != nil {
+			return p, fmt.Errorf("engine: config: sessions.retention.max_age: %w", err)
+		}
+	}
+	if r.AttachmentMaxAge != "" {
+		if p.AttachmentMaxAge, err = sessions.ParseAge(r.AttachmentMaxAge); err != nil {
+			return p, fmt.Errorf("engine: config: sessions.retention.attachment_max_age: %w", err)
+		}
+	}
+	if r.MaxSessions < 0 {
+		return p, fmt.Errorf("engine: config: sessions.retention.max_sessions must be >= 0, got %d", r.MaxSessions)
+	}
+	p.MaxSessions = r.MaxSessions
+	return p, nil
+}
+
+// BudgetConfig sets dollar spend limits, priced with pkg/modeladapter/usage.
+// Zero disables a limit. Rolling totals are shared across processes through
+// a ledger in .shelly/local/spend/.
+type BudgetConfig struct {
+	Session       float64            `yaml:"session"`         // USD per session, including all sub-agents.
+	Daily         float64            `yaml:"daily"`           // USD across all sessions and processes in the last 24h.
+	Agents        map[string]float64 `yaml:"agents"`          // USD per agent config name, per session.
+	Providers     map[string]float64 `yaml:"providers"`       // USD per provider name in the last 24h.
+	WarnThreshold float64            `yaml:"warn_threshold"`  // Fraction in (0, 1) at which to warn (default 0.8).
+	ConfirmOnWarn bool               `yaml:"confirm_on_warn"` // Ask the user whether to continue when a warning fires.
+}
+
+// Trigger kinds accepted in TriggerConfig.Kind.
+const (
+	TriggerCron    = "cron"    // Fires on a cron schedule.
+	TriggerWatch   = "watch"   // Fires when files matching Paths change.
+	TriggerWebhook = "webhook" // Fires on POST /hooks/<name> to the daemon listener.
+	TriggerTask    = "task"    // Fires when a task enters pending with the given Assignee.
+)
+
+// DaemonConfig holds settings for `shelly daemon`.
+type DaemonConfig struct {
+	Listen       string `yaml:"listen"`        // Webhook listen address (default "127.0.0.1:7717").
+	PollInterval string `yaml:"poll_interval"` // How long file changes settle before a watch trigger fires, also the retry interval for busy watch and task triggers (default "2s").
+}
+
+// TriggerConfig describes an event that starts an agent run in the daemon.
+// Prompt is a text/template rendered with the trigger event.
+type TriggerConfig struct {
+	Name           string   `yaml:"name"`
+	Kind           string   `yaml:"kind"` // cron, watch, webhook or task.
+	Agent          string   `yaml:"agent"`
+	Prompt         string   `yaml:"prompt"`
+	MaxConcurrency int      `yaml:"max_concurrency"` // Concurrent runs allowed for this trigger (default 1). Cron and webhook events beyond the limit are dropped; watch and task events are retried.
+	Timeout        string   `yaml:"timeout"`         // Per-run duration limit (default "30m").
+	Schedule       string   `yaml:"schedule"`        // cron: 5-field expression or @hourly/@daily/@every <duration>.
+	Paths          []string `yaml:"paths"`           // watch: globs relative to the project root (supports **).
+	Secret         string   `yaml:"secret"`          // webhook: required X-Shelly-Secret header value.
+	Assignee       string   `yaml:"assignee"`        // task: assignee to react to.
+}
+
+// WorkflowConfig declares a repeatable multi-step pipeline. Steps form a DAG
+// through Needs and are run by Engine.RunWorkflow.
+type WorkflowConfig struct {
+	Name        string               `yaml:"name"`
+	Description string               `yaml:"description"`
+	Steps       []WorkflowStepConfig `yaml:"steps"`
+}
+
+// WorkflowStepConfig is one step of a workflow, run by a fresh instance of
+// Agent.
+type WorkflowStepConfig struct {
+	Name    string            `yaml:"name"`
+	Agent   string            `yaml:"agent"`
+	Prompt  string            `yaml:"prompt"`   // text/template with .Input, .Item, .Index, .Steps and {{state "key"}}.
+	Needs   []string          `yaml:"needs"`    // Steps that must finish before this one starts.
+	When    map[string]string `yaml:"when"`     // Required status per needed step: completed (default), failed or any.
+	ForEach string            `yaml:"for_each"` // State key holding a JSON array; the step runs once per element, in parallel.
+	Output  string            `yaml:"output"`   // State key that receives the step's summary (an array for for_each steps), scoped to the run.
 }
 
 // GitConfig holds git tool settings.
@@ -54,6 +234,55 @@ type BatchConfig struct {
 	PollInterval  string `yaml:"poll_interval"`  // Duration between batch status polls (default "5s").
 	Timeout       string `yaml:"timeout"`        // Maximum time to wait for batch results (default "1h").
 	MaxBatchSize  int    `yaml:"max_batch_size"` // Maximum requests per batch before auto-flush (default 100).
+	StorageURI    string `yaml:"storage_uri"`    // bedrock/vertex: s3:// or gs:// prefix that batch jobs read and write.
+	RoleARN       string `yaml:"role_arn"`       // bedrock: IAM service role the batch job assumes.
+}
+
+// OllamaConfig holds settings for the ollama provider kind.
+type OllamaConfig struct {
+	NumCtx    int    `yaml:"num_ctx"`    // Context window the model is loaded with (0 = discovered from the model, capped).
+	KeepAlive string `yaml:"keep_alive"` // How long the model stays loaded: a duration or seconds, negative = forever.
+}
+
+// BedrockConfig holds settings for the bedrock provider kind. Credentials come
+// from the standard AWS chain (environment, shared credentials file, container
+// or instance metadata).
+type BedrockConfig struct {
+	Region  string `yaml:"region"`  // AWS region ("" = AWS_REGION or AWS_DEFAULT_REGION).
+	Profile string `yaml:"profile"` // Shared credentials profile ("" = AWS_PROFILE or "default").
+}
+
+// VertexConfig holds settings for the vertex provider kind. Credentials are
+// Application Default Credentials.
+type VertexConfig struct {
+	Project         string `yaml:"project"`          // GCP project ("" = from the credentials or GOOGLE_CLOUD_PROJECT).
+	Region          string `yaml:"region"`           // e.g. us-central1 or global ("" = GOOGLE_CLOUD_LOCATION).
+	CredentialsFile string `yaml:"credentials_file"` // Service account or user credentials ("" = GOOGLE_APPLICATION_CREDENTIALS or gcloud's file).
+}
+
+// TokenizerConfig controls a provider's pre-call token estimates, which drive
+// context thresholds (compaction, offloading, masking).
+type TokenizerConfig struct {
+	Encoding    string `yaml:"encoding"`     // cl100k_base, o200k_base or heuristic ("" = the model's encoding if known and installed, else heuristic).
+	CountTokens bool   `yaml:"count_tokens"` // anthropic, gemini: count exactly with the provider's endpoint (one extra cached request per turn).
+}
+
+// ResponsesConfig holds settings for the openai_responses provider kind.
+type ResponsesConfig struct {
+	Store            *bool    `yaml:"store"`             // Keep responses server-side and chain turns (nil = true).
+	ReasoningEffort  string   `yaml:"reasoning_effort"`  // minimal, low, medium or high.
+	ReasoningSummary string   `yaml:"reasoning_summary"` // auto, concise or detailed.
+	BuiltinTools     []string `yaml:"builtin_tools"`     // Server-side tools: web_search, code_interpreter, image_generation.
+}
+
+// options converts the config to adapter options.
+func (r ResponsesConfig) options() openai.ResponsesOptions {
+	return openai.ResponsesOptions{
+		Store:            r.Store == nil || *r.Store,
+		ReasoningEffort:  r.ReasoningEffort,
+		ReasoningSummary: r.ReasoningSummary,
+		BuiltinTools:     r.BuiltinTools,
+	}
 }
 
 // ProviderConfig describes an LLM provider instance.
@@ -67,6 +296,11 @@ type ProviderConfig struct {
 	MaxTokens     *int            `yaml:"max_tokens"`     // Max output tokens per response (nil = use provider default).
 	RateLimit     RateLimitConfig `yaml:"rate_limit"`
 	Batch         BatchConfig     `yaml:"batch"`
+	Ollama        OllamaConfig    `yaml:"ollama"`
+	Responses     ResponsesConfig `yaml:"responses"`
+	Bedrock       BedrockConfig   `yaml:"bedrock"`
+	Vertex        VertexConfig    `yaml:"vertex"`
+	Tokenizer     TokenizerConfig `yaml:"tokenizer"`
 }
 
 // MCPConfig describes an MCP server to connect to.
@@ -87,8 +321,9 @@ type EffectConfig struct {
 // In YAML it supports both a plain string ("filesystem") and an object form
 // ({name: git, tools: [git_status, git_diff]}).
 type ToolboxRef struct {
-	Name  string   `yaml:"name"`
-	Tools []string `yaml:"tools"`
+	Name   string   `yaml:"name"`
+	Tools  []string `yaml:"tools,omitempty"`
+	Strict []string `yaml:"strict,omitempty"` // Tools whose malformed arguments are rejected instead of repaired.
 }
 
 // UnmarshalYAML supports both scalar strings and mapping nodes.
@@ -106,9 +341,10 @@ func (r *ToolboxRef) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
-// MarshalYAML emits a plain string when Tools is empty, otherwise a mapping.
+// MarshalYAML emits a plain string when Tools and Strict are empty, otherwise
+// a mapping.
 func (r ToolboxRef) MarshalYAML() (any, error) {
-	if len(r.Tools) == 0 {
+	if len(r.Tools) == 0 && len(r.Strict) == 0 {
 		return r.Name, nil
 	}
 	type alias ToolboxRef
@@ -207,6 +443,61 @@ func ExpandConfigStrings(cfg *Config) {
 	cfg.EntryAgent = os.ExpandEnv(cfg.EntryAgent)
 	cfg.Filesystem.PermissionsFile = os.ExpandEnv(cfg.Filesystem.PermissionsFile)
 	cfg.Git.WorkDir = os.ExpandEnv(cfg.Git.WorkDir)
+	for i := range cfg.Context.NestedFiles {
+		cfg.Context.NestedFiles[i] = os.ExpandEnv(cfg.Context.NestedFiles[i])
+	}
+
+	for i := range cfg.Hooks {
+		h := &cfg.Hooks[i]
+		h.Event = os.ExpandEnv(h.Event)
+		h.Command = os.ExpandEnv(h.Command)
+		h.Matcher = os.ExpandEnv(h.Matcher)
+		h.Timeout = os.ExpandEnv(h.Timeout)
+		for j := range h.Args {
+			h.Args[j] = os.ExpandEnv(h.Args[j])
+		}
+	}
+
+	cfg.Notifications.Terminal = os.ExpandEnv(cfg.Notifications.Terminal)
+	if c := cfg.Notifications.Command; c != nil {
+		c.Command = os.ExpandEnv(c.Command)
+		c.Timeout = os.ExpandEnv(c.Timeout)
+		for j := range c.Args {
+			c.Args[j] = os.ExpandEnv(c.Args[j])
+		}
+	}
+
+	// Templates are left alone: "$" starts a template variable.
+	for i := range cfg.ToolRenderers {
+		r := &cfg.ToolRenderers[i]
+		r.Tool = os.ExpandEnv(r.Tool)
+		r.MCPServer = os.ExpandEnv(r.MCPServer)
+	}
+
+	cfg.Speech.Provider = os.ExpandEnv(cfg.Speech.Provider)
+	cfg.Speech.TranscriptionModel = os.ExpandEnv(cfg.Speech.TranscriptionModel)
+	cfg.Speech.SpeechModel = os.ExpandEnv(cfg.Speech.SpeechModel)
+	cfg.Speech.Voice = os.ExpandEnv(cfg.Speech.Voice)
+	cfg.Speech.Player = os.ExpandEnv(cfg.Speech.Player)
+
+	cfg.Sessions.Retention.MaxAge = os.ExpandEnv(cfg.Sessions.Retention.MaxAge)
+	cfg.Sessions.Retention.AttachmentMaxAge = os.ExpandEnv(cfg.Sessions.Retention.AttachmentMaxAge)
+
+	cfg.Daemon.Listen = os.ExpandEnv(cfg.Daemon.Listen)
+	cfg.Daemon.PollInterval = os.ExpandEnv(cfg.Daemon.PollInterval)
+	for i := range cfg.Triggers {
+		t := &cfg.Triggers[i]
+		t.Name = os.ExpandEnv(t.Name)
+		t.Kind = os.ExpandEnv(t.Kind)
+		t.Agent = os.ExpandEnv(t.Agent)
+		t.Timeout = os.ExpandEnv(t.Timeout)
+		t.Schedule = os.ExpandEnv(t.Schedule)
+		t.Secret = os.ExpandEnv(t.Secret)
+		t.Assignee = os.ExpandEnv(t.Assignee)
+		for j := range t.Paths {
+			t.Paths[j] = os.ExpandEnv(t.Paths[j])
+		}
+	}
 
 	for i := range cfg.Providers {
 		p := &cfg.Providers[i]
@@ -219,6 +510,16 @@ func ExpandConfigStrings(cfg *Config) {
 		p.Batch.CollectWindow = os.ExpandEnv(p.Batch.CollectWindow)
 		p.Batch.PollInterval = os.ExpandEnv(p.Batch.PollInterval)
 		p.Batch.Timeout = os.ExpandEnv(p.Batch.Timeout)
+		p.Ollama.KeepAlive = os.ExpandEnv(p.Ollama.KeepAlive)
+		p.Responses.ReasoningEffort = os.ExpandEnv(p.Responses.ReasoningEffort)
+		p.Responses.ReasoningSummary = os.ExpandEnv(p.Responses.ReasoningSummary)
+		p.Batch.StorageURI = os.ExpandEnv(p.Batch.StorageURI)
+		p.Batch.RoleARN = os.ExpandEnv(p.Batch.RoleARN)
+		p.Bedrock.Region = os.ExpandEnv(p.Bedrock.Region)
+		p.Bedrock.Profile = os.ExpandEnv(p.Bedrock.Profile)
+		p.Vertex.Project = os.ExpandEnv(p.Vertex.Project)
+		p.Vertex.Region = os.ExpandEnv(p.Vertex.Region)
+		p.Vertex.CredentialsFile = os.ExpandEnv(p.Vertex.CredentialsFile)
 	}
 
 	for i := range cfg.MCPServers {
@@ -243,6 +544,9 @@ func ExpandConfigStrings(cfg *Config) {
 			for k := range a.Toolboxes[j].Tools {
 				a.Toolboxes[j].Tools[k] = os.ExpandEnv(a.Toolboxes[j].Tools[k])
 			}
+			for k := range a.Toolboxes[j].Strict {
+				a.Toolboxes[j].Strict[k] = os.ExpandEnv(a.Toolboxes[j].Strict[k])
+			}
 		}
 		for j := range a.Skills {
 			a.Skills[j] = os.ExpandEnv(a.Skills[j])
@@ -259,6 +563,24 @@ func ExpandConfigStrings(cfg *Config) {
 	}
 }
 
+// ToolRendererConfig declares how a frontend renders the results of a tool,
+// of every tool of an MCP server, or of one tool of a server. The engine only
+// checks its structure; views and templates are interpreted by the frontend.
+type ToolRendererConfig struct {
+	Tool      string `yaml:"tool"`       // Tool name. Empty applies to every tool of MCPServer.
+	MCPServer string `yaml:"mcp_server"` // Name of an entry in mcp_servers.
+	View      string `yaml:"view"`       // Built-in view to reuse (e.g. diff, table, json).
+	Template  string `yaml:"template"`   // Go text/template rendering the result.
+	MaxLines  int    `yaml:"max_lines"`  // Lines shown while collapsed (0 = default, -1 = never collapse).
+}
+
+// KeybindingsConfig configures the keys of interactive frontends. Action
+// names and key syntax are interpreted by the frontend.
+type KeybindingsConfig struct {
+	Vim      bool                `yaml:"vim"`      // Start the prompt input in vim mode.
+	Bindings map[string][]string `yaml:"bindings"` // Action name to the keys bound to it, e.g. open_editor: [ctrl+e].
+}
+
 // KnownProviderKinds returns the list of registered provider kind strings.
 func KnownProviderKinds() []string {
 	factoryMu.RLock()
@@ -304,6 +626,335 @@ func (c Config) Validate() error {
 		}
 	}
 
+	if err := validateHooks(c.Hooks); err != nil {
+		return err
+	}
+
+	switch c.Filesystem.Review {
+	case filesystem.ReviewOff, filesystem.ReviewEach, filesystem.ReviewEndOfTurn:
+	default:
+		return fmt.Errorf("engine: config: filesystem: review must be one of \"each\", \"end_of_turn\" or empty, got %q", c.Filesystem.Review)
+	}
+
+	if err := validateNotifications(c.Notifications); err != nil {
+		return err
+	}
+
+	if err := validateToolRenderers(c.ToolRenderers, mcpNames); err != nil {
+		return err
+	}
+
+	if err := validateKeybindings(c.Keybindings); err != nil {
+		return err
+	}
+
+	if err := validateBudget(c.Budget, agentNames, providerNames); err != nil {
+		return err
+	}
+
+	if err := validateTriggers(c.Daemon, c.Triggers, agentNames); err != nil {
+		return err
+	}
+
+	if err := validateSpeech(c.Speech, c.Providers); err != nil {
+		return err
+	}
+
+	if _, err := c.Sessions.Retention.Policy(); err != nil {
+		return err
+	}
+
+	return validateWorkflows(c.Workflows, c.Agents, agentNames)
+}
+
+func validateWorkflows(wfs []WorkflowConfig, agents []AgentConfig, agentNames map[string]struct{}) error {
+	if _, ok := referencedBuiltins(agents)["workflows"]; ok && len(wfs) == 0 {
+		return fmt.Errorf("engine: config: workflows toolbox referenced but no workflows are configured")
+	}
+
+	names := make(map[string]struct{}, len(wfs))
+	for _, wf := range wfs {
+		if wf.Name == "" {
+			return fmt.Errorf("engine: config: workflow name is required")
+		}
+		if _, dup := names[wf.Name]; dup {
+			return fmt.Errorf("engine: config: duplicate workflow name %q", wf.Name)
+		}
+		names[wf.Name] = struct{}{}
+
+		if len(wf.Steps) == 0 {
+			return fmt.Errorf("engine: config: workflow %q: at least one step is required", wf.Name)
+		}
+
+		steps := make(map[string]struct{}, len(wf.Steps))
+		for _, st := range wf.Steps {
+			if st.Name == "" {
+				return fmt.Errorf("engine: config: workflow %q: step name is required", wf.Name)
+			}
+			if _, dup := steps[st.Name]; dup {
+				return fmt.Errorf("engine: config: workflow %q: duplicate step name %q", wf.Name, st.Name)
+			}
+			steps[st.Name] = struct{}{}
+		}
+
+		for _, st := range wf.Steps {
+			if err := validateWorkflowStep(st, steps, agentNames); err != nil {
+				return fmt.Errorf("engine: config: workflow %q: step %q: %w", wf.Name, st.Name, err)
+			}
+		}
+
+		if _, err := workflowOrder(wf.Steps); err != nil {
+			return fmt.Errorf("engine: config: workflow %q: %w", wf.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateWorkflowStep(st WorkflowStepConfig, steps, agentNames map[string]struct{}) error {
+	if _, ok := agentNames[st.Agent]; !ok {
+		return fmt.Errorf("agent %q not found in agents", st.Agent)
+	}
+	if st.Prompt == "" {
+		return fmt.Errorf("prompt is required")
+	}
+	if _, err := parseWorkflowPrompt(st.Name, st.Prompt, nil); err != nil {
+		return fmt.Errorf("invalid prompt template: %w", err)
+	}
+
+	needs := make(map[string]struct{}, len(st.Needs))
+	for _, n := range st.Needs {
+		if n == st.Name {
+			return fmt.Errorf("step cannot need itself")
+		}
+		if _, ok := steps[n]; !ok {
+			return fmt.Errorf("needs unknown step %q", n)
+		}
+		needs[n] = struct{}{}
+	}
+
+	for dep, status := range st.When {
+		if _, ok := needs[dep]; !ok {
+			return fmt.Errorf("when references %q, which is not in needs", dep)
+		}
+		switch status {
+		case WorkflowCompleted, WorkflowFailed, workflowWhenAny:
+		default:
+			return fmt.Errorf("when %q: status must be completed, failed or any, got %q", dep, status)
+		}
+	}
+	return nil
+}
+
+func validateTriggers(d DaemonConfig, ts []TriggerConfig, agentNames map[string]struct{}) error {
+	if d.PollInterval != "" {
+		if _, err := time.ParseDuration(d.PollInterval); err != nil {
+			return fmt.Errorf("engine: config: daemon: invalid poll_interval %q: %w", d.PollInterval, err)
+		}
+	}
+
+	names := make(map[string]struct{}, len(ts))
+	for _, t := range ts {
+		if t.Name == "" {
+			return fmt.Errorf("engine: config: trigger name is required")
+		}
+		if _, dup := names[t.Name]; dup {
+			return fmt.Errorf("engine: config: duplicate trigger name %q", t.Name)
+		}
+		names[t.Name] = struct{}{}
+
+		if _, ok := agentNames[t.Agent]; !ok {
+			return fmt.Errorf("engine: config: trigger %q: agent %q not found in agents", t.Name, t.Agent)
+		}
+		if t.Prompt == "" {
+			return fmt.Errorf("engine: config: trigger %q: prompt is required", t.Name)
+		}
+		if _, err := template.New(t.Name).Parse(t.Prompt); err != nil {
+			return fmt.Errorf("engine: config: trigger %q: invalid prompt template: %w", t.Name, err)
+		}
+		if t.MaxConcurrency < 0 {
+			return fmt.Errorf("engine: config: trigger %q: max_concurrency must be >= 0", t.Name)
+		}
+		if t.Timeout != "" {
+			if _, err := time.ParseDuration(t.Timeout); err != nil {
+				return fmt.Errorf("engine: config: trigger %q: invalid timeout %q: %w", t.Name, t.Timeout, err)
+			}
+		}
+
+		switch t.Kind {
+		case TriggerCron:
+			if t.Schedule == "" {
+				return fmt.Errorf("engine: config: trigger %q: schedule is required", t.Name)
+			}
+		case TriggerWatch:
+			if len(t.Paths) == 0 {
+				return fmt.Errorf("engine: config: trigger %q: paths is required", t.Name)
+			}
+		case TriggerWebhook:
+			if t.Secret == "" {
+				return fmt.Errorf("engine: config: trigger %q: secret is required", t.Name)
+			}
+		case TriggerTask:
+			if t.Assignee == "" {
+				return fmt.Errorf("engine: config: trigger %q: assignee is required", t.Name)
+			}
+		default:
+			return fmt.Errorf("engine: config: trigger %q: unknown kind %q", t.Name, t.Kind)
+		}
+	}
+	return nil
+}
+
+func validateBudget(b BudgetConfig, agentNames, providerNames map[string]struct{}) error {
+	if b.Session < 0 || b.Daily < 0 {
+		return fmt.Errorf("engine: config: budget: limits must be >= 0")
+	}
+	if b.WarnThreshold < 0 || b.WarnThreshold >= 1 {
+		return fmt.Errorf("engine: config: budget: warn_threshold must be in [0, 1), got %v", b.WarnThreshold)
+	}
+	for name, limit := range b.Agents {
+		if _, ok := agentNames[name]; !ok {
+			return fmt.Errorf("engine: config: budget: agent %q not found in agents", name)
+		}
+		if limit < 0 {
+			return fmt.Errorf("engine: config: budget: agent %q: limit must be >= 0", name)
+		}
+	}
+	for name, limit := range b.Providers {
+		if _, ok := providerNames[name]; !ok {
+			return fmt.Errorf("engine: config: budget: provider %q not found in providers", name)
+		}
+		if limit < 0 {
+			return fmt.Errorf("engine: config: budget: provider %q: limit must be >= 0", name)
+		}
+	}
+	return nil
+}
+
+func validateSpeech(sc SpeechConfig, providers []ProviderConfig) error {
+	if sc.Provider == "" {
+		if sc.SpeakReplies {
+			return fmt.Errorf("engine: config: speech: speak_replies requires a provider")
+		}
+		return nil
+	}
+	for _, p := range providers {
+		if p.Name != sc.Provider {
+			continue
+		}
+		if p.Kind != "openai" && p.Kind != "openai_responses" {
+			return fmt.Errorf("engine: config: speech: provider %q must be of kind openai or openai_responses, got %q", sc.Provider, p.Kind)
+		}
+		return nil
+	}
+	return fmt.Errorf("engine: config: speech: provider %q not found in providers", sc.Provider)
+}
+
+func validateHooks(hs []HookConfig) error {
+	for i, h := range hs {
+		if !hooks.Event(h.Event).Valid() {
+			return fmt.Errorf("engine: config: hooks[%d]: unknown event %q", i, h.Event)
+		}
+		if h.Command == "" {
+			return fmt.Errorf("engine: config: hooks[%d]: command is required", i)
+		}
+		if h.Matcher != "" {
+			if _, err := regexp.Compile(h.Matcher); err != nil {
+				return fmt.Errorf("engine: config: hooks[%d]: invalid matcher: %w", i, err)
+			}
+		}
+		if h.Timeout != "" {
+			if _, err := time.ParseDuration(h.Timeout); err != nil {
+				return fmt.Errorf("engine: config: hooks[%d]: invalid timeout %q: %w", i, h.Timeout, err)
+			}
+		}
+		switch p := hooks.ErrorPolicy(h.OnError); {
+		case !p.Valid():
+			return fmt.Errorf("engine: config: hooks[%d]: on_error must be \"deny\" or \"allow\", got %q", i, h.OnError)
+		case p == hooks.OnErrorDeny && !hooks.Event(h.Event).CanDeny():
+			return fmt.Errorf("engine: config: hooks[%d]: on_error: deny does not apply to %s hooks", i, h.Event)
+		}
+	}
+	return nil
+}
+
+func validateNotifications(n NotifyConfig) error {
+	switch n.Terminal {
+	case "", notify.TerminalBell, notify.TerminalOSC9, notify.TerminalOSC777:
+	default:
+		return fmt.Errorf("engine: config: notifications: terminal must be one of \"bell\", \"osc9\", \"osc777\" or empty, got %q", n.Terminal)
+	}
+
+	if n.Command != nil {
+		if n.Command.Command == "" {
+			return fmt.Errorf("engine: config: notifications: command: command is required")
+		}
+		if n.Command.Timeout != "" {
+			if _, err := time.ParseDuration(n.Command.Timeout); err != nil {
+				return fmt.Errorf("engine: config: notifications: command: invalid timeout %q: %w", n.Command.Timeout, err)
+			}
+		}
+	}
+
+	seen := make(map[string]struct{}, len(n.Rules))
+	for i, r := range n.Rules {
+		if !notify.Event(r.Event).Valid() {
+			return fmt.Errorf("engine: config: notifications: rules[%d]: unknown event %q", i, r.Event)
+		}
+		if _, ok := seen[r.Event]; ok {
+			return fmt.Errorf("engine: config: notifications: rules[%d]: duplicate rule for %q", i, r.Event)
+		}
+		seen[r.Event] = struct{}{}
+
+		for _, f := range [...][2]string{{"idle", r.Idle}, {"min_duration", r.MinDuration}} {
+			if f[1] == "" {
+				continue
+			}
+			if d, err := time.ParseDuration(f[1]); err != nil || d < 0 {
+				return fmt.Errorf("engine: config: notifications: rules[%d]: invalid %s %q", i, f[0], f[1])
+			}
+		}
+	}
+	return nil
+}
+
+func validateToolRenderers(renderers []ToolRendererConfig, mcpNames map[string]struct{}) error {
+	for i, r := range renderers {
+		if r.Tool == "" && r.MCPServer == "" {
+			return fmt.Errorf("engine: config: tool_renderers[%d]: tool or mcp_server is required", i)
+		}
+		if r.MCPServer != "" {
+			if _, ok := mcpNames[r.MCPServer]; !ok {
+				return fmt.Errorf("engine: config: tool_renderers[%d]: mcp_server %q not found in mcp_servers", i, r.MCPServer)
+			}
+		}
+		if (r.View == "") == (r.Template == "") {
+			return fmt.Errorf("engine: config: tool_renderers[%d]: exactly one of view or template is required", i)
+		}
+		if r.MaxLines < -1 {
+			return fmt.Errorf("engine: config: tool_renderers[%d]: max_lines must be -1 or more, got %d", i, r.MaxLines)
+		}
+	}
+	return nil
+}
+
+func validateKeybindings(kc KeybindingsConfig) error {
+	actions := make([]string, 0, len(kc.Bindings))
+	for action := range kc.Bindings {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	for _, action := range actions {
+		keys := kc.Bindings[action]
+		if action == "" {
+			return fmt.Errorf("engine: config: keybindings: action name is required")
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("engine: config: keybindings: %s: at least one key is required", action)
+		}
+		if slices.Contains(keys, "") {
+			return fmt.Errorf("engine: config: keybindings: %s: empty key", action)
+		}
+	}
 	return nil
 }
 
@@ -325,6 +976,18 @@ func validateProviders(providers []ProviderConfig) (map[string]struct{}, error)
 		if err := validateBatchConfig(p); err != nil {
 			return nil, err
 		}
+		if p.Ollama.NumCtx < 0 {
+			return nil, fmt.Errorf("engine: config: provider %q: ollama.num_ctx must be >= 0", p.Name)
+		}
+		if err := ollama.ValidateKeepAlive(p.Ollama.KeepAlive); err != nil {
+			return nil, fmt.Errorf("engine: config: provider %q: ollama.%w", p.Name, err)
+		}
+		if err := p.Responses.options().Validate(); err != nil {
+			return nil, fmt.Errorf("engine: config: provider %q: responses.%w", p.Name, err)
+		}
+		if err := validateTokenizerConfig(p); err != nil {
+			return nil, err
+		}
 		if _, dup := names[p.Name]; dup {
 			return nil, fmt.Errorf("engine: config: duplicate provider name %q", p.Name)
 		}
@@ -333,6 +996,18 @@ func validateProviders(providers []ProviderConfig) (map[string]struct{}, error)
 	return names, nil
 }
 
+func validateTokenizerConfig(p ProviderConfig) error {
+	switch p.Tokenizer.Encoding {
+	case "", encodingHeuristic, bpe.Cl100kBase, bpe.O200kBase:
+	default:
+		return fmt.Errorf("engine: config: provider %q: tokenizer.encoding: unknown encoding %q", p.Name, p.Tokenizer.Encoding)
+	}
+	if p.Tokenizer.CountTokens && p.Kind != "anthropic" && p.Kind != "gemini" {
+		return fmt.Errorf("engine: config: provider %q: tokenizer.count_tokens is only supported by anthropic and gemini", p.Name)
+	}
+	return nil
+}
+
 func validateBatchConfig(p ProviderConfig) error {
 	if !p.Batch.Enabled {
 		return nil
@@ -353,6 +1028,19 @@ func validateBatchConfig(p ProviderConfig) error {
 	if p.Batch.MaxBatchSize < 0 {
 		return fmt.Errorf("engine: config: provider %q: batch.max_batch_size must be >= 0", p.Name)
 	}
+	switch p.Kind {
+	case "bedrock":
+		if !strings.HasPrefix(p.Batch.StorageURI, "s3://") {
+			return fmt.Errorf("engine: config: provider %q: batch.storage_uri must be an s3:// uri", p.Name)
+		}
+		if p.Batch.RoleARN == "" {
+			return fmt.Errorf("engine: config: provider %q: batch.role_arn is required", p.Name)
+		}
+	case "vertex":
+		if !strings.HasPrefix(p.Batch.StorageURI, "gs://") {
+			return fmt.Errorf("engine: config: provider %q: batch.storage_uri must be a gs:// uri", p.Name)
+		}
+	}
 	return nil
 }
 
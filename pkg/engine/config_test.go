@@ -3,7 +3,9 @@ package engine
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -245,6 +247,108 @@ func TestConfig_Validate_FilesystemToolbox(t *testing.T) {
 	assert.NoError(t, cfg.Validate())
 }
 
+func TestConfig_Validate_FilesystemReview(t *testing.T) {
+	cfg := Config{
+		Providers:  []ProviderConfig{{Name: "p1", Kind: "anthropic"}},
+		Agents:     []AgentConfig{{Name: "a1", Toolboxes: []ToolboxRef{{Name: "filesystem"}}}},
+		Filesystem: FilesystemConfig{Review: "end_of_turn"},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Filesystem.Review = "always"
+	assert.ErrorContains(t, cfg.Validate(), `review must be one of "each", "end_of_turn" or empty`)
+}
+
+func TestConfig_Validate_Notifications(t *testing.T) {
+	base := func() Config {
+		return Config{
+			Providers: []ProviderConfig{{Name: "p1", Kind: "anthropic"}},
+			Agents:    []AgentConfig{{Name: "a1"}},
+			Notifications: NotifyConfig{
+				Terminal: "osc9",
+				Command:  &NotifyCommandConfig{Command: "ntfy", Timeout: "5s"},
+				Rules:    []NotifyRuleConfig{{Event: "ask_user", Idle: "30s"}, {Event: "agent_end", MinDuration: "2m"}},
+			},
+		}
+	}
+	cfg := base()
+	assert.NoError(t, cfg.Validate())
+
+	tests := []struct {
+		name   string
+		modify func(*NotifyConfig)
+		want   string
+	}{
+		{"terminal", func(n *NotifyConfig) { n.Terminal = "osc99" }, `terminal must be one of`},
+		{"command", func(n *NotifyConfig) { n.Command.Command = "" }, "command is required"},
+		{"timeout", func(n *NotifyConfig) { n.Command.Timeout = "soon" }, `invalid timeout "soon"`},
+		{"event", func(n *NotifyConfig) { n.Rules[0].Event = "tool_call_end" }, `rules[0]: unknown event "tool_call_end"`},
+		{"duplicate", func(n *NotifyConfig) { n.Rules[1].Event = "ask_user" }, `rules[1]: duplicate rule for "ask_user"`},
+		{"idle", func(n *NotifyConfig) { n.Rules[0].Idle = "-1s" }, `rules[0]: invalid idle "-1s"`},
+		{"min_duration", func(n *NotifyConfig) { n.Rules[1].MinDuration = "long" }, `rules[1]: invalid min_duration "long"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base()
+			tt.modify(&cfg.Notifications)
+			assert.ErrorContains(t, cfg.Validate(), tt.want)
+		})
+	}
+}
+
+func TestConfig_Validate_ToolRenderers(t *testing.T) {
+	base := func() Config {
+		return Config{
+			Providers:  []ProviderConfig{{Name: "p1", Kind: "anthropic"}},
+			MCPServers: []MCPConfig{{Name: "tracker", Command: "tracker-mcp"}},
+			Agents:     []AgentConfig{{Name: "a1"}},
+			ToolRenderers: []ToolRendererConfig{
+				{Tool: "git_diff", View: "diff"},
+				{Tool: "get_issue", MCPServer: "tracker", Template: "{{.Result.title}}", MaxLines: -1},
+			},
+		}
+	}
+	cfg := base()
+	assert.NoError(t, cfg.Validate())
+
+	tests := []struct {
+		name   string
+		modify func([]ToolRendererConfig)
+		want   string
+	}{
+		{"target", func(r []ToolRendererConfig) { r[0].Tool = "" }, "tool_renderers[0]: tool or mcp_server is required"},
+		{"server", func(r []ToolRendererConfig) { r[1].MCPServer = "jira" }, `tool_renderers[1]: mcp_server "jira" not found`},
+		{"none", func(r []ToolRendererConfig) { r[0].View = "" }, "exactly one of view or template"},
+		{"both", func(r []ToolRendererConfig) { r[1].View = "json" }, "exactly one of view or template"},
+		{"max_lines", func(r []ToolRendererConfig) { r[0].MaxLines = -2 }, "max_lines must be -1 or more"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base()
+			tt.modify(cfg.ToolRenderers)
+			assert.ErrorContains(t, cfg.Validate(), tt.want)
+		})
+	}
+}
+
+func TestConfig_Validate_Keybindings(t *testing.T) {
+	cfg := Config{
+		Providers: []ProviderConfig{{Name: "p1", Kind: "anthropic"}},
+		Agents:    []AgentConfig{{Name: "a1"}},
+		Keybindings: KeybindingsConfig{
+			Vim:      true,
+			Bindings: map[string][]string{"open_editor": {"ctrl+e"}, "submit": {"enter", "ctrl+s"}},
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Keybindings.Bindings["history_search"] = nil
+	assert.ErrorContains(t, cfg.Validate(), "keybindings: history_search: at least one key is required")
+
+	cfg.Keybindings.Bindings["history_search"] = []string{"ctrl+r", ""}
+	assert.ErrorContains(t, cfg.Validate(), "keybindings: history_search: empty key")
+}
+
 func TestConfig_Validate_DuplicateMCP(t *testing.T) {
 	cfg := Config{
 		Providers: []ProviderConfig{{Name: "p1", Kind: "anthropic"}},
@@ -291,6 +395,124 @@ func TestConfig_Validate_ZeroContextWindow(t *testing.T) {
 	assert.NoError(t, cfg.Validate())
 }
 
+func TestConfig_Validate_Ollama(t *testing.T) {
+	cfg := Config{
+		Providers: []ProviderConfig{{Name: "p1", Kind: "ollama", Ollama: OllamaConfig{NumCtx: 8192, KeepAlive: "10m"}}},
+		Agents:    []AgentConfig{{Name: "a1"}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Providers[0].Ollama.KeepAlive = "-1"
+	require.NoError(t, cfg.Validate())
+
+	cfg.Providers[0].Ollama.KeepAlive = "forever"
+	assert.ErrorContains(t, cfg.Validate(), "ollama.keep_alive must be a duration")
+
+	cfg.Providers[0].Ollama = OllamaConfig{NumCtx: -1}
+	assert.ErrorContains(t, cfg.Validate(), "ollama.num_ctx must be >= 0")
+}
+
+func TestConfig_Validate_Responses(t *testing.T) {
+	cfg := Config{
+		Providers: []ProviderConfig{{Name: "p1", Kind: "openai_responses", Responses: ResponsesConfig{
+			ReasoningEffort: "medium", ReasoningSummary: "auto", BuiltinTools: []string{"web_search"},
+		}}},
+		Agents: []AgentConfig{{Name: "a1"}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Providers[0].Responses.ReasoningEffort = "extreme"
+	assert.ErrorContains(t, cfg.Validate(), "responses.reasoning_effort must be one of")
+
+	cfg.Providers[0].Responses = ResponsesConfig{BuiltinTools: []string{"shell"}}
+	assert.ErrorContains(t, cfg.Validate(), `responses.builtin_tools: unknown tool "shell"`)
+}
+
+func TestConfig_Validate_CloudBatchStorage(t *testing.T) {
+	cfg := Config{
+		Providers: []ProviderConfig{{Name: "aws", Kind: "bedrock", Batch: BatchConfig{
+			Enabled: true, StorageURI: "s3://bucket/prefix", RoleARN: "arn:aws:iam::1:role/batch",
+		}}},
+		Agents: []AgentConfig{{Name: "a1"}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Providers[0].Batch.RoleARN = ""
+	assert.ErrorContains(t, cfg.Validate(), "batch.role_arn is required")
+
+	cfg.Providers[0].Batch.StorageURI = "gs://bucket"
+	assert.ErrorContains(t, cfg.Validate(), "batch.storage_uri must be an s3:// uri")
+
+	cfg.Providers[0] = ProviderConfig{Name: "gcp", Kind: "vertex", Batch: BatchConfig{Enabled: true}}
+	assert.ErrorContains(t, cfg.Validate(), "batch.storage_uri must be a gs:// uri")
+
+	cfg.Providers[0].Batch.StorageURI = "gs://bucket"
+	require.NoError(t, cfg.Validate())
+}
+
+func TestConfig_Validate_Tokenizer(t *testing.T) {
+	cfg := Config{
+		Providers: []ProviderConfig{{Name: "p1", Kind: "anthropic", Tokenizer: TokenizerConfig{CountTokens: true}}},
+		Agents:    []AgentConfig{{Name: "a1"}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Providers[0].Kind = "openai"
+	assert.ErrorContains(t, cfg.Validate(), "tokenizer.count_tokens is only supported by anthropic and gemini")
+
+	cfg.Providers[0].Tokenizer = TokenizerConfig{Encoding: "o200k_base"}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Providers[0].Tokenizer.Encoding = "p50k_base"
+	assert.ErrorContains(t, cfg.Validate(), `tokenizer.encoding: unknown encoding "p50k_base"`)
+}
+
+func TestConfig_Validate_Speech(t *testing.T) {
+	cfg := Config{
+		Providers: []ProviderConfig{{Name: "claude", Kind: "anthropic"}, {Name: "oa", Kind: "openai"}},
+		Agents:    []AgentConfig{{Name: "a1"}},
+		Speech:    SpeechConfig{SpeakReplies: true},
+	}
+	assert.ErrorContains(t, cfg.Validate(), "speech: speak_replies requires a provider")
+
+	cfg.Speech.Provider = "oa"
+	require.NoError(t, cfg.Validate())
+
+	cfg.Speech.Provider = "claude"
+	assert.ErrorContains(t, cfg.Validate(), `speech: provider "claude" must be of kind openai or openai_responses, got "anthropic"`)
+
+	cfg.Speech.Provider = "missing"
+	assert.ErrorContains(t, cfg.Validate(), `speech: provider "missing" not found in providers`)
+}
+
+func TestConfig_Validate_SessionsRetention(t *testing.T) {
+	cfg := Config{
+		Providers: []ProviderConfig{{Name: "p1", Kind: "anthropic"}},
+		Agents:    []AgentConfig{{Name: "a1"}},
+		Sessions:  SessionsConfig{Retention: RetentionConfig{MaxAge: "30d", AttachmentMaxAge: "168h", MaxSessions: 100}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	p, err := cfg.Sessions.Retention.Policy()
+	require.NoError(t, err)
+	assert.Equal(t, 30*24*time.Hour, p.MaxAge)
+	assert.Equal(t, 7*24*time.Hour, p.AttachmentMaxAge)
+	assert.Equal(t, 100, p.MaxSessions)
+
+	cfg.Sessions.Retention.MaxAge = "a month"
+	assert.ErrorContains(t, cfg.Validate(), "sessions.retention.max_age")
+
+	cfg.Sessions.Retention = RetentionConfig{MaxSessions: -1}
+	assert.ErrorContains(t, cfg.Validate(), "sessions.retention.max_sessions must be >= 0")
+}
+
+func TestResponsesConfig_StoreDefaultsToTrue(t *testing.T) {
+	assert.True(t, ResponsesConfig{}.options().Store)
+
+	off := false
+	assert.False(t, ResponsesConfig{Store: &off}.options().Store)
+}
+
 func TestConfig_Validate_InvalidContextThreshold(t *testing.T) {
 	cfg := Config{
 		Providers: []ProviderConfig{{Name: "p1", Kind: "anthropic"}},
@@ -541,6 +763,7 @@ func TestToolboxRef_MarshalYAML_RoundTrip(t *testing.T) {
 			Toolboxes: []ToolboxRef{
 				{Name: "filesystem"},
 				{Name: "git", Tools: []string{"git_status"}},
+				{Name: "memory", Strict: []string{"remember"}},
 			},
 		}},
 	}
@@ -718,3 +941,184 @@ func TestConfig_ExpandConfigStrings_InteractionFields(t *testing.T) {
 	assert.Equal(t, "interactive", cfg.Agents[0].Options.InteractionMode)
 	assert.Equal(t, "10m", cfg.Agents[0].Options.QuestionTimeout)
 }
+
+func TestConfig_Validate_Hooks(t *testing.T) {
+	base := Config{
+		Providers: []ProviderConfig{{Name: "p1", Kind: "anthropic"}},
+		Agents:    []AgentConfig{{Name: "a1", Provider: "p1"}},
+	}
+
+	valid := base
+	valid.Hooks = []HookConfig{{Event: "post_tool_call", Command: "gofmt", Matcher: "^fs_(write|edit)$", Timeout: "10s"}}
+	require.NoError(t, valid.Validate())
+
+	tests := []struct {
+		name string
+		hook HookConfig
+		want string
+	}{
+		{"unknown event", HookConfig{Event: "on_save", Command: "x"}, `unknown event "on_save"`},
+		{"missing command", HookConfig{Event: "agent_end"}, "command is required"},
+		{"bad matcher", HookConfig{Event: "pre_tool_call", Command: "x", Matcher: "("}, "invalid matcher"},
+		{"bad timeout", HookConfig{Event: "session_end", Command: "x", Timeout: "soon"}, "invalid timeout"},
+		{"bad on_error", HookConfig{Event: "pre_tool_call", Command: "x", OnError: "ignore"}, `on_error must be "deny" or "allow", got "ignore"`},
+		{"on_error deny on notification", HookConfig{Event: "agent_end", Command: "x", OnError: "deny"}, "on_error: deny does not apply to agent_end hooks"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			cfg.Hooks = []HookConfig{tt.hook}
+			assert.ErrorContains(t, cfg.Validate(), tt.want)
+		})
+	}
+}
+
+func TestConfig_Validate_Triggers(t *testing.T) {
+	base := Config{
+		Providers: []ProviderConfig{{Name: "p1", Kind: "anthropic"}},
+		Agents:    []AgentConfig{{Name: "a1", Provider: "p1"}},
+	}
+
+	valid := base
+	valid.Daemon = DaemonConfig{Listen: "127.0.0.1:9000", PollInterval: "1s"}
+	valid.Triggers = []TriggerConfig{
+		{Name: "nightly", Kind: TriggerCron, Agent: "a1", Prompt: "audit", Schedule: "0 3 * * *"},
+		{Name: "docs", Kind: TriggerWatch, Agent: "a1", Prompt: "{{range .Files}}{{.}}{{end}}", Paths: []string{"docs/**"}},
+		{Name: "ci", Kind: TriggerWebhook, Agent: "a1", Prompt: "{{.Body}}", MaxConcurrency: 2, Timeout: "10m", Secret: "s3cret"},
+		{Name: "board", Kind: TriggerTask, Agent: "a1", Prompt: "{{.Task.Title}}", Assignee: "a1"},
+	}
+	require.NoError(t, valid.Validate())
+
+	tests := []struct {
+		name    string
+		trigger TriggerConfig
+		want    string
+	}{
+		{"missing name", TriggerConfig{Kind: TriggerWebhook, Agent: "a1", Prompt: "x"}, "trigger name is required"},
+		{"unknown agent", TriggerConfig{Name: "t", Kind: TriggerWebhook, Agent: "nope", Prompt: "x"}, `agent "nope" not found`},
+		{"missing prompt", TriggerConfig{Name: "t", Kind: TriggerWebhook, Agent: "a1"}, "prompt is required"},
+		{"bad template", TriggerConfig{Name: "t", Kind: TriggerWebhook, Agent: "a1", Prompt: "{{.Body"}, "invalid prompt template"},
+		{"negative concurrency", TriggerConfig{Name: "t", Kind: TriggerWebhook, Agent: "a1", Prompt: "x", MaxConcurrency: -1}, "max_concurrency"},
+		{"bad timeout", TriggerConfig{Name: "t", Kind: TriggerWebhook, Agent: "a1", Prompt: "x", Timeout: "soon"}, "invalid timeout"},
+		{"cron without schedule", TriggerConfig{Name: "t", Kind: TriggerCron, Agent: "a1", Prompt: "x"}, "schedule is required"},
+		{"watch without paths", TriggerConfig{Name: "t", Kind: TriggerWatch, Agent: "a1", Prompt: "x"}, "paths is required"},
+		{"webhook without secret", TriggerConfig{Name: "t", Kind: TriggerWebhook, Agent: "a1", Prompt: "x"}, "secret is required"},
+		{"task without assignee", TriggerConfig{Name: "t", Kind: TriggerTask, Agent: "a1", Prompt: "x"}, "assignee is required"},
+		{"unknown kind", TriggerConfig{Name: "t", Kind: "email", Agent: "a1", Prompt: "x"}, `unknown kind "email"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			cfg.Triggers = []TriggerConfig{tt.trigger}
+			assert.ErrorContains(t, cfg.Validate(), tt.want)
+		})
+	}
+
+	dup := base
+	dup.Triggers = []TriggerConfig{
+		{Name: "t", Kind: TriggerWebhook, Agent: "a1", Prompt: "x", Secret: "s"},
+		{Name: "t", Kind: TriggerWebhook, Agent: "a1", Prompt: "y", Secret: "s"},
+	}
+	assert.ErrorContains(t, dup.Validate(), `duplicate trigger name "t"`)
+
+	badPoll := base
+	badPoll.Daemon.PollInterval = "often"
+	assert.ErrorContains(t, badPoll.Validate(), "invalid poll_interval")
+}
+
+func TestConfig_Validate_Workflows(t *testing.T) {
+	base := Config{
+		Providers: []ProviderConfig{{Name: "p1", Kind: "anthropic"}},
+		Agents:    []AgentConfig{{Name: "a1", Provider: "p1"}},
+	}
+
+	valid := base
+	valid.Workflows = []WorkflowConfig{{
+		Name: "review",
+		Steps: []WorkflowStepConfig{
+			{Name: "list", Agent: "a1", Prompt: "list {{.Input}}", Output: "files"},
+			{Name: "review", Agent: "a1", Prompt: "review {{.Item}}", Needs: []string{"list"}, ForEach: "files"},
+			{Name: "fix", Agent: "a1", Prompt: `fix {{state "files"}}`, Needs: []string{"review"}, When: map[string]string{"review": "any"}},
+		},
+	}}
+	require.NoError(t, valid.Validate())
+
+	step := func(st ...WorkflowStepConfig) []WorkflowConfig {
+		return []WorkflowConfig{{Name: "w", Steps: st}}
+	}
+	tests := []struct {
+		name      string
+		workflows []WorkflowConfig
+		want      string
+	}{
+		{"missing name", []WorkflowConfig{{Steps: []WorkflowStepConfig{{Name: "s", Agent: "a1", Prompt: "x"}}}}, "workflow name is required"},
+		{"no steps", []WorkflowConfig{{Name: "w"}}, "at least one step is required"},
+		{"missing step name", step(WorkflowStepConfig{Agent: "a1", Prompt: "x"}), "step name is required"},
+		{"duplicate step", step(WorkflowStepConfig{Name: "s", Agent: "a1", Prompt: "x"}, WorkflowStepConfig{Name: "s", Agent: "a1", Prompt: "y"}), `duplicate step name "s"`},
+		{"unknown agent", step(WorkflowStepConfig{Name: "s", Agent: "nope", Prompt: "x"}), `agent "nope" not found`},
+		{"missing prompt", step(WorkflowStepConfig{Name: "s", Agent: "a1"}), "prompt is required"},
+		{"bad template", step(WorkflowStepConfig{Name: "s", Agent: "a1", Prompt: "{{.Input"}), "invalid prompt template"},
+		{"unknown need", step(WorkflowStepConfig{Name: "s", Agent: "a1", Prompt: "x", Needs: []string{"t"}}), `needs unknown step "t"`},
+		{"self need", step(WorkflowStepConfig{Name: "s", Agent: "a1", Prompt: "x", Needs: []string{"s"}}), "cannot need itself"},
+		{"when not in needs", step(WorkflowStepConfig{Name: "s", Agent: "a1", Prompt: "x", When: map[string]string{"t": "failed"}}), "not in needs"},
+		{"bad when status", step(
+			WorkflowStepConfig{Name: "s", Agent: "a1", Prompt: "x"},
+			WorkflowStepConfig{Name: "t", Agent: "a1", Prompt: "x", Needs: []string{"s"}, When: map[string]string{"s": "done"}},
+		), "status must be completed, failed or any"},
+		{"cycle", step(
+			WorkflowStepConfig{Name: "s", Agent: "a1", Prompt: "x", Needs: []string{"t"}},
+			WorkflowStepConfig{Name: "t", Agent: "a1", Prompt: "x", Needs: []string{"s"}},
+		), "dependency cycle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			cfg.Workflows = tt.workflows
+			assert.ErrorContains(t, cfg.Validate(), tt.want)
+		})
+	}
+
+	dup := valid
+	dup.Workflows = append(slices.Clone(valid.Workflows), valid.Workflows[0])
+	assert.ErrorContains(t, dup.Validate(), `duplicate workflow name "review"`)
+
+	toolOnly := base
+	toolOnly.Agents = []AgentConfig{{Name: "a1", Provider: "p1", Toolboxes: []ToolboxRef{{Name: "workflows"}}}}
+	assert.ErrorContains(t, toolOnly.Validate(), "no workflows are configured")
+}
+
+func TestConfig_Validate_Budget(t *testing.T) {
+	base := Config{
+		Providers: []ProviderConfig{{Name: "p1", Kind: "anthropic"}},
+		Agents:    []AgentConfig{{Name: "a1", Provider: "p1"}},
+	}
+
+	valid := base
+	valid.Budget = BudgetConfig{
+		Session:       5,
+		Daily:         20,
+		Agents:        map[string]float64{"a1": 2},
+		Providers:     map[string]float64{"p1": 10},
+		WarnThreshold: 0.75,
+	}
+	require.NoError(t, valid.Validate())
+
+	tests := []struct {
+		name   string
+		budget BudgetConfig
+		want   string
+	}{
+		{"negative session", BudgetConfig{Session: -1}, "limits must be >= 0"},
+		{"threshold too high", BudgetConfig{WarnThreshold: 1}, "warn_threshold"},
+		{"unknown agent", BudgetConfig{Agents: map[string]float64{"nope": 1}}, `agent "nope" not found`},
+		{"negative agent", BudgetConfig{Agents: map[string]float64{"a1": -1}}, `agent "a1": limit must be >= 0`},
+		{"unknown provider", BudgetConfig{Providers: map[string]float64{"nope": 1}}, `provider "nope" not found`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			cfg.Budget = tt.budget
+			assert.ErrorContains(t, cfg.Validate(), tt.want)
+		})
+	}
+}
@@ -34,6 +34,7 @@ var effectFactories = map[string]EffectFactory{
 	"reflection":        buildReflectionEffect,
 	"progress":          buildProgressEffect,
 	"tool_scope":        buildToolScopeEffect,
+	"tool_search":       buildToolSearchEffect,
 	"offload":           buildOffloadEffect,
 	"token_budget":      buildTokenBudgetEffect,
 	"time_budget":       buildTimeBudgetEffect,
@@ -88,13 +89,16 @@ func sortEffects(effs []agent.Effect) {
 }
 
 // effectPriority returns 0 for compaction-class effects (which should run
-// first) and 1 for everything else.
+// first), 2 for ToolSearchEffect (so its catalog only contains tools that
+// survived static scoping) and 1 for everything else.
 func effectPriority(e agent.Effect) int {
 	switch e.(type) {
 	case *effects.CompactEffect, *effects.SlidingWindowEffect, *effects.ObservationMaskEffect:
 		return 0
 	case *effects.ToolScopeEffect, *effects.OffloadEffect:
 		return 1
+	case *effects.ToolSearchEffect:
+		return 2
 	default:
 		return 1
 	}
@@ -280,6 +284,27 @@ func buildToolScopeEffect(params map[string]any, _ EffectWiringContext) (agent.E
 	}), nil
 }
 
+// buildToolSearchEffect creates a ToolSearchEffect from YAML params.
+func buildToolSearchEffect(params map[string]any, _ EffectWiringContext) (agent.Effect, error) {
+	core, err := paramStringSlice(params, "core")
+	if err != nil {
+		return nil, err
+	}
+	maxResults, err := paramInt(params, "max_results", 0)
+	if err != nil {
+		return nil, err
+	}
+	threshold, err := paramInt(params, "threshold", 0)
+	if err != nil {
+		return nil, err
+	}
+	return effects.NewToolSearchEffect(effects.ToolSearchConfig{
+		Core:       core,
+		MaxResults: maxResults,
+		Threshold:  threshold,
+	}), nil
+}
+
 // buildTokenBudgetEffect creates a TokenBudgetEffect from YAML params.
 func buildTokenBudgetEffect(params map[string]any, _ EffectWiringContext) (agent.Effect, error) {
 	maxTokens, err := paramInt(params, "max_tokens", 0)
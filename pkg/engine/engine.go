@@ -6,15 +6,23 @@ import (
 	"log/slog"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/germanamz/shelly/pkg/agent"
+	"github.com/germanamz/shelly/pkg/budget"
 	"github.com/germanamz/shelly/pkg/chats/message"
 	"github.com/germanamz/shelly/pkg/chats/role"
 	"github.com/germanamz/shelly/pkg/codingtoolbox/ask"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/filesystem"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/memory"
+	"github.com/germanamz/shelly/pkg/hooks"
 	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/bpe"
 	"github.com/germanamz/shelly/pkg/modeladapter/usage"
+	"github.com/germanamz/shelly/pkg/notify"
 	"github.com/germanamz/shelly/pkg/projectctx"
+	"github.com/germanamz/shelly/pkg/providers/openai"
 	"github.com/germanamz/shelly/pkg/sessions"
 	"github.com/germanamz/shelly/pkg/shellydir"
 	"github.com/germanamz/shelly/pkg/skill"
@@ -33,14 +41,25 @@ type Engine struct {
 	sessionStore   *sessions.Store
 	store          *state.Store
 	taskStore      *tasks.Store
+	memory         *memory.Store
 	responder      *ask.Responder
+	reviewer       *filesystem.Reviewer
+	fs             *filesystem.FS // nil when no agent uses the filesystem toolbox
 	registry       *agent.Registry
 	completers     map[string]modeladapter.Completer
-	usageDiffLocks map[string]*sync.Mutex // per-provider lock for AgentUsageCompleter diff safety
+	contextWindows map[string]int            // context windows discovered from the provider at startup
+	tokens         map[string]providerTokens // per-provider token estimation shared by its agents
+	usageDiffLocks map[string]*sync.Mutex    // per-provider lock for AgentUsageCompleter diff safety
 	toolboxes      map[string]*toolbox.ToolBox
 	mcpClients     []*mcpclient.Client
 	dir            shellydir.Dir
 	projectCtx     projectctx.Context
+	nestedCtx      *projectctx.NestedLoader // nil when nested instructions are disabled
+	hooks          *hooks.Runner            // nil when no hooks are configured
+	budget         *budget.Tracker          // nil when no spend limit is configured
+	notifier       *notify.Notifier         // nil when no notification rule is configured
+	notifyDone     chan struct{}            // closed when the notification watcher has stopped
+	speech         *openai.Speech           // nil when speech is not configured
 	knowledgeStale bool
 	skills         []skill.Skill
 
@@ -55,7 +74,9 @@ type Engine struct {
 	agentCancels map[string]context.CancelFunc
 
 	inboxMu      sync.Mutex
-	agentInboxes map[string]chan message.Message
+	agentInboxes map[inboxKey]chan message.Message
+
+	workflowRuns atomic.Int64 // RunWorkflow counter, for run IDs
 }
 
 // New creates an Engine from the given configuration. It validates the config,
@@ -91,16 +112,24 @@ func New(ctx context.Context, cfg Config) (*Engine, error) {
 		events:         NewEventBus(),
 		registry:       agent.NewRegistry(),
 		completers:     make(map[string]modeladapter.Completer, len(cfg.Providers)),
+		contextWindows: make(map[string]int),
+		tokens:         make(map[string]providerTokens, len(cfg.Providers)),
 		usageDiffLocks: make(map[string]*sync.Mutex, len(cfg.Providers)),
 		toolboxes:      make(map[string]*toolbox.ToolBox),
 		sessions:       make(map[string]*Session),
 		dir:            dir,
 		agentCancels:   make(map[string]context.CancelFunc),
-		agentInboxes:   make(map[string]chan message.Message),
+		agentInboxes:   make(map[inboxKey]chan message.Message),
 	}
 
 	e.sessionStore = sessions.New(dir.SessionsDir())
 
+	if !cfg.Context.DisableNested {
+		e.nestedCtx = projectctx.NewNestedLoader(filepath.Dir(dir.Root()), cfg.Context.NestedFiles, cfg.Context.MaxExternalFileSize)
+	}
+
+	e.hooks = buildHookRunner(cfg.Hooks, filepath.Dir(dir.Root()))
+
 	// Migrate any legacy v1 session files to v2 directory layout.
 	if n, err := e.sessionStore.MigrateV1(); err != nil {
 		slog.Warn("engine: session v1 migration", "err", err)
@@ -108,6 +137,15 @@ func New(ctx context.Context, cfg Config) (*Engine, error) {
 		slog.Info("engine: migrated v1 sessions", "count", n)
 	}
 
+	// Apply the session retention policy (validated by Validate).
+	if policy, _ := cfg.Sessions.Retention.Policy(); !policy.IsZero() {
+		if report, err := e.sessionStore.Prune(policy, time.Now(), false); err != nil {
+			slog.Warn("engine: session retention", "err", err)
+		} else if len(report.Deleted)+len(report.AttachmentsFrom) > 0 {
+			slog.Info("engine: pruned sessions", "deleted", len(report.Deleted), "attachments_from", len(report.AttachmentsFrom), "freed_bytes", report.FreedBytes)
+		}
+	}
+
 	// Bootstrap .shelly/ directory structure.
 	if dir.Exists() {
 		if err := shellydir.EnsureStructure(dir); err != nil {
@@ -119,19 +157,47 @@ func New(ctx context.Context, cfg Config) (*Engine, error) {
 		}
 	}
 
+	budgetTracker, err := buildBudgetTracker(cfg.Budget, dir)
+	if err != nil {
+		return nil, fmt.Errorf("engine: %w", err)
+	}
+	e.budget = budgetTracker
+
 	// Load skills, project context, and MCP connections in parallel.
 	if err := e.parallelInit(ctx, cfg, dir, status); err != nil {
 		return nil, err
 	}
 
-	// Build provider completers.
+	// Build provider completers. Each is wrapped so audio parts reach models
+	// that cannot take them as transcripts.
+	e.speech = buildSpeech(cfg)
+	var transcriber modeladapter.Transcriber
+	if e.speech != nil {
+		transcriber = e.speech
+	}
+	encodings := make(map[string]*bpe.Encoding)
 	for _, pc := range cfg.Providers {
 		status(fmt.Sprintf("Initializing provider %q...", pc.Name))
-		c, err := buildCompleter(pc)
+		pt, err := buildProviderTokens(pc, dir.TokenizersDir(), encodings)
+		if err != nil {
+			return nil, err
+		}
+
+		c, err := buildCompleter(pc, modeladapter.WithInputEstimate(pt.estimate))
 		if err != nil {
 			return nil, fmt.Errorf("engine: provider %q: %w", pc.Name, err)
 		}
+		c = modeladapter.NewTranscribingCompleter(c, transcriber)
 		e.completers[pc.Name] = c
+
+		if w := discoverContextWindow(ctx, pc, cfg.DefaultContextWindows, c); w > 0 {
+			e.contextWindows[pc.Name] = w
+		}
+
+		if pt.counter, err = buildTokenCounter(pc, c); err != nil {
+			return nil, err
+		}
+		e.tokens[pc.Name] = pt
 	}
 
 	// Wire built-in toolboxes (ask, state, tasks, notes, filesystem, etc.).
@@ -149,6 +215,11 @@ func New(ctx context.Context, cfg Config) (*Engine, error) {
 		}
 	}
 
+	e.notifier = buildNotifier(cfg, filepath.Dir(dir.Root()))
+	if e.notifier != nil {
+		e.watchNotifications(ctx)
+	}
+
 	status("Ready")
 
 	return e, nil
@@ -178,6 +249,13 @@ func (e *Engine) State() *state.Store { return e.store }
 // Tasks returns the shared task store, or nil if tasks are not enabled.
 func (e *Engine) Tasks() *tasks.Store { return e.taskStore }
 
+// Memory returns the long-term memory store. It is available even when no
+// agent has the memory toolbox, so that frontends can review memories.
+func (e *Engine) Memory() *memory.Store { return e.memory }
+
+// Dir returns the engine's .shelly directory. The project root is its parent.
+func (e *Engine) Dir() shellydir.Dir { return e.dir }
+
 // NewSession creates a new interactive session. If agentName is empty the
 // config's EntryAgent is used. If EntryAgent is also empty, the first agent
 // in the config is used.
@@ -189,14 +267,11 @@ func (e *Engine) NewSession(agentName string) (*Session, error) {
 		agentName = e.cfg.Agents[0].Name
 	}
 
-	factory, ok := e.registry.Get(agentName)
-	if !ok {
-		return nil, fmt.Errorf("engine: agent %q not found", agentName)
-	}
-
 	// Perform expensive work outside the lock.
-	a := factory()
-	a.SetRegistry(e.registry)
+	a, providerName, err := e.sessionAgent(agentName, "")
+	if err != nil {
+		return nil, err
+	}
 	a.Init()
 
 	e.mu.Lock()
@@ -207,8 +282,10 @@ func (e *Engine) NewSession(agentName string) (*Session, error) {
 	e.nextID++
 	id := fmt.Sprintf("session-%d", e.nextID)
 
-	s := newSession(id, a, e, e.events, e.responder)
-	s.providerInfo = e.resolveProviderInfo(agentName)
+	s := newSession(id, a, e, e.events, e.responder, e.reviewer)
+	s.providerName = providerName
+	s.providerInfo = e.providerInfo(providerName)
+	s.hooks = e.hooks
 	e.wireAutoSave(s)
 
 	e.sessions[id] = s
@@ -219,27 +296,43 @@ func (e *Engine) NewSession(agentName string) (*Session, error) {
 
 // ResumeSession loads a previously persisted session from disk and creates a
 // new live session with the restored messages. The persistID is reused so
-// subsequent saves overwrite the same file.
+// subsequent saves overwrite the same file. The session continues with the
+// provider it last used (see Session.SetCompleter) if that provider is still
+// configured.
 func (e *Engine) ResumeSession(persistID string) (*Session, error) {
 	info, msgs, err := e.sessionStore.Load(persistID)
 	if err != nil {
 		return nil, fmt.Errorf("engine: load session: %w", err)
 	}
 
-	factory, ok := e.registry.Get(info.Agent)
-	if !ok {
+	if _, ok := e.registry.Get(info.Agent); !ok {
 		return nil, fmt.Errorf("engine: agent %q not found (session references unavailable agent)", info.Agent)
 	}
 
-	a := factory()
-	a.SetRegistry(e.registry)
+	provider := info.Provider.Name
+	if _, ok := e.completers[provider]; provider != "" && !ok {
+		slog.Warn("engine: resumed session's provider is no longer configured, using the agent's default", "session", persistID, "provider", provider)
+		provider = ""
+	}
+
+	a, providerName, err := e.sessionAgent(info.Agent, provider)
+	if err != nil {
+		return nil, err
+	}
 	a.Init()
 
 	// Skip the persisted system prompt (index 0) — the freshly initialized
 	// agent already has its own system prompt. Append only the non-system
-	// messages.
-	if len(msgs) > 1 {
-		a.Chat().Append(msgs[1:]...)
+	// messages. Imported transcripts may have no system prompt.
+	if len(msgs) > 0 && msgs[0].Role == role.System {
+		msgs = msgs[1:]
+	}
+	providerInfo := e.providerInfo(providerName)
+	if info.Provider.Kind != "" {
+		msgs = portHistory(msgs, ProviderInfo{Kind: info.Provider.Kind, Model: info.Provider.Model}, providerInfo)
+	}
+	if len(msgs) > 0 {
+		a.Chat().Append(msgs...)
 	}
 
 	e.mu.Lock()
@@ -250,10 +343,16 @@ func (e *Engine) ResumeSession(persistID string) (*Session, error) {
 	e.nextID++
 	id := fmt.Sprintf("session-%d", e.nextID)
 
-	s := newSession(id, a, e, e.events, e.responder)
+	s := newSession(id, a, e, e.events, e.responder, e.reviewer)
 	s.persistID = info.ID
 	s.createdAt = info.CreatedAt
-	s.providerInfo = e.resolveProviderInfo(info.Agent)
+	s.trigger = info.Trigger
+	s.providerName = providerName
+	s.providerInfo = providerInfo
+	s.switches = info.Switches
+	s.usage.restore(info.Usage)
+	s.queue.restore(info.Queue)
+	s.hooks = e.hooks
 	e.wireAutoSave(s)
 
 	e.sessions[id] = s
@@ -271,32 +370,41 @@ func (e *Engine) Session(id string) (*Session, bool) {
 	return s, ok
 }
 
-// RemoveSession removes a session from the engine. Returns true if the session
-// existed and was removed, false if no session with that ID was found. The
-// caller is responsible for ensuring the session is no longer active before
-// removing it.
+// RemoveSession removes a session from the engine and runs its session_end
+// hooks. Returns true if the session existed and was removed, false if no
+// session with that ID was found. The caller is responsible for ensuring the
+// session is no longer active before removing it.
 func (e *Engine) RemoveSession(id string) bool {
 	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	_, ok := e.sessions[id]
+	s, ok := e.sessions[id]
 	if ok {
 		delete(e.sessions, id)
 	}
-	return ok
-}
+	e.mu.Unlock()
 
-// resolveProviderInfo looks up the ProviderConfig for the given agent and
-// returns a ProviderInfo with Kind and Model.
-func (e *Engine) resolveProviderInfo(agentName string) ProviderInfo {
-	var agentProvider string
-	for _, ac := range e.cfg.Agents {
-		if ac.Name == agentName {
-			agentProvider = ac.Provider
-			break
+	if ok {
+		s.end()
+		if e.budget != nil {
+			e.budget.Forget(id)
 		}
 	}
-	providerName := e.agentProviderName(agentProvider)
+	return ok
+}
+
+// Providers returns the configured providers.
+func (e *Engine) Providers() []ProviderConfig { return e.cfg.Providers }
+
+// Agents returns the configured agents.
+func (e *Engine) Agents() []AgentConfig { return e.cfg.Agents }
+
+// ToolRenderers returns the configured tool result renderers.
+func (e *Engine) ToolRenderers() []ToolRendererConfig { return e.cfg.ToolRenderers }
+
+// Keybindings returns the configured frontend key bindings.
+func (e *Engine) Keybindings() KeybindingsConfig { return e.cfg.Keybindings }
+
+// providerInfo returns the Kind and Model of the named provider.
+func (e *Engine) providerInfo(providerName string) ProviderInfo {
 	for _, pc := range e.cfg.Providers {
 		if pc.Name == providerName {
 			return ProviderInfo{Kind: pc.Kind, Model: pc.Model}
@@ -308,6 +416,11 @@ func (e *Engine) resolveProviderInfo(agentName string) ProviderInfo {
 // SessionStore returns the session persistence store.
 func (e *Engine) SessionStore() *sessions.Store { return e.sessionStore }
 
+// SaveSession persists the session immediately. Sessions are saved
+// automatically after each successful Send; use this to also record runs that
+// ended in an error.
+func (e *Engine) SaveSession(s *Session) error { return e.saveSession(s) }
+
 // wireAutoSave sets the onSendComplete callback on a session to persist it
 // after each successful Send or Compact.
 func (e *Engine) wireAutoSave(s *Session) {
@@ -338,6 +451,7 @@ func (e *Engine) saveSession(s *Session) error {
 		ID:    s.persistID,
 		Agent: s.AgentName(),
 		Provider: sessions.ProviderMeta{
+			Name:  s.providerName,
 			Kind:  s.providerInfo.Kind,
 			Model: s.providerInfo.Model,
 		},
@@ -345,17 +459,28 @@ func (e *Engine) saveSession(s *Session) error {
 		UpdatedAt: time.Now(),
 		Preview:   preview,
 		MsgCount:  ch.Len(),
+		Trigger:   s.trigger,
+		Switches:  s.switches,
+		Usage:     s.usage.snapshot(),
+		Queue:     s.Queue(),
 	}
 
 	return e.sessionStore.Save(info, msgs)
 }
 
 // sessionLifecycle is the subset of Engine that Session needs for coordinating
-// shutdown. Session holds this interface instead of *Engine to avoid reaching
-// into Engine's internal fields.
+// shutdown and running workflows. Session holds this interface instead of
+// *Engine to avoid reaching into Engine's internal fields.
 type sessionLifecycle interface {
 	acquireSend() error
 	releaseSend()
+	RunWorkflow(ctx context.Context, name, input string) (*WorkflowResult, error)
+	sessionAgent(agentName, provider string) (*agent.Agent, string, error)
+	providerInfo(providerName string) ProviderInfo
+	reviewStaged(ctx context.Context, st *filesystem.Staging) string
+	RegisterAgentInbox(sessionID, name string, inbox chan message.Message)
+	UnregisterAgentInbox(sessionID, name string)
+	SendToAgent(sessionID, agentID string, msg message.Message) error
 }
 
 // acquireSend checks that the engine is not closed and increments the in-flight
@@ -405,19 +530,38 @@ func (e *Engine) CancelAgent(name string) bool {
 	return ok
 }
 
-// RegisterAgentInbox stores the inbox channel for the named agent so that
-// SendToAgent can deliver user messages to it.
-func (e *Engine) RegisterAgentInbox(name string, inbox chan message.Message) {
+// inboxKey identifies an agent instance's inbox: agent names are only unique
+// within a session (session agents are named after their config).
+type inboxKey struct{ session, agent string }
+
+// RegisterAgentInbox stores the inbox channel for the named agent of the
+// session with the given ID so that SendToAgent can deliver user messages to
+// it.
+func (e *Engine) RegisterAgentInbox(sessionID, name string, inbox chan message.Message) {
 	e.inboxMu.Lock()
 	defer e.inboxMu.Unlock()
-	e.agentInboxes[name] = inbox
+	e.agentInboxes[inboxKey{sessionID, name}] = inbox
 }
 
-// UnregisterAgentInbox removes the inbox channel for the named agent.
-func (e *Engine) UnregisterAgentInbox(name string) {
+// UnregisterAgentInbox removes the inbox channel for the named agent of the
+// session with the given ID.
+func (e *Engine) UnregisterAgentInbox(sessionID, name string) {
 	e.inboxMu.Lock()
 	defer e.inboxMu.Unlock()
-	delete(e.agentInboxes, name)
+	delete(e.agentInboxes, inboxKey{sessionID, name})
+}
+
+// registerChildInbox implements agent.InboxRegistrar for delegated children,
+// taking the session from the delegating call's context.
+func (e *Engine) registerChildInbox(ctx context.Context, name string, inbox chan message.Message) {
+	sid, _ := sessionIDFromContext(ctx)
+	e.RegisterAgentInbox(sid, name, inbox)
+}
+
+// unregisterChildInbox implements agent.InboxUnregistrar.
+func (e *Engine) unregisterChildInbox(ctx context.Context, name string) {
+	sid, _ := sessionIDFromContext(ctx)
+	e.UnregisterAgentInbox(sid, name)
 }
 
 // ErrAgentNotFound is returned when SendToAgent targets an unknown agent.
@@ -426,12 +570,13 @@ var ErrAgentNotFound = fmt.Errorf("engine: agent not found")
 // ErrAgentInboxFull is returned when the agent already has a pending message.
 var ErrAgentInboxFull = fmt.Errorf("engine: agent has a pending message")
 
-// SendToAgent delivers a user message to a running agent's inbox. Returns
-// ErrAgentNotFound if the agent is not registered, or ErrAgentInboxFull if
-// the inbox already has a pending message.
-func (e *Engine) SendToAgent(agentID string, msg message.Message) error {
+// SendToAgent delivers a user message to the inbox of a running agent of the
+// session with the given ID. Returns ErrAgentNotFound if the agent is not
+// registered, or ErrAgentInboxFull if the inbox already has a pending
+// message.
+func (e *Engine) SendToAgent(sessionID, agentID string, msg message.Message) error {
 	e.inboxMu.Lock()
-	inbox, ok := e.agentInboxes[agentID]
+	inbox, ok := e.agentInboxes[inboxKey{sessionID, agentID}]
 	e.inboxMu.Unlock()
 	if !ok {
 		return ErrAgentNotFound
@@ -458,10 +603,26 @@ func (e *Engine) Close() error {
 		// Wait for all in-flight session sends to finish before tearing down.
 		e.wg.Wait()
 
+		e.mu.RLock()
+		remaining := make([]*Session, 0, len(e.sessions))
+		for _, s := range e.sessions {
+			remaining = append(remaining, s)
+		}
+		e.mu.RUnlock()
+
+		for _, s := range remaining {
+			s.end()
+		}
+
 		if e.cancel != nil {
 			e.cancel()
 		}
 
+		if e.notifyDone != nil {
+			<-e.notifyDone
+		}
+		e.notifier.Close()
+
 		for _, c := range e.mcpClients {
 			if err := c.Close(); err != nil && firstErr == nil {
 				firstErr = err
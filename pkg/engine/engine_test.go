@@ -13,6 +13,7 @@ import (
 	"github.com/germanamz/shelly/pkg/chats/message"
 	"github.com/germanamz/shelly/pkg/chats/role"
 	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/sessions"
 	"github.com/germanamz/shelly/pkg/tools/toolbox"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -27,6 +28,29 @@ func (m *mockCompleter) Complete(_ context.Context, _ *chat.Chat, _ []toolbox.To
 	return message.NewText("bot", role.Assistant, m.reply), nil
 }
 
+// newTestEngine registers factory for the provider kind, creates an engine for
+// cfg and closes it when the test ends. Without cfg.ShellyDir the engine uses
+// a .shelly path in a temporary directory, which is not created.
+func newTestEngine(t *testing.T, kind string, factory ProviderFactory, cfg Config) *Engine {
+	t.Helper()
+
+	RegisterProvider(kind, factory)
+	if cfg.ShellyDir == "" {
+		cfg.ShellyDir = filepath.Join(t.TempDir(), ".shelly")
+	}
+
+	eng, err := New(context.Background(), cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = eng.Close() })
+
+	return eng
+}
+
+// fixedProvider returns a provider factory that always serves c.
+func fixedProvider(c modeladapter.Completer) ProviderFactory {
+	return func(ProviderConfig) (modeladapter.Completer, error) { return c, nil }
+}
+
 func TestEngine_NewSession_DefaultAgent(t *testing.T) {
 	RegisterProvider("mock", func(_ ProviderConfig) (modeladapter.Completer, error) {
 		return &mockCompleter{reply: "hello"}, nil
@@ -456,6 +480,37 @@ func TestEngine_ResumeSession(t *testing.T) {
 	assert.Equal(t, "second message", listed[0].Preview)
 }
 
+func TestEngine_ResumeSession_Imported(t *testing.T) {
+	RegisterProvider("mock", func(_ ProviderConfig) (modeladapter.Completer, error) {
+		return &mockCompleter{reply: "ok"}, nil
+	})
+
+	cfg := Config{
+		ShellyDir: filepath.Join(t.TempDir(), ".shelly"),
+		Providers: []ProviderConfig{{Name: "p1", Kind: "mock"}},
+		Agents:    []AgentConfig{{Name: "bot", Provider: "p1"}},
+	}
+
+	eng, err := New(context.Background(), cfg)
+	require.NoError(t, err)
+	defer func() { _ = eng.Close() }()
+
+	// Imported transcripts often have no system prompt; nothing may be dropped.
+	info := sessions.SessionInfo{ID: "imported", Agent: "bot"}
+	require.NoError(t, eng.SessionStore().Save(info, []message.Message{
+		message.NewText("user", role.User, "hello"),
+		message.NewText("assistant", role.Assistant, "hi"),
+	}))
+
+	resumed, err := eng.ResumeSession("imported")
+	require.NoError(t, err)
+
+	msgs := resumed.Chat().Messages()
+	require.Len(t, msgs, 3)
+	assert.Equal(t, role.System, msgs[0].Role)
+	assert.Equal(t, "hello", msgs[1].TextContent())
+}
+
 func TestEngine_ResumeSession_NotFound(t *testing.T) {
 	RegisterProvider("mock", func(_ ProviderConfig) (modeladapter.Completer, error) {
 		return &mockCompleter{reply: "ok"}, nil
@@ -473,3 +528,70 @@ func TestEngine_ResumeSession_NotFound(t *testing.T) {
 	_, err = eng.ResumeSession("nonexistent")
 	assert.Error(t, err)
 }
+
+func TestBuildEffects_ToolSearchRunsAfterToolScope(t *testing.T) {
+	effs, err := buildEffects([]EffectConfig{
+		{Kind: "tool_search", Params: map[string]any{"core": []any{"fs_read"}, "max_results": 3}},
+		{Kind: "tool_scope", Params: map[string]any{"exclude": []any{"exec_run"}}},
+	}, EffectWiringContext{AgentName: "test"})
+	require.NoError(t, err)
+	require.Len(t, effs, 2)
+
+	assert.IsType(t, &effects.ToolScopeEffect{}, effs[0])
+	assert.IsType(t, &effects.ToolSearchEffect{}, effs[1])
+}
+
+// recallCallCompleter calls recall_memories with a stringified limit, then
+// replies once the tool result is in.
+type recallCallCompleter struct{}
+
+func (recallCallCompleter) Complete(_ context.Context, ch *chat.Chat, _ []toolbox.Tool) (message.Message, error) {
+	if last, ok := ch.Last(); ok && last.Role == role.Tool {
+		return message.NewText("bot", role.Assistant, "done"), nil
+	}
+	return message.New("bot", role.Assistant,
+		content.ToolCall{ID: "c1", Name: "recall_memories", Arguments: `{"query":"release","limit":"3"}`},
+	), nil
+}
+
+func TestEngine_ToolArgumentEvents(t *testing.T) {
+	RegisterProvider("argtest", func(_ ProviderConfig) (modeladapter.Completer, error) {
+		return recallCallCompleter{}, nil
+	})
+
+	eng, err := New(context.Background(), Config{
+		ShellyDir: filepath.Join(t.TempDir(), ".shelly"),
+		Providers: []ProviderConfig{{Name: "p1", Kind: "argtest"}},
+		Agents: []AgentConfig{
+			{Name: "lenient", Provider: "p1", Toolboxes: []ToolboxRef{{Name: "memory"}}},
+			{Name: "strict", Provider: "p1", Toolboxes: []ToolboxRef{{Name: "memory", Strict: []string{"recall_memories"}}}},
+		},
+	})
+	require.NoError(t, err)
+	defer func() { _ = eng.Close() }()
+
+	sub := eng.Events().Subscribe(64)
+	defer eng.Events().Unsubscribe(sub)
+
+	for _, name := range []string{"lenient", "strict"} {
+		sess, err := eng.NewSession(name)
+		require.NoError(t, err)
+		_, err = sess.Send(context.Background(), "hi")
+		require.NoError(t, err)
+	}
+
+	got := map[EventKind]string{}
+	timeout := time.After(time.Second)
+	for len(got) < 2 {
+		select {
+		case e := <-sub.C:
+			if e.Kind == EventToolArgsRepaired || e.Kind == EventToolArgsInvalid {
+				got[e.Kind] = e.Agent
+			}
+		case <-timeout:
+			t.Fatalf("missing tool argument events: %v", got)
+		}
+	}
+	assert.Equal(t, "lenient", got[EventToolArgsRepaired])
+	assert.Equal(t, "strict", got[EventToolArgsInvalid])
+}
@@ -15,17 +15,24 @@ const (
 	EventMessageAdded       EventKind = "message_added"
 	EventToolCallStart      EventKind = "tool_call_start"
 	EventToolCallEnd        EventKind = "tool_call_end"
+	EventToolArgsRepaired   EventKind = "tool_args_repaired" // Data: agent.ToolArgsEventData
+	EventToolArgsInvalid    EventKind = "tool_args_invalid"  // Data: agent.ToolArgsEventData
 	EventAgentStart         EventKind = "agent_start"
 	EventAgentEnd           EventKind = "agent_end"
 	EventAskUser            EventKind = "ask_user"
 	EventFileChange         EventKind = "file_change"
+	EventFileReview         EventKind = "file_review" // Data: filesystem.ReviewRequest
 	EventCompaction         EventKind = "compaction"
 	EventError              EventKind = "error"
 	EventBatchSubmitted     EventKind = "batch_submitted"
 	EventBatchPolling       EventKind = "batch_polling"
-	EventBatchCompleted     EventKind = "batch_completed"
+	EventBatchCompleted     EventKind = "batch_completed" // Data: *BatchSummary
 	EventBatchFallback      EventKind = "batch_fallback"
 	EventDelegationProgress EventKind = "delegation_progress"
+	EventBudgetWarning      EventKind = "budget_warning"  // Data: budget.Scope
+	EventBudgetExceeded     EventKind = "budget_exceeded" // Data: budget.Scope
+	EventWorkflowStep       EventKind = "workflow_step"   // Data: WorkflowStepEvent
+	EventQueueChanged       EventKind = "queue_changed"   // Data: QueueEvent
 )
 
 // Event is an immutable notification of engine activity.
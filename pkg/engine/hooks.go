@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/agent"
+	"github.com/germanamz/shelly/pkg/agentctx"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/hooks"
+)
+
+// ErrHookDenied is returned when a user_prompt_submit or agent_start hook
+// denies the action. The hook's reason is appended to the error message.
+var ErrHookDenied = errors.New("engine: denied by hook")
+
+// buildHookRunner converts hook configs into a hooks.Runner that executes
+// commands from dir. Returns nil when no hooks are configured. Configs are
+// assumed to have passed Validate.
+func buildHookRunner(cfgs []HookConfig, dir string) *hooks.Runner {
+	if len(cfgs) == 0 {
+		return nil
+	}
+
+	hs := make([]hooks.Hook, 0, len(cfgs))
+	for _, c := range cfgs {
+		h := hooks.Hook{
+			Event:   hooks.Event(c.Event),
+			Command: c.Command,
+			Args:    c.Args,
+			OnError: hooks.ErrorPolicy(c.OnError),
+		}
+		if c.Matcher != "" {
+			h.Matcher = regexp.MustCompile(c.Matcher)
+		}
+		if c.Timeout != "" {
+			h.Timeout, _ = time.ParseDuration(c.Timeout)
+		}
+		hs = append(hs, h)
+	}
+
+	return hooks.NewRunner(dir, hs)
+}
+
+// runHooks executes the hooks for in.Event, filling the session ID and agent
+// name from ctx when unset. Hook failures are logged and otherwise ignored.
+func runHooks(ctx context.Context, r *hooks.Runner, in hooks.Input) hooks.Output {
+	if in.SessionID == "" {
+		in.SessionID, _ = sessionIDFromContext(ctx)
+	}
+	if in.Agent == "" {
+		in.Agent = agentctx.AgentNameFromContext(ctx)
+	}
+
+	out, err := r.Run(ctx, in)
+	if err != nil {
+		slog.Warn("engine: hook failed", "event", in.Event, "err", err)
+	}
+	return out
+}
+
+// hookDenied wraps ErrHookDenied with the hook's reason.
+func hookDenied(reason string) error {
+	if reason == "" {
+		return ErrHookDenied
+	}
+	return fmt.Errorf("%w: %s", ErrHookDenied, reason)
+}
+
+// toolHookMiddleware runs pre_tool_call and post_tool_call hooks around each
+// tool call. A pre_tool_call deny skips the tool and returns the reason as an
+// error result; a replacement tool_input rewrites the call's arguments. A
+// post_tool_call deny replaces the result with an error. additional_context
+// from either phase is appended to the result the model sees.
+func toolHookMiddleware(r *hooks.Runner) agent.ToolMiddleware {
+	return func(next agent.ToolCaller) agent.ToolCaller {
+		return func(ctx context.Context, tc content.ToolCall) content.ToolResult {
+			var extra []string
+
+			if r.Has(hooks.PreToolCall) {
+				pre := runHooks(ctx, r, hooks.Input{
+					Event:      hooks.PreToolCall,
+					ToolName:   tc.Name,
+					ToolCallID: tc.ID,
+					ToolInput:  toolInput(tc.Arguments),
+				})
+				if pre.Denied() {
+					return content.ToolResult{
+						ToolCallID: tc.ID,
+						Content:    deniedToolMessage(tc.Name, pre.Reason),
+						IsError:    true,
+					}
+				}
+				if len(pre.ToolInput) > 0 {
+					tc.Arguments = string(pre.ToolInput)
+				}
+				if pre.AdditionalContext != "" {
+					extra = append(extra, pre.AdditionalContext)
+				}
+			}
+
+			result := next(ctx, tc)
+
+			if r.Has(hooks.PostToolCall) {
+				post := runHooks(ctx, r, hooks.Input{
+					Event:      hooks.PostToolCall,
+					ToolName:   tc.Name,
+					ToolCallID: tc.ID,
+					ToolInput:  toolInput(tc.Arguments),
+					ToolResult: result.Content,
+					IsError:    result.IsError,
+				})
+				if post.Denied() {
+					return content.ToolResult{
+						ToolCallID: tc.ID,
+						Content:    deniedToolMessage(tc.Name, post.Reason),
+						IsError:    true,
+					}
+				}
+				if post.AdditionalContext != "" {
+					extra = append(extra, post.AdditionalContext)
+				}
+			}
+
+			if len(extra) > 0 {
+				result.Content += "\n\n" + strings.Join(extra, "\n\n")
+			}
+
+			return result
+		}
+	}
+}
+
+// agentHookMiddleware runs agent_start before and agent_end after each agent
+// run. An agent_start deny aborts the run with ErrHookDenied.
+func agentHookMiddleware(r *hooks.Runner) agent.Middleware {
+	return func(next agent.Runner) agent.Runner {
+		return agent.RunnerFunc(func(ctx context.Context) (message.Message, error) {
+			if r.Has(hooks.AgentStart) {
+				if out := runHooks(ctx, r, hooks.Input{Event: hooks.AgentStart}); out.Denied() {
+					return message.Message{}, hookDenied(out.Reason)
+				}
+			}
+
+			reply, err := next.Run(ctx)
+
+			if r.Has(hooks.AgentEnd) {
+				in := hooks.Input{Event: hooks.AgentEnd, Reply: reply.TextContent()}
+				if err != nil {
+					in.Error = err.Error()
+				}
+				runHooks(ctx, r, in)
+			}
+
+			return reply, err
+		})
+	}
+}
+
+// toolInput returns the tool call arguments as raw JSON, or nil when they
+// are not valid JSON.
+func toolInput(args string) json.RawMessage {
+	if !json.Valid([]byte(args)) {
+		return nil
+	}
+	return json.RawMessage(args)
+}
+
+// deniedToolMessage formats the error result shown to the model when a hook
+// blocks a tool call.
+func deniedToolMessage(tool, reason string) string {
+	if reason == "" {
+		return fmt.Sprintf("tool call %s was denied by a hook", tool)
+	}
+	return fmt.Sprintf("tool call %s was denied by a hook: %s", tool, reason)
+}
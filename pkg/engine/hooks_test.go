@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/hooks"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func shHookConfig(event hooks.Event, script string) HookConfig {
+	return HookConfig{Event: string(event), Command: "sh", Args: []string{"-c", script}}
+}
+
+func newHookEngine(t *testing.T, hs ...HookConfig) *Engine {
+	t.Helper()
+
+	return newTestEngine(t, "mock", fixedProvider(&mockCompleter{reply: "ok"}), Config{
+		Providers: []ProviderConfig{{Name: "p1", Kind: "mock"}},
+		Agents:    []AgentConfig{{Name: "bot", Provider: "p1"}},
+		Hooks:     hs,
+	})
+}
+
+func TestToolHookMiddleware_PreDenySkipsTool(t *testing.T) {
+	r := buildHookRunner([]HookConfig{
+		shHookConfig(hooks.PreToolCall, `echo '{"decision":"deny","reason":"no shell"}'`),
+	}, t.TempDir())
+
+	called := false
+	next := func(_ context.Context, tc content.ToolCall) content.ToolResult {
+		called = true
+		return content.ToolResult{ToolCallID: tc.ID, Content: "ran"}
+	}
+
+	result := toolHookMiddleware(r)(next)(context.Background(), content.ToolCall{ID: "c1", Name: "exec_run", Arguments: `{}`})
+
+	assert.False(t, called)
+	assert.True(t, result.IsError)
+	assert.Equal(t, "c1", result.ToolCallID)
+	assert.Contains(t, result.Content, "no shell")
+}
+
+func TestToolHookMiddleware_RewritesInputAndAppendsContext(t *testing.T) {
+	matched := shHookConfig(hooks.PostToolCall, `echo '{"additional_context":"formatted a.go"}'`)
+	matched.Matcher = "^fs_write$"
+
+	r := buildHookRunner([]HookConfig{
+		shHookConfig(hooks.PreToolCall, `echo '{"tool_input":{"path":"b.go"}}'`),
+		matched,
+	}, t.TempDir())
+
+	var got string
+	next := func(_ context.Context, tc content.ToolCall) content.ToolResult {
+		got = tc.Arguments
+		return content.ToolResult{ToolCallID: tc.ID, Content: "wrote"}
+	}
+
+	result := toolHookMiddleware(r)(next)(context.Background(), content.ToolCall{ID: "c1", Name: "fs_write", Arguments: `{"path":"a.go"}`})
+
+	assert.JSONEq(t, `{"path":"b.go"}`, got)
+	assert.False(t, result.IsError)
+	assert.Equal(t, "wrote\n\nformatted a.go", result.Content)
+}
+
+// malformedCallCompleter calls recall_memories with a trailing comma in its
+// arguments, then replies with the tool result.
+type malformedCallCompleter struct{}
+
+func (malformedCallCompleter) Complete(_ context.Context, ch *chat.Chat, _ []toolbox.Tool) (message.Message, error) {
+	if last, ok := ch.Last(); ok && last.Role == role.Tool {
+		return message.NewText("bot", role.Assistant, "done: "+last.Parts[0].(content.ToolResult).Content), nil
+	}
+	return message.New("bot", role.Assistant,
+		content.ToolCall{ID: "c1", Name: "recall_memories", Arguments: `{"query": "release",}`},
+	), nil
+}
+
+func TestToolHook_SeesRepairedArguments(t *testing.T) {
+	eng := newTestEngine(t, "malformed", fixedProvider(malformedCallCompleter{}), Config{
+		Providers: []ProviderConfig{{Name: "p1", Kind: "malformed"}},
+		Agents:    []AgentConfig{{Name: "bot", Provider: "p1", Toolboxes: []ToolboxRef{{Name: "memory"}}}},
+		Hooks: []HookConfig{shHookConfig(hooks.PreToolCall,
+			`grep -q '"query":"release"' && echo '{"decision":"deny","reason":"no release queries"}'; true`)},
+	})
+
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+
+	reply, err := sess.Send(context.Background(), "hi")
+	require.NoError(t, err)
+	assert.Contains(t, reply.TextContent(), "denied by a hook: no release queries")
+}
+
+func TestSession_PromptHookDenies(t *testing.T) {
+	eng := newHookEngine(t, shHookConfig(hooks.UserPromptSubmit, "echo 'secrets in prompt' >&2; exit 2"))
+
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+
+	_, err = sess.Send(context.Background(), "my password is hunter2")
+	require.ErrorIs(t, err, ErrHookDenied)
+	assert.ErrorContains(t, err, "secrets in prompt")
+
+	// The denied prompt never reaches the chat.
+	for _, m := range sess.Chat().Messages() {
+		assert.NotEqual(t, role.User, m.Role)
+	}
+}
+
+func TestSession_PromptHookAppendsContext(t *testing.T) {
+	eng := newHookEngine(t, shHookConfig(hooks.UserPromptSubmit, `echo '{"additional_context":"branch: main"}'`))
+
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+
+	_, err = sess.Send(context.Background(), "hi")
+	require.NoError(t, err)
+
+	msgs := sess.Chat().Messages()
+	require.GreaterOrEqual(t, len(msgs), 2)
+	assert.Equal(t, []content.Part{content.Text{Text: "hi"}, content.Text{Text: "branch: main"}}, msgs[1].Parts)
+}
+
+func TestAgentHooks_StartAndEnd(t *testing.T) {
+	dir := t.TempDir()
+	eng := newHookEngine(t,
+		shHookConfig(hooks.AgentStart, "cat > "+filepath.Join(dir, "start.json")),
+		shHookConfig(hooks.AgentEnd, "cat > "+filepath.Join(dir, "end.json")),
+	)
+
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+
+	_, err = sess.Send(context.Background(), "hi")
+	require.NoError(t, err)
+
+	var start, end hooks.Input
+	readHookInput(t, filepath.Join(dir, "start.json"), &start)
+	readHookInput(t, filepath.Join(dir, "end.json"), &end)
+
+	assert.Equal(t, sess.ID(), start.SessionID)
+	assert.Equal(t, "bot", start.Agent)
+	assert.Equal(t, "ok", end.Reply)
+}
+
+func TestAgentHooks_StartDenies(t *testing.T) {
+	eng := newHookEngine(t, shHookConfig(hooks.AgentStart, `echo '{"decision":"deny","reason":"maintenance"}'`))
+
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+
+	_, err = sess.Send(context.Background(), "hi")
+	require.ErrorIs(t, err, ErrHookDenied)
+}
+
+func TestEngine_SessionEndHook(t *testing.T) {
+	dir := t.TempDir()
+	eng := newHookEngine(t, shHookConfig(hooks.SessionEnd, "cat >> "+filepath.Join(dir, "ended.jsonl")))
+
+	removed, err := eng.NewSession("")
+	require.NoError(t, err)
+	kept, err := eng.NewSession("")
+	require.NoError(t, err)
+
+	require.True(t, eng.RemoveSession(removed.ID()))
+	require.NoError(t, eng.Close())
+
+	data, err := os.ReadFile(filepath.Join(dir, "ended.jsonl"))
+	require.NoError(t, err)
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var ids []string
+	for dec.More() {
+		var in hooks.Input
+		require.NoError(t, dec.Decode(&in))
+		assert.Equal(t, hooks.SessionEnd, in.Event)
+		ids = append(ids, in.SessionID)
+	}
+	assert.Equal(t, []string{removed.ID(), kept.ID()}, ids)
+}
+
+func readHookInput(t *testing.T, path string, in *hooks.Input) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, in))
+}
@@ -85,6 +85,22 @@ func (e *Engine) connectMCPClients(ctx context.Context, servers []MCPConfig, sta
 	return nil
 }
 
+// MCPTools maps the name of each tool provided by an MCP server to the
+// server's name.
+func (e *Engine) MCPTools() map[string]string {
+	tools := make(map[string]string)
+	for _, mc := range e.cfg.MCPServers {
+		tb, ok := e.toolboxes[mc.Name]
+		if !ok {
+			continue
+		}
+		for _, t := range tb.Tools() {
+			tools[t.Name] = mc.Name
+		}
+	}
+	return tools
+}
+
 // wireRoots seeds MCP clients with currently-approved directories as roots and
 // registers an observer that dynamically propagates new approvals.
 func (e *Engine) wireRoots(permStore *permissions.Store) {
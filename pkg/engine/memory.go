@@ -0,0 +1,36 @@
+package engine
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/germanamz/shelly/pkg/agent"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/memory"
+)
+
+// defaultMemoryRecall is the number of memories recalled into an agent's
+// system prompt when memory.recall is not set.
+const defaultMemoryRecall = 5
+
+// memoryRecall returns the function that recalls memories into the system
+// prompt of agents with the memory toolbox. It returns nil for other agents
+// and when recall is disabled.
+func (e *Engine) memoryRecall(ac AgentConfig) agent.MemoryRecall {
+	k := e.cfg.Memory.Recall
+	hasMemory := slices.ContainsFunc(ac.Toolboxes, func(ref ToolboxRef) bool { return ref.Name == "memory" })
+	if k < 0 || !hasMemory || e.memory == nil {
+		return nil
+	}
+	if k == 0 {
+		k = defaultMemoryRecall
+	}
+
+	return func(query string) string {
+		entries, err := e.memory.Recall(query, k, "")
+		if err != nil {
+			slog.Warn("engine: memory recall", "agent", ac.Name, "err", err)
+			return ""
+		}
+		return memory.Format(entries)
+	}
+}
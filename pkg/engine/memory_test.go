@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/memory"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// promptCompleter records the system prompt of each call.
+type promptCompleter struct {
+	prompts []string
+}
+
+func (c *promptCompleter) Complete(_ context.Context, ch *chat.Chat, _ []toolbox.Tool) (message.Message, error) {
+	c.prompts = append(c.prompts, ch.SystemPrompt())
+	return message.NewText("bot", role.Assistant, "ok"), nil
+}
+
+func newMemoryEngine(t *testing.T, c modeladapter.Completer, mc MemoryConfig) *Engine {
+	t.Helper()
+
+	shellyDir := filepath.Join(t.TempDir(), ".shelly")
+	require.NoError(t, os.MkdirAll(shellyDir, 0o750))
+
+	return newTestEngine(t, "memtest", fixedProvider(c), Config{
+		ShellyDir: shellyDir,
+		Providers: []ProviderConfig{{Name: "p1", Kind: "memtest", Model: "m"}},
+		Agents: []AgentConfig{
+			{Name: "bot", Provider: "p1", Toolboxes: []ToolboxRef{{Name: "memory"}}},
+			{Name: "plain", Provider: "p1"},
+		},
+		EntryAgent: "bot",
+		Memory:     mc,
+	})
+}
+
+func TestMemory_RecalledIntoSystemPrompt(t *testing.T) {
+	c := &promptCompleter{}
+	eng := newMemoryEngine(t, c, MemoryConfig{Recall: 2})
+
+	for _, e := range []memory.Entry{
+		{Kind: memory.KindPreference, Content: "Reply in Spanish"},
+		{Kind: memory.KindProcedure, Content: "Release by tagging the commit", Tags: []string{"release"}},
+		{Kind: memory.KindFact, Content: "The frontend uses Vite"},
+	} {
+		_, _, err := eng.Memory().Remember(e)
+		require.NoError(t, err)
+	}
+
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+	_, err = sess.Send(context.Background(), "how do I cut a release?")
+	require.NoError(t, err)
+
+	require.Len(t, c.prompts, 1)
+	assert.Contains(t, c.prompts[0], "Release by tagging the commit")
+	assert.Contains(t, c.prompts[0], "Reply in Spanish")
+	assert.NotContains(t, c.prompts[0], "Vite", "only the top memories are recalled")
+
+	// Agents without the memory toolbox get no memories.
+	plain, err := eng.NewSession("plain")
+	require.NoError(t, err)
+	_, err = plain.Send(context.Background(), "how do I cut a release?")
+	require.NoError(t, err)
+	assert.NotContains(t, c.prompts[1], "<memories>")
+}
+
+func TestMemory_RecallDisabled(t *testing.T) {
+	c := &promptCompleter{}
+	eng := newMemoryEngine(t, c, MemoryConfig{Recall: -1})
+
+	_, _, err := eng.Memory().Remember(memory.Entry{Kind: memory.KindPreference, Content: "Reply in Spanish"})
+	require.NoError(t, err)
+
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+	_, err = sess.Send(context.Background(), "hello")
+	require.NoError(t, err)
+	assert.NotContains(t, c.prompts[0], "<memories>")
+}
@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/agent"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/ask"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/filesystem"
+	"github.com/germanamz/shelly/pkg/notify"
+)
+
+// notifyBodyLimit caps the length of a notification body in runes.
+const notifyBodyLimit = 200
+
+// buildNotifier creates the notifier for cfg.Notifications, or nil when no
+// rule is configured. Commands run in dir. Durations were checked by Validate.
+func buildNotifier(cfg Config, dir string) *notify.Notifier {
+	nc := cfg.Notifications
+	if len(nc.Rules) == 0 {
+		return nil
+	}
+
+	var backends []notify.Backend
+	if nc.Desktop {
+		if b, ok := notify.Desktop(); ok {
+			backends = append(backends, b)
+		} else {
+			slog.Warn("engine: desktop notifications unavailable: needs notify-send or gdbus and a D-Bus session bus")
+		}
+	}
+	if nc.Command != nil {
+		c := notify.Command{Command: nc.Command.Command, Args: nc.Command.Args, Dir: dir}
+		if nc.Command.Timeout != "" {
+			c.Timeout, _ = time.ParseDuration(nc.Command.Timeout)
+		}
+		backends = append(backends, c)
+	}
+	if cfg.NotifyTerminal != nil && (nc.Terminal != "" || len(backends) == 0) {
+		backends = append(backends, notify.Terminal(cfg.NotifyTerminal, nc.Terminal))
+	}
+
+	rules := make([]notify.Rule, 0, len(nc.Rules))
+	for _, rc := range nc.Rules {
+		r := notify.Rule{Event: notify.Event(rc.Event)}
+		if rc.Idle != "" {
+			r.Idle, _ = time.ParseDuration(rc.Idle)
+		}
+		if rc.MinDuration != "" {
+			r.MinDuration, _ = time.ParseDuration(rc.MinDuration)
+		}
+		rules = append(rules, r)
+	}
+
+	return notify.New(rules, backends...)
+}
+
+// Notifier returns the engine's notifier, or nil when notifications are not
+// configured. Frontends call its Activity method on user input so that idle
+// rules only fire while the user is away. The methods of a nil notifier are
+// no-ops.
+func (e *Engine) Notifier() *notify.Notifier { return e.notifier }
+
+// watchNotifications forwards bus events to the notifier until ctx is done,
+// then forwards the events still buffered and closes e.notifyDone.
+func (e *Engine) watchNotifications(ctx context.Context) {
+	sub := e.events.Subscribe(256)
+	e.notifyDone = make(chan struct{})
+
+	go func() {
+		defer close(e.notifyDone)
+		defer e.events.Unsubscribe(sub)
+
+		w := newNotifyWatcher()
+		for {
+			select {
+			case ev := <-sub.C:
+				e.forwardNotification(w, ev)
+			case <-ctx.Done():
+				for {
+					select {
+					case ev := <-sub.C:
+						e.forwardNotification(w, ev)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+func (e *Engine) forwardNotification(w *notifyWatcher, ev Event) {
+	if n, ok := w.notification(ev); ok {
+		e.notifier.Notify(n)
+	}
+}
+
+// notifyWatcher turns bus events into notifications. It tracks the
+// top-level run of each session to time it and to leave failed runs to the
+// error notification.
+type notifyWatcher struct {
+	starts map[string]time.Time
+	failed map[string]bool
+}
+
+func newNotifyWatcher() *notifyWatcher {
+	return &notifyWatcher{starts: make(map[string]time.Time), failed: make(map[string]bool)}
+}
+
+// notification returns the notification for ev, if ev can notify.
+func (w *notifyWatcher) notification(ev Event) (notify.Notification, bool) {
+	n := notify.Notification{SessionID: ev.SessionID, Agent: ev.Agent}
+
+	switch ev.Kind {
+	case EventAskUser:
+		q, _ := ev.Data.(ask.Question)
+		n.Event, n.Title = notify.AskUser, "Shelly needs input"
+		n.Body = ev.Agent + " asks: " + q.Text
+	case EventFileReview:
+		req, _ := ev.Data.(filesystem.ReviewRequest)
+		n.Event, n.Title = notify.FileReview, "Shelly needs a review"
+		n.Body = ev.Agent + " wants to edit " + req.Change.Path
+	case EventAgentStart:
+		if d, _ := ev.Data.(agent.AgentEventData); d.Parent == "" {
+			w.starts[ev.SessionID] = ev.Timestamp
+			delete(w.failed, ev.SessionID)
+		}
+		return n, false
+	case EventError:
+		w.failed[ev.SessionID] = true
+		n.Event, n.Title = notify.Error, "Shelly run failed"
+		n.Body = fmt.Sprintf("%s: %v", ev.Agent, ev.Data)
+	case EventAgentEnd:
+		d, _ := ev.Data.(agent.AgentEventData)
+		start, ok := w.starts[ev.SessionID]
+		if d.Parent != "" || !ok {
+			return n, false
+		}
+		failed := w.failed[ev.SessionID]
+		delete(w.starts, ev.SessionID)
+		delete(w.failed, ev.SessionID)
+		if failed {
+			return n, false
+		}
+		n.Event, n.Title = notify.AgentEnd, "Shelly finished"
+		n.Duration = ev.Timestamp.Sub(start)
+		n.Body = fmt.Sprintf("%s finished after %s", ev.Agent, n.Duration.Round(time.Second))
+		if summary, _, _ := strings.Cut(strings.TrimSpace(d.Summary), "\n"); summary != "" {
+			n.Body += ": " + summary
+		}
+	case EventBatchCompleted:
+		s, ok := ev.Data.(*BatchSummary)
+		if !ok {
+			return n, false
+		}
+		n.Event, n.Title = notify.BatchCompleted, "Shelly batch finished"
+		n.Duration = s.Elapsed
+		n.Body = fmt.Sprintf("%d completed, %d failed, %d pending after %s", s.Completed, s.Failed, s.Pending, s.Elapsed.Round(time.Second))
+	default:
+		return n, false
+	}
+
+	if r := []rune(n.Body); len(r) > notifyBodyLimit {
+		n.Body = string(r[:notifyBodyLimit-1]) + "…"
+	}
+	return n, true
+}
@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/agent"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/ask"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_Notifications(t *testing.T) {
+	RegisterProvider("mock", func(_ ProviderConfig) (modeladapter.Completer, error) {
+		return &mockCompleter{reply: "all done\nmore detail"}, nil
+	})
+
+	var terminal bytes.Buffer
+	eng, err := New(context.Background(), Config{
+		ShellyDir: filepath.Join(t.TempDir(), ".shelly"),
+		Providers: []ProviderConfig{{Name: "p1", Kind: "mock", Model: "test"}},
+		Agents:    []AgentConfig{{Name: "bot", Provider: "p1"}},
+		Notifications: NotifyConfig{
+			Terminal: "osc777",
+			Rules:    []NotifyRuleConfig{{Event: "agent_end"}},
+		},
+		NotifyTerminal: &terminal,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, eng.Notifier())
+
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+	_, err = sess.Send(context.Background(), "hi")
+	require.NoError(t, err)
+
+	// Close delivers the events still buffered before returning.
+	require.NoError(t, eng.Close())
+	assert.Equal(t, "\x1b]777;notify;Shelly finished;bot finished after 0s: all done\a", terminal.String())
+}
+
+func TestBuildNotifier_NoRules(t *testing.T) {
+	assert.Nil(t, buildNotifier(Config{Notifications: NotifyConfig{Terminal: "bell"}}, ""))
+}
+
+func TestNotifyWatcher(t *testing.T) {
+	w := newNotifyWatcher()
+	start := time.Now()
+	top := agent.AgentEventData{}
+	child := agent.AgentEventData{Parent: "bot"}
+
+	notification := func(kind EventKind, sid, agentName string, at time.Time, data any) (notify.Notification, bool) {
+		return w.notification(Event{Kind: kind, SessionID: sid, Agent: agentName, Timestamp: at, Data: data})
+	}
+
+	n, ok := notification(EventAskUser, "s1", "bot", start, ask.Question{Text: "Which file?"})
+	require.True(t, ok)
+	assert.Equal(t, notify.AskUser, n.Event)
+	assert.Equal(t, "bot asks: Which file?", n.Body)
+
+	// A run is timed from its top-level start; sub-agents do not notify.
+	_, ok = notification(EventAgentStart, "s1", "bot", start, top)
+	assert.False(t, ok)
+	_, ok = notification(EventAgentStart, "s1", "helper", start.Add(time.Second), child)
+	assert.False(t, ok)
+	_, ok = notification(EventAgentEnd, "s1", "helper", start.Add(time.Minute), child)
+	assert.False(t, ok)
+	n, ok = notification(EventAgentEnd, "s1", "bot", start.Add(90*time.Second), top)
+	require.True(t, ok)
+	assert.Equal(t, notify.AgentEnd, n.Event)
+	assert.Equal(t, 90*time.Second, n.Duration)
+	assert.Equal(t, "bot finished after 1m30s", n.Body)
+
+	// A failed run notifies as an error only.
+	_, _ = notification(EventAgentStart, "s2", "bot", start, top)
+	n, ok = notification(EventError, "s2", "bot", start, errors.New("boom"))
+	require.True(t, ok)
+	assert.Equal(t, "bot: boom", n.Body)
+	_, ok = notification(EventAgentEnd, "s2", "bot", start, top)
+	assert.False(t, ok)
+
+	n, ok = notification(EventBatchCompleted, "", "", start, &BatchSummary{Completed: 3, Failed: 1, Elapsed: 2 * time.Minute})
+	require.True(t, ok)
+	assert.Equal(t, "3 completed, 1 failed, 0 pending after 2m0s", n.Body)
+	assert.Equal(t, 2*time.Minute, n.Duration)
+
+	_, ok = notification(EventToolCallStart, "s1", "bot", start, nil)
+	assert.False(t, ok)
+}
@@ -1,25 +1,34 @@
 package engine
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/germanamz/shelly/pkg/modeladapter"
 	"github.com/germanamz/shelly/pkg/modeladapter/batch"
 	"github.com/germanamz/shelly/pkg/providers/anthropic"
+	"github.com/germanamz/shelly/pkg/providers/bedrock"
 	"github.com/germanamz/shelly/pkg/providers/gemini"
 	"github.com/germanamz/shelly/pkg/providers/grok"
+	"github.com/germanamz/shelly/pkg/providers/ollama"
 	"github.com/germanamz/shelly/pkg/providers/openai"
+	"github.com/germanamz/shelly/pkg/providers/vertex"
 )
 
 // BuiltinContextWindows maps well-known provider kinds to their default context
 // window sizes in tokens. Used when ContextWindow is nil (omitted in YAML).
 var BuiltinContextWindows = map[string]int{
-	"anthropic": 200000,
-	"openai":    128000,
-	"grok":      131072,
-	"gemini":    1048576,
+	"anthropic":        200000,
+	"openai":           128000,
+	"openai_responses": 128000,
+	"grok":             131072,
+	"gemini":           1048576,
+	"ollama":           4096, // Ollama's default num_ctx; replaced by discovery when the server is reachable.
+	"bedrock":          200000,
+	"vertex":           200000, // Fits Claude; set context_window to use Gemini's larger window.
 }
 
 // resolveContextWindow returns the effective context window for a provider.
@@ -49,6 +58,10 @@ func init() {
 	factories["openai"] = newOpenAI
 	factories["grok"] = newGrok
 	factories["gemini"] = newGemini
+	factories["ollama"] = newOllama
+	factories["openai_responses"] = newOpenAIResponses
+	factories["bedrock"] = newBedrock
+	factories["vertex"] = newVertex
 }
 
 // RegisterProvider registers a custom provider factory under the given kind.
@@ -95,6 +108,20 @@ func newOpenAI(cfg ProviderConfig) (modeladapter.Completer, error) {
 	return a, nil
 }
 
+func newOpenAIResponses(cfg ProviderConfig) (modeladapter.Completer, error) {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.openai.com"
+	}
+
+	a := openai.NewResponses(baseURL, cfg.APIKey, cfg.Model)
+	if cfg.MaxTokens != nil {
+		a.Config.MaxTokens = *cfg.MaxTokens
+	}
+	a.Options = cfg.Responses.options()
+	return a, nil
+}
+
 func newGrok(cfg ProviderConfig) (modeladapter.Completer, error) {
 	baseURL := cfg.BaseURL
 	if baseURL == "" {
@@ -122,6 +149,119 @@ func newGemini(cfg ProviderConfig) (modeladapter.Completer, error) {
 	return a, nil
 }
 
+func newOllama(cfg ProviderConfig) (modeladapter.Completer, error) {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = ollama.DefaultBaseURL
+	}
+
+	a := ollama.New(baseURL, cfg.APIKey, cfg.Model)
+	if cfg.MaxTokens != nil {
+		a.Config.MaxTokens = *cfg.MaxTokens
+	}
+	a.Options = ollama.Options{NumCtx: cfg.Ollama.NumCtx, KeepAlive: cfg.Ollama.KeepAlive}
+	return a, nil
+}
+
+func newBedrock(cfg ProviderConfig) (modeladapter.Completer, error) {
+	region := bedrock.ResolveRegion(cfg.Bedrock.Region)
+	if region == "" {
+		return nil, fmt.Errorf("engine: provider %q: bedrock region is required (bedrock.region or AWS_REGION)", cfg.Name)
+	}
+
+	a := bedrock.New(cfg.BaseURL, region, cfg.Model, bedrock.DefaultCredentialChain(cfg.Bedrock.Profile))
+	if cfg.MaxTokens != nil {
+		a.Config.MaxTokens = *cfg.MaxTokens
+	}
+	return a, nil
+}
+
+// newVertex serves Claude models through the anthropic adapter and every
+// other model through the gemini adapter.
+func newVertex(cfg ProviderConfig) (modeladapter.Completer, error) {
+	vc, err := vertexConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if vertex.Publisher(cfg.Model) == vertex.PublisherAnthropic {
+		a := vc.Anthropic(cfg.Model)
+		if cfg.MaxTokens != nil {
+			a.Config.MaxTokens = *cfg.MaxTokens
+		}
+		return a, nil
+	}
+
+	a := vc.Gemini(cfg.Model)
+	if cfg.MaxTokens != nil {
+		a.Config.MaxTokens = *cfg.MaxTokens
+	}
+	return a, nil
+}
+
+func vertexConfig(cfg ProviderConfig) (vertex.Config, error) {
+	vc, err := vertex.NewConfig(cfg.Vertex.Project, cfg.Vertex.Region, cfg.Vertex.CredentialsFile)
+	if err != nil {
+		return vertex.Config{}, fmt.Errorf("engine: provider %q: %w", cfg.Name, err)
+	}
+	vc.BaseURL = cfg.BaseURL
+	return vc, nil
+}
+
+// contextWindowDiscoveryTimeout bounds the startup lookup of a provider's
+// context window.
+const contextWindowDiscoveryTimeout = 5 * time.Second
+
+// discoverContextWindow asks a completer that supports it for its model's
+// context window. It only runs when the config sets neither context_window
+// nor a default_context_windows entry for the kind. Returns 0 when there is
+// nothing to discover or discovery fails; the built-in default then applies.
+func discoverContextWindow(ctx context.Context, cfg ProviderConfig, configDefaults map[string]int, c modeladapter.Completer) int {
+	if cfg.ContextWindow != nil {
+		return 0
+	}
+	if _, ok := configDefaults[cfg.Kind]; ok {
+		return 0
+	}
+	d, ok := unwrapCompleter[modeladapter.ContextWindowDiscoverer](c)
+	if !ok {
+		return 0
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, contextWindowDiscoveryTimeout)
+	defer cancel()
+
+	window, err := d.DiscoverContextWindow(ctx)
+	if err != nil {
+		slog.Warn("engine: context window discovery failed", "provider", cfg.Name, "err", err)
+		return 0
+	}
+
+	if oa, ok := d.(*ollama.Adapter); ok {
+		if info, found := oa.Info(); found && !info.Has(ollama.CapabilityTools) {
+			slog.Warn("engine: model does not support tool calling", "provider", cfg.Name, "model", cfg.Model)
+		}
+	}
+	return window
+}
+
+// unwrapCompleter follows Unwrap chains (e.g. rate limiting) until it finds a
+// completer of type T.
+func unwrapCompleter[T any](c modeladapter.Completer) (T, bool) {
+	for c != nil {
+		if t, ok := c.(T); ok {
+			return t, true
+		}
+		u, ok := c.(interface{ Unwrap() modeladapter.Completer })
+		if !ok {
+			break
+		}
+		c = u.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
 // BatchSubmitterFactory creates a batch.Submitter from a ProviderConfig and
 // the already-built completer (used for request building).
 type BatchSubmitterFactory func(cfg ProviderConfig, completer modeladapter.Completer) (batch.Submitter, error)
@@ -132,6 +272,8 @@ var (
 		"anthropic": newAnthropicBatchSubmitter,
 		"openai":    newOpenAIBatchSubmitter,
 		"grok":      newGrokBatchSubmitter,
+		"bedrock":   newBedrockBatchSubmitter,
+		"vertex":    newVertexBatchSubmitter,
 		// Gemini is excluded: its REST API does not offer a batch endpoint.
 		// Gemini batch is only available via Vertex AI Batch Prediction.
 	}
@@ -171,6 +313,29 @@ func newGrokBatchSubmitter(_ ProviderConfig, completer modeladapter.Completer) (
 	return grok.NewBatchSubmitter(adapter), nil
 }
 
+func newBedrockBatchSubmitter(cfg ProviderConfig, completer modeladapter.Completer) (batch.Submitter, error) {
+	adapter, ok := completer.(*bedrock.Adapter)
+	if !ok {
+		return nil, fmt.Errorf("engine: bedrock batch: completer is not *bedrock.Adapter")
+	}
+	return bedrock.NewBatchSubmitter(adapter, bedrock.BatchOptions{
+		StorageURI: cfg.Batch.StorageURI,
+		RoleARN:    cfg.Batch.RoleARN,
+	})
+}
+
+func newVertexBatchSubmitter(cfg ProviderConfig, completer modeladapter.Completer) (batch.Submitter, error) {
+	mapper, ok := completer.(vertex.RequestMapper)
+	if !ok {
+		return nil, fmt.Errorf("engine: vertex batch: completer does not map batch requests")
+	}
+	vc, err := vertexConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return vertex.NewBatchSubmitter(vc, cfg.Model, mapper, cfg.Batch.StorageURI)
+}
+
 func buildBatchSubmitter(cfg ProviderConfig, completer modeladapter.Completer) (batch.Submitter, error) {
 	batchFactoryMu.RLock()
 	factory, ok := batchFactories[cfg.Kind]
@@ -214,8 +379,9 @@ func parseBatchOpts(cfg BatchConfig) (batch.CollectorOpts, error) {
 // buildCompleter creates a Completer from a ProviderConfig using the registered
 // factory for its Kind. If rate limiting is configured, the completer is wrapped
 // with a RateLimitedCompleter. If batch mode is enabled, the completer is
-// wrapped with a batch Collector before rate limiting.
-func buildCompleter(cfg ProviderConfig) (modeladapter.Completer, error) {
+// wrapped with a batch Collector before rate limiting. rlOptions are passed to
+// the RateLimitedCompleter.
+func buildCompleter(cfg ProviderConfig, rlOptions ...modeladapter.RateLimitOption) (modeladapter.Completer, error) {
 	factory, ok := getFactory(cfg.Kind)
 	if !ok {
 		return nil, fmt.Errorf("engine: unknown provider kind %q", cfg.Kind)
@@ -258,7 +424,7 @@ func buildCompleter(cfg ProviderConfig) (modeladapter.Completer, error) {
 			RPM:        rl.RPM,
 			MaxRetries: rl.MaxRetries,
 			BaseDelay:  baseDelay,
-		})
+		}, rlOptions...)
 	}
 
 	return c, nil
@@ -1,9 +1,23 @@
 package engine
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/message"
 	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/batch"
+	"github.com/germanamz/shelly/pkg/providers/anthropic"
+	"github.com/germanamz/shelly/pkg/providers/bedrock"
+	"github.com/germanamz/shelly/pkg/providers/gemini"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -17,10 +31,14 @@ func resetProviders() {
 	defer factoryMu.Unlock()
 
 	factories = map[string]ProviderFactory{
-		"anthropic": newAnthropic,
-		"openai":    newOpenAI,
-		"grok":      newGrok,
-		"gemini":    newGemini,
+		"anthropic":        newAnthropic,
+		"openai":           newOpenAI,
+		"grok":             newGrok,
+		"gemini":           newGemini,
+		"ollama":           newOllama,
+		"openai_responses": newOpenAIResponses,
+		"bedrock":          newBedrock,
+		"vertex":           newVertex,
 	}
 }
 
@@ -33,6 +51,10 @@ func TestResolveContextWindow_DefaultsForKnownKinds(t *testing.T) {
 		{"openai", 128000},
 		{"grok", 131072},
 		{"gemini", 1048576},
+		{"ollama", 4096},
+		{"openai_responses", 128000},
+		{"bedrock", 200000},
+		{"vertex", 200000},
 	}
 	for _, tt := range tests {
 		t.Run(tt.kind, func(t *testing.T) {
@@ -86,6 +108,8 @@ func TestMaxTokens_AppliedToProvider(t *testing.T) {
 		{"openai", 4096},
 		{"grok", 4096},
 		{"gemini", 8192},
+		{"ollama", 8192},
+		{"openai_responses", 4096},
 	}
 	for _, tt := range tests {
 		t.Run(tt.kind+"_default", func(t *testing.T) {
@@ -109,3 +133,137 @@ func TestMaxTokens_AppliedToProvider(t *testing.T) {
 		})
 	}
 }
+
+// writeVertexCredentials writes an authorized_user credentials file.
+func writeVertexCredentials(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "adc.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"type":"authorized_user","client_id":"c","client_secret":"s","refresh_token":"rt","quota_project_id":"proj"}`), 0o600))
+	return path
+}
+
+func TestBuildCompleter_CloudProviders(t *testing.T) {
+	maxTok := 16384
+	creds := writeVertexCredentials(t)
+
+	t.Run("bedrock", func(t *testing.T) {
+		c, err := buildCompleter(ProviderConfig{Kind: "bedrock", Model: "anthropic.claude-sonnet-4", Bedrock: BedrockConfig{Region: "us-east-1"}})
+		require.NoError(t, err)
+		require.IsType(t, &bedrock.Adapter{}, c)
+		assert.Equal(t, 4096, c.(modeladapter.UsageReporter).ModelMaxTokens())
+	})
+
+	t.Run("bedrock requires a region", func(t *testing.T) {
+		t.Setenv("AWS_REGION", "")
+		t.Setenv("AWS_DEFAULT_REGION", "")
+		_, err := buildCompleter(ProviderConfig{Name: "aws", Kind: "bedrock", Model: "anthropic.claude-sonnet-4"})
+		require.ErrorContains(t, err, "bedrock region is required")
+	})
+
+	t.Run("vertex gemini", func(t *testing.T) {
+		c, err := buildCompleter(ProviderConfig{
+			Kind: "vertex", Model: "gemini-2.5-pro", MaxTokens: &maxTok,
+			Vertex: VertexConfig{Region: "us-central1", CredentialsFile: creds},
+		})
+		require.NoError(t, err)
+		require.IsType(t, &gemini.Adapter{}, c)
+		assert.Equal(t, maxTok, c.(modeladapter.UsageReporter).ModelMaxTokens())
+	})
+
+	t.Run("vertex claude", func(t *testing.T) {
+		c, err := buildCompleter(ProviderConfig{
+			Kind: "vertex", Model: "claude-sonnet-4@20250514",
+			Vertex: VertexConfig{Region: "us-east5", CredentialsFile: creds},
+		})
+		require.NoError(t, err)
+		require.IsType(t, &anthropic.Adapter{}, c)
+	})
+
+	t.Run("vertex requires a region", func(t *testing.T) {
+		t.Setenv("GOOGLE_CLOUD_LOCATION", "")
+		_, err := buildCompleter(ProviderConfig{Name: "gcp", Kind: "vertex", Model: "gemini-2.5-pro", Vertex: VertexConfig{CredentialsFile: creds}})
+		require.ErrorContains(t, err, "region is required")
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		c, err := buildCompleter(ProviderConfig{
+			Kind: "bedrock", Model: "anthropic.claude-sonnet-4", Bedrock: BedrockConfig{Region: "us-east-1"},
+			Batch: BatchConfig{Enabled: true, StorageURI: "s3://bucket/shelly", RoleARN: "arn:aws:iam::1:role/batch"},
+		})
+		require.NoError(t, err)
+		require.IsType(t, &batch.Collector{}, c)
+
+		c, err = buildCompleter(ProviderConfig{
+			Kind: "vertex", Model: "gemini-2.5-flash",
+			Vertex: VertexConfig{Region: "us-central1", CredentialsFile: creds},
+			Batch:  BatchConfig{Enabled: true, StorageURI: "gs://bucket/shelly"},
+		})
+		require.NoError(t, err)
+		require.IsType(t, &batch.Collector{}, c)
+	})
+}
+
+// discoveringCompleter reports a fixed context window through
+// modeladapter.ContextWindowDiscoverer.
+type discoveringCompleter struct {
+	window int
+	err    error
+	calls  int
+}
+
+func (d *discoveringCompleter) Complete(_ context.Context, _ *chat.Chat, _ []toolbox.Tool) (message.Message, error) {
+	return message.Message{}, nil
+}
+
+func (d *discoveringCompleter) DiscoverContextWindow(_ context.Context) (int, error) {
+	d.calls++
+	return d.window, d.err
+}
+
+func TestDiscoverContextWindow(t *testing.T) {
+	t.Run("discovered through wrappers", func(t *testing.T) {
+		d := &discoveringCompleter{window: 32768}
+		c := modeladapter.NewRateLimitedCompleter(d, modeladapter.RateLimitOpts{})
+		assert.Equal(t, 32768, discoverContextWindow(context.Background(), ProviderConfig{Kind: "ollama"}, nil, c))
+	})
+
+	t.Run("explicit context_window skips discovery", func(t *testing.T) {
+		d := &discoveringCompleter{window: 32768}
+		cfg := ProviderConfig{Kind: "ollama", ContextWindow: intPtr(8192)}
+		assert.Equal(t, 0, discoverContextWindow(context.Background(), cfg, nil, d))
+		assert.Zero(t, d.calls)
+	})
+
+	t.Run("kind default skips discovery", func(t *testing.T) {
+		d := &discoveringCompleter{window: 32768}
+		assert.Equal(t, 0, discoverContextWindow(context.Background(), ProviderConfig{Kind: "ollama"}, map[string]int{"ollama": 16384}, d))
+		assert.Zero(t, d.calls)
+	})
+
+	t.Run("failure falls back", func(t *testing.T) {
+		d := &discoveringCompleter{err: errors.New("connection refused")}
+		assert.Equal(t, 0, discoverContextWindow(context.Background(), ProviderConfig{Kind: "ollama"}, nil, d))
+	})
+
+	t.Run("completer without discovery", func(t *testing.T) {
+		assert.Equal(t, 0, discoverContextWindow(context.Background(), ProviderConfig{Kind: "openai"}, nil, workflowCompleter{}))
+	})
+}
+
+func TestNew_OllamaContextWindowDiscovery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/show", r.URL.Path)
+		_, _ = io.WriteString(w, `{"capabilities":["completion","tools"],"model_info":{"qwen2.context_length":131072}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	eng, err := New(context.Background(), Config{
+		ShellyDir: filepath.Join(t.TempDir(), ".shelly"),
+		Providers: []ProviderConfig{{Name: "local", Kind: "ollama", BaseURL: srv.URL, Model: "qwen2.5"}},
+		Agents:    []AgentConfig{{Name: "bot", Provider: "local"}},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = eng.Close() })
+
+	assert.Equal(t, 32768, eng.resolveAgentContextWindow(AgentConfig{Provider: "local"}))
+}
@@ -0,0 +1,325 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/agent"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/sessions"
+)
+
+// ErrQueuedPromptNotFound is returned when a queue operation targets an
+// unknown prompt.
+var ErrQueuedPromptNotFound = fmt.Errorf("engine: queued prompt not found")
+
+// QueueEvent is the data of EventQueueChanged.
+type QueueEvent struct {
+	Queue    []sessions.QueuedPrompt // The queue after the change.
+	Injected []sessions.QueuedPrompt // Interrupt prompts just injected into the running agent.
+}
+
+// queuedPrompt is a queue entry with its attachments, which are kept in
+// memory only.
+type queuedPrompt struct {
+	sessions.QueuedPrompt
+	attachments []content.Part
+}
+
+// promptQueue holds the follow-up prompts of a session. While a Send runs,
+// the session agent's inbox is open (steering) and interrupt prompts are
+// injected into it.
+type promptQueue struct {
+	mu       sync.Mutex
+	items    []queuedPrompt
+	nextID   int
+	steering bool
+}
+
+// snapshot returns the queued prompts. The caller must hold mu.
+func (q *promptQueue) snapshot() []sessions.QueuedPrompt {
+	out := make([]sessions.QueuedPrompt, len(q.items))
+	for i, it := range q.items {
+		out[i] = it.QueuedPrompt
+	}
+	return out
+}
+
+// newID returns the next prompt ID. The caller must hold mu.
+func (q *promptQueue) newID() string {
+	q.nextID++
+	return fmt.Sprintf("q%d", q.nextID)
+}
+
+// index returns the position of the prompt with the given ID. The caller
+// must hold mu.
+func (q *promptQueue) index(id string) (int, error) {
+	i := slices.IndexFunc(q.items, func(it queuedPrompt) bool { return it.ID == id })
+	if i < 0 {
+		return -1, fmt.Errorf("%w: %s", ErrQueuedPromptNotFound, id)
+	}
+	return i, nil
+}
+
+// restore replaces the queue with persisted prompts, giving them fresh IDs.
+func (q *promptQueue) restore(prompts []sessions.QueuedPrompt) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.items = q.items[:0]
+	for _, p := range prompts {
+		p.ID = q.newID()
+		q.items = append(q.items, queuedPrompt{QueuedPrompt: p})
+	}
+}
+
+// Enqueue adds a follow-up prompt to the end of the session's queue and
+// returns it. Interrupt prompts are injected into the running agent at its
+// next iteration; after-turn prompts wait for PopQueued. Attachments are
+// sent with the prompt but not persisted.
+func (s *Session) Enqueue(mode sessions.QueueMode, text string, attachments ...content.Part) (sessions.QueuedPrompt, error) {
+	if err := validQueueMode(mode); err != nil {
+		return sessions.QueuedPrompt{}, err
+	}
+	if text == "" && len(attachments) == 0 {
+		return sessions.QueuedPrompt{}, fmt.Errorf("engine: queue: empty prompt")
+	}
+
+	s.queue.mu.Lock()
+	p := sessions.QueuedPrompt{ID: s.queue.newID(), Text: text, Mode: mode, CreatedAt: time.Now()}
+	s.queue.items = append(s.queue.items, queuedPrompt{QueuedPrompt: p, attachments: attachments})
+	s.queueChanged()
+	s.queue.mu.Unlock()
+
+	return p, nil
+}
+
+// Queue returns the session's queued prompts in delivery order.
+func (s *Session) Queue() []sessions.QueuedPrompt {
+	s.queue.mu.Lock()
+	defer s.queue.mu.Unlock()
+	return s.queue.snapshot()
+}
+
+// EditQueued replaces the text of a queued prompt.
+func (s *Session) EditQueued(id, text string) error {
+	return s.updateQueue(id, func(i int) error {
+		if text == "" && len(s.queue.items[i].attachments) == 0 {
+			return fmt.Errorf("engine: queue: empty prompt")
+		}
+		s.queue.items[i].Text = text
+		return nil
+	})
+}
+
+// SetQueuedMode changes when a queued prompt is delivered.
+func (s *Session) SetQueuedMode(id string, mode sessions.QueueMode) error {
+	if err := validQueueMode(mode); err != nil {
+		return err
+	}
+	return s.updateQueue(id, func(i int) error {
+		s.queue.items[i].Mode = mode
+		return nil
+	})
+}
+
+// MoveQueued moves a queued prompt by delta positions (negative = earlier),
+// stopping at either end of the queue.
+func (s *Session) MoveQueued(id string, delta int) error {
+	return s.updateQueue(id, func(i int) error {
+		j := max(0, min(i+delta, len(s.queue.items)-1))
+		it := s.queue.items[i]
+		s.queue.items = slices.Insert(slices.Delete(s.queue.items, i, i+1), j, it)
+		return nil
+	})
+}
+
+// RemoveQueued drops a queued prompt.
+func (s *Session) RemoveQueued(id string) error {
+	return s.updateQueue(id, func(i int) error {
+		s.queue.items = slices.Delete(s.queue.items, i, i+1)
+		return nil
+	})
+}
+
+// PopQueued removes and returns the first queued prompt, whatever its mode,
+// with its attachments. Frontends call it when a turn completes to send the
+// next prompt.
+func (s *Session) PopQueued() (sessions.QueuedPrompt, []content.Part, bool) {
+	s.queue.mu.Lock()
+	defer s.queue.mu.Unlock()
+
+	if len(s.queue.items) == 0 {
+		return sessions.QueuedPrompt{}, nil, false
+	}
+	it := s.queue.items[0]
+	s.queue.items = slices.Delete(s.queue.items, 0, 1)
+	s.queueChanged()
+	return it.QueuedPrompt, it.attachments, true
+}
+
+// updateQueue applies fn to the prompt with the given ID and publishes the
+// change.
+func (s *Session) updateQueue(id string, fn func(i int) error) error {
+	s.queue.mu.Lock()
+	defer s.queue.mu.Unlock()
+
+	i, err := s.queue.index(id)
+	if err != nil {
+		return err
+	}
+	if err := fn(i); err != nil {
+		return err
+	}
+	s.queueChanged()
+	return nil
+}
+
+// queueChanged injects pending interrupt prompts and publishes the queue.
+// The caller must hold queue.mu.
+func (s *Session) queueChanged() {
+	s.publishQueue(s.injectInterrupts())
+}
+
+// publishQueue publishes the queue with the prompts just injected, if any.
+// The caller must hold queue.mu.
+func (s *Session) publishQueue(injected []sessions.QueuedPrompt) {
+	s.events.publish(EventQueueChanged, s.id, s.agent.Name(), QueueEvent{Queue: s.queue.snapshot(), Injected: injected})
+}
+
+// injectInterrupts sends all queued interrupt prompts to the running session
+// agent as one user message via SendToAgent. They stay queued while the
+// agent is idle or still has an unread message. The caller must hold
+// queue.mu.
+func (s *Session) injectInterrupts() []sessions.QueuedPrompt {
+	if !s.queue.steering {
+		return nil
+	}
+
+	var injected []sessions.QueuedPrompt
+	var parts []content.Part
+	for _, it := range s.queue.items {
+		if it.Mode != sessions.QueueInterrupt {
+			continue
+		}
+		injected = append(injected, it.QueuedPrompt)
+		if it.Text != "" {
+			parts = append(parts, content.Text{Text: it.Text})
+		}
+		parts = append(parts, it.attachments...)
+	}
+	if len(injected) == 0 {
+		return nil
+	}
+
+	if err := s.lifecycle.SendToAgent(s.id, s.agent.Name(), message.New("user", role.User, parts...)); err != nil {
+		return nil
+	}
+	s.queue.items = slices.DeleteFunc(s.queue.items, func(it queuedPrompt) bool { return it.Mode == sessions.QueueInterrupt })
+	return injected
+}
+
+// deliverInterrupts retries injecting interrupt prompts. interruptEffect
+// calls it before each of the session agent's completions, after the agent
+// has read its inbox.
+func (s *Session) deliverInterrupts() {
+	s.queue.mu.Lock()
+	defer s.queue.mu.Unlock()
+
+	if injected := s.injectInterrupts(); len(injected) > 0 {
+		s.publishQueue(injected)
+	}
+}
+
+// interruptEffect delivers the session's queued interrupt prompts from the
+// session agent's loop, at PhaseBeforeComplete.
+type interruptEffect struct{ s *Session }
+
+// Eval implements agent.Effect.
+func (e interruptEffect) Eval(_ context.Context, ic agent.IterationContext) error {
+	if ic.Phase == agent.PhaseBeforeComplete {
+		e.s.deliverInterrupts()
+	}
+	return nil
+}
+
+// setAgent makes a the session agent and attaches interruptEffect to it.
+func (s *Session) setAgent(a *agent.Agent) {
+	a.AddEffects(interruptEffect{s})
+	s.agent = a
+}
+
+// runSteered runs the session agent with its inbox registered so that
+// interrupt prompts reach it at its next iteration. A prompt that arrives
+// after the agent's last iteration starts another run rather than being
+// dropped; one left unread by a failed run goes back to the front of the
+// queue.
+func (s *Session) runSteered(ctx context.Context) (message.Message, error) {
+	a := s.agent
+	a.InitInbox()
+	for {
+		s.setSteering(true)
+		reply, err := a.Run(ctx)
+		s.setSteering(false)
+
+		select {
+		case msg := <-a.Inbox():
+			if err != nil {
+				s.requeue(msg)
+				return reply, err
+			}
+			a.Chat().Append(msg)
+		default:
+			return reply, err
+		}
+	}
+}
+
+// setSteering registers or unregisters the session agent's inbox. Opening it
+// injects interrupt prompts queued while the agent was idle.
+func (s *Session) setSteering(on bool) {
+	s.queue.mu.Lock()
+	defer s.queue.mu.Unlock()
+
+	s.queue.steering = on
+	if !on {
+		s.lifecycle.UnregisterAgentInbox(s.id, s.agent.Name())
+		return
+	}
+	s.lifecycle.RegisterAgentInbox(s.id, s.agent.Name(), s.agent.Inbox())
+	if injected := s.injectInterrupts(); len(injected) > 0 {
+		s.publishQueue(injected)
+	}
+}
+
+// requeue puts an unread inbox message back at the front of the queue as an
+// after-turn prompt.
+func (s *Session) requeue(msg message.Message) {
+	var attachments []content.Part
+	for _, p := range msg.Parts {
+		if _, ok := p.(content.Text); !ok {
+			attachments = append(attachments, p)
+		}
+	}
+
+	s.queue.mu.Lock()
+	defer s.queue.mu.Unlock()
+
+	p := sessions.QueuedPrompt{ID: s.queue.newID(), Text: msg.TextContent(), Mode: sessions.QueueAfterTurn, CreatedAt: time.Now()}
+	s.queue.items = slices.Insert(s.queue.items, 0, queuedPrompt{QueuedPrompt: p, attachments: attachments})
+	s.publishQueue(nil)
+}
+
+func validQueueMode(mode sessions.QueueMode) error {
+	switch mode {
+	case sessions.QueueAfterTurn, sessions.QueueInterrupt:
+		return nil
+	default:
+		return fmt.Errorf("engine: queue: unknown mode %q", mode)
+	}
+}
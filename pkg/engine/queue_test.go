@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/sessions"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// steerCompleter records the last message of each call. Its first call
+// waits for release so that prompts can be queued while the agent runs.
+type steerCompleter struct {
+	mu      sync.Mutex
+	seen    []string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *steerCompleter) Complete(_ context.Context, ch *chat.Chat, _ []toolbox.Tool) (message.Message, error) {
+	last, _ := ch.Last()
+	c.mu.Lock()
+	c.seen = append(c.seen, last.TextContent())
+	first := len(c.seen) == 1
+	c.mu.Unlock()
+
+	if first {
+		close(c.started)
+		<-c.release
+	}
+	return message.NewText("bot", role.Assistant, "ok"), nil
+}
+
+func texts(q []sessions.QueuedPrompt) []string {
+	var out []string
+	for _, p := range q {
+		out = append(out, p.Text)
+	}
+	return out
+}
+
+func TestSession_Queue(t *testing.T) {
+	eng, _ := newBudgetEngine(t, &pricedCompleter{}, BudgetConfig{})
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+
+	sub := eng.Events().Subscribe(64)
+	defer eng.Events().Unsubscribe(sub)
+
+	a, err := sess.Enqueue(sessions.QueueAfterTurn, "one")
+	require.NoError(t, err)
+	b, err := sess.Enqueue(sessions.QueueAfterTurn, "two")
+	require.NoError(t, err)
+	c, err := sess.Enqueue(sessions.QueueInterrupt, "three")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one", "two", "three"}, texts(sess.Queue()))
+
+	ev := <-sub.C
+	assert.Equal(t, EventQueueChanged, ev.Kind)
+	assert.Equal(t, []string{"one"}, texts(ev.Data.(QueueEvent).Queue))
+
+	require.NoError(t, sess.MoveQueued(c.ID, -5))
+	require.NoError(t, sess.MoveQueued(a.ID, 1))
+	assert.Equal(t, []string{"three", "two", "one"}, texts(sess.Queue()))
+
+	require.NoError(t, sess.EditQueued(b.ID, "two!"))
+	require.NoError(t, sess.SetQueuedMode(b.ID, sessions.QueueInterrupt))
+	require.NoError(t, sess.RemoveQueued(c.ID))
+	assert.Equal(t, []sessions.QueueMode{sessions.QueueInterrupt, sessions.QueueAfterTurn}, []sessions.QueueMode{sess.Queue()[0].Mode, sess.Queue()[1].Mode})
+
+	require.ErrorIs(t, sess.RemoveQueued(c.ID), ErrQueuedPromptNotFound)
+	require.Error(t, sess.EditQueued(b.ID, ""))
+	require.Error(t, sess.SetQueuedMode(b.ID, "later"))
+	_, err = sess.Enqueue(sessions.QueueAfterTurn, "")
+	require.Error(t, err)
+
+	// Prompts are popped in order, whatever their mode.
+	p, _, ok := sess.PopQueued()
+	require.True(t, ok)
+	assert.Equal(t, "two!", p.Text)
+
+	// The queue is saved with the session and restored on resume.
+	require.NoError(t, eng.SaveSession(sess))
+	resumed, err := eng.ResumeSession(sess.PersistID())
+	require.NoError(t, err)
+	require.Len(t, resumed.Queue(), 1)
+	assert.Equal(t, "one", resumed.Queue()[0].Text)
+	assert.Equal(t, sessions.QueueAfterTurn, resumed.Queue()[0].Mode)
+
+	p, _, ok = resumed.PopQueued()
+	require.True(t, ok)
+	assert.Equal(t, "one", p.Text)
+	_, _, ok = resumed.PopQueued()
+	assert.False(t, ok)
+}
+
+func TestSession_Queue_Interrupt(t *testing.T) {
+	c := &steerCompleter{started: make(chan struct{}), release: make(chan struct{})}
+	eng, _ := newBudgetEngine(t, c, BudgetConfig{})
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := sess.Send(context.Background(), "start")
+		done <- err
+	}()
+	<-c.started
+
+	// The first prompt fills the agent's inbox; the second waits for it to
+	// be read. After-turn prompts are left for the frontend.
+	_, err = sess.Enqueue(sessions.QueueInterrupt, "first")
+	require.NoError(t, err)
+	_, err = sess.Enqueue(sessions.QueueInterrupt, "second")
+	require.NoError(t, err)
+	_, err = sess.Enqueue(sessions.QueueAfterTurn, "later")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"second", "later"}, texts(sess.Queue()))
+
+	close(c.release)
+	require.NoError(t, <-done)
+
+	// Prompts read after the agent's final reply continue the same Send.
+	assert.Equal(t, []string{"start", "first", "second"}, c.seen)
+	assert.Equal(t, []string{"later"}, texts(sess.Queue()))
+
+	assert.ErrorIs(t, eng.SendToAgent(sess.ID(), sess.AgentName(), message.NewText("user", role.User, "late")), ErrAgentNotFound, "the inbox is closed between turns")
+}
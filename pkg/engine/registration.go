@@ -8,7 +8,10 @@ import (
 	"time"
 
 	"github.com/germanamz/shelly/pkg/agent"
+	"github.com/germanamz/shelly/pkg/agent/effects"
+	"github.com/germanamz/shelly/pkg/hooks"
 	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/projectctx"
 	"github.com/germanamz/shelly/pkg/skill"
 	"github.com/germanamz/shelly/pkg/tools/toolbox"
 )
@@ -54,12 +57,18 @@ type registrationContext struct {
 	identity        agentIdentity
 	completer       modeladapter.Completer
 	usageDiffLock   *sync.Mutex // shared lock for per-agent usage tracking
+	tokens          providerTokens
 	tooling         agentTooling
 	effects         effectSetup
 	events          agentEvents
 	contextStr      string
+	nestedCtx       *projectctx.NestedLoader
+	hooks           *hooks.Runner
+	budget          *budgetEffect // template copied per agent instance; nil when budgets are off
+	usage           *usageEffect  // template copied per agent instance
 	contextWindow   int
 	reflectionDir   string
+	memoryRecall    agent.MemoryRecall // nil when the agent has no memory toolbox
 	maxIter         int
 	warnIter        int
 	maxDepth        int
@@ -144,7 +153,8 @@ func (e *Engine) buildRegistrationContext(ac AgentConfig) (registrationContext,
 		taskBoard = &taskBoardAdapter{store: e.taskStore}
 	}
 
-	providerLabel := e.resolveProviderInfo(ac.Name).Label()
+	providerName := e.agentProviderName(ac.Provider)
+	providerLabel := e.providerInfo(providerName).Label()
 
 	card := buildAgentCard(ac)
 
@@ -153,8 +163,6 @@ func (e *Engine) buildRegistrationContext(ac AgentConfig) (registrationContext,
 		questionTimeout, _ = time.ParseDuration(ac.Options.QuestionTimeout) // already validated
 	}
 
-	providerName := e.agentProviderName(ac.Provider)
-
 	return registrationContext{
 		identity: agentIdentity{
 			name:          ac.Name,
@@ -165,6 +173,7 @@ func (e *Engine) buildRegistrationContext(ac AgentConfig) (registrationContext,
 		},
 		completer:     completer,
 		usageDiffLock: e.resolveUsageDiffLock(providerName),
+		tokens:        e.tokens[providerName],
 		tooling: agentTooling{
 			toolboxes: tbs,
 			skills:    skills,
@@ -179,12 +188,17 @@ func (e *Engine) buildRegistrationContext(ac AgentConfig) (registrationContext,
 			eventFunc:         e.buildAgentEventFunc(),
 			cancelRegistrar:   agent.CancelRegistrar(e.RegisterAgentCancel),
 			cancelUnregistrar: agent.CancelUnregistrar(e.UnregisterAgentCancel),
-			inboxRegistrar:    agent.InboxRegistrar(e.RegisterAgentInbox),
-			inboxUnregistrar:  agent.InboxUnregistrar(e.UnregisterAgentInbox),
+			inboxRegistrar:    e.registerChildInbox,
+			inboxUnregistrar:  e.unregisterChildInbox,
 		},
 		contextStr:      e.projectCtx.String(),
+		nestedCtx:       e.nestedCtx,
+		hooks:           e.hooks,
+		budget:          e.newBudgetEffect(ac.Name, providerName),
+		usage:           e.newUsageEffect(providerName),
 		contextWindow:   contextWindow,
 		reflectionDir:   reflectionDir,
+		memoryRecall:    e.memoryRecall(ac),
 		maxIter:         ac.Options.MaxIterations,
 		warnIter:        ac.Options.WarnIterations,
 		maxDepth:        ac.Options.MaxDelegationDepth,
@@ -218,7 +232,8 @@ func (e *Engine) resolveCompleter(ac AgentConfig) (modeladapter.Completer, error
 	return completer, nil
 }
 
-// collectToolboxes gathers the agent's declared toolboxes (always including ask).
+// collectToolboxes gathers the agent's declared toolboxes (always including
+// ask), applying each reference's tool whitelist and strict tools.
 func (e *Engine) collectToolboxes(ac AgentConfig) ([]*toolbox.ToolBox, error) {
 	var tbs []*toolbox.ToolBox
 	if askTB, ok := e.toolboxes["ask"]; ok {
@@ -236,7 +251,7 @@ func (e *Engine) collectToolboxes(ac AgentConfig) ([]*toolbox.ToolBox, error) {
 		if !ok {
 			return nil, fmt.Errorf("engine: agent %q: toolbox %q not found", ac.Name, ref.Name)
 		}
-		tbs = append(tbs, tb.Filter(ref.Tools))
+		tbs = append(tbs, tb.Filter(ref.Tools).Strict(ref.Strict))
 	}
 	return tbs, nil
 }
@@ -275,6 +290,9 @@ func (e *Engine) resolveAgentContextWindow(ac AgentConfig) int {
 	providerName := e.agentProviderName(ac.Provider)
 	for _, pc := range e.cfg.Providers {
 		if pc.Name == providerName {
+			if w, ok := e.contextWindows[pc.Name]; ok {
+				return w
+			}
 			return resolveContextWindow(pc, e.cfg.DefaultContextWindows)
 		}
 	}
@@ -305,6 +323,11 @@ func (e *Engine) buildAgentEventNotifier() agent.EventNotifier {
 		switch kind {
 		case "agent_start":
 			ek = EventAgentStart
+			if d, ok := data.(agent.AgentEventData); ok && d.Parent != "" {
+				if s, ok := e.Session(sid); ok {
+					s.usage.setParent(agentName, d.Parent)
+				}
+			}
 		case "agent_end":
 			ek = EventAgentEnd
 		case "delegation_progress":
@@ -326,6 +349,10 @@ func (e *Engine) buildAgentEventFunc() agent.EventFunc {
 			ek = EventToolCallStart
 		case "tool_call_end":
 			ek = EventToolCallEnd
+		case "tool_args_repaired":
+			ek = EventToolArgsRepaired
+		case "tool_args_invalid":
+			ek = EventToolArgsInvalid
 		case "message_added":
 			ek = EventMessageAdded
 		default:
@@ -345,44 +372,68 @@ func (e *Engine) registerFactory(rc registrationContext) error {
 		EstimatedCost:  rc.agentCard.estimatedCost,
 		MaxConcurrency: rc.agentCard.maxConcurrency,
 	}
-	e.registry.RegisterEntry(entry, func() *agent.Agent {
-		// Build fresh effects for each agent instance so stateful effects
-		// (e.g. SlidingWindowEffect, ReflectionEffect, LoopDetectEffect)
-		// are not shared across agents created by the same factory.
-		agentEffects, bErr := buildEffects(rc.effects.configs, rc.effects.wiringCtx)
-		if bErr != nil {
-			panic(fmt.Sprintf("engine: agent %q: buildEffects failed after validation: %v", rc.identity.name, bErr))
-		}
+	e.registry.RegisterEntry(entry, func() *agent.Agent { return newAgent(rc) })
 
-		opts := agent.Options{
-			MaxIterations:      rc.maxIter,
-			WarnIterations:     rc.warnIter,
-			MaxDelegationDepth: rc.maxDepth,
-			MaxHandoffs:        rc.maxHandoffs,
-			Skills:             rc.tooling.skills,
-			Effects:            agentEffects,
-			Context:            rc.contextStr,
-			EventNotifier:      rc.events.notifier,
-			EventFunc:          rc.events.eventFunc,
-			CancelRegistrar:    rc.events.cancelRegistrar,
-			CancelUnregistrar:  rc.events.cancelUnregistrar,
-			InboxRegistrar:     rc.events.inboxRegistrar,
-			InboxUnregistrar:   rc.events.inboxUnregistrar,
-			ReflectionDir:      rc.reflectionDir,
-			Prefix:             rc.identity.prefix,
-			ProviderLabel:      rc.identity.providerLabel,
-			TaskBoard:          rc.tooling.taskBoard,
-			InteractionMode:    rc.interactionMode,
-			QuestionTimeout:    rc.questionTimeout,
-			UsageDiffLock:      rc.usageDiffLock,
-		}
+	return nil
+}
 
-		a := agent.New(rc.identity.name, rc.identity.desc, rc.identity.instr, rc.completer, opts)
-		a.AddToolBoxes(rc.tooling.toolboxes...)
-		return a
-	})
+// newAgent builds an agent instance from its registration context.
+func newAgent(rc registrationContext) *agent.Agent {
+	// Build fresh effects for each agent instance so stateful effects
+	// (e.g. SlidingWindowEffect, ReflectionEffect, LoopDetectEffect)
+	// are not shared across agents created by the same factory.
+	agentEffects, bErr := buildEffects(rc.effects.configs, rc.effects.wiringCtx)
+	if bErr != nil {
+		panic(fmt.Sprintf("engine: agent %q: buildEffects failed after validation: %v", rc.identity.name, bErr))
+	}
+	if rc.nestedCtx != nil {
+		agentEffects = append(agentEffects, effects.NewNestedContextEffect(effects.NestedContextConfig{
+			Loader: rc.nestedCtx,
+		}))
+	}
+	if rc.budget != nil {
+		b := *rc.budget
+		agentEffects = append(agentEffects, &b)
+	}
+	if rc.usage != nil {
+		u := *rc.usage
+		agentEffects = append(agentEffects, &u)
+	}
 
-	return nil
+	opts := agent.Options{
+		MaxIterations:      rc.maxIter,
+		WarnIterations:     rc.warnIter,
+		MaxDelegationDepth: rc.maxDepth,
+		MaxHandoffs:        rc.maxHandoffs,
+		Skills:             rc.tooling.skills,
+		Effects:            agentEffects,
+		Context:            rc.contextStr,
+		EventNotifier:      rc.events.notifier,
+		EventFunc:          rc.events.eventFunc,
+		CancelRegistrar:    rc.events.cancelRegistrar,
+		CancelUnregistrar:  rc.events.cancelUnregistrar,
+		InboxRegistrar:     rc.events.inboxRegistrar,
+		InboxUnregistrar:   rc.events.inboxUnregistrar,
+		ReflectionDir:      rc.reflectionDir,
+		Prefix:             rc.identity.prefix,
+		ProviderLabel:      rc.identity.providerLabel,
+		TaskBoard:          rc.tooling.taskBoard,
+		InteractionMode:    rc.interactionMode,
+		QuestionTimeout:    rc.questionTimeout,
+		UsageDiffLock:      rc.usageDiffLock,
+		TokenEstimator:     rc.tokens.estimator,
+		TokenCalibration:   rc.tokens.calibration,
+		TokenCounter:       rc.tokens.counter,
+		MemoryRecall:       rc.memoryRecall,
+	}
+	if rc.hooks != nil {
+		opts.Middleware = []agent.Middleware{agentHookMiddleware(rc.hooks)}
+		opts.ToolMiddleware = []agent.ToolMiddleware{toolHookMiddleware(rc.hooks)}
+	}
+
+	a := agent.New(rc.identity.name, rc.identity.desc, rc.identity.instr, rc.completer, opts)
+	a.AddToolBoxes(rc.tooling.toolboxes...)
+	return a
 }
 
 // buildAgentCard extracts agent card fields from the AgentConfig.
@@ -0,0 +1,45 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/filesystem"
+)
+
+// RespondReview delivers the user's decision for a pending file change review
+// (see EventFileReview).
+func (s *Session) RespondReview(reviewID string, d filesystem.Decision) error {
+	return s.reviewer.Respond(reviewID, d)
+}
+
+// reviewStaged reviews the file edits staged during a turn in end_of_turn
+// review mode. The outcome is kept for the model and sent with the next
+// prompt, since the turn that made the edits has already ended.
+func (s *Session) reviewStaged(ctx context.Context, st *filesystem.Staging) {
+	if st.Len() == 0 {
+		return
+	}
+	if note := s.lifecycle.reviewStaged(ctx, st); note != "" {
+		s.reviewNote = note
+	}
+}
+
+// withReviewNote prepends the pending review note, if any, to the parts of a
+// prompt and clears it.
+func (s *Session) withReviewNote(parts []content.Part) []content.Part {
+	if s.reviewNote == "" {
+		return parts
+	}
+	note := content.Text{Text: s.reviewNote}
+	s.reviewNote = ""
+	return append([]content.Part{note}, parts...)
+}
+
+// reviewStaged passes staged edits to the filesystem toolbox for review.
+func (e *Engine) reviewStaged(ctx context.Context, st *filesystem.Staging) string {
+	if e.fs == nil {
+		return ""
+	}
+	return e.fs.ReviewStaged(ctx, st)
+}
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/ask"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/filesystem"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writingCompleter answers a prompt naming a path by writing "hello" to it
+// with fs_write, and a tool result with "done". It records the prompts.
+type writingCompleter struct{ prompts []string }
+
+func (w *writingCompleter) Complete(_ context.Context, ch *chat.Chat, _ []toolbox.Tool) (message.Message, error) {
+	last, _ := ch.Last()
+	if last.Role == role.Tool {
+		return message.NewText("bot", role.Assistant, "done: "+last.Parts[0].(content.ToolResult).Content), nil
+	}
+
+	w.prompts = append(w.prompts, last.TextContent())
+	var parts []content.Part
+	for _, p := range last.Parts {
+		if t, ok := p.(content.Text); ok {
+			parts = append(parts, t)
+		}
+	}
+	args, _ := json.Marshal(map[string]string{"path": parts[len(parts)-1].(content.Text).Text, "content": "hello\n"})
+	return message.New("bot", role.Assistant, content.ToolCall{ID: "c1", Name: "fs_write", Arguments: string(args)}), nil
+}
+
+// newReviewEngine creates an engine whose agent uses the filesystem toolbox
+// in the given review mode. Permission prompts are approved and reviews are
+// answered with decide.
+func newReviewEngine(t *testing.T, mode string, decide func(filesystem.Change) filesystem.Decision) (*Engine, *writingCompleter) {
+	t.Helper()
+
+	w := &writingCompleter{}
+	eng := newTestEngine(t, "writing", fixedProvider(w), Config{
+		Providers:  []ProviderConfig{{Name: "p1", Kind: "writing", Model: "test-model"}},
+		Agents:     []AgentConfig{{Name: "bot", Provider: "p1", Toolboxes: []ToolboxRef{{Name: "filesystem"}}}},
+		Filesystem: FilesystemConfig{Review: mode},
+	})
+
+	sub := eng.Events().Subscribe(64)
+	go func() {
+		for ev := range sub.C {
+			sess, ok := eng.Session(ev.SessionID)
+			if !ok {
+				continue
+			}
+			switch d := ev.Data.(type) {
+			case ask.Question:
+				_ = sess.Respond(d.ID, "yes")
+			case filesystem.ReviewRequest:
+				_ = sess.RespondReview(d.ID, decide(d.Change))
+			}
+		}
+	}()
+	t.Cleanup(func() { eng.Events().Unsubscribe(sub) })
+
+	return eng, w
+}
+
+func TestSession_ReviewEach(t *testing.T) {
+	eng, _ := newReviewEngine(t, filesystem.ReviewEach, func(c filesystem.Change) filesystem.Decision {
+		return filesystem.RejectAll(c, "use a greeting constant")
+	})
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	reply, err := sess.Send(context.Background(), path)
+	require.NoError(t, err)
+
+	assert.Contains(t, reply.TextContent(), "change rejected by reviewer")
+	assert.Contains(t, reply.TextContent(), "use a greeting constant")
+	assert.NoFileExists(t, path)
+}
+
+func TestSession_ReviewEndOfTurn(t *testing.T) {
+	eng, w := newReviewEngine(t, filesystem.ReviewEndOfTurn, func(c filesystem.Change) filesystem.Decision {
+		return filesystem.RejectAll(c, "not now")
+	})
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	reply, err := sess.Send(context.Background(), path)
+	require.NoError(t, err)
+	assert.Contains(t, reply.TextContent(), "staged for review")
+	assert.NoFileExists(t, path)
+
+	// The outcome reaches the model with the next prompt.
+	_, err = sess.Send(context.Background(), filepath.Join(t.TempDir(), "b.txt"))
+	require.NoError(t, err)
+	require.Len(t, w.prompts, 2)
+	assert.Contains(t, w.prompts[1], "Review of the file edits staged during the previous turn")
+	assert.Contains(t, w.prompts[1], `"comment":"not now"`)
+}
+
+func TestSession_ReviewEndOfTurnAccepted(t *testing.T) {
+	eng, w := newReviewEngine(t, filesystem.ReviewEndOfTurn, filesystem.AcceptAll)
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	_, err = sess.Send(context.Background(), path)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path) //nolint:gosec // test file
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(data))
+
+	_, err = sess.Send(context.Background(), path)
+	require.NoError(t, err)
+	assert.NotContains(t, w.prompts[1], "Review of the file edits")
+}
@@ -11,21 +11,21 @@ import (
 
 func TestSendToAgent_NotFound(t *testing.T) {
 	e := &Engine{
-		agentInboxes: make(map[string]chan message.Message),
+		agentInboxes: make(map[inboxKey]chan message.Message),
 	}
-	err := e.SendToAgent("nonexistent", message.NewText("user", role.User, "hello"))
+	err := e.SendToAgent("s1", "nonexistent", message.NewText("user", role.User, "hello"))
 	assert.ErrorIs(t, err, ErrAgentNotFound)
 }
 
 func TestSendToAgent_Success(t *testing.T) {
 	inbox := make(chan message.Message, 1)
 	e := &Engine{
-		agentInboxes: make(map[string]chan message.Message),
+		agentInboxes: make(map[inboxKey]chan message.Message),
 	}
-	e.RegisterAgentInbox("agent-1", inbox)
+	e.RegisterAgentInbox("s1", "agent-1", inbox)
 
 	msg := message.NewText("user", role.User, "hello")
-	err := e.SendToAgent("agent-1", msg)
+	err := e.SendToAgent("s1", "agent-1", msg)
 	require.NoError(t, err)
 
 	received := <-inbox
@@ -35,30 +35,48 @@ func TestSendToAgent_Success(t *testing.T) {
 func TestSendToAgent_InboxFull(t *testing.T) {
 	inbox := make(chan message.Message, 1)
 	e := &Engine{
-		agentInboxes: make(map[string]chan message.Message),
+		agentInboxes: make(map[inboxKey]chan message.Message),
 	}
-	e.RegisterAgentInbox("agent-1", inbox)
+	e.RegisterAgentInbox("s1", "agent-1", inbox)
 
 	// Fill the inbox.
 	msg1 := message.NewText("user", role.User, "first")
-	require.NoError(t, e.SendToAgent("agent-1", msg1))
+	require.NoError(t, e.SendToAgent("s1", "agent-1", msg1))
 
 	// Second send should fail.
 	msg2 := message.NewText("user", role.User, "second")
-	err := e.SendToAgent("agent-1", msg2)
+	err := e.SendToAgent("s1", "agent-1", msg2)
 	assert.ErrorIs(t, err, ErrAgentInboxFull)
 }
 
 func TestRegisterUnregisterAgentInbox(t *testing.T) {
 	inbox := make(chan message.Message, 1)
 	e := &Engine{
-		agentInboxes: make(map[string]chan message.Message),
+		agentInboxes: make(map[inboxKey]chan message.Message),
 	}
 
-	e.RegisterAgentInbox("agent-1", inbox)
-	require.NoError(t, e.SendToAgent("agent-1", message.NewText("user", role.User, "hello")))
+	e.RegisterAgentInbox("s1", "agent-1", inbox)
+	require.NoError(t, e.SendToAgent("s1", "agent-1", message.NewText("user", role.User, "hello")))
 
-	e.UnregisterAgentInbox("agent-1")
-	err := e.SendToAgent("agent-1", message.NewText("user", role.User, "hello"))
+	e.UnregisterAgentInbox("s1", "agent-1")
+	err := e.SendToAgent("s1", "agent-1", message.NewText("user", role.User, "hello"))
 	assert.ErrorIs(t, err, ErrAgentNotFound)
 }
+
+func TestSendToAgent_ScopedBySession(t *testing.T) {
+	e := &Engine{
+		agentInboxes: make(map[inboxKey]chan message.Message),
+	}
+	inbox1 := make(chan message.Message, 1)
+	inbox2 := make(chan message.Message, 1)
+	e.RegisterAgentInbox("s1", "coder", inbox1)
+	e.RegisterAgentInbox("s2", "coder", inbox2)
+
+	require.NoError(t, e.SendToAgent("s2", "coder", message.NewText("user", role.User, "for s2")))
+	assert.Empty(t, inbox1)
+	assert.Equal(t, "for s2", (<-inbox2).TextContent())
+
+	e.UnregisterAgentInbox("s1", "coder")
+	assert.ErrorIs(t, e.SendToAgent("s1", "coder", message.NewText("user", role.User, "hi")), ErrAgentNotFound)
+	require.NoError(t, e.SendToAgent("s2", "coder", message.NewText("user", role.User, "still open")))
+}
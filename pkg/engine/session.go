@@ -2,8 +2,9 @@ package engine
 
 import (
 	"context"
-	"crypto/rand"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,7 +17,10 @@ import (
 	"github.com/germanamz/shelly/pkg/chats/role"
 	"github.com/germanamz/shelly/pkg/codingtoolbox/ask"
 	"github.com/germanamz/shelly/pkg/codingtoolbox/filesystem"
+	"github.com/germanamz/shelly/pkg/hooks"
 	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/usage"
+	"github.com/germanamz/shelly/pkg/sessions"
 )
 
 // ProviderInfo holds the provider kind and model for display purposes.
@@ -40,38 +44,43 @@ type Session struct {
 	persistID    string
 	createdAt    time.Time
 	agent        *agent.Agent
+	providerName string // provider config name
 	providerInfo ProviderInfo
+	switches     []sessions.Switch
+	usage        usageLedger
 	lifecycle    sessionLifecycle
 	events       *EventBus
 	responder    *ask.Responder
+	reviewer     *filesystem.Reviewer
 	sessionTrust *filesystem.SessionTrust
+	reviewNote   string // outcome of the last end-of-turn review, sent with the next prompt
+	hooks        *hooks.Runner
+	trigger      string // daemon trigger that started the session, persisted with it
+	queue        promptQueue
 
 	onSendComplete func()
 
-	mu     sync.Mutex
-	active bool
-}
-
-// generatePersistID returns a random 16-character hex string for session persistence.
-func generatePersistID() string {
-	var buf [8]byte
-	_, _ = rand.Read(buf[:])
-	return fmt.Sprintf("%x", buf)
+	mu      sync.Mutex
+	active  bool
+	stopRun context.CancelCauseFunc // cancels the active Send; nil when idle
 }
 
 // newSession creates a session with the given ID, agent, lifecycle coordinator,
-// event bus, and responder.
-func newSession(id string, a *agent.Agent, lc sessionLifecycle, events *EventBus, responder *ask.Responder) *Session {
-	return &Session{
+// event bus, responder and file change reviewer.
+func newSession(id string, a *agent.Agent, lc sessionLifecycle, events *EventBus, responder *ask.Responder, reviewer *filesystem.Reviewer) *Session {
+	s := &Session{
 		id:           id,
-		persistID:    generatePersistID(),
+		persistID:    sessions.NewID(),
 		createdAt:    time.Now(),
 		agent:        a,
 		lifecycle:    lc,
 		events:       events,
 		responder:    responder,
+		reviewer:     reviewer,
 		sessionTrust: &filesystem.SessionTrust{},
 	}
+	s.setAgent(a)
+	return s
 }
 
 // ID returns the session identifier.
@@ -89,9 +98,25 @@ func (s *Session) Chat() *chat.Chat { return s.agent.Chat() }
 // Completer returns the session's underlying completer for usage reporting.
 func (s *Session) Completer() modeladapter.Completer { return s.agent.Completer() }
 
+// SetTrigger records the daemon trigger that started the session. It is
+// persisted with the session so run history can be traced back to it.
+func (s *Session) SetTrigger(name string) { s.trigger = name }
+
+// Trigger returns the daemon trigger that started the session, or "".
+func (s *Session) Trigger() string { return s.trigger }
+
 // ProviderInfo returns the session's provider metadata.
 func (s *Session) ProviderInfo() ProviderInfo { return s.providerInfo }
 
+// Usage returns the session's cumulative token usage and cost per agent
+// instance and provider, in first-use order. It includes usage from before
+// the session was resumed.
+func (s *Session) Usage() []sessions.AgentUsage { return s.usage.snapshot() }
+
+// UsageTotal returns the session's cumulative token usage and cost summed
+// over every agent instance, including delegated ones.
+func (s *Session) UsageTotal() (usage.TokenCount, float64) { return s.usage.total() }
+
 // Send appends a text message from the user and runs the agent's ReAct loop.
 // It returns the agent's reply. Only one Send may be active per session.
 func (s *Session) Send(ctx context.Context, text string) (message.Message, error) {
@@ -107,21 +132,42 @@ func (s *Session) SendParts(ctx context.Context, parts ...content.Part) (message
 	}
 	defer s.lifecycle.releaseSend()
 
-	if err := s.acquire(); err != nil {
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+
+	if err := s.acquire(stop); err != nil {
 		return message.Message{}, err
 	}
 	defer s.release()
 
-	s.events.publish(EventAgentStart, s.id, s.agent.Name(), agent.AgentEventData{Prefix: s.agent.Prefix(), ProviderLabel: s.agent.ProviderLabel()})
-
 	ctx = withSessionID(ctx, s.id)
 	ctx = agentctx.WithAgentName(ctx, s.agent.Name())
 	ctx = filesystem.WithSessionTrust(ctx, s.sessionTrust)
+	staging := &filesystem.Staging{}
+	ctx = filesystem.WithStaging(ctx, staging)
+
+	if s.hooks.Has(hooks.UserPromptSubmit) {
+		out := runHooks(ctx, s.hooks, hooks.Input{Event: hooks.UserPromptSubmit, Prompt: message.New("user", role.User, parts...).TextContent()})
+		if out.Denied() {
+			return message.Message{}, hookDenied(out.Reason)
+		}
+		if out.AdditionalContext != "" {
+			parts = append(parts, content.Text{Text: out.AdditionalContext})
+		}
+	}
+
+	s.events.publish(EventAgentStart, s.id, s.agent.Name(), agent.AgentEventData{Prefix: s.agent.Prefix(), ProviderLabel: s.agent.ProviderLabel()})
 
-	s.agent.Chat().Append(message.New("user", role.User, parts...))
+	s.agent.Chat().Append(message.New("user", role.User, s.withReviewNote(parts)...))
 
-	reply, err := s.agent.Run(ctx)
+	reply, err := s.runSteered(ctx)
+	s.reviewStaged(ctx, staging)
 	if err != nil {
+		// Report why the session was stopped (e.g. an exhausted budget)
+		// rather than the bare context.Canceled seen by the agent.
+		if cause := context.Cause(ctx); errors.Is(err, context.Canceled) && cause != nil && !errors.Is(cause, context.Canceled) {
+			err = cause
+		}
 		s.events.publish(EventError, s.id, s.agent.Name(), err)
 		s.events.publish(EventAgentEnd, s.id, s.agent.Name(), agent.AgentEventData{Prefix: s.agent.Prefix(), ProviderLabel: s.agent.ProviderLabel()})
 		return message.Message{}, err
@@ -136,7 +182,53 @@ func (s *Session) SendParts(ctx context.Context, parts ...content.Part) (message
 	return reply, nil
 }
 
-func (s *Session) acquire() error {
+// RunWorkflow runs the named workflow under the session, like Send: it counts
+// as the session's active Send and its steps appear as children of the
+// session agent. The request and the workflow report are appended to the chat
+// so that later turns can refer to them.
+func (s *Session) RunWorkflow(ctx context.Context, name, input string) (*WorkflowResult, error) {
+	if err := s.lifecycle.acquireSend(); err != nil {
+		return nil, err
+	}
+	defer s.lifecycle.releaseSend()
+
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+
+	if err := s.acquire(stop); err != nil {
+		return nil, err
+	}
+	defer s.release()
+
+	ctx = withSessionID(ctx, s.id)
+	ctx = agentctx.WithAgentName(ctx, s.agent.Name())
+	ctx = filesystem.WithSessionTrust(ctx, s.sessionTrust)
+	staging := &filesystem.Staging{}
+	ctx = filesystem.WithStaging(ctx, staging)
+
+	s.agent.Chat().Append(message.NewText("user", role.User, strings.TrimSpace("/run "+name+" "+input)))
+
+	res, err := s.lifecycle.RunWorkflow(ctx, name, input)
+	s.reviewStaged(ctx, staging)
+	if res != nil {
+		s.agent.Chat().Append(message.NewText(s.agent.Name(), role.Assistant, res.Report()))
+	}
+	if err != nil {
+		if cause := context.Cause(ctx); errors.Is(err, context.Canceled) && cause != nil && !errors.Is(cause, context.Canceled) {
+			err = cause
+		}
+		s.events.publish(EventError, s.id, s.agent.Name(), err)
+		return res, err
+	}
+
+	if s.onSendComplete != nil {
+		s.onSendComplete()
+	}
+
+	return res, nil
+}
+
+func (s *Session) acquire(stop context.CancelCauseFunc) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -144,6 +236,7 @@ func (s *Session) acquire() error {
 		return fmt.Errorf("engine: session %s: another Send is already active", s.id)
 	}
 	s.active = true
+	s.stopRun = stop
 	return nil
 }
 
@@ -152,6 +245,19 @@ func (s *Session) release() {
 	defer s.mu.Unlock()
 
 	s.active = false
+	s.stopRun = nil
+}
+
+// stop cancels the active Send, if any, with the given cause. Every agent in
+// the session's delegation tree runs under that Send's context.
+func (s *Session) stop(cause error) {
+	s.mu.Lock()
+	stop := s.stopRun
+	s.mu.Unlock()
+
+	if stop != nil {
+		stop(cause)
+	}
 }
 
 // AgentName returns the name of the session's agent.
@@ -165,7 +271,7 @@ func (s *Session) Compact(ctx context.Context) (effects.SummarizeResult, error)
 	}
 	defer s.lifecycle.releaseSend()
 
-	if err := s.acquire(); err != nil {
+	if err := s.acquire(nil); err != nil {
 		return effects.SummarizeResult{}, err
 	}
 	defer s.release()
@@ -177,6 +283,18 @@ func (s *Session) Compact(ctx context.Context) (effects.SummarizeResult, error)
 	return result, err
 }
 
+// end runs the session's session_end hooks.
+func (s *Session) end() {
+	if !s.hooks.Has(hooks.SessionEnd) {
+		return
+	}
+	runHooks(context.Background(), s.hooks, hooks.Input{
+		Event:     hooks.SessionEnd,
+		SessionID: s.id,
+		Agent:     s.agent.Name(),
+	})
+}
+
 // Respond delivers a user response to a pending ask_user question.
 func (s *Session) Respond(questionID, response string) error {
 	return s.responder.Respond(questionID, response)
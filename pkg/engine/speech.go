@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/providers/openai"
+)
+
+// buildSpeech creates the speech client for cfg.Speech, or returns nil when
+// speech is not configured. Validate has checked the provider reference.
+func buildSpeech(cfg Config) *openai.Speech {
+	if cfg.Speech.Provider == "" {
+		return nil
+	}
+
+	var pc ProviderConfig
+	for _, p := range cfg.Providers {
+		if p.Name == cfg.Speech.Provider {
+			pc = p
+			break
+		}
+	}
+
+	baseURL := pc.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.openai.com"
+	}
+
+	s := openai.NewSpeech(baseURL, pc.APIKey)
+	if cfg.Speech.TranscriptionModel != "" {
+		s.TranscriptionModel = cfg.Speech.TranscriptionModel
+	}
+	if cfg.Speech.SpeechModel != "" {
+		s.SpeechModel = cfg.Speech.SpeechModel
+	}
+	if cfg.Speech.Voice != "" {
+		s.Voice = cfg.Speech.Voice
+	}
+	return s
+}
+
+// Speech returns the speech configuration.
+func (e *Engine) Speech() SpeechConfig { return e.cfg.Speech }
+
+// Synthesizer returns the text-to-speech client, or nil when speech is not
+// configured.
+func (e *Engine) Synthesizer() modeladapter.Synthesizer {
+	if e.speech == nil {
+		return nil
+	}
+	return e.speech
+}
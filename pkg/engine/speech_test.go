@@ -0,0 +1,22 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/providers/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildSpeech(t *testing.T) {
+	cfg := Config{
+		Providers: []ProviderConfig{{Name: "oa", Kind: "openai", APIKey: "k"}},
+	}
+	assert.Nil(t, buildSpeech(cfg))
+
+	cfg.Speech = SpeechConfig{Provider: "oa", Voice: "verse"}
+	s := buildSpeech(cfg)
+	require.NotNil(t, s)
+	assert.Equal(t, "verse", s.Voice)
+	assert.Equal(t, openai.NewSpeech("", "").TranscriptionModel, s.TranscriptionModel)
+}
@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/agent"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/providers/openai"
+	"github.com/germanamz/shelly/pkg/sessions"
+)
+
+// SetCompleter switches the session to the named provider, keeping the
+// conversation. The session agent is rebuilt with that provider's completer,
+// token estimation, context window and budget pricing. It fails while a Send
+// is active.
+func (s *Session) SetCompleter(provider string) error {
+	if provider == "" {
+		return fmt.Errorf("engine: session %s: provider is required", s.id)
+	}
+	return s.switchTo(s.agent.ConfigName(), provider)
+}
+
+// SetAgent switches the session to the named agent with its configured
+// provider, keeping the conversation. It fails while a Send is active.
+func (s *Session) SetAgent(name string) error {
+	return s.switchTo(name, "")
+}
+
+// ProviderName returns the config name of the session's provider.
+func (s *Session) ProviderName() string { return s.providerName }
+
+// Switches returns the session's agent and model switches, oldest first.
+func (s *Session) Switches() []sessions.Switch { return s.switches }
+
+// switchTo replaces the session agent with a fresh agentName agent using
+// provider ("" = the agent's configured provider). The chat is carried over:
+// the new agent's system prompt replaces the old one and provider-specific
+// metadata is ported (see portHistory). The switch is recorded and, once the
+// session has messages, persisted.
+func (s *Session) switchTo(agentName, provider string) error {
+	if err := s.lifecycle.acquireSend(); err != nil {
+		return err
+	}
+	defer s.lifecycle.releaseSend()
+
+	if err := s.acquire(nil); err != nil {
+		return err
+	}
+	defer s.release()
+
+	a, providerName, err := s.lifecycle.sessionAgent(agentName, provider)
+	if err != nil {
+		return err
+	}
+	if agentName == s.agent.ConfigName() && providerName == s.providerName {
+		return nil
+	}
+	info := s.lifecycle.providerInfo(providerName)
+
+	ch := s.agent.Chat()
+	ch.Replace(portHistory(ch.Messages(), s.providerInfo, info)...)
+	a.SetChat(ch)
+	a.Init()
+
+	s.setAgent(a)
+	s.providerName = providerName
+	s.providerInfo = info
+	s.switches = append(s.switches, sessions.Switch{
+		At:       time.Now(),
+		Agent:    a.Name(),
+		Provider: sessions.ProviderMeta{Name: providerName, Kind: info.Kind, Model: info.Model},
+		MsgIndex: ch.Len(),
+	})
+
+	// Persist the switch so a resumed session continues with the new model.
+	// A session without messages is not saved until its first Send.
+	if ch.Len() > 1 && s.onSendComplete != nil {
+		s.onSendComplete()
+	}
+	return nil
+}
+
+// sessionAgent builds a session agent for agentName. A non-empty provider
+// overrides the agent's configured one. The agent has the registry set and
+// tracks its own usage, so concurrent sessions on one provider are charged
+// separately, but is not initialized. It also returns the name of the
+// provider the agent uses.
+func (e *Engine) sessionAgent(agentName, provider string) (*agent.Agent, string, error) {
+	factory, ok := e.registry.Get(agentName)
+	if !ok {
+		return nil, "", fmt.Errorf("engine: agent %q not found", agentName)
+	}
+
+	ac, configured := e.agentConfig(agentName)
+	configuredProvider := e.agentProviderName(ac.Provider)
+
+	var a *agent.Agent
+	switch {
+	case provider == "" || provider == configuredProvider:
+		a = factory()
+		provider = configuredProvider
+	case !configured:
+		return nil, "", fmt.Errorf("engine: agent %q: provider cannot be changed", agentName)
+	default:
+		if _, ok := e.completers[provider]; !ok {
+			return nil, "", fmt.Errorf("engine: provider %q not found", provider)
+		}
+		ac.Provider = provider
+		rc, err := e.buildRegistrationContext(ac)
+		if err != nil {
+			return nil, "", err
+		}
+		a = newAgent(rc)
+	}
+
+	a.SetRegistry(e.registry)
+	a.TrackUsage()
+	return a, provider, nil
+}
+
+// agentConfig returns the config of the named agent.
+func (e *Engine) agentConfig(name string) (AgentConfig, bool) {
+	for _, ac := range e.cfg.Agents {
+		if ac.Name == name {
+			return ac, true
+		}
+	}
+	return AgentConfig{}, false
+}
+
+// portHistory prepares messages produced by one model for another. When the
+// provider kind or model changes it drops the provider-specific opaque data
+// that only the producing model accepts: tool call metadata (e.g. Gemini
+// thought signatures), reasoning signatures and encrypted content, and OpenAI
+// Responses chaining IDs. Reasoning without readable text is removed; other
+// reasoning is kept for display, and providers skip it on the wire.
+func portHistory(msgs []message.Message, from, to ProviderInfo) []message.Message {
+	if from == to {
+		return msgs
+	}
+
+	out := make([]message.Message, 0, len(msgs))
+	for _, m := range msgs {
+		parts := make([]content.Part, 0, len(m.Parts))
+		for _, p := range m.Parts {
+			switch v := p.(type) {
+			case content.ToolCall:
+				v.Metadata = nil
+				parts = append(parts, v)
+			case content.Reasoning:
+				if v.Text == "" {
+					continue
+				}
+				v.Metadata = nil
+				parts = append(parts, v)
+			default:
+				parts = append(parts, p)
+			}
+		}
+		m.Parts = parts
+
+		if m.Metadata != nil {
+			meta := maps.Clone(m.Metadata)
+			delete(meta, openai.ResponseIDMetaKey)
+			delete(meta, openai.ResponsePrefixMetaKey)
+			if len(meta) == 0 {
+				meta = nil
+			}
+			m.Metadata = meta
+		}
+		out = append(out, m)
+	}
+	return out
+}
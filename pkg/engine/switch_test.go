@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/providers/openai"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// modelCompleter replies with its model name.
+type modelCompleter struct{ model string }
+
+func (m *modelCompleter) Complete(_ context.Context, _ *chat.Chat, _ []toolbox.Tool) (message.Message, error) {
+	return message.NewText("bot", role.Assistant, m.model), nil
+}
+
+func newSwitchEngine(t *testing.T) *Engine {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), ".shelly")
+	require.NoError(t, os.MkdirAll(dir, 0o750))
+
+	factory := func(pc ProviderConfig) (modeladapter.Completer, error) {
+		return &modelCompleter{model: pc.Model}, nil
+	}
+	return newTestEngine(t, "switch-mock", factory, Config{
+		ShellyDir: dir,
+		Providers: []ProviderConfig{
+			{Name: "cheap", Kind: "switch-mock", Model: "small"},
+			{Name: "smart", Kind: "switch-mock", Model: "large"},
+		},
+		Agents: []AgentConfig{
+			{Name: "coder", Description: "writes code", Instructions: "Write code.", Provider: "cheap"},
+			{Name: "reviewer", Description: "reviews code", Instructions: "Review code.", Provider: "smart"},
+		},
+	})
+}
+
+func TestSession_SetCompleter(t *testing.T) {
+	eng := newSwitchEngine(t)
+	ctx := context.Background()
+
+	sess, err := eng.NewSession("coder")
+	require.NoError(t, err)
+	assert.Equal(t, "cheap", sess.ProviderName())
+
+	reply, err := sess.Send(ctx, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "small", reply.TextContent())
+
+	ch := sess.Chat()
+	require.NoError(t, sess.SetCompleter("smart"))
+	assert.Same(t, ch, sess.Chat(), "the chat is carried over")
+	assert.Equal(t, "coder", sess.AgentName())
+	assert.Equal(t, "smart", sess.ProviderName())
+	assert.Equal(t, ProviderInfo{Kind: "switch-mock", Model: "large"}, sess.ProviderInfo())
+	assert.Equal(t, 3, ch.Len())
+
+	reply, err = sess.Send(ctx, "again")
+	require.NoError(t, err)
+	assert.Equal(t, "large", reply.TextContent())
+
+	info, _, err := eng.SessionStore().Load(sess.PersistID())
+	require.NoError(t, err)
+	assert.Equal(t, "smart", info.Provider.Name)
+	assert.Equal(t, "large", info.Provider.Model)
+	require.Len(t, info.Switches, 1)
+	assert.Equal(t, "coder", info.Switches[0].Agent)
+	assert.Equal(t, "smart", info.Switches[0].Provider.Name)
+	assert.Equal(t, 3, info.Switches[0].MsgIndex)
+
+	resumed, err := eng.ResumeSession(sess.PersistID())
+	require.NoError(t, err)
+	assert.Equal(t, "smart", resumed.ProviderName(), "resumed sessions restore the last model")
+	assert.Len(t, resumed.Switches(), 1)
+	reply, err = resumed.Send(ctx, "resumed")
+	require.NoError(t, err)
+	assert.Equal(t, "large", reply.TextContent())
+}
+
+func TestSession_SetAgent(t *testing.T) {
+	eng := newSwitchEngine(t)
+	ctx := context.Background()
+
+	sess, err := eng.NewSession("coder")
+	require.NoError(t, err)
+	_, err = sess.Send(ctx, "hello")
+	require.NoError(t, err)
+
+	require.NoError(t, sess.SetAgent("reviewer"))
+	assert.Equal(t, "reviewer", sess.AgentName())
+	assert.Equal(t, "smart", sess.ProviderName(), "the agent's configured provider is used")
+	assert.Contains(t, sess.Chat().SystemPrompt(), "Review code.")
+	assert.Equal(t, "hello", sess.Chat().At(1).TextContent())
+
+	reply, err := sess.Send(ctx, "review it")
+	require.NoError(t, err)
+	assert.Equal(t, "large", reply.TextContent())
+
+	info, _, err := eng.SessionStore().Load(sess.PersistID())
+	require.NoError(t, err)
+	assert.Equal(t, "reviewer", info.Agent)
+}
+
+func TestSession_SwitchErrors(t *testing.T) {
+	eng := newSwitchEngine(t)
+
+	sess, err := eng.NewSession("coder")
+	require.NoError(t, err)
+
+	require.ErrorContains(t, sess.SetCompleter("nope"), `provider "nope" not found`)
+	require.ErrorContains(t, sess.SetCompleter(""), "provider is required")
+	require.ErrorContains(t, sess.SetAgent("nope"), `agent "nope" not found`)
+	assert.Equal(t, "coder", sess.AgentName())
+	assert.Empty(t, sess.Switches())
+
+	require.NoError(t, sess.SetCompleter("cheap"), "switching to the current provider is a no-op")
+	assert.Empty(t, sess.Switches())
+
+	_, _, err = eng.SessionStore().Load(sess.PersistID())
+	assert.Error(t, err, "sessions without messages are not saved on switch")
+}
+
+func TestPortHistory(t *testing.T) {
+	reply := message.New("bot", role.Assistant,
+		content.Reasoning{Text: "thinking", Metadata: map[string]string{"signature": "sig"}},
+		content.Reasoning{Metadata: map[string]string{"encrypted": "blob"}},
+		content.ToolCall{ID: "c1", Name: "read", Arguments: "{}", Metadata: map[string]string{"thoughtSignature": "ts"}},
+	)
+	message.SetMeta(&reply, openai.ResponseIDMetaKey, "resp_1")
+	message.SetMeta(&reply, "keep", true)
+	msgs := []message.Message{message.NewText("user", role.User, "hi"), reply}
+
+	gemini := ProviderInfo{Kind: "gemini", Model: "gemini-3-pro"}
+	assert.Equal(t, msgs, portHistory(msgs, gemini, gemini), "same model keeps everything")
+
+	ported := portHistory(msgs, gemini, ProviderInfo{Kind: "anthropic", Model: "claude"})
+	require.Len(t, ported, 2)
+	assert.Equal(t, msgs[0], ported[0])
+	assert.Equal(t, []content.Part{
+		content.Reasoning{Text: "thinking"},
+		content.ToolCall{ID: "c1", Name: "read", Arguments: "{}"},
+	}, ported[1].Parts)
+	assert.Equal(t, map[string]any{"keep": true}, ported[1].Metadata)
+
+	// The input is not modified.
+	assert.Len(t, msgs[1].Parts, 3)
+	assert.Contains(t, msgs[1].Metadata, openai.ResponseIDMetaKey)
+}
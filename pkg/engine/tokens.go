@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sync"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/bpe"
+	"github.com/germanamz/shelly/pkg/providers/vertex"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+)
+
+// encodingHeuristic selects modeladapter.HeuristicTokenizer explicitly.
+const encodingHeuristic = "heuristic"
+
+// providerTokens holds a provider's token estimation setup. All agents using
+// the provider share it, so calibration learns from every call.
+type providerTokens struct {
+	estimator   modeladapter.TokenEstimator
+	calibration *modeladapter.Calibration
+	counter     modeladapter.TokenCounter // nil unless tokenizer.count_tokens is set
+}
+
+// buildProviderTokens resolves the tokenizer and calibration for a provider.
+// Vocabularies are the bundled ones unless vocabDir holds an override, and
+// are shared through encodings. An override that cannot be read is an error
+// for a configured encoding; one picked from the model name falls back to the
+// bundled vocabulary.
+func buildProviderTokens(cfg ProviderConfig, vocabDir string, encodings map[string]*bpe.Encoding) (providerTokens, error) {
+	pt := providerTokens{
+		calibration: &modeladapter.Calibration{CacheInInput: cacheInInput(cfg)},
+	}
+
+	name := cfg.Tokenizer.Encoding
+	explicit := name != ""
+	if !explicit {
+		name = bpe.ForModel(cfg.Model)
+	}
+	if name == encodingHeuristic {
+		return pt, nil
+	}
+	if name != "" {
+		enc, ok := encodings[name]
+		if !ok {
+			var err error
+			enc, err = loadEncoding(vocabDir, name, explicit)
+			if err != nil {
+				return providerTokens{}, fmt.Errorf("engine: provider %q: tokenizer: %w", cfg.Name, err)
+			}
+			encodings[name] = enc
+		}
+		pt.estimator.Tokenizer = enc
+		return pt, nil
+	}
+
+	heuristicOnce.Do(func() {
+		slog.Info("engine: no BPE vocabulary for model, estimating tokens heuristically", "provider", cfg.Name, "model", cfg.Model)
+	})
+	return pt, nil
+}
+
+// heuristicOnce limits the heuristic tokenizer notice to one per process.
+var heuristicOnce sync.Once
+
+// loadEncoding reads the named vocabulary from vocabDir when it is there and
+// returns the bundled one otherwise. When strict is false an unreadable
+// override is logged and skipped.
+func loadEncoding(vocabDir, name string, strict bool) (*bpe.Encoding, error) {
+	enc, err := bpe.LoadDir(vocabDir, name)
+	switch {
+	case err == nil:
+		return enc, nil
+	case errors.Is(err, fs.ErrNotExist):
+	case strict:
+		return nil, err
+	default:
+		slog.Warn("engine: ignoring unreadable vocabulary override", "encoding", name, "err", err)
+	}
+	return bpe.Builtin(name)
+}
+
+// estimate returns the calibrated input token estimate of a request.
+func (pt providerTokens) estimate(c *chat.Chat, tools []toolbox.Tool) int {
+	return pt.calibration.Apply(pt.estimator.EstimateTotal(c, tools))
+}
+
+// buildTokenCounter returns a caching exact counter for the provider's
+// completer when tokenizer.count_tokens is set, and nil otherwise.
+func buildTokenCounter(cfg ProviderConfig, c modeladapter.Completer) (modeladapter.TokenCounter, error) {
+	if !cfg.Tokenizer.CountTokens {
+		return nil, nil
+	}
+	tc, ok := unwrapCompleter[modeladapter.TokenCounter](c)
+	if !ok {
+		return nil, fmt.Errorf("engine: provider %q: tokenizer.count_tokens is not supported", cfg.Name)
+	}
+	return modeladapter.NewCachedTokenCounter(tc), nil
+}
+
+// cacheInInput reports whether a provider kind counts cached tokens as part
+// of its reported input tokens. Anthropic's Messages API and Bedrock's
+// Converse API report them separately.
+func cacheInInput(cfg ProviderConfig) bool {
+	switch cfg.Kind {
+	case "anthropic", "bedrock":
+		return false
+	case "vertex":
+		return vertex.Publisher(cfg.Model) != vertex.PublisherAnthropic
+	default:
+		return true
+	}
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/bpe"
+	"github.com/germanamz/shelly/pkg/providers/anthropic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeVocab writes a byte-level vocabulary (no merges) for encoding to dir.
+func writeVocab(t *testing.T, dir, encoding string) {
+	t.Helper()
+
+	var b strings.Builder
+	for i := range 256 {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), i)
+	}
+	require.NoError(t, os.MkdirAll(dir, 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, encoding+".tiktoken"), []byte(b.String()), 0o600))
+}
+
+func TestBuildProviderTokens_EncodingFromModel(t *testing.T) {
+	dir := t.TempDir()
+	writeVocab(t, dir, bpe.O200kBase)
+	encodings := map[string]*bpe.Encoding{}
+
+	cfg := ProviderConfig{Name: "oa", Kind: "openai", Model: "gpt-4o"}
+	pt, err := buildProviderTokens(cfg, dir, encodings)
+	require.NoError(t, err)
+
+	enc, ok := pt.estimator.Tokenizer.(*bpe.Encoding)
+	require.True(t, ok)
+	assert.Equal(t, bpe.O200kBase, enc.Name())
+	assert.Same(t, enc, encodings[bpe.O200kBase], "vocabulary is shared")
+	assert.True(t, pt.calibration.CacheInInput)
+}
+
+func TestBuildProviderTokens_BundledVocabulary(t *testing.T) {
+	dir := t.TempDir()
+	builtin, err := bpe.Builtin(bpe.O200kBase)
+	require.NoError(t, err)
+
+	// Without an override the bundled vocabulary is used.
+	cfg := ProviderConfig{Name: "oa", Kind: "openai", Model: "gpt-4.1"}
+	pt, err := buildProviderTokens(cfg, dir, map[string]*bpe.Encoding{})
+	require.NoError(t, err)
+	assert.Same(t, builtin, pt.estimator.Tokenizer)
+
+	// An unreadable override is skipped when picked from the model name and
+	// an error when configured explicitly.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "o200k_base.tiktoken"), []byte("garbage\n"), 0o600))
+	pt, err = buildProviderTokens(cfg, dir, map[string]*bpe.Encoding{})
+	require.NoError(t, err)
+	assert.Same(t, builtin, pt.estimator.Tokenizer)
+
+	cfg.Tokenizer.Encoding = bpe.O200kBase
+	_, err = buildProviderTokens(cfg, dir, map[string]*bpe.Encoding{})
+	require.ErrorContains(t, err, `engine: provider "oa": tokenizer: bpe: o200k_base line 1: missing rank`)
+
+	cfg.Tokenizer.Encoding = encodingHeuristic
+	pt, err = buildProviderTokens(cfg, dir, map[string]*bpe.Encoding{})
+	require.NoError(t, err)
+	assert.Nil(t, pt.estimator.Tokenizer)
+
+	// Models without a known encoding use the heuristic.
+	pt, err = buildProviderTokens(ProviderConfig{Name: "claude", Kind: "anthropic", Model: "claude-sonnet-4"}, dir, map[string]*bpe.Encoding{})
+	require.NoError(t, err)
+	assert.Nil(t, pt.estimator.Tokenizer)
+}
+
+func TestBuildTokenCounter(t *testing.T) {
+	cfg := ProviderConfig{Name: "claude", Kind: "anthropic", Model: "claude-sonnet-4"}
+	c := modeladapter.NewRateLimitedCompleter(anthropic.New("http://unused", "k", cfg.Model), modeladapter.RateLimitOpts{MaxRetries: 1})
+
+	tc, err := buildTokenCounter(cfg, c)
+	require.NoError(t, err)
+	assert.Nil(t, tc, "off unless configured")
+
+	// The counter is found through rate limiting wrappers.
+	cfg.Tokenizer.CountTokens = true
+	tc, err = buildTokenCounter(cfg, c)
+	require.NoError(t, err)
+	assert.IsType(t, &modeladapter.CachedTokenCounter{}, tc)
+
+	_, err = buildTokenCounter(cfg, &mockCompleter{})
+	require.EqualError(t, err, `engine: provider "claude": tokenizer.count_tokens is not supported`)
+}
+
+func TestCacheInInput(t *testing.T) {
+	assert.False(t, cacheInInput(ProviderConfig{Kind: "anthropic"}))
+	assert.False(t, cacheInInput(ProviderConfig{Kind: "bedrock"}))
+	assert.False(t, cacheInInput(ProviderConfig{Kind: "vertex", Model: "claude-sonnet-4@20250514"}))
+	assert.True(t, cacheInInput(ProviderConfig{Kind: "vertex", Model: "gemini-2.5-pro"}))
+	assert.True(t, cacheInInput(ProviderConfig{Kind: "openai"}))
+}
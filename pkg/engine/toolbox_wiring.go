@@ -11,6 +11,7 @@ import (
 	"github.com/germanamz/shelly/pkg/codingtoolbox/filesystem"
 	shellygit "github.com/germanamz/shelly/pkg/codingtoolbox/git"
 	shellyhttp "github.com/germanamz/shelly/pkg/codingtoolbox/http"
+	"github.com/germanamz/shelly/pkg/codingtoolbox/memory"
 	"github.com/germanamz/shelly/pkg/codingtoolbox/notes"
 	"github.com/germanamz/shelly/pkg/codingtoolbox/permissions"
 	"github.com/germanamz/shelly/pkg/codingtoolbox/search"
@@ -30,6 +31,8 @@ var builtinToolboxNames = map[string]struct{}{
 	"git":        {},
 	"http":       {},
 	"notes":      {},
+	"memory":     {},
+	"workflows":  {},
 }
 
 // BuiltinToolboxNames returns the sorted list of built-in toolbox names.
@@ -63,18 +66,30 @@ func (e *Engine) wireBuiltinToolboxes(cfg Config, dir shellydir.Dir) error {
 
 	refs := referencedBuiltins(cfg.Agents)
 
+	// Workflows run on the task board and pass data through the state store.
+	if len(cfg.Workflows) > 0 {
+		refs["state"] = struct{}{}
+		refs["tasks"] = struct{}{}
+		e.toolboxes["workflows"] = e.workflowTools(cfg.Workflows)
+	}
+
 	e.wireStores(refs)
 	e.wireNotes(refs, dir)
+	e.wireMemory(refs, dir)
 
 	return e.wirePermissionGatedTools(cfg, dir, refs)
 }
 
-// wireResponder creates the ask responder toolbox (always available).
+// wireResponder creates the ask responder toolbox (always available) and the
+// file change reviewer.
 func (e *Engine) wireResponder() {
 	e.responder = ask.NewResponder(func(ctx context.Context, q ask.Question) {
 		publishFromContext(e.events, ctx, EventAskUser, q)
 	})
 	e.toolboxes["ask"] = e.responder.Tools()
+	e.reviewer = filesystem.NewReviewer(func(ctx context.Context, r filesystem.ReviewRequest) {
+		publishFromContext(e.events, ctx, EventFileReview, r)
+	})
 }
 
 // wireStores creates state and task stores if referenced by any agent.
@@ -99,6 +114,15 @@ func (e *Engine) wireNotes(refs map[string]struct{}, dir shellydir.Dir) {
 	}
 }
 
+// wireMemory creates the long-term memory store, persisted in
+// .shelly/local/memory.json, and its toolbox if referenced.
+func (e *Engine) wireMemory(refs map[string]struct{}, dir shellydir.Dir) {
+	e.memory = memory.New(dir.MemoryPath())
+	if _, ok := refs["memory"]; ok {
+		e.toolboxes["memory"] = e.memory.Tools()
+	}
+}
+
 // wirePermissionGatedTools creates filesystem, exec, search, git, and http
 // toolboxes if referenced. All share a single permissions store.
 func (e *Engine) wirePermissionGatedTools(cfg Config, dir shellydir.Dir, refs map[string]struct{}) error {
@@ -136,6 +160,8 @@ func (e *Engine) wirePermissionGatedTools(cfg Config, dir shellydir.Dir, refs ma
 			publishFromContext(e.events, ctx, EventFileChange, message)
 		}
 		fsTools := filesystem.New(permStore, e.responder.Ask, notifyFn)
+		fsTools.SetReview(cfg.Filesystem.Review, e.reviewer.Review)
+		e.fs = fsTools
 		e.toolboxes["filesystem"] = fsTools.Tools()
 	}
 
@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/germanamz/shelly/pkg/agent"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/usage"
+	"github.com/germanamz/shelly/pkg/sessions"
+)
+
+// usageLedger accumulates the token usage and cost of every agent instance in
+// one session, keyed by instance and provider. It is persisted with the
+// session. It is safe for concurrent use.
+type usageLedger struct {
+	mu      sync.Mutex
+	entries []sessions.AgentUsage
+	parents map[string]string // instance name → delegating instance
+}
+
+// record adds one completion's usage to the entry of agent on provider.
+func (l *usageLedger) record(agentName string, provider sessions.ProviderMeta, tc usage.TokenCount, cost float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	i := slices.IndexFunc(l.entries, func(u sessions.AgentUsage) bool {
+		return u.Agent == agentName && u.Provider.Name == provider.Name
+	})
+	if i < 0 {
+		l.entries = append(l.entries, sessions.AgentUsage{Agent: agentName, Parent: l.parents[agentName], Provider: provider})
+		i = len(l.entries) - 1
+	}
+
+	u := &l.entries[i]
+	u.Calls++
+	u.InputTokens += tc.InputTokens
+	u.OutputTokens += tc.OutputTokens
+	u.CacheCreationTokens += tc.CacheCreationInputTokens
+	u.CacheReadTokens += tc.CacheReadInputTokens
+	u.CostUSD += cost
+}
+
+// setParent records that parent delegated to agentName.
+func (l *usageLedger) setParent(agentName, parent string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.parents == nil {
+		l.parents = make(map[string]string)
+	}
+	l.parents[agentName] = parent
+	for i := range l.entries {
+		if l.entries[i].Agent == agentName {
+			l.entries[i].Parent = parent
+		}
+	}
+}
+
+// snapshot returns a copy of the entries in first-use order.
+func (l *usageLedger) snapshot() []sessions.AgentUsage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.entries)
+}
+
+// total returns the token usage and cost summed over all entries.
+func (l *usageLedger) total() (usage.TokenCount, float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var tc usage.TokenCount
+	var cost float64
+	for _, u := range l.entries {
+		tc.InputTokens += u.InputTokens
+		tc.OutputTokens += u.OutputTokens
+		tc.CacheCreationInputTokens += u.CacheCreationTokens
+		tc.CacheReadInputTokens += u.CacheReadTokens
+		cost += u.CostUSD
+	}
+	return tc, cost
+}
+
+// restore replaces the entries with persisted ones.
+func (l *usageLedger) restore(entries []sessions.AgentUsage) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = slices.Clone(entries)
+}
+
+// usageEffect charges the usage of every completion of one agent instance to
+// its session's ledger. Like budgetEffect it charges, after each completion,
+// everything the agent's own completer recorded since the previous charge.
+type usageEffect struct {
+	provider sessions.ProviderMeta
+	pricing  usage.ModelPricing
+	record   func(sessionID, agentName string, provider sessions.ProviderMeta, tc usage.TokenCount, cost float64)
+
+	charged usage.TokenCount // completer usage total already charged
+}
+
+// Eval implements agent.Effect.
+func (e *usageEffect) Eval(ctx context.Context, ic agent.IterationContext) error {
+	if ic.Phase != agent.PhaseAfterComplete {
+		return nil
+	}
+
+	sid, ok := sessionIDFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	total := usageTotal(ic.Completer)
+	delta := tokenDelta(e.charged, total)
+	e.charged = total
+	e.record(sid, ic.AgentName, e.provider, delta, usage.CalculateCost(delta, e.pricing))
+	return nil
+}
+
+// newUsageEffect creates the usage effect template for an agent using
+// providerName. The agent factory copies it per instance.
+func (e *Engine) newUsageEffect(providerName string) *usageEffect {
+	info := e.providerInfo(providerName)
+	pricing, _ := usage.LookupPricing(info.Kind, info.Model)
+
+	return &usageEffect{
+		provider: sessions.ProviderMeta{Name: providerName, Kind: info.Kind, Model: info.Model},
+		pricing:  pricing,
+		record:   e.recordUsage,
+	}
+}
+
+// recordUsage charges usage to the ledger of the session with the given ID.
+func (e *Engine) recordUsage(sessionID, agentName string, provider sessions.ProviderMeta, tc usage.TokenCount, cost float64) {
+	if s, ok := e.Session(sessionID); ok {
+		s.usage.record(agentName, provider, tc, cost)
+	}
+}
+
+// RateLimits returns the most recent rate limit headroom reported by each
+// provider, keyed by provider config name. Providers that have not reported
+// any are omitted.
+func (e *Engine) RateLimits() map[string]modeladapter.RateLimitInfo {
+	limits := make(map[string]modeladapter.RateLimitInfo)
+	for name, c := range e.completers {
+		r, ok := unwrapCompleter[modeladapter.RateLimitInfoReporter](c)
+		if !ok {
+			continue
+		}
+		if info := r.LastRateLimitInfo(); info != nil {
+			limits[name] = *info
+		}
+	}
+	return limits
+}
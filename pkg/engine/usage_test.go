@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/usage"
+	"github.com/germanamz/shelly/pkg/sessions"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession_Usage(t *testing.T) {
+	eng, _ := newBudgetEngine(t, &pricedCompleter{}, BudgetConfig{})
+	ctx := context.Background()
+
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+	assert.Empty(t, sess.Usage())
+
+	_, err = sess.Send(ctx, "one")
+	require.NoError(t, err)
+	_, err = sess.Send(ctx, "two")
+	require.NoError(t, err)
+
+	want := sessions.AgentUsage{
+		Agent:       "bot",
+		Provider:    sessions.ProviderMeta{Name: "p1", Kind: "priced", Model: "test-model"},
+		Calls:       2,
+		InputTokens: 2_000_000,
+		CostUSD:     2,
+	}
+	assert.Equal(t, []sessions.AgentUsage{want}, sess.Usage())
+
+	// Totals are saved with the session and survive a resume.
+	resumed, err := eng.ResumeSession(sess.PersistID())
+	require.NoError(t, err)
+	assert.Equal(t, []sessions.AgentUsage{want}, resumed.Usage())
+
+	_, err = resumed.Send(ctx, "three")
+	require.NoError(t, err)
+	require.Len(t, resumed.Usage(), 1)
+	assert.Equal(t, 3, resumed.Usage()[0].Calls)
+}
+
+func TestSession_UsageConcurrentSessions(t *testing.T) {
+	eng, _ := newBudgetEngine(t, &pricedCompleter{}, BudgetConfig{})
+	ctx := context.Background()
+
+	// Sessions sharing the provider are charged only their own calls.
+	calls := []int{1, 3, 5, 7}
+	sessList := make([]*Session, len(calls))
+	var wg sync.WaitGroup
+	for i, n := range calls {
+		sess, err := eng.NewSession("")
+		require.NoError(t, err)
+		sessList[i] = sess
+		wg.Go(func() {
+			for range n {
+				_, err := sess.Send(ctx, "hi")
+				assert.NoError(t, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	for i, n := range calls {
+		total, cost := sessList[i].UsageTotal()
+		assert.Equal(t, n*1_000_000, total.InputTokens, "session %d", i)
+		assert.InDelta(t, float64(n), cost, 1e-9, "session %d", i)
+	}
+}
+
+func TestSession_UsageIncludesCompaction(t *testing.T) {
+	eng, _ := newBudgetEngine(t, &pricedCompleter{}, BudgetConfig{})
+	ctx := context.Background()
+
+	sess, err := eng.NewSession("")
+	require.NoError(t, err)
+	_, err = sess.Send(ctx, "one")
+	require.NoError(t, err)
+	_, err = sess.Compact(ctx)
+	require.NoError(t, err)
+
+	// The summarization call is charged with the next completion.
+	_, err = sess.Send(ctx, "two")
+	require.NoError(t, err)
+	total, cost := sess.UsageTotal()
+	assert.Equal(t, 3_000_000, total.InputTokens)
+	assert.InDelta(t, 3.0, cost, 1e-9)
+}
+
+func TestUsageLedger(t *testing.T) {
+	var l usageLedger
+	cheap := sessions.ProviderMeta{Name: "cheap"}
+	smart := sessions.ProviderMeta{Name: "smart"}
+
+	l.record("coder", cheap, usage.TokenCount{InputTokens: 10, OutputTokens: 5, CacheReadInputTokens: 3}, 0.5)
+	l.setParent("coder-fix-1", "coder")
+	l.record("coder-fix-1", cheap, usage.TokenCount{InputTokens: 7}, 0.25)
+	l.record("coder", smart, usage.TokenCount{OutputTokens: 1}, 1)
+	l.record("coder", cheap, usage.TokenCount{InputTokens: 1}, 0)
+
+	got := l.snapshot()
+	require.Len(t, got, 3)
+	assert.Equal(t, sessions.AgentUsage{Agent: "coder", Provider: cheap, Calls: 2, InputTokens: 11, OutputTokens: 5, CacheReadTokens: 3, CostUSD: 0.5}, got[0])
+	assert.Equal(t, "coder", got[1].Parent)
+	assert.Equal(t, smart, got[2].Provider, "a model switch starts a new entry")
+
+	// A parent learned after the first record is applied too.
+	l.record("reviewer-1", cheap, usage.TokenCount{}, 0)
+	l.setParent("reviewer-1", "coder")
+	assert.Equal(t, "coder", l.snapshot()[3].Parent)
+
+	l.restore(got[:1])
+	assert.Len(t, l.snapshot(), 1)
+}
+
+// rateLimitedCompleter reports fixed rate limit headroom.
+type rateLimitedCompleter struct{}
+
+func (rateLimitedCompleter) Complete(context.Context, *chat.Chat, []toolbox.Tool) (message.Message, error) {
+	return message.Message{}, nil
+}
+
+func (rateLimitedCompleter) LastRateLimitInfo() *modeladapter.RateLimitInfo {
+	return &modeladapter.RateLimitInfo{RemainingRequests: 42, RemainingTokens: 9000}
+}
+
+func TestEngine_RateLimits(t *testing.T) {
+	RegisterProvider("ratelimited", func(ProviderConfig) (modeladapter.Completer, error) {
+		return rateLimitedCompleter{}, nil
+	})
+	RegisterProvider("plain", func(ProviderConfig) (modeladapter.Completer, error) {
+		return &pricedCompleter{}, nil
+	})
+
+	eng, err := New(context.Background(), Config{
+		ShellyDir: t.TempDir(),
+		Providers: []ProviderConfig{{Name: "limited", Kind: "ratelimited"}, {Name: "other", Kind: "plain"}},
+		Agents:    []AgentConfig{{Name: "bot", Provider: "limited"}},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = eng.Close() })
+
+	limits := eng.RateLimits()
+	require.Len(t, limits, 1)
+	assert.Equal(t, 42, limits["limited"].RemainingRequests, "reporters are found through completer wrappers")
+}
@@ -0,0 +1,649 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/agent"
+	"github.com/germanamz/shelly/pkg/agentctx"
+	"github.com/germanamz/shelly/pkg/tasks"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+)
+
+// Workflow step and run statuses.
+const (
+	WorkflowCompleted = "completed"
+	WorkflowFailed    = "failed"
+	WorkflowSkipped   = "skipped"
+	WorkflowRunning   = "running" // Only reported through EventWorkflowStep.
+
+	workflowWhenAny = "any" // WorkflowStepConfig.When value matching completed or failed.
+
+	// maxWorkflowDepth caps how deeply workflows may start other workflows
+	// through run_workflow.
+	maxWorkflowDepth = 4
+)
+
+// WorkflowStepResult is the outcome of one workflow step. Fan-out steps
+// report one entry per element in Items.
+type WorkflowStepResult struct {
+	Name    string               `json:"name"`
+	Agent   string               `json:"agent"`
+	Status  string               `json:"status"`
+	Summary string               `json:"summary,omitempty"`
+	Error   string               `json:"error,omitempty"`
+	TaskID  string               `json:"task_id,omitempty"`
+	Items   []WorkflowStepResult `json:"items,omitempty"`
+}
+
+// WorkflowResult is the outcome of a workflow run. Status is failed when a
+// step failed and no later step consumed that failure through its When.
+type WorkflowResult struct {
+	Workflow string               `json:"workflow"`
+	RunID    string               `json:"run_id"`
+	Status   string               `json:"status"`
+	Steps    []WorkflowStepResult `json:"steps"` // In config order.
+	Elapsed  time.Duration        `json:"elapsed"`
+}
+
+// Report renders the result as a short Markdown summary.
+func (r *WorkflowResult) Report() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**Workflow %s** %s in %s\n", r.Workflow, r.Status, r.Elapsed.Round(time.Second))
+	for _, s := range r.Steps {
+		fmt.Fprintf(&b, "\n- **%s** (%s): %s", s.Name, s.Agent, s.Status)
+		if s.Error != "" {
+			fmt.Fprintf(&b, " — %s", s.Error)
+		}
+		if s.Summary != "" {
+			fmt.Fprintf(&b, "\n  %s", strings.ReplaceAll(s.Summary, "\n", "\n  "))
+		}
+	}
+	return b.String()
+}
+
+// WorkflowStepEvent is the data of EventWorkflowStep. It is published when a
+// step (or a fan-out element, with Index >= 0) starts and when it finishes.
+type WorkflowStepEvent struct {
+	Workflow string
+	RunID    string
+	Step     string
+	Index    int // Fan-out element index, or -1.
+	Agent    string
+	Status   string // WorkflowRunning or a final step status.
+	Summary  string
+}
+
+// Workflows returns the configured workflows.
+func (e *Engine) Workflows() []WorkflowConfig { return e.cfg.Workflows }
+
+// RunWorkflow runs the named workflow to completion. Every step is
+// materialised on the task board as a task blocked by the tasks of the steps
+// it needs. Ready steps run in parallel, each on a fresh instance of its agent
+// that reports through task_complete. Outputs are passed between steps through
+// state store keys scoped to the run (see workflowRun.stateKey), so concurrent
+// runs of the same workflow do not see each other's data.
+//
+// A step may start another workflow through run_workflow. The workflows
+// running in ctx are tracked so that a workflow cannot start itself, directly
+// or through others, and nesting stops at maxWorkflowDepth.
+//
+// Step failures are reported in the result; the error is non-nil only for
+// unknown workflows, rejected nesting or when ctx is cancelled.
+func (e *Engine) RunWorkflow(ctx context.Context, name, input string) (*WorkflowResult, error) {
+	i := slices.IndexFunc(e.cfg.Workflows, func(wf WorkflowConfig) bool { return wf.Name == name })
+	if i < 0 {
+		return nil, fmt.Errorf("engine: workflow %q not found", name)
+	}
+
+	stack := workflowStackFromContext(ctx)
+	if slices.Contains(stack, name) {
+		return nil, fmt.Errorf("engine: workflow %q is already running (%s)", name, strings.Join(append(stack, name), " -> "))
+	}
+	if len(stack) >= maxWorkflowDepth {
+		return nil, fmt.Errorf("engine: workflow %q: nesting exceeds %d workflows (%s)", name, maxWorkflowDepth, strings.Join(stack, " -> "))
+	}
+	ctx = withWorkflowStack(ctx, append(slices.Clip(stack), name))
+
+	r, err := e.newWorkflowRun(e.cfg.Workflows[i], input)
+	if err != nil {
+		return nil, err
+	}
+	return r.run(ctx)
+}
+
+// workflowRun holds the state of one RunWorkflow call.
+type workflowRun struct {
+	e       *Engine
+	wf      WorkflowConfig
+	id      string
+	input   string
+	prompts map[string]*template.Template
+	taskIDs map[string]string
+	results map[string]*WorkflowStepResult
+}
+
+func (e *Engine) newWorkflowRun(wf WorkflowConfig, input string) (*workflowRun, error) {
+	r := &workflowRun{
+		e:       e,
+		wf:      wf,
+		id:      fmt.Sprintf("%s-%d", wf.Name, e.workflowRuns.Add(1)),
+		input:   input,
+		prompts: make(map[string]*template.Template, len(wf.Steps)),
+		taskIDs: make(map[string]string, len(wf.Steps)),
+		results: make(map[string]*WorkflowStepResult, len(wf.Steps)),
+	}
+	for _, st := range wf.Steps {
+		tmpl, err := parseWorkflowPrompt(st.Name, st.Prompt, r.lookup)
+		if err != nil {
+			return nil, fmt.Errorf("engine: workflow %q: step %q: %w", wf.Name, st.Name, err)
+		}
+		r.prompts[st.Name] = tmpl
+	}
+	return r, nil
+}
+
+// run materialises the steps on the task board and executes them.
+func (r *workflowRun) run(ctx context.Context) (*WorkflowResult, error) {
+	start := time.Now()
+
+	order, err := workflowOrder(r.wf.Steps)
+	if err != nil {
+		return nil, fmt.Errorf("engine: workflow %q: %w", r.wf.Name, err)
+	}
+	for _, st := range order {
+		blockedBy := make([]string, 0, len(st.Needs))
+		for _, n := range st.Needs {
+			blockedBy = append(blockedBy, r.taskIDs[n])
+		}
+		id, err := r.e.taskStore.Create(tasks.Task{
+			Title:       r.wf.Name + "/" + st.Name,
+			Description: fmt.Sprintf("Workflow %s step %s (agent %s)", r.wf.Name, st.Name, st.Agent),
+			BlockedBy:   blockedBy,
+			Metadata:    map[string]any{"workflow": r.wf.Name, "workflow_run": r.id, "step": st.Name},
+			CreatedBy:   "workflow:" + r.wf.Name,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("engine: workflow %q: %w", r.wf.Name, err)
+		}
+		r.taskIDs[st.Name] = id
+	}
+
+	type finished struct {
+		step string
+		res  WorkflowStepResult
+	}
+	done := make(chan finished)
+	pending := make(map[string]WorkflowStepConfig, len(r.wf.Steps))
+	for _, st := range r.wf.Steps {
+		pending[st.Name] = st
+	}
+	running := 0
+
+	for {
+		// Start or skip every step whose needs have finished. Skipping can
+		// unblock further steps, so repeat until nothing changes.
+		for progress := ctx.Err() == nil; progress; {
+			progress = false
+			for _, st := range r.wf.Steps {
+				if _, ok := pending[st.Name]; !ok || !r.needsFinished(st) {
+					continue
+				}
+				delete(pending, st.Name)
+				progress = true
+
+				if reason := r.skipReason(st); reason != "" {
+					r.finish(ctx, st, WorkflowStepResult{Status: WorkflowSkipped, Error: reason})
+					continue
+				}
+				// Claim and snapshot the needed results here: r.results is
+				// only touched by this goroutine.
+				r.claimTask(st)
+				data := workflowPromptData{Input: r.input, Index: -1, Steps: r.stepResults(st)}
+				running++
+				go func() {
+					done <- finished{step: st.Name, res: r.runStep(ctx, st, data)}
+				}()
+			}
+		}
+
+		if running == 0 {
+			break
+		}
+		f := <-done
+		running--
+		for _, st := range r.wf.Steps {
+			if st.Name == f.step {
+				r.finish(ctx, st, f.res)
+			}
+		}
+	}
+
+	// Steps left pending were never reached because ctx was cancelled.
+	for _, st := range r.wf.Steps {
+		if _, ok := pending[st.Name]; ok {
+			r.finish(ctx, st, WorkflowStepResult{Status: WorkflowSkipped, Error: "workflow canceled"})
+		}
+	}
+
+	res := &WorkflowResult{
+		Workflow: r.wf.Name,
+		RunID:    r.id,
+		Status:   r.status(),
+		Elapsed:  time.Since(start),
+	}
+	for _, st := range r.wf.Steps {
+		res.Steps = append(res.Steps, *r.results[st.Name])
+	}
+	return res, ctx.Err()
+}
+
+// needsFinished reports whether every step st needs has a result.
+func (r *workflowRun) needsFinished(st WorkflowStepConfig) bool {
+	for _, n := range st.Needs {
+		if _, ok := r.results[n]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// skipReason returns why st must not run, or "" when its conditions hold.
+func (r *workflowRun) skipReason(st WorkflowStepConfig) string {
+	for _, n := range st.Needs {
+		got := r.results[n].Status
+		want := st.When[n]
+		if want == "" {
+			want = WorkflowCompleted
+		}
+		switch {
+		case got == WorkflowSkipped:
+			return fmt.Sprintf("%s was skipped", n)
+		case want == workflowWhenAny, got == want:
+		default:
+			return fmt.Sprintf("%s %s, want %s", n, got, want)
+		}
+	}
+	return ""
+}
+
+// finish records a step result (once) and mirrors it on the task board.
+func (r *workflowRun) finish(ctx context.Context, st WorkflowStepConfig, res WorkflowStepResult) {
+	if _, ok := r.results[st.Name]; ok {
+		return
+	}
+	res.Name = st.Name
+	res.Agent = st.Agent
+	res.TaskID = r.taskIDs[st.Name]
+	r.results[st.Name] = &res
+
+	if res.Status == WorkflowSkipped {
+		_ = r.e.taskStore.Cancel(res.TaskID)
+	} else {
+		r.setTaskStatus(res.TaskID, res.Status, res.Summary)
+	}
+	r.publish(ctx, st, -1, res.Status, res.Summary)
+}
+
+// status is failed when a failed step was not consumed by a When condition.
+func (r *workflowRun) status() string {
+	handled := make(map[string]bool)
+	for _, st := range r.wf.Steps {
+		for dep, want := range st.When {
+			if want == WorkflowFailed || want == workflowWhenAny {
+				handled[dep] = true
+			}
+		}
+	}
+	for name, res := range r.results {
+		if res.Status == WorkflowFailed && !handled[name] {
+			return WorkflowFailed
+		}
+	}
+	return WorkflowCompleted
+}
+
+// runStep runs a claimed step, once or per for_each element.
+func (r *workflowRun) runStep(ctx context.Context, st WorkflowStepConfig, data workflowPromptData) WorkflowStepResult {
+	r.publish(ctx, st, -1, WorkflowRunning, "")
+
+	if st.ForEach == "" {
+		res := r.runAgent(ctx, st, data)
+		r.storeOutput(st, res.Summary)
+		return res
+	}
+
+	items, err := r.forEachItems(st.ForEach)
+	if err != nil {
+		return WorkflowStepResult{Status: WorkflowFailed, Error: err.Error()}
+	}
+
+	res := WorkflowStepResult{Status: WorkflowCompleted, Items: make([]WorkflowStepResult, len(items))}
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Go(func() {
+			id, err := r.e.taskStore.Create(tasks.Task{
+				Title:     fmt.Sprintf("%s/%s[%d]", r.wf.Name, st.Name, i),
+				Metadata:  map[string]any{"workflow": r.wf.Name, "workflow_run": r.id, "step": st.Name, "index": float64(i)},
+				CreatedBy: "workflow:" + r.wf.Name,
+			})
+			if err == nil {
+				_ = r.e.taskStore.Claim(id, st.Agent)
+			}
+			r.publish(ctx, st, i, WorkflowRunning, "")
+
+			d := data
+			d.Item, d.Index = item, i
+			ir := r.runAgent(ctx, st, d)
+			ir.Name = fmt.Sprintf("%s[%d]", st.Name, i)
+			ir.Agent = st.Agent
+			ir.TaskID = id
+			if err == nil {
+				r.setTaskStatus(id, ir.Status, ir.Summary)
+			}
+			r.publish(ctx, st, i, ir.Status, ir.Summary)
+			res.Items[i] = ir
+		})
+	}
+	wg.Wait()
+
+	summaries := make([]string, len(items))
+	failed := 0
+	for i, ir := range res.Items {
+		summaries[i] = ir.Summary
+		if ir.Status == WorkflowFailed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		res.Status = WorkflowFailed
+		res.Error = fmt.Sprintf("%d of %d items failed", failed, len(items))
+	}
+	res.Summary = fmt.Sprintf("%d items, %d failed", len(items), failed)
+	r.storeOutput(st, summaries)
+	return res
+}
+
+// runAgent renders the step prompt and runs a fresh instance of the step's
+// agent on it.
+func (r *workflowRun) runAgent(ctx context.Context, st WorkflowStepConfig, data workflowPromptData) WorkflowStepResult {
+	var prompt bytes.Buffer
+	if err := r.prompts[st.Name].Execute(&prompt, data); err != nil {
+		return WorkflowStepResult{Status: WorkflowFailed, Error: fmt.Sprintf("render prompt: %v", err)}
+	}
+
+	a, ok := r.e.registry.SpawnTask(st.Agent, prompt.String())
+	if !ok {
+		return WorkflowStepResult{Status: WorkflowFailed, Error: fmt.Sprintf("agent %q not found", st.Agent)}
+	}
+
+	parent := agentctx.AgentNameFromContext(ctx)
+	sid, _ := sessionIDFromContext(ctx)
+	r.e.events.publish(EventAgentStart, sid, a.Name(), agent.AgentEventData{Prefix: a.Prefix(), Parent: parent, ProviderLabel: a.ProviderLabel(), Task: prompt.String()})
+
+	reply, err := a.Run(ctx)
+
+	res := WorkflowStepResult{Status: WorkflowCompleted, Summary: reply.TextContent()}
+	if cr := a.CompletionResult(); cr != nil {
+		if cr.Status != "" {
+			res.Status = cr.Status
+		}
+		if cr.Summary != "" {
+			res.Summary = cr.Summary
+		}
+	}
+	if err != nil {
+		res.Status = WorkflowFailed
+		res.Error = err.Error()
+	}
+
+	r.e.events.publish(EventAgentEnd, sid, a.Name(), agent.AgentEventData{Prefix: a.Prefix(), Parent: parent, ProviderLabel: a.ProviderLabel(), Summary: res.Summary})
+	return res
+}
+
+// stepResults returns the results of the steps st needs, for its prompt.
+func (r *workflowRun) stepResults(st WorkflowStepConfig) map[string]WorkflowStepResult {
+	out := make(map[string]WorkflowStepResult, len(st.Needs))
+	for _, n := range st.Needs {
+		out[n] = *r.results[n]
+	}
+	return out
+}
+
+// forEachItems reads the JSON array stored under key. A JSON string holding
+// an array (as written by an Output key) is decoded as well.
+func (r *workflowRun) forEachItems(key string) ([]string, error) {
+	raw, ok := r.lookup(key)
+	if !ok {
+		return nil, fmt.Errorf("for_each: state key %q is not set", key)
+	}
+
+	var elems []json.RawMessage
+	if err := json.Unmarshal(raw, &elems); err != nil {
+		var s string
+		if json.Unmarshal(raw, &s) != nil || json.Unmarshal([]byte(s), &elems) != nil {
+			return nil, fmt.Errorf("for_each: state key %q does not hold a JSON array", key)
+		}
+	}
+
+	items := make([]string, len(elems))
+	for i, el := range elems {
+		items[i] = stateText(el)
+	}
+	return items, nil
+}
+
+// storeOutput writes v under the step's output key, if any.
+func (r *workflowRun) storeOutput(st WorkflowStepConfig, v any) {
+	if st.Output == "" {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	r.e.store.Set(r.stateKey(st.Output), data)
+}
+
+// stateKey returns the state store key under which this run keeps key:
+// wf/<run ID>/<key>.
+func (r *workflowRun) stateKey(key string) string {
+	return "wf/" + r.id + "/" + key
+}
+
+// lookup reads key as seen by this run: the run's own output under key if a
+// step wrote one, otherwise the shared key (e.g. a value seeded before the
+// run).
+func (r *workflowRun) lookup(key string) (json.RawMessage, bool) {
+	if v, ok := r.e.store.Get(r.stateKey(key)); ok {
+		return v, true
+	}
+	return r.e.store.Get(key)
+}
+
+// claimTask marks a step task in progress. Needs that finished without
+// completing (allowed through When) are dropped from BlockedBy first so that
+// the board reflects the resolved edge.
+func (r *workflowRun) claimTask(st WorkflowStepConfig) {
+	id := r.taskIDs[st.Name]
+	t, ok := r.e.taskStore.Get(id)
+	if !ok {
+		return
+	}
+	if len(t.BlockedBy) > 0 {
+		var blockedBy []string
+		for _, n := range st.Needs {
+			if r.results[n].Status == WorkflowCompleted {
+				blockedBy = append(blockedBy, r.taskIDs[n])
+			}
+		}
+		_ = r.e.taskStore.Update(id, tasks.Update{BlockedBy: &blockedBy})
+	}
+	_ = r.e.taskStore.Claim(id, st.Agent)
+}
+
+// setTaskStatus mirrors a final step status on its task.
+func (r *workflowRun) setTaskStatus(id, status, summary string) {
+	s := tasks.StatusCompleted
+	if status == WorkflowFailed {
+		s = tasks.StatusFailed
+	}
+	upd := tasks.Update{Status: &s}
+	if summary != "" {
+		upd.Metadata = map[string]any{"summary": summary}
+	}
+	_ = r.e.taskStore.Update(id, upd)
+}
+
+// publish emits EventWorkflowStep.
+func (r *workflowRun) publish(ctx context.Context, st WorkflowStepConfig, index int, status, summary string) {
+	publishFromContext(r.e.events, ctx, EventWorkflowStep, WorkflowStepEvent{
+		Workflow: r.wf.Name,
+		RunID:    r.id,
+		Step:     st.Name,
+		Index:    index,
+		Agent:    st.Agent,
+		Status:   status,
+		Summary:  summary,
+	})
+}
+
+// --- context helpers for the workflow stack ---
+
+type workflowStackCtxKey struct{}
+
+// withWorkflowStack records the names of the workflows running in ctx,
+// outermost first.
+func withWorkflowStack(ctx context.Context, stack []string) context.Context {
+	return context.WithValue(ctx, workflowStackCtxKey{}, stack)
+}
+
+func workflowStackFromContext(ctx context.Context) []string {
+	v, _ := ctx.Value(workflowStackCtxKey{}).([]string)
+	return v
+}
+
+// workflowPromptData is the data available to step prompt templates.
+type workflowPromptData struct {
+	Input string                        // Input passed to RunWorkflow.
+	Item  string                        // Current for_each element.
+	Index int                           // Current for_each index, or -1.
+	Steps map[string]WorkflowStepResult // Results of the needed steps.
+}
+
+// parseWorkflowPrompt parses a step prompt. The state function reads a key
+// through lookup, which may be nil when only validating.
+func parseWorkflowPrompt(name, text string, lookup func(key string) (json.RawMessage, bool)) (*template.Template, error) {
+	funcs := template.FuncMap{
+		"state": func(key string) string {
+			if lookup == nil {
+				return ""
+			}
+			v, _ := lookup(key)
+			return stateText(v)
+		},
+	}
+	return template.New(name).Option("missingkey=zero").Funcs(funcs).Parse(text)
+}
+
+// stateText renders a JSON value for a prompt: strings unquoted, anything
+// else as JSON.
+func stateText(v json.RawMessage) string {
+	if len(v) == 0 {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(v, &s) == nil {
+		return s
+	}
+	return string(v)
+}
+
+// workflowOrder returns the steps in dependency order, or an error when the
+// needs form a cycle.
+func workflowOrder(steps []WorkflowStepConfig) ([]WorkflowStepConfig, error) {
+	placed := make(map[string]bool, len(steps))
+	order := make([]WorkflowStepConfig, 0, len(steps))
+	for len(order) < len(steps) {
+		progress := false
+		for _, st := range steps {
+			if placed[st.Name] {
+				continue
+			}
+			ready := true
+			for _, n := range st.Needs {
+				if !placed[n] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				placed[st.Name] = true
+				order = append(order, st)
+				progress = true
+			}
+		}
+		if !progress {
+			var cyclic []string
+			for _, st := range steps {
+				if !placed[st.Name] {
+					cyclic = append(cyclic, st.Name)
+				}
+			}
+			return nil, fmt.Errorf("steps %s form a dependency cycle", strings.Join(cyclic, ", "))
+		}
+	}
+	return order, nil
+}
+
+// workflowNames returns the configured workflow names, for tool descriptions.
+func workflowNames(wfs []WorkflowConfig) []string {
+	names := make([]string, 0, len(wfs))
+	for _, wf := range wfs {
+		names = append(names, wf.Name)
+	}
+	return slices.Sorted(slices.Values(names))
+}
+
+// workflowTools returns the toolbox exposing run_workflow.
+func (e *Engine) workflowTools(wfs []WorkflowConfig) *toolbox.ToolBox {
+	tb := toolbox.New()
+	tb.Register(toolbox.Tool{
+		Name: "run_workflow",
+		Description: fmt.Sprintf("Run a configured multi-step workflow and wait for its result. "+
+			"Available workflows: %s.", strings.Join(workflowNames(wfs), ", ")),
+		InputSchema: json.RawMessage(`{"type":"object","properties":{"workflow":{"type":"string","description":"Workflow name"},"input":{"type":"string","description":"Input passed to the step prompts as {{.Input}}"}},"required":["workflow"]}`),
+		Handler:     e.handleRunWorkflow,
+	})
+	return tb
+}
+
+type runWorkflowInput struct {
+	Workflow string `json:"workflow"`
+	Input    string `json:"input"`
+}
+
+func (e *Engine) handleRunWorkflow(ctx context.Context, input json.RawMessage) (string, error) {
+	var in runWorkflowInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", fmt.Errorf("invalid input: %w", err)
+	}
+
+	res, err := e.RunWorkflow(ctx, in.Workflow, in.Input)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := json.Marshal(res)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
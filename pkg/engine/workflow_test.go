@@ -0,0 +1,230 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/tasks"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// workflowCompleter answers "list" prompts with a JSON array, runs the
+// workflow named by "nest <name>" prompts through run_workflow, fails prompts
+// mentioning "bad", and echoes everything else.
+type workflowCompleter struct{}
+
+func (workflowCompleter) Complete(_ context.Context, ch *chat.Chat, _ []toolbox.Tool) (message.Message, error) {
+	last, _ := ch.Last()
+	if last.Role == role.Tool {
+		return message.NewText("bot", role.Assistant, "nested: "+last.Parts[0].(content.ToolResult).Content), nil
+	}
+	prompt := last.TextContent()
+	switch {
+	case strings.HasPrefix(prompt, "nest "):
+		return message.New("bot", role.Assistant, content.ToolCall{
+			ID:        "c1",
+			Name:      "run_workflow",
+			Arguments: fmt.Sprintf(`{"workflow":%q}`, strings.TrimPrefix(prompt, "nest ")),
+		}), nil
+	case strings.HasPrefix(prompt, "list"):
+		return message.NewText("bot", role.Assistant, `["good.go","bad.go"]`), nil
+	case strings.Contains(prompt, "bad"):
+		return message.Message{}, errors.New("cannot review")
+	}
+	return message.NewText("bot", role.Assistant, "done: "+prompt), nil
+}
+
+func newWorkflowEngine(t *testing.T, wfs ...WorkflowConfig) *Engine {
+	t.Helper()
+
+	return newTestEngine(t, "workflow", fixedProvider(workflowCompleter{}), Config{
+		Providers: []ProviderConfig{{Name: "p1", Kind: "workflow", Model: "test-model"}},
+		Agents:    []AgentConfig{{Name: "bot", Provider: "p1", Toolboxes: []ToolboxRef{{Name: "workflows"}}}},
+		Workflows: wfs,
+	})
+}
+
+func TestRunWorkflow_FanOutAndConditionalEdges(t *testing.T) {
+	eng := newWorkflowEngine(t, WorkflowConfig{
+		Name: "review",
+		Steps: []WorkflowStepConfig{
+			{Name: "list", Agent: "bot", Prompt: "list files in {{.Input}}", Output: "files"},
+			{Name: "review", Agent: "bot", Prompt: "review {{.Item}}", Needs: []string{"list"}, ForEach: "files", Output: "reviews"},
+			{Name: "triage", Agent: "bot", Prompt: "triage {{.Steps.review.Error}}", Needs: []string{"review"}, When: map[string]string{"review": WorkflowFailed}},
+			{Name: "ship", Agent: "bot", Prompt: "ship", Needs: []string{"review"}},
+			{Name: "announce", Agent: "bot", Prompt: "announce", Needs: []string{"ship"}},
+		},
+	})
+
+	res, err := eng.RunWorkflow(context.Background(), "review", "pkg/")
+	require.NoError(t, err)
+
+	steps := make(map[string]WorkflowStepResult)
+	for _, s := range res.Steps {
+		steps[s.Name] = s
+	}
+
+	assert.Equal(t, WorkflowCompleted, res.Status, "the review failure is handled by triage")
+	assert.Equal(t, WorkflowCompleted, steps["list"].Status)
+	assert.Equal(t, WorkflowFailed, steps["review"].Status)
+	require.Len(t, steps["review"].Items, 2)
+	assert.Equal(t, "done: review good.go", steps["review"].Items[0].Summary)
+	assert.Equal(t, WorkflowFailed, steps["review"].Items[1].Status)
+	assert.Equal(t, "done: triage 1 of 2 items failed", steps["triage"].Summary)
+	assert.Equal(t, WorkflowSkipped, steps["ship"].Status)
+	assert.Equal(t, WorkflowSkipped, steps["announce"].Status)
+
+	reviews, ok := eng.State().Get("wf/" + res.RunID + "/reviews")
+	require.True(t, ok)
+	_, ok = eng.State().Get("reviews")
+	assert.False(t, ok, "outputs are scoped to the run")
+	var got []string
+	require.NoError(t, json.Unmarshal(reviews, &got))
+	assert.Equal(t, []string{"done: review good.go", ""}, got)
+
+	board := make(map[string]tasks.Status)
+	for _, task := range eng.Tasks().List(tasks.Filter{}) {
+		board[task.Title] = task.Status
+	}
+	assert.Equal(t, tasks.StatusCompleted, board["review/list"])
+	assert.Equal(t, tasks.StatusFailed, board["review/review"])
+	assert.Equal(t, tasks.StatusCompleted, board["review/review[0]"])
+	assert.Equal(t, tasks.StatusFailed, board["review/review[1]"])
+	assert.Equal(t, tasks.StatusCompleted, board["review/triage"])
+	assert.Equal(t, tasks.StatusCanceled, board["review/ship"])
+	assert.Equal(t, tasks.StatusCanceled, board["review/announce"])
+}
+
+func TestRunWorkflow_ConcurrentRunsKeepOutputsApart(t *testing.T) {
+	eng := newWorkflowEngine(t, WorkflowConfig{
+		Name: "pipe",
+		Steps: []WorkflowStepConfig{
+			{Name: "first", Agent: "bot", Prompt: "first {{.Input}} {{state \"seed\"}}", Output: "out"},
+			{Name: "second", Agent: "bot", Prompt: "second {{state \"out\"}}", Needs: []string{"first"}},
+		},
+	})
+	eng.State().Set("seed", json.RawMessage(`"s"`))
+
+	inputs := []string{"one", "two", "three", "four"}
+	results := make([]*WorkflowResult, len(inputs))
+	var wg sync.WaitGroup
+	for i, in := range inputs {
+		wg.Go(func() {
+			res, err := eng.RunWorkflow(context.Background(), "pipe", in)
+			assert.NoError(t, err)
+			results[i] = res
+		})
+	}
+	wg.Wait()
+
+	for i, in := range inputs {
+		require.NotNil(t, results[i])
+		assert.Equal(t, "done: second done: first "+in+" s", results[i].Steps[1].Summary)
+	}
+	seed, _ := eng.State().Get("seed")
+	assert.JSONEq(t, `"s"`, string(seed), "shared keys are read, not overwritten")
+}
+
+func TestRunWorkflow_UnhandledFailure(t *testing.T) {
+	eng := newWorkflowEngine(t, WorkflowConfig{
+		Name: "single",
+		Steps: []WorkflowStepConfig{
+			{Name: "check", Agent: "bot", Prompt: "check {{.Input}}"},
+		},
+	})
+
+	res, err := eng.RunWorkflow(context.Background(), "single", "bad input")
+	require.NoError(t, err)
+	assert.Equal(t, WorkflowFailed, res.Status)
+	assert.Contains(t, res.Report(), "cannot review")
+
+	_, err = eng.RunWorkflow(context.Background(), "missing", "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `workflow "missing" not found`)
+}
+
+func TestRunWorkflow_Tool(t *testing.T) {
+	eng := newWorkflowEngine(t, WorkflowConfig{
+		Name:  "echo",
+		Steps: []WorkflowStepConfig{{Name: "say", Agent: "bot", Prompt: "say {{.Input}}"}},
+	})
+
+	tool, ok := eng.toolboxes["workflows"].Get("run_workflow")
+	require.True(t, ok)
+	assert.Contains(t, tool.Description, "echo")
+
+	out, err := tool.Handler(context.Background(), json.RawMessage(`{"workflow":"echo","input":"hi"}`))
+	require.NoError(t, err)
+
+	var res WorkflowResult
+	require.NoError(t, json.Unmarshal([]byte(out), &res))
+	assert.Equal(t, WorkflowCompleted, res.Status)
+	assert.Equal(t, "done: say hi", res.Steps[0].Summary)
+}
+
+func TestRunWorkflow_RejectsReentry(t *testing.T) {
+	eng := newWorkflowEngine(t,
+		WorkflowConfig{Name: "outer", Steps: []WorkflowStepConfig{{Name: "call", Agent: "bot", Prompt: "nest inner"}}},
+		WorkflowConfig{Name: "inner", Steps: []WorkflowStepConfig{{Name: "call", Agent: "bot", Prompt: "nest outer"}}},
+	)
+
+	res, err := eng.RunWorkflow(context.Background(), "outer", "")
+	require.NoError(t, err)
+	assert.Equal(t, WorkflowCompleted, res.Status)
+
+	// The inner workflow ran, and its attempt to start outer again failed.
+	var inner WorkflowResult
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(res.Steps[0].Summary, "nested: ")), &inner))
+	assert.Equal(t, "inner", inner.Workflow)
+	assert.Equal(t, `nested: engine: workflow "outer" is already running (outer -> inner -> outer)`, inner.Steps[0].Summary)
+
+	ctx := withWorkflowStack(context.Background(), []string{"a", "b", "c", "d"})
+	_, err = eng.RunWorkflow(ctx, "inner", "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "nesting exceeds 4 workflows (a -> b -> c -> d)")
+}
+
+func TestWorkflowOrder_Cycle(t *testing.T) {
+	_, err := workflowOrder([]WorkflowStepConfig{
+		{Name: "a", Needs: []string{"b"}},
+		{Name: "b", Needs: []string{"a"}},
+		{Name: "c"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "steps a, b form a dependency cycle")
+}
+
+func TestRunBatch_WorkflowTask(t *testing.T) {
+	eng := newWorkflowEngine(t, WorkflowConfig{
+		Name:  "echo",
+		Steps: []WorkflowStepConfig{{Name: "say", Agent: "bot", Prompt: "say {{.Input}}"}},
+	})
+
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.jsonl")
+	outputPath := filepath.Join(dir, "out.jsonl")
+	require.NoError(t, os.WriteFile(tasksPath, []byte(`{"id":"w1","workflow":"echo","task":"hi"}`+"\n"), 0o600))
+
+	summary, err := RunBatch(context.Background(), eng, tasksPath, outputPath, BatchOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, summary.Completed)
+
+	results := readBatchResults(t, outputPath)
+	require.Len(t, results, 1)
+	assert.Equal(t, "echo", results[0].Workflow)
+	require.Len(t, results[0].Steps, 1)
+	assert.Equal(t, "done: say hi", results[0].Steps[0].Summary)
+}
@@ -0,0 +1,293 @@
+// Package hooks runs user-defined external commands at agent lifecycle
+// events (tool calls, agent runs, prompt submission, session end). Each hook
+// receives a JSON Input on stdin and may reply with a JSON Output on stdout
+// to approve or deny the action, rewrite a tool call's input, or append
+// context for the model.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Event identifies the lifecycle point a hook is attached to.
+type Event string
+
+// Supported hook events.
+const (
+	PreToolCall      Event = "pre_tool_call"      // Before a tool runs. Can deny or rewrite the input.
+	PostToolCall     Event = "post_tool_call"     // After a tool runs. Can deny (replace the result) or append context.
+	AgentStart       Event = "agent_start"        // Before an agent's run begins. Can deny the run.
+	AgentEnd         Event = "agent_end"          // After an agent's run finishes. Notification only.
+	UserPromptSubmit Event = "user_prompt_submit" // Before a user prompt enters the chat. Can deny or append context.
+	SessionEnd       Event = "session_end"        // When a session is removed or the engine closes. Notification only.
+)
+
+// Events lists all supported hook events.
+var Events = []Event{PreToolCall, PostToolCall, AgentStart, AgentEnd, UserPromptSubmit, SessionEnd}
+
+// Valid reports whether e is a supported event.
+func (e Event) Valid() bool {
+	for _, ev := range Events {
+		if e == ev {
+			return true
+		}
+	}
+	return false
+}
+
+// CanDeny reports whether hooks for e can deny the action they observe.
+// Notification events (agent_end, session_end) cannot.
+func (e Event) CanDeny() bool {
+	return e != AgentEnd && e != SessionEnd
+}
+
+// DefaultTimeout bounds a single hook execution when Hook.Timeout is zero.
+const DefaultTimeout = 30 * time.Second
+
+// denyExitCode is the exit status a hook uses to deny an action without
+// printing JSON; stderr becomes the reason.
+const denyExitCode = 2
+
+// Decision is a hook's verdict on the action it observed.
+type Decision string
+
+// Hook decisions. An empty decision defers to other hooks.
+const (
+	Approve Decision = "approve"
+	Deny    Decision = "deny"
+)
+
+// ErrorPolicy decides what happens to the action when a hook fails to
+// start, times out, exits with an unexpected status or prints invalid JSON.
+type ErrorPolicy string
+
+// Hook error policies.
+const (
+	OnErrorDefault ErrorPolicy = ""      // OnErrorDeny for pre_tool_call, OnErrorAllow for other events.
+	OnErrorAllow   ErrorPolicy = "allow" // Skip the failed hook (fail open).
+	OnErrorDeny    ErrorPolicy = "deny"  // Deny the action (fail closed).
+)
+
+// Valid reports whether p is a supported policy.
+func (p ErrorPolicy) Valid() bool {
+	return p == OnErrorDefault || p == OnErrorAllow || p == OnErrorDeny
+}
+
+// Hook is a single external command bound to an event.
+type Hook struct {
+	Event   Event
+	Command string
+	Args    []string
+	Matcher *regexp.Regexp // Filters tool events by tool name and agent events by agent name (nil = match all).
+	Timeout time.Duration  // Per-execution limit (0 = DefaultTimeout).
+	OnError ErrorPolicy    // What a failure of the hook does to the action.
+}
+
+// failClosed reports whether a failure of the hook denies the action.
+func (h Hook) failClosed() bool {
+	switch h.OnError {
+	case OnErrorDeny:
+		return h.Event.CanDeny()
+	case OnErrorAllow:
+		return false
+	default:
+		return h.Event == PreToolCall
+	}
+}
+
+// Input is the JSON document written to a hook's stdin.
+type Input struct {
+	Event      Event           `json:"event"`
+	SessionID  string          `json:"session_id,omitempty"`
+	Agent      string          `json:"agent,omitempty"`
+	Cwd        string          `json:"cwd,omitempty"`
+	ToolName   string          `json:"tool_name,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+	ToolInput  json.RawMessage `json:"tool_input,omitempty"`
+	ToolResult string          `json:"tool_result,omitempty"`
+	IsError    bool            `json:"is_error,omitempty"`
+	Prompt     string          `json:"prompt,omitempty"`
+	Reply      string          `json:"reply,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// Output is the JSON document a hook may print to stdout. Empty stdout is
+// equivalent to an empty Output.
+type Output struct {
+	Decision          Decision        `json:"decision,omitempty"`
+	Reason            string          `json:"reason,omitempty"`
+	ToolInput         json.RawMessage `json:"tool_input,omitempty"`         // Replacement tool input (pre_tool_call only).
+	AdditionalContext string          `json:"additional_context,omitempty"` // Text appended for the model.
+}
+
+// Denied reports whether the output denies the action.
+func (o Output) Denied() bool { return o.Decision == Deny }
+
+// Runner executes the hooks registered for each event. A nil *Runner is
+// valid and runs nothing.
+type Runner struct {
+	dir   string
+	hooks []Hook
+}
+
+// NewRunner creates a Runner that executes hooks with dir as the working
+// directory (empty = the process working directory).
+func NewRunner(dir string, hooks []Hook) *Runner {
+	return &Runner{dir: dir, hooks: hooks}
+}
+
+// Has reports whether any hook is registered for event.
+func (r *Runner) Has(event Event) bool {
+	if r == nil {
+		return false
+	}
+	for _, h := range r.hooks {
+		if h.Event == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Run executes the hooks matching in.Event in declaration order and merges
+// their outputs:
+//
+//   - The first deny stops the chain and is returned as-is.
+//   - A replacement tool_input is passed to subsequent hooks and returned.
+//   - additional_context values are joined with blank lines.
+//
+// Hooks that fail to start, time out, exit with an unexpected status, or
+// print invalid JSON deny the action when their ErrorPolicy says so (the
+// default for pre_tool_call), with the failure as the reason. Otherwise they
+// are skipped (fail open). Either way their errors are joined into the
+// returned error while the merged Output remains usable.
+func (r *Runner) Run(ctx context.Context, in Input) (Output, error) {
+	if r == nil {
+		return Output{}, nil
+	}
+
+	if in.Cwd == "" {
+		in.Cwd = r.dir
+	}
+
+	var (
+		merged   Output
+		contexts []string
+		errs     []error
+	)
+
+	for _, h := range r.hooks {
+		if h.Event != in.Event || !h.matches(in) {
+			continue
+		}
+
+		out, err := r.exec(ctx, h, in)
+		if err != nil {
+			err = fmt.Errorf("hooks: %s %q: %w", h.Event, h.Command, err)
+			errs = append(errs, err)
+			if h.failClosed() {
+				return Output{Decision: Deny, Reason: err.Error()}, errors.Join(errs...)
+			}
+			continue
+		}
+
+		if out.Denied() {
+			return out, errors.Join(errs...)
+		}
+		if out.Decision == Approve {
+			merged.Decision = Approve
+			if out.Reason != "" {
+				merged.Reason = out.Reason
+			}
+		}
+		if len(out.ToolInput) > 0 {
+			in.ToolInput = out.ToolInput
+			merged.ToolInput = out.ToolInput
+		}
+		if s := strings.TrimSpace(out.AdditionalContext); s != "" {
+			contexts = append(contexts, s)
+		}
+	}
+
+	merged.AdditionalContext = strings.Join(contexts, "\n\n")
+
+	return merged, errors.Join(errs...)
+}
+
+// matches reports whether the hook's matcher accepts the input's subject.
+func (h Hook) matches(in Input) bool {
+	if h.Matcher == nil {
+		return true
+	}
+
+	switch in.Event {
+	case PreToolCall, PostToolCall:
+		return h.Matcher.MatchString(in.ToolName)
+	case AgentStart, AgentEnd:
+		return h.Matcher.MatchString(in.Agent)
+	default:
+		return true
+	}
+}
+
+// exec runs a single hook and decodes its output.
+func (r *Runner) exec(ctx context.Context, h Hook, in Input) (Output, error) {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return Output{}, err
+	}
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.Command, h.Args...) //nolint:gosec // hook commands come from trusted config
+	cmd.Dir = r.dir
+	cmd.WaitDelay = time.Second // don't wait on grandchildren holding stdout open after a timeout
+	cmd.Stdin = bytes.NewReader(payload)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == denyExitCode && ctx.Err() == nil {
+			return Output{Decision: Deny, Reason: strings.TrimSpace(stderr.String())}, nil
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return Output{}, fmt.Errorf("%w: %s", err, msg)
+		}
+		return Output{}, err
+	}
+
+	raw := bytes.TrimSpace(stdout.Bytes())
+	if len(raw) == 0 {
+		return Output{}, nil
+	}
+
+	var out Output
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return Output{}, fmt.Errorf("invalid output: %w", err)
+	}
+
+	switch out.Decision {
+	case "", Approve, Deny:
+	default:
+		return Output{}, fmt.Errorf("invalid decision %q", out.Decision)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,194 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shHook builds a hook that runs script with sh.
+func shHook(event Event, script string) Hook {
+	return Hook{Event: event, Command: "sh", Args: []string{"-c", script}}
+}
+
+func TestEvent_Valid(t *testing.T) {
+	assert.True(t, PreToolCall.Valid())
+	assert.True(t, SessionEnd.Valid())
+	assert.False(t, Event("on_save").Valid())
+}
+
+func TestRunner_NilIsNoop(t *testing.T) {
+	var r *Runner
+	assert.False(t, r.Has(PreToolCall))
+
+	out, err := r.Run(context.Background(), Input{Event: PreToolCall})
+	require.NoError(t, err)
+	assert.Equal(t, Output{}, out)
+}
+
+func TestRunner_ReceivesInputOnStdin(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRunner(dir, []Hook{shHook(PostToolCall, "cat > input.json")})
+
+	_, err := r.Run(context.Background(), Input{
+		Event:     PostToolCall,
+		ToolName:  "fs_write",
+		ToolInput: json.RawMessage(`{"path":"a.go"}`),
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "input.json"))
+	require.NoError(t, err)
+
+	var got Input
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, PostToolCall, got.Event)
+	assert.Equal(t, "fs_write", got.ToolName)
+	assert.Equal(t, dir, got.Cwd)
+	assert.JSONEq(t, `{"path":"a.go"}`, string(got.ToolInput))
+}
+
+func TestRunner_DenyStopsChain(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRunner(dir, []Hook{
+		shHook(PreToolCall, `echo '{"decision":"deny","reason":"policy"}'`),
+		shHook(PreToolCall, "touch ran"),
+	})
+
+	out, err := r.Run(context.Background(), Input{Event: PreToolCall, ToolName: "exec_run"})
+	require.NoError(t, err)
+	assert.True(t, out.Denied())
+	assert.Equal(t, "policy", out.Reason)
+	assert.NoFileExists(t, filepath.Join(dir, "ran"))
+}
+
+func TestRunner_ExitCodeTwoDenies(t *testing.T) {
+	r := NewRunner("", []Hook{shHook(PreToolCall, "echo 'rm is not allowed' >&2; exit 2")})
+
+	out, err := r.Run(context.Background(), Input{Event: PreToolCall})
+	require.NoError(t, err)
+	assert.True(t, out.Denied())
+	assert.Equal(t, "rm is not allowed", out.Reason)
+}
+
+func TestRunner_ModifiedInputChains(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRunner(dir, []Hook{
+		shHook(PreToolCall, `echo '{"tool_input":{"command":"ls -la"}}'`),
+		shHook(PreToolCall, "cat > second.json"),
+	})
+
+	out, err := r.Run(context.Background(), Input{Event: PreToolCall, ToolInput: json.RawMessage(`{"command":"ls"}`)})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"command":"ls -la"}`, string(out.ToolInput))
+
+	data, err := os.ReadFile(filepath.Join(dir, "second.json"))
+	require.NoError(t, err)
+
+	var got Input
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.JSONEq(t, `{"command":"ls -la"}`, string(got.ToolInput))
+}
+
+func TestRunner_JoinsAdditionalContext(t *testing.T) {
+	r := NewRunner("", []Hook{
+		shHook(UserPromptSubmit, `echo '{"additional_context":"first"}'`),
+		shHook(UserPromptSubmit, `echo '{"decision":"approve","additional_context":"second"}'`),
+	})
+
+	out, err := r.Run(context.Background(), Input{Event: UserPromptSubmit, Prompt: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, Approve, out.Decision)
+	assert.Equal(t, "first\n\nsecond", out.AdditionalContext)
+}
+
+func TestRunner_MatcherFiltersByToolName(t *testing.T) {
+	dir := t.TempDir()
+	h := shHook(PostToolCall, "touch matched")
+	h.Matcher = regexp.MustCompile(`^fs_(write|edit)$`)
+	r := NewRunner(dir, []Hook{h})
+
+	_, err := r.Run(context.Background(), Input{Event: PostToolCall, ToolName: "fs_read"})
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "matched"))
+
+	_, err = r.Run(context.Background(), Input{Event: PostToolCall, ToolName: "fs_write"})
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "matched"))
+}
+
+func TestRunner_FailuresAreSkipped(t *testing.T) {
+	slow := shHook(PostToolCall, "sleep 5")
+	slow.Timeout = 50 * time.Millisecond
+
+	r := NewRunner("", []Hook{
+		shHook(PostToolCall, "echo boom >&2; exit 1"),
+		shHook(PostToolCall, "echo not-json"),
+		slow,
+		shHook(PostToolCall, `echo '{"additional_context":"ok"}'`),
+	})
+
+	out, err := r.Run(context.Background(), Input{Event: PostToolCall})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+	assert.Contains(t, err.Error(), "invalid output")
+	assert.False(t, out.Denied())
+	assert.Equal(t, "ok", out.AdditionalContext)
+}
+
+func TestRunner_PreToolCallFailuresDeny(t *testing.T) {
+	slow := shHook(PreToolCall, "sleep 5")
+	slow.Timeout = 50 * time.Millisecond
+
+	tests := []struct {
+		name string
+		hook Hook
+		want string
+	}{
+		{"crash", shHook(PreToolCall, "echo boom >&2; exit 1"), "boom"},
+		{"invalid json", shHook(PreToolCall, "echo not-json"), "invalid output"},
+		{"timeout", slow, "signal: killed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			r := NewRunner(dir, []Hook{tt.hook, shHook(PreToolCall, "touch later")})
+
+			out, err := r.Run(context.Background(), Input{Event: PreToolCall, ToolName: "exec_run"})
+			require.Error(t, err)
+			assert.True(t, out.Denied(), "pre_tool_call fails closed by default")
+			assert.Contains(t, out.Reason, tt.want)
+			assert.NoFileExists(t, filepath.Join(dir, "later"), "the deny stops the chain")
+		})
+	}
+}
+
+func TestRunner_OnErrorPolicy(t *testing.T) {
+	open := shHook(PreToolCall, "exit 1")
+	open.OnError = OnErrorAllow
+
+	out, err := NewRunner("", []Hook{open}).Run(context.Background(), Input{Event: PreToolCall})
+	require.Error(t, err)
+	assert.False(t, out.Denied(), "allow opts pre_tool_call out of failing closed")
+
+	closed := shHook(UserPromptSubmit, "exit 1")
+	closed.OnError = OnErrorDeny
+
+	out, err = NewRunner("", []Hook{closed}).Run(context.Background(), Input{Event: UserPromptSubmit})
+	require.Error(t, err)
+	assert.True(t, out.Denied(), "deny makes other events fail closed")
+
+	notify := shHook(AgentEnd, "exit 1")
+	notify.OnError = OnErrorDeny
+
+	out, err = NewRunner("", []Hook{notify}).Run(context.Background(), Input{Event: AgentEnd})
+	require.Error(t, err)
+	assert.False(t, out.Denied(), "notification events cannot deny")
+}
@@ -48,7 +48,8 @@ type Result struct {
 
 // pendingRequest ties a Request to its result delivery channel.
 type pendingRequest struct {
-	ctx    context.Context //nolint:containedctx // stored to propagate caller cancellation to fallback
-	req    Request
-	result chan Result
+	ctx         context.Context //nolint:containedctx // stored to propagate caller cancellation to fallback
+	req         Request
+	fingerprint string // set only while a journal or resumed batches are present
+	result      chan Result
 }
@@ -74,6 +74,8 @@ type Collector struct {
 	inner     modeladapter.Completer // sync fallback
 	opts      CollectorOpts
 	onEvent   atomic.Pointer[EventHandler]
+	journal   atomic.Pointer[Journal]
+	resume    resumeState
 
 	lifecycleCtx    context.Context
 	lifecycleCancel context.CancelFunc
@@ -135,6 +137,19 @@ func (c *Collector) SetUUIDFunc(fn func() string) { c.uuidFunc = fn }
 // Complete implements modeladapter.Completer. It enqueues the request into
 // the pending batch and blocks until the result is available.
 func (c *Collector) Complete(ctx context.Context, ch *chat.Chat, tools []toolbox.Tool) (message.Message, error) {
+	var fp string
+	if c.loadJournal() != nil || c.hasResumable() {
+		fp = Fingerprint(ch, tools)
+		res, ok, err := c.completeResumed(ctx, fp)
+		if err != nil {
+			return message.Message{}, err
+		}
+		if ok {
+			c.tracker.Add(res.Usage)
+			return res.Message, nil
+		}
+	}
+
 	resultCh := make(chan Result, 1)
 
 	req := pendingRequest{
@@ -144,7 +159,8 @@ func (c *Collector) Complete(ctx context.Context, ch *chat.Chat, tools []toolbox
 			Chat:  ch,
 			Tools: tools,
 		},
-		result: resultCh,
+		fingerprint: fp,
+		result:      resultCh,
 	}
 
 	c.enqueue(req)
@@ -242,12 +258,15 @@ func (c *Collector) flushBatch(batch []pendingRequest) {
 		return
 	}
 
+	c.journalSubmitted(batchID, batch)
+
 	c.emitEvent(EventPolling, map[string]any{
 		"batch_id": batchID,
 		"count":    len(reqs),
 	})
 
 	results, err := c.pollUntilDone(ctx, batchID)
+	c.finishBatch(batchID, err)
 	if err != nil {
 		c.emitEvent(EventFallback, map[string]any{
 			"reason":   "poll_error",
@@ -279,6 +298,10 @@ func (c *Collector) pollUntilDone(ctx context.Context, batchID string) (map[stri
 		}
 
 		if err := c.sleepFunc(ctx, c.opts.PollInterval); err != nil {
+			// Keep the batch running when it is journaled for a later resume.
+			if c.keepForResume(err) {
+				return nil, err
+			}
 			// Context cancelled — attempt to cancel the batch.
 			cancelCtx, cancelFn := context.WithTimeout(context.Background(), 10*time.Second)
 			_ = c.submitter.CancelBatch(cancelCtx, batchID)
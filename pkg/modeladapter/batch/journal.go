@@ -0,0 +1,215 @@
+package batch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+)
+
+// InFlight describes a submitted provider batch whose results have not been
+// collected yet. Journals persist it so that a restarted process can re-attach
+// to the batch through PollBatch instead of paying for the same requests twice.
+type InFlight struct {
+	BatchID  string            `json:"batch_id"`
+	Requests map[string]string `json:"requests"` // request fingerprint → request ID
+}
+
+// Journal records the lifecycle of submitted batches. Implementations must be
+// safe for concurrent use and should not block.
+type Journal interface {
+	// BatchSubmitted is called after a batch was accepted by the provider.
+	BatchSubmitted(b InFlight)
+	// BatchFinished is called once the batch results were collected or the
+	// batch was cancelled, after which it can no longer be re-attached.
+	BatchFinished(batchID string)
+}
+
+// Fingerprint returns a stable identifier for a completion request. Two
+// requests with the same messages (role and content parts) and tool names
+// share a fingerprint across processes. Senders and metadata are ignored.
+func Fingerprint(ch *chat.Chat, tools []toolbox.Tool) string {
+	h := sha256.New()
+	for _, m := range ch.Messages() {
+		fmt.Fprintf(h, "%s\x00", m.Role)
+		for _, p := range m.Parts {
+			fmt.Fprintf(h, "%T\x00%+v\x00", p, p)
+		}
+		h.Write([]byte{'\n'})
+	}
+	for _, t := range tools {
+		fmt.Fprintf(h, "tool\x00%s\x00", t.Name)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// resumeRef points a fingerprint at a request inside a re-attached batch.
+type resumeRef struct {
+	batchID   string
+	requestID string
+}
+
+// attachedBatch is a re-attached provider batch polled by a single goroutine.
+type attachedBatch struct {
+	done    chan struct{}
+	results map[string]Result
+	err     error
+}
+
+// resumeState holds the batches handed to Resume and the ones being polled.
+type resumeState struct {
+	mu       sync.Mutex
+	refs     map[string]resumeRef // fingerprint → request
+	attached map[string]*attachedBatch
+}
+
+// SetJournal registers a journal that is told about every submitted and
+// finished batch. While a journal is set, batches are left running at the
+// provider when the Collector is stopped so they can be resumed later.
+// Passing nil removes the journal.
+func (c *Collector) SetJournal(j Journal) {
+	if j == nil {
+		c.journal.Store(nil)
+		return
+	}
+	c.journal.Store(&j)
+}
+
+func (c *Collector) loadJournal() Journal {
+	if j := c.journal.Load(); j != nil {
+		return *j
+	}
+	return nil
+}
+
+// Resume hands previously journaled in-flight batches to the Collector. A
+// later Complete call whose fingerprint matches a journaled request waits for
+// that batch (polling it once, shared by all matching calls) instead of
+// submitting a new request. Unmatched or failed requests are submitted as
+// usual.
+func (c *Collector) Resume(batches []InFlight) {
+	c.resume.mu.Lock()
+	defer c.resume.mu.Unlock()
+
+	if c.resume.refs == nil {
+		c.resume.refs = make(map[string]resumeRef)
+	}
+	for _, b := range batches {
+		for fp, id := range b.Requests {
+			c.resume.refs[fp] = resumeRef{batchID: b.BatchID, requestID: id}
+		}
+	}
+}
+
+// hasResumable reports whether any resumed request is still unclaimed.
+func (c *Collector) hasResumable() bool {
+	c.resume.mu.Lock()
+	defer c.resume.mu.Unlock()
+	return len(c.resume.refs) > 0
+}
+
+// completeResumed serves a request from a re-attached batch. ok is false when
+// the fingerprint is unknown or the batch did not produce a usable result, in
+// which case the caller submits the request normally.
+func (c *Collector) completeResumed(ctx context.Context, fp string) (res Result, ok bool, err error) {
+	c.resume.mu.Lock()
+	ref, found := c.resume.refs[fp]
+	if !found {
+		c.resume.mu.Unlock()
+		return Result{}, false, nil
+	}
+	delete(c.resume.refs, fp)
+
+	if c.resume.attached == nil {
+		c.resume.attached = make(map[string]*attachedBatch)
+	}
+	ab, polling := c.resume.attached[ref.batchID]
+	if !polling {
+		ab = &attachedBatch{done: make(chan struct{})}
+		c.resume.attached[ref.batchID] = ab
+		go c.pollAttached(ref.batchID, ab)
+	}
+	c.resume.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return Result{}, false, ctx.Err()
+	case <-ab.done:
+	}
+
+	if ab.err != nil {
+		return Result{}, false, nil
+	}
+	r, present := ab.results[ref.requestID]
+	if !present || r.Err != nil {
+		return Result{}, false, nil
+	}
+	return r, true, nil
+}
+
+// pollAttached polls a re-attached batch until it is done and publishes the
+// outcome to every waiter.
+func (c *Collector) pollAttached(batchID string, ab *attachedBatch) {
+	defer close(ab.done)
+
+	ctx, cancel := context.WithTimeout(c.lifecycleCtx, c.opts.Timeout)
+	defer cancel()
+
+	c.emitEvent(EventPolling, map[string]any{
+		"batch_id": batchID,
+		"resumed":  true,
+	})
+
+	ab.results, ab.err = c.pollUntilDone(ctx, batchID)
+	c.finishBatch(batchID, ab.err)
+	if ab.err != nil {
+		c.emitEvent(EventFallback, map[string]any{
+			"reason":   "resume_error",
+			"error":    ab.err.Error(),
+			"batch_id": batchID,
+		})
+		return
+	}
+
+	c.emitEvent(EventCompleted, map[string]any{
+		"batch_id": batchID,
+		"count":    len(ab.results),
+		"resumed":  true,
+	})
+}
+
+// finishBatch tells the journal that batchID can no longer be resumed, unless
+// err shows the Collector was stopped while the batch was still running.
+func (c *Collector) finishBatch(batchID string, err error) {
+	j := c.loadJournal()
+	if j == nil || c.keepForResume(err) {
+		return
+	}
+	j.BatchFinished(batchID)
+}
+
+// keepForResume reports whether a batch interrupted by err should be left
+// running at the provider for a later Resume.
+func (c *Collector) keepForResume(err error) bool {
+	return err != nil && errors.Is(err, context.Canceled) && c.lifecycleCtx.Err() != nil && c.loadJournal() != nil
+}
+
+// journalSubmitted records a freshly submitted batch in the journal.
+func (c *Collector) journalSubmitted(batchID string, batch []pendingRequest) {
+	j := c.loadJournal()
+	if j == nil {
+		return
+	}
+	reqs := make(map[string]string, len(batch))
+	for _, pr := range batch {
+		if pr.fingerprint != "" {
+			reqs[pr.fingerprint] = pr.req.ID
+		}
+	}
+	j.BatchSubmitted(InFlight{BatchID: batchID, Requests: reqs})
+}
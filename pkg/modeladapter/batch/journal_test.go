@@ -0,0 +1,150 @@
+package batch_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/modeladapter/batch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memJournal records journal callbacks in memory.
+type memJournal struct {
+	mu       sync.Mutex
+	inFlight map[string]batch.InFlight
+	finished []string
+}
+
+func newMemJournal() *memJournal {
+	return &memJournal{inFlight: make(map[string]batch.InFlight)}
+}
+
+func (j *memJournal) BatchSubmitted(b batch.InFlight) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.inFlight[b.BatchID] = b
+}
+
+func (j *memJournal) BatchFinished(batchID string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.inFlight, batchID)
+	j.finished = append(j.finished, batchID)
+}
+
+func (j *memJournal) pending() []batch.InFlight {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := make([]batch.InFlight, 0, len(j.inFlight))
+	for _, b := range j.inFlight {
+		out = append(out, b)
+	}
+	return out
+}
+
+// gatedSubmitter reports batches as running until released.
+type gatedSubmitter struct {
+	*mockSubmitter
+	released atomic.Bool
+}
+
+func (g *gatedSubmitter) PollBatch(ctx context.Context, batchID string) (map[string]batch.Result, bool, error) {
+	if !g.released.Load() {
+		return nil, false, nil
+	}
+	return g.mockSubmitter.PollBatch(ctx, batchID)
+}
+
+func TestFingerprint(t *testing.T) {
+	a := chat.New(message.NewText("alice", role.User, "hello"))
+	b := chat.New(message.NewText("bob", role.User, "hello"))
+	c := chat.New(message.NewText("alice", role.User, "goodbye"))
+
+	assert.Equal(t, batch.Fingerprint(a, nil), batch.Fingerprint(b, nil), "sender is ignored")
+	assert.NotEqual(t, batch.Fingerprint(a, nil), batch.Fingerprint(c, nil))
+}
+
+func TestCollector_JournalRecordsBatches(t *testing.T) {
+	sub := newMockSubmitter()
+	c := batch.NewCollector(&mockCompleter{}, sub, batch.CollectorOpts{
+		CollectWindow: 10 * time.Millisecond,
+		PollInterval:  time.Millisecond,
+		Timeout:       5 * time.Second,
+	})
+	j := newMemJournal()
+	c.SetJournal(j)
+
+	_, err := c.Complete(context.Background(), chat.New(message.NewText("", role.User, "hello")), nil)
+	require.NoError(t, err)
+
+	assert.Empty(t, j.pending())
+	assert.Equal(t, []string{"batch-1"}, j.finished)
+}
+
+func TestCollector_ResumeReattachesToBatch(t *testing.T) {
+	sub := &gatedSubmitter{mockSubmitter: newMockSubmitter()}
+	opts := batch.CollectorOpts{
+		CollectWindow: 10 * time.Millisecond,
+		PollInterval:  time.Millisecond,
+		Timeout:       5 * time.Second,
+	}
+	newChat := func() *chat.Chat { return chat.New(message.NewText("", role.User, "hello")) }
+
+	// First process: submit, then stop while the batch is still running.
+	first := batch.NewCollector(&mockCompleter{}, sub, opts)
+	j := newMemJournal()
+	first.SetJournal(j)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = first.Complete(context.Background(), newChat(), nil)
+	}()
+	require.Eventually(t, func() bool { return len(j.pending()) == 1 }, 2*time.Second, time.Millisecond)
+	first.Stop()
+	<-done
+
+	assert.False(t, sub.cancelCalled.Load(), "journaled batch must stay running")
+	pending := j.pending()
+	require.Len(t, pending, 1)
+
+	// Second process: re-attach instead of submitting again.
+	sub.released.Store(true)
+	inner := &mockCompleter{}
+	second := batch.NewCollector(inner, sub, opts)
+	second.SetJournal(j)
+	second.Resume(pending)
+
+	msg, err := second.Complete(context.Background(), newChat(), nil)
+	require.NoError(t, err)
+	assert.Contains(t, msg.TextContent(), "batch response")
+	assert.Equal(t, 1, sub.batchCount(), "no new batch submitted")
+	assert.Equal(t, int32(0), inner.calls.Load())
+	assert.Empty(t, j.pending())
+
+	last, ok := second.UsageTracker().Last()
+	require.True(t, ok)
+	assert.Equal(t, 10, last.InputTokens)
+}
+
+func TestCollector_ResumeUnmatchedSubmitsNormally(t *testing.T) {
+	sub := newMockSubmitter()
+	c := batch.NewCollector(&mockCompleter{}, sub, batch.CollectorOpts{
+		CollectWindow: 10 * time.Millisecond,
+		PollInterval:  time.Millisecond,
+		Timeout:       5 * time.Second,
+	})
+	c.Resume([]batch.InFlight{{BatchID: "old", Requests: map[string]string{"other": "req"}}})
+
+	msg, err := c.Complete(context.Background(), chat.New(message.NewText("", role.User, "hello")), nil)
+	require.NoError(t, err)
+	assert.Contains(t, msg.TextContent(), "batch response")
+	assert.Equal(t, 1, sub.batchCount())
+}
@@ -0,0 +1,192 @@
+// Package bpe implements byte-level BPE tokenization with tiktoken
+// vocabularies, counting tokens exactly as OpenAI models see them.
+//
+// Vocabularies are tiktoken's ".tiktoken" files (one base64 token and its
+// rank per line). Builtin returns the bundled, gzip-compressed copies; LoadDir
+// reads them from a directory such as .shelly/local/tokenizers instead.
+package bpe
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encoding names.
+const (
+	Cl100kBase = "cl100k_base"
+	O200kBase  = "o200k_base"
+)
+
+// pieceCacheSize bounds the number of pre-tokenized pieces whose token
+// counts an Encoding remembers.
+const pieceCacheSize = 1 << 14
+
+// Encoding is a BPE vocabulary together with the pre-tokenizer that splits
+// text into pieces before merging. It is safe for concurrent use.
+type Encoding struct {
+	name  string
+	ranks map[string]int
+	split func(string) []string
+
+	mu    sync.Mutex
+	cache map[string]int
+}
+
+// Load reads a tiktoken vocabulary for the named encoding from r.
+func Load(name string, r io.Reader) (*Encoding, error) {
+	split, ok := splitters[name]
+	if !ok {
+		return nil, fmt.Errorf("bpe: unknown encoding %q", name)
+	}
+
+	ranks := make(map[string]int)
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		tok, rank, found := strings.Cut(text, " ")
+		if !found {
+			return nil, fmt.Errorf("bpe: %s line %d: missing rank", name, line)
+		}
+		b, err := base64.StdEncoding.DecodeString(tok)
+		if err != nil {
+			return nil, fmt.Errorf("bpe: %s line %d: %w", name, line, err)
+		}
+		n, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("bpe: %s line %d: %w", name, line, err)
+		}
+		ranks[string(b)] = n
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("bpe: read %s: %w", name, err)
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("bpe: %s: empty vocabulary", name)
+	}
+
+	return &Encoding{name: name, ranks: ranks, split: split, cache: make(map[string]int)}, nil
+}
+
+// LoadDir loads the named encoding from <dir>/<name>.tiktoken.
+func LoadDir(dir, name string) (*Encoding, error) {
+	f, err := os.Open(filepath.Join(dir, name+".tiktoken")) //nolint:gosec // dir comes from config, name is a known encoding.
+	if err != nil {
+		return nil, fmt.Errorf("bpe: %w", err)
+	}
+	defer f.Close() //nolint:errcheck // read-only file.
+
+	return Load(name, f)
+}
+
+// Name returns the encoding name, e.g. "o200k_base".
+func (e *Encoding) Name() string { return e.name }
+
+// Encode returns the token IDs of text. Special tokens are not recognized;
+// they are encoded as ordinary text.
+func (e *Encoding) Encode(text string) []int {
+	var ids []int
+	for _, piece := range e.split(text) {
+		if id, ok := e.ranks[piece]; ok {
+			ids = append(ids, id)
+			continue
+		}
+		for _, part := range e.merge(piece) {
+			ids = append(ids, e.ranks[part])
+		}
+	}
+	return ids
+}
+
+// CountTokens returns the number of tokens in text. It implements
+// modeladapter.Tokenizer.
+func (e *Encoding) CountTokens(text string) int {
+	n := 0
+	for _, piece := range e.split(text) {
+		n += e.countPiece(piece)
+	}
+	return n
+}
+
+func (e *Encoding) countPiece(piece string) int {
+	if _, ok := e.ranks[piece]; ok {
+		return 1
+	}
+
+	e.mu.Lock()
+	n, ok := e.cache[piece]
+	e.mu.Unlock()
+	if ok {
+		return n
+	}
+
+	n = len(e.merge(piece))
+
+	e.mu.Lock()
+	if len(e.cache) >= pieceCacheSize {
+		clear(e.cache)
+	}
+	e.cache[piece] = n
+	e.mu.Unlock()
+
+	return n
+}
+
+// merge splits piece into vocabulary tokens by repeatedly joining the
+// adjacent pair with the lowest rank, starting from single bytes.
+func (e *Encoding) merge(piece string) []string {
+	// bounds[i] is the start offset of part i; the last entry is len(piece).
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+
+	for len(bounds) > 2 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := e.ranks[piece[bounds[i]:bounds[i+2]]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+
+	parts := make([]string, len(bounds)-1)
+	for i := range parts {
+		parts[i] = piece[bounds[i]:bounds[i+1]]
+	}
+	return parts
+}
+
+// ForModel returns the encoding OpenAI models use, or "" when the model is
+// not a known OpenAI model.
+func ForModel(model string) string {
+	m := strings.ToLower(model)
+	if i := strings.LastIndex(m, "/"); i >= 0 {
+		m = m[i+1:]
+	}
+	switch {
+	case strings.HasPrefix(m, "gpt-4o"), strings.HasPrefix(m, "gpt-4.1"),
+		strings.HasPrefix(m, "gpt-4.5"), strings.HasPrefix(m, "gpt-5"),
+		strings.HasPrefix(m, "o1"), strings.HasPrefix(m, "o3"), strings.HasPrefix(m, "o4"),
+		strings.HasPrefix(m, "chatgpt-4o"), strings.HasPrefix(m, "gpt-oss"):
+		return O200kBase
+	case strings.HasPrefix(m, "gpt-4"), strings.HasPrefix(m, "gpt-3.5"),
+		strings.HasPrefix(m, "text-embedding-3"), strings.HasPrefix(m, "text-embedding-ada-002"):
+		return Cl100kBase
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+package bpe_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/modeladapter/bpe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// vocab returns a tiktoken file with every single byte (ranks 0-255)
+// followed by the given merges.
+func vocab(merges ...string) string {
+	var b strings.Builder
+	for i := range 256 {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), i)
+	}
+	for i, m := range merges {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(m)), 256+i)
+	}
+	return b.String()
+}
+
+func TestEncode_MergesLowestRankFirst(t *testing.T) {
+	enc, err := bpe.Load(bpe.Cl100kBase, strings.NewReader(vocab("he", "ll", "hell", " w", "or", " wor")))
+	require.NoError(t, err)
+
+	// "hello" merges he, ll, then hell; "o" stays a byte.
+	assert.Equal(t, []int{258, 'o'}, enc.Encode("hello"))
+	// " world" is its own piece: " w" and "or" merge into " wor".
+	assert.Equal(t, []int{258, 'o', 261, 'l', 'd'}, enc.Encode("hello world"))
+	assert.Equal(t, 5, enc.CountTokens("hello world"))
+	assert.Equal(t, 5, enc.CountTokens("hello world"), "cached count")
+}
+
+func TestCountTokens_WholePieceInVocabulary(t *testing.T) {
+	enc, err := bpe.Load(bpe.O200kBase, strings.NewReader(vocab("Hello", "World")))
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, enc.CountTokens("HelloWorld"))
+	assert.Equal(t, bpe.O200kBase, enc.Name())
+}
+
+func TestCountTokens_CJKFallsBackToBytes(t *testing.T) {
+	enc, err := bpe.Load(bpe.Cl100kBase, strings.NewReader(vocab()))
+	require.NoError(t, err)
+
+	// Without merges every UTF-8 byte is a token.
+	assert.Equal(t, len("日本語"), enc.CountTokens("日本語"))
+}
+
+func TestLoad_Errors(t *testing.T) {
+	_, err := bpe.Load("p50k_base", strings.NewReader(vocab()))
+	require.EqualError(t, err, `bpe: unknown encoding "p50k_base"`)
+
+	_, err = bpe.Load(bpe.Cl100kBase, strings.NewReader("aGk=\n"))
+	require.EqualError(t, err, "bpe: cl100k_base line 1: missing rank")
+
+	_, err = bpe.Load(bpe.Cl100kBase, strings.NewReader(""))
+	require.EqualError(t, err, "bpe: cl100k_base: empty vocabulary")
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "o200k_base.tiktoken"), []byte(vocab()), 0o600))
+
+	enc, err := bpe.LoadDir(dir, bpe.O200kBase)
+	require.NoError(t, err)
+	assert.Equal(t, 2, enc.CountTokens("hi"))
+
+	_, err = bpe.LoadDir(dir, bpe.Cl100kBase)
+	require.Error(t, err)
+}
+
+func TestBuiltin(t *testing.T) {
+	cl, err := bpe.Builtin(bpe.Cl100kBase)
+	require.NoError(t, err)
+	assert.Equal(t, []int{15339, 1917}, cl.Encode("hello world"))
+
+	o, err := bpe.Builtin(bpe.O200kBase)
+	require.NoError(t, err)
+	assert.Equal(t, []int{24912, 2375}, o.Encode("hello world"))
+
+	again, err := bpe.Builtin(bpe.O200kBase)
+	require.NoError(t, err)
+	assert.Same(t, o, again, "decoded once")
+
+	_, err = bpe.Builtin("p50k_base")
+	require.EqualError(t, err, `bpe: unknown encoding "p50k_base"`)
+}
+
+func TestForModel(t *testing.T) {
+	tests := map[string]string{
+		"gpt-4o-mini":        bpe.O200kBase,
+		"gpt-4.1":            bpe.O200kBase,
+		"gpt-5":              bpe.O200kBase,
+		"o3-mini":            bpe.O200kBase,
+		"openai/gpt-oss-20b": bpe.O200kBase,
+		"gpt-4-turbo":        bpe.Cl100kBase,
+		"gpt-3.5-turbo":      bpe.Cl100kBase,
+		"claude-sonnet-4":    "",
+		"grok-3":             "",
+	}
+	for model, want := range tests {
+		assert.Equal(t, want, bpe.ForModel(model), model)
+	}
+}
@@ -0,0 +1,46 @@
+package bpe
+
+import (
+	"compress/gzip"
+	"embed"
+	"fmt"
+	"sync"
+)
+
+// vocabFS holds the gzip-compressed tiktoken vocabularies of the supported
+// encodings, as published by OpenAI.
+//
+//go:embed vocab/*.tiktoken.gz
+var vocabFS embed.FS
+
+// builtins decodes each bundled vocabulary once, on first use.
+var builtins = map[string]func() (*Encoding, error){
+	Cl100kBase: sync.OnceValues(func() (*Encoding, error) { return loadBuiltin(Cl100kBase) }),
+	O200kBase:  sync.OnceValues(func() (*Encoding, error) { return loadBuiltin(O200kBase) }),
+}
+
+// Builtin returns the bundled vocabulary of the named encoding. It is decoded
+// on the first call and shared by later ones.
+func Builtin(name string) (*Encoding, error) {
+	load, ok := builtins[name]
+	if !ok {
+		return nil, fmt.Errorf("bpe: unknown encoding %q", name)
+	}
+	return load()
+}
+
+func loadBuiltin(name string) (*Encoding, error) {
+	f, err := vocabFS.Open("vocab/" + name + ".tiktoken.gz")
+	if err != nil {
+		return nil, fmt.Errorf("bpe: %w", err)
+	}
+	defer f.Close() //nolint:errcheck // embedded file.
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("bpe: %s: %w", name, err)
+	}
+	defer zr.Close() //nolint:errcheck // read-only stream.
+
+	return Load(name, zr)
+}
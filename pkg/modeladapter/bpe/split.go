@@ -0,0 +1,199 @@
+package bpe
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// splitters maps encoding names to their pre-tokenizers. Go's regexp lacks
+// the lookahead tiktoken's patterns use, so both are hand-written scanners
+// that follow the patterns alternative by alternative.
+var splitters = map[string]func(string) []string{
+	Cl100kBase: splitCl100k,
+	O200kBase:  splitO200k,
+}
+
+// splitCl100k splits text like cl100k_base's pattern:
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}|
+//	 ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func splitCl100k(text string) []string {
+	return splitWith(text, func(s string) int {
+		if n := matchContraction(s); n > 0 {
+			return n
+		}
+		if n := withPrefix(s, matchLetters); n > 0 {
+			return n
+		}
+		if n := matchDigits(s); n > 0 {
+			return n
+		}
+		if n := matchPunct(s, "\r\n"); n > 0 {
+			return n
+		}
+		return matchSpace(s)
+	})
+}
+
+// splitO200k splits text like o200k_base's pattern, which splits words at
+// case changes and keeps contractions attached:
+//
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func splitO200k(text string) []string {
+	return splitWith(text, func(s string) int {
+		if n := withPrefix(s, matchCasedWord); n > 0 {
+			return n
+		}
+		if n := matchDigits(s); n > 0 {
+			return n
+		}
+		if n := matchPunct(s, "\r\n/"); n > 0 {
+			return n
+		}
+		return matchSpace(s)
+	})
+}
+
+// splitWith cuts text into pieces with match, which returns the byte length
+// of the piece at the start of its argument.
+func splitWith(text string, match func(string) int) []string {
+	var pieces []string
+	for len(text) > 0 {
+		n := match(text)
+		if n <= 0 {
+			_, n = utf8.DecodeRuneInString(text)
+		}
+		pieces = append(pieces, text[:n])
+		text = text[n:]
+	}
+	return pieces
+}
+
+var contractions = []string{"'s", "'t", "'re", "'ve", "'m", "'ll", "'d"}
+
+// matchContraction matches (?i:'s|'t|'re|'ve|'m|'ll|'d).
+func matchContraction(s string) int {
+	for _, c := range contractions {
+		if len(s) >= len(c) && strings.EqualFold(s[:len(c)], c) {
+			return len(c)
+		}
+	}
+	return 0
+}
+
+// withPrefix matches [^\r\n\p{L}\p{N}]? followed by word, trying the
+// prefix first like a greedy regexp.
+func withPrefix(s string, word func(string) int) int {
+	r, size := utf8.DecodeRuneInString(s)
+	if r != '\r' && r != '\n' && !unicode.IsLetter(r) && !unicode.IsNumber(r) {
+		if n := word(s[size:]); n > 0 {
+			return size + n
+		}
+		return 0
+	}
+	return word(s)
+}
+
+// matchLetters matches \p{L}+.
+func matchLetters(s string) int {
+	return runLength(s, unicode.IsLetter)
+}
+
+// matchDigits matches \p{N}{1,3}.
+func matchDigits(s string) int {
+	n := 0
+	for i := 0; i < 3 && n < len(s); i++ {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if !unicode.IsNumber(r) {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+// matchPunct matches " ?[^\s\p{L}\p{N}]+[trail]*".
+func matchPunct(s, trail string) int {
+	n := 0
+	if strings.HasPrefix(s, " ") {
+		n = 1
+	}
+	body := runLength(s[n:], func(r rune) bool {
+		return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if body == 0 {
+		return 0
+	}
+	n += body
+	return n + runLength(s[n:], func(r rune) bool { return strings.ContainsRune(trail, r) })
+}
+
+// matchSpace matches \s*[\r\n]+|\s+(?!\S)|\s+.
+func matchSpace(s string) int {
+	run := runLength(s, unicode.IsSpace)
+	if run == 0 {
+		return 0
+	}
+
+	// \s*[\r\n]+ backtracks to end after the run's last line break.
+	if i := strings.LastIndexAny(s[:run], "\r\n"); i >= 0 {
+		return i + 1
+	}
+
+	// \s+(?!\S) leaves the last whitespace character to prefix the next
+	// word, unless the run ends the text.
+	if run == len(s) {
+		return run
+	}
+	_, last := utf8.DecodeLastRuneInString(s[:run])
+	if run > last {
+		return run - last
+	}
+	return run
+}
+
+// Character classes of o200k_base's word alternatives.
+func isUpperish(r rune) bool {
+	return unicode.In(r, unicode.Lu, unicode.Lt, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+func isLowerish(r rune) bool {
+	return unicode.In(r, unicode.Ll, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// matchCasedWord matches the word part of o200k_base's first two
+// alternatives, including the optional contraction suffix.
+func matchCasedWord(s string) int {
+	upper := runLength(s, isUpperish)
+	lower := runLength(s[upper:], isLowerish)
+
+	var n int
+	switch {
+	case lower > 0:
+		// [upper]*[lower]+
+		n = upper + lower
+	case upper > 0:
+		// [upper]+[lower]*, or [upper]*[lower]+ after backtracking when the
+		// last upper character is also lower-ish; both end here.
+		n = upper
+	default:
+		return 0
+	}
+	return n + matchContraction(s[n:])
+}
+
+// runLength returns the byte length of the leading run of runes matching f.
+func runLength(s string, f func(rune) bool) int {
+	n := 0
+	for n < len(s) {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if !f(r) {
+			break
+		}
+		n += size
+	}
+	return n
+}
@@ -0,0 +1,39 @@
+package bpe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitCl100k(t *testing.T) {
+	tests := map[string][]string{
+		"Hello world":          {"Hello", " world"},
+		"I'm here":             {"I", "'m", " here"},
+		"12345":                {"123", "45"},
+		"foo  bar":             {"foo", " ", " bar"},
+		"a\n\nb":               {"a", "\n\n", "b"},
+		"x = 1;\n":             {"x", " =", " ", "1", ";\n"},
+		"\tif x {\n\t\treturn": {"\tif", " x", " {\n", "\t", "\treturn"},
+		"end  ":                {"end", "  "},
+		"日本語です":                {"日本語です"},
+	}
+	for text, want := range tests {
+		assert.Equal(t, want, splitCl100k(text), "%q", text)
+	}
+}
+
+func TestSplitO200k(t *testing.T) {
+	tests := map[string][]string{
+		"HelloWorld":  {"Hello", "World"},
+		"don't stop":  {"don't", " stop"},
+		"HTTP server": {"HTTP", " server"},
+		"a/b/\n":      {"a", "/b", "/\n"},
+		"1234 apples": {"123", "4", " apples"},
+		"  indented":  {" ", " indented"},
+		"x\r\n\r\ny":  {"x", "\r\n\r\n", "y"},
+	}
+	for text, want := range tests {
+		assert.Equal(t, want, splitO200k(text), "%q", text)
+	}
+}
@@ -20,3 +20,9 @@ type UsageReporter interface {
 	UsageTracker() *usage.Tracker
 	ModelMaxTokens() int
 }
+
+// ContextWindowDiscoverer is implemented by completers that can look up the
+// context window of their model at runtime, such as local model servers.
+type ContextWindowDiscoverer interface {
+	DiscoverContextWindow(ctx context.Context) (int, error)
+}
@@ -22,6 +22,14 @@ type Auth struct {
 	Scheme string // Scheme prefix (default: "Bearer" when Header is "Authorization").
 }
 
+// RequestAuthorizer authorizes outgoing requests whose credentials cannot be
+// expressed as a static Auth key, such as signed requests (AWS SigV4) or
+// short-lived OAuth tokens. It runs on every request right before it is sent,
+// after all headers are set.
+type RequestAuthorizer interface {
+	Authorize(req *http.Request) error
+}
+
 // ModelConfig holds model-specific settings.
 type ModelConfig struct {
 	Name        string  // Model identifier (e.g. "gpt-4").
@@ -38,6 +46,7 @@ type Client struct {
 	headers       map[string]string
 	httpClient    *http.Client
 	headerParser  RateLimitHeaderParser
+	authorizer    RequestAuthorizer
 	rateLimitInfo atomic.Pointer[RateLimitInfo]
 	clientOnce    sync.Once
 	defaultClient *http.Client
@@ -61,6 +70,12 @@ func WithHeaderParser(p RateLimitHeaderParser) ClientOption {
 	return func(c *Client) { c.headerParser = p }
 }
 
+// WithAuthorizer sets a RequestAuthorizer applied to every request sent
+// through Do. It can be combined with an empty Auth.
+func WithAuthorizer(a RequestAuthorizer) ClientOption {
+	return func(c *Client) { c.authorizer = a }
+}
+
 // NewClient creates a Client with the given base URL, auth, and options.
 func NewClient(baseURL string, auth Auth, opts ...ClientOption) *Client {
 	c := &Client{
@@ -136,8 +151,14 @@ func (c *Client) NewRequest(ctx context.Context, method, path string, body io.Re
 	return req, nil
 }
 
-// Do sends the request using the configured HTTP client.
+// Do authorizes the request, if an authorizer is configured, and sends it
+// using the configured HTTP client.
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if c.authorizer != nil {
+		if err := c.authorizer.Authorize(req); err != nil {
+			return nil, fmt.Errorf("authorize request: %w", err)
+		}
+	}
 	return c.getHTTPClient().Do(req) //nolint:gosec // URL is built from trusted BaseURL config, not user input.
 }
 
@@ -133,6 +133,38 @@ func TestDo_Passthrough(t *testing.T) {
 	assert.Equal(t, "ok", string(body))
 }
 
+type authorizerFunc func(*http.Request) error
+
+func (f authorizerFunc) Authorize(req *http.Request) error { return f(req) }
+
+func TestDo_Authorizer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "signed application/json", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := modeladapter.NewClient(srv.URL, modeladapter.Auth{},
+		modeladapter.WithHTTPClient(srv.Client()),
+		modeladapter.WithAuthorizer(authorizerFunc(func(req *http.Request) error {
+			// Runs after PostJSON set the content type.
+			req.Header.Set("Authorization", "signed "+req.Header.Get("Content-Type"))
+			return nil
+		})))
+
+	require.NoError(t, c.PostJSON(context.Background(), "/v1/chat", map[string]string{}, nil))
+}
+
+func TestDo_AuthorizerError(t *testing.T) {
+	c := modeladapter.NewClient("http://127.0.0.1:1", modeladapter.Auth{},
+		modeladapter.WithAuthorizer(authorizerFunc(func(*http.Request) error {
+			return errors.New("no credentials")
+		})))
+
+	err := c.PostJSON(context.Background(), "/v1/chat", map[string]string{}, nil)
+	assert.ErrorContains(t, err, "authorize request: no credentials")
+}
+
 func TestPostJSON_Success(t *testing.T) {
 	type reqBody struct {
 		Model string `json:"model"`
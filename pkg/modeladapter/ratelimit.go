@@ -37,6 +37,10 @@ type RateLimitedCompleter struct {
 	baseDelay       time.Duration // initial backoff delay
 	fallbackTracker usage.Tracker // stable fallback tracker when inner lacks UsageReporter
 
+	// estimate returns the input tokens of a pending request; nil disables
+	// the pre-call check.
+	estimate func(c *chat.Chat, tools []toolbox.Tool) int
+
 	// nowFunc is used for testing; defaults to time.Now.
 	nowFunc func() time.Time
 	// sleepFunc is used for testing; defaults to a context-aware sleep.
@@ -72,6 +76,13 @@ func WithRandFunc(fn func() float64) RateLimitOption {
 	return func(r *RateLimitedCompleter) { r.randFunc = fn }
 }
 
+// WithInputEstimate makes the input TPM check account for the request about
+// to be sent: a call waits until its estimated input tokens fit in the window.
+// A request larger than the whole limit is sent once the window is empty.
+func WithInputEstimate(fn func(c *chat.Chat, tools []toolbox.Tool) int) RateLimitOption {
+	return func(r *RateLimitedCompleter) { r.estimate = fn }
+}
+
 // NewRateLimitedCompleter wraps a Completer with rate limiting.
 func NewRateLimitedCompleter(inner Completer, opts RateLimitOpts, options ...RateLimitOption) *RateLimitedCompleter {
 	if opts.MaxRetries <= 0 {
@@ -135,7 +146,8 @@ func (r *RateLimitedCompleter) windowTotals() (inputTotal, outputTotal int) {
 }
 
 // waitForCapacity blocks until there is capacity in both TPM and RPM windows.
-func (r *RateLimitedCompleter) waitForCapacity(ctx context.Context) error {
+// need is the estimated input tokens of the pending request (0 = unknown).
+func (r *RateLimitedCompleter) waitForCapacity(ctx context.Context, need int) error {
 	if r.inputTPM <= 0 && r.outputTPM <= 0 && r.rpm <= 0 {
 		return nil
 	}
@@ -146,7 +158,8 @@ func (r *RateLimitedCompleter) waitForCapacity(ctx context.Context) error {
 		r.pruneWindow(now)
 		inputTotal, outputTotal := r.windowTotals()
 
-		inputOK := r.inputTPM <= 0 || inputTotal < r.inputTPM
+		inputOK := r.inputTPM <= 0 || inputTotal < r.inputTPM &&
+			(need <= 0 || inputTotal+need <= r.inputTPM || len(r.window) == 0)
 		outputOK := r.outputTPM <= 0 || outputTotal < r.outputTPM
 		rpmOK := r.rpm <= 0 || len(r.window) < r.rpm
 
@@ -194,7 +207,11 @@ func (r *RateLimitedCompleter) jitter(d time.Duration) time.Duration {
 
 // Complete implements Completer with proactive TPM/RPM throttling and 429 retry.
 func (r *RateLimitedCompleter) Complete(ctx context.Context, c *chat.Chat, tools []toolbox.Tool) (message.Message, error) {
-	if err := r.waitForCapacity(ctx); err != nil {
+	need := 0
+	if r.estimate != nil && r.inputTPM > 0 {
+		need = r.estimate(c, tools)
+	}
+	if err := r.waitForCapacity(ctx, need); err != nil {
 		return message.Message{}, err
 	}
 
@@ -296,6 +313,9 @@ func (r *RateLimitedCompleter) adaptFromServerInfo(ctx context.Context) error {
 	return r.sleepFunc(ctx, sleepUntil.Sub(now))
 }
 
+// Unwrap returns the wrapped completer.
+func (r *RateLimitedCompleter) Unwrap() Completer { return r.inner }
+
 // UsageTracker forwards to the inner completer if it implements UsageReporter.
 func (r *RateLimitedCompleter) UsageTracker() *usage.Tracker {
 	if ur, ok := r.inner.(UsageReporter); ok {
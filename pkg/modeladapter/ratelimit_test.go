@@ -169,6 +169,58 @@ func TestRateLimitedCompleter_InputTPMThrottling(t *testing.T) {
 	assert.True(t, sleepCalled)
 }
 
+func TestRateLimitedCompleter_InputEstimate(t *testing.T) {
+	fc := &fakeCompleter{}
+	fc.handler = func(_ context.Context, _ *chat.Chat) (message.Message, error) {
+		fc.tracker.Add(usage.TokenCount{InputTokens: 60})
+		return okMessage(), nil
+	}
+
+	currentTime := time.Now()
+	sleeps := 0
+
+	rl := modeladapter.NewRateLimitedCompleter(fc, modeladapter.RateLimitOpts{
+		InputTPM:   100,
+		MaxRetries: 1,
+		BaseDelay:  time.Millisecond,
+	},
+		modeladapter.WithNowFunc(func() time.Time { return currentTime }),
+		modeladapter.WithSleepFunc(func(_ context.Context, d time.Duration) error {
+			sleeps++
+			currentTime = currentTime.Add(d)
+			return nil
+		}),
+		modeladapter.WithInputEstimate(func(_ *chat.Chat, _ []toolbox.Tool) int { return 60 }),
+	)
+
+	_, err := rl.Complete(context.Background(), &chat.Chat{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, sleeps)
+
+	// 60 in the window is under the limit, but 60 more would exceed it.
+	_, err = rl.Complete(context.Background(), &chat.Chat{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sleeps)
+}
+
+func TestRateLimitedCompleter_InputEstimateLargerThanLimit(t *testing.T) {
+	fc := &fakeCompleter{}
+	fc.handler = func(_ context.Context, _ *chat.Chat) (message.Message, error) {
+		return okMessage(), nil
+	}
+
+	rl := modeladapter.NewRateLimitedCompleter(fc, modeladapter.RateLimitOpts{InputTPM: 100, MaxRetries: 1},
+		modeladapter.WithSleepFunc(func(_ context.Context, _ time.Duration) error {
+			t.Fatal("an empty window must not wait")
+			return nil
+		}),
+		modeladapter.WithInputEstimate(func(_ *chat.Chat, _ []toolbox.Tool) int { return 500 }),
+	)
+
+	_, err := rl.Complete(context.Background(), &chat.Chat{}, nil)
+	require.NoError(t, err)
+}
+
 func TestRateLimitedCompleter_OutputTPMThrottling(t *testing.T) {
 	fc := &fakeCompleter{}
 	fc.handler = func(_ context.Context, _ *chat.Chat) (message.Message, error) {
@@ -0,0 +1,189 @@
+package modeladapter
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"weak"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/modeladapter/usage"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+)
+
+// Transcriber converts speech to text.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audio content.Audio) (string, error)
+}
+
+// Synthesizer converts text to speech.
+type Synthesizer interface {
+	Synthesize(ctx context.Context, text string) (content.Audio, error)
+}
+
+// AudioInputSupporter is implemented by completers whose model accepts
+// content.Audio parts of the given media type.
+type AudioInputSupporter interface {
+	SupportsAudioInput(mediaType string) bool
+}
+
+var (
+	_ Completer     = (*TranscribingCompleter)(nil)
+	_ UsageReporter = (*TranscribingCompleter)(nil)
+)
+
+// TranscribingCompleter wraps a Completer whose model cannot take some or all
+// audio input. Before each call it replaces the audio parts the model does not
+// accept with text transcripts from a Transcriber. Without a Transcriber, or
+// when transcription fails, the audio is replaced with a short note. The chat
+// passed to Complete is never modified.
+//
+// Replacements, failure notes included, are cached per chat by audio content,
+// so each clip is transcribed at most once per conversation. A chat's cache
+// is dropped when the chat is garbage collected.
+type TranscribingCompleter struct {
+	inner           Completer
+	transcriber     Transcriber
+	supports        AudioInputSupporter // nil when the model takes no audio
+	fallbackTracker usage.Tracker
+
+	mu     sync.Mutex
+	caches map[weak.Pointer[chat.Chat]]map[[sha256.Size]byte]string
+}
+
+// NewTranscribingCompleter wraps inner. Audio support is looked up on inner
+// and the completers it wraps; transcriber may be nil.
+func NewTranscribingCompleter(inner Completer, transcriber Transcriber) *TranscribingCompleter {
+	t := &TranscribingCompleter{
+		inner:       inner,
+		transcriber: transcriber,
+		caches:      make(map[weak.Pointer[chat.Chat]]map[[sha256.Size]byte]string),
+	}
+	for c := inner; c != nil; {
+		if s, ok := c.(AudioInputSupporter); ok {
+			t.supports = s
+			break
+		}
+		u, ok := c.(interface{ Unwrap() Completer })
+		if !ok {
+			break
+		}
+		c = u.Unwrap()
+	}
+	return t
+}
+
+// Complete implements Completer.
+func (t *TranscribingCompleter) Complete(ctx context.Context, c *chat.Chat, tools []toolbox.Tool) (message.Message, error) {
+	msgs := c.Messages()
+	changed := false
+	for i, m := range msgs {
+		var parts []content.Part
+		for j, p := range m.Parts {
+			a, ok := p.(content.Audio)
+			if !ok || t.accepts(a.MediaType) {
+				if parts != nil {
+					parts = append(parts, p)
+				}
+				continue
+			}
+			if parts == nil {
+				parts = append(make([]content.Part, 0, len(m.Parts)), m.Parts[:j]...)
+			}
+			parts = append(parts, content.Text{Text: t.transcript(ctx, c, a)})
+		}
+		if parts != nil {
+			msgs[i].Parts = parts
+			changed = true
+		}
+	}
+	if !changed {
+		return t.inner.Complete(ctx, c, tools)
+	}
+	return t.inner.Complete(ctx, chat.New(msgs...), tools)
+}
+
+// accepts reports whether the model takes audio of mediaType natively.
+func (t *TranscribingCompleter) accepts(mediaType string) bool {
+	return t.supports != nil && t.supports.SupportsAudioInput(mediaType)
+}
+
+// transcript returns the text that stands in for a in chat c.
+func (t *TranscribingCompleter) transcript(ctx context.Context, c *chat.Chat, a content.Audio) string {
+	name := "audio"
+	if a.Path != "" {
+		name = filepath.Base(a.Path)
+	}
+	if t.transcriber == nil {
+		return fmt.Sprintf("[%s omitted: the model does not accept audio and no speech provider is configured]", name)
+	}
+
+	key := sha256.Sum256(a.Data)
+	cache := t.cache(c)
+	t.mu.Lock()
+	text, ok := cache[key]
+	t.mu.Unlock()
+	if ok {
+		return text
+	}
+
+	transcript, err := t.transcriber.Transcribe(ctx, a)
+	if err != nil {
+		slog.Warn("modeladapter: audio transcription failed", "audio", name, "err", err)
+		text = fmt.Sprintf("[%s omitted: transcription failed]", name)
+	} else {
+		text = fmt.Sprintf("[Transcript of %s]\n%s", name, transcript)
+	}
+
+	t.mu.Lock()
+	cache[key] = text
+	t.mu.Unlock()
+	return text
+}
+
+// cache returns the replacements cached for chat c, creating an empty cache
+// that is removed once c is garbage collected.
+func (t *TranscribingCompleter) cache(c *chat.Chat) map[[sha256.Size]byte]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	wp := weak.Make(c)
+	cache, ok := t.caches[wp]
+	if !ok {
+		cache = make(map[[sha256.Size]byte]string)
+		t.caches[wp] = cache
+		runtime.AddCleanup(c, t.dropCache, wp)
+	}
+	return cache
+}
+
+func (t *TranscribingCompleter) dropCache(wp weak.Pointer[chat.Chat]) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.caches, wp)
+}
+
+// Unwrap returns the wrapped completer.
+func (t *TranscribingCompleter) Unwrap() Completer { return t.inner }
+
+// UsageTracker forwards to the inner completer if it implements UsageReporter.
+func (t *TranscribingCompleter) UsageTracker() *usage.Tracker {
+	if ur, ok := t.inner.(UsageReporter); ok {
+		return ur.UsageTracker()
+	}
+	return &t.fallbackTracker
+}
+
+// ModelMaxTokens forwards to the inner completer if it implements UsageReporter.
+func (t *TranscribingCompleter) ModelMaxTokens() int {
+	if ur, ok := t.inner.(UsageReporter); ok {
+		return ur.ModelMaxTokens()
+	}
+	return 0
+}
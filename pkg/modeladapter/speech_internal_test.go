@@ -0,0 +1,48 @@
+package modeladapter
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type echoCompleter struct{}
+
+func (echoCompleter) Complete(context.Context, *chat.Chat, []toolbox.Tool) (message.Message, error) {
+	return message.NewText("", role.Assistant, "ok"), nil
+}
+
+type echoTranscriber struct{}
+
+func (echoTranscriber) Transcribe(_ context.Context, a content.Audio) (string, error) {
+	return string(a.Data), nil
+}
+
+func TestTranscribingCompleter_DropsCacheWithChat(t *testing.T) {
+	tc := NewTranscribingCompleter(echoCompleter{}, echoTranscriber{})
+
+	func() {
+		c := chat.New(message.New("user", role.User, content.Audio{Data: []byte("hi"), MediaType: "audio/wav"}))
+		_, err := tc.Complete(context.Background(), c, nil)
+		require.NoError(t, err)
+	}()
+	tc.mu.Lock()
+	assert.Len(t, tc.caches, 1)
+	tc.mu.Unlock()
+
+	assert.Eventually(t, func() bool {
+		runtime.GC()
+		tc.mu.Lock()
+		defer tc.mu.Unlock()
+		return len(tc.caches) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,141 @@
+package modeladapter_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingCompleter captures the chat it receives.
+type recordingCompleter struct {
+	got   *chat.Chat
+	audio func(mediaType string) bool // nil: no AudioInputSupporter behaviour
+}
+
+func (r *recordingCompleter) Complete(_ context.Context, c *chat.Chat, _ []toolbox.Tool) (message.Message, error) {
+	r.got = c
+	return message.NewText("", role.Assistant, "ok"), nil
+}
+
+// audioCompleter is a recordingCompleter that accepts some audio.
+type audioCompleter struct{ recordingCompleter }
+
+func (a *audioCompleter) SupportsAudioInput(mediaType string) bool { return a.audio(mediaType) }
+
+type fakeTranscriber struct {
+	calls int
+	err   error
+}
+
+func (f *fakeTranscriber) Transcribe(_ context.Context, a content.Audio) (string, error) {
+	f.calls++
+	if f.err != nil {
+		return "", f.err
+	}
+	return "heard " + string(a.Data), nil
+}
+
+func audioChat() *chat.Chat {
+	return chat.New(
+		message.NewText("", role.System, "sys"),
+		message.New("user", role.User,
+			content.Text{Text: "listen"},
+			content.Audio{Path: "/tmp/memo.wav", Data: []byte("hello"), MediaType: "audio/wav"},
+		),
+	)
+}
+
+func TestTranscribingCompleter_TranscribesAndCaches(t *testing.T) {
+	inner := &recordingCompleter{}
+	tr := &fakeTranscriber{}
+	tc := modeladapter.NewTranscribingCompleter(inner, tr)
+
+	c := audioChat()
+	_, err := tc.Complete(context.Background(), c, nil)
+	require.NoError(t, err)
+
+	msgs := inner.got.Messages()
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "sys", inner.got.SystemPrompt())
+	require.Len(t, msgs[1].Parts, 2)
+	assert.Equal(t, content.Text{Text: "listen"}, msgs[1].Parts[0])
+	assert.Equal(t, content.Text{Text: "[Transcript of memo.wav]\nheard hello"}, msgs[1].Parts[1])
+
+	// The caller's chat keeps its audio.
+	assert.IsType(t, content.Audio{}, c.At(1).Parts[1])
+
+	_, err = tc.Complete(context.Background(), c, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, tr.calls, "transcript is cached")
+}
+
+func TestTranscribingCompleter_PassesSupportedAudio(t *testing.T) {
+	inner := &audioCompleter{recordingCompleter{audio: func(mt string) bool { return mt == "audio/wav" }}}
+	tr := &fakeTranscriber{}
+
+	// Support is found through wrappers.
+	rl := modeladapter.NewRateLimitedCompleter(inner, modeladapter.RateLimitOpts{})
+	tc := modeladapter.NewTranscribingCompleter(rl, tr)
+
+	c := audioChat()
+	_, err := tc.Complete(context.Background(), c, nil)
+	require.NoError(t, err)
+	assert.Same(t, c, inner.got)
+	assert.Zero(t, tr.calls)
+}
+
+func TestTranscribingCompleter_Fallbacks(t *testing.T) {
+	inner := &recordingCompleter{}
+
+	_, err := modeladapter.NewTranscribingCompleter(inner, nil).Complete(context.Background(), audioChat(), nil)
+	require.NoError(t, err)
+	text := inner.got.At(1).Parts[1].(content.Text).Text
+	assert.True(t, strings.HasPrefix(text, "[memo.wav omitted: the model does not accept audio"), text)
+
+	tr := &fakeTranscriber{err: errors.New("boom")}
+	_, err = modeladapter.NewTranscribingCompleter(inner, tr).Complete(context.Background(), audioChat(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, content.Text{Text: "[memo.wav omitted: transcription failed]"}, inner.got.At(1).Parts[1])
+}
+
+func TestTranscribingCompleter_CachesPerConversation(t *testing.T) {
+	inner := &recordingCompleter{}
+	tr := &fakeTranscriber{err: errors.New("boom")}
+	tc := modeladapter.NewTranscribingCompleter(inner, tr)
+
+	// A failed transcription is not retried for the rest of the conversation.
+	c := audioChat()
+	for range 3 {
+		_, err := tc.Complete(context.Background(), c, nil)
+		require.NoError(t, err)
+		assert.Equal(t, content.Text{Text: "[memo.wav omitted: transcription failed]"}, inner.got.At(1).Parts[1])
+	}
+	assert.Equal(t, 1, tr.calls)
+
+	// The cache holds every clip of the conversation, however many there are.
+	tr.err = nil
+	for i := range 50 {
+		c.Append(message.New("user", role.User, content.Audio{Data: []byte{byte(i)}, MediaType: "audio/wav"}))
+	}
+	_, err := tc.Complete(context.Background(), c, nil)
+	require.NoError(t, err)
+	_, err = tc.Complete(context.Background(), c, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 51, tr.calls)
+
+	// Another conversation transcribes the same clip afresh.
+	_, err = tc.Complete(context.Background(), audioChat(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, content.Text{Text: "[Transcript of memo.wav]\nheard hello"}, inner.got.At(1).Parts[1])
+	assert.Equal(t, 52, tr.calls)
+}
@@ -16,15 +16,26 @@ const perMessageOverhead = 4
 const perToolOverhead = 10
 
 // TokenEstimator estimates token counts for chat messages and tool definitions.
-// It uses a character-to-token heuristic (approximately 1 token per 4 characters
-// for English text, with overhead for JSON structure in tool definitions).
-// The zero value is ready to use.
-type TokenEstimator struct{}
+// Text is counted with Tokenizer, plus a fixed overhead for message and tool
+// definition structure. The zero value is ready to use and counts with
+// HeuristicTokenizer.
+type TokenEstimator struct {
+	Tokenizer Tokenizer // Nil uses HeuristicTokenizer.
+}
 
-// charsToTokens converts a character count to an estimated token count using the
-// 1-token-per-4-characters heuristic.
-func charsToTokens(chars int) int {
-	return (chars + 3) / 4 // round up
+// count returns the tokens of the concatenated texts.
+func (e TokenEstimator) count(texts ...string) int {
+	var tok Tokenizer = HeuristicTokenizer{}
+	if e.Tokenizer != nil {
+		tok = e.Tokenizer
+	}
+	n := 0
+	for _, t := range texts {
+		if t != "" {
+			n += tok.CountTokens(t)
+		}
+	}
+	return n
 }
 
 // EstimateChat estimates the total input tokens for a chat conversation.
@@ -35,7 +46,7 @@ func (e TokenEstimator) EstimateChat(c *chat.Chat) int {
 
 	// System prompt.
 	if sp := c.SystemPrompt(); sp != "" {
-		tokens += charsToTokens(len(sp)) + perMessageOverhead
+		tokens += e.count(sp) + perMessageOverhead
 	}
 
 	for _, m := range c.Messages() {
@@ -48,11 +59,13 @@ func (e TokenEstimator) EstimateChat(c *chat.Chat) int {
 		for _, p := range m.Parts {
 			switch v := p.(type) {
 			case content.Text:
-				tokens += charsToTokens(len(v.Text))
+				tokens += e.count(v.Text)
 			case content.ToolCall:
-				tokens += charsToTokens(len(v.ID) + len(v.Name) + len(v.Arguments))
+				tokens += e.count(v.ID, v.Name, v.Arguments)
 			case content.ToolResult:
-				tokens += charsToTokens(len(v.ToolCallID) + len(v.Content))
+				tokens += e.count(v.ToolCallID, v.Content)
+			case content.Reasoning:
+				tokens += e.count(v.Text)
 			}
 		}
 	}
@@ -61,14 +74,13 @@ func (e TokenEstimator) EstimateChat(c *chat.Chat) int {
 }
 
 // EstimateTools estimates the token cost of tool definitions. For each tool it
-// sums the name, description, and serialized input schema, then applies the
-// character-to-token heuristic plus a per-tool structural overhead.
+// counts the name, description, and serialized input schema, plus a per-tool
+// structural overhead.
 func (e TokenEstimator) EstimateTools(tools []toolbox.Tool) int {
 	tokens := 0
 
 	for _, t := range tools {
-		chars := len(t.Name) + len(t.Description) + len(t.InputSchema)
-		tokens += charsToTokens(chars) + perToolOverhead
+		tokens += e.count(t.Name, t.Description, string(t.InputSchema)) + perToolOverhead
 	}
 
 	return tokens
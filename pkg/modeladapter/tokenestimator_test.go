@@ -104,6 +104,24 @@ func TestEstimateChat_LargeConversation(t *testing.T) {
 	c := chat.New(msgs...)
 
 	got := e.EstimateChat(c)
-	// Each message: 4 overhead + ceil(100/4)=25 = 29.
-	assert.Equal(t, 2900, got)
+	// Each message: 4 overhead + ceil(100/6)=17 for one ASCII word = 21.
+	assert.Equal(t, 2100, got)
+}
+
+// fixedTokenizer counts every non-empty text as n tokens.
+type fixedTokenizer struct{ n int }
+
+func (f fixedTokenizer) CountTokens(string) int { return f.n }
+
+func TestEstimateChat_UsesTokenizer(t *testing.T) {
+	e := modeladapter.TokenEstimator{Tokenizer: fixedTokenizer{n: 7}}
+	c := chat.New(
+		message.NewText("user", role.User, "hello"),
+		message.New("bot", role.Assistant,
+			content.ToolCall{ID: "c1", Name: "search", Arguments: `{}`},
+		),
+	)
+
+	// Two messages * 4 overhead + 7 for the text + 3*7 for the call fields.
+	assert.Equal(t, 8+7+21, e.EstimateChat(c))
 }
@@ -0,0 +1,276 @@
+package modeladapter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/modeladapter/usage"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+)
+
+// Tokenizer counts the tokens a model sees for a piece of text.
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// TokenCounter is implemented by completers that can count the input tokens
+// of a request exactly, usually through a provider endpoint.
+type TokenCounter interface {
+	CountTokens(ctx context.Context, c *chat.Chat, tools []toolbox.Tool) (int, error)
+}
+
+// HeuristicTokenizer estimates token counts without a vocabulary. It scans
+// the text by character class instead of dividing its byte length: runs of
+// whitespace (indentation) collapse into single tokens, ASCII words cost about
+// one token per six letters, digits are grouped by three, and CJK characters
+// cost a token each. The zero value is ready to use.
+type HeuristicTokenizer struct{}
+
+// CountTokens implements Tokenizer.
+func (HeuristicTokenizer) CountTokens(text string) int {
+	tokens := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		class := runeClass(r)
+		n := 1
+		j := i + size
+		for j < len(text) {
+			next, nextSize := utf8.DecodeRuneInString(text[j:])
+			if runeClass(next) != class {
+				break
+			}
+			n++
+			j += nextSize
+		}
+		tokens += classTokens(class, n, j == len(text))
+		i = j
+	}
+	return tokens
+}
+
+// Character classes used by HeuristicTokenizer.
+const (
+	classSpace = iota
+	classWord
+	classDigit
+	classPunct
+	classCJK
+	classLetter
+	classOther
+)
+
+func runeClass(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return classSpace
+	case r < utf8.RuneSelf && (r == '_' || unicode.IsLetter(r)):
+		return classWord
+	case unicode.IsDigit(r):
+		return classDigit
+	case r < utf8.RuneSelf:
+		return classPunct
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+		return classCJK
+	case unicode.IsLetter(r) || unicode.IsMark(r):
+		return classLetter
+	default:
+		return classOther
+	}
+}
+
+// classTokens estimates the tokens of a run of n characters of one class.
+func classTokens(class, n int, atEnd bool) int {
+	switch class {
+	case classSpace:
+		// A single space merges into the next word; longer runs such as
+		// indentation are one token.
+		if n == 1 && !atEnd {
+			return 0
+		}
+		return 1
+	case classWord:
+		return (n + 5) / 6
+	case classDigit:
+		return (n + 2) / 3
+	case classPunct:
+		return (n + 1) / 2
+	case classLetter:
+		return (n + 1) / 2
+	default: // classCJK, classOther
+		return n
+	}
+}
+
+// Calibration corrects token estimates with the input token counts providers
+// report. It keeps an exponential moving average of actual/estimated and is
+// meant to be shared by everything estimating for one provider. It is safe
+// for concurrent use; a nil *Calibration applies no correction.
+type Calibration struct {
+	// CacheInInput reports whether the provider's InputTokens already
+	// include cached tokens (OpenAI, Gemini). When false (Anthropic,
+	// Bedrock) cache reads and writes are added to get the prompt size.
+	CacheInInput bool
+
+	mu      sync.Mutex
+	factor  float64
+	samples int
+}
+
+const (
+	// calibrationMinTokens is the smallest estimate worth learning from;
+	// below it per-message overhead dominates the ratio.
+	calibrationMinTokens = 256
+	// calibrationWeight is the weight of a new observation in the average.
+	calibrationWeight = 0.2
+	// Bounds on the correction factor, so one odd response cannot skew
+	// estimates by more than these.
+	calibrationMinFactor = 0.25
+	calibrationMaxFactor = 4.0
+)
+
+// Observe records the raw (uncalibrated) estimate of a request and the token
+// usage the provider reported for it.
+func (c *Calibration) Observe(estimated int, tc usage.TokenCount) {
+	if c == nil || estimated < calibrationMinTokens {
+		return
+	}
+	actual := tc.InputTokens
+	if !c.CacheInInput {
+		actual += tc.CacheReadInputTokens + tc.CacheCreationInputTokens
+	}
+	if actual <= 0 {
+		return
+	}
+	ratio := min(max(float64(actual)/float64(estimated), calibrationMinFactor), calibrationMaxFactor)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.samples == 0 {
+		c.factor = ratio
+	} else {
+		c.factor += calibrationWeight * (ratio - c.factor)
+	}
+	c.samples++
+}
+
+// Factor returns the current correction factor, 1 before any observation.
+func (c *Calibration) Factor() float64 {
+	if c == nil {
+		return 1
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.samples == 0 {
+		return 1
+	}
+	return c.factor
+}
+
+// Samples returns the number of observations made.
+func (c *Calibration) Samples() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.samples
+}
+
+// Apply scales a raw estimate by the correction factor.
+func (c *Calibration) Apply(tokens int) int {
+	f := c.Factor()
+	if f == 1 {
+		return tokens
+	}
+	return int(float64(tokens)*f + 0.5)
+}
+
+// tokenCountCacheSize bounds the number of exact counts CachedTokenCounter
+// remembers.
+const tokenCountCacheSize = 64
+
+// CachedTokenCounter wraps a TokenCounter and remembers exact counts by a
+// fingerprint of the request, so recounting an unchanged conversation does
+// not call the provider again. It is safe for concurrent use.
+type CachedTokenCounter struct {
+	inner TokenCounter
+
+	mu    sync.Mutex
+	order [][sha256.Size]byte
+	cache map[[sha256.Size]byte]int
+}
+
+// NewCachedTokenCounter creates a CachedTokenCounter around inner.
+func NewCachedTokenCounter(inner TokenCounter) *CachedTokenCounter {
+	return &CachedTokenCounter{inner: inner, cache: make(map[[sha256.Size]byte]int)}
+}
+
+// CountTokens implements TokenCounter.
+func (cc *CachedTokenCounter) CountTokens(ctx context.Context, c *chat.Chat, tools []toolbox.Tool) (int, error) {
+	key, err := requestFingerprint(c, tools)
+	if err != nil {
+		return cc.inner.CountTokens(ctx, c, tools)
+	}
+
+	cc.mu.Lock()
+	n, ok := cc.cache[key]
+	cc.mu.Unlock()
+	if ok {
+		return n, nil
+	}
+
+	n, err = cc.inner.CountTokens(ctx, c, tools)
+	if err != nil {
+		return 0, err
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if _, ok := cc.cache[key]; !ok {
+		if len(cc.order) == tokenCountCacheSize {
+			delete(cc.cache, cc.order[0])
+			cc.order = cc.order[1:]
+		}
+		cc.order = append(cc.order, key)
+	}
+	cc.cache[key] = n
+	return n, nil
+}
+
+// requestFingerprint hashes the messages and tool definitions of a request.
+func requestFingerprint(c *chat.Chat, tools []toolbox.Tool) ([sha256.Size]byte, error) {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, m := range c.Messages() {
+		fmt.Fprintf(h, "%s %s\n", m.Role, m.Sender)
+		for _, p := range m.Parts {
+			// The type name keeps parts with the same fields apart.
+			fmt.Fprintf(h, "%T ", p)
+			if err := enc.Encode(p); err != nil {
+				return [sha256.Size]byte{}, err
+			}
+		}
+	}
+	for _, t := range tools {
+		if err := enc.Encode(struct {
+			Name        string
+			Description string
+			InputSchema json.RawMessage
+		}{t.Name, t.Description, t.InputSchema}); err != nil {
+			return [sha256.Size]byte{}, err
+		}
+	}
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+	return key, nil
+}
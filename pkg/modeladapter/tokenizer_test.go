@@ -0,0 +1,129 @@
+package modeladapter_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/usage"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeuristicTokenizer(t *testing.T) {
+	var tok modeladapter.HeuristicTokenizer
+
+	tests := map[string]int{
+		"":                  0,
+		"hello world":       2, // a single space merges into the next word
+		"日本語のテキスト":          8, // one token per CJK character
+		"12345":             2, // digits grouped by three
+		"\t\t\treturn nil":  3, // indentation run, "return", " nil"
+		"a, b":              3, // "a", ",", " b"
+		"ContextDiscoverer": 3, // long identifiers split every six letters
+	}
+	for text, want := range tests {
+		assert.Equal(t, want, tok.CountTokens(text), "%q", text)
+	}
+}
+
+func TestHeuristicTokenizer_CodeAndCJKVersusCharsPerFour(t *testing.T) {
+	var tok modeladapter.HeuristicTokenizer
+
+	code := strings.Repeat("        if err != nil {\n            return err\n        }\n", 20)
+	assert.Less(t, tok.CountTokens(code), (len(code)+3)/4, "indentation should not count per character")
+
+	cjk := strings.Repeat("这是一个测试", 20)
+	assert.Greater(t, tok.CountTokens(cjk), (len(cjk)+3)/4, "CJK text should not be underestimated")
+}
+
+func TestCalibration(t *testing.T) {
+	var nilCal *modeladapter.Calibration
+	assert.InDelta(t, 1.0, nilCal.Factor(), 0)
+	assert.Equal(t, 100, nilCal.Apply(100))
+	nilCal.Observe(1000, usage.TokenCount{InputTokens: 2000}) // no panic
+
+	cal := &modeladapter.Calibration{}
+	assert.Equal(t, 1000, cal.Apply(1000))
+
+	// Small estimates are ignored.
+	cal.Observe(10, usage.TokenCount{InputTokens: 40})
+	assert.Equal(t, 0, cal.Samples())
+
+	// Cache tokens count towards the prompt unless CacheInInput.
+	cal.Observe(1000, usage.TokenCount{InputTokens: 1000, CacheReadInputTokens: 1000})
+	assert.InDelta(t, 2.0, cal.Factor(), 1e-9)
+	assert.Equal(t, 2000, cal.Apply(1000))
+
+	// Later observations move the average gradually.
+	cal.Observe(1000, usage.TokenCount{InputTokens: 1000})
+	assert.InDelta(t, 1.8, cal.Factor(), 1e-9)
+	assert.Equal(t, 2, cal.Samples())
+
+	openai := &modeladapter.Calibration{CacheInInput: true}
+	openai.Observe(1000, usage.TokenCount{InputTokens: 1500, CacheReadInputTokens: 1000})
+	assert.InDelta(t, 1.5, openai.Factor(), 1e-9)
+
+	// The factor is clamped.
+	wild := &modeladapter.Calibration{}
+	wild.Observe(1000, usage.TokenCount{InputTokens: 100000})
+	assert.InDelta(t, 4.0, wild.Factor(), 1e-9)
+}
+
+type countingCounter struct {
+	calls atomic.Int32
+	err   error
+}
+
+func (c *countingCounter) CountTokens(_ context.Context, ch *chat.Chat, tools []toolbox.Tool) (int, error) {
+	c.calls.Add(1)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return 100*ch.Len() + len(tools), nil
+}
+
+func TestCachedTokenCounter(t *testing.T) {
+	inner := &countingCounter{}
+	cc := modeladapter.NewCachedTokenCounter(inner)
+	ctx := context.Background()
+
+	c := chat.New(message.NewText("user", role.User, "hi"))
+	n, err := cc.CountTokens(ctx, c, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 100, n)
+
+	n, err = cc.CountTokens(ctx, c, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 100, n)
+	assert.Equal(t, int32(1), inner.calls.Load(), "unchanged request is served from cache")
+
+	n, err = cc.CountTokens(ctx, c, []toolbox.Tool{{Name: "echo"}})
+	require.NoError(t, err)
+	assert.Equal(t, 101, n)
+
+	c.Append(message.NewText("bot", role.Assistant, "hello"))
+	n, err = cc.CountTokens(ctx, c, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 200, n)
+	assert.Equal(t, int32(3), inner.calls.Load())
+}
+
+func TestCachedTokenCounter_ErrorsAreNotCached(t *testing.T) {
+	inner := &countingCounter{err: errors.New("boom")}
+	cc := modeladapter.NewCachedTokenCounter(inner)
+	c := chat.New(message.NewText("user", role.User, "hi"))
+
+	_, err := cc.CountTokens(context.Background(), c, nil)
+	require.EqualError(t, err, "boom")
+	_, err = cc.CountTokens(context.Background(), c, nil)
+	require.Error(t, err)
+	assert.Equal(t, int32(2), inner.calls.Load())
+}
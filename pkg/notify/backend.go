@@ -0,0 +1,180 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Terminal notification styles.
+const (
+	TerminalBell   = "bell"   // BEL character; most terminals flash or mark the tab.
+	TerminalOSC9   = "osc9"   // OSC 9 (iTerm2, WezTerm, Windows Terminal, kitty, ghostty).
+	TerminalOSC777 = "osc777" // OSC 777 notify (rxvt-unicode, foot, Konsole, ghostty).
+)
+
+// Terminal returns a backend that writes a terminal notification sequence of
+// the given style to w. Unknown styles fall back to the bell.
+func Terminal(w io.Writer, style string) Backend {
+	return terminalBackend{w: w, style: style}
+}
+
+type terminalBackend struct {
+	w     io.Writer
+	style string
+}
+
+func (t terminalBackend) Notify(_ context.Context, n Notification) error {
+	var seq string
+	switch t.style {
+	case TerminalOSC9:
+		seq = "\x1b]9;" + oscText(n.Title+": "+n.Body) + "\a"
+	case TerminalOSC777:
+		seq = "\x1b]777;notify;" + strings.ReplaceAll(oscText(n.Title), ";", ",") + ";" + oscText(n.Body) + "\a"
+	default:
+		seq = "\a"
+	}
+	_, err := io.WriteString(t.w, seq)
+	return err
+}
+
+// oscText replaces control characters, which would end or corrupt the
+// sequence, with spaces.
+func oscText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// Desktop returns a backend that shows a freedesktop notification through
+// notify-send, or through gdbus when notify-send is not installed. ok is false
+// when neither is available or no D-Bus session bus is reachable.
+func Desktop() (b Backend, ok bool) {
+	if !sessionBusAvailable() {
+		return nil, false
+	}
+	if path, err := exec.LookPath("notify-send"); err == nil {
+		return desktopBackend{path: path}, true
+	}
+	if path, err := exec.LookPath("gdbus"); err == nil {
+		return desktopBackend{path: path, gdbus: true}, true
+	}
+	return nil, false
+}
+
+func sessionBusAvailable() bool {
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") != "" {
+		return true
+	}
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(runtimeDir, "bus"))
+	return err == nil
+}
+
+type desktopBackend struct {
+	path  string
+	gdbus bool
+}
+
+func (d desktopBackend) Notify(ctx context.Context, n Notification) error {
+	args := []string{"--app-name=shelly", "--", n.Title, n.Body}
+	if d.gdbus {
+		args = []string{
+			"call", "--session",
+			"--dest", "org.freedesktop.Notifications",
+			"--object-path", "/org/freedesktop/Notifications",
+			"--method", "org.freedesktop.Notifications.Notify",
+			`"shelly"`, "0", `""`, gvariantString(n.Title), gvariantString(n.Body), "[]", "{}", "-1",
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, d.path, args...) //nolint:gosec // path comes from LookPath, arguments are passed without a shell
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s: %w: %s", filepath.Base(d.path), err, msg)
+		}
+		return fmt.Errorf("%s: %w", filepath.Base(d.path), err)
+	}
+	return nil
+}
+
+// gvariantString quotes s as a GVariant text-format string for gdbus.
+func gvariantString(s string) string { return strconv.Quote(oscText(s)) }
+
+// Command is a backend that runs an external command for each notification.
+// The command receives the notification as JSON on stdin and in the
+// SHELLY_NOTIFY_EVENT, SHELLY_NOTIFY_TITLE and SHELLY_NOTIFY_BODY environment
+// variables.
+type Command struct {
+	Command string
+	Args    []string
+	Dir     string        // Working directory ("" = current).
+	Timeout time.Duration // Zero means DeliveryTimeout.
+}
+
+// commandInput is the JSON document written to a Command's stdin.
+type commandInput struct {
+	Event           Event   `json:"event"`
+	Title           string  `json:"title"`
+	Body            string  `json:"body"`
+	SessionID       string  `json:"session_id,omitempty"`
+	Agent           string  `json:"agent,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+}
+
+// Notify runs the command.
+func (c Command) Notify(ctx context.Context, n Notification) error {
+	payload, err := json.Marshal(commandInput{
+		Event:           n.Event,
+		Title:           n.Title,
+		Body:            n.Body,
+		SessionID:       n.SessionID,
+		Agent:           n.Agent,
+		DurationSeconds: n.Duration.Seconds(),
+	})
+	if err != nil {
+		return err
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DeliveryTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c.Command, c.Args...) //nolint:gosec // notification commands come from trusted config
+	cmd.Dir = c.Dir
+	cmd.WaitDelay = time.Second // don't wait on grandchildren holding stderr open after a timeout
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(),
+		"SHELLY_NOTIFY_EVENT="+string(n.Event),
+		"SHELLY_NOTIFY_TITLE="+n.Title,
+		"SHELLY_NOTIFY_BODY="+n.Body,
+	)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("command %s: %w: %s", c.Command, err, msg)
+		}
+		return fmt.Errorf("command %s: %w", c.Command, err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerminal(t *testing.T) {
+	n := Notification{Title: "Shelly; done", Body: "coder\nfinished\x07"}
+
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{TerminalBell, "\a"},
+		{"", "\a"},
+		{TerminalOSC9, "\x1b]9;Shelly; done: coder finished \a"},
+		{TerminalOSC777, "\x1b]777;notify;Shelly, done;coder finished \a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			var sb strings.Builder
+			require.NoError(t, Terminal(&sb, tt.style).Notify(context.Background(), n))
+			assert.Equal(t, tt.want, sb.String())
+		})
+	}
+}
+
+func TestGVariantString(t *testing.T) {
+	assert.Equal(t, `"say \"hi\" \\ now"`, gvariantString("say \"hi\" \\\nnow"))
+}
+
+func TestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+
+	c := Command{
+		Command: "sh",
+		Args:    []string{"-c", `cat > input.json && printf '%s|%s' "$SHELLY_NOTIFY_EVENT" "$SHELLY_NOTIFY_TITLE" > env.txt`},
+		Dir:     dir,
+	}
+	err := c.Notify(context.Background(), Notification{
+		Event: AgentEnd, Title: "Shelly finished", Body: "done", SessionID: "s1", Agent: "coder", Duration: 90 * time.Second,
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "input.json")) //nolint:gosec // test file
+	require.NoError(t, err)
+	var in commandInput
+	require.NoError(t, json.Unmarshal(data, &in))
+	assert.Equal(t, commandInput{Event: AgentEnd, Title: "Shelly finished", Body: "done", SessionID: "s1", Agent: "coder", DurationSeconds: 90}, in)
+
+	env, err := os.ReadFile(filepath.Join(dir, "env.txt")) //nolint:gosec // test file
+	require.NoError(t, err)
+	assert.Equal(t, "agent_end|Shelly finished", string(env))
+}
+
+func TestCommand_Error(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	c := Command{Command: "sh", Args: []string{"-c", "echo boom >&2; exit 1"}}
+
+	err := c.Notify(context.Background(), Notification{Event: Error})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+}
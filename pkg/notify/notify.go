@@ -0,0 +1,180 @@
+// Package notify alerts the user when a long-running session needs attention
+// while they are looking at another window: a question or prompt is waiting,
+// a run finished or failed, a batch completed. Rules decide which events
+// notify and how long the user must have been idle first; backends deliver
+// the notification (terminal escape sequences, desktop notifications or an
+// external command).
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Event identifies what a notification is about.
+type Event string
+
+// Supported notification events.
+const (
+	AskUser        Event = "ask_user"        // An agent asks a question or a permission prompt is waiting.
+	FileReview     Event = "file_review"     // A file edit is waiting for review.
+	AgentEnd       Event = "agent_end"       // A session's top-level run finished.
+	Error          Event = "error"           // A session's run failed.
+	BatchCompleted Event = "batch_completed" // A batch run finished.
+)
+
+// Events lists all supported notification events.
+var Events = []Event{AskUser, FileReview, AgentEnd, Error, BatchCompleted}
+
+// Valid reports whether e is a supported event.
+func (e Event) Valid() bool {
+	for _, ev := range Events {
+		if e == ev {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryTimeout bounds the delivery of one notification to all backends.
+const DeliveryTimeout = 10 * time.Second
+
+// Notification is one alert for the user.
+type Notification struct {
+	Event     Event
+	Title     string
+	Body      string
+	SessionID string
+	Agent     string
+	Duration  time.Duration // Length of the run, for AgentEnd and BatchCompleted.
+}
+
+// Rule enables notifications for an event.
+type Rule struct {
+	Event Event
+	// Idle delays the notification until the user has been idle this long
+	// after the event. Activity in between drops it. Zero notifies at once.
+	Idle time.Duration
+	// MinDuration drops notifications for runs shorter than this
+	// (AgentEnd, BatchCompleted).
+	MinDuration time.Duration
+}
+
+// Backend delivers notifications.
+type Backend interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Notifier applies rules to notifications and delivers them to its backends.
+// A nil *Notifier is valid and notifies nothing. It is safe for concurrent
+// use.
+type Notifier struct {
+	rules    map[Event]Rule
+	backends []Backend
+
+	mu       sync.Mutex
+	activity int                    // bumped by Activity; pending notifications remember the value they saw
+	pending  map[string]*time.Timer // idle notifications by event and session
+	closed   bool
+
+	sendMu sync.Mutex // serialises deliveries so terminal sequences do not interleave
+	wg     sync.WaitGroup
+}
+
+// New creates a Notifier. Events without a rule do not notify; a later rule
+// for the same event replaces an earlier one.
+func New(rules []Rule, backends ...Backend) *Notifier {
+	m := make(map[Event]Rule, len(rules))
+	for _, r := range rules {
+		m[r.Event] = r
+	}
+	return &Notifier{rules: m, backends: backends, pending: make(map[string]*time.Timer)}
+}
+
+// Notify delivers n, now or once the rule's idle delay has passed, if a rule
+// matches it. Delivery happens in the background. A notification already
+// waiting for the same event and session absorbs n.
+func (n *Notifier) Notify(note Notification) {
+	if n == nil {
+		return
+	}
+	rule, ok := n.rules[note.Event]
+	if !ok || note.Duration < rule.MinDuration {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+
+	if rule.Idle <= 0 {
+		n.deliver(note)
+		return
+	}
+
+	key := string(note.Event) + "\x00" + note.SessionID
+	if _, ok := n.pending[key]; ok {
+		return
+	}
+	activity := n.activity
+	n.pending[key] = time.AfterFunc(rule.Idle, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		delete(n.pending, key)
+		if !n.closed && n.activity == activity {
+			n.deliver(note)
+		}
+	})
+}
+
+// Activity records that the user interacted with the frontend, dropping the
+// notifications still waiting for the user to be idle.
+func (n *Notifier) Activity() {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.activity++
+	n.dropPending()
+}
+
+// Close drops waiting notifications and waits for deliveries in progress.
+func (n *Notifier) Close() {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	n.closed = true
+	n.dropPending()
+	n.mu.Unlock()
+	n.wg.Wait()
+}
+
+// dropPending stops the idle timers. n.mu must be held.
+func (n *Notifier) dropPending() {
+	for key, t := range n.pending {
+		t.Stop()
+		delete(n.pending, key)
+	}
+}
+
+// deliver sends note to every backend in the background. n.mu must be held.
+func (n *Notifier) deliver(note Notification) {
+	n.wg.Go(func() {
+		n.sendMu.Lock()
+		defer n.sendMu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), DeliveryTimeout)
+		defer cancel()
+		for _, b := range n.backends {
+			if err := b.Notify(ctx, note); err != nil {
+				slog.Warn("notify: delivery failed", "event", note.Event, "err", err)
+			}
+		}
+	})
+}
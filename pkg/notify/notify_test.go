@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a Backend that sends notifications to a channel.
+type recorder chan Notification
+
+func (r recorder) Notify(_ context.Context, n Notification) error {
+	r <- n
+	return nil
+}
+
+func receive(t *testing.T, r recorder) Notification {
+	t.Helper()
+	select {
+	case n := <-r:
+		return n
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "no notification delivered")
+		return Notification{}
+	}
+}
+
+func assertNone(t *testing.T, r recorder, wait time.Duration) {
+	t.Helper()
+	select {
+	case n := <-r:
+		assert.Failf(t, "unexpected notification", "%+v", n)
+	case <-time.After(wait):
+	}
+}
+
+func TestNotifier_Rules(t *testing.T) {
+	r := make(recorder, 4)
+	n := New([]Rule{{Event: AgentEnd, MinDuration: time.Minute}, {Event: Error}}, r)
+	defer n.Close()
+
+	n.Notify(Notification{Event: AskUser, Title: "no rule"})
+	n.Notify(Notification{Event: AgentEnd, Title: "short", Duration: time.Second})
+	n.Notify(Notification{Event: AgentEnd, Title: "long", Duration: 2 * time.Minute})
+	n.Notify(Notification{Event: Error, Title: "failed"})
+
+	got := []string{receive(t, r).Title, receive(t, r).Title}
+	assert.ElementsMatch(t, []string{"long", "failed"}, got)
+	assertNone(t, r, 20*time.Millisecond)
+}
+
+func TestNotifier_Idle(t *testing.T) {
+	r := make(recorder, 4)
+	n := New([]Rule{{Event: AskUser, Idle: 30 * time.Millisecond}}, r)
+	defer n.Close()
+
+	n.Notify(Notification{Event: AskUser, SessionID: "s1", Title: "first"})
+	n.Notify(Notification{Event: AskUser, SessionID: "s1", Title: "absorbed"})
+	n.Notify(Notification{Event: AskUser, SessionID: "s2", Title: "other session"})
+
+	got := []string{receive(t, r).Title, receive(t, r).Title}
+	assert.ElementsMatch(t, []string{"first", "other session"}, got)
+	assertNone(t, r, 50*time.Millisecond)
+}
+
+func TestNotifier_ActivityDropsPending(t *testing.T) {
+	r := make(recorder, 4)
+	n := New([]Rule{{Event: AskUser, Idle: 30 * time.Millisecond}}, r)
+	defer n.Close()
+
+	n.Notify(Notification{Event: AskUser, Title: "answered"})
+	n.Activity()
+	assertNone(t, r, 60*time.Millisecond)
+
+	n.Notify(Notification{Event: AskUser, Title: "ignored"})
+	assert.Equal(t, "ignored", receive(t, r).Title)
+}
+
+func TestNotifier_Close(t *testing.T) {
+	r := make(recorder, 4)
+	n := New([]Rule{{Event: AskUser, Idle: 10 * time.Millisecond}, {Event: Error}}, r)
+
+	n.Notify(Notification{Event: AskUser})
+	n.Close()
+	n.Notify(Notification{Event: Error})
+	assertNone(t, r, 30*time.Millisecond)
+
+	var nilNotifier *Notifier
+	nilNotifier.Notify(Notification{Event: Error})
+	nilNotifier.Activity()
+	nilNotifier.Close()
+}
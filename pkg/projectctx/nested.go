@@ -0,0 +1,154 @@
+package projectctx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultNestedFileNames lists the instruction files discovered in project
+// subdirectories, in precedence order within a single directory (later names
+// take precedence).
+var DefaultNestedFileNames = []string{"AGENTS.md", "CLAUDE.md", filepath.Join(".shelly", "context.md")}
+
+// maxIncludeDepth bounds recursive @include expansion.
+const maxIncludeDepth = 5
+
+// includeDirective prefixes a line that pulls another file into the current
+// instruction file (e.g. "@include ../shared/style.md").
+const includeDirective = "@include "
+
+// Instruction is a single nested instruction file that applies to a
+// directory subtree.
+type Instruction struct {
+	Dir     string // Directory the instructions apply to, relative to the project root (slash-separated).
+	Source  string // File the instructions were read from, relative to the project root (slash-separated).
+	Content string // File content with frontmatter stripped and @include directives expanded.
+}
+
+// NestedLoader discovers instruction files placed in subdirectories of a
+// project (e.g. services/api/AGENTS.md). Files at the project root are not
+// returned because they are already part of the system prompt context.
+type NestedLoader struct {
+	root        string
+	names       []string
+	maxFileSize int
+}
+
+// NewNestedLoader creates a NestedLoader for projectRoot. names lists the
+// instruction file names to look for in each directory; nil or empty uses
+// DefaultNestedFileNames. maxFileSize caps the bytes read per file; zero or
+// negative values fall back to DefaultMaxExternalFileSize.
+func NewNestedLoader(projectRoot string, names []string, maxFileSize int) *NestedLoader {
+	if len(names) == 0 {
+		names = DefaultNestedFileNames
+	}
+	if maxFileSize <= 0 {
+		maxFileSize = DefaultMaxExternalFileSize
+	}
+	if abs, err := filepath.Abs(projectRoot); err == nil {
+		projectRoot = abs
+	}
+
+	return &NestedLoader{root: projectRoot, names: names, maxFileSize: maxFileSize}
+}
+
+// Root returns the absolute project root the loader resolves paths against.
+func (l *NestedLoader) Root() string { return l.root }
+
+// ForPath returns the nested instructions that apply to path (a file or
+// directory, absolute or relative to the project root). Results are ordered
+// from the outermost directory to the innermost, so more specific
+// instructions appear last and take precedence. Paths outside the project
+// root yield nil.
+func (l *NestedLoader) ForPath(path string) []Instruction {
+	abs := filepath.Clean(path)
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(l.root, abs)
+	}
+
+	rel, err := filepath.Rel(l.root, abs)
+	if err != nil || rel == "." || !filepath.IsLocal(rel) {
+		return nil
+	}
+
+	// Treat the path as a directory only when it is one; missing paths
+	// (e.g. a file about to be written) resolve to their parent.
+	dirRel := filepath.Dir(rel)
+	if info, statErr := os.Stat(abs); statErr == nil && info.IsDir() {
+		dirRel = rel
+	}
+
+	var dirs []string
+	for d := dirRel; d != "." && d != string(filepath.Separator); d = filepath.Dir(d) {
+		dirs = append(dirs, d)
+	}
+
+	var out []Instruction
+	for i := len(dirs) - 1; i >= 0; i-- {
+		out = append(out, l.loadDir(dirs[i])...)
+	}
+
+	return out
+}
+
+// loadDir reads the instruction files located directly in dirRel.
+func (l *NestedLoader) loadDir(dirRel string) []Instruction {
+	var out []Instruction
+
+	for _, name := range l.names {
+		srcRel := filepath.Join(dirRel, name)
+		s := l.expand(filepath.Join(l.root, srcRel), 0, map[string]struct{}{})
+		if s == "" {
+			continue
+		}
+
+		out = append(out, Instruction{
+			Dir:     filepath.ToSlash(dirRel),
+			Source:  filepath.ToSlash(srcRel),
+			Content: s,
+		})
+	}
+
+	return out
+}
+
+// expand reads path, strips frontmatter, and replaces @include lines with the
+// referenced file's expanded content. Includes resolve relative to the
+// including file and must stay inside the project root; missing, escaping
+// and cyclic includes are dropped.
+func (l *NestedLoader) expand(path string, depth int, visiting map[string]struct{}) string {
+	if _, cyclic := visiting[path]; cyclic {
+		return ""
+	}
+
+	raw := readFileContent(path, l.maxFileSize)
+	if raw == "" {
+		return ""
+	}
+
+	visiting[path] = struct{}{}
+	defer delete(visiting, path)
+
+	lines := strings.Split(stripFrontmatter(raw), "\n")
+	for i, line := range lines {
+		target, ok := strings.CutPrefix(strings.TrimSpace(line), includeDirective)
+		if !ok {
+			continue
+		}
+
+		lines[i] = ""
+		if depth >= maxIncludeDepth {
+			continue
+		}
+
+		incPath := filepath.Join(filepath.Dir(path), strings.TrimSpace(target))
+		if rel, err := filepath.Rel(l.root, incPath); err != nil || !filepath.IsLocal(rel) {
+			continue
+		}
+
+		lines[i] = l.expand(incPath, depth+1, visiting)
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,134 @@
+package projectctx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeNested(t *testing.T, root, rel, body string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+	require.NoError(t, os.WriteFile(path, []byte(body), 0o600))
+}
+
+func TestNestedLoader_OrdersOutermostFirst(t *testing.T) {
+	root := t.TempDir()
+	writeNested(t, root, "AGENTS.md", "root rules")
+	writeNested(t, root, "services/AGENTS.md", "services rules")
+	writeNested(t, root, "services/api/AGENTS.md", "api rules")
+	writeNested(t, root, "services/api/.shelly/context.md", "api shelly rules")
+	writeNested(t, root, "services/api/handler.go", "package api")
+
+	l := NewNestedLoader(root, nil, 0)
+	insts := l.ForPath(filepath.Join(root, "services", "api", "handler.go"))
+
+	require.Len(t, insts, 3)
+	assert.Equal(t, Instruction{Dir: "services", Source: "services/AGENTS.md", Content: "services rules"}, insts[0])
+	assert.Equal(t, Instruction{Dir: "services/api", Source: "services/api/AGENTS.md", Content: "api rules"}, insts[1])
+	assert.Equal(t, "services/api/.shelly/context.md", insts[2].Source)
+}
+
+func TestNestedLoader_DirectoryPath(t *testing.T) {
+	root := t.TempDir()
+	writeNested(t, root, "pkg/CLAUDE.md", "pkg rules")
+
+	l := NewNestedLoader(root, nil, 0)
+
+	insts := l.ForPath(filepath.Join(root, "pkg"))
+	require.Len(t, insts, 1)
+	assert.Equal(t, "pkg", insts[0].Dir)
+}
+
+func TestNestedLoader_MissingFileUsesParent(t *testing.T) {
+	root := t.TempDir()
+	writeNested(t, root, "web/AGENTS.md", "web rules")
+
+	l := NewNestedLoader(root, nil, 0)
+
+	insts := l.ForPath(filepath.Join(root, "web", "new_file.ts"))
+	require.Len(t, insts, 1)
+	assert.Equal(t, "web rules", insts[0].Content)
+}
+
+func TestNestedLoader_RelativePathUsesRoot(t *testing.T) {
+	root := t.TempDir()
+	writeNested(t, root, "web/AGENTS.md", "web rules")
+	t.Chdir(t.TempDir())
+
+	l := NewNestedLoader(root, nil, 0)
+
+	insts := l.ForPath(filepath.Join("web", "app.ts"))
+	require.Len(t, insts, 1)
+	assert.Equal(t, "web rules", insts[0].Content)
+	assert.Nil(t, l.ForPath(filepath.Join("..", "web", "app.ts")))
+}
+
+func TestNestedLoader_OutsideRoot(t *testing.T) {
+	root := t.TempDir()
+	other := t.TempDir()
+	writeNested(t, other, "sub/AGENTS.md", "other rules")
+
+	l := NewNestedLoader(root, nil, 0)
+
+	assert.Nil(t, l.ForPath(filepath.Join(other, "sub", "x.go")))
+	assert.Nil(t, l.ForPath(root))
+}
+
+func TestNestedLoader_CustomNames(t *testing.T) {
+	root := t.TempDir()
+	writeNested(t, root, "svc/AGENTS.md", "ignored")
+	writeNested(t, root, "svc/RULES.md", "custom rules")
+
+	l := NewNestedLoader(root, []string{"RULES.md"}, 0)
+
+	insts := l.ForPath(filepath.Join(root, "svc", "main.go"))
+	require.Len(t, insts, 1)
+	assert.Equal(t, "custom rules", insts[0].Content)
+}
+
+func TestNestedLoader_StripsFrontmatter(t *testing.T) {
+	root := t.TempDir()
+	writeNested(t, root, "svc/AGENTS.md", "---\ntitle: svc\n---\nBody text.")
+
+	l := NewNestedLoader(root, nil, 0)
+
+	insts := l.ForPath(filepath.Join(root, "svc", "main.go"))
+	require.Len(t, insts, 1)
+	assert.Equal(t, "Body text.", insts[0].Content)
+}
+
+func TestNestedLoader_Include(t *testing.T) {
+	root := t.TempDir()
+	writeNested(t, root, "shared/style.md", "Use tabs.\n@include nested.md")
+	writeNested(t, root, "shared/nested.md", "Nested include.")
+	writeNested(t, root, "svc/AGENTS.md", "Service rules.\n@include ../shared/style.md\nEnd.")
+
+	l := NewNestedLoader(root, nil, 0)
+
+	insts := l.ForPath(filepath.Join(root, "svc", "main.go"))
+	require.Len(t, insts, 1)
+	assert.Equal(t, "Service rules.\nUse tabs.\nNested include.\nEnd.", insts[0].Content)
+}
+
+func TestNestedLoader_IncludeCycleAndEscape(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "secret.md")
+	require.NoError(t, os.WriteFile(outside, []byte("secret"), 0o600))
+	rel, err := filepath.Rel(filepath.Join(root, "svc"), outside)
+	require.NoError(t, err)
+
+	writeNested(t, root, "svc/a.md", "A\n@include b.md")
+	writeNested(t, root, "svc/b.md", "B\n@include a.md")
+	writeNested(t, root, "svc/AGENTS.md", "@include a.md\n@include "+filepath.ToSlash(rel)+"\n@include missing.md")
+
+	l := NewNestedLoader(root, nil, 0)
+
+	insts := l.ForPath(filepath.Join(root, "svc", "main.go"))
+	require.Len(t, insts, 1)
+	assert.Equal(t, "A\nB", insts[0].Content)
+}
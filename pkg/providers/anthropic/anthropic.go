@@ -16,19 +16,34 @@ import (
 	"github.com/germanamz/shelly/pkg/tools/toolbox"
 )
 
-const messagesPath = "/v1/messages"
+const (
+	messagesPath    = "/v1/messages"
+	countTokensPath = "/v1/messages/count_tokens"
+)
 
 var (
 	_ modeladapter.Completer             = (*Adapter)(nil)
 	_ modeladapter.UsageReporter         = (*Adapter)(nil)
 	_ modeladapter.RateLimitInfoReporter = (*Adapter)(nil)
+	_ modeladapter.TokenCounter          = (*Adapter)(nil)
 )
 
 // Adapter implements modeladapter.Completer for the Anthropic Messages API.
 type Adapter struct {
-	client *modeladapter.Client
-	Config modeladapter.ModelConfig
-	usage  usage.Tracker
+	client   *modeladapter.Client
+	Config   modeladapter.ModelConfig
+	platform Platform
+	usage    usage.Tracker
+}
+
+// Platform routes an Adapter through a cloud platform that serves Anthropic
+// models with the Messages wire format, such as Google Vertex AI.
+type Platform struct {
+	// Path replaces /v1/messages as the request path.
+	Path string
+	// AnthropicVersion is sent in the request body instead of the model
+	// name, e.g. "vertex-2023-10-16".
+	AnthropicVersion string
 }
 
 // New creates an Adapter configured for the Anthropic API.
@@ -46,6 +61,20 @@ func New(baseURL, apiKey, model string) *Adapter {
 	}
 }
 
+// NewWithPlatform creates an Adapter that sends Messages API requests through
+// a cloud platform. Authentication is left to opts, typically
+// modeladapter.WithAuthorizer.
+func NewWithPlatform(baseURL, model string, p Platform, opts ...modeladapter.ClientOption) *Adapter {
+	return &Adapter{
+		client: modeladapter.NewClient(baseURL, modeladapter.Auth{}, opts...),
+		Config: modeladapter.ModelConfig{
+			Name:      model,
+			MaxTokens: 4096,
+		},
+		platform: p,
+	}
+}
+
 // UsageTracker returns the adapter's token usage tracker.
 func (a *Adapter) UsageTracker() *usage.Tracker { return &a.usage }
 
@@ -62,21 +91,60 @@ func (a *Adapter) LastRateLimitInfo() *modeladapter.RateLimitInfo {
 func (a *Adapter) Complete(ctx context.Context, c *chat.Chat, tools []toolbox.Tool) (message.Message, error) {
 	req := a.buildRequest(c, tools)
 
+	path := messagesPath
+	if a.platform.Path != "" {
+		path = a.platform.Path
+	}
+
 	var resp apiResponse
-	if err := a.client.PostJSON(ctx, messagesPath, req, &resp); err != nil {
+	if err := a.client.PostJSON(ctx, path, req, &resp); err != nil {
 		return message.Message{}, fmt.Errorf("anthropic: %w", err)
 	}
 
-	a.usage.Add(usage.TokenCount{
-		InputTokens:              resp.Usage.InputTokens,
-		OutputTokens:             resp.Usage.OutputTokens,
-		CacheCreationInputTokens: resp.Usage.CacheCreationInputTokens,
-		CacheReadInputTokens:     resp.Usage.CacheReadInputTokens,
-	})
+	a.usage.Add(resp.Usage.tokenCount())
 
 	return a.parseResponse(resp), nil
 }
 
+// CountTokens returns the input tokens of the request Complete would send for
+// c and tools, using the Messages API count_tokens endpoint. It is not
+// available when the adapter runs through a Platform.
+func (a *Adapter) CountTokens(ctx context.Context, c *chat.Chat, tools []toolbox.Tool) (int, error) {
+	if a.platform.Path != "" {
+		return 0, fmt.Errorf("anthropic: token counting is not available on this platform")
+	}
+
+	req := a.buildRequest(c, tools)
+	body := countRequest{
+		Model:    req.Model,
+		System:   req.System,
+		Messages: req.Messages,
+		Tools:    req.Tools,
+	}
+
+	var resp countResponse
+	if err := a.client.PostJSON(ctx, countTokensPath, body, &resp); err != nil {
+		return 0, fmt.Errorf("anthropic: count tokens: %w", err)
+	}
+	return resp.InputTokens, nil
+}
+
+// MarshalRequest returns the Messages API request body for c and tools. It is
+// used by batch submitters of platforms that take Anthropic request bodies.
+func (a *Adapter) MarshalRequest(c *chat.Chat, tools []toolbox.Tool) (json.RawMessage, error) {
+	return json.Marshal(a.buildRequest(c, tools))
+}
+
+// UnmarshalResponse parses a Messages API response body into the assistant
+// reply and its token usage.
+func (a *Adapter) UnmarshalResponse(data []byte) (message.Message, usage.TokenCount, error) {
+	var resp apiResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return message.Message{}, usage.TokenCount{}, fmt.Errorf("anthropic: decode response: %w", err)
+	}
+	return a.parseResponse(resp), resp.Usage.tokenCount(), nil
+}
+
 // --- request types ---
 
 type cacheControl struct {
@@ -84,12 +152,25 @@ type cacheControl struct {
 }
 
 type apiRequest struct {
-	Model       string           `json:"model"`
-	MaxTokens   int              `json:"max_tokens"`
-	System      []apiSystemBlock `json:"system,omitempty"`
-	Messages    []apiMessage     `json:"messages"`
-	Temperature *float64         `json:"temperature,omitempty"`
-	Tools       []apiToolDef     `json:"tools,omitempty"`
+	Model            string           `json:"model,omitempty"`
+	AnthropicVersion string           `json:"anthropic_version,omitempty"`
+	MaxTokens        int              `json:"max_tokens"`
+	System           []apiSystemBlock `json:"system,omitempty"`
+	Messages         []apiMessage     `json:"messages"`
+	Temperature      *float64         `json:"temperature,omitempty"`
+	Tools            []apiToolDef     `json:"tools,omitempty"`
+}
+
+// countRequest is the count_tokens body, which takes no generation settings.
+type countRequest struct {
+	Model    string           `json:"model"`
+	System   []apiSystemBlock `json:"system,omitempty"`
+	Messages []apiMessage     `json:"messages"`
+	Tools    []apiToolDef     `json:"tools,omitempty"`
+}
+
+type countResponse struct {
+	InputTokens int `json:"input_tokens"`
 }
 
 type apiSystemBlock struct {
@@ -143,6 +224,15 @@ type apiUsage struct {
 	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
 }
 
+func (u apiUsage) tokenCount() usage.TokenCount {
+	return usage.TokenCount{
+		InputTokens:              u.InputTokens,
+		OutputTokens:             u.OutputTokens,
+		CacheCreationInputTokens: u.CacheCreationInputTokens,
+		CacheReadInputTokens:     u.CacheReadInputTokens,
+	}
+}
+
 // --- conversion helpers ---
 
 func (a *Adapter) buildRequest(c *chat.Chat, tools []toolbox.Tool) apiRequest {
@@ -150,6 +240,10 @@ func (a *Adapter) buildRequest(c *chat.Chat, tools []toolbox.Tool) apiRequest {
 		Model:     a.Config.Name,
 		MaxTokens: a.Config.MaxTokens,
 	}
+	if a.platform.AnthropicVersion != "" {
+		req.Model = ""
+		req.AnthropicVersion = a.platform.AnthropicVersion
+	}
 
 	if sp := c.SystemPrompt(); sp != "" {
 		req.System = []apiSystemBlock{
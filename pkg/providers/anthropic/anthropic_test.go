@@ -491,3 +491,35 @@ func TestComplete_HTTPError(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "401")
 }
+
+func TestCountTokens(t *testing.T) {
+	_, adapter := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/messages/count_tokens", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-api-key"))
+
+		req := readBody(t, r)
+		assert.Equal(t, "claude-test", req["model"])
+		assert.NotContains(t, req, "max_tokens")
+		assert.Len(t, req["messages"], 1)
+		assert.Len(t, req["tools"], 1)
+
+		writeJSON(t, w, map[string]any{"input_tokens": 42})
+	})
+
+	c := chat.New(
+		message.NewText("system", role.System, "You are helpful."),
+		message.NewText("user", role.User, "Hi"),
+	)
+	tools := []toolbox.Tool{{Name: "echo", InputSchema: json.RawMessage(`{"type":"object"}`)}}
+
+	n, err := adapter.CountTokens(context.Background(), c, tools)
+	require.NoError(t, err)
+	assert.Equal(t, 42, n)
+}
+
+func TestCountTokens_UnavailableOnPlatform(t *testing.T) {
+	a := anthropic.NewWithPlatform("http://unused", "claude-test", anthropic.Platform{Path: "/invoke"})
+
+	_, err := a.CountTokens(context.Background(), chat.New(), nil)
+	require.EqualError(t, err, "anthropic: token counting is not available on this platform")
+}
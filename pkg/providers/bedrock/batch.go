@@ -0,0 +1,281 @@
+package bedrock
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/batch"
+	"github.com/germanamz/shelly/pkg/providers/anthropic"
+)
+
+// MinBatchRecords is the smallest batch Bedrock accepts for a batch
+// inference job. Smaller batches are rejected before anything is uploaded, so
+// the batch collector completes them synchronously instead.
+const MinBatchRecords = 100
+
+// bedrockAnthropicVersion is the anthropic_version Bedrock expects in Claude
+// InvokeModel bodies.
+const bedrockAnthropicVersion = "bedrock-2023-05-31"
+
+const (
+	jobsPath      = "/model-invocation-job"
+	inputFileName = "input.jsonl"
+)
+
+// BatchOptions configure a Bedrock batch inference submitter.
+type BatchOptions struct {
+	// StorageURI is the s3://bucket/prefix under which job inputs and outputs
+	// are written.
+	StorageURI string
+	// RoleARN is the IAM service role Bedrock assumes to read and write
+	// StorageURI.
+	RoleARN string
+}
+
+// BatchSubmitter implements batch.Submitter with Bedrock batch inference jobs.
+// Records are uploaded to S3 as InvokeModel bodies, so only Anthropic models
+// are supported.
+type BatchSubmitter struct {
+	adapter *Adapter
+	control *modeladapter.Client
+	storage *s3Client
+	loc     s3Location
+	roleARN string
+	mapper  *anthropic.Adapter
+}
+
+// NewBatchSubmitter creates a BatchSubmitter for the adapter's model. When the
+// adapter uses a custom base URL, the control plane and S3 are addressed under
+// that URL too, so the whole flow can run against a single stub server.
+func NewBatchSubmitter(adapter *Adapter, opts BatchOptions) (*BatchSubmitter, error) {
+	if !IsAnthropicModel(adapter.Config.Name) {
+		return nil, fmt.Errorf("bedrock batch: model %q: only anthropic models are supported", adapter.Config.Name)
+	}
+	if opts.RoleARN == "" {
+		return nil, fmt.Errorf("bedrock batch: role_arn is required")
+	}
+	loc, err := parseS3URI(opts.StorageURI)
+	if err != nil {
+		return nil, fmt.Errorf("bedrock batch: %w", err)
+	}
+
+	controlURL := "https://bedrock." + adapter.region + ".amazonaws.com"
+	var storageEndpoint string
+	if adapter.baseURL != RuntimeURL(adapter.region) {
+		controlURL = adapter.baseURL
+		storageEndpoint = adapter.baseURL
+	}
+
+	mapper := anthropic.NewWithPlatform("", adapter.Config.Name, anthropic.Platform{AnthropicVersion: bedrockAnthropicVersion})
+
+	return &BatchSubmitter{
+		adapter: adapter,
+		control: modeladapter.NewClient(controlURL, modeladapter.Auth{},
+			modeladapter.WithAuthorizer(NewSigner(adapter.creds, adapter.region, signingName))),
+		storage: newS3Client(storageEndpoint, adapter.region, loc.Bucket, adapter.creds),
+		loc:     loc,
+		roleARN: opts.RoleARN,
+		mapper:  mapper,
+	}, nil
+}
+
+// IsAnthropicModel reports whether a Bedrock model or inference profile ID
+// refers to an Anthropic model.
+func IsAnthropicModel(model string) bool {
+	return strings.HasPrefix(model, "anthropic.") || strings.Contains(model, ".anthropic.")
+}
+
+// --- control plane types ---
+
+type createJobRequest struct {
+	JobName          string           `json:"jobName"`
+	RoleARN          string           `json:"roleArn"`
+	ModelID          string           `json:"modelId"`
+	InputDataConfig  inputDataConfig  `json:"inputDataConfig"`
+	OutputDataConfig outputDataConfig `json:"outputDataConfig"`
+}
+
+type inputDataConfig struct {
+	S3InputDataConfig struct {
+		S3URI         string `json:"s3Uri"`
+		S3InputFormat string `json:"s3InputFormat,omitempty"`
+	} `json:"s3InputDataConfig"`
+}
+
+type outputDataConfig struct {
+	S3OutputDataConfig struct {
+		S3URI string `json:"s3Uri"`
+	} `json:"s3OutputDataConfig"`
+}
+
+type createJobResponse struct {
+	JobARN string `json:"jobArn"`
+}
+
+type jobStatus struct {
+	JobARN           string           `json:"jobArn"`
+	Status           string           `json:"status"`
+	Message          string           `json:"message"`
+	InputDataConfig  inputDataConfig  `json:"inputDataConfig"`
+	OutputDataConfig outputDataConfig `json:"outputDataConfig"`
+}
+
+type inputRecord struct {
+	RecordID   string          `json:"recordId"`
+	ModelInput json.RawMessage `json:"modelInput"`
+}
+
+type outputRecord struct {
+	RecordID    string          `json:"recordId"`
+	ModelOutput json.RawMessage `json:"modelOutput"`
+	Error       *struct {
+		ErrorCode    json.RawMessage `json:"errorCode"`
+		ErrorMessage string          `json:"errorMessage"`
+	} `json:"error"`
+}
+
+// SubmitBatch uploads the requests to S3 and creates a batch inference job.
+// The returned batch ID is the job ARN.
+func (b *BatchSubmitter) SubmitBatch(ctx context.Context, reqs []batch.Request) (string, error) {
+	if len(reqs) < MinBatchRecords {
+		return "", fmt.Errorf("bedrock batch: %d requests is below the minimum of %d records per job", len(reqs), MinBatchRecords)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range reqs {
+		body, err := b.mapper.MarshalRequest(r.Chat, r.Tools)
+		if err != nil {
+			return "", fmt.Errorf("bedrock batch: build request %s: %w", r.ID, err)
+		}
+		if err := enc.Encode(inputRecord{RecordID: r.ID, ModelInput: body}); err != nil {
+			return "", fmt.Errorf("bedrock batch: encode request %s: %w", r.ID, err)
+		}
+	}
+
+	jobName := "shelly-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	inputKey := b.loc.key(jobName, inputFileName)
+	if err := b.storage.put(ctx, inputKey, buf.Bytes()); err != nil {
+		return "", fmt.Errorf("bedrock batch: upload: %w", err)
+	}
+
+	job := createJobRequest{JobName: jobName, RoleARN: b.roleARN, ModelID: b.adapter.Config.Name}
+	job.InputDataConfig.S3InputDataConfig.S3URI = b.loc.uri(inputKey)
+	job.InputDataConfig.S3InputDataConfig.S3InputFormat = "JSONL"
+	job.OutputDataConfig.S3OutputDataConfig.S3URI = b.loc.uri(b.loc.key(jobName, "output")) + "/"
+
+	var resp createJobResponse
+	if err := b.control.PostJSON(ctx, jobsPath, job, &resp); err != nil {
+		return "", fmt.Errorf("bedrock batch: submit: %w", err)
+	}
+	return resp.JobARN, nil
+}
+
+// PollBatch checks the job status and reads its output once it has finished.
+func (b *BatchSubmitter) PollBatch(ctx context.Context, batchID string) (map[string]batch.Result, bool, error) {
+	req, err := b.control.NewRequest(ctx, http.MethodGet, jobsPath+"/"+uriEscape(batchID), nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("bedrock batch: poll: %w", err)
+	}
+	resp, err := b.control.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("bedrock batch: poll: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, false, fmt.Errorf("bedrock batch: poll: status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var status jobStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, false, fmt.Errorf("bedrock batch: poll: decode: %w", err)
+	}
+
+	switch status.Status {
+	case "Completed", "PartiallyCompleted":
+	case "Failed", "Stopped", "Expired":
+		return nil, false, fmt.Errorf("bedrock batch: job %s: %s: %s", batchID, strings.ToLower(status.Status), status.Message)
+	default:
+		return nil, false, nil
+	}
+
+	results, err := b.fetchResults(ctx, batchID, status)
+	if err != nil {
+		return nil, false, err
+	}
+	return results, true, nil
+}
+
+// CancelBatch stops an in-progress job.
+func (b *BatchSubmitter) CancelBatch(ctx context.Context, batchID string) error {
+	return b.control.PostJSON(ctx, jobsPath+"/"+uriEscape(batchID)+"/stop", nil, nil)
+}
+
+// fetchResults reads the job output, which Bedrock writes to
+// {output uri}{job id}/{input file name}.out.
+func (b *BatchSubmitter) fetchResults(ctx context.Context, jobARN string, status jobStatus) (map[string]batch.Result, error) {
+	outputURI := strings.TrimSuffix(status.OutputDataConfig.S3OutputDataConfig.S3URI, "/")
+	out, err := parseS3URI(outputURI)
+	if err != nil {
+		return nil, fmt.Errorf("bedrock batch: results: %w", err)
+	}
+	jobID := jobARN[strings.LastIndex(jobARN, "/")+1:]
+	inputURI := status.InputDataConfig.S3InputDataConfig.S3URI
+	inputName := inputURI[strings.LastIndex(inputURI, "/")+1:]
+
+	body, err := b.storage.get(ctx, out.key(jobID, inputName+".out"))
+	if err != nil {
+		return nil, fmt.Errorf("bedrock batch: results: %w", err)
+	}
+	defer func() { _ = body.Close() }()
+
+	results := make(map[string]batch.Result)
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var rec outputRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, fmt.Errorf("bedrock batch: parse result line: %w", err)
+		}
+		results[rec.RecordID] = b.convertRecord(rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("bedrock batch: scan results: %w", err)
+	}
+	return results, nil
+}
+
+func (b *BatchSubmitter) convertRecord(rec outputRecord) batch.Result {
+	if rec.Error != nil {
+		return batch.Result{Err: fmt.Errorf("bedrock batch: request %s: %s (code %s)", rec.RecordID, rec.Error.ErrorMessage, rec.Error.ErrorCode)}
+	}
+	if len(rec.ModelOutput) == 0 {
+		return batch.Result{Err: fmt.Errorf("bedrock batch: request %s: no model output", rec.RecordID)}
+	}
+
+	msg, tc, err := b.mapper.UnmarshalResponse(rec.ModelOutput)
+	if err != nil {
+		return batch.Result{Err: fmt.Errorf("bedrock batch: request %s: %w", rec.RecordID, err)}
+	}
+	if len(msg.Parts) == 0 {
+		return batch.Result{Err: fmt.Errorf("bedrock batch: request %s: empty content in response", rec.RecordID)}
+	}
+	return batch.Result{Message: msg, Usage: tc}
+}
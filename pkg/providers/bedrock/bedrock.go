@@ -0,0 +1,430 @@
+// Package bedrock provides a Completer implementation for AWS Bedrock's
+// Converse API, signed with AWS Signature Version 4.
+package bedrock
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/usage"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+)
+
+var (
+	_ modeladapter.Completer     = (*Adapter)(nil)
+	_ modeladapter.UsageReporter = (*Adapter)(nil)
+)
+
+// Metadata keys used to replay reasoning blocks, which Bedrock requires to be
+// sent back unchanged alongside tool use.
+const (
+	ReasoningSignatureMetaKey = "bedrock_signature"
+	ReasoningRedactedMetaKey  = "bedrock_redacted"
+)
+
+// signingName is the SigV4 service name of the Bedrock runtime and control
+// plane endpoints.
+const signingName = "bedrock"
+
+// Adapter implements modeladapter.Completer for the Bedrock Converse API.
+type Adapter struct {
+	client *modeladapter.Client
+	Config modeladapter.ModelConfig
+	usage  usage.Tracker
+
+	baseURL string
+	region  string
+	creds   CredentialsProvider
+}
+
+// New creates an Adapter for the given region and model ID (or inference
+// profile ID). An empty baseURL uses the region's public runtime endpoint.
+func New(baseURL, region, model string, creds CredentialsProvider) *Adapter {
+	if baseURL == "" {
+		baseURL = RuntimeURL(region)
+	}
+	return &Adapter{
+		client: modeladapter.NewClient(baseURL, modeladapter.Auth{},
+			modeladapter.WithAuthorizer(NewSigner(creds, region, signingName))),
+		Config: modeladapter.ModelConfig{
+			Name:      model,
+			MaxTokens: 4096,
+		},
+		baseURL: baseURL,
+		region:  region,
+		creds:   creds,
+	}
+}
+
+// RuntimeURL returns the Bedrock runtime endpoint of a region.
+func RuntimeURL(region string) string {
+	return "https://bedrock-runtime." + region + ".amazonaws.com"
+}
+
+// ResolveRegion returns region, else AWS_REGION, else AWS_DEFAULT_REGION.
+func ResolveRegion(region string) string {
+	if region != "" {
+		return region
+	}
+	if r := os.Getenv("AWS_REGION"); r != "" {
+		return r
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// UsageTracker returns the adapter's token usage tracker.
+func (a *Adapter) UsageTracker() *usage.Tracker { return &a.usage }
+
+// ModelMaxTokens returns the maximum tokens the model will generate per response.
+func (a *Adapter) ModelMaxTokens() int { return a.Config.MaxTokens }
+
+// Complete sends a conversation to the Converse API and returns the
+// assistant's reply.
+func (a *Adapter) Complete(ctx context.Context, c *chat.Chat, tools []toolbox.Tool) (message.Message, error) {
+	req := a.buildRequest(c, tools)
+	path := "/model/" + uriEscape(a.Config.Name) + "/converse"
+
+	var resp apiResponse
+	if err := a.client.PostJSON(ctx, path, req, &resp); err != nil {
+		return message.Message{}, fmt.Errorf("bedrock: %w", err)
+	}
+
+	a.usage.Add(usage.TokenCount{
+		InputTokens:              resp.Usage.InputTokens,
+		OutputTokens:             resp.Usage.OutputTokens,
+		CacheReadInputTokens:     resp.Usage.CacheReadInputTokens,
+		CacheCreationInputTokens: resp.Usage.CacheWriteInputTokens,
+	})
+
+	if len(resp.Output.Message.Content) == 0 {
+		return message.Message{}, fmt.Errorf("bedrock: empty content in response (stop reason %q)", resp.StopReason)
+	}
+	return parseMessage(resp.Output.Message), nil
+}
+
+// --- request types ---
+
+type apiRequest struct {
+	Messages        []apiMessage    `json:"messages"`
+	System          []apiSystem     `json:"system,omitempty"`
+	InferenceConfig inferenceConfig `json:"inferenceConfig"`
+	ToolConfig      *toolConfig     `json:"toolConfig,omitempty"`
+}
+
+type apiSystem struct {
+	Text string `json:"text"`
+}
+
+type inferenceConfig struct {
+	MaxTokens   int      `json:"maxTokens,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+type toolConfig struct {
+	Tools []apiTool `json:"tools"`
+}
+
+type apiTool struct {
+	ToolSpec toolSpec `json:"toolSpec"`
+}
+
+type toolSpec struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	InputSchema inputSchema `json:"inputSchema"`
+}
+
+type inputSchema struct {
+	JSON json.RawMessage `json:"json"`
+}
+
+type apiMessage struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+// contentBlock is a Converse ContentBlock union; exactly one field is set.
+type contentBlock struct {
+	Text             string            `json:"text,omitempty"`
+	Image            *imageBlock       `json:"image,omitempty"`
+	Document         *documentBlock    `json:"document,omitempty"`
+	ToolUse          *toolUseBlock     `json:"toolUse,omitempty"`
+	ToolResult       *toolResultBlock  `json:"toolResult,omitempty"`
+	ReasoningContent *reasoningContent `json:"reasoningContent,omitempty"`
+}
+
+type imageBlock struct {
+	Format string      `json:"format"`
+	Source bytesSource `json:"source"`
+}
+
+type documentBlock struct {
+	Format string      `json:"format"`
+	Name   string      `json:"name"`
+	Source bytesSource `json:"source"`
+}
+
+// bytesSource carries a blob; []byte is base64-encoded by encoding/json, which
+// is the Converse REST encoding for blobs.
+type bytesSource struct {
+	Bytes []byte `json:"bytes"`
+}
+
+type toolUseBlock struct {
+	ToolUseID string          `json:"toolUseId"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+}
+
+type toolResultBlock struct {
+	ToolUseID string              `json:"toolUseId"`
+	Content   []toolResultContent `json:"content"`
+	Status    string              `json:"status,omitempty"`
+}
+
+type toolResultContent struct {
+	Text string `json:"text"`
+}
+
+type reasoningContent struct {
+	ReasoningText   *reasoningText `json:"reasoningText,omitempty"`
+	RedactedContent []byte         `json:"redactedContent,omitempty"`
+}
+
+type reasoningText struct {
+	Text      string `json:"text"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// --- response types ---
+
+type apiResponse struct {
+	Output struct {
+		Message apiMessage `json:"message"`
+	} `json:"output"`
+	StopReason string   `json:"stopReason"`
+	Usage      apiUsage `json:"usage"`
+}
+
+type apiUsage struct {
+	InputTokens           int `json:"inputTokens"`
+	OutputTokens          int `json:"outputTokens"`
+	CacheReadInputTokens  int `json:"cacheReadInputTokens"`
+	CacheWriteInputTokens int `json:"cacheWriteInputTokens"`
+}
+
+// --- conversion helpers ---
+
+func (a *Adapter) buildRequest(c *chat.Chat, tools []toolbox.Tool) apiRequest {
+	req := apiRequest{
+		InferenceConfig: inferenceConfig{MaxTokens: a.Config.MaxTokens},
+	}
+
+	if sp := c.SystemPrompt(); sp != "" {
+		req.System = []apiSystem{{Text: sp}}
+	}
+
+	if a.Config.Temperature != 0 {
+		t := a.Config.Temperature
+		req.InferenceConfig.Temperature = &t
+	}
+
+	if len(tools) > 0 {
+		req.ToolConfig = &toolConfig{Tools: make([]apiTool, len(tools))}
+		for i, t := range tools {
+			schema := t.InputSchema
+			if schema == nil {
+				schema = json.RawMessage(`{"type":"object"}`)
+			}
+			req.ToolConfig.Tools[i] = apiTool{ToolSpec: toolSpec{
+				Name:        t.Name,
+				Description: t.Description,
+				InputSchema: inputSchema{JSON: schema},
+			}}
+		}
+	}
+
+	for _, m := range c.Messages() {
+		if m.Role == role.System {
+			continue
+		}
+		appendMessage(&req.Messages, m)
+	}
+
+	return req
+}
+
+// appendMessage converts m into Converse blocks, merging consecutive blocks of
+// the same role since Converse requires alternating roles.
+func appendMessage(msgs *[]apiMessage, m message.Message) {
+	msgRole := "user"
+	if m.Role == role.Assistant {
+		msgRole = "assistant"
+	}
+
+	for _, p := range m.Parts {
+		block := partToBlock(p)
+		if block == nil {
+			continue
+		}
+
+		if n := len(*msgs); n > 0 && (*msgs)[n-1].Role == msgRole {
+			(*msgs)[n-1].Content = append((*msgs)[n-1].Content, *block)
+			continue
+		}
+		*msgs = append(*msgs, apiMessage{Role: msgRole, Content: []contentBlock{*block}})
+	}
+}
+
+func partToBlock(p content.Part) *contentBlock {
+	switch v := p.(type) {
+	case content.Text:
+		if v.Text == "" {
+			return nil
+		}
+		return &contentBlock{Text: v.Text}
+	case content.Image:
+		if len(v.Data) == 0 {
+			return nil
+		}
+		return &contentBlock{Image: &imageBlock{
+			Format: strings.TrimPrefix(v.MediaType, "image/"),
+			Source: bytesSource{Bytes: v.Data},
+		}}
+	case content.Document:
+		return &contentBlock{Document: &documentBlock{
+			Format: documentFormat(v),
+			Name:   documentName(v.Path),
+			Source: bytesSource{Bytes: v.Data},
+		}}
+	case content.ToolCall:
+		input := json.RawMessage(v.Arguments)
+		if len(input) == 0 {
+			input = json.RawMessage(`{}`)
+		}
+		return &contentBlock{ToolUse: &toolUseBlock{ToolUseID: v.ID, Name: v.Name, Input: input}}
+	case content.ToolResult:
+		block := &toolResultBlock{ToolUseID: v.ToolCallID, Content: []toolResultContent{{Text: v.Content}}}
+		if v.IsError {
+			block.Status = "error"
+		}
+		return &contentBlock{ToolResult: block}
+	case content.Reasoning:
+		return reasoningToBlock(v)
+	default:
+		return nil
+	}
+}
+
+// reasoningToBlock replays reasoning produced by Bedrock. Reasoning from other
+// providers carries no signature and is dropped.
+func reasoningToBlock(r content.Reasoning) *contentBlock {
+	if redacted := r.Metadata[ReasoningRedactedMetaKey]; redacted != "" {
+		data, err := base64.StdEncoding.DecodeString(redacted)
+		if err != nil {
+			return nil
+		}
+		return &contentBlock{ReasoningContent: &reasoningContent{RedactedContent: data}}
+	}
+	sig := r.Metadata[ReasoningSignatureMetaKey]
+	if sig == "" {
+		return nil
+	}
+	return &contentBlock{ReasoningContent: &reasoningContent{
+		ReasoningText: &reasoningText{Text: r.Text, Signature: sig},
+	}}
+}
+
+var documentFormats = map[string]string{
+	"application/pdf":    "pdf",
+	"text/csv":           "csv",
+	"application/msword": "doc",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": "docx",
+	"application/vnd.ms-excel": "xls",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": "xlsx",
+	"text/html":     "html",
+	"text/plain":    "txt",
+	"text/markdown": "md",
+}
+
+func documentFormat(d content.Document) string {
+	if f, ok := documentFormats[d.MediaType]; ok {
+		return f
+	}
+	if ext := strings.TrimPrefix(filepath.Ext(d.Path), "."); ext != "" {
+		return strings.ToLower(ext)
+	}
+	return "txt"
+}
+
+// documentName derives a document name from its path. Converse only accepts
+// alphanumerics, single spaces, hyphens, parentheses and square brackets.
+func documentName(path string) string {
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	var b strings.Builder
+	for _, r := range base {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '-', r == '(', r == ')', r == '[', r == ']':
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	name := strings.Join(strings.Fields(b.String()), " ")
+	if name == "" || name == "." {
+		return "document"
+	}
+	return name
+}
+
+func parseMessage(m apiMessage) message.Message {
+	var parts []content.Part
+
+	for _, block := range m.Content {
+		switch {
+		case block.Text != "":
+			parts = append(parts, content.Text{Text: block.Text})
+		case block.ToolUse != nil:
+			args := string(block.ToolUse.Input)
+			if args == "" || args == "null" {
+				args = "{}"
+			}
+			parts = append(parts, content.ToolCall{
+				ID:        block.ToolUse.ToolUseID,
+				Name:      block.ToolUse.Name,
+				Arguments: args,
+			})
+		case block.ReasoningContent != nil:
+			parts = append(parts, parseReasoning(*block.ReasoningContent))
+		}
+	}
+
+	return message.New("", role.Assistant, parts...)
+}
+
+func parseReasoning(r reasoningContent) content.Reasoning {
+	if len(r.RedactedContent) > 0 {
+		return content.Reasoning{Metadata: map[string]string{
+			ReasoningRedactedMetaKey: base64.StdEncoding.EncodeToString(r.RedactedContent),
+		}}
+	}
+	if r.ReasoningText == nil {
+		return content.Reasoning{}
+	}
+	reasoning := content.Reasoning{Text: r.ReasoningText.Text}
+	if r.ReasoningText.Signature != "" {
+		reasoning.Metadata = map[string]string{ReasoningSignatureMetaKey: r.ReasoningText.Signature}
+	}
+	return reasoning
+}
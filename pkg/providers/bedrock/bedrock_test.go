@@ -0,0 +1,548 @@
+package bedrock_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/modeladapter/batch"
+	"github.com/germanamz/shelly/pkg/providers/bedrock"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testCreds = bedrock.StaticCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}
+
+func readBody(t *testing.T, r *http.Request) map[string]any {
+	t.Helper()
+	var m map[string]any
+	require.NoError(t, json.NewDecoder(r.Body).Decode(&m))
+	return m
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, v any) {
+	t.Helper()
+	w.Header().Set("Content-Type", "application/json")
+	require.NoError(t, json.NewEncoder(w).Encode(v))
+}
+
+func TestComplete_Converse(t *testing.T) {
+	var req map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/model/anthropic.claude-3-5-sonnet-20240620-v1%3A0/converse", r.URL.EscapedPath())
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"),
+			"AWS4-HMAC-SHA256 Credential=AKID/"), r.Header.Get("Authorization"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/us-west-2/bedrock/aws4_request")
+		req = readBody(t, r)
+		writeJSON(t, w, map[string]any{
+			"output": map[string]any{"message": map[string]any{
+				"role": "assistant",
+				"content": []map[string]any{
+					{"reasoningContent": map[string]any{"reasoningText": map[string]any{"text": "Check the file.", "signature": "sig-1"}}},
+					{"text": "Reading it."},
+					{"toolUse": map[string]any{"toolUseId": "tu_1", "name": "read_file", "input": map[string]any{"path": "a.go"}}},
+				},
+			}},
+			"stopReason": "tool_use",
+			"usage":      map[string]any{"inputTokens": 30, "outputTokens": 12, "cacheReadInputTokens": 5},
+		})
+	}))
+	defer srv.Close()
+
+	adapter := bedrock.New(srv.URL, "us-west-2", "anthropic.claude-3-5-sonnet-20240620-v1:0", testCreds)
+	adapter.Config.Temperature = 0.2
+
+	c := chat.New(
+		message.NewText("", role.System, "Be brief."),
+		message.NewText("", role.User, "What is in a.go?"),
+		message.New("", role.User, content.Image{Data: []byte("png"), MediaType: "image/png"}),
+	)
+	tools := []toolbox.Tool{{Name: "read_file", Description: "Reads a file"}}
+
+	reply, err := adapter.Complete(context.Background(), c, tools)
+	require.NoError(t, err)
+
+	assert.Equal(t, []any{map[string]any{"text": "Be brief."}}, req["system"])
+	assert.Equal(t, map[string]any{"maxTokens": float64(4096), "temperature": 0.2}, req["inferenceConfig"])
+	msgs, _ := req["messages"].([]any)
+	require.Len(t, msgs, 1, "consecutive user messages are merged")
+	first, _ := msgs[0].(map[string]any)
+	blocks, _ := first["content"].([]any)
+	require.Len(t, blocks, 2)
+	img, _ := blocks[1].(map[string]any)
+	assert.Equal(t, map[string]any{"format": "png", "source": map[string]any{"bytes": "cG5n"}}, img["image"])
+	toolCfg, _ := req["toolConfig"].(map[string]any)
+	specs, _ := toolCfg["tools"].([]any)
+	require.Len(t, specs, 1)
+
+	require.Len(t, reply.Parts, 3)
+	reasoning, ok := reply.Parts[0].(content.Reasoning)
+	require.True(t, ok)
+	assert.Equal(t, "Check the file.", reasoning.Text)
+	assert.Equal(t, "sig-1", reasoning.Metadata[bedrock.ReasoningSignatureMetaKey])
+	calls := reply.ToolCalls()
+	require.Len(t, calls, 1)
+	assert.JSONEq(t, `{"path":"a.go"}`, calls[0].Arguments)
+
+	total := adapter.UsageTracker().Total()
+	assert.Equal(t, 30, total.InputTokens)
+	assert.Equal(t, 5, total.CacheReadInputTokens)
+}
+
+func TestComplete_ReplaysReasoningAndToolResults(t *testing.T) {
+	var req map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = readBody(t, r)
+		writeJSON(t, w, map[string]any{
+			"output": map[string]any{"message": map[string]any{
+				"role": "assistant", "content": []map[string]any{{"text": "Empty."}},
+			}},
+		})
+	}))
+	defer srv.Close()
+
+	adapter := bedrock.New(srv.URL, "us-east-1", "anthropic.claude-sonnet-4", testCreds)
+	c := chat.New(
+		message.NewText("", role.User, "What is in a.go?"),
+		message.New("", role.Assistant,
+			content.Reasoning{Text: "Check.", Metadata: map[string]string{bedrock.ReasoningSignatureMetaKey: "sig"}},
+			content.Reasoning{Text: "foreign reasoning is dropped"},
+			content.ToolCall{ID: "tu_1", Name: "read_file", Arguments: `{"path":"a.go"}`},
+		),
+		message.New("", role.Tool, content.ToolResult{ToolCallID: "tu_1", Content: "no such file", IsError: true}),
+	)
+
+	reply, err := adapter.Complete(context.Background(), c, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Empty.", reply.TextContent())
+
+	msgs, _ := req["messages"].([]any)
+	require.Len(t, msgs, 3)
+	assistant, _ := msgs[1].(map[string]any)
+	blocks, _ := assistant["content"].([]any)
+	require.Len(t, blocks, 2)
+	assert.Equal(t, map[string]any{"reasoningContent": map[string]any{
+		"reasoningText": map[string]any{"text": "Check.", "signature": "sig"},
+	}}, blocks[0])
+
+	result, _ := msgs[2].(map[string]any)
+	assert.Equal(t, "user", result["role"])
+	resultBlocks, _ := result["content"].([]any)
+	assert.Equal(t, map[string]any{"toolResult": map[string]any{
+		"toolUseId": "tu_1", "content": []any{map[string]any{"text": "no such file"}}, "status": "error",
+	}}, resultBlocks[0])
+}
+
+func TestComplete_Throttled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"message":"Too many requests"}`))
+	}))
+	defer srv.Close()
+
+	adapter := bedrock.New(srv.URL, "us-east-1", "anthropic.claude-sonnet-4", testCreds)
+	_, err := adapter.Complete(context.Background(), chat.New(message.NewText("", role.User, "hi")), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rate limited")
+}
+
+func TestDefaultCredentialChain_Env(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKENV")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "token")
+
+	creds, err := bedrock.DefaultCredentialChain("").Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKENV", creds.AccessKeyID)
+	assert.Equal(t, "token", creds.SessionToken)
+}
+
+func TestDefaultCredentialChain_SharedFile(t *testing.T) {
+	clearAWSEnv(t)
+	path := filepath.Join(t.TempDir(), "credentials")
+	require.NoError(t, os.WriteFile(path, []byte(`[default]
+aws_access_key_id = AKDEFAULT
+aws_secret_access_key = s1
+
+[work]
+aws_access_key_id = AKWORK
+aws_secret_access_key = s2
+`), 0o600))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+
+	creds, err := bedrock.DefaultCredentialChain("").Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKDEFAULT", creds.AccessKeyID)
+
+	t.Setenv("AWS_PROFILE", "work")
+	creds, err = bedrock.DefaultCredentialChain("").Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKWORK", creds.AccessKeyID)
+
+	_, err = bedrock.DefaultCredentialChain("missing").Retrieve(context.Background())
+	require.Error(t, err)
+}
+
+func TestDefaultCredentialChain_InstanceMetadata(t *testing.T) {
+	clearAWSEnv(t)
+	var credentialFetches int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			_, _ = w.Write([]byte("imds-token"))
+		case r.Header.Get("X-Aws-Ec2-Metadata-Token") != "imds-token":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/":
+			_, _ = w.Write([]byte("my-role"))
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/my-role":
+			credentialFetches++
+			_, _ = fmt.Fprint(w, `{"AccessKeyId":"AKIMDS","SecretAccessKey":"s","Token":"t","Expiration":"2999-01-01T00:00:00Z"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", srv.URL)
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "")
+
+	chain := bedrock.DefaultCredentialChain("")
+	creds, err := chain.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKIMDS", creds.AccessKeyID)
+	assert.Equal(t, "t", creds.SessionToken)
+
+	_, err = chain.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, credentialFetches, "unexpired credentials are cached")
+}
+
+func TestDefaultCredentialChain_Container(t *testing.T) {
+	clearAWSEnv(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer ecs", r.Header.Get("Authorization"))
+		_, _ = fmt.Fprint(w, `{"AccessKeyId":"AKECS","SecretAccessKey":"s","Token":"t","Expiration":"2999-01-01T00:00:00Z"}`)
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", srv.URL+"/creds")
+	t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN", "Bearer ecs")
+
+	creds, err := bedrock.DefaultCredentialChain("").Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKECS", creds.AccessKeyID)
+}
+
+// writeAWSFile writes an AWS config or credentials file and points env at it.
+func writeAWSFile(t *testing.T, env, data string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "aws")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	t.Setenv(env, path)
+}
+
+// fakeSTS answers AssumeRole and AssumeRoleWithWebIdentity with fixed
+// credentials and records the last request.
+func fakeSTS(t *testing.T) *http.Request {
+	t.Helper()
+	var last http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		last = *r
+		action := r.PostForm.Get("Action")
+		_, _ = fmt.Fprintf(w, `<%[1]sResponse><%[1]sResult><Credentials>
+<AccessKeyId>AK%[1]s</AccessKeyId><SecretAccessKey>s</SecretAccessKey><SessionToken>t</SessionToken>
+<Expiration>2999-01-01T00:00:00Z</Expiration></Credentials></%[1]sResult></%[1]sResponse>`, action)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("AWS_ENDPOINT_URL_STS", srv.URL)
+	return &last
+}
+
+func TestDefaultCredentialChain_AssumeRole(t *testing.T) {
+	clearAWSEnv(t)
+	last := fakeSTS(t)
+	writeAWSFile(t, "AWS_CONFIG_FILE", `[profile dev]
+role_arn = arn:aws:iam::123:role/dev
+source_profile = base
+external_id = ext
+region = us-west-2
+s3 =
+  max_concurrent_requests = 10
+`)
+	writeAWSFile(t, "AWS_SHARED_CREDENTIALS_FILE", `[base]
+aws_access_key_id = AKBASE
+aws_secret_access_key = s
+`)
+
+	creds, err := bedrock.DefaultCredentialChain("dev").Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKAssumeRole", creds.AccessKeyID)
+	assert.False(t, creds.Expires.IsZero())
+
+	assert.Equal(t, "arn:aws:iam::123:role/dev", last.PostForm.Get("RoleArn"))
+	assert.Equal(t, "ext", last.PostForm.Get("ExternalId"))
+	assert.Contains(t, last.Header.Get("Authorization"), "Credential=AKBASE/")
+	assert.Contains(t, last.Header.Get("Authorization"), "/us-west-2/sts/aws4_request")
+}
+
+func TestDefaultCredentialChain_WebIdentity(t *testing.T) {
+	clearAWSEnv(t)
+	last := fakeSTS(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("jwt\n"), 0o600))
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", tokenFile)
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123:role/pod")
+
+	creds, err := bedrock.DefaultCredentialChain("").Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKAssumeRoleWithWebIdentity", creds.AccessKeyID)
+	assert.Equal(t, "jwt", last.PostForm.Get("WebIdentityToken"))
+	assert.Equal(t, "arn:aws:iam::123:role/pod", last.PostForm.Get("RoleArn"))
+	assert.Empty(t, last.Header.Get("Authorization"), "web identity calls are not signed")
+}
+
+func TestDefaultCredentialChain_SSO(t *testing.T) {
+	clearAWSEnv(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	writeAWSFile(t, "AWS_CONFIG_FILE", `[profile sso]
+sso_session = corp
+sso_account_id = 123
+sso_role_name = Dev
+
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = eu-west-1
+`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/federation/credentials", r.URL.Path)
+		assert.Equal(t, "123", r.URL.Query().Get("account_id"))
+		assert.Equal(t, "Dev", r.URL.Query().Get("role_name"))
+		assert.Equal(t, "sso-token", r.Header.Get("x-amz-sso_bearer_token"))
+		_, _ = fmt.Fprint(w, `{"roleCredentials":{"accessKeyId":"AKSSO","secretAccessKey":"s","sessionToken":"t","expiration":32503680000000}}`)
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_ENDPOINT_URL_SSO", srv.URL)
+
+	_, err := bedrock.DefaultCredentialChain("sso").Retrieve(context.Background())
+	require.ErrorContains(t, err, `run "aws sso login"`)
+
+	cacheDir := filepath.Join(home, ".aws", "sso", "cache")
+	require.NoError(t, os.MkdirAll(cacheDir, 0o700))
+	sum := sha1.Sum([]byte("corp")) //nolint:gosec // cache file naming
+	cacheFile := filepath.Join(cacheDir, hex.EncodeToString(sum[:])+".json")
+
+	require.NoError(t, os.WriteFile(cacheFile, []byte(`{"accessToken":"sso-token","expiresAt":"2000-01-01T00:00:00Z"}`), 0o600))
+	_, err = bedrock.DefaultCredentialChain("sso").Retrieve(context.Background())
+	require.ErrorContains(t, err, "expired")
+
+	require.NoError(t, os.WriteFile(cacheFile, []byte(`{"accessToken":"sso-token","expiresAt":"2999-01-01T00:00:00Z"}`), 0o600))
+	creds, err := bedrock.DefaultCredentialChain("sso").Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKSSO", creds.AccessKeyID)
+	assert.Equal(t, 3000, creds.Expires.UTC().Year())
+}
+
+func TestDefaultCredentialChain_CredentialProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	clearAWSEnv(t)
+	writeAWSFile(t, "AWS_CONFIG_FILE", `[default]
+credential_process = echo '{"Version":1,"AccessKeyId":"AKPROC","SecretAccessKey":"s","SessionToken":"t","Expiration":"2999-01-01T00:00:00Z"}'
+
+[profile broken]
+credential_process = echo oops >&2; exit 3
+`)
+
+	creds, err := bedrock.DefaultCredentialChain("").Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKPROC", creds.AccessKeyID)
+	assert.Equal(t, "t", creds.SessionToken)
+
+	_, err = bedrock.DefaultCredentialChain("broken").Retrieve(context.Background())
+	require.ErrorContains(t, err, "oops")
+}
+
+func TestDefaultCredentialChain_ProfileErrors(t *testing.T) {
+	clearAWSEnv(t)
+	writeAWSFile(t, "AWS_CONFIG_FILE", `[default]
+region = us-east-1
+
+[profile mfa]
+role_arn = arn:aws:iam::123:role/admin
+source_profile = default
+mfa_serial = arn:aws:iam::123:mfa/me
+
+[profile orphan]
+role_arn = arn:aws:iam::123:role/admin
+
+[profile loop]
+role_arn = arn:aws:iam::123:role/admin
+source_profile = loop2
+
+[profile loop2]
+role_arn = arn:aws:iam::123:role/admin
+source_profile = loop
+`)
+
+	tests := []struct {
+		profile string
+		want    string
+	}{
+		{"", "no credentials found"}, // A region-only profile falls through to the metadata links.
+		{"mfa", "mfa_serial is not supported"},
+		{"orphan", "role_arn needs source_profile"},
+		{"loop", "source_profile cycle: loop -> loop2 -> loop"},
+		{"missing", `profile "missing" not found`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			_, err := bedrock.DefaultCredentialChain(tt.profile).Retrieve(context.Background())
+			require.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+// clearAWSEnv isolates a test from the host's AWS configuration.
+func clearAWSEnv(t *testing.T) {
+	t.Helper()
+	for _, k := range []string{
+		"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE",
+		"AWS_CONTAINER_CREDENTIALS_FULL_URI", "AWS_CONTAINER_CREDENTIALS_RELATIVE_URI",
+		"AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_ROLE_ARN", "AWS_ROLE_SESSION_NAME", "AWS_REGION", "AWS_DEFAULT_REGION",
+		"AWS_ENDPOINT_URL_STS", "AWS_ENDPOINT_URL_SSO",
+	} {
+		t.Setenv(k, "")
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "none"))
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "none"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+}
+
+// fakeBedrock is an in-memory S3 bucket and batch inference control plane.
+type fakeBedrock struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+	job     map[string]any
+	status  string
+}
+
+func (f *fakeBedrock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPut:
+		assert.Contains(f.t, r.Header.Get("Authorization"), "/s3/aws4_request")
+		assert.NotEmpty(f.t, r.Header.Get("X-Amz-Content-Sha256"))
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = data
+	case r.Method == http.MethodPost && r.URL.Path == "/model-invocation-job":
+		f.job = readBody(f.t, r)
+		writeJSON(f.t, w, map[string]any{"jobArn": "arn:aws:bedrock:us-east-1:123:model-invocation-job/job1"})
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/model-invocation-job/"):
+		assert.Equal(f.t, "/model-invocation-job/arn%3Aaws%3Abedrock%3Aus-east-1%3A123%3Amodel-invocation-job%2Fjob1", r.URL.EscapedPath())
+		writeJSON(f.t, w, map[string]any{
+			"status":           f.status,
+			"inputDataConfig":  f.job["inputDataConfig"],
+			"outputDataConfig": f.job["outputDataConfig"],
+		})
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestBatchSubmitter(t *testing.T) {
+	fake := &fakeBedrock{t: t, objects: map[string][]byte{}, status: "InProgress"}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	adapter := bedrock.New(srv.URL, "us-east-1", "anthropic.claude-3-haiku-20240307-v1:0", testCreds)
+	sub, err := bedrock.NewBatchSubmitter(adapter, bedrock.BatchOptions{
+		StorageURI: "s3://my-bucket/shelly",
+		RoleARN:    "arn:aws:iam::123:role/batch",
+	})
+	require.NoError(t, err)
+
+	reqs := make([]batch.Request, bedrock.MinBatchRecords)
+	for i := range reqs {
+		reqs[i] = batch.Request{ID: fmt.Sprintf("req-%d", i), Chat: chat.New(message.NewText("", role.User, "hi"))}
+	}
+
+	_, err = sub.SubmitBatch(context.Background(), reqs[:1])
+	require.ErrorContains(t, err, "below the minimum")
+
+	batchID, err := sub.SubmitBatch(context.Background(), reqs)
+	require.NoError(t, err)
+	assert.Equal(t, "arn:aws:bedrock:us-east-1:123:model-invocation-job/job1", batchID)
+
+	input := fake.job["inputDataConfig"].(map[string]any)["s3InputDataConfig"].(map[string]any)["s3Uri"].(string)
+	require.True(t, strings.HasPrefix(input, "s3://my-bucket/shelly/shelly-"), input)
+	key := "/my-bucket/" + strings.TrimPrefix(input, "s3://my-bucket/")
+	lines := strings.Split(strings.TrimSpace(string(fake.objects[key])), "\n")
+	require.Len(t, lines, bedrock.MinBatchRecords)
+	var record map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "req-0", record["recordId"])
+	modelInput := record["modelInput"].(map[string]any)
+	assert.Equal(t, "bedrock-2023-05-31", modelInput["anthropic_version"])
+	assert.Nil(t, modelInput["model"])
+
+	_, done, err := sub.PollBatch(context.Background(), batchID)
+	require.NoError(t, err)
+	assert.False(t, done)
+
+	output := fake.job["outputDataConfig"].(map[string]any)["s3OutputDataConfig"].(map[string]any)["s3Uri"].(string)
+	outKey := "/my-bucket/" + strings.TrimPrefix(output, "s3://my-bucket/") + "job1/input.jsonl.out"
+	fake.mu.Lock()
+	fake.status = "Completed"
+	fake.objects[outKey] = []byte(`{"recordId":"req-0","modelOutput":{"content":[{"type":"text","text":"hello"}],"usage":{"input_tokens":3,"output_tokens":1}}}
+{"recordId":"req-1","error":{"errorCode":400,"errorMessage":"bad input"}}
+`)
+	fake.mu.Unlock()
+
+	results, done, err := sub.PollBatch(context.Background(), batchID)
+	require.NoError(t, err)
+	require.True(t, done)
+	require.NoError(t, results["req-0"].Err)
+	assert.Equal(t, "hello", results["req-0"].Message.TextContent())
+	assert.Equal(t, 3, results["req-0"].Usage.InputTokens)
+	assert.ErrorContains(t, results["req-1"].Err, "bad input")
+}
+
+func TestNewBatchSubmitter_Validation(t *testing.T) {
+	adapter := bedrock.New("", "us-east-1", "amazon.nova-pro-v1:0", testCreds)
+	_, err := bedrock.NewBatchSubmitter(adapter, bedrock.BatchOptions{StorageURI: "s3://b", RoleARN: "arn"})
+	require.ErrorContains(t, err, "only anthropic models")
+
+	adapter = bedrock.New("", "us-east-1", "us.anthropic.claude-sonnet-4", testCreds)
+	_, err = bedrock.NewBatchSubmitter(adapter, bedrock.BatchOptions{StorageURI: "gs://b", RoleARN: "arn"})
+	require.ErrorContains(t, err, "must start with s3://")
+	_, err = bedrock.NewBatchSubmitter(adapter, bedrock.BatchOptions{StorageURI: "s3://b"})
+	require.ErrorContains(t, err, "role_arn is required")
+}
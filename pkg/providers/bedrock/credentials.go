@@ -0,0 +1,228 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials are AWS access keys used to sign requests.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string    // Set for temporary credentials.
+	Expires         time.Time // Zero for credentials that do not expire.
+}
+
+// CredentialsProvider returns the credentials requests are signed with.
+type CredentialsProvider interface {
+	Retrieve(ctx context.Context) (Credentials, error)
+}
+
+// StaticCredentials is a CredentialsProvider that always returns itself.
+type StaticCredentials Credentials
+
+// Retrieve returns the static credentials.
+func (s StaticCredentials) Retrieve(context.Context) (Credentials, error) {
+	return Credentials(s), nil
+}
+
+// errNoCredentials is returned by a chain link that found nothing to load.
+var errNoCredentials = errors.New("no credentials")
+
+// refreshWindow is how long before expiry cached credentials are refreshed.
+const refreshWindow = 5 * time.Minute
+
+// metadataTimeout bounds requests to the container and instance metadata
+// endpoints, which do not answer at all outside AWS.
+const metadataTimeout = 2 * time.Second
+
+// apiTimeout bounds requests to STS and the SSO portal.
+const apiTimeout = 30 * time.Second
+
+// DefaultCredentialChain returns the standard AWS credential chain: the
+// AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY/AWS_SESSION_TOKEN environment
+// variables, web identity federation from AWS_WEB_IDENTITY_TOKEN_FILE and
+// AWS_ROLE_ARN, the shared config and credentials files (profile, else
+// AWS_PROFILE, else "default"), the ECS container credentials endpoint and
+// the EC2 instance metadata service (IMDSv2). The first link that yields
+// credentials wins and its result is cached until shortly before it expires.
+func DefaultCredentialChain(profile string) CredentialsProvider {
+	metadata := &http.Client{Timeout: metadataTimeout}
+	api := &http.Client{Timeout: apiTimeout}
+	return &cachedChain{links: []credentialLink{
+		envCredentials,
+		func(ctx context.Context) (Credentials, error) { return envWebIdentityCredentials(ctx, api) },
+		func(ctx context.Context) (Credentials, error) { return profileCredentials(ctx, profile, metadata, api) },
+		func(ctx context.Context) (Credentials, error) { return containerCredentials(ctx, metadata) },
+		func(ctx context.Context) (Credentials, error) { return instanceCredentials(ctx, metadata) },
+	}}
+}
+
+type credentialLink func(ctx context.Context) (Credentials, error)
+
+type cachedChain struct {
+	links []credentialLink
+
+	mu     sync.Mutex
+	cached *Credentials
+}
+
+func (c *cachedChain) Retrieve(ctx context.Context) (Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cached != nil && (c.cached.Expires.IsZero() || time.Until(c.cached.Expires) > refreshWindow) {
+		return *c.cached, nil
+	}
+
+	var errs []error
+	for _, link := range c.links {
+		creds, err := link(ctx)
+		if errors.Is(err, errNoCredentials) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		c.cached = &creds
+		return creds, nil
+	}
+	if len(errs) > 0 {
+		return Credentials{}, fmt.Errorf("bedrock: no valid credentials found: %w", errors.Join(errs...))
+	}
+	return Credentials{}, errors.New("bedrock: no credentials found in environment, shared config, container or instance metadata")
+}
+
+func envCredentials(context.Context) (Credentials, error) {
+	id := os.Getenv("AWS_ACCESS_KEY_ID")
+	secret := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if id == "" || secret == "" {
+		return Credentials{}, errNoCredentials
+	}
+	return Credentials{AccessKeyID: id, SecretAccessKey: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+}
+
+// metadataCredentials is the credential document served by the container and
+// instance metadata endpoints.
+type metadataCredentials struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+func (m metadataCredentials) credentials() (Credentials, error) {
+	if m.AccessKeyID == "" || m.SecretAccessKey == "" {
+		return Credentials{}, errors.New("response has no access keys")
+	}
+	return Credentials{
+		AccessKeyID:     m.AccessKeyID,
+		SecretAccessKey: m.SecretAccessKey,
+		SessionToken:    m.Token,
+		Expires:         m.Expiration,
+	}, nil
+}
+
+func containerCredentials(ctx context.Context, client *http.Client) (Credentials, error) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if rel := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); endpoint == "" && rel != "" {
+		endpoint = "http://169.254.170.2" + rel
+	}
+	if endpoint == "" {
+		return Credentials{}, errNoCredentials
+	}
+
+	header := http.Header{}
+	if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+		header.Set("Authorization", token)
+	}
+
+	var doc metadataCredentials
+	if err := getMetadata(ctx, client, http.MethodGet, endpoint, header, &doc); err != nil {
+		return Credentials{}, fmt.Errorf("container credentials: %w", err)
+	}
+	creds, err := doc.credentials()
+	if err != nil {
+		return Credentials{}, fmt.Errorf("container credentials: %w", err)
+	}
+	return creds, nil
+}
+
+func instanceCredentials(ctx context.Context, client *http.Client) (Credentials, error) {
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return Credentials{}, errNoCredentials
+	}
+	endpoint := strings.TrimSuffix(os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT"), "/")
+	if endpoint == "" {
+		endpoint = "http://169.254.169.254"
+	}
+
+	// IMDSv2: fetch a session token first. Outside EC2 this fails fast and
+	// the chain is exhausted.
+	var token string
+	if err := getMetadata(ctx, client, http.MethodPut, endpoint+"/latest/api/token",
+		http.Header{"X-Aws-Ec2-Metadata-Token-Ttl-Seconds": {"21600"}}, &token); err != nil {
+		return Credentials{}, errNoCredentials
+	}
+	header := http.Header{"X-Aws-Ec2-Metadata-Token": {token}}
+
+	const credsPath = "/latest/meta-data/iam/security-credentials/"
+	var roles string
+	if err := getMetadata(ctx, client, http.MethodGet, endpoint+credsPath, header, &roles); err != nil {
+		return Credentials{}, fmt.Errorf("instance credentials: %w", err)
+	}
+	roleName, _, _ := strings.Cut(strings.TrimSpace(roles), "\n")
+	if roleName == "" {
+		return Credentials{}, errNoCredentials
+	}
+
+	var doc metadataCredentials
+	if err := getMetadata(ctx, client, http.MethodGet, endpoint+credsPath+roleName, header, &doc); err != nil {
+		return Credentials{}, fmt.Errorf("instance credentials: %w", err)
+	}
+	creds, err := doc.credentials()
+	if err != nil {
+		return Credentials{}, fmt.Errorf("instance credentials: %w", err)
+	}
+	return creds, nil
+}
+
+// getMetadata sends a request to a metadata endpoint. A *string dest receives
+// the raw body; anything else is decoded as JSON.
+func getMetadata(ctx context.Context, client *http.Client, method, url string, header http.Header, dest any) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := client.Do(req) //nolint:gosec // URL is a fixed metadata endpoint or set in the environment.
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+	if s, ok := dest.(*string); ok {
+		*s = string(body)
+		return nil
+	}
+	return json.Unmarshal(body, dest)
+}
@@ -0,0 +1,384 @@
+package bedrock
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// processTimeout bounds a profile's credential_process command.
+const processTimeout = time.Minute
+
+// sharedConfig holds the profiles of the shared config file (~/.aws/config)
+// and the shared credentials file (~/.aws/credentials).
+type sharedConfig struct {
+	profiles    map[string]map[string]string // Keys from the credentials file win.
+	ssoSessions map[string]map[string]string
+}
+
+// loadSharedConfig reads the shared config file (AWS_CONFIG_FILE, else
+// ~/.aws/config) and the shared credentials file (AWS_SHARED_CREDENTIALS_FILE,
+// else ~/.aws/credentials). Missing files are empty.
+func loadSharedConfig() (sharedConfig, error) {
+	cfg := sharedConfig{profiles: map[string]map[string]string{}, ssoSessions: map[string]map[string]string{}}
+
+	config, err := readINIFile(awsPath("AWS_CONFIG_FILE", "config"))
+	if err != nil {
+		return cfg, err
+	}
+	for section, values := range config {
+		switch {
+		case section == "default":
+			cfg.profiles["default"] = values
+		case strings.HasPrefix(section, "profile "):
+			cfg.profiles[strings.TrimSpace(strings.TrimPrefix(section, "profile "))] = values
+		case strings.HasPrefix(section, "sso-session "):
+			cfg.ssoSessions[strings.TrimSpace(strings.TrimPrefix(section, "sso-session "))] = values
+		}
+	}
+
+	creds, err := readINIFile(awsPath("AWS_SHARED_CREDENTIALS_FILE", "credentials"))
+	if err != nil {
+		return cfg, err
+	}
+	for name, values := range creds {
+		if cfg.profiles[name] == nil {
+			cfg.profiles[name] = map[string]string{}
+		}
+		for k, v := range values {
+			cfg.profiles[name][k] = v
+		}
+	}
+	return cfg, nil
+}
+
+// awsPath returns the path in the environment variable env, else ~/.aws/name.
+func awsPath(env, name string) string {
+	if path := os.Getenv(env); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aws", name)
+}
+
+// readINIFile parses the INI file at path, returning nil if it does not exist.
+func readINIFile(path string) (map[string]map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path) //nolint:gosec // path comes from the user's environment.
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("shared config: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	sections, err := parseINI(f)
+	if err != nil {
+		return nil, fmt.Errorf("shared config %s: %w", path, err)
+	}
+	return sections, nil
+}
+
+// parseINI returns the key/value pairs of each section. Indented lines below
+// a key with no value (nested service settings such as "s3 =") are skipped.
+func parseINI(r io.Reader) (map[string]map[string]string, error) {
+	sections := map[string]map[string]string{}
+	var values map[string]string
+	nested := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if sections[name] == nil {
+				sections[name] = map[string]string{}
+			}
+			values, nested = sections[name], false
+			continue
+		}
+		if values == nil || (nested && (raw[0] == ' ' || raw[0] == '\t')) {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok {
+			v = strings.TrimSpace(v)
+			values[strings.ToLower(strings.TrimSpace(k))] = v
+			nested = v == ""
+		}
+	}
+	return sections, scanner.Err()
+}
+
+// profileCredentials resolves the credentials of the named profile, else
+// AWS_PROFILE, else "default". A profile that is not configured is skipped
+// unless it was named explicitly.
+func profileCredentials(ctx context.Context, profile string, metadata, api *http.Client) (Credentials, error) {
+	explicit := true
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	if profile == "" {
+		profile, explicit = "default", false
+	}
+
+	cfg, err := loadSharedConfig()
+	if err != nil {
+		return Credentials{}, err
+	}
+	if _, ok := cfg.profiles[profile]; !ok {
+		if explicit {
+			return Credentials{}, fmt.Errorf("shared config: profile %q not found", profile)
+		}
+		return Credentials{}, errNoCredentials
+	}
+
+	r := &profileResolver{config: cfg, metadata: metadata, api: api}
+	creds, err := r.resolve(ctx, profile, nil)
+	if err != nil && !errors.Is(err, errNoCredentials) {
+		return Credentials{}, fmt.Errorf("shared config: profile %q: %w", profile, err)
+	}
+	return creds, err
+}
+
+// profileResolver resolves profiles of a sharedConfig, following
+// source_profile references.
+type profileResolver struct {
+	config   sharedConfig
+	metadata *http.Client // Container and instance metadata endpoints.
+	api      *http.Client // STS and the SSO portal.
+}
+
+// resolve returns the credentials of the named profile. visited holds the
+// profiles already followed through source_profile.
+//
+// The base credentials come from, in order: the source profile, static keys,
+// credential_source, web_identity_token_file, SSO and credential_process. If
+// the profile sets role_arn, the base credentials then assume that role
+// (web identity assumes it directly).
+func (r *profileResolver) resolve(ctx context.Context, name string, visited []string) (Credentials, error) {
+	if slices.Contains(visited, name) {
+		return Credentials{}, fmt.Errorf("source_profile cycle: %s -> %s", strings.Join(visited, " -> "), name)
+	}
+	visited = append(visited, name)
+
+	p, ok := r.config.profiles[name]
+	if !ok {
+		return Credentials{}, fmt.Errorf("source_profile %q not found", name)
+	}
+	if p["role_arn"] != "" && p["mfa_serial"] != "" {
+		return Credentials{}, fmt.Errorf("profile %q: mfa_serial is not supported; use temporary credentials from \"aws sts get-session-token\" instead", name)
+	}
+
+	var creds Credentials
+	var err error
+	switch src := p["source_profile"]; {
+	case src != "" && src != name:
+		creds, err = r.resolve(ctx, src, visited)
+	case p["aws_access_key_id"] != "" && p["aws_secret_access_key"] != "":
+		creds = Credentials{
+			AccessKeyID:     p["aws_access_key_id"],
+			SecretAccessKey: p["aws_secret_access_key"],
+			SessionToken:    p["aws_session_token"],
+		}
+	case src == name:
+		return Credentials{}, fmt.Errorf("profile %q is its own source_profile but has no access keys", name)
+	case p["credential_source"] != "":
+		creds, err = r.credentialSource(ctx, p["credential_source"])
+	case p["web_identity_token_file"] != "":
+		return webIdentityCredentials(ctx, r.api, stsRegion(p), p["web_identity_token_file"], p["role_arn"], p["role_session_name"])
+	case p["sso_session"] != "" || p["sso_start_url"] != "":
+		creds, err = r.ssoCredentials(ctx, p)
+	case p["credential_process"] != "":
+		creds, err = processCredentials(ctx, p["credential_process"])
+	case p["role_arn"] != "":
+		return Credentials{}, fmt.Errorf("profile %q: role_arn needs source_profile, credential_source or web_identity_token_file", name)
+	case len(visited) > 1:
+		return Credentials{}, fmt.Errorf("source_profile %q has no credentials", name)
+	default:
+		// A profile with only settings such as region leaves credentials
+		// to the container and instance metadata links.
+		return Credentials{}, errNoCredentials
+	}
+	if err != nil || p["role_arn"] == "" {
+		return creds, err
+	}
+	return assumeRole(ctx, r.api, creds, p)
+}
+
+// credentialSource returns the base credentials named by a profile's
+// credential_source.
+func (r *profileResolver) credentialSource(ctx context.Context, source string) (Credentials, error) {
+	var creds Credentials
+	var err error
+	switch source {
+	case "Environment":
+		creds, err = envCredentials(ctx)
+	case "EcsContainer":
+		creds, err = containerCredentials(ctx, r.metadata)
+	case "Ec2InstanceMetadata":
+		creds, err = instanceCredentials(ctx, r.metadata)
+	default:
+		return Credentials{}, fmt.Errorf("unknown credential_source %q", source)
+	}
+	if errors.Is(err, errNoCredentials) {
+		return Credentials{}, fmt.Errorf("credential_source %s: no credentials", source)
+	}
+	return creds, err
+}
+
+// ssoToken is a cached token in ~/.aws/sso/cache, written by "aws sso login".
+type ssoToken struct {
+	AccessToken string    `json:"accessToken"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// ssoCredentials exchanges the cached SSO token of a profile (legacy
+// sso_start_url or sso_session) for role credentials. Tokens are not
+// refreshed; an expired token asks the user to log in again.
+func (r *profileResolver) ssoCredentials(ctx context.Context, p map[string]string) (Credentials, error) {
+	startURL, region, cacheKey := p["sso_start_url"], p["sso_region"], p["sso_start_url"]
+	if name := p["sso_session"]; name != "" {
+		s, ok := r.config.ssoSessions[name]
+		if !ok {
+			return Credentials{}, fmt.Errorf("sso: sso-session %q not found", name)
+		}
+		startURL, region, cacheKey = s["sso_start_url"], s["sso_region"], name
+	}
+	if startURL == "" || region == "" || p["sso_account_id"] == "" || p["sso_role_name"] == "" {
+		return Credentials{}, errors.New("sso: sso_start_url, sso_region, sso_account_id and sso_role_name are required")
+	}
+
+	token, err := readSSOToken(cacheKey)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("sso: %w; run \"aws sso login\"", err)
+	}
+	if !token.ExpiresAt.IsZero() && time.Now().After(token.ExpiresAt) {
+		return Credentials{}, fmt.Errorf("sso: token for %s expired at %s; run \"aws sso login\"", startURL, token.ExpiresAt.Format(time.RFC3339))
+	}
+
+	endpoint := strings.TrimSuffix(os.Getenv("AWS_ENDPOINT_URL_SSO"), "/")
+	if endpoint == "" {
+		endpoint = "https://portal.sso." + region + ".amazonaws.com"
+	}
+	query := "account_id=" + uriEscape(p["sso_account_id"]) + "&role_name=" + uriEscape(p["sso_role_name"])
+
+	var doc struct {
+		RoleCredentials struct {
+			AccessKeyID     string `json:"accessKeyId"`
+			SecretAccessKey string `json:"secretAccessKey"`
+			SessionToken    string `json:"sessionToken"`
+			Expiration      int64  `json:"expiration"` // Unix milliseconds.
+		} `json:"roleCredentials"`
+	}
+	header := http.Header{"x-amz-sso_bearer_token": {token.AccessToken}}
+	if err := getMetadata(ctx, r.api, http.MethodGet, endpoint+"/federation/credentials?"+query, header, &doc); err != nil {
+		return Credentials{}, fmt.Errorf("sso: get role credentials: %w", err)
+	}
+	rc := doc.RoleCredentials
+	if rc.AccessKeyID == "" || rc.SecretAccessKey == "" {
+		return Credentials{}, errors.New("sso: response has no access keys")
+	}
+	return Credentials{
+		AccessKeyID:     rc.AccessKeyID,
+		SecretAccessKey: rc.SecretAccessKey,
+		SessionToken:    rc.SessionToken,
+		Expires:         time.UnixMilli(rc.Expiration),
+	}, nil
+}
+
+// readSSOToken reads the cached SSO token for key (the sso-session name, or
+// the start URL of a legacy profile).
+func readSSOToken(key string) (ssoToken, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ssoToken{}, err
+	}
+	// The AWS CLI names cache files by the SHA-1 of the key.
+	sum := sha1.Sum([]byte(key)) //nolint:gosec // not used for security.
+	path := filepath.Join(home, ".aws", "sso", "cache", hex.EncodeToString(sum[:])+".json")
+
+	data, err := os.ReadFile(path) //nolint:gosec // path is derived from the user's config.
+	if errors.Is(err, os.ErrNotExist) {
+		return ssoToken{}, fmt.Errorf("no cached token for %s", key)
+	}
+	if err != nil {
+		return ssoToken{}, err
+	}
+	var token ssoToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return ssoToken{}, fmt.Errorf("cached token for %s: %w", key, err)
+	}
+	if token.AccessToken == "" {
+		return ssoToken{}, fmt.Errorf("cached token for %s is empty", key)
+	}
+	return token, nil
+}
+
+// processCredentials runs a profile's credential_process with the system
+// shell and parses the credentials it prints.
+func processCredentials(ctx context.Context, command string) (Credentials, error) {
+	runCtx, cancel := context.WithTimeout(ctx, processTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(runCtx, "cmd", "/C", command) //nolint:gosec // command comes from the user's AWS config
+	} else {
+		cmd = exec.CommandContext(runCtx, "sh", "-c", command) //nolint:gosec // command comes from the user's AWS config
+	}
+	cmd.WaitDelay = time.Second
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return Credentials{}, fmt.Errorf("credential_process: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var doc struct {
+		Version         int       `json:"Version"`
+		AccessKeyID     string    `json:"AccessKeyId"`
+		SecretAccessKey string    `json:"SecretAccessKey"`
+		SessionToken    string    `json:"SessionToken"`
+		Expiration      time.Time `json:"Expiration"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		return Credentials{}, fmt.Errorf("credential_process: parse output: %w", err)
+	}
+	if doc.Version != 1 {
+		return Credentials{}, fmt.Errorf("credential_process: unsupported Version %d", doc.Version)
+	}
+	if doc.AccessKeyID == "" || doc.SecretAccessKey == "" {
+		return Credentials{}, errors.New("credential_process: output has no access keys")
+	}
+	return Credentials{
+		AccessKeyID:     doc.AccessKeyID,
+		SecretAccessKey: doc.SecretAccessKey,
+		SessionToken:    doc.SessionToken,
+		Expires:         doc.Expiration,
+	}, nil
+}
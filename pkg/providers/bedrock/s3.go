@@ -0,0 +1,103 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/germanamz/shelly/pkg/modeladapter"
+)
+
+// s3Location is a parsed s3://bucket/prefix URI.
+type s3Location struct {
+	Bucket string
+	Prefix string // Without leading or trailing slashes; may be empty.
+}
+
+func parseS3URI(uri string) (s3Location, error) {
+	rest, ok := strings.CutPrefix(uri, "s3://")
+	if !ok {
+		return s3Location{}, fmt.Errorf("storage uri %q: must start with s3://", uri)
+	}
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return s3Location{}, fmt.Errorf("storage uri %q: missing bucket", uri)
+	}
+	return s3Location{Bucket: bucket, Prefix: strings.Trim(prefix, "/")}, nil
+}
+
+// key joins the prefix and the given path elements.
+func (l s3Location) key(elems ...string) string {
+	if l.Prefix != "" {
+		elems = append([]string{l.Prefix}, elems...)
+	}
+	return strings.Join(elems, "/")
+}
+
+func (l s3Location) uri(key string) string {
+	return "s3://" + l.Bucket + "/" + key
+}
+
+// s3Client reads and writes objects in a single bucket.
+type s3Client struct {
+	client *modeladapter.Client
+}
+
+// newS3Client addresses the bucket virtual-hosted style, or path style under
+// endpoint when one is given.
+func newS3Client(endpoint, region, bucket string, creds CredentialsProvider) *s3Client {
+	baseURL := "https://" + bucket + ".s3." + region + ".amazonaws.com"
+	if endpoint != "" {
+		baseURL = strings.TrimSuffix(endpoint, "/") + "/" + bucket
+	}
+	return &s3Client{client: modeladapter.NewClient(baseURL, modeladapter.Auth{},
+		modeladapter.WithAuthorizer(NewSigner(creds, region, "s3")))}
+}
+
+func (s *s3Client) put(ctx context.Context, key string, data []byte) error {
+	req, err := s.client.NewRequest(ctx, http.MethodPut, objectPath(key), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("s3 put %s: %w", key, err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("s3 put %s: %w", key, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("s3 put %s: status %d: %s", key, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// get returns the object body; the caller must close it.
+func (s *s3Client) get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.client.NewRequest(ctx, http.MethodGet, objectPath(key), nil)
+	if err != nil {
+		return nil, fmt.Errorf("s3 get %s: %w", key, err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 get %s: %w", key, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("s3 get %s: status %d: %s", key, resp.StatusCode, string(body))
+	}
+	return resp.Body, nil
+}
+
+func objectPath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = uriEscape(seg)
+	}
+	return "/" + strings.Join(segments, "/")
+}
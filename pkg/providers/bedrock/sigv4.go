@@ -0,0 +1,203 @@
+package bedrock
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/modeladapter"
+)
+
+var _ modeladapter.RequestAuthorizer = (*Signer)(nil)
+
+const (
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat  = "20060102T150405Z"
+)
+
+// Signer signs requests with AWS Signature Version 4. It implements
+// modeladapter.RequestAuthorizer.
+type Signer struct {
+	Credentials CredentialsProvider
+	Region      string
+	Service     string // Signing name, e.g. "bedrock" or "s3".
+
+	now func() time.Time
+}
+
+// NewSigner creates a Signer for the given service and region.
+func NewSigner(creds CredentialsProvider, region, service string) *Signer {
+	return &Signer{Credentials: creds, Region: region, Service: service}
+}
+
+// Authorize adds the X-Amz-Date, X-Amz-Security-Token and Authorization
+// headers to req. S3 requests also get X-Amz-Content-Sha256, which S3
+// requires.
+func (s *Signer) Authorize(req *http.Request) error {
+	creds, err := s.Credentials.Retrieve(req.Context())
+	if err != nil {
+		return err
+	}
+
+	payloadHash, err := hashBody(req)
+	if err != nil {
+		return fmt.Errorf("sigv4: hash body: %w", err)
+	}
+
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	t := now().UTC()
+	amzDate := t.Format(amzDateFormat)
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	if s.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	headers, signedHeaders := canonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req),
+		canonicalQuery(req),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/" + s.Service + "/aws4_request"
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, hexSHA256([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+// hashBody returns the hex SHA-256 of the request body, leaving the body
+// readable for sending.
+func hashBody(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return hexSHA256(nil), nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = body.Close() }()
+		h := sha256.New()
+		if _, err := io.Copy(h, body); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", err
+	}
+	_ = req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return hexSHA256(data), nil
+}
+
+// canonicalURI returns the escaped request path. Services other than S3
+// expect each already-escaped segment to be escaped a second time.
+func (s *Signer) canonicalURI(req *http.Request) string {
+	path := req.URL.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	if s.Service == "s3" {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		segments[i] = uriEscape(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	pairs := make([]string, 0, len(query))
+	for k, vs := range query {
+		for _, v := range vs {
+			pairs = append(pairs, uriEscape(k)+"="+uriEscape(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// canonicalHeaders signs the host and every x-amz-* header.
+func canonicalHeaders(req *http.Request) (headers, signed string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") {
+			values[lk] = strings.Join(v, ",")
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for k := range values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, k := range names {
+		b.WriteString(k + ":" + strings.Join(strings.Fields(values[k]), " ") + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+// uriEscape percent-encodes every byte except the RFC 3986 unreserved
+// characters, as SigV4 requires.
+func uriEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
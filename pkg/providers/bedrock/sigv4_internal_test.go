@@ -0,0 +1,65 @@
+package bedrock
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var exampleCreds = StaticCredentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+func fixedTime(t *testing.T) func() time.Time {
+	t.Helper()
+	ts, err := time.Parse(amzDateFormat, "20150830T123600Z")
+	require.NoError(t, err)
+	return func() time.Time { return ts }
+}
+
+// TestSigner_GetVanilla checks the "get-vanilla" case of the AWS SigV4 test
+// suite.
+func TestSigner_GetVanilla(t *testing.T) {
+	s := NewSigner(exampleCreds, "us-east-1", "service")
+	s.now = fixedTime(t)
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Authorize(req))
+
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t,
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+			"SignedHeaders=host;x-amz-date, "+
+			"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+	assert.Empty(t, req.Header.Get("X-Amz-Content-Sha256"))
+}
+
+func TestSigner_S3AndSessionToken(t *testing.T) {
+	creds := exampleCreds
+	creds.SessionToken = "session"
+	s := NewSigner(creds, "eu-west-1", "s3")
+	s.now = fixedTime(t)
+
+	req, err := http.NewRequest(http.MethodPut, "https://bucket.s3.eu-west-1.amazonaws.com/a/b.jsonl", strings.NewReader("{}"))
+	require.NoError(t, err)
+	require.NoError(t, s.Authorize(req))
+
+	assert.Equal(t, hexSHA256([]byte("{}")), req.Header.Get("X-Amz-Content-Sha256"))
+	assert.Equal(t, "session", req.Header.Get("X-Amz-Security-Token"))
+	assert.Contains(t, req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,")
+}
+
+func TestSigner_CanonicalURIDoubleEscapes(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://bedrock-runtime.us-east-1.amazonaws.com/model/"+uriEscape("anthropic.claude-v2:1")+"/converse", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/model/anthropic.claude-v2%253A1/converse", (&Signer{Service: "bedrock"}).canonicalURI(req))
+	assert.Equal(t, "/model/anthropic.claude-v2%3A1/converse", (&Signer{Service: "s3"}).canonicalURI(req))
+}
@@ -0,0 +1,168 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stsVersion is the STS query API version.
+const stsVersion = "2011-06-15"
+
+// stsResponse is an STS AssumeRole or AssumeRoleWithWebIdentity response.
+type stsResponse struct {
+	AssumeRole   stsCredentials `xml:"AssumeRoleResult>Credentials"`
+	WebIdentity  stsCredentials `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	ErrorCode    string         `xml:"Error>Code"`
+	ErrorMessage string         `xml:"Error>Message"`
+}
+
+type stsCredentials struct {
+	AccessKeyID     string    `xml:"AccessKeyId"`
+	SecretAccessKey string    `xml:"SecretAccessKey"`
+	SessionToken    string    `xml:"SessionToken"`
+	Expiration      time.Time `xml:"Expiration"`
+}
+
+// stsRegion returns the region of a profile's STS calls: its region setting,
+// else AWS_REGION, else AWS_DEFAULT_REGION, else us-east-1.
+func stsRegion(p map[string]string) string {
+	if r := ResolveRegion(p["region"]); r != "" {
+		return r
+	}
+	return "us-east-1"
+}
+
+// stsEndpoint returns AWS_ENDPOINT_URL_STS, else the regional STS endpoint.
+func stsEndpoint(region string) string {
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL_STS"); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/")
+	}
+	return "https://sts." + region + ".amazonaws.com"
+}
+
+// roleSessionName returns name, else a name derived from the current time.
+func roleSessionName(name string) string {
+	if name != "" {
+		return name
+	}
+	return "shelly-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+// envWebIdentityCredentials assumes AWS_ROLE_ARN with the token in
+// AWS_WEB_IDENTITY_TOKEN_FILE, as set up by EKS IAM roles for service
+// accounts.
+func envWebIdentityCredentials(ctx context.Context, client *http.Client) (Credentials, error) {
+	tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	if tokenFile == "" {
+		return Credentials{}, errNoCredentials
+	}
+	return webIdentityCredentials(ctx, client, stsRegion(nil), tokenFile, os.Getenv("AWS_ROLE_ARN"), os.Getenv("AWS_ROLE_SESSION_NAME"))
+}
+
+// webIdentityCredentials calls STS AssumeRoleWithWebIdentity, which is not
+// signed. The token file is read on every call because it is rotated.
+func webIdentityCredentials(ctx context.Context, client *http.Client, region, tokenFile, roleARN, sessionName string) (Credentials, error) {
+	if roleARN == "" {
+		return Credentials{}, fmt.Errorf("web identity: no role ARN for token file %s", tokenFile)
+	}
+	token, err := os.ReadFile(tokenFile) //nolint:gosec // path comes from the user's environment.
+	if err != nil {
+		return Credentials{}, fmt.Errorf("web identity: %w", err)
+	}
+
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {stsVersion},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {roleSessionName(sessionName)},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	resp, err := callSTS(ctx, client, region, form, nil)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("web identity: %w", err)
+	}
+	return resp.WebIdentity.credentials()
+}
+
+// assumeRole calls STS AssumeRole for a profile's role_arn, signed with the
+// source credentials.
+func assumeRole(ctx context.Context, client *http.Client, source Credentials, p map[string]string) (Credentials, error) {
+	form := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {stsVersion},
+		"RoleArn":         {p["role_arn"]},
+		"RoleSessionName": {roleSessionName(p["role_session_name"])},
+	}
+	if id := p["external_id"]; id != "" {
+		form.Set("ExternalId", id)
+	}
+	if d := p["duration_seconds"]; d != "" {
+		form.Set("DurationSeconds", d)
+	}
+
+	region := stsRegion(p)
+	resp, err := callSTS(ctx, client, region, form, NewSigner(StaticCredentials(source), region, "sts"))
+	if err != nil {
+		return Credentials{}, fmt.Errorf("assume role %s: %w", p["role_arn"], err)
+	}
+	return resp.AssumeRole.credentials()
+}
+
+// callSTS posts a query API request to STS, signed by signer if it is not
+// nil.
+func callSTS(ctx context.Context, client *http.Client, region string, form url.Values, signer *Signer) (stsResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, stsEndpoint(region)+"/", strings.NewReader(form.Encode()))
+	if err != nil {
+		return stsResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if signer != nil {
+		if err := signer.Authorize(req); err != nil {
+			return stsResponse{}, err
+		}
+	}
+
+	resp, err := client.Do(req) //nolint:gosec // URL is the STS endpoint or set in the environment.
+	if err != nil {
+		return stsResponse{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return stsResponse{}, err
+	}
+	var out stsResponse
+	decodeErr := xml.Unmarshal(body, &out)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if decodeErr == nil && out.ErrorCode != "" {
+			return stsResponse{}, fmt.Errorf("status %d: %s: %s", resp.StatusCode, out.ErrorCode, out.ErrorMessage)
+		}
+		return stsResponse{}, fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+	if decodeErr != nil {
+		return stsResponse{}, fmt.Errorf("decode response: %w", decodeErr)
+	}
+	return out, nil
+}
+
+func (c stsCredentials) credentials() (Credentials, error) {
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return Credentials{}, errors.New("sts: response has no access keys")
+	}
+	return Credentials{
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.SessionToken,
+		Expires:         c.Expiration,
+	}, nil
+}
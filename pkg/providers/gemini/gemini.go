@@ -21,13 +21,24 @@ import (
 var (
 	_ modeladapter.Completer     = (*Adapter)(nil)
 	_ modeladapter.UsageReporter = (*Adapter)(nil)
+	_ modeladapter.TokenCounter  = (*Adapter)(nil)
+
+	_ modeladapter.AudioInputSupporter = (*Adapter)(nil)
 )
 
 // Adapter implements modeladapter.Completer for the Google Gemini API.
 type Adapter struct {
-	client *modeladapter.Client
-	Config modeladapter.ModelConfig
-	usage  usage.Tracker
+	client   *modeladapter.Client
+	Config   modeladapter.ModelConfig
+	platform Platform
+	usage    usage.Tracker
+}
+
+// Platform routes an Adapter through a cloud platform that serves Gemini
+// models with the generateContent wire format, such as Google Vertex AI.
+type Platform struct {
+	// Path replaces /v1beta/models/{model}:generateContent as the request path.
+	Path string
 }
 
 // New creates an Adapter configured for the Gemini API.
@@ -43,9 +54,34 @@ func New(baseURL, apiKey, model string) *Adapter {
 	}
 }
 
+// NewWithPlatform creates an Adapter that sends generateContent requests
+// through a cloud platform. Authentication is left to opts, typically
+// modeladapter.WithAuthorizer.
+func NewWithPlatform(baseURL, model string, p Platform, opts ...modeladapter.ClientOption) *Adapter {
+	return &Adapter{
+		client: modeladapter.NewClient(baseURL, modeladapter.Auth{}, opts...),
+		Config: modeladapter.ModelConfig{
+			Name:      model,
+			MaxTokens: 8192,
+		},
+		platform: p,
+	}
+}
+
 // UsageTracker returns the adapter's token usage tracker.
 func (a *Adapter) UsageTracker() *usage.Tracker { return &a.usage }
 
+// SupportsAudioInput reports whether Gemini accepts inline audio of the given
+// media type. All current Gemini models take audio input.
+func (a *Adapter) SupportsAudioInput(mediaType string) bool {
+	switch mediaType {
+	case "audio/wav", "audio/x-wav", "audio/mpeg", "audio/mp3", "audio/aiff", "audio/aac", "audio/ogg", "audio/flac":
+		return true
+	default:
+		return false
+	}
+}
+
 // ModelMaxTokens returns the maximum tokens the model will generate per response.
 func (a *Adapter) ModelMaxTokens() int { return a.Config.MaxTokens }
 
@@ -53,6 +89,9 @@ func (a *Adapter) ModelMaxTokens() int { return a.Config.MaxTokens }
 func (a *Adapter) Complete(ctx context.Context, c *chat.Chat, tools []toolbox.Tool) (message.Message, error) {
 	req := a.buildRequest(c, tools)
 	path := fmt.Sprintf("/v1beta/models/%s:generateContent", a.Config.Name)
+	if a.platform.Path != "" {
+		path = a.platform.Path
+	}
 
 	var resp apiResponse
 	if err := a.client.PostJSON(ctx, path, req, &resp); err != nil {
@@ -63,15 +102,51 @@ func (a *Adapter) Complete(ctx context.Context, c *chat.Chat, tools []toolbox.To
 		return message.Message{}, fmt.Errorf("gemini: empty candidates in response")
 	}
 
-	a.usage.Add(usage.TokenCount{
-		InputTokens:          resp.UsageMetadata.PromptTokenCount,
-		OutputTokens:         resp.UsageMetadata.CandidatesTokenCount,
-		CacheReadInputTokens: resp.UsageMetadata.CachedContentTokenCount,
-	})
+	a.usage.Add(resp.UsageMetadata.tokenCount())
 
 	return a.parseCandidate(resp.Candidates[0]), nil
 }
 
+// CountTokens returns the input tokens of the request Complete would send for
+// c and tools, using the countTokens endpoint. It is not available when the
+// adapter runs through a Platform.
+func (a *Adapter) CountTokens(ctx context.Context, c *chat.Chat, tools []toolbox.Tool) (int, error) {
+	if a.platform.Path != "" {
+		return 0, fmt.Errorf("gemini: token counting is not available on this platform")
+	}
+
+	body := countRequest{GenerateContentRequest: countContentRequest{
+		Model:      "models/" + a.Config.Name,
+		apiRequest: a.buildRequest(c, tools),
+	}}
+	path := fmt.Sprintf("/v1beta/models/%s:countTokens", a.Config.Name)
+
+	var resp countResponse
+	if err := a.client.PostJSON(ctx, path, body, &resp); err != nil {
+		return 0, fmt.Errorf("gemini: count tokens: %w", err)
+	}
+	return resp.TotalTokens, nil
+}
+
+// MarshalRequest returns the generateContent request body for c and tools. It
+// is used by batch submitters of platforms that take Gemini request bodies.
+func (a *Adapter) MarshalRequest(c *chat.Chat, tools []toolbox.Tool) (json.RawMessage, error) {
+	return json.Marshal(a.buildRequest(c, tools))
+}
+
+// UnmarshalResponse parses a generateContent response body into the assistant
+// reply and its token usage.
+func (a *Adapter) UnmarshalResponse(data []byte) (message.Message, usage.TokenCount, error) {
+	var resp apiResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return message.Message{}, usage.TokenCount{}, fmt.Errorf("gemini: decode response: %w", err)
+	}
+	if len(resp.Candidates) == 0 {
+		return message.Message{}, usage.TokenCount{}, fmt.Errorf("gemini: empty candidates in response")
+	}
+	return a.parseCandidate(resp.Candidates[0]), resp.UsageMetadata.tokenCount(), nil
+}
+
 // --- request types ---
 
 type apiRequest struct {
@@ -81,6 +156,20 @@ type apiRequest struct {
 	GenerationConfig  generationConfig `json:"generationConfig"`
 }
 
+// countRequest wraps a generateContent request for the countTokens endpoint.
+type countRequest struct {
+	GenerateContentRequest countContentRequest `json:"generateContentRequest"`
+}
+
+type countContentRequest struct {
+	Model string `json:"model"`
+	apiRequest
+}
+
+type countResponse struct {
+	TotalTokens int `json:"totalTokens"`
+}
+
 type apiContent struct {
 	Role  string    `json:"role"`
 	Parts []apiPart `json:"parts"`
@@ -143,6 +232,14 @@ type apiUsageMeta struct {
 	CachedContentTokenCount int `json:"cachedContentTokenCount"`
 }
 
+func (u apiUsageMeta) tokenCount() usage.TokenCount {
+	return usage.TokenCount{
+		InputTokens:          u.PromptTokenCount,
+		OutputTokens:         u.CandidatesTokenCount,
+		CacheReadInputTokens: u.CachedContentTokenCount,
+	}
+}
+
 // --- conversion helpers ---
 
 func (a *Adapter) buildRequest(c *chat.Chat, tools []toolbox.Tool) apiRequest {
@@ -273,6 +370,13 @@ func (a *Adapter) partToAPIPart(p content.Part, callNameMap map[string]string) (
 				Data:     base64.StdEncoding.EncodeToString(v.Data),
 			},
 		}, nil
+	case content.Audio:
+		return &apiPart{
+			InlineData: &apiBlob{
+				MimeType: v.MediaType,
+				Data:     base64.StdEncoding.EncodeToString(v.Data),
+			},
+		}, nil
 	case content.ToolResult:
 		name := callNameMap[v.ToolCallID]
 		if name == "" {
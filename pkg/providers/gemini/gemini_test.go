@@ -2,6 +2,7 @@ package gemini_test
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -621,6 +622,38 @@ func TestComplete_DocumentPart(t *testing.T) {
 	assert.Equal(t, "I see a PDF.", msg.TextContent())
 }
 
+func TestComplete_WithAudio(t *testing.T) {
+	_, adapter := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		req := readBody(t, r)
+		contents := req["contents"].([]any)
+		parts := contents[0].(map[string]any)["parts"].([]any)
+		require.Len(t, parts, 2)
+		blob := parts[1].(map[string]any)["inlineData"].(map[string]any)
+		assert.Equal(t, "audio/mpeg", blob["mimeType"])
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("mp3-bytes")), blob["data"])
+
+		writeJSON(t, w, map[string]any{
+			"candidates": []map[string]any{{
+				"content":      map[string]any{"role": "model", "parts": []map[string]any{{"text": "A voice memo."}}},
+				"finishReason": "STOP",
+			}},
+		})
+	})
+
+	c := chat.New(
+		message.New("user", role.User,
+			content.Text{Text: "What is said?"},
+			content.Audio{Data: []byte("mp3-bytes"), MediaType: "audio/mpeg"},
+		),
+	)
+
+	msg, err := adapter.Complete(context.Background(), c, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "A voice memo.", msg.TextContent())
+	assert.True(t, adapter.SupportsAudioInput("audio/wav"))
+	assert.False(t, adapter.SupportsAudioInput("audio/webm"))
+}
+
 func TestComplete_HTTPError(t *testing.T) {
 	_, adapter := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusUnauthorized)
@@ -635,3 +668,28 @@ func TestComplete_HTTPError(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "401")
 }
+
+func TestCountTokens(t *testing.T) {
+	_, adapter := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1beta/models/gemini-test:countTokens", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-goog-api-key"))
+
+		req := readBody(t, r)
+		gcr, ok := req["generateContentRequest"].(map[string]any)
+		require.True(t, ok)
+		assert.Equal(t, "models/gemini-test", gcr["model"])
+		assert.Len(t, gcr["contents"], 1)
+		assert.Contains(t, gcr, "systemInstruction")
+
+		writeJSON(t, w, map[string]any{"totalTokens": 17})
+	})
+
+	c := chat.New(
+		message.NewText("system", role.System, "Be brief."),
+		message.NewText("user", role.User, "Hi"),
+	)
+
+	n, err := adapter.CountTokens(context.Background(), c, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 17, n)
+}
@@ -136,11 +136,25 @@ func ParseMessage(m Message) message.Message {
 	return message.New("", role.Assistant, parts...)
 }
 
-// hasMultiModal returns true if the message contains any Image or Document parts.
+// AudioFormat returns the input_audio format for a media type, or "" when the
+// Chat Completions API does not accept it.
+func AudioFormat(mediaType string) string {
+	switch mediaType {
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return "wav"
+	case "audio/mpeg", "audio/mp3":
+		return "mp3"
+	default:
+		return ""
+	}
+}
+
+// hasMultiModal returns true if the message contains any Image, Document or
+// Audio parts.
 func hasMultiModal(m message.Message) bool {
 	for _, p := range m.Parts {
 		switch p.(type) {
-		case content.Image, content.Document:
+		case content.Image, content.Document, content.Audio:
 			return true
 		}
 	}
@@ -176,6 +190,18 @@ func convertMultiModalMessage(m message.Message) Message {
 				Type: "file",
 				File: &FileData{Filename: filename, FileData: dataURI},
 			})
+		case content.Audio:
+			// Unsupported formats are dropped; modeladapter.TranscribingCompleter
+			// replaces them with transcripts before they get here.
+			if format := AudioFormat(v.MediaType); format != "" {
+				msg.ContentParts = append(msg.ContentParts, ContentPart{
+					Type: "input_audio",
+					InputAudio: &InputAudio{
+						Data:   base64.StdEncoding.EncodeToString(v.Data),
+						Format: format,
+					},
+				})
+			}
 		}
 	}
 	return msg
@@ -119,6 +119,23 @@ func TestConvertMessages_DocumentPart(t *testing.T) {
 	assert.Contains(t, msgs[0].ContentParts[1].File.FileData, "data:application/pdf;base64,")
 }
 
+func TestConvertMessages_AudioPart(t *testing.T) {
+	c := chat.New(
+		message.New("", role.User,
+			content.Text{Text: "Transcribe."},
+			content.Audio{Data: []byte("wav"), MediaType: "audio/wav"},
+			content.Audio{Data: []byte("ogg"), MediaType: "audio/ogg"},
+		),
+	)
+
+	msgs := openaicompat.ConvertMessages(c.Messages())
+
+	assert.Len(t, msgs, 1)
+	assert.Len(t, msgs[0].ContentParts, 2, "unsupported formats are dropped")
+	assert.Equal(t, "input_audio", msgs[0].ContentParts[1].Type)
+	assert.Equal(t, &openaicompat.InputAudio{Data: "d2F2", Format: "wav"}, msgs[0].ContentParts[1].InputAudio)
+}
+
 func TestConvertMessages_DocumentPart_DefaultFilename(t *testing.T) {
 	c := chat.New(
 		message.New("", role.User,
@@ -33,10 +33,11 @@ type Message struct {
 
 // ContentPart is a part within a multi-modal content array.
 type ContentPart struct {
-	Type     string    `json:"type"`
-	Text     string    `json:"text,omitempty"`
-	ImageURL *ImageURL `json:"image_url,omitempty"`
-	File     *FileData `json:"file,omitempty"`
+	Type       string      `json:"type"`
+	Text       string      `json:"text,omitempty"`
+	ImageURL   *ImageURL   `json:"image_url,omitempty"`
+	File       *FileData   `json:"file,omitempty"`
+	InputAudio *InputAudio `json:"input_audio,omitempty"`
 }
 
 // ImageURL holds an image reference for multi-modal messages.
@@ -50,6 +51,13 @@ type FileData struct {
 	FileData string `json:"file_data"`
 }
 
+// InputAudio holds base64 audio for multi-modal messages. Format is "wav" or
+// "mp3".
+type InputAudio struct {
+	Data   string `json:"data"`
+	Format string `json:"format"`
+}
+
 // MarshalJSON implements custom JSON marshaling for Message.
 // When ContentParts is set, the "content" field is serialized as an array;
 // otherwise it is serialized as a string (or omitted if nil).
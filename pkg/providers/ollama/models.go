@@ -0,0 +1,153 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Model capabilities reported by /api/show.
+const (
+	CapabilityCompletion = "completion"
+	CapabilityTools      = "tools"
+	CapabilityVision     = "vision"
+	CapabilityThinking   = "thinking"
+)
+
+// Model is a locally installed model as listed by /api/tags.
+type Model struct {
+	Name              string
+	Size              int64
+	ModifiedAt        time.Time
+	Family            string
+	ParameterSize     string
+	QuantizationLevel string
+}
+
+// ModelInfo is the metadata of a model as reported by /api/show.
+type ModelInfo struct {
+	Name          string
+	ContextLength int      // Trained context length, 0 when unknown.
+	Capabilities  []string // e.g. completion, tools, vision.
+}
+
+// Has reports whether the model declares the capability.
+func (m ModelInfo) Has(capability string) bool {
+	return slices.Contains(m.Capabilities, capability)
+}
+
+type apiTagsResponse struct {
+	Models []struct {
+		Name       string    `json:"name"`
+		Size       int64     `json:"size"`
+		ModifiedAt time.Time `json:"modified_at"`
+		Details    struct {
+			Family            string `json:"family"`
+			ParameterSize     string `json:"parameter_size"`
+			QuantizationLevel string `json:"quantization_level"`
+		} `json:"details"`
+	} `json:"models"`
+}
+
+type apiShowRequest struct {
+	Model string `json:"model"`
+}
+
+type apiShowResponse struct {
+	Capabilities []string                   `json:"capabilities"`
+	ModelInfo    map[string]json.RawMessage `json:"model_info"`
+}
+
+// ListModels returns the models installed on the server, sorted by name.
+func (a *Adapter) ListModels(ctx context.Context) ([]Model, error) {
+	var resp apiTagsResponse
+	if err := a.getJSON(ctx, http.MethodGet, "/api/tags", nil, &resp); err != nil {
+		return nil, fmt.Errorf("ollama: list models: %w", err)
+	}
+
+	models := make([]Model, 0, len(resp.Models))
+	for _, m := range resp.Models {
+		models = append(models, Model{
+			Name:              m.Name,
+			Size:              m.Size,
+			ModifiedAt:        m.ModifiedAt,
+			Family:            m.Details.Family,
+			ParameterSize:     m.Details.ParameterSize,
+			QuantizationLevel: m.Details.QuantizationLevel,
+		})
+	}
+	slices.SortFunc(models, func(x, y Model) int { return strings.Compare(x.Name, y.Name) })
+	return models, nil
+}
+
+// Show returns the metadata of the named model.
+func (a *Adapter) Show(ctx context.Context, model string) (ModelInfo, error) {
+	var resp apiShowResponse
+	if err := a.getJSON(ctx, http.MethodPost, "/api/show", apiShowRequest{Model: model}, &resp); err != nil {
+		return ModelInfo{}, fmt.Errorf("ollama: show %s: %w", model, err)
+	}
+
+	info := ModelInfo{Name: model, Capabilities: resp.Capabilities}
+	// The context length key is prefixed by the architecture, e.g.
+	// "llama.context_length".
+	for k, v := range resp.ModelInfo {
+		if strings.HasSuffix(k, ".context_length") {
+			_ = json.Unmarshal(v, &info.ContextLength)
+			break
+		}
+	}
+	return info, nil
+}
+
+// DiscoverContextWindow returns the context window requests run with. A
+// configured Options.NumCtx wins. Otherwise the model's trained context length
+// is looked up, capped at MaxDiscoveredNumCtx, and sent as num_ctx from then
+// on so that the server loads the model with the window the caller plans for.
+func (a *Adapter) DiscoverContextWindow(ctx context.Context) (int, error) {
+	info, err := a.Show(ctx, a.Config.Name)
+	if err != nil {
+		if a.Options.NumCtx > 0 {
+			return a.Options.NumCtx, nil
+		}
+		return 0, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.info = &info
+	if a.Options.NumCtx > 0 {
+		return a.Options.NumCtx, nil
+	}
+	if info.ContextLength == 0 {
+		return 0, fmt.Errorf("ollama: show %s: context length not reported", a.Config.Name)
+	}
+	a.numCtx = min(info.ContextLength, MaxDiscoveredNumCtx)
+	return a.numCtx, nil
+}
+
+// Info returns the model metadata found by DiscoverContextWindow.
+func (a *Adapter) Info() (ModelInfo, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.info == nil {
+		return ModelInfo{}, false
+	}
+	return *a.info, true
+}
+
+// effectiveNumCtx returns the num_ctx sent with requests (0 = server default).
+func (a *Adapter) effectiveNumCtx() int {
+	if a.Options.NumCtx > 0 {
+		return a.Options.NumCtx
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.numCtx
+}
@@ -0,0 +1,387 @@
+// Package ollama provides a Completer implementation for the native Ollama
+// chat API, including local model discovery.
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/usage"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+)
+
+// DefaultBaseURL is the address of a local Ollama server.
+const DefaultBaseURL = "http://localhost:11434"
+
+// MaxDiscoveredNumCtx caps the context window taken from a model's metadata
+// when num_ctx is not configured. Ollama allocates the KV cache for the full
+// num_ctx, so loading a 128k model at its trained length can exhaust memory.
+const MaxDiscoveredNumCtx = 32768
+
+var (
+	_ modeladapter.Completer               = (*Adapter)(nil)
+	_ modeladapter.UsageReporter           = (*Adapter)(nil)
+	_ modeladapter.ContextWindowDiscoverer = (*Adapter)(nil)
+)
+
+// Options are Ollama-specific request settings.
+type Options struct {
+	// NumCtx is the context window the model is loaded with. Zero uses the
+	// window discovered through DiscoverContextWindow, or the server default.
+	NumCtx int
+	// KeepAlive controls how long the model stays loaded after a request:
+	// a duration ("10m") or a number of seconds, negative for forever.
+	// Empty uses the server default.
+	KeepAlive string
+}
+
+// Adapter implements modeladapter.Completer for the Ollama /api/chat endpoint.
+// Responses are streamed and assembled into a single message.
+type Adapter struct {
+	client  *modeladapter.Client
+	Config  modeladapter.ModelConfig
+	Options Options
+	usage   usage.Tracker
+
+	mu     sync.Mutex
+	info   *ModelInfo // set by DiscoverContextWindow
+	numCtx int        // discovered num_ctx, sent when Options.NumCtx is zero
+}
+
+// New creates an Adapter for the Ollama server at baseURL (no trailing
+// slash). apiKey is optional and sent as a Bearer token for servers behind an
+// authenticating proxy.
+func New(baseURL, apiKey, model string) *Adapter {
+	return &Adapter{
+		client: modeladapter.NewClient(baseURL, modeladapter.Auth{Key: apiKey}),
+		Config: modeladapter.ModelConfig{
+			Name:      model,
+			MaxTokens: 8192,
+		},
+	}
+}
+
+// UsageTracker returns the adapter's token usage tracker.
+func (a *Adapter) UsageTracker() *usage.Tracker { return &a.usage }
+
+// ModelMaxTokens returns the maximum tokens the model will generate per response.
+func (a *Adapter) ModelMaxTokens() int { return a.Config.MaxTokens }
+
+// Complete sends a conversation to /api/chat and returns the assistant's reply.
+func (a *Adapter) Complete(ctx context.Context, c *chat.Chat, tools []toolbox.Tool) (message.Message, error) {
+	req := a.buildRequest(c, tools)
+
+	var (
+		text      strings.Builder
+		calls     []apiToolCall
+		promptTok int
+		outputTok int
+	)
+	err := a.stream(ctx, "/api/chat", req, func(chunk apiChatChunk) error {
+		if chunk.Error != "" {
+			return errors.New(chunk.Error)
+		}
+		text.WriteString(chunk.Message.Content)
+		calls = append(calls, chunk.Message.ToolCalls...)
+		if chunk.Done {
+			promptTok = chunk.PromptEvalCount
+			outputTok = chunk.EvalCount
+		}
+		return nil
+	})
+	if err != nil {
+		return message.Message{}, fmt.Errorf("ollama: %w", err)
+	}
+
+	a.usage.Add(usage.TokenCount{InputTokens: promptTok, OutputTokens: outputTok})
+
+	return parseReply(text.String(), calls), nil
+}
+
+// --- request types ---
+
+type apiChatRequest struct {
+	Model     string          `json:"model"`
+	Messages  []apiMessage    `json:"messages"`
+	Tools     []apiTool       `json:"tools,omitempty"`
+	Stream    bool            `json:"stream"`
+	Options   apiOptions      `json:"options"`
+	KeepAlive json.RawMessage `json:"keep_alive,omitempty"`
+}
+
+type apiMessage struct {
+	Role      string        `json:"role"`
+	Content   string        `json:"content"`
+	Images    []string      `json:"images,omitempty"`
+	ToolCalls []apiToolCall `json:"tool_calls,omitempty"`
+	ToolName  string        `json:"tool_name,omitempty"`
+}
+
+type apiToolCall struct {
+	Function apiFunctionCall `json:"function"`
+}
+
+type apiFunctionCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type apiTool struct {
+	Type     string      `json:"type"`
+	Function apiFunction `json:"function"`
+}
+
+type apiFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+type apiOptions struct {
+	NumCtx      int      `json:"num_ctx,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+// --- response types ---
+
+type apiChatChunk struct {
+	Message         apiMessage `json:"message"`
+	Done            bool       `json:"done"`
+	DoneReason      string     `json:"done_reason"`
+	PromptEvalCount int        `json:"prompt_eval_count"`
+	EvalCount       int        `json:"eval_count"`
+	Error           string     `json:"error"`
+}
+
+// --- conversion helpers ---
+
+func (a *Adapter) buildRequest(c *chat.Chat, tools []toolbox.Tool) apiChatRequest {
+	req := apiChatRequest{
+		Model:  a.Config.Name,
+		Stream: true,
+		Options: apiOptions{
+			NumCtx:     a.effectiveNumCtx(),
+			NumPredict: a.Config.MaxTokens,
+		},
+		KeepAlive: keepAliveJSON(a.Options.KeepAlive),
+	}
+
+	if a.Config.Temperature != 0 {
+		t := a.Config.Temperature
+		req.Options.Temperature = &t
+	}
+
+	for _, t := range tools {
+		schema := t.InputSchema
+		if schema == nil {
+			schema = json.RawMessage(`{"type":"object"}`)
+		}
+		req.Tools = append(req.Tools, apiTool{
+			Type:     "function",
+			Function: apiFunction{Name: t.Name, Description: t.Description, Parameters: schema},
+		})
+	}
+
+	callNames := make(map[string]string)
+	for _, m := range c.Messages() {
+		req.Messages = append(req.Messages, convertMessage(m, callNames)...)
+	}
+
+	return req
+}
+
+// convertMessage maps a chat message to API messages. Tool results expand to
+// one "tool" message each. callNames collects tool call names so that results,
+// which only carry the call ID, can be sent with tool_name.
+func convertMessage(m message.Message, callNames map[string]string) []apiMessage {
+	msg := apiMessage{Role: string(m.Role)}
+	var results []apiMessage
+
+	for _, p := range m.Parts {
+		switch v := p.(type) {
+		case content.Text:
+			msg.Content += v.Text
+		case content.Image:
+			if len(v.Data) > 0 {
+				msg.Images = append(msg.Images, base64.StdEncoding.EncodeToString(v.Data))
+			}
+		case content.ToolCall:
+			callNames[v.ID] = v.Name
+			args := json.RawMessage(v.Arguments)
+			if !json.Valid(args) {
+				args = json.RawMessage(`{}`)
+			}
+			msg.ToolCalls = append(msg.ToolCalls, apiToolCall{Function: apiFunctionCall{Name: v.Name, Arguments: args}})
+		case content.ToolResult:
+			results = append(results, apiMessage{Role: "tool", Content: v.Content, ToolName: callNames[v.ToolCallID]})
+		}
+	}
+
+	if len(results) > 0 && msg.Content == "" && len(msg.Images) == 0 && len(msg.ToolCalls) == 0 {
+		return results
+	}
+	return append([]apiMessage{msg}, results...)
+}
+
+// keepAliveJSON encodes keep_alive: numbers as seconds, anything else as a
+// duration string.
+func keepAliveJSON(v string) json.RawMessage {
+	if v == "" {
+		return nil
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return json.RawMessage(v)
+	}
+	b, _ := json.Marshal(v)
+	return b
+}
+
+// ValidateKeepAlive reports whether v is a keep_alive value Ollama accepts.
+func ValidateKeepAlive(v string) error {
+	if v == "" {
+		return nil
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return nil
+	}
+	if _, err := time.ParseDuration(v); err != nil {
+		return fmt.Errorf("keep_alive must be a duration or a number of seconds, got %q", v)
+	}
+	return nil
+}
+
+// generateCallID creates a unique tool call ID. Ollama does not return call
+// IDs, so we synthesize them.
+func generateCallID(name string) string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("call_%s_%s", name, hex.EncodeToString(b))
+}
+
+func parseReply(text string, calls []apiToolCall) message.Message {
+	var parts []content.Part
+	if text != "" {
+		parts = append(parts, content.Text{Text: text})
+	}
+	for _, tc := range calls {
+		args := string(tc.Function.Arguments)
+		if args == "" || args == "null" {
+			args = "{}"
+		}
+		parts = append(parts, content.ToolCall{
+			ID:        generateCallID(tc.Function.Name),
+			Name:      tc.Function.Name,
+			Arguments: args,
+		})
+	}
+	return message.New("", role.Assistant, parts...)
+}
+
+// --- HTTP helpers ---
+
+// stream POSTs payload and calls fn for every NDJSON chunk of the response.
+func (a *Adapter) stream(ctx context.Context, path string, payload any, fn func(apiChatChunk) error) error {
+	resp, err := a.do(ctx, http.MethodPost, path, payload)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	done := false
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk apiChatChunk
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return fmt.Errorf("decode stream: %w", err)
+		}
+		if err := fn(chunk); err != nil {
+			return err
+		}
+		done = done || chunk.Done
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read stream: %w", err)
+	}
+	if !done {
+		return errors.New("stream ended before done")
+	}
+	return nil
+}
+
+// getJSON sends a GET (payload nil) or POST request and decodes the response.
+func (a *Adapter) getJSON(ctx context.Context, method, path string, payload, dest any) error {
+	resp, err := a.do(ctx, method, path, payload)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if err := json.NewDecoder(resp.Body).Decode(dest); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// do sends a request and checks for a 2xx status.
+func (a *Adapter) do(ctx context.Context, method, path string, payload any) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("marshal payload: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := a.client.NewRequest(ctx, method, path, body)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		_ = resp.Body.Close()
+		return nil, &modeladapter.RateLimitError{
+			RetryAfter: modeladapter.ParseRetryAfter(resp.Header.Get("Retry-After")),
+			Body:       string(respBody),
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return resp, nil
+}
@@ -0,0 +1,313 @@
+package ollama_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/providers/ollama"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, handler http.HandlerFunc) *ollama.Adapter {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	return ollama.New(srv.URL, "", "llama3.2")
+}
+
+func readBody(t *testing.T, r *http.Request) map[string]any {
+	t.Helper()
+
+	body, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+
+	var req map[string]any
+	require.NoError(t, json.Unmarshal(body, &req))
+	return req
+}
+
+// writeChunks writes an NDJSON stream.
+func writeChunks(t *testing.T, w http.ResponseWriter, chunks ...map[string]any) {
+	t.Helper()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	for _, c := range chunks {
+		require.NoError(t, enc.Encode(c))
+	}
+}
+
+func TestComplete_StreamedText(t *testing.T) {
+	adapter := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+
+		req := readBody(t, r)
+		assert.Equal(t, "llama3.2", req["model"])
+		assert.Equal(t, true, req["stream"])
+		_, hasKeepAlive := req["keep_alive"]
+		assert.False(t, hasKeepAlive)
+
+		msgs, _ := req["messages"].([]any)
+		require.Len(t, msgs, 2)
+		first, _ := msgs[0].(map[string]any)
+		assert.Equal(t, "system", first["role"])
+
+		writeChunks(t, w,
+			map[string]any{"message": map[string]any{"role": "assistant", "content": "Hello"}, "done": false},
+			map[string]any{"message": map[string]any{"role": "assistant", "content": " there"}, "done": false},
+			map[string]any{"message": map[string]any{"role": "assistant", "content": ""}, "done": true, "prompt_eval_count": 12, "eval_count": 3},
+		)
+	})
+
+	c := chat.New(
+		message.NewText("", role.System, "You are helpful."),
+		message.NewText("", role.User, "Hi"),
+	)
+	msg, err := adapter.Complete(context.Background(), c, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, role.Assistant, msg.Role)
+	assert.Equal(t, "Hello there", msg.TextContent())
+
+	total := adapter.UsageTracker().Total()
+	assert.Equal(t, 12, total.InputTokens)
+	assert.Equal(t, 3, total.OutputTokens)
+}
+
+func TestComplete_ToolCallRoundTrip(t *testing.T) {
+	var req map[string]any
+	adapter := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		req = readBody(t, r)
+		writeChunks(t, w,
+			map[string]any{
+				"message": map[string]any{
+					"role": "assistant",
+					"tool_calls": []map[string]any{
+						{"function": map[string]any{"name": "read_file", "arguments": map[string]any{"path": "b.go"}}},
+					},
+				},
+				"done": false,
+			},
+			map[string]any{"message": map[string]any{"role": "assistant"}, "done": true},
+		)
+	})
+
+	c := chat.New(
+		message.NewText("", role.User, "Read a.go"),
+		message.New("", role.Assistant, content.ToolCall{ID: "call_1", Name: "read_file", Arguments: `{"path":"a.go"}`}),
+		message.New("", role.Tool, content.ToolResult{ToolCallID: "call_1", Content: "package a"}),
+	)
+	tools := []toolbox.Tool{{
+		Name:        "read_file",
+		Description: "Reads a file",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{"path":{"type":"string"}}}`),
+	}}
+
+	msg, err := adapter.Complete(context.Background(), c, tools)
+	require.NoError(t, err)
+
+	calls := msg.ToolCalls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, "read_file", calls[0].Name)
+	assert.JSONEq(t, `{"path":"b.go"}`, calls[0].Arguments)
+	assert.NotEmpty(t, calls[0].ID)
+
+	reqTools, _ := req["tools"].([]any)
+	require.Len(t, reqTools, 1)
+	tool, _ := reqTools[0].(map[string]any)
+	assert.Equal(t, "function", tool["type"])
+
+	msgs, _ := req["messages"].([]any)
+	require.Len(t, msgs, 3)
+	assistant, _ := msgs[1].(map[string]any)
+	toolCalls, _ := assistant["tool_calls"].([]any)
+	require.Len(t, toolCalls, 1)
+	result, _ := msgs[2].(map[string]any)
+	assert.Equal(t, "tool", result["role"])
+	assert.Equal(t, "read_file", result["tool_name"])
+	assert.Equal(t, "package a", result["content"])
+}
+
+func TestComplete_ImagesAndOptions(t *testing.T) {
+	var req map[string]any
+	adapter := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		req = readBody(t, r)
+		writeChunks(t, w, map[string]any{"message": map[string]any{"content": "a cat"}, "done": true})
+	})
+	adapter.Options = ollama.Options{NumCtx: 8192, KeepAlive: "-1"}
+	adapter.Config.Temperature = 0.2
+
+	c := chat.New(message.New("", role.User,
+		content.Text{Text: "What is this?"},
+		content.Image{Data: []byte("png"), MediaType: "image/png"},
+	))
+	_, err := adapter.Complete(context.Background(), c, nil)
+	require.NoError(t, err)
+
+	assert.InDelta(t, -1, req["keep_alive"], 0)
+	opts, _ := req["options"].(map[string]any)
+	assert.InDelta(t, 8192, opts["num_ctx"], 0)
+	assert.InDelta(t, 8192, opts["num_predict"], 0)
+	assert.InDelta(t, 0.2, opts["temperature"], 1e-9)
+
+	msgs, _ := req["messages"].([]any)
+	user, _ := msgs[0].(map[string]any)
+	assert.Equal(t, []any{"cG5n"}, user["images"])
+}
+
+func TestComplete_KeepAliveDuration(t *testing.T) {
+	var req map[string]any
+	adapter := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		req = readBody(t, r)
+		writeChunks(t, w, map[string]any{"message": map[string]any{"content": "ok"}, "done": true})
+	})
+	adapter.Options.KeepAlive = "10m"
+
+	_, err := adapter.Complete(context.Background(), chat.New(message.NewText("", role.User, "hi")), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "10m", req["keep_alive"])
+}
+
+func TestComplete_Errors(t *testing.T) {
+	t.Run("error chunk", func(t *testing.T) {
+		adapter := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+			writeChunks(t, w, map[string]any{"error": "model not found"})
+		})
+		_, err := adapter.Complete(context.Background(), chat.New(message.NewText("", role.User, "hi")), nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "model not found")
+	})
+
+	t.Run("truncated stream", func(t *testing.T) {
+		adapter := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+			writeChunks(t, w, map[string]any{"message": map[string]any{"content": "par"}, "done": false})
+		})
+		_, err := adapter.Complete(context.Background(), chat.New(message.NewText("", role.User, "hi")), nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "stream ended before done")
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		adapter := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+		_, err := adapter.Complete(context.Background(), chat.New(message.NewText("", role.User, "hi")), nil)
+
+		var rle *modeladapter.RateLimitError
+		require.ErrorAs(t, err, &rle)
+	})
+}
+
+func TestListModels(t *testing.T) {
+	adapter := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/api/tags", r.URL.Path)
+		_, _ = io.WriteString(w, `{"models":[
+			{"name":"qwen2.5:7b","size":4700000000,"details":{"family":"qwen2","parameter_size":"7.6B","quantization_level":"Q4_K_M"}},
+			{"name":"llama3.2:latest","size":2000000000,"details":{"family":"llama"}}
+		]}`)
+	})
+
+	models, err := adapter.ListModels(context.Background())
+	require.NoError(t, err)
+	require.Len(t, models, 2)
+	assert.Equal(t, "llama3.2:latest", models[0].Name)
+	assert.Equal(t, "qwen2.5:7b", models[1].Name)
+	assert.Equal(t, "7.6B", models[1].ParameterSize)
+	assert.Equal(t, "Q4_K_M", models[1].QuantizationLevel)
+}
+
+func showHandler(t *testing.T, contextLength int, chatReq *map[string]any) http.HandlerFunc {
+	t.Helper()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/show":
+			req := readBody(t, r)
+			assert.Equal(t, "llama3.2", req["model"])
+			_, _ = io.WriteString(w, `{"capabilities":["completion","tools"],"model_info":{"general.architecture":"llama","llama.context_length":`+
+				jsonInt(contextLength)+`}}`)
+		case "/api/chat":
+			*chatReq = readBody(t, r)
+			writeChunks(t, w, map[string]any{"message": map[string]any{"content": "ok"}, "done": true})
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}
+}
+
+func jsonInt(n int) string {
+	b, _ := json.Marshal(n)
+	return string(b)
+}
+
+func TestDiscoverContextWindow(t *testing.T) {
+	t.Run("capped and sent as num_ctx", func(t *testing.T) {
+		var chatReq map[string]any
+		adapter := newTestServer(t, showHandler(t, 131072, &chatReq))
+
+		n, err := adapter.DiscoverContextWindow(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, ollama.MaxDiscoveredNumCtx, n)
+
+		info, ok := adapter.Info()
+		require.True(t, ok)
+		assert.Equal(t, 131072, info.ContextLength)
+		assert.True(t, info.Has(ollama.CapabilityTools))
+		assert.False(t, info.Has(ollama.CapabilityVision))
+
+		_, err = adapter.Complete(context.Background(), chat.New(message.NewText("", role.User, "hi")), nil)
+		require.NoError(t, err)
+		opts, _ := chatReq["options"].(map[string]any)
+		assert.InDelta(t, ollama.MaxDiscoveredNumCtx, opts["num_ctx"], 0)
+	})
+
+	t.Run("small model uses trained length", func(t *testing.T) {
+		var chatReq map[string]any
+		adapter := newTestServer(t, showHandler(t, 8192, &chatReq))
+
+		n, err := adapter.DiscoverContextWindow(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 8192, n)
+	})
+
+	t.Run("configured num_ctx wins", func(t *testing.T) {
+		var chatReq map[string]any
+		adapter := newTestServer(t, showHandler(t, 131072, &chatReq))
+		adapter.Options.NumCtx = 65536
+
+		n, err := adapter.DiscoverContextWindow(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 65536, n)
+	})
+
+	t.Run("unknown length", func(t *testing.T) {
+		var chatReq map[string]any
+		adapter := newTestServer(t, showHandler(t, 0, &chatReq))
+
+		_, err := adapter.DiscoverContextWindow(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "context length not reported")
+	})
+}
+
+func TestValidateKeepAlive(t *testing.T) {
+	for _, v := range []string{"", "10m", "1h30m", "0", "-1", "300"} {
+		require.NoError(t, ollama.ValidateKeepAlive(v), v)
+	}
+	require.Error(t, ollama.ValidateKeepAlive("forever"))
+}
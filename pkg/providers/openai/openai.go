@@ -4,6 +4,7 @@ package openai
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/germanamz/shelly/pkg/chats/chat"
 	"github.com/germanamz/shelly/pkg/chats/message"
@@ -17,6 +18,7 @@ var (
 	_ modeladapter.Completer             = (*Adapter)(nil)
 	_ modeladapter.UsageReporter         = (*Adapter)(nil)
 	_ modeladapter.RateLimitInfoReporter = (*Adapter)(nil)
+	_ modeladapter.AudioInputSupporter   = (*Adapter)(nil)
 )
 
 // Adapter implements modeladapter.Completer for the OpenAI Chat Completions API.
@@ -45,6 +47,13 @@ func (a *Adapter) UsageTracker() *usage.Tracker { return &a.usage }
 // ModelMaxTokens returns the maximum tokens the model will generate per response.
 func (a *Adapter) ModelMaxTokens() int { return a.Config.MaxTokens }
 
+// SupportsAudioInput reports whether the model takes input_audio parts of the
+// given media type. Only the audio models (e.g. gpt-4o-audio-preview) accept
+// audio, as WAV or MP3.
+func (a *Adapter) SupportsAudioInput(mediaType string) bool {
+	return strings.Contains(a.Config.Name, "audio") && openaicompat.AudioFormat(mediaType) != ""
+}
+
 // LastRateLimitInfo returns the most recently observed rate limit info, or nil.
 func (a *Adapter) LastRateLimitInfo() *modeladapter.RateLimitInfo {
 	return a.client.LastRateLimitInfo()
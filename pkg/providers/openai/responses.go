@@ -0,0 +1,460 @@
+package openai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/usage"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+)
+
+// ResponsesPath is the Responses API endpoint.
+const ResponsesPath = "/v1/responses"
+
+// Message metadata keys written on assistant replies by ResponsesAdapter.
+const (
+	// ResponseIDMetaKey holds the ID of the response that produced the
+	// message. Follow-up requests pass it as previous_response_id.
+	ResponseIDMetaKey = "openai_response_id"
+	// ResponsePrefixMetaKey holds a fingerprint of the conversation up to and
+	// including the message. A mismatch means the history was rewritten (e.g.
+	// by compaction) and the server-side state no longer matches the chat.
+	ResponsePrefixMetaKey = "openai_response_prefix"
+)
+
+// Reasoning metadata keys on content.Reasoning parts.
+const (
+	reasoningIDKey        = "openai_id"
+	reasoningEncryptedKey = "openai_encrypted_content"
+)
+
+// Built-in tools the Responses API runs server-side.
+var builtinToolDefs = map[string]json.RawMessage{
+	"web_search":       json.RawMessage(`{"type":"web_search"}`),
+	"code_interpreter": json.RawMessage(`{"type":"code_interpreter","container":{"type":"auto"}}`),
+	"image_generation": json.RawMessage(`{"type":"image_generation"}`),
+}
+
+var (
+	reasoningEfforts   = []string{"minimal", "low", "medium", "high"}
+	reasoningSummaries = []string{"auto", "concise", "detailed"}
+)
+
+var (
+	_ modeladapter.Completer             = (*ResponsesAdapter)(nil)
+	_ modeladapter.UsageReporter         = (*ResponsesAdapter)(nil)
+	_ modeladapter.RateLimitInfoReporter = (*ResponsesAdapter)(nil)
+)
+
+// ResponsesOptions are Responses API specific settings.
+type ResponsesOptions struct {
+	// Store keeps responses on the server so follow-up turns can chain with
+	// previous_response_id and send only the new messages. When false every
+	// request carries the full history and reasoning is carried over as
+	// encrypted content instead (zero data retention).
+	Store bool
+	// ReasoningEffort is one of minimal, low, medium, high. Empty uses the
+	// model default.
+	ReasoningEffort string
+	// ReasoningSummary is one of auto, concise, detailed. Empty requests no
+	// reasoning summary.
+	ReasoningSummary string
+	// BuiltinTools enables server-side tools: web_search, code_interpreter,
+	// image_generation.
+	BuiltinTools []string
+}
+
+// Validate checks the options against the values the API accepts.
+func (o ResponsesOptions) Validate() error {
+	if o.ReasoningEffort != "" && !slices.Contains(reasoningEfforts, o.ReasoningEffort) {
+		return fmt.Errorf("reasoning_effort must be one of %s, got %q", strings.Join(reasoningEfforts, ", "), o.ReasoningEffort)
+	}
+	if o.ReasoningSummary != "" && !slices.Contains(reasoningSummaries, o.ReasoningSummary) {
+		return fmt.Errorf("reasoning_summary must be one of %s, got %q", strings.Join(reasoningSummaries, ", "), o.ReasoningSummary)
+	}
+	for _, t := range o.BuiltinTools {
+		if _, ok := builtinToolDefs[t]; !ok {
+			return fmt.Errorf("builtin_tools: unknown tool %q", t)
+		}
+	}
+	return nil
+}
+
+// ResponsesAdapter implements modeladapter.Completer for the OpenAI Responses
+// API. With Options.Store set, the response ID chain is kept in message
+// metadata so that follow-up turns send only the messages added since the
+// last reply. Reasoning output items are mapped to content.Reasoning parts.
+type ResponsesAdapter struct {
+	client  *modeladapter.Client
+	Config  modeladapter.ModelConfig
+	Options ResponsesOptions
+	usage   usage.Tracker
+}
+
+// NewResponses creates a ResponsesAdapter configured for the OpenAI API.
+// The baseURL should be "https://api.openai.com" (no trailing slash).
+func NewResponses(baseURL, apiKey, model string) *ResponsesAdapter {
+	return &ResponsesAdapter{
+		client: modeladapter.NewClient(baseURL, modeladapter.Auth{Key: apiKey},
+			modeladapter.WithHeaderParser(modeladapter.ParseOpenAIRateLimitHeaders)),
+		Config: modeladapter.ModelConfig{
+			Name:      model,
+			MaxTokens: 4096,
+		},
+		Options: ResponsesOptions{Store: true},
+	}
+}
+
+// UsageTracker returns the adapter's token usage tracker.
+func (a *ResponsesAdapter) UsageTracker() *usage.Tracker { return &a.usage }
+
+// ModelMaxTokens returns the maximum tokens the model will generate per response.
+func (a *ResponsesAdapter) ModelMaxTokens() int { return a.Config.MaxTokens }
+
+// LastRateLimitInfo returns the most recently observed rate limit info, or nil.
+func (a *ResponsesAdapter) LastRateLimitInfo() *modeladapter.RateLimitInfo {
+	return a.client.LastRateLimitInfo()
+}
+
+// Complete sends a conversation to the Responses API and returns the
+// assistant's reply. When the chat continues a stored response chain only
+// the new messages are sent; if the server no longer knows the previous
+// response the request is retried with the full history.
+func (a *ResponsesAdapter) Complete(ctx context.Context, c *chat.Chat, tools []toolbox.Tool) (message.Message, error) {
+	msgs := c.Messages()
+
+	prevID, start := a.chainStart(msgs)
+	resp, err := a.send(ctx, c.SystemPrompt(), msgs[start:], prevID, tools)
+	if err != nil && prevID != "" && strings.Contains(err.Error(), "previous_response_not_found") {
+		resp, err = a.send(ctx, c.SystemPrompt(), msgs, "", tools)
+	}
+	if err != nil {
+		return message.Message{}, fmt.Errorf("openai: %w", err)
+	}
+
+	if resp.Status == "failed" {
+		msg := "response failed"
+		if resp.Error != nil {
+			msg = resp.Error.Message
+		}
+		return message.Message{}, fmt.Errorf("openai: %s", msg)
+	}
+
+	a.usage.Add(usage.TokenCount{
+		InputTokens:          resp.Usage.InputTokens,
+		OutputTokens:         resp.Usage.OutputTokens,
+		CacheReadInputTokens: resp.Usage.InputTokensDetails.CachedTokens,
+	})
+
+	reply := parseResponseOutput(resp.Output)
+	if len(reply.Parts) == 0 {
+		return message.Message{}, fmt.Errorf("openai: empty output in response (status %s)", resp.Status)
+	}
+	if a.Options.Store && resp.ID != "" {
+		message.SetMeta(&reply, ResponseIDMetaKey, resp.ID)
+		message.SetMeta(&reply, ResponsePrefixMetaKey, a.fingerprint(append(msgs, reply)))
+	}
+	return reply, nil
+}
+
+func (a *ResponsesAdapter) send(ctx context.Context, instructions string, msgs []message.Message, prevID string, tools []toolbox.Tool) (responsesResponse, error) {
+	req := responsesRequest{
+		Model:              a.Config.Name,
+		Instructions:       instructions,
+		Input:              a.convertInput(msgs),
+		MaxOutputTokens:    a.Config.MaxTokens,
+		Store:              a.Options.Store,
+		PreviousResponseID: prevID,
+	}
+	if req.Input == nil {
+		req.Input = []responsesItem{}
+	}
+
+	if a.Config.Temperature != 0 {
+		t := a.Config.Temperature
+		req.Temperature = &t
+	}
+	if a.Options.ReasoningEffort != "" || a.Options.ReasoningSummary != "" {
+		req.Reasoning = &responsesReasoning{Effort: a.Options.ReasoningEffort, Summary: a.Options.ReasoningSummary}
+	}
+	if !a.Options.Store {
+		req.Include = []string{"reasoning.encrypted_content"}
+	}
+
+	for _, t := range tools {
+		schema := t.InputSchema
+		if schema == nil {
+			schema = json.RawMessage(`{"type":"object"}`)
+		}
+		def, _ := json.Marshal(responsesFunctionTool{Type: "function", Name: t.Name, Description: t.Description, Parameters: schema})
+		req.Tools = append(req.Tools, def)
+	}
+	for _, name := range a.Options.BuiltinTools {
+		if def, ok := builtinToolDefs[name]; ok {
+			req.Tools = append(req.Tools, def)
+		}
+	}
+
+	var resp responsesResponse
+	err := a.client.PostJSON(ctx, ResponsesPath, req, &resp)
+	return resp, err
+}
+
+// chainStart returns the previous_response_id to continue from and the index
+// of the first message to send. It returns ("", 0), i.e. full history, when
+// storage is off, no earlier reply carries a response ID, or the history up
+// to that reply no longer matches what the server saw.
+func (a *ResponsesAdapter) chainStart(msgs []message.Message) (string, int) {
+	if !a.Options.Store {
+		return "", 0
+	}
+
+	for i := len(msgs) - 1; i >= 0; i-- {
+		m := msgs[i]
+		if m.Role != role.Assistant {
+			continue
+		}
+		id, _ := m.GetMeta(ResponseIDMetaKey)
+		idStr, ok := id.(string)
+		if !ok || idStr == "" {
+			continue
+		}
+		prefix, _ := m.GetMeta(ResponsePrefixMetaKey)
+		if prefix != a.fingerprint(msgs[:i+1]) {
+			return "", 0
+		}
+		return idStr, i + 1
+	}
+	return "", 0
+}
+
+// fingerprint hashes the wire form of the conversation, ignoring system
+// messages, which are sent as instructions on every request.
+func (a *ResponsesAdapter) fingerprint(msgs []message.Message) string {
+	b, _ := json.Marshal(a.convertInput(msgs))
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:16])
+}
+
+// --- request types ---
+
+type responsesRequest struct {
+	Model              string              `json:"model"`
+	Instructions       string              `json:"instructions,omitempty"`
+	Input              []responsesItem     `json:"input"`
+	Tools              []json.RawMessage   `json:"tools,omitempty"`
+	MaxOutputTokens    int                 `json:"max_output_tokens,omitempty"`
+	Temperature        *float64            `json:"temperature,omitempty"`
+	Store              bool                `json:"store"`
+	PreviousResponseID string              `json:"previous_response_id,omitempty"`
+	Reasoning          *responsesReasoning `json:"reasoning,omitempty"`
+	Include            []string            `json:"include,omitempty"`
+}
+
+type responsesReasoning struct {
+	Effort  string `json:"effort,omitempty"`
+	Summary string `json:"summary,omitempty"`
+}
+
+type responsesFunctionTool struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// responsesItem is an input or output item. Only the fields of its Type are set.
+type responsesItem struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`
+
+	// message
+	Role    string             `json:"role,omitempty"`
+	Content []responsesContent `json:"content,omitempty"`
+
+	// function_call / function_call_output
+	CallID    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	Output    string `json:"output,omitempty"`
+
+	// reasoning; Summary is always sent for reasoning items.
+	Summary          *[]responsesContent `json:"summary,omitempty"`
+	EncryptedContent string              `json:"encrypted_content,omitempty"`
+}
+
+type responsesContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+	Refusal  string `json:"refusal,omitempty"`
+}
+
+// --- response types ---
+
+type responsesResponse struct {
+	ID     string          `json:"id"`
+	Status string          `json:"status"`
+	Output []responsesItem `json:"output"`
+	Usage  responsesUsage  `json:"usage"`
+	Error  *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type responsesUsage struct {
+	InputTokens        int `json:"input_tokens"`
+	OutputTokens       int `json:"output_tokens"`
+	InputTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"input_tokens_details"`
+}
+
+// --- conversion helpers ---
+
+// convertInput maps chat messages to Responses input items. System messages
+// are skipped; the system prompt is sent as instructions.
+func (a *ResponsesAdapter) convertInput(msgs []message.Message) []responsesItem {
+	var items []responsesItem
+	for _, m := range msgs {
+		switch m.Role {
+		case role.System:
+			continue
+		case role.User:
+			if parts := inputContent(m); len(parts) > 0 {
+				items = append(items, responsesItem{Type: "message", Role: "user", Content: parts})
+			}
+		case role.Assistant:
+			items = append(items, a.assistantItems(m)...)
+		case role.Tool:
+			for _, p := range m.Parts {
+				if tr, ok := p.(content.ToolResult); ok {
+					items = append(items, responsesItem{Type: "function_call_output", CallID: tr.ToolCallID, Output: tr.Content})
+				}
+			}
+		}
+	}
+	return items
+}
+
+func inputContent(m message.Message) []responsesContent {
+	var parts []responsesContent
+	for _, p := range m.Parts {
+		switch v := p.(type) {
+		case content.Text:
+			parts = append(parts, responsesContent{Type: "input_text", Text: v.Text})
+		case content.Image:
+			url := v.URL
+			if len(v.Data) > 0 {
+				url = fmt.Sprintf("data:%s;base64,%s", v.MediaType, base64.StdEncoding.EncodeToString(v.Data))
+			}
+			parts = append(parts, responsesContent{Type: "input_image", ImageURL: url})
+		case content.Document:
+			filename := v.Path
+			if filename == "" {
+				filename = "document"
+			}
+			parts = append(parts, responsesContent{
+				Type:     "input_file",
+				Filename: filename,
+				FileData: fmt.Sprintf("data:%s;base64,%s", v.MediaType, base64.StdEncoding.EncodeToString(v.Data)),
+			})
+		}
+	}
+	return parts
+}
+
+// assistantItems maps an assistant message back to output items, keeping
+// the original order of reasoning, text and tool calls. Reasoning is only
+// replayed when the server can use it: by ID when responses are stored, or
+// as encrypted content otherwise.
+func (a *ResponsesAdapter) assistantItems(m message.Message) []responsesItem {
+	var items []responsesItem
+	for _, p := range m.Parts {
+		switch v := p.(type) {
+		case content.Reasoning:
+			item := responsesItem{Type: "reasoning", Summary: &[]responsesContent{}}
+			if v.Text != "" {
+				item.Summary = &[]responsesContent{{Type: "summary_text", Text: v.Text}}
+			}
+			switch {
+			case v.Metadata[reasoningEncryptedKey] != "":
+				item.EncryptedContent = v.Metadata[reasoningEncryptedKey]
+				if a.Options.Store {
+					item.ID = v.Metadata[reasoningIDKey]
+				}
+			case a.Options.Store && v.Metadata[reasoningIDKey] != "":
+				item.ID = v.Metadata[reasoningIDKey]
+			default:
+				continue
+			}
+			items = append(items, item)
+		case content.Text:
+			items = append(items, responsesItem{
+				Type:    "message",
+				Role:    "assistant",
+				Content: []responsesContent{{Type: "output_text", Text: v.Text}},
+			})
+		case content.ToolCall:
+			items = append(items, responsesItem{Type: "function_call", CallID: v.ID, Name: v.Name, Arguments: v.Arguments})
+		}
+	}
+	return items
+}
+
+// parseResponseOutput converts output items to an assistant message.
+// Server-side tool calls (web search, code interpreter) are not kept; their
+// results are reflected in the output text.
+func parseResponseOutput(output []responsesItem) message.Message {
+	var parts []content.Part
+	for _, item := range output {
+		switch item.Type {
+		case "reasoning":
+			var summary []string
+			if item.Summary != nil {
+				for _, s := range *item.Summary {
+					summary = append(summary, s.Text)
+				}
+			}
+			meta := map[string]string{reasoningIDKey: item.ID}
+			if item.EncryptedContent != "" {
+				meta[reasoningEncryptedKey] = item.EncryptedContent
+			}
+			parts = append(parts, content.Reasoning{Text: strings.Join(summary, "\n\n"), Metadata: meta})
+		case "message":
+			var text strings.Builder
+			for _, c := range item.Content {
+				switch c.Type {
+				case "output_text":
+					text.WriteString(c.Text)
+				case "refusal":
+					text.WriteString(c.Refusal)
+				}
+			}
+			if text.Len() > 0 {
+				parts = append(parts, content.Text{Text: text.String()})
+			}
+		case "function_call":
+			args := item.Arguments
+			if args == "" {
+				args = "{}"
+			}
+			parts = append(parts, content.ToolCall{ID: item.CallID, Name: item.Name, Arguments: args})
+		}
+	}
+	return message.New("", role.Assistant, parts...)
+}
@@ -0,0 +1,276 @@
+package openai_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/providers/openai"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newResponsesServer(t *testing.T, handler http.HandlerFunc) *openai.ResponsesAdapter {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	return openai.NewResponses(srv.URL, "test-key", "o4-mini")
+}
+
+// toolCallResponse is a response with a reasoning item and a function call.
+func toolCallResponse(id string) map[string]any {
+	return map[string]any{
+		"id":     id,
+		"status": "completed",
+		"output": []map[string]any{
+			{
+				"type":              "reasoning",
+				"id":                "rs_1",
+				"summary":           []map[string]any{{"type": "summary_text", "text": "Need the file."}},
+				"encrypted_content": "enc-1",
+			},
+			{"type": "function_call", "id": "fc_1", "call_id": "call_1", "name": "read_file", "arguments": `{"path":"a.go"}`},
+		},
+		"usage": map[string]any{"input_tokens": 50, "output_tokens": 10, "input_tokens_details": map[string]any{"cached_tokens": 20}},
+	}
+}
+
+func textResponse(id, text string) map[string]any {
+	return map[string]any{
+		"id":     id,
+		"status": "completed",
+		"output": []map[string]any{
+			{"type": "message", "role": "assistant", "content": []map[string]any{{"type": "output_text", "text": text}}},
+		},
+		"usage": map[string]any{"input_tokens": 80, "output_tokens": 5},
+	}
+}
+
+func inputTypes(req map[string]any) []string {
+	items, _ := req["input"].([]any)
+	types := make([]string, len(items))
+	for i, it := range items {
+		m, _ := it.(map[string]any)
+		types[i], _ = m["type"].(string)
+	}
+	return types
+}
+
+var readFileTool = []toolbox.Tool{{Name: "read_file", Description: "Reads a file"}}
+
+func TestResponses_ChainsWithPreviousResponseID(t *testing.T) {
+	var reqs []map[string]any
+	adapter := newResponsesServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/responses", r.URL.Path)
+		assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+		reqs = append(reqs, readBody(t, r))
+		if len(reqs) == 1 {
+			writeJSON(t, w, toolCallResponse("resp_1"))
+			return
+		}
+		writeJSON(t, w, textResponse("resp_2", "a.go is empty"))
+	})
+	adapter.Options.ReasoningEffort = "low"
+	adapter.Options.ReasoningSummary = "auto"
+
+	c := chat.New(
+		message.NewText("", role.System, "Be brief."),
+		message.NewText("", role.User, "What is in a.go?"),
+	)
+
+	reply, err := adapter.Complete(context.Background(), c, readFileTool)
+	require.NoError(t, err)
+
+	first := reqs[0]
+	assert.Equal(t, "Be brief.", first["instructions"])
+	assert.Equal(t, true, first["store"])
+	assert.Nil(t, first["previous_response_id"])
+	assert.Nil(t, first["include"])
+	assert.Equal(t, map[string]any{"effort": "low", "summary": "auto"}, first["reasoning"])
+	assert.Equal(t, []string{"message"}, inputTypes(first))
+	tools, _ := first["tools"].([]any)
+	require.Len(t, tools, 1)
+	tool, _ := tools[0].(map[string]any)
+	assert.Equal(t, "read_file", tool["name"])
+
+	require.Len(t, reply.Parts, 2)
+	reasoning, ok := reply.Parts[0].(content.Reasoning)
+	require.True(t, ok)
+	assert.Equal(t, "Need the file.", reasoning.Text)
+	calls := reply.ToolCalls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, "call_1", calls[0].ID)
+	id, _ := reply.GetMeta(openai.ResponseIDMetaKey)
+	assert.Equal(t, "resp_1", id)
+
+	total := adapter.UsageTracker().Total()
+	assert.Equal(t, 50, total.InputTokens)
+	assert.Equal(t, 20, total.CacheReadInputTokens)
+
+	reply.Sender = "bot"
+	c.Append(reply)
+	c.Append(message.New("", role.Tool, content.ToolResult{ToolCallID: "call_1", Content: "package a"}))
+
+	reply, err = adapter.Complete(context.Background(), c, readFileTool)
+	require.NoError(t, err)
+	assert.Equal(t, "a.go is empty", reply.TextContent())
+
+	second := reqs[1]
+	assert.Equal(t, "resp_1", second["previous_response_id"])
+	assert.Equal(t, []string{"function_call_output"}, inputTypes(second), "only the delta is sent")
+}
+
+func TestResponses_FallsBackToFullHistoryAfterRewrite(t *testing.T) {
+	var reqs []map[string]any
+	adapter := newResponsesServer(t, func(w http.ResponseWriter, r *http.Request) {
+		reqs = append(reqs, readBody(t, r))
+		writeJSON(t, w, toolCallResponse("resp_1"))
+	})
+
+	c := chat.New(message.NewText("", role.User, "What is in a.go?"))
+	reply, err := adapter.Complete(context.Background(), c, readFileTool)
+	require.NoError(t, err)
+	c.Append(reply)
+	c.Append(message.New("", role.Tool, content.ToolResult{ToolCallID: "call_1", Content: "package a"}))
+
+	// A compaction-style rewrite of the history before the reply.
+	msgs := c.Messages()
+	msgs[0] = message.NewText("", role.User, "[summary] user asked about a.go")
+	c.Replace(msgs...)
+
+	_, err = adapter.Complete(context.Background(), c, readFileTool)
+	require.NoError(t, err)
+
+	second := reqs[1]
+	assert.Nil(t, second["previous_response_id"])
+	assert.Equal(t, []string{"message", "reasoning", "function_call", "function_call_output"}, inputTypes(second))
+
+	items, _ := second["input"].([]any)
+	rs, _ := items[1].(map[string]any)
+	assert.Equal(t, "rs_1", rs["id"])
+	assert.Equal(t, "enc-1", rs["encrypted_content"])
+}
+
+func TestResponses_RetriesWhenPreviousResponseExpired(t *testing.T) {
+	var reqs []map[string]any
+	adapter := newResponsesServer(t, func(w http.ResponseWriter, r *http.Request) {
+		req := readBody(t, r)
+		reqs = append(reqs, req)
+		switch {
+		case len(reqs) == 1:
+			writeJSON(t, w, toolCallResponse("resp_1"))
+		case req["previous_response_id"] != nil:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"code":"previous_response_not_found","message":"Previous response not found."}}`))
+		default:
+			writeJSON(t, w, textResponse("resp_3", "done"))
+		}
+	})
+
+	c := chat.New(message.NewText("", role.User, "What is in a.go?"))
+	reply, err := adapter.Complete(context.Background(), c, readFileTool)
+	require.NoError(t, err)
+	c.Append(reply)
+	c.Append(message.New("", role.Tool, content.ToolResult{ToolCallID: "call_1", Content: "package a"}))
+
+	reply, err = adapter.Complete(context.Background(), c, readFileTool)
+	require.NoError(t, err)
+	assert.Equal(t, "done", reply.TextContent())
+	require.Len(t, reqs, 3)
+	assert.Len(t, inputTypes(reqs[2]), 4)
+}
+
+func TestResponses_WithoutStore(t *testing.T) {
+	var reqs []map[string]any
+	adapter := newResponsesServer(t, func(w http.ResponseWriter, r *http.Request) {
+		reqs = append(reqs, readBody(t, r))
+		writeJSON(t, w, toolCallResponse("resp_1"))
+	})
+	adapter.Options.Store = false
+
+	c := chat.New(message.NewText("", role.User, "What is in a.go?"))
+	reply, err := adapter.Complete(context.Background(), c, readFileTool)
+	require.NoError(t, err)
+	_, chained := reply.GetMeta(openai.ResponseIDMetaKey)
+	assert.False(t, chained)
+
+	c.Append(reply)
+	c.Append(message.New("", role.Tool, content.ToolResult{ToolCallID: "call_1", Content: "package a"}))
+	_, err = adapter.Complete(context.Background(), c, readFileTool)
+	require.NoError(t, err)
+
+	second := reqs[1]
+	assert.Equal(t, false, second["store"])
+	assert.Equal(t, []any{"reasoning.encrypted_content"}, second["include"])
+	assert.Nil(t, second["previous_response_id"])
+
+	items, _ := second["input"].([]any)
+	rs, _ := items[1].(map[string]any)
+	assert.Nil(t, rs["id"], "reasoning IDs are not resolvable without storage")
+	assert.Equal(t, "enc-1", rs["encrypted_content"])
+}
+
+func TestResponses_BuiltinToolsAndImages(t *testing.T) {
+	var req map[string]any
+	adapter := newResponsesServer(t, func(w http.ResponseWriter, r *http.Request) {
+		req = readBody(t, r)
+		writeJSON(t, w, map[string]any{
+			"id":     "resp_1",
+			"status": "completed",
+			"output": []map[string]any{
+				{"type": "web_search_call", "id": "ws_1", "status": "completed"},
+				{"type": "message", "role": "assistant", "content": []map[string]any{{"type": "output_text", "text": "Sunny."}}},
+			},
+		})
+	})
+	adapter.Options.BuiltinTools = []string{"web_search"}
+
+	c := chat.New(message.New("", role.User,
+		content.Text{Text: "Weather where this was taken?"},
+		content.Image{Data: []byte("png"), MediaType: "image/png"},
+	))
+	reply, err := adapter.Complete(context.Background(), c, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Sunny.", reply.TextContent())
+
+	tools, _ := req["tools"].([]any)
+	require.Len(t, tools, 1)
+	assert.Equal(t, map[string]any{"type": "web_search"}, tools[0])
+
+	items, _ := req["input"].([]any)
+	msg, _ := items[0].(map[string]any)
+	parts, _ := msg["content"].([]any)
+	require.Len(t, parts, 2)
+	img, _ := parts[1].(map[string]any)
+	assert.Equal(t, "input_image", img["type"])
+	assert.Equal(t, "data:image/png;base64,cG5n", img["image_url"])
+}
+
+func TestResponses_FailedStatus(t *testing.T) {
+	adapter := newResponsesServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(t, w, map[string]any{
+			"id":     "resp_1",
+			"status": "failed",
+			"error":  map[string]any{"code": "server_error", "message": "model overloaded"},
+		})
+	})
+
+	_, err := adapter.Complete(context.Background(), chat.New(message.NewText("", role.User, "hi")), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "model overloaded")
+}
+
+func TestResponsesOptions_Validate(t *testing.T) {
+	require.NoError(t, openai.ResponsesOptions{ReasoningEffort: "high", ReasoningSummary: "auto", BuiltinTools: []string{"code_interpreter"}}.Validate())
+	assert.ErrorContains(t, openai.ResponsesOptions{ReasoningEffort: "max"}.Validate(), "reasoning_effort must be one of")
+	assert.ErrorContains(t, openai.ResponsesOptions{ReasoningSummary: "long"}.Validate(), "reasoning_summary must be one of")
+	assert.ErrorContains(t, openai.ResponsesOptions{BuiltinTools: []string{"file_search"}}.Validate(), `unknown tool "file_search"`)
+}
@@ -0,0 +1,159 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+)
+
+var (
+	_ modeladapter.Transcriber = (*Speech)(nil)
+	_ modeladapter.Synthesizer = (*Speech)(nil)
+)
+
+const (
+	transcriptionsPath = "/v1/audio/transcriptions"
+	speechPath         = "/v1/audio/speech"
+
+	// maxSpeechBytes bounds the size of synthesized audio read from a response.
+	maxSpeechBytes = 32 << 20
+)
+
+// Speech implements modeladapter.Transcriber and modeladapter.Synthesizer
+// with the OpenAI audio API.
+type Speech struct {
+	client *modeladapter.Client
+
+	TranscriptionModel string // Default "gpt-4o-mini-transcribe".
+	SpeechModel        string // Default "gpt-4o-mini-tts".
+	Voice              string // Default "alloy".
+}
+
+// NewSpeech creates a Speech client. The baseURL should be
+// "https://api.openai.com" (no trailing slash).
+func NewSpeech(baseURL, apiKey string) *Speech {
+	return &Speech{
+		client:             modeladapter.NewClient(baseURL, modeladapter.Auth{Key: apiKey}),
+		TranscriptionModel: "gpt-4o-mini-transcribe",
+		SpeechModel:        "gpt-4o-mini-tts",
+		Voice:              "alloy",
+	}
+}
+
+// Transcribe sends audio to the transcriptions endpoint and returns its text.
+func (s *Speech) Transcribe(ctx context.Context, audio content.Audio) (string, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if err := w.WriteField("model", s.TranscriptionModel); err != nil {
+		return "", fmt.Errorf("openai: transcribe: %w", err)
+	}
+	// The API detects the format from the file name.
+	fw, err := w.CreateFormFile("file", audioFileName(audio))
+	if err != nil {
+		return "", fmt.Errorf("openai: transcribe: %w", err)
+	}
+	if _, err := fw.Write(audio.Data); err != nil {
+		return "", fmt.Errorf("openai: transcribe: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("openai: transcribe: %w", err)
+	}
+
+	resp, err := s.post(ctx, transcriptionsPath, w.FormDataContentType(), &body)
+	if err != nil {
+		return "", fmt.Errorf("openai: transcribe: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var out struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("openai: transcribe: decode response: %w", err)
+	}
+	return out.Text, nil
+}
+
+// Synthesize turns text into MP3 speech.
+func (s *Speech) Synthesize(ctx context.Context, text string) (content.Audio, error) {
+	payload, err := json.Marshal(map[string]string{
+		"model":           s.SpeechModel,
+		"input":           text,
+		"voice":           s.Voice,
+		"response_format": "mp3",
+	})
+	if err != nil {
+		return content.Audio{}, fmt.Errorf("openai: synthesize: %w", err)
+	}
+
+	resp, err := s.post(ctx, speechPath, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return content.Audio{}, fmt.Errorf("openai: synthesize: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSpeechBytes))
+	if err != nil {
+		return content.Audio{}, fmt.Errorf("openai: synthesize: read response: %w", err)
+	}
+	return content.Audio{Data: data, MediaType: "audio/mpeg"}, nil
+}
+
+// post sends body to path and returns the response after checking for a
+// 2xx status. The caller closes the body.
+func (s *Speech) post(ctx context.Context, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := s.client.NewRequest(ctx, http.MethodPost, path, body)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		defer func() { _ = resp.Body.Close() }()
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &modeladapter.RateLimitError{
+			RetryAfter: modeladapter.ParseRetryAfter(resp.Header.Get("Retry-After")),
+			Body:       string(respBody),
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer func() { _ = resp.Body.Close() }()
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return resp, nil
+}
+
+// audioFileName returns a file name whose extension matches the audio format.
+func audioFileName(a content.Audio) string {
+	if a.Path != "" {
+		return filepath.Base(a.Path)
+	}
+	switch a.MediaType {
+	case "audio/mpeg", "audio/mp3":
+		return "audio.mp3"
+	case "audio/mp4", "audio/x-m4a":
+		return "audio.m4a"
+	case "audio/ogg":
+		return "audio.ogg"
+	case "audio/flac", "audio/x-flac":
+		return "audio.flac"
+	case "audio/webm":
+		return "audio.webm"
+	default:
+		return "audio.wav"
+	}
+}
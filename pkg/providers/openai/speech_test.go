@@ -0,0 +1,91 @@
+package openai_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/providers/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSpeechServer(t *testing.T, handler http.HandlerFunc) *openai.Speech {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	return openai.NewSpeech(srv.URL, "test-key")
+}
+
+func TestSpeech_Transcribe(t *testing.T) {
+	s := newSpeechServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/audio/transcriptions", r.URL.Path)
+		assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, "gpt-4o-mini-transcribe", r.FormValue("model"))
+
+		f, hdr, err := r.FormFile("file")
+		require.NoError(t, err)
+		defer func() { _ = f.Close() }()
+		assert.Equal(t, "audio.mp3", hdr.Filename)
+		data, _ := io.ReadAll(f)
+		assert.Equal(t, "mp3-bytes", string(data))
+
+		writeJSON(t, w, map[string]any{"text": "hello there"})
+	})
+
+	text, err := s.Transcribe(context.Background(), content.Audio{Data: []byte("mp3-bytes"), MediaType: "audio/mpeg"})
+	require.NoError(t, err)
+	assert.Equal(t, "hello there", text)
+}
+
+func TestSpeech_Synthesize(t *testing.T) {
+	s := newSpeechServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/audio/speech", r.URL.Path)
+
+		req := readBody(t, r)
+		assert.Equal(t, "gpt-4o-mini-tts", req["model"])
+		assert.Equal(t, "Hi!", req["input"])
+		assert.Equal(t, "verse", req["voice"])
+		assert.Equal(t, "mp3", req["response_format"])
+
+		w.Header().Set("Content-Type", "audio/mpeg")
+		_, _ = w.Write([]byte("ID3audio"))
+	})
+	s.Voice = "verse"
+
+	audio, err := s.Synthesize(context.Background(), "Hi!")
+	require.NoError(t, err)
+	assert.Equal(t, content.Audio{Data: []byte("ID3audio"), MediaType: "audio/mpeg"}, audio)
+}
+
+func TestSpeech_Errors(t *testing.T) {
+	s := newSpeechServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/audio/speech" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"bad audio"}`))
+	})
+
+	_, err := s.Transcribe(context.Background(), content.Audio{Data: []byte("x"), MediaType: "audio/wav"})
+	require.ErrorContains(t, err, "openai: transcribe: unexpected status 400")
+
+	_, err = s.Synthesize(context.Background(), "Hi")
+	var rle *modeladapter.RateLimitError
+	require.ErrorAs(t, err, &rle)
+}
+
+func TestAdapter_SupportsAudioInput(t *testing.T) {
+	assert.True(t, openai.New("http://unused", "", "gpt-4o-audio-preview").SupportsAudioInput("audio/wav"))
+	assert.False(t, openai.New("http://unused", "", "gpt-4o-audio-preview").SupportsAudioInput("audio/ogg"))
+	assert.False(t, openai.New("http://unused", "", "gpt-4.1").SupportsAudioInput("audio/wav"))
+}
@@ -0,0 +1,369 @@
+package vertex
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/modeladapter"
+)
+
+var _ modeladapter.RequestAuthorizer = (*Authorizer)(nil)
+
+// CloudPlatformScope is the OAuth scope requested for Vertex AI and Cloud
+// Storage.
+const CloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+const defaultTokenURI = "https://oauth2.googleapis.com/token"
+
+// tokenRefreshWindow is how long before expiry a cached token is refreshed.
+const tokenRefreshWindow = time.Minute
+
+// Token is an OAuth access token.
+type Token struct {
+	AccessToken string
+	Expiry      time.Time
+}
+
+// TokenSource returns OAuth access tokens.
+type TokenSource interface {
+	Token(ctx context.Context) (Token, error)
+}
+
+// Credentials are Application Default Credentials: a token source and the
+// project they belong to, if known.
+type Credentials struct {
+	TokenSource TokenSource
+	ProjectID   string
+}
+
+// credentialsFile is the JSON of a service account key or an authorized user
+// file written by "gcloud auth application-default login".
+type credentialsFile struct {
+	Type string `json:"type"`
+
+	// service_account
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+
+	// authorized_user
+	ClientID       string `json:"client_id"`
+	ClientSecret   string `json:"client_secret"`
+	RefreshToken   string `json:"refresh_token"`
+	QuotaProjectID string `json:"quota_project_id"`
+}
+
+// FindDefaultCredentials loads Application Default Credentials from path, else
+// GOOGLE_APPLICATION_CREDENTIALS, else the gcloud well-known file. When no file
+// exists it falls back to the GCE metadata server, whose host can be
+// overridden with GCE_METADATA_HOST. Tokens are cached and refreshed shortly
+// before they expire.
+func FindDefaultCredentials(path string) (Credentials, error) {
+	if path == "" {
+		path = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if path == "" {
+		if wk := wellKnownFile(); wk != "" {
+			if _, err := os.Stat(wk); err == nil {
+				path = wk
+			}
+		}
+	}
+	if path == "" {
+		return Credentials{
+			TokenSource: &cachedTokenSource{src: metadataTokenSource{client: http.DefaultClient}},
+			ProjectID:   os.Getenv("GOOGLE_CLOUD_PROJECT"),
+		}, nil
+	}
+
+	data, err := os.ReadFile(path) //nolint:gosec // path comes from config or the user's environment.
+	if err != nil {
+		return Credentials{}, fmt.Errorf("vertex: credentials: %w", err)
+	}
+	creds, err := CredentialsFromJSON(data)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("vertex: credentials %s: %w", path, err)
+	}
+	return creds, nil
+}
+
+// CredentialsFromJSON parses a service_account or authorized_user credentials
+// file.
+func CredentialsFromJSON(data []byte) (Credentials, error) {
+	var f credentialsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return Credentials{}, err
+	}
+	tokenURI := f.TokenURI
+	if tokenURI == "" {
+		tokenURI = defaultTokenURI
+	}
+
+	switch f.Type {
+	case "service_account":
+		key, err := parsePrivateKey(f.PrivateKey)
+		if err != nil {
+			return Credentials{}, err
+		}
+		if f.ClientEmail == "" {
+			return Credentials{}, errors.New("service account has no client_email")
+		}
+		return Credentials{
+			TokenSource: &cachedTokenSource{src: &serviceAccountSource{
+				email:    f.ClientEmail,
+				keyID:    f.PrivateKeyID,
+				key:      key,
+				tokenURI: tokenURI,
+				client:   http.DefaultClient,
+			}},
+			ProjectID: f.ProjectID,
+		}, nil
+	case "authorized_user":
+		if f.RefreshToken == "" {
+			return Credentials{}, errors.New("authorized user has no refresh_token")
+		}
+		return Credentials{
+			TokenSource: &cachedTokenSource{src: &refreshTokenSource{
+				clientID:     f.ClientID,
+				clientSecret: f.ClientSecret,
+				refreshToken: f.RefreshToken,
+				tokenURI:     tokenURI,
+				client:       http.DefaultClient,
+			}},
+			ProjectID: f.QuotaProjectID,
+		}, nil
+	default:
+		return Credentials{}, fmt.Errorf("unsupported credentials type %q", f.Type)
+	}
+}
+
+func wellKnownFile() string {
+	const name = "application_default_credentials.json"
+	if runtime.GOOS == "windows" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "gcloud", name)
+		}
+		return ""
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gcloud", name)
+}
+
+func parsePrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("private_key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("private_key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private_key is not an RSA key")
+	}
+	return key, nil
+}
+
+// Authorizer sets a bearer token from a TokenSource on every request. It
+// implements modeladapter.RequestAuthorizer.
+type Authorizer struct {
+	Source TokenSource
+}
+
+// Authorize sets the Authorization header.
+func (a *Authorizer) Authorize(req *http.Request) error {
+	tok, err := a.Source.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+	return nil
+}
+
+// cachedTokenSource reuses a token until shortly before it expires.
+type cachedTokenSource struct {
+	src TokenSource
+
+	mu  sync.Mutex
+	tok *Token
+}
+
+func (c *cachedTokenSource) Token(ctx context.Context) (Token, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tok != nil && (c.tok.Expiry.IsZero() || time.Until(c.tok.Expiry) > tokenRefreshWindow) {
+		return *c.tok, nil
+	}
+	tok, err := c.src.Token(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+	c.tok = &tok
+	return tok, nil
+}
+
+// serviceAccountSource exchanges a self-signed RS256 JWT for an access token
+// (RFC 7523 JWT bearer grant).
+type serviceAccountSource struct {
+	email    string
+	keyID    string
+	key      *rsa.PrivateKey
+	tokenURI string
+	client   *http.Client
+}
+
+func (s *serviceAccountSource) Token(ctx context.Context) (Token, error) {
+	assertion, err := s.signJWT(time.Now())
+	if err != nil {
+		return Token{}, fmt.Errorf("vertex: sign jwt: %w", err)
+	}
+	return fetchToken(ctx, s.client, s.tokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+}
+
+func (s *serviceAccountSource) signJWT(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": s.keyID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   s.email,
+		"scope": CloudPlatformScope,
+		"aud":   s.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// refreshTokenSource uses an OAuth refresh token from gcloud user credentials.
+type refreshTokenSource struct {
+	clientID     string
+	clientSecret string
+	refreshToken string
+	tokenURI     string
+	client       *http.Client
+}
+
+func (s *refreshTokenSource) Token(ctx context.Context) (Token, error) {
+	return fetchToken(ctx, s.client, s.tokenURI, url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {s.clientID},
+		"client_secret": {s.clientSecret},
+		"refresh_token": {s.refreshToken},
+	})
+}
+
+// metadataTokenSource reads the default service account token of a GCE, GKE
+// or Cloud Run instance.
+type metadataTokenSource struct {
+	client *http.Client
+}
+
+func (s metadataTokenSource) Token(ctx context.Context) (Token, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	endpoint := "http://" + host + "/computeMetadata/v1/instance/service-accounts/default/token"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return Token{}, fmt.Errorf("vertex: metadata token: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	tok, err := doTokenRequest(s.client, req)
+	if err != nil {
+		return Token{}, fmt.Errorf("vertex: no credentials file found and metadata server unavailable: %w", err)
+	}
+	return tok, nil
+}
+
+func fetchToken(ctx context.Context, client *http.Client, tokenURI string, form url.Values) (Token, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, fmt.Errorf("vertex: token: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	tok, err := doTokenRequest(client, req)
+	if err != nil {
+		return Token{}, fmt.Errorf("vertex: token: %w", err)
+	}
+	return tok, nil
+}
+
+func doTokenRequest(client *http.Client, req *http.Request) (Token, error) {
+	resp, err := client.Do(req) //nolint:gosec // URL is the token URI from the credentials file or the metadata server.
+	if err != nil {
+		return Token{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return Token{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Token{}, fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var r struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return Token{}, fmt.Errorf("decode: %w", err)
+	}
+	if r.AccessToken == "" {
+		return Token{}, errors.New("response has no access_token")
+	}
+
+	tok := Token{AccessToken: r.AccessToken}
+	if r.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
+	}
+	return tok, nil
+}
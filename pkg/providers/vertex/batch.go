@@ -0,0 +1,404 @@
+package vertex
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/modeladapter/batch"
+	"github.com/germanamz/shelly/pkg/modeladapter/usage"
+	"github.com/germanamz/shelly/pkg/tools/toolbox"
+)
+
+// storageURL is the Cloud Storage JSON API endpoint.
+const storageURL = "https://storage.googleapis.com"
+
+// requestLabel is the label that carries the batch request ID through Gemini
+// batch predictions, which echo each request next to its response.
+const requestLabel = "shelly_request_id"
+
+// RequestMapper converts between chats and a publisher's request and response
+// bodies. *gemini.Adapter and *anthropic.Adapter implement it.
+type RequestMapper interface {
+	MarshalRequest(c *chat.Chat, tools []toolbox.Tool) (json.RawMessage, error)
+	UnmarshalResponse(data []byte) (message.Message, usage.TokenCount, error)
+}
+
+// BatchSubmitter implements batch.Submitter with Vertex AI batch prediction
+// jobs. Inputs and outputs are exchanged through Cloud Storage.
+type BatchSubmitter struct {
+	cfg       Config
+	client    *modeladapter.Client
+	storage   *modeladapter.Client
+	bucket    string
+	prefix    string
+	publisher string
+	model     string
+	mapper    RequestMapper
+}
+
+// NewBatchSubmitter creates a BatchSubmitter for model. storageURI is the
+// gs://bucket/prefix under which job inputs and outputs are written. When
+// cfg.BaseURL is set, Cloud Storage is addressed under it too.
+func NewBatchSubmitter(cfg Config, model string, mapper RequestMapper, storageURI string) (*BatchSubmitter, error) {
+	rest, ok := strings.CutPrefix(storageURI, "gs://")
+	if !ok {
+		return nil, fmt.Errorf("vertex batch: storage uri %q: must start with gs://", storageURI)
+	}
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("vertex batch: storage uri %q: missing bucket", storageURI)
+	}
+
+	storage := storageURL
+	if cfg.BaseURL != "" {
+		storage = cfg.baseURL()
+	}
+
+	return &BatchSubmitter{
+		cfg:       cfg,
+		client:    modeladapter.NewClient(cfg.baseURL(), modeladapter.Auth{}, cfg.clientOptions()...),
+		storage:   modeladapter.NewClient(storage, modeladapter.Auth{}, cfg.clientOptions()...),
+		bucket:    bucket,
+		prefix:    strings.Trim(prefix, "/"),
+		publisher: Publisher(model),
+		model:     model,
+		mapper:    mapper,
+	}, nil
+}
+
+// --- API types ---
+
+type batchJob struct {
+	Name         string        `json:"name,omitempty"`
+	DisplayName  string        `json:"displayName,omitempty"`
+	Model        string        `json:"model,omitempty"`
+	State        string        `json:"state,omitempty"`
+	InputConfig  *inputConfig  `json:"inputConfig,omitempty"`
+	OutputConfig *outputConfig `json:"outputConfig,omitempty"`
+	OutputInfo   *outputInfo   `json:"outputInfo,omitempty"`
+	Error        *jobError     `json:"error,omitempty"`
+}
+
+type inputConfig struct {
+	InstancesFormat string    `json:"instancesFormat"`
+	GCSSource       gcsSource `json:"gcsSource"`
+}
+
+type gcsSource struct {
+	URIs []string `json:"uris"`
+}
+
+type outputConfig struct {
+	PredictionsFormat string         `json:"predictionsFormat"`
+	GCSDestination    gcsDestination `json:"gcsDestination"`
+}
+
+type gcsDestination struct {
+	OutputURIPrefix string `json:"outputUriPrefix"`
+}
+
+type outputInfo struct {
+	GCSOutputDirectory string `json:"gcsOutputDirectory"`
+}
+
+type jobError struct {
+	Message string `json:"message"`
+}
+
+type inputLine struct {
+	CustomID string          `json:"custom_id,omitempty"`
+	Request  json.RawMessage `json:"request"`
+}
+
+type outputLine struct {
+	CustomID string          `json:"custom_id"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+	Status   json.RawMessage `json:"status"`
+	Error    json.RawMessage `json:"error"`
+}
+
+type objectList struct {
+	Items []struct {
+		Name string `json:"name"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// SubmitBatch uploads the requests to Cloud Storage and creates a batch
+// prediction job. The returned batch ID is the job resource name.
+func (b *BatchSubmitter) SubmitBatch(ctx context.Context, reqs []batch.Request) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range reqs {
+		line, err := b.inputLine(r)
+		if err != nil {
+			return "", fmt.Errorf("vertex batch: build request %s: %w", r.ID, err)
+		}
+		if err := enc.Encode(line); err != nil {
+			return "", fmt.Errorf("vertex batch: encode request %s: %w", r.ID, err)
+		}
+	}
+
+	jobName := "shelly-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	inputObject := b.objectName(jobName, "input.jsonl")
+	if err := b.upload(ctx, inputObject, buf.Bytes()); err != nil {
+		return "", fmt.Errorf("vertex batch: upload: %w", err)
+	}
+
+	job := batchJob{
+		DisplayName: jobName,
+		Model:       "publishers/" + b.publisher + "/models/" + b.model,
+	}
+	job.InputConfig = &inputConfig{
+		InstancesFormat: "jsonl",
+		GCSSource:       gcsSource{URIs: []string{"gs://" + b.bucket + "/" + inputObject}},
+	}
+	job.OutputConfig = &outputConfig{
+		PredictionsFormat: "jsonl",
+		GCSDestination:    gcsDestination{OutputURIPrefix: "gs://" + b.bucket + "/" + b.objectName(jobName, "output")},
+	}
+
+	var resp batchJob
+	if err := b.client.PostJSON(ctx, b.cfg.locationPath()+"/batchPredictionJobs", job, &resp); err != nil {
+		return "", fmt.Errorf("vertex batch: submit: %w", err)
+	}
+	return resp.Name, nil
+}
+
+// inputLine wraps a request body. Anthropic lines carry a custom_id; Gemini
+// requests carry the ID as a label, which the output echoes.
+func (b *BatchSubmitter) inputLine(r batch.Request) (inputLine, error) {
+	body, err := b.mapper.MarshalRequest(r.Chat, r.Tools)
+	if err != nil {
+		return inputLine{}, err
+	}
+	if b.publisher == PublisherAnthropic {
+		return inputLine{CustomID: r.ID, Request: body}, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return inputLine{}, err
+	}
+	labels, err := json.Marshal(map[string]string{requestLabel: r.ID})
+	if err != nil {
+		return inputLine{}, err
+	}
+	fields["labels"] = labels
+	body, err = json.Marshal(fields)
+	if err != nil {
+		return inputLine{}, err
+	}
+	return inputLine{Request: body}, nil
+}
+
+// PollBatch checks the job state and reads its predictions once it has
+// finished.
+func (b *BatchSubmitter) PollBatch(ctx context.Context, batchID string) (map[string]batch.Result, bool, error) {
+	var job batchJob
+	if err := b.getJSON(ctx, b.client, "/v1/"+batchID, &job); err != nil {
+		return nil, false, fmt.Errorf("vertex batch: poll: %w", err)
+	}
+
+	switch job.State {
+	case "JOB_STATE_SUCCEEDED", "JOB_STATE_PARTIALLY_SUCCEEDED":
+	case "JOB_STATE_FAILED", "JOB_STATE_CANCELLED", "JOB_STATE_EXPIRED":
+		msg := ""
+		if job.Error != nil {
+			msg = job.Error.Message
+		}
+		return nil, false, fmt.Errorf("vertex batch: job %s: %s: %s", batchID, job.State, msg)
+	default:
+		return nil, false, nil
+	}
+
+	if job.OutputInfo == nil || job.OutputInfo.GCSOutputDirectory == "" {
+		return nil, false, fmt.Errorf("vertex batch: job %s: no output directory", batchID)
+	}
+	results, err := b.fetchResults(ctx, job.OutputInfo.GCSOutputDirectory)
+	if err != nil {
+		return nil, false, err
+	}
+	return results, true, nil
+}
+
+// CancelBatch cancels an in-progress job.
+func (b *BatchSubmitter) CancelBatch(ctx context.Context, batchID string) error {
+	return b.client.PostJSON(ctx, "/v1/"+batchID+":cancel", struct{}{}, nil)
+}
+
+// fetchResults reads every JSONL object under the job's output directory.
+func (b *BatchSubmitter) fetchResults(ctx context.Context, outputDir string) (map[string]batch.Result, error) {
+	prefix := strings.TrimPrefix(outputDir, "gs://"+b.bucket+"/")
+	names, err := b.list(ctx, strings.TrimSuffix(prefix, "/")+"/")
+	if err != nil {
+		return nil, fmt.Errorf("vertex batch: results: %w", err)
+	}
+
+	results := make(map[string]batch.Result)
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+		if err := b.readResults(ctx, name, results); err != nil {
+			return nil, fmt.Errorf("vertex batch: results: %w", err)
+		}
+	}
+	return results, nil
+}
+
+func (b *BatchSubmitter) readResults(ctx context.Context, name string, results map[string]batch.Result) error {
+	path := "/storage/v1/b/" + b.bucket + "/o/" + url.PathEscape(name) + "?alt=media"
+	body, err := b.get(ctx, b.storage, path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var out outputLine
+		if err := json.Unmarshal(line, &out); err != nil {
+			return fmt.Errorf("parse result line: %w", err)
+		}
+		id := out.CustomID
+		if id == "" {
+			id = requestID(out.Request)
+		}
+		if id == "" {
+			continue
+		}
+		results[id] = b.convertLine(id, out)
+	}
+	return scanner.Err()
+}
+
+func requestID(req json.RawMessage) string {
+	var r struct {
+		Labels map[string]string `json:"labels"`
+	}
+	_ = json.Unmarshal(req, &r)
+	return r.Labels[requestLabel]
+}
+
+func (b *BatchSubmitter) convertLine(id string, out outputLine) batch.Result {
+	if status := failureText(out.Status, out.Error); status != "" {
+		return batch.Result{Err: fmt.Errorf("vertex batch: request %s: %s", id, status)}
+	}
+	if len(out.Response) == 0 {
+		return batch.Result{Err: fmt.Errorf("vertex batch: request %s: no response", id)}
+	}
+	msg, tc, err := b.mapper.UnmarshalResponse(out.Response)
+	if err != nil {
+		return batch.Result{Err: fmt.Errorf("vertex batch: request %s: %w", id, err)}
+	}
+	return batch.Result{Message: msg, Usage: tc}
+}
+
+// failureText returns the error reported for a line: a non-empty status
+// string or an error object.
+func failureText(status, errObj json.RawMessage) string {
+	var s string
+	if json.Unmarshal(status, &s) == nil && s != "" {
+		return s
+	}
+	if len(errObj) > 0 && string(errObj) != "null" {
+		return string(errObj)
+	}
+	return ""
+}
+
+func (b *BatchSubmitter) objectName(elems ...string) string {
+	if b.prefix != "" {
+		elems = append([]string{b.prefix}, elems...)
+	}
+	return strings.Join(elems, "/")
+}
+
+func (b *BatchSubmitter) upload(ctx context.Context, name string, data []byte) error {
+	path := "/upload/storage/v1/b/" + b.bucket + "/o?uploadType=media&name=" + url.QueryEscape(name)
+	req, err := b.storage.NewRequest(ctx, http.MethodPost, path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/jsonl")
+	resp, err := b.storage.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (b *BatchSubmitter) list(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	pageToken := ""
+	for {
+		path := "/storage/v1/b/" + b.bucket + "/o?prefix=" + url.QueryEscape(prefix)
+		if pageToken != "" {
+			path += "&pageToken=" + url.QueryEscape(pageToken)
+		}
+		var page objectList
+		if err := b.getJSON(ctx, b.storage, path, &page); err != nil {
+			return nil, err
+		}
+		for _, it := range page.Items {
+			names = append(names, it.Name)
+		}
+		if page.NextPageToken == "" {
+			return names, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+func (b *BatchSubmitter) getJSON(ctx context.Context, client *modeladapter.Client, path string, dest any) error {
+	body, err := b.get(ctx, client, path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+	return json.NewDecoder(body).Decode(dest)
+}
+
+// get sends a GET and returns the body of a 2xx response; the caller must
+// close it.
+func (b *BatchSubmitter) get(ctx context.Context, client *modeladapter.Client, path string) (io.ReadCloser, error) {
+	req, err := client.NewRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,125 @@
+// Package vertex runs Gemini and Anthropic models on Google Vertex AI. It
+// reuses the gemini and anthropic adapters, which share Vertex's wire
+// formats, and authenticates them with OAuth tokens from Application Default
+// Credentials.
+package vertex
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/germanamz/shelly/pkg/modeladapter"
+	"github.com/germanamz/shelly/pkg/providers/anthropic"
+	"github.com/germanamz/shelly/pkg/providers/gemini"
+)
+
+// Publishers of models served by Vertex AI.
+const (
+	PublisherGoogle    = "google"
+	PublisherAnthropic = "anthropic"
+)
+
+// anthropicVersion is the anthropic_version Vertex expects in Claude request
+// bodies.
+const anthropicVersion = "vertex-2023-10-16"
+
+// Config locates a Vertex AI project and region.
+type Config struct {
+	BaseURL     string // Defaults to the regional endpoint, see BaseURL.
+	Project     string
+	Region      string
+	TokenSource TokenSource
+}
+
+// NewConfig resolves a Config from Application Default Credentials (see
+// FindDefaultCredentials). An empty project falls back to the credentials'
+// project, then GOOGLE_CLOUD_PROJECT; an empty region to
+// GOOGLE_CLOUD_LOCATION.
+func NewConfig(project, region, credentialsFile string) (Config, error) {
+	creds, err := FindDefaultCredentials(credentialsFile)
+	if err != nil {
+		return Config{}, err
+	}
+	if project == "" {
+		project = creds.ProjectID
+	}
+	if project == "" {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	if region == "" {
+		region = os.Getenv("GOOGLE_CLOUD_LOCATION")
+	}
+
+	cfg := Config{Project: project, Region: region, TokenSource: creds.TokenSource}
+	return cfg, cfg.Validate()
+}
+
+// BaseURL returns the Vertex AI endpoint of a region. The "global" region
+// has no regional prefix.
+func BaseURL(region string) string {
+	if region == "global" {
+		return "https://aiplatform.googleapis.com"
+	}
+	return "https://" + region + "-aiplatform.googleapis.com"
+}
+
+// Validate reports missing settings.
+func (c Config) Validate() error {
+	if c.Project == "" {
+		return fmt.Errorf("vertex: project is required")
+	}
+	if c.Region == "" {
+		return fmt.Errorf("vertex: region is required")
+	}
+	if c.TokenSource == nil {
+		return fmt.Errorf("vertex: token source is required")
+	}
+	return nil
+}
+
+// Publisher returns the publisher serving model: anthropic for Claude models,
+// google otherwise.
+func Publisher(model string) string {
+	if strings.HasPrefix(model, "claude") {
+		return PublisherAnthropic
+	}
+	return PublisherGoogle
+}
+
+// Gemini returns a gemini adapter that calls generateContent on Vertex AI.
+func (c Config) Gemini(model string) *gemini.Adapter {
+	return gemini.NewWithPlatform(c.baseURL(), model,
+		gemini.Platform{Path: c.modelPath(PublisherGoogle, model) + ":generateContent"},
+		c.clientOptions()...)
+}
+
+// Anthropic returns an anthropic adapter that calls rawPredict on Vertex AI.
+func (c Config) Anthropic(model string) *anthropic.Adapter {
+	return anthropic.NewWithPlatform(c.baseURL(), model,
+		anthropic.Platform{
+			Path:             c.modelPath(PublisherAnthropic, model) + ":rawPredict",
+			AnthropicVersion: anthropicVersion,
+		},
+		c.clientOptions()...)
+}
+
+func (c Config) baseURL() string {
+	if c.BaseURL != "" {
+		return strings.TrimSuffix(c.BaseURL, "/")
+	}
+	return BaseURL(c.Region)
+}
+
+// locationPath is the resource path of the project location.
+func (c Config) locationPath() string {
+	return "/v1/projects/" + c.Project + "/locations/" + c.Region
+}
+
+func (c Config) modelPath(publisher, model string) string {
+	return c.locationPath() + "/publishers/" + publisher + "/models/" + model
+}
+
+func (c Config) clientOptions() []modeladapter.ClientOption {
+	return []modeladapter.ClientOption{modeladapter.WithAuthorizer(&Authorizer{Source: c.TokenSource})}
+}
@@ -0,0 +1,294 @@
+package vertex_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/chats/chat"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+	"github.com/germanamz/shelly/pkg/modeladapter/batch"
+	"github.com/germanamz/shelly/pkg/providers/vertex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticToken string
+
+func (s staticToken) Token(context.Context) (vertex.Token, error) {
+	return vertex.Token{AccessToken: string(s)}, nil
+}
+
+func readBody(t *testing.T, r *http.Request) map[string]any {
+	t.Helper()
+	var m map[string]any
+	require.NoError(t, json.NewDecoder(r.Body).Decode(&m))
+	return m
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, v any) {
+	t.Helper()
+	w.Header().Set("Content-Type", "application/json")
+	require.NoError(t, json.NewEncoder(w).Encode(v))
+}
+
+func testConfig(url string) vertex.Config {
+	return vertex.Config{BaseURL: url, Project: "proj", Region: "us-central1", TokenSource: staticToken("tok")}
+}
+
+func TestGemini_GenerateContent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/projects/proj/locations/us-central1/publishers/google/models/gemini-2.5-pro:generateContent", r.URL.Path)
+		assert.Equal(t, "Bearer tok", r.Header.Get("Authorization"))
+		assert.Empty(t, r.Header.Get("x-goog-api-key"))
+		writeJSON(t, w, map[string]any{
+			"candidates":    []map[string]any{{"content": map[string]any{"role": "model", "parts": []map[string]any{{"text": "hi"}}}}},
+			"usageMetadata": map[string]any{"promptTokenCount": 4, "candidatesTokenCount": 1},
+		})
+	}))
+	defer srv.Close()
+
+	adapter := testConfig(srv.URL).Gemini("gemini-2.5-pro")
+	reply, err := adapter.Complete(context.Background(), chat.New(message.NewText("", role.User, "hello")), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "hi", reply.TextContent())
+	assert.Equal(t, 4, adapter.UsageTracker().Total().InputTokens)
+}
+
+func TestAnthropic_RawPredict(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/projects/proj/locations/us-central1/publishers/anthropic/models/claude-sonnet-4@20250514:rawPredict", r.URL.Path)
+		assert.Equal(t, "Bearer tok", r.Header.Get("Authorization"))
+		req := readBody(t, r)
+		assert.Equal(t, "vertex-2023-10-16", req["anthropic_version"])
+		assert.Nil(t, req["model"])
+		writeJSON(t, w, map[string]any{
+			"content": []map[string]any{{"type": "text", "text": "hi"}},
+			"usage":   map[string]any{"input_tokens": 4, "output_tokens": 1},
+		})
+	}))
+	defer srv.Close()
+
+	model := "claude-sonnet-4@20250514"
+	require.Equal(t, vertex.PublisherAnthropic, vertex.Publisher(model))
+	adapter := testConfig(srv.URL).Anthropic(model)
+	reply, err := adapter.Complete(context.Background(), chat.New(message.NewText("", role.User, "hello")), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "hi", reply.TextContent())
+}
+
+func TestBaseURL(t *testing.T) {
+	assert.Equal(t, "https://europe-west4-aiplatform.googleapis.com", vertex.BaseURL("europe-west4"))
+	assert.Equal(t, "https://aiplatform.googleapis.com", vertex.BaseURL("global"))
+}
+
+func TestCredentials_ServiceAccount(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	var exchanges int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exchanges++
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.Form.Get("grant_type"))
+
+		parts := strings.Split(r.Form.Get("assertion"), ".")
+		require.Len(t, parts, 3)
+		sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+		require.NoError(t, err)
+		sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig))
+
+		claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+		require.NoError(t, err)
+		var claims map[string]any
+		require.NoError(t, json.Unmarshal(claimsJSON, &claims))
+		assert.Equal(t, "sa@proj.iam.gserviceaccount.com", claims["iss"])
+		assert.Equal(t, vertex.CloudPlatformScope, claims["scope"])
+		assert.Equal(t, "http://"+r.Host+"/token", claims["aud"])
+
+		writeJSON(t, w, map[string]any{"access_token": "ya29.sa", "expires_in": 3600, "token_type": "Bearer"})
+	}))
+	defer srv.Close()
+
+	keyFile, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "proj-from-key",
+		"private_key_id": "kid",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "sa@proj.iam.gserviceaccount.com",
+		"token_uri":      srv.URL + "/token",
+	})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "key.json")
+	require.NoError(t, os.WriteFile(path, keyFile, 0o600))
+
+	creds, err := vertex.FindDefaultCredentials(path)
+	require.NoError(t, err)
+	assert.Equal(t, "proj-from-key", creds.ProjectID)
+
+	for range 2 {
+		tok, err := creds.TokenSource.Token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "ya29.sa", tok.AccessToken)
+	}
+	assert.Equal(t, 1, exchanges, "tokens are cached until they near expiry")
+}
+
+func TestCredentials_AuthorizedUser(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "refresh_token", r.Form.Get("grant_type"))
+		assert.Equal(t, "rt", r.Form.Get("refresh_token"))
+		writeJSON(t, w, map[string]any{"access_token": "ya29.user", "expires_in": 3600})
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "adc.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"type":"authorized_user","client_id":"c","client_secret":"s","refresh_token":"rt","token_uri":"`+srv.URL+`"}`), 0o600))
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", path)
+
+	creds, err := vertex.FindDefaultCredentials("")
+	require.NoError(t, err)
+	tok, err := creds.TokenSource.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "ya29.user", tok.AccessToken)
+
+	_, err = vertex.CredentialsFromJSON([]byte(`{"type":"external_account"}`))
+	require.ErrorContains(t, err, `unsupported credentials type "external_account"`)
+}
+
+// fakeVertex is an in-memory Cloud Storage bucket and batch prediction API.
+type fakeVertex struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+	job     map[string]any
+	state   string
+}
+
+func (f *fakeVertex) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	assert.Equal(f.t, "Bearer tok", r.Header.Get("Authorization"))
+	switch {
+	case r.URL.Path == "/upload/storage/v1/b/bkt/o":
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Query().Get("name")] = data
+	case r.URL.Path == "/v1/projects/proj/locations/us-central1/batchPredictionJobs":
+		f.job = readBody(f.t, r)
+		writeJSON(f.t, w, map[string]any{"name": "projects/proj/locations/us-central1/batchPredictionJobs/42"})
+	case r.URL.Path == "/v1/projects/proj/locations/us-central1/batchPredictionJobs/42":
+		out := f.job["outputConfig"].(map[string]any)["gcsDestination"].(map[string]any)["outputUriPrefix"].(string)
+		writeJSON(f.t, w, map[string]any{
+			"state":      f.state,
+			"outputInfo": map[string]any{"gcsOutputDirectory": out + "/prediction-model-1"},
+		})
+	case r.URL.Path == "/storage/v1/b/bkt/o":
+		var items []map[string]any
+		for name := range f.objects {
+			if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+				items = append(items, map[string]any{"name": name})
+			}
+		}
+		writeJSON(f.t, w, map[string]any{"items": items})
+	case strings.HasPrefix(r.URL.Path, "/storage/v1/b/bkt/o/"):
+		assert.Equal(f.t, "media", r.URL.Query().Get("alt"))
+		_, _ = w.Write(f.objects[strings.TrimPrefix(r.URL.Path, "/storage/v1/b/bkt/o/")])
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestBatchSubmitter_Gemini(t *testing.T) {
+	fake := &fakeVertex{t: t, objects: map[string][]byte{}, state: "JOB_STATE_RUNNING"}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	cfg := testConfig(srv.URL)
+	sub, err := vertex.NewBatchSubmitter(cfg, "gemini-2.5-flash", cfg.Gemini("gemini-2.5-flash"), "gs://bkt/runs")
+	require.NoError(t, err)
+
+	reqs := []batch.Request{
+		{ID: "req-a", Chat: chat.New(message.NewText("", role.User, "one"))},
+		{ID: "req-b", Chat: chat.New(message.NewText("", role.User, "two"))},
+	}
+	batchID, err := sub.SubmitBatch(context.Background(), reqs)
+	require.NoError(t, err)
+	assert.Equal(t, "projects/proj/locations/us-central1/batchPredictionJobs/42", batchID)
+	assert.Equal(t, "publishers/google/models/gemini-2.5-flash", fake.job["model"])
+
+	uris := fake.job["inputConfig"].(map[string]any)["gcsSource"].(map[string]any)["uris"].([]any)
+	require.Len(t, uris, 1)
+	input := fake.objects[strings.TrimPrefix(uris[0].(string), "gs://bkt/")]
+	lines := strings.Split(strings.TrimSpace(string(input)), "\n")
+	require.Len(t, lines, 2)
+	var line map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &line))
+	assert.Equal(t, map[string]any{"shelly_request_id": "req-a"}, line["request"].(map[string]any)["labels"])
+
+	_, done, err := sub.PollBatch(context.Background(), batchID)
+	require.NoError(t, err)
+	assert.False(t, done)
+
+	out := fake.job["outputConfig"].(map[string]any)["gcsDestination"].(map[string]any)["outputUriPrefix"].(string)
+	fake.mu.Lock()
+	fake.state = "JOB_STATE_SUCCEEDED"
+	fake.objects[strings.TrimPrefix(out, "gs://bkt/")+"/prediction-model-1/predictions.jsonl"] = []byte(
+		`{"request":{"labels":{"shelly_request_id":"req-a"}},"status":"","response":{"candidates":[{"content":{"role":"model","parts":[{"text":"uno"}]}}],"usageMetadata":{"promptTokenCount":2,"candidatesTokenCount":1}}}
+{"request":{"labels":{"shelly_request_id":"req-b"}},"status":"Bad Request: invalid content","response":null}
+`)
+	fake.mu.Unlock()
+
+	results, done, err := sub.PollBatch(context.Background(), batchID)
+	require.NoError(t, err)
+	require.True(t, done)
+	require.NoError(t, results["req-a"].Err)
+	assert.Equal(t, "uno", results["req-a"].Message.TextContent())
+	assert.Equal(t, 2, results["req-a"].Usage.InputTokens)
+	assert.ErrorContains(t, results["req-b"].Err, "invalid content")
+}
+
+func TestBatchSubmitter_AnthropicUsesCustomID(t *testing.T) {
+	fake := &fakeVertex{t: t, objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	cfg := testConfig(srv.URL)
+	model := "claude-haiku-4-5@20251001"
+	sub, err := vertex.NewBatchSubmitter(cfg, model, cfg.Anthropic(model), "gs://bkt")
+	require.NoError(t, err)
+
+	_, err = sub.SubmitBatch(context.Background(), []batch.Request{{ID: "req-a", Chat: chat.New(message.NewText("", role.User, "one"))}})
+	require.NoError(t, err)
+	assert.Equal(t, "publishers/anthropic/models/"+model, fake.job["model"])
+
+	for name, data := range fake.objects {
+		require.True(t, strings.HasSuffix(name, "/input.jsonl"), name)
+		var line map[string]any
+		require.NoError(t, json.Unmarshal(data, &line))
+		assert.Equal(t, "req-a", line["custom_id"])
+		assert.Equal(t, "vertex-2023-10-16", line["request"].(map[string]any)["anthropic_version"])
+	}
+
+	_, err = vertex.NewBatchSubmitter(cfg, model, cfg.Anthropic(model), "s3://bkt")
+	require.ErrorContains(t, err, "must start with gs://")
+}
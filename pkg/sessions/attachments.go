@@ -85,6 +85,20 @@ func attachmentExt(mediaType string) string {
 		return ".svg"
 	case "application/pdf":
 		return ".pdf"
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return ".wav"
+	case "audio/mpeg", "audio/mp3":
+		return ".mp3"
+	case "audio/mp4", "audio/x-m4a":
+		return ".m4a"
+	case "audio/ogg":
+		return ".ogg"
+	case "audio/flac", "audio/x-flac":
+		return ".flac"
+	case "audio/aac":
+		return ".aac"
+	case "audio/webm":
+		return ".webm"
 	default:
 		return ".bin"
 	}
@@ -120,6 +134,20 @@ func extToMediaType(ext string) string {
 		return "image/svg+xml"
 	case ".pdf":
 		return "application/pdf"
+	case ".wav":
+		return "audio/wav"
+	case ".mp3":
+		return "audio/mpeg"
+	case ".m4a":
+		return "audio/mp4"
+	case ".ogg":
+		return "audio/ogg"
+	case ".flac":
+		return "audio/flac"
+	case ".aac":
+		return "audio/aac"
+	case ".webm":
+		return "audio/webm"
 	default:
 		return "application/octet-stream"
 	}
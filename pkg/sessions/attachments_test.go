@@ -31,6 +31,9 @@ func TestAttachmentExt(t *testing.T) {
 		{"image/webp", ".webp"},
 		{"image/svg+xml", ".svg"},
 		{"application/pdf", ".pdf"},
+		{"audio/wav", ".wav"},
+		{"audio/mpeg", ".mp3"},
+		{"audio/mp4", ".m4a"},
 		{"application/octet-stream", ".bin"},
 		{"unknown/type", ".bin"},
 	}
@@ -0,0 +1,466 @@
+package sessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+)
+
+// Format is a transcript format for Export and Import.
+type Format string
+
+// Transcript formats.
+const (
+	FormatMarkdown  Format = "md"
+	FormatHTML      Format = "html"
+	FormatJSON      Format = "json"      // Session metadata and messages in the store's part encoding, data inlined.
+	FormatOpenAI    Format = "openai"    // {"messages": [...]} in the chat completions format.
+	FormatAnthropic Format = "anthropic" // {"system": "...", "messages": [...]} in the messages API format.
+)
+
+// ExportFormats lists the formats Export accepts.
+func ExportFormats() []Format {
+	return []Format{FormatMarkdown, FormatHTML, FormatJSON, FormatOpenAI, FormatAnthropic}
+}
+
+// ImportFormats lists the formats Import accepts.
+func ImportFormats() []Format {
+	return []Format{FormatJSON, FormatOpenAI, FormatAnthropic}
+}
+
+// ExportOptions configures Export.
+type ExportOptions struct {
+	Format Format
+
+	// AttachmentsDir, when set, makes Markdown and HTML exports link images,
+	// documents and audio to files in this directory (content-addressed, as in
+	// the store) instead of embedding them as data URIs. Other formats always
+	// embed the data.
+	AttachmentsDir string
+
+	// LinkAttachments makes Store.Export link to the session's attachment
+	// store, setting AttachmentsDir to its absolute path.
+	LinkAttachments bool
+
+	// NoRedact keeps secrets in the export. By default Redact is applied to
+	// all text, tool arguments and tool results.
+	NoRedact bool
+}
+
+// jsonTranscript is the FormatJSON document.
+type jsonTranscript struct {
+	Session  SessionInfo     `json:"session"`
+	Messages json.RawMessage `json:"messages"`
+}
+
+// Export loads the session with the given ID and writes its transcript to w.
+func (s *Store) Export(w io.Writer, id string, opts ExportOptions) error {
+	info, msgs, err := s.Load(id)
+	if err != nil {
+		return err
+	}
+
+	if opts.LinkAttachments {
+		dir, err := filepath.Abs(s.attachmentsDir(info.ID))
+		if err != nil {
+			return fmt.Errorf("sessions: export: %w", err)
+		}
+		opts.AttachmentsDir = dir
+	}
+
+	return Export(w, info, msgs, opts)
+}
+
+// Export writes a session transcript to w.
+func Export(w io.Writer, info SessionInfo, msgs []message.Message, opts ExportOptions) error {
+	if !opts.NoRedact {
+		msgs = RedactMessages(msgs)
+		info.Preview = Redact(info.Preview)
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	switch opts.Format {
+	case FormatMarkdown:
+		data, err = renderMarkdown(info, msgs, newLinker(opts.AttachmentsDir))
+	case FormatHTML:
+		data, err = renderHTML(info, msgs, newLinker(opts.AttachmentsDir))
+	case FormatJSON:
+		var raw []byte
+		if raw, err = MarshalMessages(msgs); err == nil {
+			data, err = json.MarshalIndent(jsonTranscript{Session: info, Messages: raw}, "", "  ")
+		}
+	case FormatOpenAI:
+		var oms []openAIMessage
+		if oms, err = toOpenAI(msgs); err == nil {
+			data, err = json.MarshalIndent(struct {
+				Messages []openAIMessage `json:"messages"`
+			}{oms}, "", "  ")
+		}
+	case FormatAnthropic:
+		data, err = json.MarshalIndent(toAnthropic(msgs), "", "  ")
+	default:
+		return fmt.Errorf("sessions: export: unknown format %q", opts.Format)
+	}
+	if err != nil {
+		return fmt.Errorf("sessions: export: %w", err)
+	}
+	if opts.Format != FormatMarkdown && opts.Format != FormatHTML {
+		data = append(data, '\n')
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("sessions: export: %w", err)
+	}
+	return nil
+}
+
+// Import parses a transcript in one of the ImportFormats. OpenAI and
+// Anthropic transcripts may be a bare message array or an object with a
+// "messages" array; FormatJSON also returns the exported session metadata.
+func Import(data []byte, format Format) (SessionInfo, []message.Message, error) {
+	switch format {
+	case FormatJSON:
+		var t jsonTranscript
+		if err := json.Unmarshal(data, &t); err != nil {
+			return SessionInfo{}, nil, fmt.Errorf("sessions: import: %w", err)
+		}
+		msgs, err := UnmarshalMessages(t.Messages)
+		if err != nil {
+			return SessionInfo{}, nil, err
+		}
+		return t.Session, msgs, nil
+	case FormatOpenAI:
+		var oms []openAIMessage
+		if err := unmarshalMessageArray(data, &oms, nil); err != nil {
+			return SessionInfo{}, nil, err
+		}
+		msgs, err := fromOpenAI(oms)
+		return SessionInfo{}, msgs, err
+	case FormatAnthropic:
+		var t anthropicTranscript
+		if err := unmarshalMessageArray(data, &t.Messages, &t); err != nil {
+			return SessionInfo{}, nil, err
+		}
+		msgs, err := fromAnthropic(t)
+		return SessionInfo{}, msgs, err
+	default:
+		return SessionInfo{}, nil, fmt.Errorf("sessions: import: unknown format %q", format)
+	}
+}
+
+// unmarshalMessageArray decodes data as a bare array into arr, or as an
+// object with a "messages" key into obj (when non-nil) or arr.
+func unmarshalMessageArray(data []byte, arr, obj any) error {
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(data, arr); err != nil {
+			return fmt.Errorf("sessions: import: %w", err)
+		}
+		return nil
+	}
+
+	if obj == nil {
+		obj = &struct {
+			Messages any `json:"messages"`
+		}{arr}
+	}
+	if err := json.Unmarshal(data, obj); err != nil {
+		return fmt.Errorf("sessions: import: %w", err)
+	}
+	return nil
+}
+
+// --- Markdown and HTML ---
+
+// linker turns binary parts into URLs: data URIs, or links to files written
+// to an attachments directory.
+type linker struct {
+	store *FileAttachmentStore
+	dir   string
+}
+
+func newLinker(dir string) linker {
+	if dir == "" {
+		return linker{}
+	}
+	return linker{store: NewFileAttachmentStore(dir), dir: dir}
+}
+
+func (l linker) url(data []byte, mediaType string) (string, error) {
+	if l.store == nil {
+		return dataURI(mediaType, data), nil
+	}
+	ref, err := l.store.WriteAttachment(data, mediaType)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(filepath.Join(l.dir, ref)), nil
+}
+
+// toolNames maps tool call IDs to tool names so results can be labelled.
+func toolNames(msgs []message.Message) map[string]string {
+	names := make(map[string]string)
+	for _, m := range msgs {
+		for _, tc := range m.ToolCalls() {
+			names[tc.ID] = tc.Name
+		}
+	}
+	return names
+}
+
+// prettyJSON indents JSON arguments, returning s unchanged when it is not JSON.
+func prettyJSON(s string) string {
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return s
+	}
+	return string(b)
+}
+
+// roleHeading returns the heading for a message: the role, plus the sender
+// when it says more than the role does.
+func roleHeading(m message.Message) string {
+	r := m.Role.String()
+	if r == "" {
+		r = "message"
+	}
+	h := strings.ToUpper(r[:1]) + r[1:]
+	if m.Sender != "" && m.Sender != r {
+		h += " (" + m.Sender + ")"
+	}
+	return h
+}
+
+func displayName(path, fallback string) string {
+	if path == "" {
+		return fallback
+	}
+	return filepath.Base(path)
+}
+
+func providerLabel(p ProviderMeta) string {
+	if p.Model == "" {
+		return p.Kind
+	}
+	return p.Kind + "/" + p.Model
+}
+
+// fence returns a code fence longer than any backtick run in s.
+func fence(s string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+func renderMarkdown(info SessionInfo, msgs []message.Message, l linker) ([]byte, error) {
+	var b strings.Builder
+	names := toolNames(msgs)
+
+	fmt.Fprintf(&b, "# Session %s\n\n", info.ID)
+	writeMetaMarkdown(&b, info)
+
+	for _, m := range msgs {
+		if m.Role != role.Tool {
+			fmt.Fprintf(&b, "## %s\n\n", roleHeading(m))
+		}
+		for _, p := range m.Parts {
+			if err := writePartMarkdown(&b, p, names, l); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return []byte(strings.TrimRight(b.String(), "\n") + "\n"), nil
+}
+
+func writeMetaMarkdown(b *strings.Builder, info SessionInfo) {
+	if info.Title != "" {
+		fmt.Fprintf(b, "- **Title:** %s\n", info.Title)
+	}
+	if len(info.Tags) > 0 {
+		fmt.Fprintf(b, "- **Tags:** %s\n", strings.Join(info.Tags, ", "))
+	}
+	if info.Agent != "" {
+		fmt.Fprintf(b, "- **Agent:** %s\n", info.Agent)
+	}
+	if info.Provider.Kind != "" {
+		fmt.Fprintf(b, "- **Provider:** %s\n", providerLabel(info.Provider))
+	}
+	if !info.CreatedAt.IsZero() {
+		fmt.Fprintf(b, "- **Created:** %s\n", info.CreatedAt.Format(time.RFC3339))
+	}
+	if !info.UpdatedAt.IsZero() {
+		fmt.Fprintf(b, "- **Updated:** %s\n", info.UpdatedAt.Format(time.RFC3339))
+	}
+	fmt.Fprintf(b, "- **Messages:** %d\n\n---\n\n", info.MsgCount)
+}
+
+func writePartMarkdown(b *strings.Builder, p content.Part, names map[string]string, l linker) error {
+	switch v := p.(type) {
+	case content.Text:
+		if v.Text != "" {
+			b.WriteString(v.Text + "\n\n")
+		}
+	case content.Image:
+		url := v.URL
+		if len(v.Data) > 0 {
+			var err error
+			if url, err = l.url(v.Data, v.MediaType); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(b, "![image](%s)\n\n", url)
+	case content.Document:
+		url, err := l.url(v.Data, v.MediaType)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(b, "[%s](%s)\n\n", displayName(v.Path, "document"), url)
+	case content.Audio:
+		url, err := l.url(v.Data, v.MediaType)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(b, "[%s](%s)\n\n", displayName(v.Path, "audio"), url)
+	case content.ToolCall:
+		args := prettyJSON(v.Arguments)
+		f := fence(args)
+		fmt.Fprintf(b, "<details>\n<summary>Tool call: %s</summary>\n\n%sjson\n%s\n%s\n\n</details>\n\n", v.Name, f, args, f)
+	case content.ToolResult:
+		label := "Tool result"
+		if name := names[v.ToolCallID]; name != "" {
+			label += ": " + name
+		}
+		if v.IsError {
+			label += " (error)"
+		}
+		f := fence(v.Content)
+		fmt.Fprintf(b, "<details>\n<summary>%s</summary>\n\n%s\n%s\n%s\n\n</details>\n\n", label, f, v.Content, f)
+	case content.Reasoning:
+		if v.Text != "" {
+			fmt.Fprintf(b, "<details>\n<summary>Reasoning</summary>\n\n%s\n\n</details>\n\n", v.Text)
+		}
+	}
+	return nil
+}
+
+const htmlStyle = `body{font-family:system-ui,sans-serif;max-width:52rem;margin:2rem auto;padding:0 1rem;color:#1f2328}
+h2{font-size:1rem;margin:1.5rem 0 .5rem;color:#57606a}
+.text{white-space:pre-wrap}
+pre{background:#f6f8fa;padding:.75rem;overflow-x:auto;white-space:pre-wrap}
+details{margin:.5rem 0;border:1px solid #d0d7de;border-radius:6px;padding:.25rem .75rem}
+summary{cursor:pointer;font-family:ui-monospace,monospace;font-size:.875rem}
+.error summary{color:#cf222e}
+img{max-width:100%}`
+
+func renderHTML(info SessionInfo, msgs []message.Message, l linker) ([]byte, error) {
+	var b strings.Builder
+	names := toolNames(msgs)
+	esc := html.EscapeString
+
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Session %s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n", esc(info.ID), htmlStyle)
+	fmt.Fprintf(&b, "<h1>Session %s</h1>\n<ul>\n", esc(info.ID))
+	if info.Title != "" {
+		fmt.Fprintf(&b, "<li><b>Title:</b> %s</li>\n", esc(info.Title))
+	}
+	if len(info.Tags) > 0 {
+		fmt.Fprintf(&b, "<li><b>Tags:</b> %s</li>\n", esc(strings.Join(info.Tags, ", ")))
+	}
+	if info.Agent != "" {
+		fmt.Fprintf(&b, "<li><b>Agent:</b> %s</li>\n", esc(info.Agent))
+	}
+	if info.Provider.Kind != "" {
+		fmt.Fprintf(&b, "<li><b>Provider:</b> %s</li>\n", esc(providerLabel(info.Provider)))
+	}
+	if !info.CreatedAt.IsZero() {
+		fmt.Fprintf(&b, "<li><b>Created:</b> %s</li>\n", info.CreatedAt.Format(time.RFC3339))
+	}
+	if !info.UpdatedAt.IsZero() {
+		fmt.Fprintf(&b, "<li><b>Updated:</b> %s</li>\n", info.UpdatedAt.Format(time.RFC3339))
+	}
+	fmt.Fprintf(&b, "<li><b>Messages:</b> %d</li>\n</ul>\n<hr>\n", info.MsgCount)
+
+	for _, m := range msgs {
+		if m.Role != role.Tool {
+			fmt.Fprintf(&b, "<h2>%s</h2>\n", esc(roleHeading(m)))
+		}
+		for _, p := range m.Parts {
+			if err := writePartHTML(&b, p, names, l); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	b.WriteString("</body>\n</html>\n")
+	return []byte(b.String()), nil
+}
+
+func writePartHTML(b *strings.Builder, p content.Part, names map[string]string, l linker) error {
+	esc := html.EscapeString
+	switch v := p.(type) {
+	case content.Text:
+		if v.Text != "" {
+			fmt.Fprintf(b, "<div class=\"text\">%s</div>\n", esc(v.Text))
+		}
+	case content.Image:
+		url := v.URL
+		if len(v.Data) > 0 {
+			var err error
+			if url, err = l.url(v.Data, v.MediaType); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(b, "<p><img src=\"%s\" alt=\"image\"></p>\n", esc(url))
+	case content.Document:
+		url, err := l.url(v.Data, v.MediaType)
+		if err != nil {
+			return err
+		}
+		name := displayName(v.Path, "document")
+		fmt.Fprintf(b, "<p><a href=\"%s\" download=\"%s\">%s</a></p>\n", esc(url), esc(name), esc(name))
+	case content.Audio:
+		url, err := l.url(v.Data, v.MediaType)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(b, "<p>%s<br><audio controls src=\"%s\"></audio></p>\n", esc(displayName(v.Path, "audio")), esc(url))
+	case content.ToolCall:
+		fmt.Fprintf(b, "<details>\n<summary>Tool call: %s</summary>\n<pre>%s</pre>\n</details>\n", esc(v.Name), esc(prettyJSON(v.Arguments)))
+	case content.ToolResult:
+		label, class := "Tool result", ""
+		if name := names[v.ToolCallID]; name != "" {
+			label += ": " + name
+		}
+		if v.IsError {
+			label += " (error)"
+			class = " class=\"error\""
+		}
+		fmt.Fprintf(b, "<details%s>\n<summary>%s</summary>\n<pre>%s</pre>\n</details>\n", class, esc(label), esc(v.Content))
+	case content.Reasoning:
+		if v.Text != "" {
+			fmt.Fprintf(b, "<details>\n<summary>Reasoning</summary>\n<div class=\"text\">%s</div>\n</details>\n", esc(v.Text))
+		}
+	}
+	return nil
+}
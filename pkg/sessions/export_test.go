@@ -0,0 +1,228 @@
+package sessions
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+)
+
+func exportFixture() (SessionInfo, []message.Message) {
+	info := SessionInfo{
+		ID:        "abc123",
+		Agent:     "coder",
+		Provider:  ProviderMeta{Kind: "anthropic", Model: "claude"},
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		MsgCount:  5,
+	}
+	msgs := []message.Message{
+		message.NewText("system", role.System, "Be brief."),
+		message.New("user", role.User,
+			content.Text{Text: "What is in this image? My key is sk-abcdefghijklmnopqrstuvwxyz"},
+			content.Image{Data: []byte("png-bytes"), MediaType: "image/png"},
+		),
+		message.New("coder", role.Assistant,
+			content.Reasoning{Text: "Look at the file."},
+			content.ToolCall{ID: "call_1", Name: "read_file", Arguments: `{"path":"a.go"}`},
+		),
+		message.New("tool", role.Tool, content.ToolResult{ToolCallID: "call_1", Content: "package a\n```"}),
+		message.NewText("coder", role.Assistant, "A cat."),
+	}
+	return info, msgs
+}
+
+func TestExport_Markdown(t *testing.T) {
+	info, msgs := exportFixture()
+
+	var buf bytes.Buffer
+	require.NoError(t, Export(&buf, info, msgs, ExportOptions{Format: FormatMarkdown}))
+	out := buf.String()
+
+	assert.Contains(t, out, "# Session abc123")
+	assert.Contains(t, out, "- **Provider:** anthropic/claude")
+	assert.Contains(t, out, "## User\n\nWhat is in this image? My key is [REDACTED]")
+	assert.Contains(t, out, "![image](data:image/png;base64,")
+	assert.Contains(t, out, "## Assistant (coder)")
+	assert.Contains(t, out, "<summary>Tool call: read_file</summary>\n\n```json\n{\n  \"path\": \"a.go\"\n}\n```")
+	assert.Contains(t, out, "<summary>Tool result: read_file</summary>\n\n````\npackage a\n```\n````", "fence must outgrow backticks in the content")
+	assert.Contains(t, out, "<summary>Reasoning</summary>")
+	assert.NotContains(t, out, "sk-abcdefghijklmnopqrstuvwxyz")
+}
+
+func TestExport_LinkedAttachments(t *testing.T) {
+	info, msgs := exportFixture()
+	dir := t.TempDir()
+
+	var buf bytes.Buffer
+	require.NoError(t, Export(&buf, info, msgs, ExportOptions{Format: FormatHTML, AttachmentsDir: dir}))
+
+	ref := attachmentHash([]byte("png-bytes")) + ".png"
+	assert.Contains(t, buf.String(), `<img src="`+filepath.ToSlash(filepath.Join(dir, ref))+`"`)
+	assert.NotContains(t, buf.String(), "base64")
+	assert.FileExists(t, filepath.Join(dir, ref))
+}
+
+func TestStore_Export(t *testing.T) {
+	root := t.TempDir()
+	store := New(root)
+	info, msgs := exportFixture()
+	require.NoError(t, store.Save(info, msgs))
+
+	var buf bytes.Buffer
+	require.NoError(t, store.Export(&buf, info.ID, ExportOptions{Format: FormatHTML, LinkAttachments: true}))
+
+	ref := attachmentHash([]byte("png-bytes")) + ".png"
+	assert.Contains(t, buf.String(), `<img src="`+filepath.ToSlash(filepath.Join(root, info.ID, "attachments", ref))+`"`)
+
+	buf.Reset()
+	require.NoError(t, store.Export(&buf, info.ID, ExportOptions{Format: FormatHTML}))
+	assert.Contains(t, buf.String(), "base64")
+
+	require.Error(t, store.Export(&buf, "missing", ExportOptions{Format: FormatHTML}))
+}
+
+func TestExport_HTMLEscapes(t *testing.T) {
+	msgs := []message.Message{message.NewText("user", role.User, "<script>alert(1)</script>")}
+
+	var buf bytes.Buffer
+	require.NoError(t, Export(&buf, SessionInfo{ID: "x"}, msgs, ExportOptions{Format: FormatHTML}))
+
+	assert.Contains(t, buf.String(), "&lt;script&gt;alert(1)&lt;/script&gt;")
+	assert.NotContains(t, buf.String(), "<script>")
+}
+
+func TestExport_NoRedact(t *testing.T) {
+	info, msgs := exportFixture()
+
+	var buf bytes.Buffer
+	require.NoError(t, Export(&buf, info, msgs, ExportOptions{Format: FormatJSON, NoRedact: true}))
+
+	assert.Contains(t, buf.String(), "sk-abcdefghijklmnopqrstuvwxyz")
+}
+
+func TestExport_UnknownFormat(t *testing.T) {
+	err := Export(&bytes.Buffer{}, SessionInfo{}, nil, ExportOptions{Format: "pdf"})
+	require.ErrorContains(t, err, `sessions: export: unknown format "pdf"`)
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	info, msgs := exportFixture()
+
+	var buf bytes.Buffer
+	require.NoError(t, Export(&buf, info, msgs, ExportOptions{Format: FormatJSON}))
+
+	gotInfo, got, err := Import(buf.Bytes(), FormatJSON)
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", gotInfo.ID)
+	require.Len(t, got, len(msgs))
+	assert.Equal(t, content.Image{Data: []byte("png-bytes"), MediaType: "image/png"}, got[1].Parts[1])
+	assert.Equal(t, "What is in this image? My key is [REDACTED]", got[1].TextContent())
+}
+
+func TestExport_OpenAI(t *testing.T) {
+	info, msgs := exportFixture()
+
+	var buf bytes.Buffer
+	require.NoError(t, Export(&buf, info, msgs, ExportOptions{Format: FormatOpenAI, NoRedact: true}))
+
+	var doc struct {
+		Messages []map[string]any `json:"messages"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	require.Len(t, doc.Messages, 5)
+	assert.Equal(t, "Be brief.", doc.Messages[0]["content"])
+
+	userParts := doc.Messages[1]["content"].([]any)
+	assert.Equal(t, "image_url", userParts[1].(map[string]any)["type"])
+
+	calls := doc.Messages[2]["tool_calls"].([]any)
+	assert.Equal(t, "call_1", calls[0].(map[string]any)["id"])
+	assert.NotContains(t, doc.Messages[2], "content", "tool-call-only turns carry no content")
+	assert.Equal(t, "tool", doc.Messages[3]["role"])
+
+	// The export imports back to the same conversation shape.
+	_, got, err := Import(buf.Bytes(), FormatOpenAI)
+	require.NoError(t, err)
+	require.Len(t, got, 5)
+	assert.Equal(t, content.Image{Data: []byte("png-bytes"), MediaType: "image/png"}, got[1].Parts[1])
+	assert.Equal(t, []content.ToolCall{{ID: "call_1", Name: "read_file", Arguments: `{"path":"a.go"}`}}, got[2].ToolCalls())
+	assert.Equal(t, role.Tool, got[3].Role)
+}
+
+func TestExport_Anthropic(t *testing.T) {
+	info, msgs := exportFixture()
+
+	var buf bytes.Buffer
+	require.NoError(t, Export(&buf, info, msgs, ExportOptions{Format: FormatAnthropic}))
+
+	var doc anthropicTranscript
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "Be brief.", doc.System)
+	require.Len(t, doc.Messages, 4)
+	assert.Equal(t, "tool_use", doc.Messages[1].Content[0].Type)
+	assert.Equal(t, "user", doc.Messages[2].Role)
+	assert.Equal(t, "tool_result", doc.Messages[2].Content[0].Type)
+}
+
+func TestImport_OpenAIArray(t *testing.T) {
+	data := []byte(`[
+		{"role": "developer", "content": "Be brief."},
+		{"role": "user", "content": [{"type": "text", "text": "hi"}, {"type": "image_url", "image_url": {"url": "https://x.y/cat.png"}}]},
+		{"role": "assistant", "content": null, "tool_calls": [
+			{"id": "a", "type": "function", "function": {"name": "ls", "arguments": "{}"}},
+			{"id": "b", "type": "function", "function": {"name": "pwd", "arguments": "{}"}}
+		]},
+		{"role": "tool", "tool_call_id": "a", "content": "a.go"},
+		{"role": "tool", "tool_call_id": "b", "content": [{"type": "text", "text": "/src"}]}
+	]`)
+
+	_, msgs, err := Import(data, FormatOpenAI)
+	require.NoError(t, err)
+	require.Len(t, msgs, 4)
+	assert.Equal(t, role.System, msgs[0].Role)
+	assert.Equal(t, content.Image{URL: "https://x.y/cat.png"}, msgs[1].Parts[1])
+	assert.Len(t, msgs[2].ToolCalls(), 2)
+	assert.Equal(t, []content.Part{
+		content.ToolResult{ToolCallID: "a", Content: "a.go"},
+		content.ToolResult{ToolCallID: "b", Content: "/src"},
+	}, msgs[3].Parts)
+
+	_, _, err = Import([]byte(`[{"role": "narrator", "content": "x"}]`), FormatOpenAI)
+	require.ErrorContains(t, err, `unknown role "narrator"`)
+}
+
+func TestImport_Anthropic(t *testing.T) {
+	data := []byte(`{
+		"system": "Be brief.",
+		"messages": [
+			{"role": "user", "content": [{"type": "text", "text": "List files"}]},
+			{"role": "assistant", "content": [
+				{"type": "thinking", "thinking": "Use ls.", "signature": "sig"},
+				{"type": "tool_use", "id": "toolu_1", "name": "ls", "input": {"dir": "."}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_1", "content": [{"type": "text", "text": "a.go"}, {"type": "text", "text": "b.go"}]},
+				{"type": "text", "text": "Thanks"}
+			]}
+		]
+	}`)
+
+	_, msgs, err := Import(data, FormatAnthropic)
+	require.NoError(t, err)
+	require.Len(t, msgs, 5)
+
+	assert.Equal(t, message.NewText("system", role.System, "Be brief."), msgs[0])
+	assert.Equal(t, []content.ToolCall{{ID: "toolu_1", Name: "ls", Arguments: `{"dir": "."}`}}, msgs[2].ToolCalls())
+	assert.Equal(t, role.Tool, msgs[3].Role)
+	assert.Equal(t, content.ToolResult{ToolCallID: "toolu_1", Content: "a.go\nb.go", IsError: false}, msgs[3].Parts[0])
+	assert.Equal(t, "Thanks", msgs[4].TextContent(), "user text after tool results stays a user message")
+	assert.Equal(t, role.User, msgs[4].Role)
+}
@@ -0,0 +1,460 @@
+package sessions
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+)
+
+// --- OpenAI chat completions ---
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    json.RawMessage  `json:"content,omitempty"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAIPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url,omitempty"`
+	File *struct {
+		Filename string `json:"filename,omitempty"`
+		FileData string `json:"file_data"`
+	} `json:"file,omitempty"`
+	InputAudio *struct {
+		Data   string `json:"data"`
+		Format string `json:"format"`
+	} `json:"input_audio,omitempty"`
+}
+
+// toOpenAI converts messages to the chat completions message array.
+// Reasoning parts are dropped; audio other than wav and mp3 is dropped.
+func toOpenAI(msgs []message.Message) ([]openAIMessage, error) {
+	var out []openAIMessage
+	for _, m := range msgs {
+		if m.Role == role.Tool {
+			for _, p := range m.Parts {
+				if tr, ok := p.(content.ToolResult); ok {
+					out = append(out, openAIMessage{Role: "tool", ToolCallID: tr.ToolCallID, Content: jsonString(tr.Content)})
+				}
+			}
+			continue
+		}
+
+		om := openAIMessage{Role: m.Role.String()}
+		var parts []openAIPart
+		multiModal := false
+		for _, p := range m.Parts {
+			switch v := p.(type) {
+			case content.Text:
+				parts = append(parts, openAIPart{Type: "text", Text: v.Text})
+			case content.Image:
+				op := openAIPart{Type: "image_url"}
+				op.ImageURL = &struct {
+					URL string `json:"url"`
+				}{URL: imageURL(v)}
+				parts = append(parts, op)
+				multiModal = true
+			case content.Document:
+				op := openAIPart{Type: "file"}
+				op.File = &struct {
+					Filename string `json:"filename,omitempty"`
+					FileData string `json:"file_data"`
+				}{Filename: filepath.Base(v.Path), FileData: dataURI(v.MediaType, v.Data)}
+				parts = append(parts, op)
+				multiModal = true
+			case content.Audio:
+				format := openAIAudioFormat(v.MediaType)
+				if format == "" {
+					continue
+				}
+				op := openAIPart{Type: "input_audio"}
+				op.InputAudio = &struct {
+					Data   string `json:"data"`
+					Format string `json:"format"`
+				}{Data: base64.StdEncoding.EncodeToString(v.Data), Format: format}
+				parts = append(parts, op)
+				multiModal = true
+			case content.ToolCall:
+				tc := openAIToolCall{ID: v.ID, Type: "function"}
+				tc.Function.Name = v.Name
+				tc.Function.Arguments = v.Arguments
+				om.ToolCalls = append(om.ToolCalls, tc)
+			}
+		}
+
+		if multiModal {
+			b, err := json.Marshal(parts)
+			if err != nil {
+				return nil, err
+			}
+			om.Content = b
+		} else if len(parts) > 0 || len(om.ToolCalls) == 0 {
+			texts := make([]string, len(parts))
+			for i, p := range parts {
+				texts[i] = p.Text
+			}
+			om.Content = jsonString(strings.Join(texts, "\n"))
+		}
+		out = append(out, om)
+	}
+	return out, nil
+}
+
+// fromOpenAI converts a chat completions message array to messages.
+func fromOpenAI(oms []openAIMessage) ([]message.Message, error) {
+	msgs := make([]message.Message, 0, len(oms))
+	for i, om := range oms {
+		var parts []content.Part
+
+		switch om.Role {
+		case "system", "developer", "user", "assistant":
+			var err error
+			parts, err = openAIContentParts(om.Content)
+			if err != nil {
+				return nil, fmt.Errorf("sessions: import: message %d: %w", i, err)
+			}
+			for _, tc := range om.ToolCalls {
+				parts = append(parts, content.ToolCall{ID: tc.ID, Name: tc.Function.Name, Arguments: tc.Function.Arguments})
+			}
+		case "tool":
+			text, err := openAIContentText(om.Content)
+			if err != nil {
+				return nil, fmt.Errorf("sessions: import: message %d: %w", i, err)
+			}
+			tr := content.ToolResult{ToolCallID: om.ToolCallID, Content: text}
+			// Consecutive results belong to the same turn.
+			if n := len(msgs); n > 0 && msgs[n-1].Role == role.Tool {
+				msgs[n-1].Parts = append(msgs[n-1].Parts, tr)
+				continue
+			}
+			parts = []content.Part{tr}
+		default:
+			return nil, fmt.Errorf("sessions: import: message %d: unknown role %q", i, om.Role)
+		}
+
+		r := role.Role(om.Role)
+		if om.Role == "developer" {
+			r = role.System
+		}
+		msgs = append(msgs, message.New(r.String(), r, parts...))
+	}
+	return msgs, nil
+}
+
+func openAIContentParts(raw json.RawMessage) ([]content.Part, error) {
+	if isNullJSON(raw) {
+		return nil, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if s == "" {
+			return nil, nil
+		}
+		return []content.Part{content.Text{Text: s}}, nil
+	}
+
+	var ops []openAIPart
+	if err := json.Unmarshal(raw, &ops); err != nil {
+		return nil, fmt.Errorf("content: %w", err)
+	}
+	var parts []content.Part
+	for _, op := range ops {
+		switch {
+		case op.Type == "text":
+			parts = append(parts, content.Text{Text: op.Text})
+		case op.Type == "image_url" && op.ImageURL != nil:
+			parts = append(parts, imageFromURL(op.ImageURL.URL))
+		case op.Type == "file" && op.File != nil:
+			mediaType, data, ok := parseDataURI(op.File.FileData)
+			if !ok {
+				return nil, fmt.Errorf("file %q: expected a data URI", op.File.Filename)
+			}
+			parts = append(parts, content.Document{Path: op.File.Filename, Data: data, MediaType: mediaType})
+		case op.Type == "input_audio" && op.InputAudio != nil:
+			data, err := base64.StdEncoding.DecodeString(op.InputAudio.Data)
+			if err != nil {
+				return nil, fmt.Errorf("input_audio: %w", err)
+			}
+			parts = append(parts, content.Audio{Data: data, MediaType: "audio/" + strings.Replace(op.InputAudio.Format, "mp3", "mpeg", 1)})
+		}
+	}
+	return parts, nil
+}
+
+func openAIContentText(raw json.RawMessage) (string, error) {
+	parts, err := openAIContentParts(raw)
+	if err != nil {
+		return "", err
+	}
+	return message.New("", role.Tool, parts...).TextContent(), nil
+}
+
+func openAIAudioFormat(mediaType string) string {
+	switch mediaType {
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return "wav"
+	case "audio/mpeg", "audio/mp3":
+		return "mp3"
+	default:
+		return ""
+	}
+}
+
+// --- Anthropic messages ---
+
+type anthropicTranscript struct {
+	System   string             `json:"system,omitempty"`
+	Messages []anthropicMessage `json:"messages"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type      string           `json:"type"`
+	Text      string           `json:"text,omitempty"`
+	Source    *anthropicSource `json:"source,omitempty"`
+	Title     string           `json:"title,omitempty"`
+	ID        string           `json:"id,omitempty"`
+	Name      string           `json:"name,omitempty"`
+	Input     json.RawMessage  `json:"input,omitempty"`
+	ToolUseID string           `json:"tool_use_id,omitempty"`
+	Content   json.RawMessage  `json:"content,omitempty"`
+	IsError   bool             `json:"is_error,omitempty"`
+	Thinking  string           `json:"thinking,omitempty"`
+}
+
+type anthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// toAnthropic converts messages to an Anthropic system prompt and message
+// array. Tool results become user tool_result blocks and consecutive
+// messages with the same role are merged. Reasoning and audio are dropped.
+func toAnthropic(msgs []message.Message) anthropicTranscript {
+	var t anthropicTranscript
+	for _, m := range msgs {
+		if m.Role == role.System {
+			if t.System != "" {
+				t.System += "\n\n"
+			}
+			t.System += m.TextContent()
+			continue
+		}
+
+		r := "user"
+		if m.Role == role.Assistant {
+			r = "assistant"
+		}
+
+		var blocks []anthropicBlock
+		for _, p := range m.Parts {
+			switch v := p.(type) {
+			case content.Text:
+				if v.Text != "" {
+					blocks = append(blocks, anthropicBlock{Type: "text", Text: v.Text})
+				}
+			case content.Image:
+				src := &anthropicSource{Type: "url", URL: v.URL}
+				if len(v.Data) > 0 {
+					src = &anthropicSource{Type: "base64", MediaType: v.MediaType, Data: base64.StdEncoding.EncodeToString(v.Data)}
+				}
+				blocks = append(blocks, anthropicBlock{Type: "image", Source: src})
+			case content.Document:
+				blocks = append(blocks, anthropicBlock{
+					Type:   "document",
+					Title:  filepath.Base(v.Path),
+					Source: &anthropicSource{Type: "base64", MediaType: v.MediaType, Data: base64.StdEncoding.EncodeToString(v.Data)},
+				})
+			case content.ToolCall:
+				input := json.RawMessage(v.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: v.ID, Name: v.Name, Input: input})
+			case content.ToolResult:
+				blocks = append(blocks, anthropicBlock{Type: "tool_result", ToolUseID: v.ToolCallID, Content: jsonString(v.Content), IsError: v.IsError})
+			}
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+
+		if n := len(t.Messages); n > 0 && t.Messages[n-1].Role == r {
+			t.Messages[n-1].Content = append(t.Messages[n-1].Content, blocks...)
+			continue
+		}
+		t.Messages = append(t.Messages, anthropicMessage{Role: r, Content: blocks})
+	}
+	return t
+}
+
+// fromAnthropic converts an Anthropic transcript to messages. Tool results
+// are split out of user messages into tool-role messages.
+func fromAnthropic(t anthropicTranscript) ([]message.Message, error) {
+	var msgs []message.Message
+	if t.System != "" {
+		msgs = append(msgs, message.NewText("system", role.System, t.System))
+	}
+
+	for i, am := range t.Messages {
+		if am.Role != "user" && am.Role != "assistant" {
+			return nil, fmt.Errorf("sessions: import: message %d: unknown role %q", i, am.Role)
+		}
+
+		var parts, results []content.Part
+		for _, b := range am.Content {
+			switch b.Type {
+			case "text":
+				parts = append(parts, content.Text{Text: b.Text})
+			case "image":
+				if b.Source == nil {
+					continue
+				}
+				if b.Source.Type == "url" {
+					parts = append(parts, content.Image{URL: b.Source.URL})
+					continue
+				}
+				data, err := base64.StdEncoding.DecodeString(b.Source.Data)
+				if err != nil {
+					return nil, fmt.Errorf("sessions: import: message %d: image: %w", i, err)
+				}
+				parts = append(parts, content.Image{Data: data, MediaType: b.Source.MediaType})
+			case "document":
+				if b.Source == nil || b.Source.Type != "base64" {
+					continue
+				}
+				data, err := base64.StdEncoding.DecodeString(b.Source.Data)
+				if err != nil {
+					return nil, fmt.Errorf("sessions: import: message %d: document: %w", i, err)
+				}
+				parts = append(parts, content.Document{Path: b.Title, Data: data, MediaType: b.Source.MediaType})
+			case "tool_use":
+				args := "{}"
+				if len(b.Input) > 0 {
+					args = string(b.Input)
+				}
+				parts = append(parts, content.ToolCall{ID: b.ID, Name: b.Name, Arguments: args})
+			case "tool_result":
+				text, err := anthropicResultText(b.Content)
+				if err != nil {
+					return nil, fmt.Errorf("sessions: import: message %d: tool_result: %w", i, err)
+				}
+				results = append(results, content.ToolResult{ToolCallID: b.ToolUseID, Content: text, IsError: b.IsError})
+			case "thinking":
+				parts = append(parts, content.Reasoning{Text: b.Thinking})
+			}
+		}
+
+		if len(results) > 0 {
+			msgs = append(msgs, message.New("tool", role.Tool, results...))
+		}
+		if len(parts) > 0 {
+			r := role.Role(am.Role)
+			msgs = append(msgs, message.New(r.String(), r, parts...))
+		}
+	}
+	return msgs, nil
+}
+
+// anthropicResultText flattens tool_result content, which is either a string
+// or an array of text blocks.
+func anthropicResultText(raw json.RawMessage) (string, error) {
+	if isNullJSON(raw) {
+		return "", nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var blocks []anthropicBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return "", err
+	}
+	texts := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		if b.Type == "text" {
+			texts = append(texts, b.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// --- helpers ---
+
+func jsonString(s string) json.RawMessage {
+	b, _ := json.Marshal(s)
+	return b
+}
+
+func isNullJSON(raw json.RawMessage) bool {
+	raw = bytes.TrimSpace(raw)
+	return len(raw) == 0 || bytes.Equal(raw, []byte("null"))
+}
+
+func dataURI(mediaType string, data []byte) string {
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// imageURL returns the image's URL, or a data URI for embedded bytes.
+func imageURL(img content.Image) string {
+	if len(img.Data) > 0 {
+		return dataURI(img.MediaType, img.Data)
+	}
+	return img.URL
+}
+
+// parseDataURI decodes a base64 data URI.
+func parseDataURI(uri string) (mediaType string, data []byte, ok bool) {
+	rest, found := strings.CutPrefix(uri, "data:")
+	if !found {
+		return "", nil, false
+	}
+	meta, payload, found := strings.Cut(rest, ",")
+	if !found {
+		return "", nil, false
+	}
+	mediaType, isBase64 := strings.CutSuffix(meta, ";base64")
+	if !isBase64 {
+		return "", nil, false
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", nil, false
+	}
+	return mediaType, data, true
+}
+
+// imageFromURL decodes data URIs into image bytes and keeps other URLs as is.
+func imageFromURL(url string) content.Image {
+	if mediaType, data, ok := parseDataURI(url); ok {
+		return content.Image{Data: data, MediaType: mediaType}
+	}
+	return content.Image{URL: url}
+}
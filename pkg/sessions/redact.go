@@ -0,0 +1,70 @@
+package sessions
+
+import (
+	"regexp"
+
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+)
+
+// redactedPlaceholder replaces every secret found by Redact.
+const redactedPlaceholder = "[REDACTED]"
+
+// secretPatterns match credentials that commonly end up in transcripts. The
+// first capture group, when present, is kept so that "api_key=..." stays
+// readable as "api_key=[REDACTED]".
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`),                                      // OpenAI, Anthropic
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),                                // AWS access key IDs
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{30,}`),                                 // GitHub tokens
+	regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{20,}`),                               // GitHub fine-grained tokens
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),                               // Slack tokens
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`),                                      // Google API keys
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`), // JWTs
+	regexp.MustCompile(`(?i)(\bbearer\s+)[A-Za-z0-9._~+/-]{16,}=*`),
+	regexp.MustCompile(`(?i)((?:api[_-]?key|secret(?:[_-]?key)?|password|passwd|token|access[_-]?key)["']?\s*[:=]\s*["']?)[^\s"',;]{8,}`),
+}
+
+// Redact replaces API keys, tokens, private keys and password-like
+// assignments in s with "[REDACTED]".
+func Redact(s string) string {
+	for _, re := range secretPatterns {
+		if re.NumSubexp() > 0 {
+			s = re.ReplaceAllString(s, "${1}"+redactedPlaceholder)
+		} else {
+			s = re.ReplaceAllLiteralString(s, redactedPlaceholder)
+		}
+	}
+	return s
+}
+
+// RedactMessages returns copies of msgs with Redact applied to text, tool
+// call arguments, tool results and reasoning. The input is not modified.
+func RedactMessages(msgs []message.Message) []message.Message {
+	out := make([]message.Message, len(msgs))
+	for i, m := range msgs {
+		parts := make([]content.Part, len(m.Parts))
+		for j, p := range m.Parts {
+			switch v := p.(type) {
+			case content.Text:
+				v.Text = Redact(v.Text)
+				parts[j] = v
+			case content.ToolCall:
+				v.Arguments = Redact(v.Arguments)
+				parts[j] = v
+			case content.ToolResult:
+				v.Content = Redact(v.Content)
+				parts[j] = v
+			case content.Reasoning:
+				v.Text = Redact(v.Text)
+				parts[j] = v
+			default:
+				parts[j] = p
+			}
+		}
+		m.Parts = parts
+		out[i] = m
+	}
+	return out
+}
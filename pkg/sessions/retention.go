@@ -0,0 +1,150 @@
+package sessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy bounds how long sessions and their attachments are kept.
+// Zero fields do not prune.
+type RetentionPolicy struct {
+	MaxAge           time.Duration // Delete sessions not updated within MaxAge.
+	MaxSessions      int           // Keep only the most recently updated MaxSessions.
+	AttachmentMaxAge time.Duration // Remove attachments of sessions not updated within AttachmentMaxAge; their messages are kept.
+}
+
+// IsZero reports whether the policy prunes nothing.
+func (p RetentionPolicy) IsZero() bool {
+	return p.MaxAge <= 0 && p.MaxSessions <= 0 && p.AttachmentMaxAge <= 0
+}
+
+// PruneReport describes what Prune removed (or would remove on a dry run).
+type PruneReport struct {
+	Deleted         []SessionInfo // Sessions deleted.
+	AttachmentsFrom []SessionInfo // Kept sessions whose attachments were removed.
+	FreedBytes      int64         // Bytes of session and attachment files removed.
+}
+
+// Prune applies p relative to now. With dryRun set nothing is changed but
+// the report is the same. Removed attachments are replaced in the messages by
+// a short note so the session still loads cleanly.
+func (s *Store) Prune(p RetentionPolicy, now time.Time, dryRun bool) (PruneReport, error) {
+	var report PruneReport
+	if p.IsZero() {
+		return report, nil
+	}
+
+	infos, err := s.List()
+	if err != nil {
+		return report, err
+	}
+
+	for i, info := range infos {
+		expired := p.MaxAge > 0 && now.Sub(info.UpdatedAt) > p.MaxAge
+		overflow := p.MaxSessions > 0 && i >= p.MaxSessions
+		if expired || overflow {
+			report.FreedBytes += dirSize(s.sessionDir(info.ID))
+			report.Deleted = append(report.Deleted, info)
+			if !dryRun {
+				if err := s.Delete(info.ID); err != nil {
+					return report, err
+				}
+			}
+			continue
+		}
+
+		if p.AttachmentMaxAge <= 0 || now.Sub(info.UpdatedAt) <= p.AttachmentMaxAge {
+			continue
+		}
+		size := dirSize(s.attachmentsDir(info.ID))
+		if size == 0 {
+			continue
+		}
+		report.FreedBytes += size
+		report.AttachmentsFrom = append(report.AttachmentsFrom, info)
+		if !dryRun {
+			if err := s.dropAttachments(info.ID); err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, nil
+}
+
+// dropAttachments replaces attachment-backed parts with a text note and
+// removes the attachments directory.
+func (s *Store) dropAttachments(id string) error {
+	data, err := os.ReadFile(s.messagesPath(id)) //nolint:gosec // path from trusted dir + ID
+	if err != nil {
+		return fmt.Errorf("sessions: prune attachments: %w", err)
+	}
+	var jmsgs []jsonMessage
+	if err := json.Unmarshal(data, &jmsgs); err != nil {
+		return fmt.Errorf("sessions: prune attachments: %w", err)
+	}
+
+	for i := range jmsgs {
+		for j, jp := range jmsgs[i].Parts {
+			if jp.AttachmentRef == "" {
+				continue
+			}
+			name := jp.Kind
+			if jp.URL != "" {
+				name += " " + filepath.Base(jp.URL)
+			}
+			jmsgs[i].Parts[j] = jsonPart{Kind: "text", Text: "[" + name + " removed by the session retention policy]"}
+		}
+	}
+
+	out, err := json.Marshal(jmsgs)
+	if err != nil {
+		return fmt.Errorf("sessions: prune attachments: %w", err)
+	}
+	if err := atomicWrite(s.sessionDir(id), s.messagesPath(id), out); err != nil {
+		return fmt.Errorf("sessions: prune attachments: %w", err)
+	}
+	if err := os.RemoveAll(s.attachmentsDir(id)); err != nil {
+		return fmt.Errorf("sessions: prune attachments: %w", err)
+	}
+	return nil
+}
+
+// dirSize returns the total size of the regular files under dir.
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil //nolint:nilerr // unreadable entries are skipped.
+		}
+		if fi, err := d.Info(); err == nil {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// ParseAge parses a retention age: a Go duration ("36h") or a whole number
+// of days ("30d") or weeks ("2w").
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.Atoi(n)
+			if err != nil || v < 0 {
+				return 0, fmt.Errorf("sessions: invalid age %q", s)
+			}
+			return time.Duration(v) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("sessions: invalid age %q", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,105 @@
+package sessions
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+)
+
+func TestStore_Prune(t *testing.T) {
+	store := New(t.TempDir())
+	now := time.Now()
+
+	save := func(id string, age time.Duration, msgs ...message.Message) {
+		info := testInfo(id)
+		info.UpdatedAt = now.Add(-age)
+		require.NoError(t, store.Save(info, msgs))
+	}
+	withImage := message.New("user", role.User,
+		content.Text{Text: "see"},
+		content.Image{Data: []byte("png"), MediaType: "image/png"},
+	)
+	save("fresh", time.Hour, withImage)
+	save("week", 8*24*time.Hour, withImage)
+	save("ancient", 90*24*time.Hour, testMessages()...)
+
+	policy := RetentionPolicy{MaxAge: 30 * 24 * time.Hour, AttachmentMaxAge: 7 * 24 * time.Hour}
+
+	dry, err := store.Prune(policy, now, true)
+	require.NoError(t, err)
+	require.Len(t, dry.Deleted, 1)
+	assert.Equal(t, "ancient", dry.Deleted[0].ID)
+	require.Len(t, dry.AttachmentsFrom, 1)
+	assert.Equal(t, "week", dry.AttachmentsFrom[0].ID)
+	assert.Positive(t, dry.FreedBytes)
+	_, _, err = store.Load("ancient")
+	require.NoError(t, err, "dry run changes nothing")
+
+	report, err := store.Prune(policy, now, false)
+	require.NoError(t, err)
+	assert.Equal(t, dry, report)
+
+	_, _, err = store.Load("ancient")
+	require.Error(t, err)
+
+	_, msgs, err := store.Load("week")
+	require.NoError(t, err)
+	assert.Equal(t, []content.Part{
+		content.Text{Text: "see"},
+		content.Text{Text: "[image removed by the session retention policy]"},
+	}, msgs[0].Parts)
+	_, err = os.Stat(store.attachmentsDir("week"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, msgs, err = store.Load("fresh")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("png"), msgs[0].Parts[1].(content.Image).Data)
+}
+
+func TestStore_Prune_MaxSessions(t *testing.T) {
+	store := New(t.TempDir())
+	now := time.Now()
+	for i, id := range []string{"a", "b", "c"} {
+		info := testInfo(id)
+		info.UpdatedAt = now.Add(-time.Duration(i) * time.Hour)
+		require.NoError(t, store.Save(info, testMessages()))
+	}
+
+	report, err := store.Prune(RetentionPolicy{MaxSessions: 2}, now, false)
+	require.NoError(t, err)
+	require.Len(t, report.Deleted, 1)
+	assert.Equal(t, "c", report.Deleted[0].ID)
+
+	report, err = store.Prune(RetentionPolicy{}, now, false)
+	require.NoError(t, err)
+	assert.Empty(t, report.Deleted)
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"30d", 30 * 24 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"36h", 36 * time.Hour},
+		{" 90m ", 90 * time.Minute},
+	}
+	for _, tt := range tests {
+		got, err := ParseAge(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	for _, bad := range []string{"", "d", "-1d", "soon", "-5h"} {
+		_, err := ParseAge(bad)
+		assert.Error(t, err, bad)
+	}
+}
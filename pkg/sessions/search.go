@@ -0,0 +1,117 @@
+package sessions
+
+import (
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/role"
+)
+
+const (
+	// maxSearchMatches caps the matches reported per session.
+	maxSearchMatches = 3
+	// snippetContext is the number of bytes shown on each side of a match.
+	snippetContext = 40
+)
+
+// SearchHit is a session whose messages match a search query.
+type SearchHit struct {
+	Info    SessionInfo
+	Matches []SearchMatch // At most three, in message order.
+}
+
+// SearchMatch locates one match within a session.
+type SearchMatch struct {
+	Message int       // Index of the message in the session.
+	Role    role.Role // Role of that message.
+	Snippet string    // Text around the match on a single line.
+}
+
+// Search returns the sessions whose message text, tool call arguments or
+// tool results contain query (case-insensitive), newest first. opts filter
+// the sessions searched and paginate the hits.
+func (s *Store) Search(query string, opts ListOpts) ([]SearchHit, error) {
+	filter := opts
+	filter.Limit, filter.Offset = 0, 0
+	infos, err := s.List(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	q := strings.ToLower(query)
+	var hits []SearchHit
+	for _, info := range infos {
+		data, err := os.ReadFile(s.messagesPath(info.ID)) //nolint:gosec // path from trusted dir + ID
+		if err != nil {
+			continue
+		}
+		// Attachments are not needed to search text.
+		msgs, err := UnmarshalMessages(data)
+		if err != nil {
+			continue
+		}
+
+		var matches []SearchMatch
+	scan:
+		for i, m := range msgs {
+			for _, p := range m.Parts {
+				text := searchableText(p)
+				idx := strings.Index(strings.ToLower(text), q)
+				if idx < 0 {
+					continue
+				}
+				matches = append(matches, SearchMatch{Message: i, Role: m.Role, Snippet: snippet(text, idx, len(query))})
+				if len(matches) == maxSearchMatches {
+					break scan
+				}
+			}
+		}
+		if len(matches) > 0 {
+			hits = append(hits, SearchHit{Info: info, Matches: matches})
+		}
+	}
+
+	return paginate(hits, opts), nil
+}
+
+// searchableText returns the text of a part that Search looks at.
+func searchableText(p content.Part) string {
+	switch v := p.(type) {
+	case content.Text:
+		return v.Text
+	case content.ToolCall:
+		return v.Name + " " + v.Arguments
+	case content.ToolResult:
+		return v.Content
+	case content.Reasoning:
+		return v.Text
+	default:
+		return ""
+	}
+}
+
+// snippet returns the text around text[idx:idx+n] on one line, with
+// ellipses where it was cut.
+func snippet(text string, idx, n int) string {
+	// idx comes from the lower-cased text, whose length can differ.
+	idx = min(idx, len(text))
+	start := max(0, idx-snippetContext)
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	end := min(len(text), idx+n+snippetContext)
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+
+	s := strings.Join(strings.Fields(text[start:end]), " ")
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(text) {
+		s += "…"
+	}
+	return s
+}
@@ -0,0 +1,56 @@
+package sessions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/germanamz/shelly/pkg/chats/content"
+	"github.com/germanamz/shelly/pkg/chats/message"
+	"github.com/germanamz/shelly/pkg/chats/role"
+)
+
+func TestStore_Search(t *testing.T) {
+	store := New(t.TempDir())
+
+	older := testInfo("older")
+	older.UpdatedAt = older.UpdatedAt.Add(-time.Hour)
+	require.NoError(t, store.Save(older, []message.Message{
+		message.NewText("user", role.User, "Why does the Parser panic on empty input?"),
+	}))
+	require.NoError(t, store.Save(testInfo("newer"), []message.Message{
+		message.New("bot", role.Assistant, content.ToolCall{ID: "c1", Name: "grep", Arguments: `{"pattern":"parser"}`}),
+		message.New("tool", role.Tool, content.ToolResult{ToolCallID: "c1", Content: "parser.go:12"}),
+	}))
+	require.NoError(t, store.Save(testInfo("other"), testMessages()))
+
+	hits, err := store.Search("PARSER", ListOpts{})
+	require.NoError(t, err)
+	require.Len(t, hits, 2)
+
+	assert.Equal(t, "newer", hits[0].Info.ID)
+	assert.Equal(t, []SearchMatch{
+		{Message: 0, Role: role.Assistant, Snippet: `grep {"pattern":"parser"}`},
+		{Message: 1, Role: role.Tool, Snippet: "parser.go:12"},
+	}, hits[0].Matches)
+	assert.Equal(t, "older", hits[1].Info.ID)
+
+	hits, err = store.Search("parser", ListOpts{Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, "older", hits[0].Info.ID)
+}
+
+func TestSnippet(t *testing.T) {
+	text := "The quick brown fox jumps over the lazy dog and then\nruns far, far away into the forest where nobody can find it."
+	idx := 56 // "runs"
+
+	s := snippet(text, idx, 4)
+	assert.True(t, len(s) > 0 && s[0] != 'T', "cut at the start")
+	assert.Contains(t, s, "then runs far", "newlines are collapsed")
+	assert.Equal(t, "…", s[len(s)-len("…"):])
+
+	assert.Equal(t, "héllo wörld", snippet("héllo wörld", 7, 5))
+}
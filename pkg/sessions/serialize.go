@@ -70,10 +70,26 @@ func partToJSONWithAttachments(p content.Part, w AttachmentWriter) jsonPart {
 			jp.Data = v.Data
 		}
 		return jp
+	case content.Audio:
+		jp := jsonPart{Kind: "audio", URL: v.Path, MediaType: v.MediaType}
+		if len(v.Data) > 0 && w != nil {
+			ref, err := w.WriteAttachment(v.Data, v.MediaType)
+			if err != nil {
+				slog.Warn("sessions: failed to write audio attachment, falling back to inline", "err", err)
+				jp.Data = v.Data
+			} else {
+				jp.AttachmentRef = ref
+			}
+		} else {
+			jp.Data = v.Data
+		}
+		return jp
 	case content.ToolCall:
 		return jsonPart{Kind: "tool_call", ID: v.ID, Name: v.Name, Arguments: v.Arguments, Metadata: v.Metadata}
 	case content.ToolResult:
 		return jsonPart{Kind: "tool_result", ToolCallID: v.ToolCallID, Content: v.Content, IsError: v.IsError}
+	case content.Reasoning:
+		return jsonPart{Kind: "reasoning", Text: v.Text, Metadata: v.Metadata}
 	default:
 		slog.Warn("sessions: skipping unknown part kind", "kind", p.PartKind())
 		return jsonPart{Kind: p.PartKind()}
@@ -120,10 +136,28 @@ func jsonToPartWithAttachments(jp jsonPart, r AttachmentReader) (content.Part, b
 			doc.Data = jp.Data
 		}
 		return doc, true
+	case "audio":
+		audio := content.Audio{Path: jp.URL, MediaType: jp.MediaType}
+		if jp.AttachmentRef != "" && r != nil {
+			data, mediaType, err := r.ReadAttachment(jp.AttachmentRef)
+			if err != nil {
+				slog.Warn("sessions: failed to read audio attachment", "ref", jp.AttachmentRef, "err", err)
+			} else {
+				audio.Data = data
+				if mediaType != "" {
+					audio.MediaType = mediaType
+				}
+			}
+		} else {
+			audio.Data = jp.Data
+		}
+		return audio, true
 	case "tool_call":
 		return content.ToolCall{ID: jp.ID, Name: jp.Name, Arguments: jp.Arguments, Metadata: jp.Metadata}, true
 	case "tool_result":
 		return content.ToolResult{ToolCallID: jp.ToolCallID, Content: jp.Content, IsError: jp.IsError}, true
+	case "reasoning":
+		return content.Reasoning{Text: jp.Text, Metadata: jp.Metadata}, true
 	default:
 		slog.Warn("sessions: skipping unknown part kind on unmarshal", "kind", jp.Kind)
 		return nil, false
@@ -130,6 +130,26 @@ func TestMarshalUnmarshal_ToolResultWithError(t *testing.T) {
 	assert.Equal(t, "failed", tr.Content)
 }
 
+func TestMarshalUnmarshal_Reasoning(t *testing.T) {
+	msgs := []message.Message{
+		message.New("bot", role.Assistant,
+			content.Reasoning{Text: "checking the docs", Metadata: map[string]string{"id": "rs_1"}},
+			content.Text{Text: "done"},
+		),
+	}
+
+	data, err := MarshalMessages(msgs)
+	require.NoError(t, err)
+
+	got, err := UnmarshalMessages(data)
+	require.NoError(t, err)
+
+	require.Len(t, got[0].Parts, 2)
+	r := got[0].Parts[0].(content.Reasoning)
+	assert.Equal(t, "checking the docs", r.Text)
+	assert.Equal(t, "rs_1", r.Metadata["id"])
+}
+
 func TestMarshalWithAttachments_ExtractsImageData(t *testing.T) {
 	dir := filepath.Join(t.TempDir(), "attachments")
 	store := NewFileAttachmentStore(dir)
@@ -252,6 +272,48 @@ func TestMarshalWithAttachments_Document_RoundTrip(t *testing.T) {
 	assert.Equal(t, "/tmp/report.pdf", doc.Path)
 }
 
+func TestMarshalWithAttachments_Audio_RoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "attachments")
+	store := NewFileAttachmentStore(dir)
+
+	wavData := []byte("RIFF....WAVEfmt fake")
+	msgs := []message.Message{
+		{
+			Sender: "user",
+			Role:   role.User,
+			Parts: []content.Part{
+				content.Audio{Path: "/tmp/note.wav", Data: wavData, MediaType: "audio/wav"},
+			},
+		},
+	}
+
+	data, err := MarshalMessagesWithAttachments(msgs, store)
+	require.NoError(t, err)
+
+	var jmsgs []jsonMessage
+	require.NoError(t, json.Unmarshal(data, &jmsgs))
+	audioPart := jmsgs[0].Parts[0]
+	assert.Equal(t, "audio", audioPart.Kind)
+	assert.Equal(t, ".wav", filepath.Ext(audioPart.AttachmentRef))
+	assert.Empty(t, audioPart.Data)
+
+	got, err := UnmarshalMessagesWithAttachments(data, store)
+	require.NoError(t, err)
+	require.Len(t, got[0].Parts, 1)
+
+	audio := got[0].Parts[0].(content.Audio)
+	assert.Equal(t, wavData, audio.Data)
+	assert.Equal(t, "audio/wav", audio.MediaType)
+	assert.Equal(t, "/tmp/note.wav", audio.Path)
+
+	// Without a store the data stays inline.
+	inline, err := MarshalMessages(msgs)
+	require.NoError(t, err)
+	got, err = UnmarshalMessages(inline)
+	require.NoError(t, err)
+	assert.Equal(t, msgs[0].Parts[0], got[0].Parts[0])
+}
+
 func TestMarshalUnmarshal_MixedTextImageDocument(t *testing.T) {
 	dir := filepath.Join(t.TempDir(), "attachments")
 	store := NewFileAttachmentStore(dir)
@@ -1,11 +1,14 @@
 package sessions
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -15,25 +18,118 @@ import (
 
 // ProviderMeta holds provider identification for a session.
 type ProviderMeta struct {
+	Name  string `json:"name,omitempty"` // Provider config name; empty for sessions saved before model switching.
 	Kind  string `json:"kind"`
 	Model string `json:"model"`
 }
 
+// Switch records a mid-session change of agent or model.
+type Switch struct {
+	At       time.Time    `json:"at"`
+	Agent    string       `json:"agent"`
+	Provider ProviderMeta `json:"provider"`
+	MsgIndex int          `json:"msg_index"` // Chat length at the switch; later messages come from the new agent and model.
+}
+
+// AgentUsage is the cumulative token usage and cost of one agent instance in
+// a session's delegation tree, per provider.
+type AgentUsage struct {
+	Agent               string       `json:"agent"`            // Agent instance name.
+	Parent              string       `json:"parent,omitempty"` // Delegating agent instance; empty for the session agent.
+	Provider            ProviderMeta `json:"provider"`
+	Calls               int          `json:"calls"`
+	InputTokens         int          `json:"input_tokens"`
+	OutputTokens        int          `json:"output_tokens"`
+	CacheCreationTokens int          `json:"cache_creation_tokens,omitempty"`
+	CacheReadTokens     int          `json:"cache_read_tokens,omitempty"`
+	CostUSD             float64      `json:"cost_usd"` // Zero when the model has no known pricing.
+}
+
+// QueueMode says when a queued prompt is delivered to its session.
+type QueueMode string
+
+const (
+	QueueAfterTurn QueueMode = "after_turn" // Sent as the next prompt once the current turn completes.
+	QueueInterrupt QueueMode = "interrupt"  // Injected into the running agent at its next iteration.
+)
+
+// QueuedPrompt is a follow-up prompt waiting to be sent to a session. Only
+// the text is persisted; attachments of queued prompts are not.
+type QueuedPrompt struct {
+	ID        string    `json:"id"`
+	Text      string    `json:"text"`
+	Mode      QueueMode `json:"mode"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // SessionInfo contains metadata about a persisted session.
 type SessionInfo struct {
-	ID        string       `json:"id"`
-	Agent     string       `json:"agent"`
-	Provider  ProviderMeta `json:"provider"`
-	CreatedAt time.Time    `json:"created_at"`
-	UpdatedAt time.Time    `json:"updated_at"`
-	Preview   string       `json:"preview"`
-	MsgCount  int          `json:"msg_count"`
+	ID        string         `json:"id"`
+	Agent     string         `json:"agent"`
+	Provider  ProviderMeta   `json:"provider"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	Preview   string         `json:"preview"`
+	MsgCount  int            `json:"msg_count"`
+	Trigger   string         `json:"trigger,omitempty"`  // Daemon trigger that started the session ("" = interactive).
+	Title     string         `json:"title,omitempty"`    // User-set title (see Update).
+	Tags      []string       `json:"tags,omitempty"`     // User-set tags (see Update).
+	Switches  []Switch       `json:"switches,omitempty"` // Agent and model switches, oldest first. Agent and Provider hold the latest.
+	Usage     []AgentUsage   `json:"usage,omitempty"`    // Cumulative usage per agent instance and provider.
+	Queue     []QueuedPrompt `json:"queue,omitempty"`    // Follow-up prompts not yet sent, in delivery order.
+}
+
+// HasTag reports whether the session is tagged with tag.
+func (i SessionInfo) HasTag(tag string) bool {
+	return slices.Contains(i.Tags, tag)
 }
 
-// ListOpts controls pagination for List().
+// ListOpts filters and paginates List() and Search(). Zero-value fields do
+// not filter.
 type ListOpts struct {
 	Limit  int // Maximum number of results (0 = unlimited).
 	Offset int // Number of results to skip.
+
+	Agent    string    // Only sessions of this agent.
+	Provider string    // Only sessions whose provider kind, model or "kind/model" equals this.
+	Tag      string    // Only sessions with this tag.
+	Since    time.Time // Only sessions updated at or after Since.
+	Until    time.Time // Only sessions updated before Until.
+}
+
+// matches reports whether info passes the filters in o.
+func (o ListOpts) matches(info SessionInfo) bool {
+	if o.Agent != "" && info.Agent != o.Agent {
+		return false
+	}
+	if o.Provider != "" && o.Provider != info.Provider.Kind && o.Provider != info.Provider.Model &&
+		o.Provider != info.Provider.Kind+"/"+info.Provider.Model {
+		return false
+	}
+	if o.Tag != "" && !info.HasTag(o.Tag) {
+		return false
+	}
+	if !o.Since.IsZero() && info.UpdatedAt.Before(o.Since) {
+		return false
+	}
+	if !o.Until.IsZero() && !info.UpdatedAt.Before(o.Until) {
+		return false
+	}
+	return true
+}
+
+// paginate applies o.Offset and o.Limit to items.
+func paginate[T any](items []T, o ListOpts) []T {
+	if o.Offset > 0 {
+		if o.Offset >= len(items) {
+			return nil
+		}
+		items = items[o.Offset:]
+	}
+	if o.Limit > 0 && o.Limit < len(items) {
+		items = items[:o.Limit]
+	}
+	return items
 }
 
 // StoreOption configures optional Store behavior.
@@ -48,6 +144,13 @@ func WithMaxAttachmentSize(n int64) StoreOption {
 	}
 }
 
+// NewID returns a random 16-character hex session ID.
+func NewID() string {
+	var buf [8]byte
+	_, _ = rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
+
 // Store manages session files in a directory.
 type Store struct {
 	dir               string
@@ -82,12 +185,20 @@ func (s *Store) attachmentsDir(id string) string {
 // Save writes a session to disk atomically using the v2 directory layout.
 // Binary data (e.g. images) is extracted to the attachments/ subdirectory.
 // After writing, orphan attachments no longer referenced by any message are removed.
+// When info has no Title and no Tags, those of the saved session are kept, so
+// auto-saves do not undo edits made with Update.
 func (s *Store) Save(info SessionInfo, msgs []message.Message) error {
 	sessDir := s.sessionDir(info.ID)
 	if err := os.MkdirAll(sessDir, 0o750); err != nil {
 		return fmt.Errorf("sessions: create session dir: %w", err)
 	}
 
+	if info.Title == "" && info.Tags == nil {
+		if prev, err := s.readMeta(info.ID); err == nil {
+			info.Title, info.Tags = prev.Title, prev.Tags
+		}
+	}
+
 	attachStore := NewFileAttachmentStore(s.attachmentsDir(info.ID))
 	var w AttachmentWriter = attachStore
 	if s.maxAttachmentSize > 0 {
@@ -122,13 +233,9 @@ func (s *Store) Save(info SessionInfo, msgs []message.Message) error {
 
 // Load reads a session from disk by ID (v2 directory layout only).
 func (s *Store) Load(id string) (SessionInfo, []message.Message, error) {
-	metaData, err := os.ReadFile(s.metaPath(id)) //nolint:gosec // path from trusted dir + ID
+	info, err := s.readMeta(id)
 	if err != nil {
-		return SessionInfo{}, nil, fmt.Errorf("sessions: read meta: %w", err)
-	}
-	var info SessionInfo
-	if err := json.Unmarshal(metaData, &info); err != nil {
-		return SessionInfo{}, nil, fmt.Errorf("sessions: unmarshal meta: %w", err)
+		return SessionInfo{}, nil, err
 	}
 
 	msgData, err := os.ReadFile(s.messagesPath(id)) //nolint:gosec // path from trusted dir + ID
@@ -145,8 +252,41 @@ func (s *Store) Load(id string) (SessionInfo, []message.Message, error) {
 	return info, msgs, nil
 }
 
+// readMeta reads a session's meta.json.
+func (s *Store) readMeta(id string) (SessionInfo, error) {
+	metaData, err := os.ReadFile(s.metaPath(id)) //nolint:gosec // path from trusted dir + ID
+	if err != nil {
+		return SessionInfo{}, fmt.Errorf("sessions: read meta: %w", err)
+	}
+	var info SessionInfo
+	if err := json.Unmarshal(metaData, &info); err != nil {
+		return SessionInfo{}, fmt.Errorf("sessions: unmarshal meta: %w", err)
+	}
+	return info, nil
+}
+
+// Update applies fn to a session's metadata and writes it back. It is how
+// the user-editable Title and Tags are changed; the ID cannot be.
+func (s *Store) Update(id string, fn func(*SessionInfo)) (SessionInfo, error) {
+	info, err := s.readMeta(id)
+	if err != nil {
+		return SessionInfo{}, err
+	}
+	fn(&info)
+	info.ID = id
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return SessionInfo{}, fmt.Errorf("sessions: marshal meta: %w", err)
+	}
+	if err := atomicWrite(s.sessionDir(id), s.metaPath(id), data); err != nil {
+		return SessionInfo{}, fmt.Errorf("sessions: write meta: %w", err)
+	}
+	return info, nil
+}
+
 // List returns metadata for all sessions, sorted by UpdatedAt descending.
-// Use opts to paginate; a zero-value ListOpts returns all sessions.
+// Use opts to filter and paginate; a zero-value ListOpts returns all sessions.
 func (s *Store) List(opts ...ListOpts) ([]SessionInfo, error) {
 	var o ListOpts
 	if len(opts) > 0 {
@@ -176,25 +316,16 @@ func (s *Store) List(opts ...ListOpts) ([]SessionInfo, error) {
 		if err := json.Unmarshal(data, &info); err != nil {
 			continue
 		}
-		sessions = append(sessions, info)
+		if o.matches(info) {
+			sessions = append(sessions, info)
+		}
 	}
 
 	sort.Slice(sessions, func(i, j int) bool {
 		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
 	})
 
-	// Apply pagination.
-	if o.Offset > 0 {
-		if o.Offset >= len(sessions) {
-			return nil, nil
-		}
-		sessions = sessions[o.Offset:]
-	}
-	if o.Limit > 0 && o.Limit < len(sessions) {
-		sessions = sessions[:o.Limit]
-	}
-
-	return sessions, nil
+	return paginate(sessions, o), nil
 }
 
 // Delete removes a session by ID.
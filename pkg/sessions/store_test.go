@@ -166,6 +166,67 @@ func TestStore_List_Pagination(t *testing.T) {
 	assert.Empty(t, list)
 }
 
+func TestStore_List_Filters(t *testing.T) {
+	store := New(t.TempDir())
+	now := time.Now().Truncate(time.Millisecond)
+
+	a := testInfo("a")
+	a.UpdatedAt = now.Add(-48 * time.Hour)
+	a.Tags = []string{"bug"}
+	b := testInfo("b")
+	b.Agent = "planner"
+	b.Provider = ProviderMeta{Kind: "openai", Model: "gpt-4.1"}
+	for _, info := range []SessionInfo{a, b} {
+		require.NoError(t, store.Save(info, testMessages()))
+	}
+
+	ids := func(opts ListOpts) []string {
+		infos, err := store.List(opts)
+		require.NoError(t, err)
+		var out []string
+		for _, i := range infos {
+			out = append(out, i.ID)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"b"}, ids(ListOpts{Agent: "planner"}))
+	assert.Equal(t, []string{"b"}, ids(ListOpts{Provider: "openai"}))
+	assert.Equal(t, []string{"b"}, ids(ListOpts{Provider: "openai/gpt-4.1"}))
+	assert.Equal(t, []string{"a"}, ids(ListOpts{Provider: "claude"}))
+	assert.Equal(t, []string{"a"}, ids(ListOpts{Tag: "bug"}))
+	assert.Equal(t, []string{"b"}, ids(ListOpts{Since: now.Add(-time.Hour)}))
+	assert.Equal(t, []string{"a"}, ids(ListOpts{Until: now.Add(-time.Hour)}))
+	assert.Empty(t, ids(ListOpts{Agent: "planner", Tag: "bug"}))
+}
+
+func TestStore_Update_KeptBySave(t *testing.T) {
+	store := New(t.TempDir())
+	info := testInfo("sess-1")
+	require.NoError(t, store.Save(info, testMessages()))
+
+	updated, err := store.Update("sess-1", func(i *SessionInfo) {
+		i.Title = "Flaky test"
+		i.Tags = append(i.Tags, "ci")
+		i.ID = "ignored"
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "sess-1", updated.ID)
+
+	// An auto-save without title or tags keeps the user's edits.
+	info.MsgCount = 3
+	require.NoError(t, store.Save(info, testMessages()))
+
+	loaded, _, err := store.Load("sess-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Flaky test", loaded.Title)
+	assert.Equal(t, []string{"ci"}, loaded.Tags)
+	assert.Equal(t, 3, loaded.MsgCount)
+
+	_, err = store.Update("missing", func(*SessionInfo) {})
+	require.Error(t, err)
+}
+
 func TestStore_Delete(t *testing.T) {
 	store := New(t.TempDir())
 	require.NoError(t, store.Save(testInfo("sess-1"), testMessages()))
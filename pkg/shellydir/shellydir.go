@@ -38,6 +38,9 @@ func (d Dir) ContextPath() string { return filepath.Join(d.root, "context.md") }
 // SkillsDir returns the path to the skills directory.
 func (d Dir) SkillsDir() string { return filepath.Join(d.root, "skills") }
 
+// CommandsDir returns the path to the user-defined slash commands directory.
+func (d Dir) CommandsDir() string { return filepath.Join(d.root, "commands") }
+
 // LocalDir returns the path to the local (gitignored) runtime state directory.
 func (d Dir) LocalDir() string { return filepath.Join(d.root, "local") }
 
@@ -56,9 +59,24 @@ func (d Dir) ReflectionsDir() string { return filepath.Join(d.root, "local", "re
 // SessionsDir returns the path to the sessions directory inside local/.
 func (d Dir) SessionsDir() string { return filepath.Join(d.root, "local", "sessions") }
 
+// SpendDir returns the path to the cost budget ledger directory inside local/.
+func (d Dir) SpendDir() string { return filepath.Join(d.root, "local", "spend") }
+
+// BatchesDir returns the path to the batch run manifests directory inside local/.
+func (d Dir) BatchesDir() string { return filepath.Join(d.root, "local", "batches") }
+
+// TokenizersDir returns the path to the BPE vocabulary directory inside local/.
+func (d Dir) TokenizersDir() string { return filepath.Join(d.root, "local", "tokenizers") }
+
+// MemoryPath returns the path to the long-term memory store inside local/.
+func (d Dir) MemoryPath() string { return filepath.Join(d.root, "local", "memory.json") }
+
 // HistoryPath returns the path to the input history file inside local/.
 func (d Dir) HistoryPath() string { return filepath.Join(d.root, "local", "history") }
 
+// DraftsPath returns the path to the per-session prompt drafts inside local/.
+func (d Dir) DraftsPath() string { return filepath.Join(d.root, "local", "drafts.json") }
+
 // GitignorePath returns the path to the .gitignore file inside .shelly/.
 func (d Dir) GitignorePath() string { return filepath.Join(d.root, ".gitignore") }
 
@@ -16,12 +16,17 @@ func TestDir_PathAccessors(t *testing.T) {
 	assert.Equal(t, "/project/.shelly/config.yaml", d.ConfigPath())
 	assert.Equal(t, "/project/.shelly/context.md", d.ContextPath())
 	assert.Equal(t, "/project/.shelly/skills", d.SkillsDir())
+	assert.Equal(t, "/project/.shelly/commands", d.CommandsDir())
 	assert.Equal(t, "/project/.shelly/local", d.LocalDir())
 	assert.Equal(t, "/project/.shelly/local/permissions.json", d.PermissionsPath())
 	assert.Equal(t, "/project/.shelly/knowledge", d.KnowledgeDir())
 	assert.Equal(t, "/project/.shelly/local/sessions", d.SessionsDir())
+	assert.Equal(t, "/project/.shelly/local/spend", d.SpendDir())
+	assert.Equal(t, "/project/.shelly/local/batches", d.BatchesDir())
 	assert.Equal(t, "/project/.shelly/local/notes", d.NotesDir())
 	assert.Equal(t, "/project/.shelly/local/reflections", d.ReflectionsDir())
+	assert.Equal(t, "/project/.shelly/local/memory.json", d.MemoryPath())
+	assert.Equal(t, "/project/.shelly/local/drafts.json", d.DraftsPath())
 	assert.Equal(t, "/project/.shelly/.gitignore", d.GitignorePath())
 }
 
@@ -0,0 +1,264 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// repairSyntax fixes common JSON syntax mistakes in one pass: trailing commas
+// before a closing bracket, quotes inside strings that do not end them,
+// backslashes that start no valid escape and raw control characters in
+// strings. It returns the repaired input and a description of each kind of
+// repair applied (none when nothing changed).
+func repairSyntax(in []byte) ([]byte, []string) {
+	var (
+		out      = make([]byte, 0, len(in)+8)
+		stack    []byte // open '{' and '[' outside strings
+		inString bool
+		commas   bool
+		quotes   bool
+		slashes  bool
+		controls bool
+	)
+
+	for i := 0; i < len(in); i++ {
+		c := in[i]
+
+		if inString {
+			switch {
+			case c == '\\':
+				if i+1 < len(in) && strings.IndexByte(`"\/bfnrtu`, in[i+1]) >= 0 {
+					out = append(out, c, in[i+1])
+					i++
+					continue
+				}
+				out = append(out, '\\', '\\')
+				slashes = true
+				continue
+			case c == '"':
+				if !closesString(in[i+1:], stack) {
+					out = append(out, '\\', '"')
+					quotes = true
+					continue
+				}
+				inString = false
+			case c < 0x20:
+				out = append(out, escapeControl(c)...)
+				controls = true
+				continue
+			}
+			out = append(out, c)
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			stack = append(stack, c)
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case ',':
+			if rest := skipSpace(in[i+1:]); len(rest) > 0 && (rest[0] == '}' || rest[0] == ']') {
+				commas = true
+				continue
+			}
+		}
+		out = append(out, c)
+	}
+
+	var repairs []string
+	if commas {
+		repairs = append(repairs, "removed trailing commas")
+	}
+	if quotes {
+		repairs = append(repairs, "escaped quotes inside strings")
+	}
+	if slashes {
+		repairs = append(repairs, "escaped stray backslashes inside strings")
+	}
+	if controls {
+		repairs = append(repairs, "escaped control characters inside strings")
+	}
+	return out, repairs
+}
+
+// closesString reports whether a quote followed by rest plausibly ends the
+// string it is in: it must be followed by a colon, a closing bracket, the end
+// of input, or a comma that leads on to another key or value.
+func closesString(rest, stack []byte) bool {
+	rest = skipSpace(rest)
+	if len(rest) == 0 {
+		return true
+	}
+
+	switch rest[0] {
+	case ':', '}', ']':
+		return true
+	case ',':
+		next := skipSpace(rest[1:])
+		if len(next) == 0 {
+			return true
+		}
+		if len(stack) > 0 && stack[len(stack)-1] == '[' {
+			return strings.IndexByte(`"{[]-0123456789tfn`, next[0]) >= 0
+		}
+		return next[0] == '"' || next[0] == '}'
+	default:
+		return false
+	}
+}
+
+func skipSpace(b []byte) []byte {
+	for len(b) > 0 && (b[0] == ' ' || b[0] == '\t' || b[0] == '\n' || b[0] == '\r') {
+		b = b[1:]
+	}
+	return b
+}
+
+func escapeControl(c byte) []byte {
+	switch c {
+	case '\n':
+		return []byte(`\n`)
+	case '\r':
+		return []byte(`\r`)
+	case '\t':
+		return []byte(`\t`)
+	default:
+		return fmt.Appendf(nil, `\u%04x`, c)
+	}
+}
+
+// coerce converts values in v that do not have the type their schema asks for
+// but convert to it without loss, and drops nulls sent for optional
+// properties. It records a description of each change in repairs and returns
+// the (possibly replaced) value.
+func coerce(s map[string]any, v any, ptr string, repairs *[]string) any {
+	if types := schemaTypes(s); len(types) > 0 && !matchesAny(v, types) {
+		for _, t := range types {
+			if c, ok := convert(v, t, s); ok {
+				*repairs = append(*repairs, fmt.Sprintf("%s: converted %s to %s", displayPointer(ptr), jsonType(v), t))
+				v = c
+				break
+			}
+		}
+	}
+
+	switch tv := v.(type) {
+	case map[string]any:
+		props, _ := s["properties"].(map[string]any)
+		required := make(map[string]bool)
+		if req, ok := s["required"].([]any); ok {
+			for _, r := range req {
+				if name, ok := r.(string); ok {
+					required[name] = true
+				}
+			}
+		}
+		for _, k := range sortedKeys(tv) {
+			p := ptr + "/" + escapePointer(k)
+			ps, ok := props[k].(map[string]any)
+			if !ok {
+				ps, ok = s["additionalProperties"].(map[string]any)
+			}
+			if !ok {
+				continue
+			}
+			if types := schemaTypes(ps); tv[k] == nil && !required[k] && len(types) > 0 && !matchesAny(nil, types) {
+				delete(tv, k)
+				*repairs = append(*repairs, p+": removed null from optional property")
+				continue
+			}
+			tv[k] = coerce(ps, tv[k], p, repairs)
+		}
+
+	case []any:
+		if items, ok := s["items"].(map[string]any); ok {
+			for i := range tv {
+				tv[i] = coerce(items, tv[i], ptr+"/"+strconv.Itoa(i), repairs)
+			}
+		}
+	}
+
+	return v
+}
+
+// convert converts v to JSON Schema type t when that loses nothing. s is the
+// schema of v, used for the item type when wrapping a value in an array.
+func convert(v any, t string, s map[string]any) (any, bool) {
+	switch t {
+	case "integer":
+		switch tv := v.(type) {
+		case string:
+			return toInteger(strings.TrimSpace(tv))
+		case json.Number:
+			return toInteger(string(tv))
+		}
+
+	case "number":
+		if str, ok := v.(string); ok {
+			if n, err := decode([]byte(strings.TrimSpace(str))); err == nil {
+				if num, ok := n.(json.Number); ok {
+					return num, true
+				}
+			}
+		}
+
+	case "boolean":
+		if str, ok := v.(string); ok {
+			switch strings.ToLower(strings.TrimSpace(str)) {
+			case "true":
+				return true, true
+			case "false":
+				return false, true
+			}
+		}
+
+	case "string":
+		if n, ok := v.(json.Number); ok {
+			return string(n), true
+		}
+
+	case "array", "object":
+		if str, ok := v.(string); ok {
+			if d, err := decode([]byte(strings.TrimSpace(str))); err == nil && jsonType(d) == t {
+				return d, true
+			}
+		}
+		if t == "array" && v != nil {
+			if _, isArray := v.([]any); !isArray {
+				items, _ := s["items"].(map[string]any)
+				if types := schemaTypes(items); len(types) == 0 || matchesAny(v, types) {
+					return []any{v}, true
+				}
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// toInteger parses s as a number with no fractional part that fits in an
+// int64.
+func toInteger(s string) (any, bool) {
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return json.Number(s), true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+		return nil, false
+	}
+	return json.Number(strconv.FormatInt(int64(f), 10)), true
+}
+
+func displayPointer(ptr string) string {
+	if ptr == "" {
+		return "(root)"
+	}
+	return ptr
+}
@@ -0,0 +1,306 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Violation is one way a value does not match a schema.
+type Violation struct {
+	Pointer string // JSON Pointer (RFC 6901) to the offending value; "" is the whole value.
+	Message string
+}
+
+// String renders the violation as "pointer: message", with "(root)" for the
+// whole value.
+func (v Violation) String() string {
+	return displayPointer(v.Pointer) + ": " + v.Message
+}
+
+// Arguments is the outcome of CheckArguments.
+type Arguments struct {
+	JSON       json.RawMessage // Arguments to pass on: the input, or the repaired input when Repairs is non-empty.
+	Repairs    []string        // Repairs applied, e.g. "/limit: converted string to integer".
+	Violations []Violation     // Violations left after repair; empty when the arguments are valid.
+}
+
+// Validate checks the JSON input against a JSON Schema and returns the
+// violations found, in document order. It understands the keywords Generate
+// emits and a few common ones: type, properties, required,
+// additionalProperties, items and enum. Other keywords are ignored, so the
+// constraints they express always pass. An empty schema accepts any valid
+// JSON; input that is not valid JSON yields a single violation.
+func Validate(schema, input json.RawMessage) []Violation {
+	v, err := decode(input)
+	if err != nil {
+		return []Violation{syntaxViolation(err)}
+	}
+
+	var out []Violation
+	validate(parseSchema(schema), v, "", &out)
+	return out
+}
+
+// CheckArguments validates tool-call arguments against the tool's input
+// schema. Empty or null arguments count as an empty object.
+//
+// With repair, it first fixes the mistakes weaker models commonly make:
+// trailing commas, unescaped quotes, backslashes and control characters in
+// strings (only when the input is not valid JSON), and values of the wrong
+// JSON type that convert without loss — numbers and booleans sent as strings,
+// arrays and objects sent as JSON text, numbers sent for strings and single
+// values sent for arrays. Violations are reported for what remains.
+func CheckArguments(schema, input json.RawMessage, repair bool) Arguments {
+	if t := bytes.TrimSpace(input); len(t) == 0 || string(t) == "null" {
+		input = json.RawMessage("{}")
+	}
+	res := Arguments{JSON: input}
+
+	v, err := decode(input)
+	if err != nil && repair {
+		if fixed, repairs := repairSyntax(input); len(repairs) > 0 {
+			if fv, ferr := decode(fixed); ferr == nil {
+				v, err = fv, nil
+				res.JSON = fixed
+				res.Repairs = repairs
+			}
+		}
+	}
+	if err != nil {
+		res.Violations = []Violation{syntaxViolation(err)}
+		return res
+	}
+
+	s := parseSchema(schema)
+	if repair && s != nil {
+		var repairs []string
+		v = coerce(s, v, "", &repairs)
+		if len(repairs) > 0 {
+			if b, err := encode(v); err == nil {
+				res.JSON = b
+				res.Repairs = append(res.Repairs, repairs...)
+			}
+		}
+	}
+
+	validate(s, v, "", &res.Violations)
+	return res
+}
+
+// decode parses a single JSON value, keeping numbers as json.Number so that
+// integers and their original text are preserved.
+func decode(b []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the top-level value at offset %d", dec.InputOffset())
+	}
+	return v, nil
+}
+
+// encode marshals a decoded value back to compact JSON without HTML escaping.
+func encode(v any) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// parseSchema decodes a schema into a map, or returns nil when it is empty
+// or not a JSON object.
+func parseSchema(schema json.RawMessage) map[string]any {
+	v, err := decode(schema)
+	if err != nil {
+		return nil
+	}
+	s, _ := v.(map[string]any)
+	return s
+}
+
+func syntaxViolation(err error) Violation {
+	msg := "not valid JSON: " + err.Error()
+	var se *json.SyntaxError
+	if errors.As(err, &se) {
+		msg = fmt.Sprintf("not valid JSON: %s (at offset %d)", se.Error(), se.Offset)
+	}
+	return Violation{Message: msg}
+}
+
+func validate(s map[string]any, v any, ptr string, out *[]Violation) {
+	if s == nil {
+		return
+	}
+
+	if types := schemaTypes(s); len(types) > 0 && !matchesAny(v, types) {
+		*out = append(*out, Violation{Pointer: ptr, Message: fmt.Sprintf("expected %s, got %s", strings.Join(types, " or "), describe(v))})
+		return
+	}
+
+	if enum, ok := s["enum"].([]any); ok && !inEnum(v, enum) {
+		*out = append(*out, Violation{Pointer: ptr, Message: "must be one of: " + joinJSON(enum)})
+	}
+
+	switch tv := v.(type) {
+	case map[string]any:
+		props, _ := s["properties"].(map[string]any)
+		if req, ok := s["required"].([]any); ok {
+			for _, r := range req {
+				name, _ := r.(string)
+				if _, present := tv[name]; name != "" && !present {
+					*out = append(*out, Violation{Pointer: ptr + "/" + escapePointer(name), Message: "required property is missing"})
+				}
+			}
+		}
+		for _, k := range sortedKeys(tv) {
+			p := ptr + "/" + escapePointer(k)
+			if ps, ok := props[k].(map[string]any); ok {
+				validate(ps, tv[k], p, out)
+				continue
+			}
+			switch ap := s["additionalProperties"].(type) {
+			case bool:
+				if !ap {
+					*out = append(*out, Violation{Pointer: p, Message: "unknown property"})
+				}
+			case map[string]any:
+				validate(ap, tv[k], p, out)
+			}
+		}
+
+	case []any:
+		if items, ok := s["items"].(map[string]any); ok {
+			for i, item := range tv {
+				validate(items, item, ptr+"/"+strconv.Itoa(i), out)
+			}
+		}
+	}
+}
+
+// schemaTypes returns the types a schema allows ("type" may be a string or
+// a list of strings).
+func schemaTypes(s map[string]any) []string {
+	switch t := s["type"].(type) {
+	case string:
+		return []string{t}
+	case []any:
+		var out []string
+		for _, x := range t {
+			if str, ok := x.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func matchesAny(v any, types []string) bool {
+	for _, t := range types {
+		if matches(v, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether v has the JSON Schema type t. Unknown types match.
+func matches(v any, t string) bool {
+	switch t {
+	case "integer":
+		return jsonType(v) == "integer"
+	case "number":
+		jt := jsonType(v)
+		return jt == "number" || jt == "integer"
+	case "string", "boolean", "object", "array", "null":
+		return jsonType(v) == t
+	default:
+		return true
+	}
+}
+
+// jsonType returns the JSON Schema type of a decoded value. Numbers are
+// integers only when written as one (so that they decode into Go ints).
+func jsonType(v any) string {
+	switch tv := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := strconv.ParseInt(string(tv), 10, 64); err == nil {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return "unknown"
+	}
+}
+
+// describe names the type of v for error messages, quoting short strings.
+func describe(v any) string {
+	const maxQuoted = 40
+
+	if s, ok := v.(string); ok {
+		if len([]rune(s)) > maxQuoted {
+			s = string([]rune(s)[:maxQuoted]) + "…"
+		}
+		return "string " + strconv.Quote(s)
+	}
+	return jsonType(v)
+}
+
+func inEnum(v any, enum []any) bool {
+	b, err := encode(v)
+	if err != nil {
+		return false
+	}
+	for _, e := range enum {
+		if eb, err := encode(e); err == nil && bytes.Equal(b, eb) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinJSON(values []any) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		b, _ := encode(v)
+		parts = append(parts, string(b))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// escapePointer escapes a property name for use as a JSON Pointer token.
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
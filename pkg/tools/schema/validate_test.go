@@ -0,0 +1,160 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/germanamz/shelly/pkg/tools/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type validateItem struct {
+	Path string `json:"path"`
+	Line int    `json:"line,omitempty"`
+}
+
+type validateInput struct {
+	Path    string            `json:"path"`
+	Limit   int               `json:"limit,omitempty"`
+	Ratio   float64           `json:"ratio,omitempty"`
+	Force   bool              `json:"force,omitempty"`
+	Paths   []string          `json:"paths,omitempty"`
+	Items   []validateItem    `json:"items,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	Comment string            `json:"comment,omitempty"`
+}
+
+func violations(vs []schema.Violation) []string {
+	var out []string
+	for _, v := range vs {
+		out = append(out, v.String())
+	}
+	return out
+}
+
+func TestValidate(t *testing.T) {
+	s := schema.Generate[validateInput]()
+
+	assert.Empty(t, schema.Validate(s, json.RawMessage(`{"path":"a.go","limit":3,"items":[{"path":"b.go"}]}`)))
+
+	got := schema.Validate(s, json.RawMessage(`{"limit":"ten","force":1,"items":[{"line":2},{"path":"x","line":1.5}],"env":{"A":3}}`))
+	assert.Equal(t, []string{
+		"/path: required property is missing",
+		`/env/A: expected string, got integer`,
+		`/force: expected boolean, got integer`,
+		"/items/0/path: required property is missing",
+		"/items/1/line: expected integer, got number",
+		`/limit: expected integer, got string "ten"`,
+	}, violations(got))
+
+	got = schema.Validate(s, json.RawMessage(`{"path": }`))
+	require.Len(t, got, 1)
+	assert.Equal(t, "", got[0].Pointer)
+	assert.Contains(t, got[0].Message, "not valid JSON")
+	assert.Contains(t, got[0].Message, "offset")
+
+	strict := json.RawMessage(`{"type":"object","properties":{"mode":{"type":"string","enum":["fast","slow"]},"a/b":{"type":["string","null"]}},"additionalProperties":false}`)
+	assert.Equal(t, []string{
+		`/mode: must be one of: "fast", "slow"`,
+		"/other: unknown property",
+	}, violations(schema.Validate(strict, json.RawMessage(`{"mode":"medium","a/b":null,"other":1}`))))
+	assert.Equal(t, []string{"/a~1b: expected string or null, got integer"},
+		violations(schema.Validate(strict, json.RawMessage(`{"a/b":1}`))))
+
+	// Keywords the validator does not understand are ignored.
+	assert.Empty(t, schema.Validate(json.RawMessage(`{"anyOf":[{"type":"string"}]}`), json.RawMessage(`42`)))
+	assert.Empty(t, schema.Validate(nil, json.RawMessage(`[1]`)))
+}
+
+func TestCheckArguments_Repair(t *testing.T) {
+	s := schema.Generate[validateInput]()
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		repairs []string
+	}{
+		{
+			name:    "trailing commas",
+			input:   `{"path":"a.go","paths":["b","c",],}`,
+			want:    `{"path":"a.go","paths":["b","c"]}`,
+			repairs: []string{"removed trailing commas"},
+		},
+		{
+			name:    "unescaped quotes and control characters",
+			input:   "{\"path\":\"a.go\",\"comment\":\"he said \"hi\", then\tleft\"}",
+			want:    `{"path":"a.go","comment":"he said \"hi\", then\tleft"}`,
+			repairs: []string{"escaped quotes inside strings", "escaped control characters inside strings"},
+		},
+		{
+			name:    "stray backslashes",
+			input:   `{"path":"C:\dir\new.txt"}`,
+			want:    `{"path":"C:\\dir\new.txt"}`,
+			repairs: []string{"escaped stray backslashes inside strings"},
+		},
+		{
+			name:  "stringified scalars",
+			input: `{"path":"a.go","limit":"5","ratio":" 0.5","force":"TRUE","items":[{"path":"b","line":"7.0"}]}`,
+			want:  `{"force":true,"items":[{"line":7,"path":"b"}],"limit":5,"path":"a.go","ratio":0.5}`,
+			repairs: []string{
+				"/force: converted string to boolean",
+				"/items/0/line: converted string to integer",
+				"/limit: converted string to integer",
+				"/ratio: converted string to number",
+			},
+		},
+		{
+			name:  "stringified containers, numbers for strings and single values",
+			input: `{"path":42,"paths":"a.go","items":"[{\"path\":\"b\"}]","limit":null}`,
+			want:  `{"items":[{"path":"b"}],"path":"42","paths":["a.go"]}`,
+			repairs: []string{
+				"/items: converted string to array",
+				"/limit: removed null from optional property",
+				"/path: converted integer to string",
+				"/paths: converted string to array",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := schema.CheckArguments(s, json.RawMessage(tt.input), true)
+			assert.Empty(t, res.Violations)
+			assert.Equal(t, tt.repairs, res.Repairs)
+			assert.JSONEq(t, tt.want, string(res.JSON))
+
+			// Strict mode reports the problems instead.
+			strict := schema.CheckArguments(s, json.RawMessage(tt.input), false)
+			assert.NotEmpty(t, strict.Violations)
+			assert.Empty(t, strict.Repairs)
+			assert.Equal(t, tt.input, string(strict.JSON))
+		})
+	}
+}
+
+func TestCheckArguments(t *testing.T) {
+	s := schema.Generate[validateInput]()
+
+	// Valid arguments pass through untouched.
+	in := json.RawMessage(`{ "path": "a.go" }`)
+	res := schema.CheckArguments(s, in, true)
+	assert.Empty(t, res.Violations)
+	assert.Empty(t, res.Repairs)
+	assert.Equal(t, in, res.JSON)
+
+	// Empty arguments are an empty object.
+	res = schema.CheckArguments(s, nil, true)
+	assert.Equal(t, `{}`, string(res.JSON))
+	assert.Equal(t, []string{"/path: required property is missing"}, violations(res.Violations))
+
+	// What cannot be repaired is still reported.
+	res = schema.CheckArguments(s, json.RawMessage(`{"path":"a.go","limit":"many",}`), true)
+	assert.Equal(t, []string{"removed trailing commas"}, res.Repairs)
+	assert.Equal(t, []string{`/limit: expected integer, got string "many"`}, violations(res.Violations))
+
+	res = schema.CheckArguments(s, json.RawMessage(`{"path":`), true)
+	require.Len(t, res.Violations, 1)
+	assert.Contains(t, res.Violations[0].String(), "(root): not valid JSON")
+}
@@ -9,9 +9,13 @@ import (
 type Handler func(ctx context.Context, input json.RawMessage) (string, error)
 
 // Tool represents an executable tool with a name, description, JSON Schema, and handler.
+// Agents validate call arguments against InputSchema before invoking Handler.
+// Strict turns off the lenient repair of malformed arguments for the tool, so
+// that they are rejected instead.
 type Tool struct {
 	Name        string
 	Description string
 	InputSchema json.RawMessage
 	Handler     Handler
+	Strict      bool
 }
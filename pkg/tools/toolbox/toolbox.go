@@ -1,5 +1,7 @@
 package toolbox
 
+import "slices"
+
 // ToolBox orchestrates a collection of tools. It allows registering, retrieving,
 // listing, and filtering tools. Tools are stored in insertion order.
 type ToolBox struct {
@@ -74,3 +76,20 @@ func (tb *ToolBox) Filter(names []string) *ToolBox {
 	}
 	return filtered
 }
+
+// Strict returns a new ToolBox in which the named tools have Strict set, so
+// their arguments are validated without repair. Unknown names are silently
+// skipped. An empty list returns the original ToolBox unchanged.
+func (tb *ToolBox) Strict(names []string) *ToolBox {
+	if len(names) == 0 {
+		return tb
+	}
+	strict := New()
+	for _, t := range tb.items {
+		if slices.Contains(names, t.Name) {
+			t.Strict = true
+		}
+		strict.Register(t)
+	}
+	return strict
+}
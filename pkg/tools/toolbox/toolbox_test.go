@@ -197,6 +197,23 @@ func TestFilterOriginalNotMutated(t *testing.T) {
 	assert.Equal(t, 1, filtered.Len())
 }
 
+func TestStrict(t *testing.T) {
+	tb := New()
+	tb.Register(newEchoTool("a"), newEchoTool("b"))
+
+	assert.Same(t, tb, tb.Strict(nil))
+
+	strict := tb.Strict([]string{"b", "missing"})
+	a, _ := strict.Get("a")
+	b, _ := strict.Get("b")
+	assert.False(t, a.Strict)
+	assert.True(t, b.Strict)
+
+	// The original is not mutated.
+	b, _ = tb.Get("b")
+	assert.False(t, b.Strict)
+}
+
 func TestLen(t *testing.T) {
 	tb := New()
 	assert.Equal(t, 0, tb.Len())